@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
+	_ "time/tzdata" // embed timezone data for recurring schedules
 
 	"nyengo-deliveries/internal/config"
 	"nyengo-deliveries/internal/database"
@@ -16,6 +18,7 @@ import (
 	"nyengo-deliveries/internal/middleware"
 	"nyengo-deliveries/internal/repository"
 	"nyengo-deliveries/internal/services"
+	"nyengo-deliveries/internal/storage"
 	"nyengo-deliveries/internal/websocket"
 )
 
@@ -41,40 +44,155 @@ func main() {
 		log.Printf("Redis connection failed (real-time features disabled): %v", err)
 	}
 
+	// Initialize blob storage for delivery photos and signatures
+	blobStore, err := storage.NewBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize file storage: %v", err)
+	}
+
 	// Initialize repositories
 	courierRepo := repository.NewCourierRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
 	deliveryRepo := repository.NewDeliveryRepository(db)
 	paymentRepo := repository.NewPaymentRepository(db)
+	subscriptionRepo := repository.NewSubscriptionRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	orderImportRepo := repository.NewOrderImportRepository(db)
+	codRepo := repository.NewCODRepository(db)
+	scanRepo := repository.NewScanRepository(db)
+	dispatchRepo := repository.NewDispatchRepository(db)
+	driverRepo := repository.NewDriverRepository(db)
+	vehicleRepo := repository.NewVehicleRepository(db)
+	shiftRepo := repository.NewShiftRepository(db)
+	holidayRepo := repository.NewHolidayRepository(db)
 
 	// Initialize services
 	courierService := services.NewCourierService(courierRepo)
 	pricingService := services.NewPricingService(cfg)
-	orderService := services.NewOrderService(orderRepo, courierRepo, pricingService)
+	orderStateMachine := services.NewOrderStateMachine(orderRepo)
+	orderService := services.NewOrderService(orderRepo, courierRepo, pricingService, orderStateMachine)
 	notificationService := services.NewNotificationService(redisClient)
-	trackingService := services.NewTrackingService(redisClient, deliveryRepo, orderRepo)
+	trackingService := services.NewTrackingService(redisClient, deliveryRepo, orderRepo, orderStateMachine)
 	paymentService := services.NewPaymentService(paymentRepo, orderRepo, courierRepo, cfg)
 	externalCourierService := services.NewExternalCourierService(cfg)
+	cancellationService := services.NewCancellationService(orderRepo, orderStateMachine, pricingService, trackingService, notificationService, cfg)
+	proofService := services.NewProofService(orderRepo, blobStore, cfg)
+	deliveryPINService := services.NewDeliveryPINService(orderRepo, orderStateMachine, notificationService, cfg)
+
+	// Delivery handoff: send the customer a PIN when the order goes in transit and require it to deliver
+	orderStateMachine.BeforeTransition(deliveryPINService.GuardDelivery)
+	orderStateMachine.OnTransition(deliveryPINService.IssueOnTransit)
+
+	// Courier ratings and aggregates: refreshed on each delivery outcome and rating, and fully recomputed periodically
+	courierStatsService := services.NewCourierStatsService(orderRepo, courierRepo)
+	ratingService := services.NewRatingService(orderRepo, courierStatsService, notificationService)
+	orderStateMachine.OnTransition(courierStatsService.RecomputeOnTransition)
+	go courierStatsService.Run(context.Background(), cfg.CourierStatsInterval)
+
+	// Multi-stop orders: per-stop completion, store webhooks and the first en-route event
+	storeWebhookService := services.NewStoreWebhookService(paymentRepo)
+	stopService := services.NewStopService(orderRepo, orderStateMachine, trackingService, storeWebhookService)
+	orderStateMachine.OnTransition(stopService.AnnounceFirstStop)
+
+	// Scheduled pickups: reminders, activation when due and overdue flags
+	pickupScheduler := services.NewPickupScheduler(orderRepo, orderStateMachine, notificationService, storeWebhookService, cfg)
+	go pickupScheduler.Run(context.Background(), cfg.SchedulerInterval)
+
+	// Recurring deliveries: orders are generated ahead of each occurrence
+	subscriptionService := services.NewSubscriptionService(subscriptionRepo, orderService, cfg)
+	go subscriptionService.Run(context.Background(), cfg.SubscriptionInterval)
+
+	// Failed delivery attempts: re-attempt scheduling, due reminders and return-to-sender legs
+	deliveryAttemptService := services.NewDeliveryAttemptService(orderRepo, orderStateMachine, pricingService, trackingService, notificationService, storeWebhookService, cfg)
+	orderStateMachine.OnTransition(deliveryAttemptService.AnnounceReattempt)
+	orderStateMachine.OnTransition(deliveryAttemptService.CompleteReturn)
+	go deliveryAttemptService.Run(context.Background(), cfg.SchedulerInterval)
+
+	// Cash on delivery: drivers confirm the goods payment before handoff and remit it to the store
+	codService := services.NewCODService(codRepo, orderRepo, paymentRepo, notificationService, storeWebhookService, cfg)
+	exportService := services.NewExportService(orderRepo, paymentRepo, courierRepo, cfg)
+	labelService := services.NewLabelService(orderRepo, courierRepo, orderImportRepo, cfg)
+	orderStateMachine.BeforeTransition(codService.GuardDelivery)
+	orderStateMachine.OnTransition(codService.SettleOnTransition)
 
 	// Initialize WebSocket hub with Redis for cross-instance communication
 	wsHub := websocket.NewHub()
 	wsHub.SetRedis(redisClient)
 	go wsHub.Run()
 
+	// Vehicle registry: dispatch and store courier lists only offer couriers with a vehicle that can
+	// carry the package; couriers are reminded of expiring insurance and roadworthiness
+	vehicleService := services.NewVehicleService(vehicleRepo, courierRepo, notificationService, cfg)
+	go vehicleService.Run(context.Background(), cfg.SchedulerInterval)
+
+	// Shifts: couriers and drivers go online and offline over REST or the WebSocket; shifts that miss
+	// their heartbeat are closed, and only couriers on shift are dispatched or listed to stores
+	shiftService := services.NewShiftService(shiftRepo, driverRepo, notificationService, wsHub, cfg)
+	wsHub.SetShiftHandler(shiftService.HandleSocket)
+	go shiftService.Run(context.Background(), cfg.SchedulerInterval)
+
+	// Operating hours: couriers are only dispatched or listed to stores when open at the pickup time,
+	// in their own timezone and with platform and courier holidays applied
+	operatingHoursService := services.NewOperatingHoursService(holidayRepo, courierRepo, cfg)
+
+	// Automatic dispatch: orders offered to the best-scoring courier, then the next until one accepts,
+	// or broadcast to every eligible courier with the first to accept winning
+	dispatchService := services.NewDispatchService(orderService, orderRepo, courierRepo, dispatchRepo, orderStateMachine, trackingService, vehicleService, shiftService, operatingHoursService, notificationService, storeWebhookService, wsHub, cfg)
+	go dispatchService.Run(context.Background(), cfg.SchedulerInterval)
+
+	// Courier-to-courier transfers: handovers with an earnings re-split and live tracking moved over
+	transferService := services.NewTransferService(orderRepo, courierRepo, trackingService, notificationService, storeWebhookService, cfg)
+
+	// Fleet drivers: courier companies register drivers, who sign in with their own scoped tokens
+	// to work and track the orders assigned to them
+	driverService := services.NewDriverService(driverRepo, orderRepo, orderService, trackingService, notificationService, cfg)
+	wsHub.SetLocationAuthorizer(driverService.AuthorizeLocation)
+
+	// Parcel scans: chain of custody, moving orders on at pickup and when they leave for delivery
+	scanService := services.NewScanService(scanRepo, orderRepo, orderStateMachine, driverService, storeWebhookService)
+
+	// Order amendments: repricing, store approval of large increases and the amendment log
+	amendmentService := services.NewAmendmentService(orderRepo, pricingService, trackingService, notificationService, storeWebhookService, cfg)
+
+	// Bulk order imports from CSV/JSON
+	orderImportService := services.NewOrderImportService(orderImportRepo, courierRepo, orderService, pricingService, cfg)
+
+	// Idempotency keys: retried store and payment requests replay the stored response
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, cfg)
+	go idempotencyService.Run(context.Background(), cfg.IdempotencyPurgeInterval)
+
 	// Initialize handlers
 	courierHandler := handlers.NewCourierHandler(courierService)
-	orderHandler := handlers.NewOrderHandler(orderService, notificationService, wsHub)
-	pricingHandler := handlers.NewPricingHandler(pricingService)
-	storeHandler := handlers.NewStoreHandler(courierService, orderService, pricingService, externalCourierService, cfg)
-	webhookHandler := handlers.NewWebhookHandler(orderService, notificationService, orderRepo, cfg)
-	trackingHandler := handlers.NewTrackingHandler(trackingService, orderRepo)
+	orderHandler := handlers.NewOrderHandler(orderService, cancellationService, deliveryPINService, dispatchService, notificationService, wsHub)
+	pricingHandler := handlers.NewPricingHandler(pricingService, operatingHoursService)
+	storeHandler := handlers.NewStoreHandler(courierService, orderService, cancellationService, dispatchService, vehicleService, shiftService, operatingHoursService, pricingService, externalCourierService, cfg)
+	webhookHandler := handlers.NewWebhookHandler(orderService, cancellationService, notificationService, orderRepo, cfg)
+	trackingHandler := handlers.NewTrackingHandler(trackingService, driverService, orderRepo)
 	paymentHandler := handlers.NewPaymentHandler(paymentService)
+	proofHandler := handlers.NewProofHandler(proofService, blobStore, cfg)
+	ratingHandler := handlers.NewRatingHandler(ratingService)
+	stopHandler := handlers.NewStopHandler(stopService)
+	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionService)
+	orderImportHandler := handlers.NewOrderImportHandler(orderImportService)
+	amendmentHandler := handlers.NewAmendmentHandler(amendmentService)
+	deliveryAttemptHandler := handlers.NewDeliveryAttemptHandler(deliveryAttemptService)
+	codHandler := handlers.NewCODHandler(codService)
+	exportHandler := handlers.NewExportHandler(exportService, cfg.ExportTimeout)
+	labelHandler := handlers.NewLabelHandler(labelService)
+	scanHandler := handlers.NewScanHandler(scanService)
+	dispatchHandler := handlers.NewDispatchHandler(dispatchService)
+	transferHandler := handlers.NewTransferHandler(transferService)
+	driverHandler := handlers.NewDriverHandler(driverService)
+	vehicleHandler := handlers.NewVehicleHandler(vehicleService)
+	shiftHandler := handlers.NewShiftHandler(shiftService)
+	operatingHoursHandler := handlers.NewOperatingHoursHandler(operatingHoursService)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:      "Nyengo Deliveries API",
 		ServerHeader: "Nyengo",
 		ErrorHandler: handlers.CustomErrorHandler,
+		BodyLimit:    2*cfg.MaxUploadSize + 1024*1024, // Photo + signature + form fields
 	})
 
 	// Global middleware
@@ -83,7 +201,7 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.AllowedOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-API-Key,X-Webhook-Secret",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-API-Key,X-Webhook-Secret,Idempotency-Key",
 		AllowCredentials: true,
 	}))
 
@@ -153,18 +271,135 @@ func main() {
 					"available": "GET /api/v1/couriers/available",
 					"rates":     "GET /api/v1/couriers/:id/rates",
 				},
+				"shifts": fiber.Map{
+					"online":           "POST /api/v1/couriers/shift/online",
+					"offline":          "POST /api/v1/couriers/shift/offline",
+					"heartbeat":        "POST /api/v1/couriers/shift/heartbeat",
+					"status":           "GET /api/v1/couriers/shift",
+					"history":          "GET /api/v1/couriers/shifts?driverId=&from=&to=",
+					"availability":     "GET /api/v1/couriers/availability",
+					"driver_online":    "POST /api/v1/drivers/me/shift/online",
+					"driver_offline":   "POST /api/v1/drivers/me/shift/offline",
+					"driver_heartbeat": "POST /api/v1/drivers/me/shift/heartbeat",
+					"driver_status":    "GET /api/v1/drivers/me/shift",
+					"driver_history":   "GET /api/v1/drivers/me/shifts?from=&to=",
+					"websocket":        "WS /ws (type shift, action online|offline|heartbeat)",
+				},
+				"operating_hours": fiber.Map{
+					"hours":           "GET /api/v1/couriers/hours",
+					"update_hours":    "PUT /api/v1/couriers/profile (operatingHours, timezone)",
+					"holidays":        "GET /api/v1/couriers/holidays?from=&to=",
+					"add_holiday":     "POST /api/v1/couriers/holidays",
+					"delete_holiday":  "DELETE /api/v1/couriers/holidays/:id",
+					"store_hours":     "GET /api/v1/stores/couriers/:id/hours?pickupAt=",
+					"platform_list":   "GET /api/v1/admin/holidays?country=&from=&to=",
+					"platform_add":    "POST /api/v1/admin/holidays",
+					"platform_delete": "DELETE /api/v1/admin/holidays/:id",
+				},
+				"vehicles": fiber.Map{
+					"register": "POST /api/v1/couriers/vehicles",
+					"list":     "GET /api/v1/couriers/vehicles?type=&active=",
+					"get":      "GET /api/v1/couriers/vehicles/:id",
+					"update":   "PUT /api/v1/couriers/vehicles/:id",
+					"delete":   "DELETE /api/v1/couriers/vehicles/:id",
+				},
+				"drivers": fiber.Map{
+					"register":       "POST /api/v1/couriers/drivers",
+					"list":           "GET /api/v1/couriers/drivers?status=",
+					"get":            "GET /api/v1/couriers/drivers/:id",
+					"update":         "PUT /api/v1/couriers/drivers/:id",
+					"invite":         "POST /api/v1/couriers/drivers/:id/invite",
+					"orders":         "GET /api/v1/couriers/drivers/:id/orders",
+					"earnings":       "GET /api/v1/couriers/drivers/:id/earnings?from=&to=",
+					"performance":    "GET /api/v1/couriers/drivers/:id/performance",
+					"assign":         "POST /api/v1/orders/:id/driver",
+					"unassign":       "DELETE /api/v1/orders/:id/driver",
+					"activate":       "POST /api/v1/drivers/activate",
+					"login":          "POST /api/v1/drivers/login",
+					"me":             "GET /api/v1/drivers/me",
+					"my_assignments": "GET /api/v1/drivers/me/assignments?status=",
+					"my_orders":      "GET /api/v1/drivers/me/orders",
+					"my_earnings":    "GET /api/v1/drivers/me/earnings?from=&to=",
+					"my_performance": "GET /api/v1/drivers/me/performance",
+					"accept":         "POST /api/v1/drivers/me/orders/:id/accept",
+					"reject":         "POST /api/v1/drivers/me/orders/:id/reject",
+				},
 				"stores": fiber.Map{
-					"list_couriers": "GET /api/v1/stores/couriers",
+					"list_couriers": "GET /api/v1/stores/couriers?pickupAt=&includeClosed=",
 					"create_order":  "POST /api/v1/stores/orders",
+					"list_orders":   "GET /api/v1/stores/orders?storeId=",
+					"import_orders": "POST /api/v1/stores/orders/import",
+					"import_status": "GET /api/v1/stores/orders/imports/:id",
+					"import_report": "GET /api/v1/stores/orders/imports/:id/report",
+					"import_labels": "GET /api/v1/stores/orders/imports/:id/labels",
 					"order_status":  "GET /api/v1/stores/orders/:id/status",
+					"order_label":   "GET /api/v1/stores/orders/:id/label?format=pdf|zpl&size=4x6|a6",
+					"batch_labels":  "POST /api/v1/stores/orders/labels",
+					"cancel_order":  "POST /api/v1/stores/orders/:id/cancel",
+					"order_proof":   "GET /api/v1/stores/orders/:id/proof",
+					"stop_proof":    "GET /api/v1/stores/orders/:id/stops/:stopId/proof",
+					"rate_order":    "POST /api/v1/stores/orders/:id/rating",
+					"amend_order":   "PATCH /api/v1/stores/orders/:id",
+					"amendments":    "GET /api/v1/stores/orders/:id/amendments",
+					"approve_amend": "POST /api/v1/stores/orders/:id/amendments/:amendmentId/approve",
+					"reject_amend":  "POST /api/v1/stores/orders/:id/amendments/:amendmentId/reject",
+					"attempts":      "GET /api/v1/stores/orders/:id/attempts",
+					"custody":       "GET /api/v1/stores/orders/:id/custody",
+					"dispatch_log":  "GET /api/v1/stores/orders/:id/dispatch",
+					"assign":        "POST /api/v1/stores/orders/:id/assign",
+					"transfers":     "GET /api/v1/stores/orders/:id/transfers",
+				},
+				"cod": fiber.Map{
+					"remittances": "GET /api/v1/stores/cod/remittances?storeId=",
+					"confirm":     "POST /api/v1/stores/cod/remittances/:id/confirm?storeId=",
+					"reject":      "POST /api/v1/stores/cod/remittances/:id/reject?storeId=",
+					"settlement":  "GET /api/v1/stores/cod/settlement?storeId=&from=&to=&format=csv",
+				},
+				"subscriptions": fiber.Map{
+					"create":      "POST /api/v1/stores/subscriptions",
+					"list":        "GET /api/v1/stores/subscriptions?storeId=",
+					"get":         "GET /api/v1/stores/subscriptions/:id",
+					"occurrences": "GET /api/v1/stores/subscriptions/:id/occurrences",
+					"pause":       "POST /api/v1/stores/subscriptions/:id/pause",
+					"resume":      "POST /api/v1/stores/subscriptions/:id/resume",
+					"skip":        "POST /api/v1/stores/subscriptions/:id/skip",
+					"end":         "POST /api/v1/stores/subscriptions/:id/end",
 				},
 				"orders": fiber.Map{
 					"create":        "POST /api/v1/orders",
 					"list":          "GET /api/v1/orders",
+					"export":        "GET /api/v1/orders/export?format=csv|xlsx|pdf",
 					"get":           "GET /api/v1/orders/:id",
+					"amend":         "PATCH /api/v1/orders/:id",
+					"amendments":    "GET /api/v1/orders/:id/amendments",
 					"update_status": "PUT /api/v1/orders/:id/status",
+					"offers":        "GET /api/v1/orders/offers",
 					"accept":        "PUT /api/v1/orders/:id/accept",
 					"decline":       "PUT /api/v1/orders/:id/decline",
+					"cancel":        "POST /api/v1/orders/:id/cancel",
+					"upload_proof":  "POST /api/v1/orders/:id/proof",
+					"get_proof":     "GET /api/v1/orders/:id/proof",
+					"deliver":       "POST /api/v1/orders/:id/deliver",
+					"resend_pin":    "POST /api/v1/orders/:id/pin/resend",
+					"fail_attempt":  "POST /api/v1/orders/:id/attempts",
+					"attempts":      "GET /api/v1/orders/:id/attempts",
+					"collect_cod":   "POST /api/v1/orders/:id/cod",
+					"label":         "GET /api/v1/orders/:id/label?format=pdf|zpl&size=4x6|a6",
+					"custody":       "GET /api/v1/orders/:id/custody",
+					"stop_status":   "PUT /api/v1/orders/:id/stops/:stopId/status",
+					"stop_proof":    "POST /api/v1/orders/:id/stops/:stopId/proof",
+				},
+				"transfers": fiber.Map{
+					"propose":       "POST /api/v1/orders/:id/transfer",
+					"log":           "GET /api/v1/orders/:id/transfers",
+					"incoming":      "GET /api/v1/orders/transfers",
+					"accept":        "POST /api/v1/orders/transfers/:transferId/accept",
+					"reject":        "POST /api/v1/orders/transfers/:transferId/reject",
+					"cancel":        "POST /api/v1/orders/transfers/:transferId/cancel",
+					"admin_propose": "POST /api/v1/admin/orders/:id/transfer",
+				},
+				"scans": fiber.Map{
+					"scan": "POST /api/v1/scans",
 				},
 				"tracking": fiber.Map{
 					"live":    "GET /api/v1/tracking/:orderId",
@@ -172,14 +407,21 @@ func main() {
 					"start":   "POST /api/v1/tracking/:orderId/start",
 					"update":  "POST /api/v1/tracking/:orderId/location",
 					"stop":    "POST /api/v1/tracking/:orderId/stop",
+					"rate":    "POST /api/v1/tracking/:orderId/rating",
 				},
 				"payments": fiber.Map{
-					"verify":       "GET /api/v1/payments/verify/:orderId",
-					"payable":      "GET /api/v1/payments/payable-orders",
-					"request":      "POST /api/v1/payments/payouts",
-					"history":      "GET /api/v1/payments/payouts",
-					"earnings":     "GET /api/v1/payments/earnings",
-					"transactions": "GET /api/v1/payments/wallet/transactions",
+					"verify":         "GET /api/v1/payments/verify/:orderId",
+					"payable":        "GET /api/v1/payments/payable-orders",
+					"request":        "POST /api/v1/payments/payouts",
+					"history":        "GET /api/v1/payments/payouts",
+					"earnings":       "GET /api/v1/payments/earnings",
+					"transactions":   "GET /api/v1/payments/wallet/transactions",
+					"export_tx":      "GET /api/v1/payments/wallet/transactions/export?format=csv|xlsx|pdf",
+					"export_payouts": "GET /api/v1/payments/payouts/export?format=csv|xlsx|pdf",
+					"cod":            "GET /api/v1/payments/cod",
+					"remit":          "POST /api/v1/payments/cod/remittances",
+					"remittances":    "GET /api/v1/payments/cod/remittances",
+					"remittance":     "GET /api/v1/payments/cod/remittances/:id",
 				},
 				"pricing": fiber.Map{
 					"estimate": "POST /api/v1/pricing/estimate",
@@ -198,6 +440,8 @@ func main() {
 	// Public routes
 	api.Post("/couriers/register", courierHandler.Register)
 	api.Post("/couriers/login", courierHandler.Login)
+	api.Post("/drivers/activate", driverHandler.Activate)
+	api.Post("/drivers/login", driverHandler.Login)
 
 	// Pricing routes (public for stores)
 	pricing := api.Group("/pricing")
@@ -206,9 +450,43 @@ func main() {
 	// Store integration routes (API key authenticated)
 	stores := api.Group("/stores")
 	stores.Use(middleware.APIKeyAuth(cfg))
+	stores.Use(middleware.Idempotency(idempotencyService))
 	stores.Get("/couriers", storeHandler.ListCouriers)
+	stores.Get("/couriers/:id/hours", operatingHoursHandler.StoreHours)
 	stores.Post("/orders", storeHandler.CreateOrder)
+	stores.Get("/orders", storeHandler.ListOrders)
+	stores.Post("/orders/import", orderImportHandler.Import)
+	stores.Get("/orders/imports/:id", orderImportHandler.Get)
+	stores.Get("/orders/imports/:id/report", orderImportHandler.Report)
+	stores.Get("/orders/imports/:id/labels", labelHandler.Import)
+	stores.Post("/orders/labels", labelHandler.Batch)
 	stores.Get("/orders/:id/status", storeHandler.GetOrderStatus)
+	stores.Get("/orders/:id/label", labelHandler.StoreOrder)
+	stores.Post("/orders/:id/cancel", storeHandler.CancelOrder)
+	stores.Get("/orders/:id/proof", proofHandler.StoreGetProof)
+	stores.Get("/orders/:id/stops/:stopId/proof", proofHandler.StoreGetStopProof)
+	stores.Post("/orders/:id/rating", ratingHandler.StoreRateOrder)
+	stores.Patch("/orders/:id", amendmentHandler.StoreAmend)
+	stores.Get("/orders/:id/amendments", amendmentHandler.StoreList)
+	stores.Post("/orders/:id/amendments/:amendmentId/approve", amendmentHandler.Approve)
+	stores.Post("/orders/:id/amendments/:amendmentId/reject", amendmentHandler.Reject)
+	stores.Get("/orders/:id/attempts", deliveryAttemptHandler.StoreList)
+	stores.Get("/orders/:id/custody", scanHandler.StoreCustody)
+	stores.Get("/orders/:id/dispatch", dispatchHandler.StoreLog)
+	stores.Post("/orders/:id/assign", dispatchHandler.StoreAssign)
+	stores.Get("/orders/:id/transfers", transferHandler.StoreList)
+	stores.Get("/cod/remittances", codHandler.StoreListRemittances)
+	stores.Post("/cod/remittances/:id/confirm", codHandler.ConfirmRemittance)
+	stores.Post("/cod/remittances/:id/reject", codHandler.RejectRemittance)
+	stores.Get("/cod/settlement", codHandler.Settlement)
+	stores.Post("/subscriptions", subscriptionHandler.Create)
+	stores.Get("/subscriptions", subscriptionHandler.List)
+	stores.Get("/subscriptions/:id", subscriptionHandler.Get)
+	stores.Get("/subscriptions/:id/occurrences", subscriptionHandler.Upcoming)
+	stores.Post("/subscriptions/:id/pause", subscriptionHandler.Pause)
+	stores.Post("/subscriptions/:id/resume", subscriptionHandler.Resume)
+	stores.Post("/subscriptions/:id/skip", subscriptionHandler.Skip)
+	stores.Post("/subscriptions/:id/end", subscriptionHandler.End)
 
 	// Protected courier routes
 	couriers := api.Group("/couriers")
@@ -217,6 +495,29 @@ func main() {
 	couriers.Put("/profile", courierHandler.UpdateProfile)
 	couriers.Get("/dashboard", courierHandler.GetDashboard)
 	couriers.Get("/available", courierHandler.ListAvailable)
+	couriers.Post("/drivers", driverHandler.Register)
+	couriers.Get("/drivers", driverHandler.List)
+	couriers.Get("/drivers/:id", driverHandler.Get)
+	couriers.Put("/drivers/:id", driverHandler.Update)
+	couriers.Post("/drivers/:id/invite", driverHandler.Invite)
+	couriers.Get("/drivers/:id/orders", driverHandler.Orders)
+	couriers.Get("/drivers/:id/earnings", driverHandler.Earnings)
+	couriers.Get("/drivers/:id/performance", driverHandler.Performance)
+	couriers.Get("/shift", shiftHandler.Status)
+	couriers.Post("/shift/online", shiftHandler.Online)
+	couriers.Post("/shift/offline", shiftHandler.Offline)
+	couriers.Post("/shift/heartbeat", shiftHandler.Heartbeat)
+	couriers.Get("/shifts", shiftHandler.History)
+	couriers.Get("/availability", shiftHandler.Availability)
+	couriers.Get("/hours", operatingHoursHandler.Hours)
+	couriers.Get("/holidays", operatingHoursHandler.ListHolidays)
+	couriers.Post("/holidays", operatingHoursHandler.AddHoliday)
+	couriers.Delete("/holidays/:id", operatingHoursHandler.DeleteHoliday)
+	couriers.Post("/vehicles", vehicleHandler.Register)
+	couriers.Get("/vehicles", vehicleHandler.List)
+	couriers.Get("/vehicles/:id", vehicleHandler.Get)
+	couriers.Put("/vehicles/:id", vehicleHandler.Update)
+	couriers.Delete("/vehicles/:id", vehicleHandler.Delete)
 	couriers.Get("/:id/rates", courierHandler.GetRates)
 
 	// Protected order routes
@@ -224,13 +525,63 @@ func main() {
 	orders.Use(middleware.JWTAuth(cfg.JWTSecret))
 	orders.Post("/", orderHandler.Create)
 	orders.Get("/", orderHandler.List)
+	orders.Get("/export", exportHandler.Orders)
+	orders.Get("/offers", dispatchHandler.Offers)
+	orders.Get("/transfers", transferHandler.Incoming)
+	orders.Post("/transfers/:transferId/accept", transferHandler.Accept)
+	orders.Post("/transfers/:transferId/reject", transferHandler.Reject)
+	orders.Post("/transfers/:transferId/cancel", transferHandler.Cancel)
 	orders.Get("/:id", orderHandler.GetByID)
+	orders.Patch("/:id", amendmentHandler.Amend)
+	orders.Get("/:id/amendments", amendmentHandler.List)
 	orders.Put("/:id/status", orderHandler.UpdateStatus)
 	orders.Put("/:id/accept", orderHandler.Accept)
 	orders.Put("/:id/decline", orderHandler.Decline)
+	orders.Post("/:id/cancel", orderHandler.Cancel)
+	orders.Post("/:id/proof", proofHandler.Upload)
+	orders.Get("/:id/proof", proofHandler.GetProof)
+	orders.Post("/:id/deliver", orderHandler.ConfirmDelivery)
+	orders.Post("/:id/pin/resend", orderHandler.ResendDeliveryPIN)
+	orders.Post("/:id/attempts", deliveryAttemptHandler.RecordFailure)
+	orders.Get("/:id/attempts", deliveryAttemptHandler.List)
+	orders.Get("/:id/label", labelHandler.CourierOrder)
+	orders.Get("/:id/custody", scanHandler.Custody)
+	orders.Post("/:id/cod", codHandler.Collect)
+	orders.Post("/:id/transfer", transferHandler.Propose)
+	orders.Get("/:id/transfers", transferHandler.List)
+	orders.Post("/:id/driver", driverHandler.AssignOrder)
+	orders.Delete("/:id/driver", driverHandler.UnassignOrder)
+	orders.Put("/:id/stops/:stopId/status", stopHandler.UpdateStatus)
+	orders.Post("/:id/stops/:stopId/proof", proofHandler.UploadStopProof)
+
+	// Driver routes (driver-scoped token)
+	drivers := api.Group("/drivers/me")
+	drivers.Use(middleware.DriverAuth(cfg.JWTSecret))
+	drivers.Get("/", driverHandler.Me)
+	drivers.Get("/assignments", driverHandler.MyAssignments)
+	drivers.Get("/orders", driverHandler.MyOrders)
+	drivers.Get("/earnings", driverHandler.MyEarnings)
+	drivers.Get("/performance", driverHandler.MyPerformance)
+	drivers.Post("/orders/:id/accept", driverHandler.AcceptAssignment)
+	drivers.Post("/orders/:id/reject", driverHandler.RejectAssignment)
+	drivers.Get("/shift", shiftHandler.Status)
+	drivers.Post("/shift/online", shiftHandler.Online)
+	drivers.Post("/shift/offline", shiftHandler.Offline)
+	drivers.Post("/shift/heartbeat", shiftHandler.Heartbeat)
+	drivers.Get("/shifts", shiftHandler.History)
+
+	// Parcel scans by drivers and hub staff
+	scans := api.Group("/scans")
+	scans.Use(middleware.CourierOrDriverAuth(cfg.JWTSecret))
+	scans.Post("/", scanHandler.Scan)
+
+	// Signed file downloads (only needed when files are stored locally)
+	if proofHandler.ServesFiles() {
+		api.Get("/files/*", proofHandler.ServeFile)
+	}
 
 	// WebSocket endpoint for real-time updates
-	app.Get("/ws", middleware.JWTAuth(cfg.JWTSecret), websocket.HandleWebSocket(wsHub))
+	app.Get("/ws", middleware.CourierOrDriverAuth(cfg.JWTSecret), websocket.HandleWebSocket(wsHub))
 
 	// Webhook routes
 	webhooks := api.Group("/webhooks")
@@ -239,22 +590,30 @@ func main() {
 
 	// Tracking routes - live location tracking
 	tracking := api.Group("/tracking")
-	tracking.Get("/:orderId", trackingHandler.GetLiveTracking)                                             // Get current tracking
-	tracking.Get("/:orderId/history", trackingHandler.GetLocationHistory)                                  // Get location history
-	tracking.Post("/:orderId/start", middleware.JWTAuth(cfg.JWTSecret), trackingHandler.StartTracking)     // Start tracking
-	tracking.Post("/:orderId/location", middleware.JWTAuth(cfg.JWTSecret), trackingHandler.UpdateLocation) // Update location
-	tracking.Post("/:orderId/stop", middleware.JWTAuth(cfg.JWTSecret), trackingHandler.StopTracking)       // Stop tracking
+	tracking.Get("/:orderId", trackingHandler.GetLiveTracking)                                                         // Get current tracking
+	tracking.Get("/:orderId/history", trackingHandler.GetLocationHistory)                                              // Get location history
+	tracking.Post("/:orderId/start", middleware.CourierOrDriverAuth(cfg.JWTSecret), trackingHandler.StartTracking)     // Start tracking
+	tracking.Post("/:orderId/location", middleware.CourierOrDriverAuth(cfg.JWTSecret), trackingHandler.UpdateLocation) // Update location
+	tracking.Post("/:orderId/stop", middleware.CourierOrDriverAuth(cfg.JWTSecret), trackingHandler.StopTracking)       // Stop tracking
+	tracking.Post("/:orderId/rating", ratingHandler.CustomerRateOrder)                                                 // Customer rating from tracking link
 
 	// Payment and Payout routes (courier authenticated)
 	payments := api.Group("/payments")
 	payments.Use(middleware.JWTAuth(cfg.JWTSecret))
-	payments.Get("/verify/:orderId", paymentHandler.VerifyPayment)             // Verify order payment
-	payments.Get("/payable-orders", paymentHandler.GetPayableOrders)           // Get orders eligible for payout
-	payments.Post("/payouts", paymentHandler.RequestPayout)                    // Request payout
-	payments.Get("/payouts", paymentHandler.GetPayoutHistory)                  // Get payout history
-	payments.Get("/payouts/:payoutId", paymentHandler.GetPayoutByID)           // Get specific payout
-	payments.Get("/earnings", paymentHandler.GetEarningsSummary)               // Get earnings summary
-	payments.Get("/wallet/transactions", paymentHandler.GetWalletTransactions) // Get wallet transactions
+	payments.Use(middleware.Idempotency(idempotencyService))
+	payments.Get("/verify/:orderId", paymentHandler.VerifyPayment)                // Verify order payment
+	payments.Get("/payable-orders", paymentHandler.GetPayableOrders)              // Get orders eligible for payout
+	payments.Post("/payouts", paymentHandler.RequestPayout)                       // Request payout
+	payments.Get("/payouts", paymentHandler.GetPayoutHistory)                     // Get payout history
+	payments.Get("/payouts/export", exportHandler.Payouts)                        // Export payouts
+	payments.Get("/payouts/:payoutId", paymentHandler.GetPayoutByID)              // Get specific payout
+	payments.Get("/earnings", paymentHandler.GetEarningsSummary)                  // Get earnings summary
+	payments.Get("/wallet/transactions", paymentHandler.GetWalletTransactions)    // Get wallet transactions
+	payments.Get("/wallet/transactions/export", exportHandler.WalletTransactions) // Export wallet transactions
+	payments.Get("/cod", codHandler.Summary)                                      // Cash-on-delivery liability
+	payments.Post("/cod/remittances", codHandler.CreateRemittance)                // Remit collected cash to a store
+	payments.Get("/cod/remittances", codHandler.ListRemittances)                  // Remittance history
+	payments.Get("/cod/remittances/:id", codHandler.GetRemittance)                // Remittance with its orders
 
 	// Store payment verification routes (API key authenticated)
 	stores.Get("/payments/verify/:orderId", paymentHandler.StoreVerifyPayment)
@@ -262,10 +621,15 @@ func main() {
 	// Admin payment management routes (should have admin auth in production)
 	admin := api.Group("/admin")
 	admin.Use(middleware.JWTAuth(cfg.JWTSecret)) // TODO: Add admin role check
-	admin.Post("/payments/payouts/:payoutId/process", paymentHandler.ProcessPayout)
+	admin.Post("/payments/payouts/:payoutId/process", middleware.Idempotency(idempotencyService), paymentHandler.ProcessPayout)
+	admin.Post("/orders/:id/transfer", middleware.AdminOnly(cfg), transferHandler.AdminPropose)
+	admin.Get("/holidays", middleware.AdminOnly(cfg), operatingHoursHandler.ListPlatformHolidays)
+	admin.Post("/holidays", middleware.AdminOnly(cfg), operatingHoursHandler.AddPlatformHoliday)
+	admin.Delete("/holidays/:id", middleware.AdminOnly(cfg), operatingHoursHandler.DeletePlatformHoliday)
 
 	log.Printf("📍 Live tracking enabled")
 	log.Printf("💳 Payment & Payout system enabled")
+	log.Printf("📷 Proof-of-delivery storage: %s", cfg.StorageDriver)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -32,6 +32,62 @@ type Config struct {
 	SurgePricingMult float64 // Surge pricing multiplier (1.0 = no surge)
 	PlatformFeePerc  float64 // Platform fee percentage (e.g., 0.15 for 15%)
 
+	// Multi-stop orders
+	MultiStopFeePerStop float64 // Flat fee for each drop-off after the first
+	MaxStopsPerOrder    int     // Maximum drop-offs on one order
+
+	// Order metadata
+	MaxMetadataKeys  int // Maximum top-level keys in an order's metadata
+	MaxMetadataBytes int // Maximum size of an order's metadata as JSON
+
+	// Order amendments
+	AmendmentApprovalThreshold float64 // Fare increase (fraction of the current fare) above which the store must approve
+
+	// Bulk order imports
+	MaxImportRows int // Maximum rows in one CSV/JSON import
+
+	// Order and earnings exports
+	ExportTimeout time.Duration // Longest a streamed CSV/XLSX/PDF export may run
+
+	// Shipping labels
+	TrackingPageURL string // Customer tracking page; label QR codes link to <url>/<order ID>
+
+	// Failed delivery attempts
+	MaxDeliveryAttempts int           // Attempts (including the first) before the parcel is returned to sender
+	ReattemptDelay      time.Duration // How long after a failed attempt the next one is scheduled
+
+	// Automatic dispatch
+	DispatchOfferTimeout    time.Duration // How long a courier has to accept an offer before the next candidate is tried
+	DispatchMaxRadiusKm     float64       // Couriers whose live position is further from the pickup are skipped
+	DispatchMaxActiveOrders int           // Couriers with this many open orders per available shift are skipped
+	DispatchMaxOffers       int           // Offers made before the order is given up as undispatchable
+
+	// Broadcast offers (first courier to accept wins)
+	BroadcastWindow             time.Duration // How long a broadcast stays open before it escalates
+	BroadcastEscalationRadiusKm float64       // Radius of the second, wider broadcast; 0 hands straight to the store
+
+	// Courier-to-courier order transfers
+	TransferRequestTTL    time.Duration // How long the receiving courier has to accept a handover
+	TransferEarningsRule  string        // How earnings are split on a handover: progress, fixed or receiver
+	TransferOriginalShare float64       // Fraction kept by the handing-over courier under the fixed rule (and progress without a live position)
+
+	// Drivers
+	DriverInviteTTL         time.Duration // How long a driver's invite code can be used to set a password
+	DriverInviteMaxAttempts int           // Wrong invite codes allowed for a phone number before activation is locked
+	DriverInviteLockout     time.Duration // How long activation stays locked
+
+	// Vehicles
+	VehicleExpiryReminderLead time.Duration // How long before a vehicle's insurance or roadworthiness expires the courier is reminded
+
+	// Shifts
+	ShiftHeartbeatTimeout time.Duration // An online courier or driver without a heartbeat for this long is set offline
+	ShiftMaxDuration      time.Duration // Shifts open for longer are not offered new jobs (0 disables)
+
+	// Cancellation fee policy (fraction of total fare charged when a store cancels)
+	CancellationFeePending  float64 // Before the courier accepts
+	CancellationFeeAccepted float64 // After acceptance, before pickup
+	CancellationFeePickedUp float64 // After the parcel has been collected
+
 	// Distance calculation settings
 	MaxDeliveryDistance    float64 // Maximum delivery distance in km
 	FreeDeliveryRadius     float64 // Free delivery radius in km (if applicable)
@@ -53,8 +109,49 @@ type Config struct {
 	// Store API Keys (for third-party store integrations)
 	StoreAPIKeys []string
 
+	// Courier accounts allowed to use admin-only endpoints
+	AdminCourierIDs []string
+
 	// Webhook settings
 	WebhookSecret string // Shared secret for delivery webhook authentication
+
+	// Delivery PIN settings (handoff confirmation)
+	DeliveryPINLength      int           // Number of digits in the PIN sent to the customer
+	DeliveryPINMaxAttempts int           // Wrong PINs allowed before submissions are locked
+	DeliveryPINLockout     time.Duration // How long submissions stay locked
+
+	// Background jobs
+	CourierStatsInterval     time.Duration // How often courier rating/delivery aggregates are fully recomputed
+	SchedulerInterval        time.Duration // How often scheduled pickups are checked
+	IdempotencyPurgeInterval time.Duration // How often expired idempotency keys are deleted
+
+	// Idempotency keys (safe retries of store and payment requests)
+	IdempotencyKeyTTL time.Duration // How long a key's stored response is replayed
+
+	// Scheduled pickups
+	ScheduleReminderLead   time.Duration // How long before pickup the courier is reminded
+	ScheduleActivationLead time.Duration // How long before pickup a scheduled order enters the active queue
+
+	// Recurring delivery subscriptions
+	DefaultTimezone      string        // IANA timezone used when a schedule does not name one
+	SubscriptionLeadTime time.Duration // How far ahead recurring orders are generated
+	SubscriptionInterval time.Duration // How often subscriptions are checked for due occurrences
+
+	// File storage settings (proof-of-delivery photos, signatures)
+	StorageDriver        string        // "local" or "s3"
+	StorageLocalPath     string        // Root directory for the local driver
+	StoragePublicURL     string        // Public base URL of this API, used for local download links
+	StorageSigningSecret string        // Secret used to sign local download links
+	SignedURLTTL         time.Duration // Lifetime of generated download links
+	MaxUploadSize        int           // Maximum accepted upload size in bytes
+	MaxImagePixels       int           // Maximum width*height of an uploaded image, checked before decoding
+
+	// S3-compatible storage settings (AWS S3, MinIO)
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
 }
 
 // CurrencyPresets contains preset configurations for different currencies
@@ -165,6 +262,62 @@ func LoadConfig() *Config {
 		SurgePricingMult: getFloatEnv("SURGE_MULTIPLIER", 1.0),      // No surge by default
 		PlatformFeePerc:  getFloatEnv("PLATFORM_FEE_PERCENT", 0.10), // 10% platform fee
 
+		// Multi-stop defaults
+		MultiStopFeePerStop: getFloatEnv("MULTI_STOP_FEE_PER_STOP", 10.0), // K10 per extra drop-off
+		MaxStopsPerOrder:    getIntEnv("MAX_STOPS_PER_ORDER", 10),
+
+		// Order metadata defaults
+		MaxMetadataKeys:  getIntEnv("ORDER_METADATA_MAX_KEYS", 50),
+		MaxMetadataBytes: getIntEnv("ORDER_METADATA_MAX_BYTES", 8192),
+
+		// Order amendment defaults
+		AmendmentApprovalThreshold: getFloatEnv("AMENDMENT_APPROVAL_THRESHOLD", 0.10), // Increases over 10% need store approval
+
+		// Bulk import defaults
+		MaxImportRows: getIntEnv("MAX_IMPORT_ROWS", 500),
+
+		// Export defaults
+		ExportTimeout: getDurationEnv("EXPORT_TIMEOUT", 5*time.Minute),
+
+		// Shipping label defaults
+		TrackingPageURL: getEnv("TRACKING_PAGE_URL", "http://localhost:3000/track"),
+
+		// Failed delivery attempt defaults
+		MaxDeliveryAttempts: getIntEnv("MAX_DELIVERY_ATTEMPTS", 3),
+		ReattemptDelay:      getDurationEnv("REATTEMPT_DELAY", 24*time.Hour), // Try again the next day
+
+		// Automatic dispatch defaults
+		DispatchOfferTimeout:    getDurationEnv("DISPATCH_OFFER_TIMEOUT", 2*time.Minute),
+		DispatchMaxRadiusKm:     getFloatEnv("DISPATCH_MAX_RADIUS_KM", 15),
+		DispatchMaxActiveOrders: getIntEnv("DISPATCH_MAX_ACTIVE_ORDERS", 5),
+		DispatchMaxOffers:       getIntEnv("DISPATCH_MAX_OFFERS", 5),
+
+		// Broadcast offer defaults
+		BroadcastWindow:             getDurationEnv("BROADCAST_WINDOW", 3*time.Minute),
+		BroadcastEscalationRadiusKm: getFloatEnv("BROADCAST_ESCALATION_RADIUS_KM", 30),
+
+		// Order transfer defaults
+		TransferRequestTTL:    getDurationEnv("TRANSFER_REQUEST_TTL", 15*time.Minute),
+		TransferEarningsRule:  getEnv("TRANSFER_EARNINGS_RULE", "progress"),
+		TransferOriginalShare: getFloatEnv("TRANSFER_ORIGINAL_SHARE", 0.30),
+
+		// Driver defaults
+		DriverInviteTTL:         getDurationEnv("DRIVER_INVITE_TTL", 72*time.Hour),
+		DriverInviteMaxAttempts: getIntEnv("DRIVER_INVITE_MAX_ATTEMPTS", 5),
+		DriverInviteLockout:     getDurationEnv("DRIVER_INVITE_LOCKOUT", 15*time.Minute),
+
+		// Vehicle defaults
+		VehicleExpiryReminderLead: getDurationEnv("VEHICLE_EXPIRY_REMINDER_LEAD", 30*24*time.Hour),
+
+		// Shift defaults
+		ShiftHeartbeatTimeout: getDurationEnv("SHIFT_HEARTBEAT_TIMEOUT", 5*time.Minute),
+		ShiftMaxDuration:      getDurationEnv("SHIFT_MAX_DURATION", 12*time.Hour),
+
+		// Cancellation fee defaults
+		CancellationFeePending:  getFloatEnv("CANCELLATION_FEE_PENDING", 0.0),    // Free before acceptance
+		CancellationFeeAccepted: getFloatEnv("CANCELLATION_FEE_ACCEPTED", 0.25),  // 25% once a courier is committed
+		CancellationFeePickedUp: getFloatEnv("CANCELLATION_FEE_PICKED_UP", 0.50), // 50% once the parcel is collected
+
 		// Distance defaults
 		MaxDeliveryDistance:    getFloatEnv("MAX_DELIVERY_DISTANCE", 50.0),    // 50km max
 		FreeDeliveryRadius:     getFloatEnv("FREE_DELIVERY_RADIUS", 0.0),      // No free delivery
@@ -186,8 +339,53 @@ func LoadConfig() *Config {
 		// Store API Keys (comma-separated in env)
 		StoreAPIKeys: getStoreAPIKeys(),
 
+		// Admin courier accounts (comma-separated in env; none by default)
+		AdminCourierIDs: getListEnv("ADMIN_COURIER_IDS"),
+
 		// Webhook settings
 		WebhookSecret: getEnv("WEBHOOK_SECRET", "nyg_webhook_secret_dev_2024"),
+
+		// Delivery PIN defaults
+		DeliveryPINLength:      getIntEnv("DELIVERY_PIN_LENGTH", 6),
+		DeliveryPINMaxAttempts: getIntEnv("DELIVERY_PIN_MAX_ATTEMPTS", 5),
+		DeliveryPINLockout:     getDurationEnv("DELIVERY_PIN_LOCKOUT", 15*time.Minute),
+
+		// Background job defaults
+		CourierStatsInterval:     getDurationEnv("COURIER_STATS_INTERVAL", time.Hour),
+		SchedulerInterval:        getDurationEnv("SCHEDULER_INTERVAL", time.Minute),
+		IdempotencyPurgeInterval: getDurationEnv("IDEMPOTENCY_PURGE_INTERVAL", time.Hour),
+
+		// Idempotency key defaults
+		IdempotencyKeyTTL: getDurationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+
+		// Scheduled pickup defaults
+		ScheduleReminderLead:   getDurationEnv("SCHEDULE_REMINDER_LEAD", time.Hour),
+		ScheduleActivationLead: getDurationEnv("SCHEDULE_ACTIVATION_LEAD", 30*time.Minute),
+
+		// Recurring delivery defaults
+		DefaultTimezone:      getEnv("DEFAULT_TIMEZONE", "Africa/Lusaka"),
+		SubscriptionLeadTime: getDurationEnv("SUBSCRIPTION_LEAD_TIME", 48*time.Hour),
+		SubscriptionInterval: getDurationEnv("SUBSCRIPTION_INTERVAL", 15*time.Minute),
+
+		// File storage defaults
+		StorageDriver:        getEnv("STORAGE_DRIVER", "local"),
+		StorageLocalPath:     getEnv("STORAGE_LOCAL_PATH", "./uploads"),
+		StoragePublicURL:     getEnv("STORAGE_PUBLIC_URL", "http://localhost:8080"),
+		StorageSigningSecret: getEnv("STORAGE_SIGNING_SECRET", ""), // Falls back to the JWT secret
+		SignedURLTTL:         getDurationEnv("SIGNED_URL_TTL", 15*time.Minute),
+		MaxUploadSize:        getIntEnv("MAX_UPLOAD_SIZE", 8*1024*1024), // 8MB
+		MaxImagePixels:       getIntEnv("MAX_IMAGE_PIXELS", 40_000_000), // 40 megapixels
+
+		// S3-compatible storage defaults
+		S3Endpoint:  getEnv("S3_ENDPOINT", ""),
+		S3Region:    getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:    getEnv("S3_BUCKET", "nyengo-deliveries"),
+		S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+	}
+
+	if cfg.StorageSigningSecret == "" {
+		cfg.StorageSigningSecret = cfg.JWTSecret
 	}
 
 	// Auto-fill currency symbol and locale if not set
@@ -334,6 +532,27 @@ func getStoreAPIKeys() []string {
 	}
 }
 
+// getListEnv returns the comma-separated values of an env var, empty when it is not set
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// IsAdminCourier checks if the courier account may use admin-only endpoints
+func (c *Config) IsAdminCourier(courierID string) bool {
+	for _, adminID := range c.AdminCourierIDs {
+		if strings.EqualFold(adminID, courierID) {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidateStoreAPIKey checks if the provided API key is valid
 func (c *Config) ValidateStoreAPIKey(apiKey string) bool {
 	for _, validKey := range c.StoreAPIKeys {
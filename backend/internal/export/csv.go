@@ -0,0 +1,56 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+const csvTimeLayout = "2006-01-02 15:04:05"
+
+// csvWriter writes a header row followed by one line per row, flushing as it goes
+type csvWriter struct {
+	doc Document
+	out *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, doc Document) (*csvWriter, error) {
+	cw := &csvWriter{doc: doc, out: csv.NewWriter(w)}
+	header := make([]string, len(doc.Columns))
+	for i, column := range doc.Columns {
+		header[i] = column.Title
+	}
+	if err := cw.out.Write(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteRow(values ...interface{}) error {
+	if err := checkRow(&cw.doc, values); err != nil {
+		return err
+	}
+	record := make([]string, len(values))
+	for i, value := range values {
+		column := cw.doc.Columns[i]
+		record[i] = formatPlain(&cw.doc, column, value, csvTimeLayout)
+		if column.Kind == KindText {
+			record[i] = neutralizeFormula(record[i])
+		}
+	}
+	return cw.out.Write(record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.out.Flush()
+	return cw.out.Error()
+}
+
+// neutralizeFormula stops spreadsheets from evaluating free text such as a customer name
+// starting with "=" as a formula, by prefixing it with an apostrophe
+func neutralizeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
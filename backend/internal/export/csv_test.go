@@ -0,0 +1,53 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestNeutralizeFormula(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"Jane Banda":       "Jane Banda",
+		"=SUM(A1:A9)":      "'=SUM(A1:A9)",
+		"+260971234567":    "'+260971234567",
+		"-2+3":             "'-2+3",
+		"@cmd":             "'@cmd",
+		"\tindented":       "'\tindented",
+		"\rreturn":         "'\rreturn",
+		"Plot 12 = corner": "Plot 12 = corner",
+		"'quoted":          "'quoted",
+	}
+	for value, want := range tests {
+		if got := neutralizeFormula(value); got != want {
+			t.Errorf("neutralizeFormula(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	file := writeDocument(t, FormatCSV, testDocument(t))
+	records, err := csv.NewReader(bytes.NewReader(file)).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the CSV: %v", err)
+	}
+
+	want := [][]string{
+		{"Order", "Customer", "Distance", "Fare", "Created"},
+		{"NYG-1", markupName, "3.46", "45.50", "2026-03-05 12:30:00"},
+		{"NYG-2", accentName, "12", "120.00", ""},
+		// Text columns are neutralized, negative amounts in number columns are not
+		{"NYG-3", "'" + formulaName, "", "-10.25", "2026-03-05 12:30:00"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("CSV has %d records, want %d: %q", len(records), len(want), records)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("record %d field %d = %q, want %q", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+}
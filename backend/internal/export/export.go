@@ -0,0 +1,186 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedFormat is returned for export formats other than csv, xlsx and pdf
+var ErrUnsupportedFormat = errors.New("format must be csv, xlsx or pdf")
+
+// Format is the file format of an export
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+	FormatPDF  Format = "pdf"
+)
+
+// ParseFormat parses a format name, defaulting to CSV
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(value))) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatXLSX:
+		return FormatXLSX, nil
+	case FormatPDF:
+		return FormatPDF, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Kind tells a writer how to format and align a column's values
+type Kind int
+
+const (
+	KindText   Kind = iota // string
+	KindNumber             // float64 or int, shown with up to two decimals
+	KindMoney              // float64 in the configured currency
+	KindTime               // time.Time or *time.Time, empty when nil
+)
+
+// Column describes one column of an export
+type Column struct {
+	Title string
+	Kind  Kind
+	Width float64 // Relative width, used for PDF layout and spreadsheet column widths
+	Total bool    // Sum the column in the statement's totals row (PDF only)
+}
+
+// Business identifies the company issuing a statement
+type Business struct {
+	Name    string
+	Country string
+	Email   string
+	Phone   string
+}
+
+// Document describes an export: its columns and, for PDF statements, the heading
+type Document struct {
+	Title       string
+	Details     []string // Lines under the title, e.g. who the statement is for and the period
+	Sheet       string   // Worksheet name for XLSX
+	Columns     []Column
+	Business    Business
+	Location    *time.Location       // Timezone times are shown in
+	FormatMoney func(float64) string // Formats money in PDF statements
+	GeneratedAt time.Time
+}
+
+// Writer writes the rows of an export as they are produced, so exports of any size
+// are streamed to the client instead of being built in memory
+type Writer interface {
+	// WriteRow writes one row, with one value per column
+	WriteRow(values ...interface{}) error
+
+	// Close finishes the file. Nothing is written after Close.
+	Close() error
+}
+
+// NewWriter creates a writer for the format and writes the document heading
+func NewWriter(format Format, w io.Writer, doc Document) (Writer, error) {
+	if doc.Location == nil {
+		doc.Location = time.UTC
+	}
+	if doc.FormatMoney == nil {
+		doc.FormatMoney = func(amount float64) string { return strconv.FormatFloat(amount, 'f', 2, 64) }
+	}
+	if doc.GeneratedAt.IsZero() {
+		doc.GeneratedAt = time.Now()
+	}
+
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, doc)
+	case FormatXLSX:
+		return newXLSXWriter(w, doc)
+	case FormatPDF:
+		return newPDFWriter(w, doc)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// checkRow returns an error if a row does not have one value per column
+func checkRow(doc *Document, values []interface{}) error {
+	if len(values) != len(doc.Columns) {
+		return fmt.Errorf("export row has %d values, expected %d", len(values), len(doc.Columns))
+	}
+	return nil
+}
+
+// numberValue converts a numeric cell value to float64
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case *float64:
+		if v != nil {
+			return *v, true
+		}
+	}
+	return 0, false
+}
+
+// timeValue converts a time cell value, reporting false for nil or zero times
+func timeValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v != nil && !v.IsZero() {
+			return *v, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatPlain formats a value for formats without typed cells
+func formatPlain(doc *Document, column Column, value interface{}, timeLayout string) string {
+	switch column.Kind {
+	case KindNumber:
+		if n, ok := numberValue(value); ok {
+			return strconv.FormatFloat(math.Round(n*100)/100, 'f', -1, 64)
+		}
+		return ""
+	case KindMoney:
+		if n, ok := numberValue(value); ok {
+			return strconv.FormatFloat(n, 'f', 2, 64)
+		}
+		return ""
+	case KindTime:
+		if t, ok := timeValue(value); ok {
+			return t.In(doc.Location).Format(timeLayout)
+		}
+		return ""
+	}
+	if value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// Values that need escaping in XML, in PDF strings or as spreadsheet formulas
+const (
+	markupName  = `Tom & Jerry's <Deli>`
+	accentName  = "Chileshe Mwansa Café – Ndola"
+	formulaName = "=HYPERLINK(\"http://x\")"
+)
+
+var testCreated = time.Date(2026, 3, 5, 10, 30, 0, 0, time.UTC)
+
+func testDocument(t *testing.T) Document {
+	lusaka, err := time.LoadLocation("Africa/Lusaka")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	return Document{
+		Title:   "Orders (March) \\ Statement",
+		Details: []string{"Prepared for: " + markupName},
+		Sheet:   "Orders & <Payouts>",
+		Columns: []Column{
+			{Title: "Order", Width: 12},
+			{Title: "Customer", Width: 20},
+			{Title: "Distance", Kind: KindNumber, Width: 8},
+			{Title: "Fare", Kind: KindMoney, Width: 10, Total: true},
+			{Title: "Created", Kind: KindTime, Width: 14},
+		},
+		Business:    Business{Name: "Nyengo Deliveries", Country: "Zambia"},
+		Location:    lusaka,
+		GeneratedAt: testCreated,
+	}
+}
+
+// writeDocument writes the test rows in format and returns the file
+func writeDocument(t *testing.T, format Format, doc Document) []byte {
+	var b bytes.Buffer
+	w, err := NewWriter(format, &b, doc)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	rows := [][]interface{}{
+		{"NYG-1", markupName, 3.456, 45.5, testCreated},
+		{"NYG-2", accentName, 12, 120.0, (*time.Time)(nil)},
+		{"NYG-3", formulaName, nil, -10.25, &testCreated},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row...); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := w.WriteRow("NYG-4"); err == nil || !strings.Contains(err.Error(), "expected 5") {
+		t.Errorf("WriteRow() with one value: error = %v, want a column count error", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string]Format{"": FormatCSV, "csv": FormatCSV, " XLSX ": FormatXLSX, "Pdf": FormatPDF}
+	for value, want := range tests {
+		if got, err := ParseFormat(value); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xls"); err != ErrUnsupportedFormat {
+		t.Errorf("ParseFormat(xls) error = %v, want ErrUnsupportedFormat", err)
+	}
+}
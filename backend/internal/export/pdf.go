@@ -0,0 +1,196 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"nyengo-deliveries/internal/pdf"
+)
+
+// A4 landscape, in points, so wide tables fit
+const (
+	pdfPageWidth  = 842.0
+	pdfPageHeight = 595.0
+	pdfMargin     = 36.0
+	pdfFontSize   = 8.0
+	pdfRowHeight  = 13.0
+	pdfCellPad    = 3.0
+	pdfTimeLayout = "02 Jan 2006 15:04"
+)
+
+// pdfWriter lays the rows out as a statement table, repeating the column titles on every page
+type pdfWriter struct {
+	doc     Document
+	out     *pdf.Writer
+	page    *pdf.Page
+	pageNo  int
+	y       float64
+	columns []float64 // Column widths in points
+	totals  []float64
+	rows    int
+}
+
+func newPDFWriter(w io.Writer, doc Document) (*pdfWriter, error) {
+	pw := &pdfWriter{
+		doc:    doc,
+		out:    pdf.NewWriter(w),
+		totals: make([]float64, len(doc.Columns)),
+	}
+
+	var weights float64
+	for _, column := range doc.Columns {
+		weights += columnWeight(column)
+	}
+	for _, column := range doc.Columns {
+		pw.columns = append(pw.columns, (pdfPageWidth-2*pdfMargin)*columnWeight(column)/weights)
+	}
+
+	pw.newPage()
+	return pw, pw.out.Err()
+}
+
+func columnWeight(column Column) float64 {
+	if column.Width <= 0 {
+		return 10
+	}
+	return column.Width
+}
+
+func (pw *pdfWriter) WriteRow(values ...interface{}) error {
+	if err := checkRow(&pw.doc, values); err != nil {
+		return err
+	}
+	if pw.y-pdfRowHeight < pdfMargin+pdfRowHeight {
+		pw.newPage()
+	}
+
+	cells := make([]string, len(values))
+	for i, value := range values {
+		column := pw.doc.Columns[i]
+		switch column.Kind {
+		case KindMoney:
+			if n, ok := numberValue(value); ok {
+				cells[i] = pw.doc.FormatMoney(n)
+				if column.Total {
+					pw.totals[i] += n
+				}
+			}
+		default:
+			cells[i] = formatPlain(&pw.doc, column, value, pdfTimeLayout)
+			if n, ok := numberValue(value); ok && column.Total {
+				pw.totals[i] += n
+			}
+		}
+	}
+	pw.rows++
+	pw.drawRow(cells, pdf.Helvetica)
+	return pw.out.Err()
+}
+
+func (pw *pdfWriter) Close() error {
+	if pw.rows == 0 {
+		pw.y -= pdfRowHeight
+		pw.page.Text(pdf.Helvetica, pdfFontSize, pdfMargin+pdfCellPad, pw.y, "No records for this period.")
+	}
+
+	hasTotals := false
+	for _, column := range pw.doc.Columns {
+		hasTotals = hasTotals || column.Total
+	}
+	if hasTotals {
+		if pw.y-2*pdfRowHeight < pdfMargin+pdfRowHeight {
+			pw.newPage()
+		}
+		pw.y -= 4
+		pw.rule(pw.y)
+		cells := make([]string, len(pw.doc.Columns))
+		cells[0] = fmt.Sprintf("Total (%d)", pw.rows)
+		for i, column := range pw.doc.Columns {
+			if !column.Total {
+				continue
+			}
+			if column.Kind == KindMoney {
+				cells[i] = pw.doc.FormatMoney(pw.totals[i])
+			} else {
+				cells[i] = formatPlain(&pw.doc, column, pw.totals[i], "")
+			}
+		}
+		pw.drawRow(cells, pdf.HelveticaBold)
+	}
+	pw.footer()
+
+	return pw.out.Close(pdf.Info{Title: pw.doc.Title, Producer: pw.doc.Business.Name, Created: pw.doc.GeneratedAt})
+}
+
+// newPage finishes the current page and starts the next one with the table header.
+// The first page also carries the business details and the statement heading.
+func (pw *pdfWriter) newPage() {
+	if pw.page != nil {
+		pw.footer()
+	}
+	pw.page = pw.out.AddPage(pdfPageWidth, pdfPageHeight)
+	pw.pageNo++
+	pw.y = pdfPageHeight - pdfMargin
+
+	if pw.pageNo == 1 {
+		pw.y -= 16
+		pw.page.Text(pdf.HelveticaBold, 16, pdfMargin, pw.y, pw.doc.Business.Name)
+		var contact []string
+		for _, value := range []string{pw.doc.Business.Country, pw.doc.Business.Email, pw.doc.Business.Phone} {
+			if value != "" {
+				contact = append(contact, value)
+			}
+		}
+		if len(contact) > 0 {
+			pw.y -= 13
+			pw.page.Text(pdf.Helvetica, 9, pdfMargin, pw.y, strings.Join(contact, "  |  "))
+		}
+
+		pw.y -= 26
+		pw.page.Text(pdf.HelveticaBold, 13, pdfMargin, pw.y, pw.doc.Title)
+		details := append(append([]string{}, pw.doc.Details...),
+			"Generated "+pw.doc.GeneratedAt.In(pw.doc.Location).Format(pdfTimeLayout+" MST"))
+		for _, line := range details {
+			pw.y -= 12
+			pw.page.Text(pdf.Helvetica, 9, pdfMargin, pw.y, line)
+		}
+		pw.y -= 12
+	}
+
+	titles := make([]string, len(pw.doc.Columns))
+	for i, column := range pw.doc.Columns {
+		titles[i] = column.Title
+	}
+	pw.drawRow(titles, pdf.HelveticaBold)
+	pw.rule(pw.y - 4)
+	pw.y -= 4
+}
+
+func (pw *pdfWriter) footer() {
+	footer := fmt.Sprintf("%s  |  %s  |  Page %d", pw.doc.Business.Name, pw.doc.Title, pw.pageNo)
+	pw.page.Text(pdf.Helvetica, 7, pdfMargin, pdfMargin-14, footer)
+}
+
+// drawRow draws one table row below the current position, truncating cells to their column width
+func (pw *pdfWriter) drawRow(cells []string, font pdf.Font) {
+	pw.y -= pdfRowHeight
+	x := pdfMargin
+	for i, cell := range cells {
+		width := pw.columns[i]
+		text := pdf.FitText(cell, font, pdfFontSize, width-2*pdfCellPad)
+		left := x + pdfCellPad
+		if kind := pw.doc.Columns[i].Kind; (kind == KindMoney || kind == KindNumber) && text != "" {
+			left = x + width - pdfCellPad - pdf.TextWidth(text, font, pdfFontSize)
+		}
+		if text != "" {
+			pw.page.Text(font, pdfFontSize, left, pw.y, text)
+		}
+		x += width
+	}
+}
+
+// rule draws a horizontal line across the table
+func (pw *pdfWriter) rule(y float64) {
+	pw.page.Line(pdfMargin, y, pdfPageWidth-pdfMargin, y, 0.5)
+}
@@ -0,0 +1,192 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	pdfTrailer = regexp.MustCompile(`trailer\n<< /Size (\d+) /Root (\d+) 0 R /Info (\d+) 0 R >>\nstartxref\n(\d+)\n%%EOF\n$`)
+	pdfRef     = regexp.MustCompile(`(\d+) 0 R`)
+	pdfContent = regexp.MustCompile(`/Contents (\d+) 0 R`)
+)
+
+// readPDF checks the file's structure: every cross-reference offset must point at its object and the
+// page tree must reach every page. It returns the info dictionary and the decompressed page contents.
+func readPDF(t *testing.T, file []byte) (string, []string) {
+	t.Helper()
+	if !bytes.HasPrefix(file, []byte("%PDF-1.4\n")) {
+		t.Fatalf("file does not start with a PDF header: %q", file[:16])
+	}
+	m := pdfTrailer.FindSubmatch(file)
+	if m == nil {
+		t.Fatalf("file does not end with a trailer: %q", file[len(file)-120:])
+	}
+	size, _ := strconv.Atoi(string(m[1]))
+	root, _ := strconv.Atoi(string(m[2]))
+	info, _ := strconv.Atoi(string(m[3]))
+	xref, _ := strconv.Atoi(string(m[4]))
+
+	table := string(file[xref:])
+	header := fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", size)
+	if !strings.HasPrefix(table, header) {
+		t.Fatalf("startxref %d does not point at a table of %d objects: %q", xref, size, table[:40])
+	}
+	table = table[len(header):]
+
+	dicts := map[int]string{}
+	streams := map[int][]byte{}
+	for id := 1; id < size; id++ {
+		var offset int
+		if _, err := fmt.Sscanf(table[(id-1)*20:id*20], "%010d 00000 n \n", &offset); err != nil {
+			t.Fatalf("xref entry %d: %v", id, err)
+		}
+		start := fmt.Sprintf("%d 0 obj\n", id)
+		if offset >= xref || !bytes.HasPrefix(file[offset:], []byte(start)) {
+			t.Fatalf("xref offset %d of object %d points at %q", offset, id, file[offset:offset+12])
+		}
+		body := file[offset+len(start):]
+
+		var length int
+		if _, err := fmt.Sscanf(string(body), "<< /Length %d /Filter /FlateDecode >>\nstream\n", &length); err == nil {
+			data := body[bytes.Index(body, []byte("stream\n"))+len("stream\n"):]
+			if !bytes.HasPrefix(data[length:], []byte("\nendstream\nendobj\n")) {
+				t.Fatalf("stream %d does not end after its /Length of %d", id, length)
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(data[:length]))
+			if err != nil {
+				t.Fatalf("stream %d: %v", id, err)
+			}
+			content, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("stream %d: %v", id, err)
+			}
+			streams[id] = content
+			continue
+		}
+		end := bytes.Index(body, []byte("\nendobj\n"))
+		if end < 0 {
+			t.Fatalf("object %d has no endobj", id)
+		}
+		dicts[id] = string(body[:end])
+	}
+
+	if !strings.HasPrefix(dicts[root], "<< /Type /Catalog /Pages ") {
+		t.Fatalf("root object %d is not a catalog: %q", root, dicts[root])
+	}
+	pagesID, _ := strconv.Atoi(pdfRef.FindStringSubmatch(dicts[root])[1])
+	pages := dicts[pagesID]
+	kids := pdfRef.FindAllStringSubmatch(pages[strings.Index(pages, "[")+1:strings.Index(pages, "]")], -1)
+	if !strings.Contains(pages, fmt.Sprintf("/Count %d ", len(kids))) {
+		t.Errorf("page tree %q does not count its %d kids", pages, len(kids))
+	}
+
+	var contents []string
+	for _, kid := range kids {
+		id, _ := strconv.Atoi(kid[1])
+		page := dicts[id]
+		if !strings.HasPrefix(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 842.00 595.00]", pagesID)) {
+			t.Fatalf("object %d is not an A4 landscape page: %q", id, page)
+		}
+		contentID, _ := strconv.Atoi(pdfContent.FindStringSubmatch(page)[1])
+		content, ok := streams[contentID]
+		if !ok {
+			t.Fatalf("page %d has no content stream %d", id, contentID)
+		}
+		contents = append(contents, string(content))
+	}
+	return dicts[info], contents
+}
+
+func TestPDFWriter(t *testing.T) {
+	info, pages := readPDF(t, writeDocument(t, FormatPDF, testDocument(t)))
+	if len(pages) != 1 {
+		t.Fatalf("document has %d pages, want 1", len(pages))
+	}
+	if want := `/Title (Orders \(March\) \\ Statement) /Producer (Nyengo Deliveries) /CreationDate (D:20260305103000Z)`; !strings.Contains(info, want) {
+		t.Errorf("info = %q, want %q", info, want)
+	}
+
+	page := pages[0]
+	for _, want := range []string{
+		"(Nyengo Deliveries) Tj",
+		`(Orders \(March\) \\ Statement) Tj`,
+		// Markup needs no escaping in PDF strings
+		"(Prepared for: Tom & Jerry's <Deli>) Tj",
+		"(Generated 05 Mar 2026 12:30 CAT) Tj",
+		"(Customer) Tj",
+		"(3.46) Tj",
+		// Non-ASCII text is converted to WinAnsiEncoding
+		"(Chileshe Mwansa Caf\xe9 \x96 Ndola) Tj",
+		`(=HYPERLINK\("http://x"\)) Tj`,
+		"(05 Mar 2026 12:30) Tj",
+		"(-10.25) Tj",
+		"(Total \\(3\\)) Tj",
+		"(155.25) Tj",
+		"(Nyengo Deliveries  |  Orders \\(March\\) \\\\ Statement  |  Page 1) Tj",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not draw %q", want)
+		}
+	}
+	if strings.Contains(page, "Café") {
+		t.Errorf("page contains UTF-8 text")
+	}
+}
+
+func TestPDFWriterPages(t *testing.T) {
+	doc := testDocument(t)
+	var b bytes.Buffer
+	w, err := NewWriter(FormatPDF, &b, doc)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for i := 1; i <= 100; i++ {
+		if err := w.WriteRow(fmt.Sprintf("NYG-%d", i), "Jane Banda", 1, 10.0, testCreated); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	_, pages := readPDF(t, b.Bytes())
+	if len(pages) < 3 {
+		t.Fatalf("100 rows fit on %d pages, want at least 3", len(pages))
+	}
+	for i, page := range pages {
+		if !strings.Contains(page, "(Order) Tj") {
+			t.Errorf("page %d does not repeat the column titles", i+1)
+		}
+		if !strings.Contains(page, fmt.Sprintf("Page %d) Tj", i+1)) {
+			t.Errorf("page %d has no footer", i+1)
+		}
+	}
+	if strings.Count(strings.Join(pages, ""), "(Nyengo Deliveries) Tj") != 1 {
+		t.Errorf("business heading is not only on the first page")
+	}
+	if last := pages[len(pages)-1]; !strings.Contains(last, "(NYG-100) Tj") || !strings.Contains(last, "(Total \\(100\\)) Tj") || !strings.Contains(last, "(1000.00) Tj") {
+		t.Errorf("last page does not end with the last row and the totals")
+	}
+}
+
+func TestPDFWriterEmpty(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(FormatPDF, &b, testDocument(t))
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	_, pages := readPDF(t, b.Bytes())
+	if len(pages) != 1 || !strings.Contains(pages[0], "(No records for this period.) Tj") || !strings.Contains(pages[0], "(Total \\(0\\)) Tj") {
+		t.Errorf("empty statement = %q", pages)
+	}
+}
@@ -0,0 +1,222 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cell styles defined in xlsxStyles, by index into cellXfs
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleMoney
+	xlsxStyleTime
+	xlsxStyleNumber
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="2"><numFmt numFmtId="164" formatCode="#,##0.00"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="5">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+</styleSheet>`
+
+// xlsxEpoch is day zero of spreadsheet date serial numbers
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxWriter writes a single-sheet workbook. The fixed parts are written first and the sheet is
+// streamed last, so rows go straight into the deflated zip entry without being held in memory.
+type xlsxWriter struct {
+	doc   Document
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+func newXLSXWriter(w io.Writer, doc Document) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(sheetName(doc.Sheet)))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw := &xlsxWriter{doc: doc, zip: zw, sheet: bufio.NewWriter(f)}
+
+	xw.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	xw.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// Keep the header row visible while scrolling
+	xw.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	xw.sheet.WriteString(`<cols>`)
+	for i, column := range doc.Columns {
+		width := column.Width * 1.6
+		if width < 8 {
+			width = 8
+		}
+		fmt.Fprintf(xw.sheet, `<col min="%d" max="%d" width="%.1f" customWidth="1"/>`, i+1, i+1, width)
+	}
+	xw.sheet.WriteString(`</cols><sheetData>`)
+
+	header := make([]interface{}, len(doc.Columns))
+	for i, column := range doc.Columns {
+		header[i] = column.Title
+	}
+	xw.writeRow(header, true)
+	return xw, nil
+}
+
+func (xw *xlsxWriter) WriteRow(values ...interface{}) error {
+	if err := checkRow(&xw.doc, values); err != nil {
+		return err
+	}
+	xw.writeRow(values, false)
+	// Hand full buffers to the zip entry as we go; errors surface on the next write or on Close
+	if xw.sheet.Buffered() > 32*1024 {
+		return xw.sheet.Flush()
+	}
+	return nil
+}
+
+func (xw *xlsxWriter) writeRow(values []interface{}, header bool) {
+	xw.row++
+	fmt.Fprintf(xw.sheet, `<row r="%d">`, xw.row)
+	for i, value := range values {
+		ref := columnName(i) + strconv.Itoa(xw.row)
+		if header {
+			xw.writeText(ref, value.(string), xlsxStyleHeader)
+			continue
+		}
+
+		switch xw.doc.Columns[i].Kind {
+		case KindNumber, KindMoney:
+			n, ok := numberValue(value)
+			if !ok {
+				continue
+			}
+			style := xlsxStyleNumber
+			if xw.doc.Columns[i].Kind == KindMoney {
+				style = xlsxStyleMoney
+			}
+			fmt.Fprintf(xw.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(n, 'f', -1, 64))
+		case KindTime:
+			t, ok := timeValue(value)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(xw.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleTime, strconv.FormatFloat(serialDate(t.In(xw.doc.Location)), 'f', -1, 64))
+		default:
+			text := formatPlain(&xw.doc, xw.doc.Columns[i], value, "")
+			if text != "" {
+				xw.writeText(ref, text, xlsxStyleDefault)
+			}
+		}
+	}
+	xw.sheet.WriteString(`</row>`)
+}
+
+// writeText writes an inline string cell, which avoids building a shared strings table
+func (xw *xlsxWriter) writeText(ref, text string, style int) {
+	fmt.Fprintf(xw.sheet, `<c r="%s" t="inlineStr" s="%d"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(text))
+}
+
+func (xw *xlsxWriter) Close() error {
+	xw.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zip.Close()
+}
+
+// serialDate converts a wall-clock time to a spreadsheet date serial number
+func serialDate(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(xlsxEpoch).Hours() / 24
+}
+
+// columnName returns the spreadsheet column letters for a zero-based index (0 → A, 26 → AA)
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// sheetName makes a name valid as a worksheet name: at most 31 characters and none of []:*?/\
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "Sheet1"
+	}
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
+
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type xlsxCell struct {
+	Ref   string `xml:"r,attr"`
+	Type  string `xml:"t,attr"`
+	Style int    `xml:"s,attr"`
+	Value string `xml:"v"`
+	Text  string `xml:"is>t"`
+}
+
+type xlsxSheet struct {
+	Cols []struct {
+		Min   int     `xml:"min,attr"`
+		Width float64 `xml:"width,attr"`
+	} `xml:"cols>col"`
+	Rows []struct {
+		Ref   int        `xml:"r,attr"`
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX opens the workbook and returns its parts by name
+func readXLSX(t *testing.T, file []byte) map[string][]byte {
+	zr, err := zip.NewReader(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", f.Name, err)
+		}
+		parts[f.Name] = content
+	}
+	return parts
+}
+
+func TestXLSXWriter(t *testing.T) {
+	parts := readXLSX(t, writeDocument(t, FormatXLSX, testDocument(t)))
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		content, ok := parts[name]
+		if !ok {
+			t.Fatalf("workbook has no %s", name)
+		}
+		// Every part must be well-formed XML
+		d := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed: %v", name, err)
+			}
+		}
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &workbook); err != nil {
+		t.Fatalf("decoding workbook.xml: %v", err)
+	}
+	if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != "Orders & <Payouts>" {
+		t.Errorf("sheets = %+v, want one named %q", workbook.Sheets, "Orders & <Payouts>")
+	}
+
+	raw := string(parts["xl/worksheets/sheet1.xml"])
+	if strings.Contains(raw, "<Deli>") || !strings.Contains(raw, "Tom &amp; Jerry&#39;s &lt;Deli&gt;") {
+		t.Errorf("sheet1.xml does not escape markup: %s", raw)
+	}
+	if !strings.Contains(raw, accentName) {
+		t.Errorf("sheet1.xml does not keep non-ASCII text as UTF-8: %s", raw)
+	}
+	var sheet xlsxSheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatalf("decoding sheet1.xml: %v", err)
+	}
+	if len(sheet.Cols) != 5 || sheet.Cols[0].Width != 19.2 || sheet.Cols[2].Width != 12.8 {
+		t.Errorf("cols = %+v, want five with widths scaled from the columns", sheet.Cols)
+	}
+
+	// Serial number of 2026-03-05 12:30 in Lusaka, where testCreated is shown
+	created := strconv.FormatFloat(46086+12.5/24, 'f', -1, 64)
+	want := [][]xlsxCell{
+		{
+			{Ref: "A1", Type: "inlineStr", Style: xlsxStyleHeader, Text: "Order"},
+			{Ref: "B1", Type: "inlineStr", Style: xlsxStyleHeader, Text: "Customer"},
+			{Ref: "C1", Type: "inlineStr", Style: xlsxStyleHeader, Text: "Distance"},
+			{Ref: "D1", Type: "inlineStr", Style: xlsxStyleHeader, Text: "Fare"},
+			{Ref: "E1", Type: "inlineStr", Style: xlsxStyleHeader, Text: "Created"},
+		},
+		{
+			{Ref: "A2", Type: "inlineStr", Text: "NYG-1"},
+			{Ref: "B2", Type: "inlineStr", Text: markupName},
+			{Ref: "C2", Style: xlsxStyleNumber, Value: "3.456"},
+			{Ref: "D2", Style: xlsxStyleMoney, Value: "45.5"},
+			{Ref: "E2", Style: xlsxStyleTime, Value: created},
+		},
+		{
+			{Ref: "A3", Type: "inlineStr", Text: "NYG-2"},
+			{Ref: "B3", Type: "inlineStr", Text: accentName},
+			{Ref: "C3", Style: xlsxStyleNumber, Value: "12"},
+			{Ref: "D3", Style: xlsxStyleMoney, Value: "120"},
+		},
+		{
+			{Ref: "A4", Type: "inlineStr", Text: "NYG-3"},
+			{Ref: "B4", Type: "inlineStr", Text: formulaName},
+			{Ref: "D4", Style: xlsxStyleMoney, Value: "-10.25"},
+			{Ref: "E4", Style: xlsxStyleTime, Value: created},
+		},
+	}
+	if len(sheet.Rows) != len(want) {
+		t.Fatalf("sheet has %d rows, want %d", len(sheet.Rows), len(want))
+	}
+	for i, row := range sheet.Rows {
+		if row.Ref != i+1 {
+			t.Errorf("row %d numbered %d", i+1, row.Ref)
+		}
+		if len(row.Cells) != len(want[i]) {
+			t.Errorf("row %d = %+v, want %+v", i+1, row.Cells, want[i])
+			continue
+		}
+		for j, cell := range row.Cells {
+			if cell != want[i][j] {
+				t.Errorf("cell %s = %+v, want %+v", want[i][j].Ref, cell, want[i][j])
+			}
+		}
+	}
+}
+
+func TestSerialDate(t *testing.T) {
+	if got := serialDate(xlsxEpoch); got != 0 {
+		t.Errorf("serialDate(epoch) = %v, want 0", got)
+	}
+	created := testCreated.In(testDocument(t).Location)
+	if got := serialDate(created); math.Abs(got-(46086+12.5/24)) > 1e-9 {
+		t.Errorf("serialDate(%v) = %v, want the Lusaka wall-clock time", created, got)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 4: "E", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for index, want := range tests {
+		if got := columnName(index); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", index, got, want)
+		}
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	tests := map[string]string{
+		"":                                  "Sheet1",
+		"  Orders  ":                        "Orders",
+		"Payouts [2026/03]":                 "Payouts -2026-03-",
+		"Wallet: a*b?c\\d":                  "Wallet- a-b-c-d",
+		"Transactions for the whole period": "Transactions for the whole peri",
+		"Café – ünïcode names are counted in characters": "Café – ünïcode names are counte",
+	}
+	for name, want := range tests {
+		if got := sheetName(name); got != want {
+			t.Errorf("sheetName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// AmendmentHandler handles changes to an order's drop-off and package details
+type AmendmentHandler struct {
+	service *services.AmendmentService
+}
+
+// NewAmendmentHandler creates a new amendment handler
+func NewAmendmentHandler(service *services.AmendmentService) *AmendmentHandler {
+	return &AmendmentHandler{service: service}
+}
+
+// Amend changes one of the courier's orders on behalf of the customer
+// PATCH /api/v1/orders/:id
+func (h *AmendmentHandler) Amend(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.AmendOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	amendment, order, err := h.service.AmendByCourier(c.Context(), courierID, orderID, &req)
+	if err != nil {
+		return amendmentError(c, err)
+	}
+	return amendmentResponse(c, amendment, order)
+}
+
+// StoreAmend changes an order for the store; it is applied without approval
+// PATCH /api/v1/stores/orders/:id
+func (h *AmendmentHandler) StoreAmend(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.AmendOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	amendment, order, err := h.service.AmendByStore(c.Context(), orderID, &req)
+	if err != nil {
+		return amendmentError(c, err)
+	}
+	return amendmentResponse(c, amendment, order)
+}
+
+// List returns the amendment log of one of the courier's orders
+// GET /api/v1/orders/:id/amendments
+func (h *AmendmentHandler) List(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	amendments, err := h.service.ListForCourier(c.Context(), courierID, orderID)
+	if err != nil {
+		return amendmentError(c, err)
+	}
+	return Success(c, amendments)
+}
+
+// StoreList returns an order's amendment log
+// GET /api/v1/stores/orders/:id/amendments
+func (h *AmendmentHandler) StoreList(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	amendments, err := h.service.List(c.Context(), orderID)
+	if err != nil {
+		return amendmentError(c, err)
+	}
+	return Success(c, amendments)
+}
+
+// Approve applies an amendment that was waiting for store approval
+// POST /api/v1/stores/orders/:id/amendments/:amendmentId/approve
+func (h *AmendmentHandler) Approve(c *fiber.Ctx) error {
+	orderID, amendmentID, req, err := parseAmendmentDecision(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	amendment, order, err := h.service.Approve(c.Context(), orderID, amendmentID, req.Note)
+	if err != nil {
+		return amendmentError(c, err)
+	}
+	return Success(c, fiber.Map{"amendment": amendment, "order": order})
+}
+
+// Reject discards an amendment that was waiting for store approval
+// POST /api/v1/stores/orders/:id/amendments/:amendmentId/reject
+func (h *AmendmentHandler) Reject(c *fiber.Ctx) error {
+	orderID, amendmentID, req, err := parseAmendmentDecision(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	amendment, err := h.service.Reject(c.Context(), orderID, amendmentID, req.Note)
+	if err != nil {
+		return amendmentError(c, err)
+	}
+	return Success(c, fiber.Map{"amendment": amendment})
+}
+
+// amendmentResponse returns 202 for amendments waiting for store approval and 200 once applied
+func amendmentResponse(c *fiber.Ctx, amendment *models.OrderAmendment, order *models.Order) error {
+	if amendment.Status == models.AmendmentStatusPendingApproval {
+		return c.Status(fiber.StatusAccepted).JSON(APIResponse{Success: true, Data: fiber.Map{"amendment": amendment, "order": order}})
+	}
+	return Success(c, fiber.Map{"amendment": amendment, "order": order})
+}
+
+func parseAmendmentDecision(c *fiber.Ctx) (uuid.UUID, uuid.UUID, *models.AmendmentDecisionRequest, error) {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, nil, errors.New("Invalid order ID")
+	}
+	amendmentID, err := uuid.Parse(c.Params("amendmentId"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, nil, errors.New("Invalid amendment ID")
+	}
+
+	var req models.AmendmentDecisionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return uuid.Nil, uuid.Nil, nil, errors.New("Invalid request body")
+		}
+	}
+	return orderID, amendmentID, &req, nil
+}
+
+// amendmentError maps amendment errors to HTTP responses
+func amendmentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrAmendmentNotFound):
+		return NotFound(c, "Amendment not found")
+	case errors.Is(err, services.ErrInvalidAmendment), errors.Is(err, services.ErrInvalidOrder):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrOrderNotAmendable),
+		errors.Is(err, services.ErrAmendmentPending),
+		errors.Is(err, services.ErrAmendmentDecided),
+		errors.Is(err, services.ErrAmendmentConflict):
+		return Conflict(c, err.Error())
+	}
+	return orderStatusError(c, err)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// CODHandler handles cash-on-delivery collections, courier cash liability and remittances to stores
+type CODHandler struct {
+	service *services.CODService
+}
+
+// NewCODHandler creates a new cash-on-delivery handler
+func NewCODHandler(service *services.CODService) *CODHandler {
+	return &CODHandler{service: service}
+}
+
+// Collect confirms the cash the driver collected from the customer at handoff
+// POST /api/v1/orders/:id/cod
+func (h *CODHandler) Collect(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.CollectCODRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	order, err := h.service.Collect(c.Context(), courierID, orderID, &req)
+	if err != nil {
+		return codError(c, err)
+	}
+	return Success(c, fiber.Map{
+		"message":        "Cash collection recorded",
+		"codAmount":      order.CODAmount,
+		"codCollected":   order.CODCollected,
+		"codStatus":      order.CODStatus,
+		"codCollectedAt": order.CODCollectedAt,
+	})
+}
+
+// Summary returns the courier's cash liability and the cash still to be remitted per store
+// GET /api/v1/payments/cod
+func (h *CODHandler) Summary(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	summary, err := h.service.Summary(c.Context(), courierID)
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, summary)
+}
+
+// CreateRemittance hands the cash collected for a store over to that store
+// POST /api/v1/payments/cod/remittances
+func (h *CODHandler) CreateRemittance(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	var req models.CreateRemittanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	remittance, err := h.service.CreateRemittance(c.Context(), courierID, &req)
+	if err != nil {
+		return codError(c, err)
+	}
+	return Created(c, remittance)
+}
+
+// ListRemittances returns the courier's remittances
+// GET /api/v1/payments/cod/remittances?status=&page=&pageSize=
+func (h *CODHandler) ListRemittances(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	page, pageSize := remittancePage(c)
+
+	remittances, err := h.service.ListForCourier(c.Context(), courierID, c.Query("status"), page, pageSize)
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, fiber.Map{"remittances": remittances, "page": page, "pageSize": pageSize})
+}
+
+// GetRemittance returns one of the courier's remittances with the orders it covers
+// GET /api/v1/payments/cod/remittances/:id
+func (h *CODHandler) GetRemittance(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid remittance ID")
+	}
+
+	remittance, err := h.service.GetForCourier(c.Context(), courierID, id)
+	if err != nil {
+		return codError(c, err)
+	}
+	return Success(c, remittance)
+}
+
+// StoreListRemittances returns the remittances made to a store
+// GET /api/v1/stores/cod/remittances?storeId=&status=&page=&pageSize=
+func (h *CODHandler) StoreListRemittances(c *fiber.Ctx) error {
+	storeID, err := uuid.Parse(c.Query("storeId"))
+	if err != nil {
+		return BadRequest(c, "A valid storeId is required")
+	}
+	page, pageSize := remittancePage(c)
+
+	remittances, err := h.service.ListForStore(c.Context(), storeID, c.Query("status"), page, pageSize)
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, fiber.Map{"remittances": remittances, "page": page, "pageSize": pageSize})
+}
+
+// ConfirmRemittance records that the store received a remittance
+// POST /api/v1/stores/cod/remittances/:id/confirm?storeId=
+func (h *CODHandler) ConfirmRemittance(c *fiber.Ctx) error {
+	storeID, id, req, err := parseRemittanceDecision(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	remittance, err := h.service.Confirm(c.Context(), storeID, id, req.Note)
+	if err != nil {
+		return codError(c, err)
+	}
+	return Success(c, remittance)
+}
+
+// RejectRemittance records that the store did not receive a remittance
+// POST /api/v1/stores/cod/remittances/:id/reject?storeId=
+func (h *CODHandler) RejectRemittance(c *fiber.Ctx) error {
+	storeID, id, req, err := parseRemittanceDecision(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	remittance, err := h.service.Reject(c.Context(), storeID, id, req.Note)
+	if err != nil {
+		return codError(c, err)
+	}
+	return Success(c, remittance)
+}
+
+// Settlement returns a store's cash-on-delivery settlement report, as JSON or CSV
+// GET /api/v1/stores/cod/settlement?storeId=&from=&to=&format=csv
+func (h *CODHandler) Settlement(c *fiber.Ctx) error {
+	storeID, err := uuid.Parse(c.Query("storeId"))
+	if err != nil {
+		return BadRequest(c, "A valid storeId is required")
+	}
+
+	report, err := h.service.Settlement(c.Context(), storeID, c.Query("from"), c.Query("to"))
+	if err != nil {
+		return codError(c, err)
+	}
+	if c.Query("format") != "csv" {
+		return Success(c, report)
+	}
+
+	var buf bytes.Buffer
+	if err := h.service.WriteSettlement(&buf, report); err != nil {
+		return ServerError(c, err.Error())
+	}
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="cod-settlement-%s-%s.csv"`,
+		report.From.Format("20060102"), report.To.AddDate(0, 0, -1).Format("20060102")))
+	return c.Send(buf.Bytes())
+}
+
+func remittancePage(c *fiber.Ctx) (int, int) {
+	page, pageSize := c.QueryInt("page", 1), c.QueryInt("pageSize", 20)
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+func parseRemittanceDecision(c *fiber.Ctx) (uuid.UUID, uuid.UUID, *models.RemittanceDecisionRequest, error) {
+	storeID, err := uuid.Parse(c.Query("storeId"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, nil, errors.New("A valid storeId is required")
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, nil, errors.New("Invalid remittance ID")
+	}
+
+	var req models.RemittanceDecisionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return uuid.Nil, uuid.Nil, nil, errors.New("Invalid request body")
+		}
+	}
+	return storeID, id, &req, nil
+}
+
+// codError maps cash-on-delivery errors to HTTP responses
+func codError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrRemittanceNotFound):
+		return NotFound(c, "Remittance not found")
+	case errors.Is(err, services.ErrInvalidCOD):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrNoCODOnOrder),
+		errors.Is(err, services.ErrCODNotCollectable),
+		errors.Is(err, services.ErrCODOnMultiStop),
+		errors.Is(err, services.ErrRemittancePending),
+		errors.Is(err, services.ErrNothingToRemit),
+		errors.Is(err, services.ErrRemittanceDecided):
+		return Conflict(c, err.Error())
+	}
+	return orderStatusError(c, err)
+}
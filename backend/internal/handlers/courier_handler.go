@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
@@ -62,6 +64,9 @@ func (h *CourierHandler) UpdateProfile(c *fiber.Ctx) error {
 
 	courier, err := h.service.UpdateProfile(c.Context(), courierID, &req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidOperatingHours) {
+			return BadRequest(c, err.Error())
+		}
 		return ServerError(c, err.Error())
 	}
 	return Success(c, courier)
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// DeliveryAttemptHandler handles failed delivery attempts, re-attempts and returns to sender
+type DeliveryAttemptHandler struct {
+	service *services.DeliveryAttemptService
+}
+
+// NewDeliveryAttemptHandler creates a new delivery attempt handler
+func NewDeliveryAttemptHandler(service *services.DeliveryAttemptService) *DeliveryAttemptHandler {
+	return &DeliveryAttemptHandler{service: service}
+}
+
+// RecordFailure records a failed delivery attempt with the driver's reason code
+// POST /api/v1/orders/:id/attempts
+func (h *DeliveryAttemptHandler) RecordFailure(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.RecordAttemptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	result, err := h.service.RecordFailure(c.Context(), courierID, orderID, &req)
+	if err != nil {
+		return deliveryAttemptError(c, err)
+	}
+	return Created(c, result)
+}
+
+// List returns the failed attempts of one of the courier's orders
+// GET /api/v1/orders/:id/attempts
+func (h *DeliveryAttemptHandler) List(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	attempts, err := h.service.ListForCourier(c.Context(), courierID, orderID)
+	if err != nil {
+		return deliveryAttemptError(c, err)
+	}
+	return Success(c, attempts)
+}
+
+// StoreList returns an order's failed attempts
+// GET /api/v1/stores/orders/:id/attempts
+func (h *DeliveryAttemptHandler) StoreList(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	attempts, err := h.service.List(c.Context(), orderID)
+	if err != nil {
+		return deliveryAttemptError(c, err)
+	}
+	return Success(c, attempts)
+}
+
+// deliveryAttemptError maps delivery attempt errors to HTTP responses
+func deliveryAttemptError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidAttempt):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrAttemptNotAllowed), errors.Is(err, services.ErrAttemptOnMultiStop):
+		return Conflict(c, err.Error())
+	}
+	return orderStatusError(c, err)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// DispatchHandler exposes the automatic dispatch audit log, broadcast offers and store assignment
+type DispatchHandler struct {
+	service *services.DispatchService
+}
+
+// NewDispatchHandler creates a new dispatch handler
+func NewDispatchHandler(service *services.DispatchService) *DispatchHandler {
+	return &DispatchHandler{service: service}
+}
+
+// StoreLog returns an order's dispatch state and the reasons each courier was offered, ranked or skipped
+// GET /api/v1/stores/orders/:id/dispatch
+func (h *DispatchHandler) StoreLog(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	dispatchLog, err := h.service.Log(c.Context(), orderID)
+	if err != nil {
+		return orderStatusError(c, err)
+	}
+	return Success(c, dispatchLog)
+}
+
+// Offers lists the broadcast orders the authenticated courier can still accept
+// GET /api/v1/orders/offers
+func (h *DispatchHandler) Offers(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+
+	orders, err := h.service.Offers(c.Context(), courierID)
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, orders)
+}
+
+// StoreAssign gives an order nobody accepted to a courier the store picked
+// POST /api/v1/stores/orders/:id/assign
+func (h *DispatchHandler) StoreAssign(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.AssignCourierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+	if req.CourierID == uuid.Nil {
+		return BadRequest(c, "courierId is required")
+	}
+
+	order, err := h.service.Assign(c.Context(), orderID, req.CourierID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOrder) {
+			return BadRequest(c, err.Error())
+		}
+		return orderStatusError(c, err)
+	}
+	return Success(c, order)
+}
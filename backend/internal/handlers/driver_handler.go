@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// DriverHandler handles a courier company's drivers and the drivers' own endpoints
+type DriverHandler struct {
+	service *services.DriverService
+}
+
+// NewDriverHandler creates a new driver handler
+func NewDriverHandler(service *services.DriverService) *DriverHandler {
+	return &DriverHandler{service: service}
+}
+
+// Register adds a driver to the authenticated courier and returns their invite code
+// POST /api/v1/couriers/drivers
+func (h *DriverHandler) Register(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+
+	var req models.DriverRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	invite, err := h.service.Register(c.Context(), courierID, &req)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Created(c, invite)
+}
+
+// List returns the authenticated courier's drivers
+// GET /api/v1/couriers/drivers?status=
+func (h *DriverHandler) List(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+
+	drivers, err := h.service.List(c.Context(), courierID, c.Query("status"))
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, drivers)
+}
+
+// Get returns one of the authenticated courier's drivers
+// GET /api/v1/couriers/drivers/:id
+func (h *DriverHandler) Get(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	driverID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid driver ID")
+	}
+
+	driver, err := h.service.Get(c.Context(), courierID, driverID)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, driver)
+}
+
+// Update changes a driver's details, or suspends and reinstates them
+// PUT /api/v1/couriers/drivers/:id
+func (h *DriverHandler) Update(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	driverID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid driver ID")
+	}
+
+	var req models.DriverUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	driver, err := h.service.Update(c.Context(), courierID, driverID, &req)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, driver)
+}
+
+// Invite issues a new invite code for a driver
+// POST /api/v1/couriers/drivers/:id/invite
+func (h *DriverHandler) Invite(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	driverID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid driver ID")
+	}
+
+	invite, err := h.service.Invite(c.Context(), courierID, driverID)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, invite)
+}
+
+// Orders returns a page of the orders assigned to one of the authenticated courier's drivers
+// GET /api/v1/couriers/drivers/:id/orders
+func (h *DriverHandler) Orders(c *fiber.Ctx) error {
+	driverID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid driver ID")
+	}
+	return h.orders(c, c.Locals("courier_id").(uuid.UUID), driverID)
+}
+
+// Earnings sums what one of the authenticated courier's drivers delivered
+// GET /api/v1/couriers/drivers/:id/earnings?from=&to=
+func (h *DriverHandler) Earnings(c *fiber.Ctx) error {
+	driverID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid driver ID")
+	}
+	return h.earnings(c, c.Locals("courier_id").(uuid.UUID), driverID)
+}
+
+// Performance returns the delivery metrics of one of the authenticated courier's drivers
+// GET /api/v1/couriers/drivers/:id/performance
+func (h *DriverHandler) Performance(c *fiber.Ctx) error {
+	driverID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid driver ID")
+	}
+	return h.performance(c, c.Locals("courier_id").(uuid.UUID), driverID)
+}
+
+// AssignOrder gives one of the authenticated courier's orders to one of their drivers
+// POST /api/v1/orders/:id/driver
+func (h *DriverHandler) AssignOrder(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.AssignDriverRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	assignment, err := h.service.AssignOrder(c.Context(), courierID, orderID, &req)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Created(c, assignment)
+}
+
+// UnassignOrder takes an order off its driver
+// DELETE /api/v1/orders/:id/driver
+func (h *DriverHandler) UnassignOrder(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	assignment, err := h.service.UnassignOrder(c.Context(), courierID, orderID)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, assignment)
+}
+
+// Activate accepts a driver's invite, sets their password and returns their token
+// POST /api/v1/drivers/activate
+func (h *DriverHandler) Activate(c *fiber.Ctx) error {
+	var req models.DriverActivateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	result, err := h.service.Activate(c.Context(), &req)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, result)
+}
+
+// Login signs a driver in
+// POST /api/v1/drivers/login
+func (h *DriverHandler) Login(c *fiber.Ctx) error {
+	var req models.DriverLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	result, err := h.service.Login(c.Context(), &req)
+	if err != nil {
+		if errors.Is(err, services.ErrDriverNotActive) {
+			return Forbidden(c, err.Error())
+		}
+		return driverError(c, err)
+	}
+	return Success(c, result)
+}
+
+// Me returns the authenticated driver's profile
+// GET /api/v1/drivers/me
+func (h *DriverHandler) Me(c *fiber.Ctx) error {
+	courierID, driverID := driverLocals(c)
+
+	driver, err := h.service.Get(c.Context(), courierID, driverID)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, driver)
+}
+
+// MyAssignments lists the authenticated driver's assignments
+// GET /api/v1/drivers/me/assignments?status=
+func (h *DriverHandler) MyAssignments(c *fiber.Ctx) error {
+	courierID, driverID := driverLocals(c)
+
+	assignments, err := h.service.Assignments(c.Context(), courierID, driverID, c.Query("status"))
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, assignments)
+}
+
+// MyOrders returns a page of the orders assigned to the authenticated driver
+// GET /api/v1/drivers/me/orders
+func (h *DriverHandler) MyOrders(c *fiber.Ctx) error {
+	courierID, driverID := driverLocals(c)
+	return h.orders(c, courierID, driverID)
+}
+
+// MyEarnings sums what the authenticated driver delivered
+// GET /api/v1/drivers/me/earnings?from=&to=
+func (h *DriverHandler) MyEarnings(c *fiber.Ctx) error {
+	courierID, driverID := driverLocals(c)
+	return h.earnings(c, courierID, driverID)
+}
+
+// MyPerformance returns the authenticated driver's delivery metrics
+// GET /api/v1/drivers/me/performance
+func (h *DriverHandler) MyPerformance(c *fiber.Ctx) error {
+	courierID, driverID := driverLocals(c)
+	return h.performance(c, courierID, driverID)
+}
+
+// AcceptAssignment accepts an order assigned to the authenticated driver
+// POST /api/v1/drivers/me/orders/:id/accept
+func (h *DriverHandler) AcceptAssignment(c *fiber.Ctx) error {
+	return h.respond(c, true)
+}
+
+// RejectAssignment hands an order assigned to the authenticated driver back to their company
+// POST /api/v1/drivers/me/orders/:id/reject
+func (h *DriverHandler) RejectAssignment(c *fiber.Ctx) error {
+	return h.respond(c, false)
+}
+
+func (h *DriverHandler) respond(c *fiber.Ctx, accept bool) error {
+	courierID, driverID := driverLocals(c)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.DriverAssignmentResponseRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return BadRequest(c, "Invalid request body")
+		}
+	}
+
+	assignment, err := h.service.RespondAssignment(c.Context(), courierID, driverID, orderID, accept, req.Note)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, assignment)
+}
+
+func (h *DriverHandler) orders(c *fiber.Ctx, courierID, driverID uuid.UUID) error {
+	filters, err := parseOrderListFilters(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	result, err := h.service.Orders(c.Context(), courierID, driverID, filters)
+	if err != nil {
+		if errors.Is(err, services.ErrDriverNotFound) {
+			return NotFound(c, "Driver not found")
+		}
+		return orderQueryError(c, err)
+	}
+	return Success(c, result)
+}
+
+func (h *DriverHandler) earnings(c *fiber.Ctx, courierID, driverID uuid.UUID) error {
+	from, err := parseQueryTime(c.Query("from"), false)
+	if err != nil {
+		return BadRequest(c, "from must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	to, err := parseQueryTime(c.Query("to"), true)
+	if err != nil {
+		return BadRequest(c, "to must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+
+	earnings, err := h.service.Earnings(c.Context(), courierID, driverID, from, to)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, earnings)
+}
+
+func (h *DriverHandler) performance(c *fiber.Ctx, courierID, driverID uuid.UUID) error {
+	performance, err := h.service.Performance(c.Context(), courierID, driverID)
+	if err != nil {
+		return driverError(c, err)
+	}
+	return Success(c, performance)
+}
+
+// driverLocals returns the company and driver of a driver-scoped token
+func driverLocals(c *fiber.Ctx) (uuid.UUID, uuid.UUID) {
+	return c.Locals("courier_id").(uuid.UUID), c.Locals("driver_id").(uuid.UUID)
+}
+
+// driverError maps driver errors to HTTP responses
+func driverError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDriverNotFound):
+		return NotFound(c, "Driver not found")
+	case errors.Is(err, services.ErrNoDriverAssignment):
+		return NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidDriver):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrInviteInvalid), errors.Is(err, services.ErrInvalidDriverCredentials):
+		return Unauthorized(c, err.Error())
+	case errors.Is(err, services.ErrInviteLocked):
+		return TooManyRequests(c, err.Error())
+	case errors.Is(err, services.ErrNotAssignedDriver):
+		return Forbidden(c, err.Error())
+	case errors.Is(err, services.ErrDriverPhoneTaken),
+		errors.Is(err, services.ErrDriverNotActive),
+		errors.Is(err, services.ErrOrderNotAssignable),
+		errors.Is(err, services.ErrDriverAssignmentClosed):
+		return Conflict(c, err.Error())
+	}
+	return orderStatusError(c, err)
+}
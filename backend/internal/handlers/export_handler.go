@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/export"
+	"nyengo-deliveries/internal/services"
+)
+
+// ExportHandler streams order history, wallet transactions and payouts as CSV, XLSX or PDF
+type ExportHandler struct {
+	service *services.ExportService
+	timeout time.Duration
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(service *services.ExportService, timeout time.Duration) *ExportHandler {
+	return &ExportHandler{service: service, timeout: timeout}
+}
+
+// Orders exports the courier's order history, filtered like the order list
+// GET /api/v1/orders/export?format=csv|xlsx|pdf&status=&dateFrom=&dateTo=&...
+func (h *ExportHandler) Orders(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+	filters, err := parseOrderListFilters(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	exp, err := h.service.Orders(c.Context(), courierID, filters, format)
+	if err != nil {
+		return exportError(c, err)
+	}
+	return h.stream(c, exp)
+}
+
+// WalletTransactions exports the courier's wallet transactions
+// GET /api/v1/payments/wallet/transactions/export?format=&from=&to=
+func (h *ExportHandler) WalletTransactions(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	format, from, to, err := parseExportPeriod(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	exp, err := h.service.WalletTransactions(c.Context(), courierID, from, to, format)
+	if err != nil {
+		return exportError(c, err)
+	}
+	return h.stream(c, exp)
+}
+
+// Payouts exports the courier's payouts
+// GET /api/v1/payments/payouts/export?format=&status=&from=&to=
+func (h *ExportHandler) Payouts(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	format, from, to, err := parseExportPeriod(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	exp, err := h.service.Payouts(c.Context(), courierID, c.Query("status"), from, to, format)
+	if err != nil {
+		return exportError(c, err)
+	}
+	return h.stream(c, exp)
+}
+
+// stream sends the export as a download. Rows are written while the response is sent, so the
+// status is already 200 when a database error interrupts the export; it is logged and the file is cut short.
+func (h *ExportHandler) stream(c *fiber.Ctx, exp *services.Export) error {
+	c.Set(fiber.HeaderContentType, exp.Format.ContentType())
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, exp.Filename))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context ends when the handler returns, before the body is written
+		ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+		defer cancel()
+
+		if err := exp.Write(ctx, w); err != nil {
+			log.Printf("⚠️ Export %s failed: %v", exp.Filename, err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+func parseExportPeriod(c *fiber.Ctx) (export.Format, *time.Time, *time.Time, error) {
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	from, err := parseQueryTime(c.Query("from"), false)
+	if err != nil {
+		return "", nil, nil, errors.New("from must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	to, err := parseQueryTime(c.Query("to"), true)
+	if err != nil {
+		return "", nil, nil, errors.New("to must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	return format, from, to, nil
+}
+
+// exportError maps export errors to HTTP responses
+func exportError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrInvalidExport) {
+		return BadRequest(c, err.Error())
+	}
+	return orderQueryError(c, err)
+}
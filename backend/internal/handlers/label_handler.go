@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/label"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// LabelHandler serves printable shipping labels as PDF or ZPL
+type LabelHandler struct {
+	service *services.LabelService
+}
+
+// NewLabelHandler creates a new label handler
+func NewLabelHandler(service *services.LabelService) *LabelHandler {
+	return &LabelHandler{service: service}
+}
+
+// StoreOrder returns the label(s) of an order, available as soon as the order is created
+// GET /api/v1/stores/orders/:id/label?format=pdf|zpl&size=4x6|a6
+func (h *LabelHandler) StoreOrder(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+	format, size, err := parseLabelOptions(c.Query("format"), c.Query("size"))
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	labels, order, err := h.service.ForOrder(c.Context(), orderID)
+	if err != nil {
+		return labelError(c, err)
+	}
+	return h.send(c, format, size, labels, "label-"+order.OrderNumber)
+}
+
+// CourierOrder returns the label(s) of one of the courier's orders
+// GET /api/v1/orders/:id/label?format=&size=
+func (h *LabelHandler) CourierOrder(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+	format, size, err := parseLabelOptions(c.Query("format"), c.Query("size"))
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	labels, order, err := h.service.ForCourierOrder(c.Context(), courierID, orderID)
+	if err != nil {
+		return labelError(c, err)
+	}
+	return h.send(c, format, size, labels, "label-"+order.OrderNumber)
+}
+
+// Batch returns the labels of several orders in one document for batch printing
+// POST /api/v1/stores/orders/labels
+func (h *LabelHandler) Batch(c *fiber.Ctx) error {
+	var req models.LabelBatchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+	format, size, err := parseLabelOptions(req.Format, req.Size)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+	orderIDs := make([]uuid.UUID, 0, len(req.OrderIDs))
+	for _, value := range req.OrderIDs {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return BadRequest(c, fmt.Sprintf("Invalid order ID %q", value))
+		}
+		orderIDs = append(orderIDs, id)
+	}
+
+	labels, err := h.service.ForOrders(c.Context(), orderIDs)
+	if err != nil {
+		return labelError(c, err)
+	}
+	return h.send(c, format, size, labels, fmt.Sprintf("labels-%d", len(labels)))
+}
+
+// Import returns the labels of every order created by a bulk import
+// GET /api/v1/stores/orders/imports/:id/labels?format=&size=
+func (h *LabelHandler) Import(c *fiber.Ctx) error {
+	importID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid import ID")
+	}
+	format, size, err := parseLabelOptions(c.Query("format"), c.Query("size"))
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	labels, imp, err := h.service.ForImport(c.Context(), importID)
+	if err != nil {
+		return labelError(c, err)
+	}
+	return h.send(c, format, size, labels, "labels-import-"+imp.ID.String())
+}
+
+func (h *LabelHandler) send(c *fiber.Ctx, format label.Format, size label.Size, labels []label.Label, name string) error {
+	var buf bytes.Buffer
+	if err := h.service.Write(&buf, format, size, labels); err != nil {
+		return ServerError(c, err.Error())
+	}
+
+	disposition := "attachment"
+	if format == label.FormatPDF {
+		disposition = "inline" // Opens in the browser's print preview
+	}
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`%s; filename="%s.%s"`, disposition, name, format))
+	return c.Send(buf.Bytes())
+}
+
+func parseLabelOptions(format, size string) (label.Format, label.Size, error) {
+	f, err := label.ParseFormat(format)
+	if err != nil {
+		return "", "", err
+	}
+	s, err := label.ParseSize(size)
+	if err != nil {
+		return "", "", err
+	}
+	return f, s, nil
+}
+
+// labelError maps label errors to HTTP responses
+func labelError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidLabelRequest):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrLabelUnavailable):
+		return Conflict(c, err.Error())
+	case errors.Is(err, services.ErrImportNotFound):
+		return NotFound(c, "Import not found")
+	}
+	return orderStatusError(c, err)
+}
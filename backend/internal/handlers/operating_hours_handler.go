@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// OperatingHoursHandler handles courier operating hours and the courier and platform holiday calendars
+type OperatingHoursHandler struct {
+	service *services.OperatingHoursService
+}
+
+// NewOperatingHoursHandler creates a new operating hours handler
+func NewOperatingHoursHandler(service *services.OperatingHoursService) *OperatingHoursHandler {
+	return &OperatingHoursHandler{service: service}
+}
+
+// Hours returns the authenticated courier's hours, whether it is open now and its upcoming holidays
+// GET /api/v1/couriers/hours
+func (h *OperatingHoursHandler) Hours(c *fiber.Ctx) error {
+	hours, err := h.service.Hours(c.Context(), c.Locals("courier_id").(uuid.UUID), time.Now())
+	if err != nil {
+		return hoursError(c, err)
+	}
+	return Success(c, hours)
+}
+
+// StoreHours returns a courier's hours and whether it takes pickups at pickupAt (defaults to now),
+// with the next available pickup time when it does not
+// GET /api/v1/stores/couriers/:id/hours?pickupAt=
+func (h *OperatingHoursHandler) StoreHours(c *fiber.Ctx) error {
+	courierID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid courier ID")
+	}
+
+	pickupAt := time.Now()
+	if raw := c.Query("pickupAt"); raw != "" {
+		if pickupAt, err = time.Parse(time.RFC3339, raw); err != nil {
+			return BadRequest(c, "pickupAt must be an RFC 3339 time")
+		}
+	}
+
+	hours, err := h.service.Hours(c.Context(), courierID, pickupAt)
+	if err != nil {
+		return hoursError(c, err)
+	}
+	return Success(c, hours)
+}
+
+// ListHolidays lists the authenticated courier's holidays, its own and the platform's for its country
+// GET /api/v1/couriers/holidays?from=&to=
+func (h *OperatingHoursHandler) ListHolidays(c *fiber.Ctx) error {
+	from, to, err := holidayQueryRange(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	holidays, err := h.service.ListHolidays(c.Context(), c.Locals("courier_id").(uuid.UUID), from, to)
+	if err != nil {
+		return hoursError(c, err)
+	}
+	return Success(c, holidays)
+}
+
+// AddHoliday closes the authenticated courier for a day, or sets special hours for it
+// POST /api/v1/couriers/holidays
+func (h *OperatingHoursHandler) AddHoliday(c *fiber.Ctx) error {
+	var req models.HolidayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	holiday, err := h.service.AddHoliday(c.Context(), c.Locals("courier_id").(uuid.UUID), &req)
+	if err != nil {
+		return hoursError(c, err)
+	}
+	return Created(c, holiday)
+}
+
+// DeleteHoliday removes one of the authenticated courier's holidays
+// DELETE /api/v1/couriers/holidays/:id
+func (h *OperatingHoursHandler) DeleteHoliday(c *fiber.Ctx) error {
+	holidayID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid holiday ID")
+	}
+
+	if err := h.service.DeleteHoliday(c.Context(), c.Locals("courier_id").(uuid.UUID), holidayID); err != nil {
+		return hoursError(c, err)
+	}
+	return Success(c, fiber.Map{"message": "Holiday removed"})
+}
+
+// ListPlatformHolidays lists the platform holidays, optionally for one country
+// GET /api/v1/admin/holidays?country=&from=&to=
+func (h *OperatingHoursHandler) ListPlatformHolidays(c *fiber.Ctx) error {
+	from, to, err := holidayQueryRange(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	holidays, err := h.service.ListPlatformHolidays(c.Context(), c.Query("country"), from, to)
+	if err != nil {
+		return hoursError(c, err)
+	}
+	return Success(c, holidays)
+}
+
+// AddPlatformHoliday adds a public holiday for a country, or for every country
+// POST /api/v1/admin/holidays
+func (h *OperatingHoursHandler) AddPlatformHoliday(c *fiber.Ctx) error {
+	var req models.HolidayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	holiday, err := h.service.AddPlatformHoliday(c.Context(), &req)
+	if err != nil {
+		return hoursError(c, err)
+	}
+	return Created(c, holiday)
+}
+
+// DeletePlatformHoliday removes a platform holiday
+// DELETE /api/v1/admin/holidays/:id
+func (h *OperatingHoursHandler) DeletePlatformHoliday(c *fiber.Ctx) error {
+	holidayID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid holiday ID")
+	}
+
+	if err := h.service.DeletePlatformHoliday(c.Context(), holidayID); err != nil {
+		return hoursError(c, err)
+	}
+	return Success(c, fiber.Map{"message": "Holiday removed"})
+}
+
+// holidayQueryRange reads the from and to query params of a holiday list
+func holidayQueryRange(c *fiber.Ctx) (*time.Time, *time.Time, error) {
+	from, err := parseQueryTime(c.Query("from"), false)
+	if err != nil {
+		return nil, nil, errors.New("from must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	to, err := parseQueryTime(c.Query("to"), true)
+	if err != nil {
+		return nil, nil, errors.New("to must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	return from, to, nil
+}
+
+// hoursError maps operating hours and holiday errors to HTTP responses
+func hoursError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrCourierNotFound):
+		return NotFound(c, "Courier not found")
+	case errors.Is(err, services.ErrHolidayNotFound):
+		return NotFound(c, "Holiday not found")
+	case errors.Is(err, services.ErrInvalidHoliday):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrHolidayExists):
+		return Conflict(c, err.Error())
+	}
+	return ServerError(c, err.Error())
+}
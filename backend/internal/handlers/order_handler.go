@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
@@ -11,12 +16,15 @@ import (
 
 type OrderHandler struct {
 	service      *services.OrderService
+	cancellation *services.CancellationService
+	deliveryPIN  *services.DeliveryPINService
+	dispatch     *services.DispatchService
 	notification *services.NotificationService
 	hub          *websocket.Hub
 }
 
-func NewOrderHandler(service *services.OrderService, notification *services.NotificationService, hub *websocket.Hub) *OrderHandler {
-	return &OrderHandler{service: service, notification: notification, hub: hub}
+func NewOrderHandler(service *services.OrderService, cancellation *services.CancellationService, deliveryPIN *services.DeliveryPINService, dispatch *services.DispatchService, notification *services.NotificationService, hub *websocket.Hub) *OrderHandler {
+	return &OrderHandler{service: service, cancellation: cancellation, deliveryPIN: deliveryPIN, dispatch: dispatch, notification: notification, hub: hub}
 }
 
 func (h *OrderHandler) Create(c *fiber.Ctx) error {
@@ -28,23 +36,27 @@ func (h *OrderHandler) Create(c *fiber.Ctx) error {
 
 	order, err := h.service.Create(c.Context(), courierID, &req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidOrder) {
+			return BadRequest(c, err.Error())
+		}
 		return ServerError(c, err.Error())
 	}
 
 	return Created(c, order)
 }
 
+// List returns the courier's orders with filters, whitelisted sorting and page or cursor pagination
+// GET /api/v1/orders?status=&paymentStatus=&storeId=&dateFrom=&dateTo=&minFare=&maxFare=&search=&sortBy=&sortOrder=&page=&pageSize=&cursor=&include=
 func (h *OrderHandler) List(c *fiber.Ctx) error {
 	courierID := c.Locals("courier_id").(uuid.UUID)
-	filters := &models.OrderListFilters{
-		Page:     c.QueryInt("page", 1),
-		PageSize: c.QueryInt("pageSize", 20),
-		Search:   c.Query("search"),
+	filters, err := parseOrderListFilters(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
 	}
 
 	result, err := h.service.List(c.Context(), courierID, filters)
 	if err != nil {
-		return ServerError(c, err.Error())
+		return orderQueryError(c, err)
 	}
 	return Success(c, result)
 }
@@ -63,6 +75,7 @@ func (h *OrderHandler) GetByID(c *fiber.Ctx) error {
 }
 
 func (h *OrderHandler) UpdateStatus(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
 	orderID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return BadRequest(c, "Invalid order ID")
@@ -73,32 +86,254 @@ func (h *OrderHandler) UpdateStatus(c *fiber.Ctx) error {
 		return BadRequest(c, "Invalid request body")
 	}
 
-	if err := h.service.UpdateStatus(c.Context(), orderID, req.Status); err != nil {
-		return ServerError(c, err.Error())
+	// Deliveries must go through the PIN handoff check
+	if req.Status == models.OrderStatusDelivered {
+		order, err := h.deliveryPIN.ConfirmDelivery(c.Context(), courierID, orderID, req.PIN, req.Note, c.IP())
+		if err != nil {
+			return orderStatusError(c, err)
+		}
+		return Success(c, fiber.Map{"message": "Status updated", "status": order.Status})
 	}
-	return Success(c, fiber.Map{"message": "Status updated"})
+
+	// Failed attempts and returns are driven by the delivery attempt workflow, and cancellations
+	// settle fees and refunds through their own endpoint
+	switch req.Status {
+	case models.OrderStatusFailed, models.OrderStatusReattemptScheduled, models.OrderStatusReturning, models.OrderStatusReturned:
+		return BadRequest(c, "Record failed delivery attempts with POST /api/v1/orders/:id/attempts")
+	case models.OrderStatusCancelled:
+		return BadRequest(c, "Cancel orders with POST /api/v1/orders/:id/cancel")
+	}
+
+	order, err := h.service.UpdateStatus(c.Context(), courierID, orderID, req.Status, req.Note)
+	if err != nil {
+		return orderStatusError(c, err)
+	}
+	return Success(c, fiber.Map{"message": "Status updated", "status": order.Status})
+}
+
+// ConfirmDelivery marks an order delivered after checking the customer's PIN
+// POST /api/v1/orders/:id/deliver
+func (h *OrderHandler) ConfirmDelivery(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.ConfirmDeliveryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	order, err := h.deliveryPIN.ConfirmDelivery(c.Context(), courierID, orderID, req.PIN, req.Note, c.IP())
+	if err != nil {
+		return orderStatusError(c, err)
+	}
+	return Success(c, fiber.Map{"message": "Order delivered", "status": order.Status})
+}
+
+// ResendDeliveryPIN sends the customer a new delivery PIN
+// POST /api/v1/orders/:id/pin/resend
+func (h *OrderHandler) ResendDeliveryPIN(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	if err := h.deliveryPIN.ResendPIN(c.Context(), courierID, orderID); err != nil {
+		return orderStatusError(c, err)
+	}
+	return Success(c, fiber.Map{"message": "A new delivery PIN has been sent to the customer"})
 }
 
 func (h *OrderHandler) Accept(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
 	orderID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return BadRequest(c, "Invalid order ID")
 	}
 
-	if err := h.service.Accept(c.Context(), orderID); err != nil {
-		return ServerError(c, err.Error())
+	if _, err := h.dispatch.Accept(c.Context(), courierID, orderID); err != nil {
+		return orderStatusError(c, err)
 	}
 	return Success(c, fiber.Map{"message": "Order accepted"})
 }
 
+// Decline declines an order. Automatically dispatched orders are offered to the next courier.
+// PUT /api/v1/orders/:id/decline
 func (h *OrderHandler) Decline(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
 	orderID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return BadRequest(c, "Invalid order ID")
 	}
 
-	if err := h.service.Decline(c.Context(), orderID); err != nil {
-		return ServerError(c, err.Error())
+	if _, err := h.dispatch.Decline(c.Context(), courierID, orderID); err != nil {
+		return orderStatusError(c, err)
 	}
 	return Success(c, fiber.Map{"message": "Order declined"})
 }
+
+// Cancel cancels an order assigned to the authenticated courier
+// POST /api/v1/orders/:id/cancel
+func (h *OrderHandler) Cancel(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.CancelOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+	if req.Reason == "" {
+		return BadRequest(c, "Cancellation reason is required")
+	}
+
+	result, err := h.cancellation.CancelByCourier(c.Context(), courierID, orderID, req.Reason)
+	if err != nil {
+		if errors.Is(err, services.ErrNotOrderCourier) {
+			return Forbidden(c, err.Error())
+		}
+		return orderStatusError(c, err)
+	}
+	return Success(c, result)
+}
+
+// parseOrderListFilters reads order list query parameters. List values are comma-separated and
+// dates are RFC 3339 times or YYYY-MM-DD days (UTC), with a dateTo day covering the whole day.
+func parseOrderListFilters(c *fiber.Ctx) (*models.OrderListFilters, error) {
+	filters := &models.OrderListFilters{
+		Search:    strings.TrimSpace(c.Query("search")),
+		SortBy:    c.Query("sortBy"),
+		SortOrder: strings.ToLower(c.Query("sortOrder")),
+		Page:      c.QueryInt("page", 1),
+		PageSize:  c.QueryInt("pageSize", 20),
+		Cursor:    c.Query("cursor"),
+	}
+
+	for _, status := range splitQuery(c.Query("status")) {
+		filters.Status = append(filters.Status, models.OrderStatus(status))
+	}
+	for _, status := range splitQuery(c.Query("paymentStatus")) {
+		filters.PaymentStatus = append(filters.PaymentStatus, models.PaymentStatus(status))
+	}
+	if value := c.Query("storeId"); value != "" {
+		storeID, err := uuid.Parse(value)
+		if err != nil {
+			return nil, errors.New("storeId is not a valid ID")
+		}
+		filters.StoreID = &storeID
+	}
+
+	var err error
+	if filters.DateFrom, err = parseQueryTime(c.Query("dateFrom"), false); err != nil {
+		return nil, errors.New("dateFrom must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	if filters.DateTo, err = parseQueryTime(c.Query("dateTo"), true); err != nil {
+		return nil, errors.New("dateTo must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	if filters.MinFare, err = parseQueryFloat(c.Query("minFare")); err != nil {
+		return nil, errors.New("minFare must be a number")
+	}
+	if filters.MaxFare, err = parseQueryFloat(c.Query("maxFare")); err != nil {
+		return nil, errors.New("maxFare must be a number")
+	}
+
+	// metadata.<key>=<value> filters on a custom field
+	for key, value := range c.Queries() {
+		if name, ok := strings.CutPrefix(key, "metadata."); ok {
+			if filters.Metadata == nil {
+				filters.Metadata = map[string]string{}
+			}
+			filters.Metadata[name] = value
+		}
+	}
+
+	for _, include := range splitQuery(c.Query("include")) {
+		switch include {
+		case "statusHistory":
+			filters.IncludeStatusHistory = true
+		case "tracking":
+			filters.IncludeTracking = true
+		default:
+			return nil, errors.New("include must be statusHistory and/or tracking")
+		}
+	}
+	return filters, nil
+}
+
+func splitQuery(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func parseQueryTime(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func parseQueryFloat(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// orderQueryError maps order list errors to HTTP responses
+func orderQueryError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrInvalidOrderQuery) {
+		return BadRequest(c, err.Error())
+	}
+	return ServerError(c, err.Error())
+}
+
+// orderStatusError maps state machine and handoff errors to HTTP responses
+func orderStatusError(c *fiber.Ctx, err error) error {
+	var transitionErr *services.InvalidTransitionError
+	var invalidPIN *services.InvalidPINError
+	var lockedPIN *services.PINLockedError
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		return NotFound(c, "Order not found")
+	case errors.Is(err, services.ErrNotOrderCourier):
+		return Forbidden(c, err.Error())
+	case errors.As(err, &lockedPIN):
+		return TooManyRequests(c, err.Error())
+	case errors.As(err, &invalidPIN):
+		return BadRequest(c, err.Error())
+	case errors.As(err, &transitionErr),
+		errors.Is(err, services.ErrConcurrentStatusChange),
+		errors.Is(err, services.ErrOfferUnavailable),
+		errors.Is(err, services.ErrDeliveryPINRequired),
+		errors.Is(err, services.ErrDeliveryPINNotIssued),
+		errors.Is(err, services.ErrSignatureRequired),
+		errors.Is(err, services.ErrCODCollectionRequired),
+		errors.Is(err, services.ErrCompleteStopsFirst):
+		return Conflict(c, err.Error())
+	}
+	return ServerError(c, err.Error())
+}
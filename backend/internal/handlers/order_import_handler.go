@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// OrderImportHandler handles bulk order imports for stores
+type OrderImportHandler struct {
+	service *services.OrderImportService
+}
+
+// NewOrderImportHandler creates a new order import handler
+func NewOrderImportHandler(service *services.OrderImportService) *OrderImportHandler {
+	return &OrderImportHandler{service: service}
+}
+
+// Import validates, prices and creates orders from a CSV upload or a JSON array
+// POST /api/v1/stores/orders/import?courierId=&storeId=&dryRun=true&onError=continue|abort
+// Body: multipart form with a "file" field (CSV), a text/csv body, or a JSON array of orders
+func (h *OrderImportHandler) Import(c *fiber.Ctx) error {
+	opts := services.ImportOptions{
+		DryRun:  formOrQuery(c, "dryRun") == "true",
+		OnError: models.ImportErrorMode(formOrQuery(c, "onError")),
+	}
+	for name, target := range map[string]**uuid.UUID{"courierId": &opts.CourierID, "storeId": &opts.StoreID} {
+		if value := formOrQuery(c, name); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return BadRequest(c, fmt.Sprintf("Invalid %s", name))
+			}
+			*target = &id
+		}
+	}
+
+	var rows []services.ImportRow
+	var err error
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEMultipartForm):
+		fileHeader, ferr := c.FormFile("file")
+		if ferr != nil {
+			return BadRequest(c, "A CSV file is required in the \"file\" field")
+		}
+		file, ferr := fileHeader.Open()
+		if ferr != nil {
+			return BadRequest(c, "Could not read the uploaded file")
+		}
+		defer file.Close()
+		opts.Format = "csv"
+		rows, err = h.service.ParseCSV(file)
+	case strings.HasPrefix(contentType, "text/csv"):
+		opts.Format = "csv"
+		rows, err = h.service.ParseCSV(bytes.NewReader(c.Body()))
+	default:
+		opts.Format = "json"
+		rows, err = h.service.ParseJSON(c.Body())
+	}
+	if err != nil {
+		return orderImportError(c, err)
+	}
+
+	imp, err := h.service.Import(c.Context(), rows, opts)
+	if err != nil {
+		return orderImportError(c, err)
+	}
+	return Created(c, imp)
+}
+
+// Get returns an import job and its per-row results
+// GET /api/v1/stores/orders/imports/:id
+func (h *OrderImportHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid import ID")
+	}
+
+	imp, err := h.service.Get(c.Context(), id)
+	if err != nil {
+		return orderImportError(c, err)
+	}
+	return Success(c, imp)
+}
+
+// Report downloads an import's per-row results as CSV
+// GET /api/v1/stores/orders/imports/:id/report
+func (h *OrderImportHandler) Report(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid import ID")
+	}
+
+	imp, err := h.service.Get(c.Context(), id)
+	if err != nil {
+		return orderImportError(c, err)
+	}
+
+	var buf bytes.Buffer
+	if err := h.service.WriteReport(&buf, imp); err != nil {
+		return ServerError(c, err.Error())
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="order-import-%s.csv"`, imp.ID))
+	return c.Send(buf.Bytes())
+}
+
+// formOrQuery reads an option from the multipart form, falling back to the query string
+func formOrQuery(c *fiber.Ctx, name string) string {
+	if strings.HasPrefix(strings.ToLower(c.Get(fiber.HeaderContentType)), fiber.MIMEMultipartForm) {
+		if value := c.FormValue(name); value != "" {
+			return value
+		}
+	}
+	return c.Query(name)
+}
+
+// orderImportError maps import errors to HTTP responses
+func orderImportError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrImportNotFound):
+		return NotFound(c, "Import not found")
+	case errors.Is(err, services.ErrInvalidImport):
+		return BadRequest(c, err.Error())
+	}
+	return ServerError(c, err.Error())
+}
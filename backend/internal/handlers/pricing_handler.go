@@ -1,18 +1,23 @@
 package handlers
 
 import (
+	"errors"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 
 	"nyengo-deliveries/internal/models"
 	"nyengo-deliveries/internal/services"
 )
 
 type PricingHandler struct {
-	service *services.PricingService
+	service      *services.PricingService
+	hoursService *services.OperatingHoursService
 }
 
-func NewPricingHandler(service *services.PricingService) *PricingHandler {
-	return &PricingHandler{service: service}
+func NewPricingHandler(service *services.PricingService, hoursService *services.OperatingHoursService) *PricingHandler {
+	return &PricingHandler{service: service, hoursService: hoursService}
 }
 
 func (h *PricingHandler) GetEstimate(c *fiber.Ctx) error {
@@ -21,6 +26,12 @@ func (h *PricingHandler) GetEstimate(c *fiber.Ctx) error {
 		return BadRequest(c, "Invalid request body")
 	}
 
+	// Multi-stop routes end at the last stop
+	if len(req.Stops) > 0 {
+		last := req.Stops[len(req.Stops)-1]
+		req.DeliveryLatitude, req.DeliveryLongitude = last.Latitude, last.Longitude
+	}
+
 	if req.PickupLatitude == 0 || req.PickupLongitude == 0 ||
 		req.DeliveryLatitude == 0 || req.DeliveryLongitude == 0 {
 		return BadRequest(c, "Pickup and delivery coordinates are required")
@@ -28,8 +39,31 @@ func (h *PricingHandler) GetEstimate(c *fiber.Ctx) error {
 
 	estimate, err := h.service.CalculateEstimate(&req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidOrder) {
+			return BadRequest(c, err.Error())
+		}
 		return ServerError(c, err.Error())
 	}
 
+	// Estimates for a specific courier say whether it is open at the pickup time
+	if req.CourierID != "" {
+		courierID, err := uuid.Parse(req.CourierID)
+		if err != nil {
+			return BadRequest(c, "Invalid courier ID")
+		}
+		pickupAt := time.Now()
+		if req.PickupAt != nil && req.PickupAt.After(pickupAt) {
+			pickupAt = *req.PickupAt
+		}
+		status, err := h.hoursService.Status(c.Context(), courierID, pickupAt)
+		if err != nil {
+			if errors.Is(err, services.ErrCourierNotFound) {
+				return NotFound(c, "Courier not found")
+			}
+			return ServerError(c, err.Error())
+		}
+		estimate.OpeningStatus = status
+	}
+
 	return Success(c, estimate)
 }
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/services"
+	"nyengo-deliveries/internal/storage"
+)
+
+// ProofHandler handles proof-of-delivery uploads and downloads
+type ProofHandler struct {
+	proofService *services.ProofService
+	localStore   *storage.LocalStore // nil unless files are stored on the local filesystem
+	cfg          *config.Config
+}
+
+// NewProofHandler creates a new proof-of-delivery handler
+func NewProofHandler(proofService *services.ProofService, blobStore storage.BlobStore, cfg *config.Config) *ProofHandler {
+	localStore, _ := blobStore.(*storage.LocalStore)
+	return &ProofHandler{proofService: proofService, localStore: localStore, cfg: cfg}
+}
+
+// ServesFiles reports whether the API itself must serve stored files
+func (h *ProofHandler) ServesFiles() bool {
+	return h.localStore != nil
+}
+
+// Upload stores the delivery photo and optional signature for an order
+// POST /api/v1/orders/:id/proof (multipart: photo, signature, recipientName)
+func (h *ProofHandler) Upload(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	upload, err := h.readProofForm(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	proof, err := h.proofService.UploadProof(c.Context(), courierID, orderID, upload)
+	if err != nil {
+		return proofError(c, err)
+	}
+	return Created(c, proof)
+}
+
+// UploadStopProof stores the delivery photo and optional signature for one stop of a multi-stop order
+// POST /api/v1/orders/:id/stops/:stopId/proof (multipart: photo, signature, recipientName)
+func (h *ProofHandler) UploadStopProof(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, stopID, err := parseStopParams(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	upload, err := h.readProofForm(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	proof, err := h.proofService.UploadStopProof(c.Context(), courierID, orderID, stopID, upload)
+	if err != nil {
+		return proofError(c, err)
+	}
+	return Created(c, proof)
+}
+
+// StoreGetStopProof returns signed download links for one stop of a store's multi-stop order
+// GET /api/v1/stores/orders/:id/stops/:stopId/proof
+func (h *ProofHandler) StoreGetStopProof(c *fiber.Ctx) error {
+	orderID, stopID, err := parseStopParams(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	proof, err := h.proofService.GetStopProof(c.Context(), orderID, stopID)
+	if err != nil {
+		return proofError(c, err)
+	}
+	return Success(c, proof)
+}
+
+// GetProof returns signed download links for an order's proof of delivery
+// GET /api/v1/orders/:id/proof
+func (h *ProofHandler) GetProof(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	proof, err := h.proofService.GetCourierProof(c.Context(), courierID, orderID)
+	if err != nil {
+		return proofError(c, err)
+	}
+	return Success(c, proof)
+}
+
+// StoreGetProof returns signed download links for a store's order
+// GET /api/v1/stores/orders/:id/proof
+func (h *ProofHandler) StoreGetProof(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	proof, err := h.proofService.GetProof(c.Context(), orderID)
+	if err != nil {
+		return proofError(c, err)
+	}
+	return Success(c, proof)
+}
+
+// ServeFile streams a file from local storage after checking its signed link
+// GET /api/v1/files/*?expires=...&signature=...
+func (h *ProofHandler) ServeFile(c *fiber.Ctx) error {
+	if h.localStore == nil {
+		return NotFound(c, "File not found")
+	}
+
+	filePath, err := h.localStore.Open(c.Params("*"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return NotFound(c, "File not found")
+		}
+		return Forbidden(c, err.Error())
+	}
+
+	c.Set("Cache-Control", "private, max-age=300")
+	return c.SendFile(filePath)
+}
+
+// readProofForm reads the photo, optional signature and recipient name from a multipart form
+func (h *ProofHandler) readProofForm(c *fiber.Ctx) (*services.ProofUpload, error) {
+	photoHeader, err := c.FormFile("photo")
+	if err != nil {
+		return nil, errors.New("A delivery photo is required (multipart field 'photo')")
+	}
+	photo, err := h.readUpload(photoHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature []byte
+	if signatureHeader, err := c.FormFile("signature"); err == nil {
+		if signature, err = h.readUpload(signatureHeader); err != nil {
+			return nil, err
+		}
+	}
+
+	return &services.ProofUpload{
+		Photo:         photo,
+		Signature:     signature,
+		RecipientName: c.FormValue("recipientName"),
+	}, nil
+}
+
+// readUpload reads a multipart file, rejecting anything over the configured size limit
+func (h *ProofHandler) readUpload(header *multipart.FileHeader) ([]byte, error) {
+	if header.Size > int64(h.cfg.MaxUploadSize) {
+		return nil, fmt.Errorf("%s exceeds maximum size of %d KB", header.Filename, h.cfg.MaxUploadSize/1024)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s", header.Filename)
+	}
+	defer file.Close()
+
+	return io.ReadAll(io.LimitReader(file, int64(h.cfg.MaxUploadSize)+1))
+}
+
+// proofError maps proof service errors to HTTP responses
+func proofError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		return NotFound(c, "Order not found")
+	case errors.Is(err, services.ErrNotOrderCourier):
+		return Forbidden(c, err.Error())
+	case errors.Is(err, services.ErrNoDeliveryProof):
+		return NotFound(c, err.Error())
+	case errors.Is(err, services.ErrProofNotAllowed):
+		return Conflict(c, err.Error())
+	case errors.Is(err, services.ErrInvalidProof):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrStopNotFound):
+		return NotFound(c, err.Error())
+	case errors.Is(err, services.ErrNotMultiStop), errors.Is(err, services.ErrStopAlreadyCompleted):
+		return Conflict(c, err.Error())
+	}
+	return ServerError(c, err.Error())
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// RatingHandler handles delivery ratings from stores and customers
+type RatingHandler struct {
+	ratingService *services.RatingService
+}
+
+// NewRatingHandler creates a new rating handler
+func NewRatingHandler(ratingService *services.RatingService) *RatingHandler {
+	return &RatingHandler{ratingService: ratingService}
+}
+
+// StoreRateOrder rates a delivered order on behalf of the store's customer
+// POST /api/v1/stores/orders/:id/rating
+func (h *RatingHandler) StoreRateOrder(c *fiber.Ctx) error {
+	return h.rateOrder(c, c.Params("id"), models.StatusActorStore)
+}
+
+// CustomerRateOrder rates a delivered order from the customer's tracking link.
+// Only the order UUID from the link is accepted, not the printed order number.
+// POST /api/v1/tracking/:orderId/rating
+func (h *RatingHandler) CustomerRateOrder(c *fiber.Ctx) error {
+	return h.rateOrder(c, c.Params("orderId"), models.StatusActorCustomer)
+}
+
+func (h *RatingHandler) rateOrder(c *fiber.Ctx, orderIDParam, ratedBy string) error {
+	orderID, err := uuid.Parse(orderIDParam)
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.RateOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	order, err := h.ratingService.RateOrder(c.Context(), orderID, &req, ratedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOrderNotFound):
+			return NotFound(c, "Order not found")
+		case errors.Is(err, services.ErrOrderAlreadyRated), errors.Is(err, services.ErrOrderNotDelivered):
+			return Conflict(c, err.Error())
+		case errors.Is(err, services.ErrInvalidRating), errors.Is(err, services.ErrInvalidFeedback):
+			return BadRequest(c, err.Error())
+		}
+		return ServerError(c, err.Error())
+	}
+
+	return Created(c, fiber.Map{
+		"orderId":  order.ID,
+		"rating":   order.CustomerRating,
+		"feedback": order.CustomerFeedback,
+		"ratedBy":  order.RatedBy,
+		"ratedAt":  order.RatedAt,
+	})
+}
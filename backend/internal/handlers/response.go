@@ -46,12 +46,30 @@ func Unauthorized(c *fiber.Ctx, message string) error {
 	})
 }
 
+func Forbidden(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusForbidden).JSON(APIResponse{
+		Success: false, Error: &APIError{Code: "FORBIDDEN", Message: message},
+	})
+}
+
 func NotFound(c *fiber.Ctx, message string) error {
 	return c.Status(fiber.StatusNotFound).JSON(APIResponse{
 		Success: false, Error: &APIError{Code: "NOT_FOUND", Message: message},
 	})
 }
 
+func Conflict(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusConflict).JSON(APIResponse{
+		Success: false, Error: &APIError{Code: "CONFLICT", Message: message},
+	})
+}
+
+func TooManyRequests(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusTooManyRequests).JSON(APIResponse{
+		Success: false, Error: &APIError{Code: "RATE_LIMITED", Message: message},
+	})
+}
+
 func ServerError(c *fiber.Ctx, message string) error {
 	return c.Status(fiber.StatusInternalServerError).JSON(APIResponse{
 		Success: false, Error: &APIError{Code: "SERVER_ERROR", Message: message},
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// ScanHandler handles parcel label scans and custody timelines
+type ScanHandler struct {
+	service *services.ScanService
+}
+
+// NewScanHandler creates a new scan handler
+func NewScanHandler(service *services.ScanService) *ScanHandler {
+	return &ScanHandler{service: service}
+}
+
+// Scan records a scan of a parcel's barcode or QR code by a driver or hub staff member, with the
+// courier's token or a driver's
+// POST /api/v1/scans
+func (h *ScanHandler) Scan(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	driverID := c.Locals("driver_id").(uuid.UUID)
+
+	var req models.ScanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	result, err := h.service.Scan(c.Context(), courierID, driverID, &req)
+	if err != nil {
+		return scanError(c, err)
+	}
+	return Created(c, result)
+}
+
+// Custody returns the chain of custody of one of the courier's orders
+// GET /api/v1/orders/:id/custody
+func (h *ScanHandler) Custody(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	timeline, err := h.service.CustodyForCourier(c.Context(), courierID, orderID)
+	if err != nil {
+		return scanError(c, err)
+	}
+	return Success(c, timeline)
+}
+
+// StoreCustody returns an order's chain of custody
+// GET /api/v1/stores/orders/:id/custody
+func (h *ScanHandler) StoreCustody(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	timeline, err := h.service.Custody(c.Context(), orderID)
+	if err != nil {
+		return scanError(c, err)
+	}
+	return Success(c, timeline)
+}
+
+// scanError maps scan errors to HTTP responses
+func scanError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidScan):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrUnknownParcel):
+		return NotFound(c, err.Error())
+	case errors.Is(err, services.ErrScanNotAllowed), errors.Is(err, services.ErrDuplicateScan):
+		return Conflict(c, err.Error())
+	case errors.Is(err, services.ErrDriverNotFound),
+		errors.Is(err, services.ErrDriverNotActive),
+		errors.Is(err, services.ErrNotAssignedDriver):
+		return driverError(c, err)
+	}
+	return orderStatusError(c, err)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/services"
+)
+
+// ShiftHandler handles couriers and drivers going online and offline. The same routes serve the
+// courier account under /couriers and drivers under /drivers/me.
+type ShiftHandler struct {
+	service *services.ShiftService
+}
+
+// NewShiftHandler creates a new shift handler
+func NewShiftHandler(service *services.ShiftService) *ShiftHandler {
+	return &ShiftHandler{service: service}
+}
+
+// Online starts a shift for the authenticated courier or driver
+// POST /api/v1/couriers/shift/online
+// POST /api/v1/drivers/me/shift/online
+func (h *ShiftHandler) Online(c *fiber.Ctx) error {
+	courierID, driverID := shiftLocals(c)
+
+	status, err := h.service.Online(c.Context(), courierID, driverID)
+	if err != nil {
+		return shiftError(c, err)
+	}
+	return Success(c, status)
+}
+
+// Offline ends the authenticated courier's or driver's shift
+// POST /api/v1/couriers/shift/offline
+// POST /api/v1/drivers/me/shift/offline
+func (h *ShiftHandler) Offline(c *fiber.Ctx) error {
+	courierID, driverID := shiftLocals(c)
+
+	status, err := h.service.Offline(c.Context(), courierID, driverID)
+	if err != nil {
+		return shiftError(c, err)
+	}
+	return Success(c, status)
+}
+
+// Heartbeat keeps the authenticated courier's or driver's shift open
+// POST /api/v1/couriers/shift/heartbeat
+// POST /api/v1/drivers/me/shift/heartbeat
+func (h *ShiftHandler) Heartbeat(c *fiber.Ctx) error {
+	courierID, driverID := shiftLocals(c)
+
+	status, err := h.service.Heartbeat(c.Context(), courierID, driverID)
+	if err != nil {
+		return shiftError(c, err)
+	}
+	return Success(c, status)
+}
+
+// Status returns whether the authenticated courier or driver is online
+// GET /api/v1/couriers/shift
+// GET /api/v1/drivers/me/shift
+func (h *ShiftHandler) Status(c *fiber.Ctx) error {
+	courierID, driverID := shiftLocals(c)
+
+	status, err := h.service.Status(c.Context(), courierID, driverID)
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, status)
+}
+
+// History lists shifts and hours worked. Couriers see all their shifts, or one driver's with
+// driverId; drivers see their own.
+// GET /api/v1/couriers/shifts?driverId=&from=&to=
+// GET /api/v1/drivers/me/shifts?from=&to=
+func (h *ShiftHandler) History(c *fiber.Ctx) error {
+	courierID, driverID := shiftLocals(c)
+
+	var filter *uuid.UUID
+	if driverID != uuid.Nil {
+		filter = &driverID
+	} else if raw := c.Query("driverId"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return BadRequest(c, "Invalid driver ID")
+		}
+		filter = &id
+	}
+
+	from, err := parseQueryTime(c.Query("from"), false)
+	if err != nil {
+		return BadRequest(c, "from must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	to, err := parseQueryTime(c.Query("to"), true)
+	if err != nil {
+		return BadRequest(c, "to must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+
+	history, err := h.service.History(c.Context(), courierID, filter, from, to)
+	if err != nil {
+		return shiftError(c, err)
+	}
+	return Success(c, history)
+}
+
+// Availability returns who is online for the authenticated courier right now
+// GET /api/v1/couriers/availability
+func (h *ShiftHandler) Availability(c *fiber.Ctx) error {
+	availability, err := h.service.Availability(c.Context(), c.Locals("courier_id").(uuid.UUID))
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, availability)
+}
+
+// shiftLocals returns the courier and, for driver tokens, the driver (uuid.Nil for the courier account)
+func shiftLocals(c *fiber.Ctx) (uuid.UUID, uuid.UUID) {
+	driverID, _ := c.Locals("driver_id").(uuid.UUID)
+	return c.Locals("courier_id").(uuid.UUID), driverID
+}
+
+// shiftError maps shift errors to HTTP responses
+func shiftError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidShift):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrDriverNotFound):
+		return NotFound(c, "Driver not found")
+	case errors.Is(err, services.ErrDriverNotActive):
+		return Forbidden(c, err.Error())
+	case errors.Is(err, services.ErrNotOnline):
+		return Conflict(c, err.Error())
+	}
+	return ServerError(c, err.Error())
+}
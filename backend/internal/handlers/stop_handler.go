@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// StopHandler handles drop-offs on multi-stop orders
+type StopHandler struct {
+	stopService *services.StopService
+}
+
+// NewStopHandler creates a new stop handler
+func NewStopHandler(stopService *services.StopService) *StopHandler {
+	return &StopHandler{stopService: stopService}
+}
+
+// UpdateStatus marks a stop delivered or failed
+// PUT /api/v1/orders/:id/stops/:stopId/status
+func (h *StopHandler) UpdateStatus(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, stopID, err := parseStopParams(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	var req models.UpdateStopStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	order, err := h.stopService.CompleteStop(c.Context(), courierID, orderID, stopID, &req)
+	if err != nil {
+		return stopError(c, err)
+	}
+	return Success(c, order)
+}
+
+// parseStopParams reads the order and stop IDs from the route
+func parseStopParams(c *fiber.Ctx) (uuid.UUID, uuid.UUID, error) {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("Invalid order ID")
+	}
+	stopID, err := uuid.Parse(c.Params("stopId"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("Invalid stop ID")
+	}
+	return orderID, stopID, nil
+}
+
+// stopError maps stop errors to HTTP responses, falling back to order status errors
+func stopError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrStopNotFound):
+		return NotFound(c, err.Error())
+	case errors.Is(err, services.ErrNotMultiStop),
+		errors.Is(err, services.ErrStopNotAllowed),
+		errors.Is(err, services.ErrStopAlreadyCompleted),
+		errors.Is(err, services.ErrStopProofRequired):
+		return Conflict(c, err.Error())
+	case errors.Is(err, services.ErrInvalidStopUpdate), errors.Is(err, services.ErrStopCODRequired):
+		return BadRequest(c, err.Error())
+	}
+	return orderStatusError(c, err)
+}
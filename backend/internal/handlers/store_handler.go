@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -15,6 +19,11 @@ import (
 type StoreHandler struct {
 	courierService         *services.CourierService
 	orderService           *services.OrderService
+	cancellationService    *services.CancellationService
+	dispatchService        *services.DispatchService
+	vehicleService         *services.VehicleService
+	shiftService           *services.ShiftService
+	hoursService           *services.OperatingHoursService
 	pricingService         *services.PricingService
 	externalCourierService *services.ExternalCourierService
 	cfg                    *config.Config
@@ -23,6 +32,11 @@ type StoreHandler struct {
 func NewStoreHandler(
 	courierService *services.CourierService,
 	orderService *services.OrderService,
+	cancellationService *services.CancellationService,
+	dispatchService *services.DispatchService,
+	vehicleService *services.VehicleService,
+	shiftService *services.ShiftService,
+	hoursService *services.OperatingHoursService,
 	pricingService *services.PricingService,
 	externalCourierService *services.ExternalCourierService,
 	cfg *config.Config,
@@ -30,6 +44,11 @@ func NewStoreHandler(
 	return &StoreHandler{
 		courierService:         courierService,
 		orderService:           orderService,
+		cancellationService:    cancellationService,
+		dispatchService:        dispatchService,
+		vehicleService:         vehicleService,
+		shiftService:           shiftService,
+		hoursService:           hoursService,
 		pricingService:         pricingService,
 		externalCourierService: externalCourierService,
 		cfg:                    cfg,
@@ -37,10 +56,22 @@ func NewStoreHandler(
 }
 
 // ListCouriers returns available couriers based on delivery distance
-// Query params: pickupLat, pickupLon, deliveryLat, deliveryLon
+// Query params: pickupLat, pickupLon, deliveryLat, deliveryLon, packageWeight, packageSize, pickupAt, includeClosed
 // For local deliveries (< threshold), returns registered local couriers
 // For inter-city deliveries (>= threshold), returns external courier services
+// Only local couriers who are open at the pickup time (pickupAt, RFC 3339, defaults to now) and on
+// shift are returned; with a later pickupAt shifts are not checked. With packageWeight (kg) or
+// packageSize, only those with a vehicle able to carry the package. includeClosed=true also returns
+// closed couriers, with the time they next take pickups.
 func (h *StoreHandler) ListCouriers(c *fiber.Ctx) error {
+	filter, err := h.parseCourierFilter(c)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOrder) {
+			return BadRequest(c, err.Error())
+		}
+		return ServerError(c, err.Error())
+	}
+
 	// Parse coordinates from query params
 	pickupLat, err := strconv.ParseFloat(c.Query("pickupLat", "0"), 64)
 	if err != nil {
@@ -61,7 +92,7 @@ func (h *StoreHandler) ListCouriers(c *fiber.Ctx) error {
 
 	// If coordinates provided, use distance-based selection
 	if pickupLat != 0 && pickupLon != 0 && deliveryLat != 0 && deliveryLon != 0 {
-		return h.listCouriersByDistance(c, pickupLat, pickupLon, deliveryLat, deliveryLon)
+		return h.listCouriersByDistance(c, pickupLat, pickupLon, deliveryLat, deliveryLon, filter)
 	}
 
 	// Fallback to area-based selection (legacy)
@@ -70,11 +101,91 @@ func (h *StoreHandler) ListCouriers(c *fiber.Ctx) error {
 	if err != nil {
 		return ServerError(c, err.Error())
 	}
-	return Success(c, couriers)
+	matching := make([]models.CourierListItem, 0, len(couriers))
+	for _, courier := range couriers {
+		if opening, ok := filter.admit(courier.ID); ok {
+			courier.OpeningStatus = opening
+			matching = append(matching, courier)
+		}
+	}
+	return Success(c, matching)
+}
+
+// courierFilter decides which local couriers a store is offered, with their opening status
+type courierFilter struct {
+	onShift       map[uuid.UUID]bool
+	capable       map[uuid.UUID]bool // nil when no package was described
+	openings      map[uuid.UUID]*models.OpeningStatus
+	scheduled     bool // the pickup is later, so who is on shift now does not matter
+	includeClosed bool
+}
+
+// admit reports whether a courier is offered, with its opening status at the pickup time
+func (f *courierFilter) admit(courierID uuid.UUID) (*models.OpeningStatus, bool) {
+	if f.capable != nil && !f.capable[courierID] {
+		return nil, false
+	}
+	opening := f.openings[courierID]
+	if opening != nil && !opening.IsOpen {
+		return opening, f.includeClosed
+	}
+	return opening, f.scheduled || f.onShift[courierID]
+}
+
+// parseCourierFilter reads the pickupAt, includeClosed, packageWeight and packageSize query params
+// into the filter for the couriers a store can book
+func (h *StoreHandler) parseCourierFilter(c *fiber.Ctx) (*courierFilter, error) {
+	filter := &courierFilter{includeClosed: c.QueryBool("includeClosed")}
+
+	pickupAt := time.Now()
+	if raw := c.Query("pickupAt"); raw != "" {
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: pickupAt must be an RFC 3339 time", services.ErrInvalidOrder)
+		}
+		if at.After(pickupAt) {
+			pickupAt, filter.scheduled = at, true
+		}
+	}
+
+	size := strings.ToLower(strings.TrimSpace(c.Query("packageSize")))
+	rawWeight := c.Query("packageWeight")
+
+	var weight float64
+	if rawWeight != "" {
+		var err error
+		if weight, err = strconv.ParseFloat(rawWeight, 64); err != nil || weight < 0 {
+			return nil, fmt.Errorf("%w: packageWeight must be a non-negative number of kg", services.ErrInvalidOrder)
+		}
+	}
+	if size != "" && size != "small" && size != "medium" && size != "large" {
+		return nil, fmt.Errorf("%w: packageSize must be small, medium or large", services.ErrInvalidOrder)
+	}
+
+	availabilities, err := h.shiftService.Availabilities(c.Context())
+	if err != nil {
+		return nil, err
+	}
+	filter.onShift = make(map[uuid.UUID]bool, len(availabilities))
+	for courierID, availability := range availabilities {
+		if availability.AvailableShifts > 0 {
+			filter.onShift[courierID] = true
+		}
+	}
+	if filter.openings, err = h.hoursService.Statuses(c.Context(), pickupAt); err != nil {
+		return nil, err
+	}
+	if size != "" || rawWeight != "" {
+		if filter.capable, err = h.vehicleService.CapableCouriers(c.Context(), weight, size); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
 }
 
-// listCouriersByDistance returns couriers based on calculated distance
-func (h *StoreHandler) listCouriersByDistance(c *fiber.Ctx, pickupLat, pickupLon, deliveryLat, deliveryLon float64) error {
+// listCouriersByDistance returns couriers based on calculated distance. Local couriers the filter
+// does not admit are left out, and closed ones are never recommended.
+func (h *StoreHandler) listCouriersByDistance(c *fiber.Ctx, pickupLat, pickupLon, deliveryLat, deliveryLon float64, filter *courierFilter) error {
 	// Calculate distance
 	distance := h.pricingService.CalculateDistance(pickupLat, pickupLon, deliveryLat, deliveryLon)
 	distance = math.Round(distance*100) / 100
@@ -93,7 +204,12 @@ func (h *StoreHandler) listCouriersByDistance(c *fiber.Ctx, pickupLat, pickupLon
 			return ServerError(c, err.Error())
 		}
 
+		var open []models.CourierOption
 		for _, courier := range couriers {
+			opening, ok := filter.admit(courier.ID)
+			if !ok {
+				continue
+			}
 			fare := h.pricingService.CalculateLocalCourierFare(distance, courier.BaseRatePerKm, courier.MinimumFare)
 			estimatedTime := h.calculateEstimatedTime(distance)
 
@@ -112,13 +228,17 @@ func (h *StoreHandler) listCouriersByDistance(c *fiber.Ctx, pickupLat, pickupLon
 				IsVerified:      courier.IsVerified,
 				IsFeatured:      courier.IsFeatured,
 				EstimatedTime:   estimatedTime,
+				OpeningStatus:   opening,
 			}
 			courierOptions = append(courierOptions, option)
+			if opening == nil || opening.IsOpen {
+				open = append(open, option)
+			}
 		}
 
-		// Set recommendations for local couriers
-		if len(courierOptions) > 0 {
-			cheapest, fastest, recommended = h.findLocalRecommendations(courierOptions)
+		// Set recommendations for local couriers open at the pickup time
+		if len(open) > 0 {
+			cheapest, fastest, recommended = h.findLocalRecommendations(open)
 		}
 	} else {
 		// Inter-city delivery - return external couriers
@@ -173,7 +293,9 @@ func (h *StoreHandler) findLocalRecommendations(options []models.CourierOption)
 		if opt.EstimatedFare < options[cheapestIdx].EstimatedFare {
 			cheapestIdx = i
 		}
-		if opt.Rating > options[bestRatedIdx].Rating {
+		// Ties go to the courier with more reviews behind their rating
+		best := options[bestRatedIdx]
+		if opt.Rating > best.Rating || (opt.Rating == best.Rating && opt.TotalReviews > best.TotalReviews) {
 			bestRatedIdx = i
 		}
 	}
@@ -230,28 +352,82 @@ func (h *StoreHandler) findExternalRecommendations(options []models.CourierOptio
 	return &cheapest, &fastest, &recommended
 }
 
+// CreateOrder creates an order for the chosen courier. With "dispatch": "auto" it is offered to the
+// best available courier; with "dispatch": "broadcast" to every eligible courier at once.
+// POST /api/v1/stores/orders
 func (h *StoreHandler) CreateOrder(c *fiber.Ctx) error {
 	var req struct {
-		CourierID string `json:"courierId"`
+		CourierID    string              `json:"courierId"`
+		Dispatch     models.DispatchMode `json:"dispatch"`
+		DispatchArea string              `json:"dispatchArea"`
 		models.CreateOrderRequest
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return BadRequest(c, "Invalid request body")
 	}
 
-	courierID, err := uuid.Parse(req.CourierID)
-	if err != nil {
-		return BadRequest(c, "Invalid courier ID")
+	var order *models.Order
+	var err error
+	switch req.Dispatch {
+	case models.DispatchModeAuto:
+		if req.CourierID != "" {
+			return BadRequest(c, "courierId cannot be combined with automatic dispatch")
+		}
+		order, err = h.dispatchService.CreateOrder(c.Context(), &req.CreateOrderRequest, req.DispatchArea)
+	case models.DispatchModeBroadcast:
+		if req.CourierID != "" {
+			return BadRequest(c, "courierId cannot be combined with a broadcast")
+		}
+		order, err = h.dispatchService.BroadcastOrder(c.Context(), &req.CreateOrderRequest, req.DispatchArea)
+	case "", models.DispatchModeManual:
+		courierID, parseErr := uuid.Parse(req.CourierID)
+		if parseErr != nil {
+			return BadRequest(c, "Invalid courier ID")
+		}
+		order, err = h.orderService.Create(c.Context(), courierID, &req.CreateOrderRequest)
+	default:
+		return BadRequest(c, "dispatch must be manual, auto or broadcast")
 	}
-
-	order, err := h.orderService.Create(c.Context(), courierID, &req.CreateOrderRequest)
 	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidOrder):
+			return BadRequest(c, err.Error())
+		case errors.Is(err, services.ErrNoCourierAvailable):
+			return Conflict(c, err.Error())
+		}
 		return ServerError(c, err.Error())
 	}
 
+	// The shipping label can be printed straight away
+	c.Set(fiber.HeaderLink, fmt.Sprintf(`</api/v1/stores/orders/%s/label>; rel="label"`, order.ID))
 	return Created(c, order)
 }
 
+// ListOrders returns a store's orders with the same filters, sorting and pagination as the courier order list
+// GET /api/v1/stores/orders?storeId=&courierId=&status=...
+func (h *StoreHandler) ListOrders(c *fiber.Ctx) error {
+	filters, err := parseOrderListFilters(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+	if filters.StoreID == nil {
+		return BadRequest(c, "A valid storeId is required")
+	}
+	if value := c.Query("courierId"); value != "" {
+		courierID, err := uuid.Parse(value)
+		if err != nil {
+			return BadRequest(c, "courierId is not a valid ID")
+		}
+		filters.CourierID = &courierID
+	}
+
+	result, err := h.orderService.Query(c.Context(), filters)
+	if err != nil {
+		return orderQueryError(c, err)
+	}
+	return Success(c, result)
+}
+
 func (h *StoreHandler) GetOrderStatus(c *fiber.Ctx) error {
 	orderID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -270,3 +446,26 @@ func (h *StoreHandler) GetOrderStatus(c *fiber.Ctx) error {
 		"updatedAt":   order.UpdatedAt,
 	})
 }
+
+// CancelOrder cancels an order on behalf of the store, applying the cancellation fee policy
+// POST /api/v1/stores/orders/:id/cancel
+func (h *StoreHandler) CancelOrder(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.CancelOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+	if req.Reason == "" {
+		return BadRequest(c, "Cancellation reason is required")
+	}
+
+	result, err := h.cancellationService.CancelByStore(c.Context(), orderID, req.Reason)
+	if err != nil {
+		return orderStatusError(c, err)
+	}
+	return Success(c, result)
+}
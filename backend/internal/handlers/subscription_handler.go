@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// SubscriptionHandler handles recurring delivery subscriptions for stores
+type SubscriptionHandler struct {
+	service *services.SubscriptionService
+}
+
+// NewSubscriptionHandler creates a new subscription handler
+func NewSubscriptionHandler(service *services.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{service: service}
+}
+
+// Create creates a recurring delivery from an order template and schedule
+// POST /api/v1/stores/subscriptions
+func (h *SubscriptionHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	sub, err := h.service.Create(c.Context(), &req)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	return Created(c, sub)
+}
+
+// List returns a store's subscriptions
+// GET /api/v1/stores/subscriptions?storeId=...
+func (h *SubscriptionHandler) List(c *fiber.Ctx) error {
+	storeID, err := uuid.Parse(c.Query("storeId"))
+	if err != nil {
+		return BadRequest(c, "A valid storeId is required")
+	}
+
+	subs, err := h.service.ListByStore(c.Context(), storeID)
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, subs)
+}
+
+// Get returns a subscription
+// GET /api/v1/stores/subscriptions/:id
+func (h *SubscriptionHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid subscription ID")
+	}
+
+	sub, err := h.service.Get(c.Context(), id)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	return Success(c, sub)
+}
+
+// Upcoming lists the next occurrences of a subscription
+// GET /api/v1/stores/subscriptions/:id/occurrences?limit=10
+func (h *SubscriptionHandler) Upcoming(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid subscription ID")
+	}
+
+	occurrences, err := h.service.Upcoming(c.Context(), id, c.QueryInt("limit", 10))
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	return Success(c, occurrences)
+}
+
+// Pause stops generating orders for a subscription
+// POST /api/v1/stores/subscriptions/:id/pause
+func (h *SubscriptionHandler) Pause(c *fiber.Ctx) error {
+	return h.changeStatus(c, h.service.Pause)
+}
+
+// Resume restarts a paused subscription
+// POST /api/v1/stores/subscriptions/:id/resume
+func (h *SubscriptionHandler) Resume(c *fiber.Ctx) error {
+	return h.changeStatus(c, h.service.Resume)
+}
+
+// End permanently ends a subscription
+// POST /api/v1/stores/subscriptions/:id/end
+func (h *SubscriptionHandler) End(c *fiber.Ctx) error {
+	return h.changeStatus(c, h.service.End)
+}
+
+// Skip skips one upcoming occurrence
+// POST /api/v1/stores/subscriptions/:id/skip
+func (h *SubscriptionHandler) Skip(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid subscription ID")
+	}
+
+	var req models.SkipOccurrenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+	if req.Date == "" {
+		return BadRequest(c, "The date to skip is required (YYYY-MM-DD)")
+	}
+
+	sub, err := h.service.Skip(c.Context(), id, req.Date)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	return Success(c, sub)
+}
+
+func (h *SubscriptionHandler) changeStatus(c *fiber.Ctx, change func(ctx context.Context, id uuid.UUID) (*models.DeliverySubscription, error)) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid subscription ID")
+	}
+
+	sub, err := change(c.Context(), id)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	return Success(c, sub)
+}
+
+// subscriptionError maps subscription errors to HTTP responses
+func subscriptionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrSubscriptionNotFound):
+		return NotFound(c, "Subscription not found")
+	case errors.Is(err, services.ErrOccurrenceNotFound):
+		return NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidSubscription), errors.Is(err, services.ErrInvalidRecurrence):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrSubscriptionState), errors.Is(err, services.ErrOccurrenceGenerated):
+		return Conflict(c, err.Error())
+	}
+	return ServerError(c, err.Error())
+}
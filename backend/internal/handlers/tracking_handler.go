@@ -14,13 +14,15 @@ import (
 // TrackingHandler handles live tracking API endpoints
 type TrackingHandler struct {
 	trackingService *services.TrackingService
+	driverService   *services.DriverService
 	orderRepo       *repository.OrderRepository
 }
 
 // NewTrackingHandler creates a new tracking handler
-func NewTrackingHandler(trackingService *services.TrackingService, orderRepo *repository.OrderRepository) *TrackingHandler {
+func NewTrackingHandler(trackingService *services.TrackingService, driverService *services.DriverService, orderRepo *repository.OrderRepository) *TrackingHandler {
 	return &TrackingHandler{
 		trackingService: trackingService,
+		driverService:   driverService,
 		orderRepo:       orderRepo,
 	}
 }
@@ -48,7 +50,16 @@ func (h *TrackingHandler) resolveOrderID(c *fiber.Ctx, orderIDParam string) (uui
 	return uuid.Nil, err
 }
 
-// StartTracking initiates tracking for an order
+// authorize checks that the authenticated courier or driver may drive the order's tracking and
+// returns the assigned driver's details, or nil when the courier account works the order itself
+func (h *TrackingHandler) authorize(c *fiber.Ctx, orderID uuid.UUID) (*services.DriverInfo, error) {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	driverID := c.Locals("driver_id").(uuid.UUID)
+	return h.driverService.AuthorizeTracking(c.Context(), courierID, driverID, orderID)
+}
+
+// StartTracking initiates tracking for an order. Orders assigned to a driver are tracked under
+// that driver's details; otherwise they come from the body.
 // POST /api/v1/tracking/:orderId/start
 func (h *TrackingHandler) StartTracking(c *fiber.Ctx) error {
 	orderID, err := uuid.Parse(c.Params("orderId"))
@@ -56,17 +67,23 @@ func (h *TrackingHandler) StartTracking(c *fiber.Ctx) error {
 		return BadRequest(c, "Invalid order ID")
 	}
 
-	var driverInfo services.DriverInfo
-	if err := c.BodyParser(&driverInfo); err != nil {
-		return BadRequest(c, "Invalid driver info")
+	driverInfo, err := h.authorize(c, orderID)
+	if err != nil {
+		return driverError(c, err)
 	}
+	if driverInfo == nil {
+		driverInfo = &services.DriverInfo{}
+		if err := c.BodyParser(driverInfo); err != nil {
+			return BadRequest(c, "Invalid driver info")
+		}
 
-	if driverInfo.Name == "" || driverInfo.Phone == "" {
-		return BadRequest(c, "Driver name and phone are required")
+		if driverInfo.Name == "" || driverInfo.Phone == "" {
+			return BadRequest(c, "Driver name and phone are required")
+		}
 	}
 
-	if err := h.trackingService.StartTracking(c.Context(), orderID, &driverInfo); err != nil {
-		return ServerError(c, err.Error())
+	if err := h.trackingService.StartTracking(c.Context(), orderID, driverInfo); err != nil {
+		return orderStatusError(c, err)
 	}
 
 	return Success(c, fiber.Map{
@@ -75,7 +92,8 @@ func (h *TrackingHandler) StartTracking(c *fiber.Ctx) error {
 	})
 }
 
-// UpdateLocation receives location update from driver
+// UpdateLocation receives location update from driver. Only the driver assigned to the order
+// may send them, or the courier account when no driver is.
 // POST /api/v1/tracking/:orderId/location
 func (h *TrackingHandler) UpdateLocation(c *fiber.Ctx) error {
 	orderID, err := uuid.Parse(c.Params("orderId"))
@@ -100,6 +118,10 @@ func (h *TrackingHandler) UpdateLocation(c *fiber.Ctx) error {
 		return BadRequest(c, "Latitude and longitude are required")
 	}
 
+	if _, err := h.authorize(c, orderID); err != nil {
+		return driverError(c, err)
+	}
+
 	update := &services.LocationUpdate{
 		OrderID:   orderID,
 		Latitude:  req.Latitude,
@@ -203,6 +225,10 @@ func (h *TrackingHandler) StopTracking(c *fiber.Ctx) error {
 		req.Reason = "completed"
 	}
 
+	if _, err := h.authorize(c, orderID); err != nil {
+		return driverError(c, err)
+	}
+
 	if err := h.trackingService.StopTracking(c.Context(), orderID, req.Reason); err != nil {
 		return ServerError(c, err.Error())
 	}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// TransferHandler handles courier-to-courier order handovers
+type TransferHandler struct {
+	service *services.TransferService
+}
+
+// NewTransferHandler creates a new transfer handler
+func NewTransferHandler(service *services.TransferService) *TransferHandler {
+	return &TransferHandler{service: service}
+}
+
+// Propose asks another courier to take over one of the authenticated courier's orders
+// POST /api/v1/orders/:id/transfer
+func (h *TransferHandler) Propose(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.TransferOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	transfer, err := h.service.Propose(c.Context(), courierID, orderID, &req)
+	if err != nil {
+		return transferError(c, err)
+	}
+	return Created(c, transfer)
+}
+
+// AdminPropose asks a courier to take over an order on behalf of its current courier
+// POST /api/v1/admin/orders/:id/transfer
+func (h *TransferHandler) AdminPropose(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	var req models.TransferOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	transfer, err := h.service.ProposeByAdmin(c.Context(), orderID, &req)
+	if err != nil {
+		return transferError(c, err)
+	}
+	return Created(c, transfer)
+}
+
+// Incoming lists the handovers waiting for the authenticated courier's answer
+// GET /api/v1/orders/transfers
+func (h *TransferHandler) Incoming(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+
+	transfers, err := h.service.Incoming(c.Context(), courierID)
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, transfers)
+}
+
+// Accept takes over the order of a handover addressed to the authenticated courier
+// POST /api/v1/orders/transfers/:transferId/accept
+func (h *TransferHandler) Accept(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	transferID, err := uuid.Parse(c.Params("transferId"))
+	if err != nil {
+		return BadRequest(c, "Invalid transfer ID")
+	}
+
+	transfer, order, err := h.service.Accept(c.Context(), courierID, transferID)
+	if err != nil {
+		return transferError(c, err)
+	}
+	return Success(c, fiber.Map{"transfer": transfer, "order": order})
+}
+
+// Reject turns down a handover addressed to the authenticated courier
+// POST /api/v1/orders/transfers/:transferId/reject
+func (h *TransferHandler) Reject(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	transferID, req, err := parseTransferResponse(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	transfer, err := h.service.Reject(c.Context(), courierID, transferID, req.Note)
+	if err != nil {
+		return transferError(c, err)
+	}
+	return Success(c, transfer)
+}
+
+// Cancel withdraws a handover the authenticated courier proposed
+// POST /api/v1/orders/transfers/:transferId/cancel
+func (h *TransferHandler) Cancel(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	transferID, req, err := parseTransferResponse(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	transfer, err := h.service.Cancel(c.Context(), courierID, transferID, req.Note)
+	if err != nil {
+		return transferError(c, err)
+	}
+	return Success(c, transfer)
+}
+
+// List returns the handover log of an order the authenticated courier holds or was part of
+// GET /api/v1/orders/:id/transfers
+func (h *TransferHandler) List(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	transfers, err := h.service.ListForCourier(c.Context(), courierID, orderID)
+	if err != nil {
+		return transferError(c, err)
+	}
+	return Success(c, transfers)
+}
+
+// StoreList returns an order's handover log
+// GET /api/v1/stores/orders/:id/transfers
+func (h *TransferHandler) StoreList(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid order ID")
+	}
+
+	transfers, err := h.service.List(c.Context(), orderID)
+	if err != nil {
+		return transferError(c, err)
+	}
+	return Success(c, transfers)
+}
+
+func parseTransferResponse(c *fiber.Ctx) (uuid.UUID, *models.TransferResponseRequest, error) {
+	transferID, err := uuid.Parse(c.Params("transferId"))
+	if err != nil {
+		return uuid.Nil, nil, errors.New("Invalid transfer ID")
+	}
+
+	var req models.TransferResponseRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return uuid.Nil, nil, errors.New("Invalid request body")
+		}
+	}
+	return transferID, &req, nil
+}
+
+// transferError maps order transfer errors to HTTP responses
+func transferError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTransferNotFound):
+		return NotFound(c, "Transfer not found")
+	case errors.Is(err, services.ErrInvalidTransfer):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrNotTransferRecipient), errors.Is(err, services.ErrNotTransferProposer):
+		return Forbidden(c, err.Error())
+	case errors.Is(err, services.ErrOrderNotTransferable),
+		errors.Is(err, services.ErrTransferPending),
+		errors.Is(err, services.ErrTransferClosed),
+		errors.Is(err, services.ErrTransferExpired),
+		errors.Is(err, services.ErrTransferCODHeld):
+		return Conflict(c, err.Error())
+	}
+	return orderStatusError(c, err)
+}
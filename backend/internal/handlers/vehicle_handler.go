@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/services"
+)
+
+// VehicleHandler handles a courier company's vehicle registry
+type VehicleHandler struct {
+	service *services.VehicleService
+}
+
+// NewVehicleHandler creates a new vehicle handler
+func NewVehicleHandler(service *services.VehicleService) *VehicleHandler {
+	return &VehicleHandler{service: service}
+}
+
+// Register adds a vehicle to the authenticated courier
+// POST /api/v1/couriers/vehicles
+func (h *VehicleHandler) Register(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+
+	var req models.VehicleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	vehicle, err := h.service.Register(c.Context(), courierID, &req)
+	if err != nil {
+		return vehicleError(c, err)
+	}
+	return Created(c, vehicle)
+}
+
+// List returns the authenticated courier's vehicles
+// GET /api/v1/couriers/vehicles?type=&active=
+func (h *VehicleHandler) List(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+
+	vehicles, err := h.service.List(c.Context(), courierID, c.Query("type"), c.QueryBool("active"))
+	if err != nil {
+		return ServerError(c, err.Error())
+	}
+	return Success(c, vehicles)
+}
+
+// Get returns one of the authenticated courier's vehicles
+// GET /api/v1/couriers/vehicles/:id
+func (h *VehicleHandler) Get(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	vehicleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid vehicle ID")
+	}
+
+	vehicle, err := h.service.Get(c.Context(), courierID, vehicleID)
+	if err != nil {
+		return vehicleError(c, err)
+	}
+	return Success(c, vehicle)
+}
+
+// Update changes a vehicle's details or documents, or takes it out of service
+// PUT /api/v1/couriers/vehicles/:id
+func (h *VehicleHandler) Update(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	vehicleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid vehicle ID")
+	}
+
+	var req models.VehicleUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	vehicle, err := h.service.Update(c.Context(), courierID, vehicleID, &req)
+	if err != nil {
+		return vehicleError(c, err)
+	}
+	return Success(c, vehicle)
+}
+
+// Delete removes a vehicle from the authenticated courier's registry
+// DELETE /api/v1/couriers/vehicles/:id
+func (h *VehicleHandler) Delete(c *fiber.Ctx) error {
+	courierID := c.Locals("courier_id").(uuid.UUID)
+	vehicleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return BadRequest(c, "Invalid vehicle ID")
+	}
+
+	if err := h.service.Delete(c.Context(), courierID, vehicleID); err != nil {
+		return vehicleError(c, err)
+	}
+	return Success(c, fiber.Map{"message": "Vehicle removed"})
+}
+
+// vehicleError maps vehicle errors to HTTP responses
+func vehicleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrVehicleNotFound):
+		return NotFound(c, "Vehicle not found")
+	case errors.Is(err, services.ErrInvalidVehicle):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrVehiclePlateTaken):
+		return Conflict(c, err.Error())
+	}
+	return ServerError(c, err.Error())
+}
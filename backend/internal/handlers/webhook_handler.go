@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,6 +15,7 @@ import (
 
 type WebhookHandler struct {
 	orderService        *services.OrderService
+	cancellationService *services.CancellationService
 	notificationService *services.NotificationService
 	orderRepo           *repository.OrderRepository
 	cfg                 *config.Config
@@ -21,12 +23,14 @@ type WebhookHandler struct {
 
 func NewWebhookHandler(
 	orderService *services.OrderService,
+	cancellationService *services.CancellationService,
 	notificationService *services.NotificationService,
 	orderRepo *repository.OrderRepository,
 	cfg *config.Config,
 ) *WebhookHandler {
 	return &WebhookHandler{
 		orderService:        orderService,
+		cancellationService: cancellationService,
 		notificationService: notificationService,
 		orderRepo:           orderRepo,
 		cfg:                 cfg,
@@ -155,12 +159,39 @@ func (h *WebhookHandler) HandleDeliveryWebhook(c *fiber.Ctx) error {
 	// Map courier status to internal status
 	internalStatus, exists := courierStatusMapping[status]
 	if !exists {
-		// Default to accepted/processing for unknown statuses
-		internalStatus = models.OrderStatusAccepted
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Unknown delivery status: " + status,
+		})
+	}
+
+	// Repeated deliveries of the same status are acknowledged without changes
+	if order.Status == internalStatus {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"success": true,
+			"message": "Delivery status unchanged",
+			"data": fiber.Map{
+				"orderId":   order.ID,
+				"newStatus": internalStatus,
+			},
+		})
 	}
 
-	// Update order status
-	if err := h.orderRepo.UpdateStatus(c.Context(), order.ID, internalStatus); err != nil {
+	// Update order status through the state machine. Cancellations settle fees, refunds and the
+	// courier's wallet like any other cancellation, and notify the courier themselves.
+	if internalStatus == models.OrderStatusCancelled {
+		_, err = h.cancellationService.CancelFromWebhook(c.Context(), order, payload.Event)
+	} else {
+		_, err = h.orderService.UpdateStatusFromWebhook(c.Context(), order, internalStatus, payload.Event)
+	}
+	if err != nil {
+		var transitionErr *services.InvalidTransitionError
+		if errors.As(err, &transitionErr) || errors.Is(err, services.ErrConcurrentStatusChange) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"success": false,
+				"error":   err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   "Failed to update order status",
@@ -174,7 +205,7 @@ func (h *WebhookHandler) HandleDeliveryWebhook(c *fiber.Ctx) error {
 	}
 
 	// Notify via Redis pub/sub if notification service is available
-	if h.notificationService != nil {
+	if h.notificationService != nil && internalStatus != models.OrderStatusCancelled {
 		_ = h.notificationService.SendOrderUpdate(c.Context(), order.CourierID.String(), order.ID.String(), string(internalStatus))
 	}
 
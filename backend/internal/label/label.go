@@ -0,0 +1,116 @@
+package label
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnsupportedSize is returned for label sizes other than a6 and 4x6
+var ErrUnsupportedSize = errors.New("size must be a6 or 4x6")
+
+// ErrUnsupportedFormat is returned for label formats other than pdf and zpl
+var ErrUnsupportedFormat = errors.New("format must be pdf or zpl")
+
+// Size is a label stock size
+type Size string
+
+const (
+	SizeA6       Size = "a6"  // 105 x 148 mm
+	Size4x6      Size = "4x6" // 4 x 6 in thermal labels
+	DefaultSize       = Size4x6
+	pointsPerMM       = 72 / 25.4
+	zplDotsPerIn      = 203 // Standard 8 dots/mm thermal printers
+)
+
+// ParseSize parses a size name, defaulting to 4x6
+func ParseSize(value string) (Size, error) {
+	switch Size(strings.ToLower(strings.TrimSpace(value))) {
+	case "":
+		return DefaultSize, nil
+	case SizeA6:
+		return SizeA6, nil
+	case Size4x6:
+		return Size4x6, nil
+	}
+	return "", ErrUnsupportedSize
+}
+
+// points returns the page size in PDF points
+func (s Size) points() (float64, float64) {
+	if s == SizeA6 {
+		return 105 * pointsPerMM, 148 * pointsPerMM
+	}
+	return 4 * 72, 6 * 72
+}
+
+// dots returns the label size in printer dots at 203 dpi
+func (s Size) dots() (int, int) {
+	width, height := s.points()
+	return int(width / 72 * zplDotsPerIn), int(height / 72 * zplDotsPerIn)
+}
+
+// Format is the output format of a label batch
+type Format string
+
+const (
+	FormatPDF Format = "pdf"
+	FormatZPL Format = "zpl"
+)
+
+// ParseFormat parses a format name, defaulting to PDF
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(value))) {
+	case "", FormatPDF:
+		return FormatPDF, nil
+	case FormatZPL:
+		return FormatZPL, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	if f == FormatZPL {
+		return "application/zpl; charset=utf-8"
+	}
+	return "application/pdf"
+}
+
+// Party is the sender or recipient printed on a label
+type Party struct {
+	Name    string
+	Phone   string
+	Address string
+	Notes   string
+}
+
+// Label is the content of one shipping label
+type Label struct {
+	Carrier            string // Business name printed in the header
+	OrderNumber        string // Encoded in the barcode
+	TrackingURL        string // Encoded in the QR code
+	Stop               string // e.g. "Stop 2 of 3" on multi-stop orders
+	From               Party
+	To                 Party
+	PackageSize        string
+	PackageWeight      float64 // kg, 0 when unknown
+	PackageDescription string
+	Fragile            bool
+	SignatureRequired  bool
+	CashToCollect      string // Formatted cash-on-delivery amount, empty when nothing is collected
+}
+
+// flags returns the handling instructions to highlight on the label
+func (l *Label) flags() []string {
+	var flags []string
+	if l.Fragile {
+		flags = append(flags, "FRAGILE")
+	}
+	if l.SignatureRequired {
+		flags = append(flags, "SIGNATURE REQUIRED")
+	}
+	if l.CashToCollect != "" {
+		flags = append(flags, "COLLECT "+l.CashToCollect)
+	}
+	return flags
+}
@@ -0,0 +1,171 @@
+package label
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"nyengo-deliveries/internal/pdf"
+)
+
+const (
+	pdfMargin     = 12.0
+	pdfBarHeight  = 46.0
+	pdfMaxQRSize  = 100.0
+	pdfMaxModule  = 1.5 // Widest barcode module in points; narrower when the order number is long
+	pdfColumnGap  = 8.0
+	pdfFlagHeight = 14.0
+)
+
+// WritePDF writes the labels as a PDF with one page per label
+func WritePDF(w io.Writer, size Size, labels []Label) error {
+	doc := pdf.NewWriter(w)
+	width, height := size.points()
+	producer := ""
+	for i := range labels {
+		if err := drawPDFLabel(doc.AddPage(width, height), &labels[i]); err != nil {
+			return fmt.Errorf("label for %s: %w", labels[i].OrderNumber, err)
+		}
+		if err := doc.Err(); err != nil {
+			return err
+		}
+		producer = labels[i].Carrier
+	}
+	return doc.Close(pdf.Info{Title: "Shipping labels", Producer: producer})
+}
+
+func drawPDFLabel(p *pdf.Page, l *Label) error {
+	inner := p.Width - 2*pdfMargin
+	y := p.Height - pdfMargin
+
+	// Header: carrier and, on multi-stop orders, the stop
+	y -= 14
+	p.Text(pdf.HelveticaBold, 13, pdfMargin, y, pdf.FitText(l.Carrier, pdf.HelveticaBold, 13, inner*0.6))
+	if l.Stop != "" {
+		p.Text(pdf.HelveticaBold, 11, p.Width-pdfMargin-pdf.TextWidth(l.Stop, pdf.HelveticaBold, 11), y, l.Stop)
+	}
+	y -= 7
+	p.Line(pdfMargin, y, p.Width-pdfMargin, y, 1)
+
+	y = drawParty(p, "FROM", &l.From, y, inner, 9, 8, 2)
+	p.Line(pdfMargin, y, p.Width-pdfMargin, y, 0.5)
+	y = drawParty(p, "TO", &l.To, y, inner, 13, 11, 3)
+	p.Line(pdfMargin, y, p.Width-pdfMargin, y, 1)
+
+	// Barcode and the human-readable order number along the bottom
+	bars, err := Code128(l.OrderNumber)
+	if err != nil {
+		return err
+	}
+	modules := 0
+	for _, width := range bars {
+		modules += width
+	}
+	module := math.Min(inner/float64(modules), pdfMaxModule)
+	x := (p.Width - float64(modules)*module) / 2
+	barBottom := pdfMargin + 16
+	for i, width := range bars {
+		if i%2 == 0 {
+			p.FillRect(x, barBottom, float64(width)*module, pdfBarHeight)
+		}
+		x += float64(width) * module
+	}
+	p.Text(pdf.HelveticaBold, 11, (p.Width-pdf.TextWidth(l.OrderNumber, pdf.HelveticaBold, 11))/2, pdfMargin+2, l.OrderNumber)
+	bottom := barBottom + pdfBarHeight + 6
+	p.Line(pdfMargin, bottom, p.Width-pdfMargin, bottom, 1)
+
+	// Middle: package details on the left, tracking QR code on the right
+	top := y - 4
+	qrSize := math.Min(top-bottom-8, pdfMaxQRSize)
+	if l.TrackingURL != "" && qrSize > 0 {
+		qr, err := EncodeQR(l.TrackingURL)
+		if err != nil {
+			return err
+		}
+		drawQR(p, qr, p.Width-pdfMargin-qrSize, top-qrSize, qrSize)
+	} else {
+		qrSize = -pdfColumnGap
+	}
+	column := inner - qrSize - pdfColumnGap
+
+	y = top - 9
+	p.Text(pdf.HelveticaBold, 7, pdfMargin, y, "PACKAGE")
+	y -= 12
+	p.Text(pdf.HelveticaBold, 10, pdfMargin, y, pdf.FitText(packageSummary(l), pdf.HelveticaBold, 10, column))
+	for _, line := range pdf.WrapText(l.PackageDescription, pdf.Helvetica, 8, column, 2) {
+		y -= 9.5
+		p.Text(pdf.Helvetica, 8, pdfMargin, y, line)
+	}
+	for _, flag := range l.flags() {
+		if y-pdfFlagHeight-6 < bottom {
+			break
+		}
+		y -= pdfFlagHeight + 4
+		text := pdf.FitText(flag, pdf.HelveticaBold, 9, column-8)
+		p.StrokeRect(pdfMargin, y-4, pdf.TextWidth(text, pdf.HelveticaBold, 9)+8, pdfFlagHeight, 1.2)
+		p.Text(pdf.HelveticaBold, 9, pdfMargin+4, y, text)
+	}
+	return nil
+}
+
+// drawParty draws a sender or recipient block below y and returns the y of the separator under it
+func drawParty(p *pdf.Page, title string, party *Party, y, width, nameSize, textSize float64, addressLines int) float64 {
+	y -= 10
+	p.Text(pdf.HelveticaBold, 7, pdfMargin, y, title)
+	y -= nameSize + 1
+	p.Text(pdf.HelveticaBold, nameSize, pdfMargin, y, pdf.FitText(party.Name, pdf.HelveticaBold, nameSize, width))
+	for _, line := range pdf.WrapText(party.Address, pdf.Helvetica, textSize, width, addressLines) {
+		y -= textSize + 1.5
+		p.Text(pdf.Helvetica, textSize, pdfMargin, y, line)
+	}
+	if party.Phone != "" {
+		y -= textSize + 1.5
+		p.Text(pdf.Helvetica, textSize, pdfMargin, y, party.Phone)
+	}
+	for _, line := range pdf.WrapText(notesLine(party.Notes), pdf.Helvetica, 8, width, 2) {
+		y -= 10
+		p.Text(pdf.Helvetica, 8, pdfMargin, y, line)
+	}
+	return y - 6
+}
+
+// drawQR draws the symbol and its quiet zone in a square of the given size, one rectangle per dark run
+func drawQR(p *pdf.Page, qr *QRCode, x, y, size float64) {
+	module := size / float64(qr.Size+2*qrQuietZone)
+	for row := 0; row < qr.Size; row++ {
+		top := y + size - float64(row+qrQuietZone+1)*module
+		for col := 0; col < qr.Size; {
+			if !qr.Modules[row][col] {
+				col++
+				continue
+			}
+			start := col
+			for col < qr.Size && qr.Modules[row][col] {
+				col++
+			}
+			p.FillRect(x+float64(start+qrQuietZone)*module, top, float64(col-start)*module, module)
+		}
+	}
+}
+
+func packageSummary(l *Label) string {
+	parts := []string{}
+	if l.PackageSize != "" {
+		parts = append(parts, strings.ToUpper(l.PackageSize[:1])+l.PackageSize[1:])
+	}
+	if l.PackageWeight > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f kg", l.PackageWeight))
+	}
+	if len(parts) == 0 {
+		return "Parcel"
+	}
+	return strings.Join(parts, "  |  ")
+}
+
+func notesLine(notes string) string {
+	if notes = strings.TrimSpace(notes); notes == "" {
+		return ""
+	}
+	return "Notes: " + notes
+}
@@ -0,0 +1,169 @@
+package label
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeQRVersion(t *testing.T) {
+	// Byte mode capacities at error correction level M, from the QR specification
+	tests := []struct {
+		length int
+		size   int
+	}{
+		{1, 21},
+		{14, 21},
+		{15, 25},
+		{26, 25},
+		{27, 29},
+		{42, 29},
+		{43, 33},
+		{62, 33},
+		{84, 37},
+		{106, 41},
+		{122, 45},
+		{152, 49},
+		{180, 53},
+		{181, 57},
+		{213, 57},
+	}
+
+	for _, tt := range tests {
+		qr, err := EncodeQR(strings.Repeat("a", tt.length))
+		if err != nil {
+			t.Fatalf("EncodeQR(%d bytes) error = %v", tt.length, err)
+		}
+		if qr.Size != tt.size {
+			t.Errorf("EncodeQR(%d bytes) size = %d, want %d", tt.length, qr.Size, tt.size)
+		}
+		if len(qr.Modules) != qr.Size || len(qr.Modules[0]) != qr.Size {
+			t.Errorf("EncodeQR(%d bytes) modules are %dx%d, want %dx%d",
+				tt.length, len(qr.Modules[0]), len(qr.Modules), qr.Size, qr.Size)
+		}
+	}
+
+	if _, err := EncodeQR(strings.Repeat("a", 214)); !errors.Is(err, ErrQRTooLong) {
+		t.Errorf("EncodeQR(214 bytes) error = %v, want ErrQRTooLong", err)
+	}
+}
+
+func TestEncodeQRKnownSymbols(t *testing.T) {
+	// Reference symbols from an independent encoder (ZXing) in byte mode at level M
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{
+			text: "nyengo",
+			want: []string{
+				"#######.##..#.#######",
+				"#.....#.#####.#.....#",
+				"#.###.#.###.#.#.###.#",
+				"#.###.#..####.#.###.#",
+				"#.###.#.#...#.#.###.#",
+				"#.....#..####.#.....#",
+				"#######.#.#.#.#######",
+				".........####........",
+				"#..#######..##..#.###",
+				".##..#.#.#..#.#.#..#.",
+				"##...##..#...##.#.###",
+				".###...#.#.#......#.#",
+				".###..##.##...#..#...",
+				"........##.##....###.",
+				"#######.##..#######..",
+				"#.....#.#..###.#.##..",
+				"#.###.#.#..##.#.##.##",
+				"#.###.#.##.##........",
+				"#.###.#..#....#.##.##",
+				"#.....#...#..########",
+				"#######.#..#....#....",
+			},
+		},
+		{
+			text: "https://nyengo.com/t/NYG-1",
+			want: []string{
+				"#######.##.#..#...#######",
+				"#.....#..#.#..#.#.#.....#",
+				"#.###.#.####.#....#.###.#",
+				"#.###.#...#.##.#..#.###.#",
+				"#.###.#..#.##.#...#.###.#",
+				"#.....#.#...###.#.#.....#",
+				"#######.#.#.#.#.#.#######",
+				".........#.#..##.........",
+				"#.#...##...##..##..#..#.#",
+				"###....##.###.######.#.##",
+				"########.#..##.###.#.##.#",
+				"#..#...#..#.#..###.###...",
+				"##..####.####.#.#.##....#",
+				".#..##..###..#.#..##...##",
+				"##.##.#.#.##...#..#..##.#",
+				"..####...##...####.###...",
+				"##..#.#.#..#.#..#####..#.",
+				"........#.##.#..#...#...#",
+				"#######.#.##....#.#.#...#",
+				"#.....#..#..#..##...#...#",
+				"#.###.#..##.##.######..#.",
+				"#.###.#..#.#.#####..#.##.",
+				"#.###.#.##.#.##.##.###.##",
+				"#.....#..####.#.#####....",
+				"#######.######..##...#..#",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			qr, err := EncodeQR(tt.text)
+			if err != nil {
+				t.Fatalf("EncodeQR() error = %v", err)
+			}
+			if qr.Size != len(tt.want) {
+				t.Fatalf("EncodeQR() size = %d, want %d", qr.Size, len(tt.want))
+			}
+			for y, row := range qr.Modules {
+				var got strings.Builder
+				for _, dark := range row {
+					if dark {
+						got.WriteByte('#')
+					} else {
+						got.WriteByte('.')
+					}
+				}
+				if got.String() != tt.want[y] {
+					t.Errorf("row %d = %s, want %s", y, got.String(), tt.want[y])
+				}
+			}
+		})
+	}
+}
+
+func TestRSRemainder(t *testing.T) {
+	// Version 1-M "HELLO WORLD" codewords, the worked example of the QR specification
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{
+			name: "HELLO WORLD 1-M",
+			data: []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17},
+			want: []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+		},
+		{
+			name: "all zero",
+			data: make([]byte, 16),
+			want: make([]byte, 10),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rsRemainder(tt.data, rsDivisor(len(tt.want)))
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("rsRemainder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
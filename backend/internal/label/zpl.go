@@ -0,0 +1,117 @@
+package label
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const zplMargin = 30
+
+// WriteZPL writes the labels as ZPL II for 203 dpi thermal printers. Barcodes and QR codes are
+// drawn by the printer; text is sent as UTF-8.
+func WriteZPL(w io.Writer, size Size, labels []Label) error {
+	out := bufio.NewWriter(w)
+	width, height := size.dots()
+	inner := width - 2*zplMargin
+
+	for i := range labels {
+		l := &labels[i]
+		bars, err := Code128(l.OrderNumber)
+		if err != nil {
+			return fmt.Errorf("label for %s: %w", l.OrderNumber, err)
+		}
+
+		fmt.Fprintf(out, "^XA\n^CI28\n^PW%d\n^LL%d\n^LH0,0\n", width, height)
+
+		// Header
+		zplField(out, zplMargin, 30, 40, inner, 1, "L", l.Carrier)
+		if l.Stop != "" {
+			zplField(out, zplMargin, 34, 34, inner, 1, "R", l.Stop)
+		}
+		zplRule(out, 80, inner, 3)
+
+		// Sender and recipient
+		zplField(out, zplMargin, 95, 22, inner, 1, "L", "FROM")
+		zplField(out, zplMargin, 120, 28, inner, 1, "L", l.From.Name)
+		zplField(out, zplMargin, 152, 24, inner, 2, "L", l.From.Address)
+		zplField(out, zplMargin, 212, 24, inner, 1, "L", l.From.Phone)
+		zplRule(out, 245, inner, 2)
+
+		zplField(out, zplMargin, 260, 22, inner, 1, "L", "TO")
+		zplField(out, zplMargin, 288, 40, inner, 1, "L", l.To.Name)
+		zplField(out, zplMargin, 336, 34, inner, 3, "L", l.To.Address)
+		zplField(out, zplMargin, 462, 34, inner, 1, "L", l.To.Phone)
+		zplField(out, zplMargin, 505, 24, inner, 2, "L", notesLine(l.To.Notes))
+		zplRule(out, 570, inner, 3)
+
+		// Package details on the left, tracking QR code on the right
+		column := inner
+		if l.TrackingURL != "" {
+			qrWidth := 230
+			column = inner - qrWidth - 20
+			fmt.Fprintf(out, "^FO%d,580^BQN,2,6^FH^FDMA,%s^FS\n", width-zplMargin-qrWidth, zplEscape(l.TrackingURL))
+		}
+		zplField(out, zplMargin, 590, 22, column, 1, "L", "PACKAGE")
+		zplField(out, zplMargin, 618, 30, column, 1, "L", packageSummary(l))
+		zplField(out, zplMargin, 656, 24, column, 2, "L", l.PackageDescription)
+		y := 730
+		for _, flag := range l.flags() {
+			if y+44 > height-250 {
+				break
+			}
+			fmt.Fprintf(out, "^FO%d,%d^GB%d,44,3^FS\n", zplMargin, y, column)
+			zplField(out, zplMargin+10, y+10, 28, column-20, 1, "L", flag)
+			y += 56
+		}
+
+		// Barcode and the human-readable order number along the bottom
+		zplRule(out, height-250, inner, 3)
+		modules := 0
+		for _, width := range bars {
+			modules += width
+		}
+		moduleWidth := 3
+		if modules*moduleWidth > inner {
+			moduleWidth = 2
+		}
+		x := (width - modules*moduleWidth) / 2
+		if x < zplMargin {
+			x = zplMargin
+		}
+		fmt.Fprintf(out, "^FO%d,%d^BY%d^BCN,140,N,N,N^FH^FD%s^FS\n", x, height-225, moduleWidth, zplEscape(l.OrderNumber))
+		zplField(out, zplMargin, height-70, 36, inner, 1, "C", l.OrderNumber)
+
+		io.WriteString(out, "^XZ\n")
+	}
+	return out.Flush()
+}
+
+// zplField writes a text block of up to lines lines, wrapped to width dots and aligned L, C or R
+func zplField(out io.Writer, x, y, fontSize, width, lines int, align, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	fmt.Fprintf(out, "^FO%d,%d^A0N,%d,%d^FB%d,%d,4,%s^FH^FD%s^FS\n", x, y, fontSize, fontSize, width, lines, align, zplEscape(text))
+}
+
+func zplRule(out io.Writer, y, width, thickness int) {
+	fmt.Fprintf(out, "^FO%d,%d^GB%d,%d,%d^FS\n", zplMargin, y, width, thickness, thickness)
+}
+
+// zplEscape hex-escapes the characters ZPL treats as commands, for use after ^FH
+func zplEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '^' || r == '~' || r == '_' || r == '\\':
+			fmt.Fprintf(&b, "_%02X", r)
+		case r < 32:
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
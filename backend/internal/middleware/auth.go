@@ -8,9 +8,28 @@ import (
 	"github.com/google/uuid"
 
 	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
 )
 
+// JWTAuth authenticates courier accounts and sets courier_id. Driver tokens are rejected, so a
+// driver cannot act as the whole courier company.
 func JWTAuth(secret string) fiber.Handler {
+	return jwtAuth(secret, true, false)
+}
+
+// DriverAuth authenticates drivers signed in with their own credentials and sets driver_id and the
+// courier_id of their company
+func DriverAuth(secret string) fiber.Handler {
+	return jwtAuth(secret, false, true)
+}
+
+// CourierOrDriverAuth accepts a courier account or one of its drivers. driver_id is uuid.Nil for
+// courier accounts.
+func CourierOrDriverAuth(secret string) fiber.Handler {
+	return jwtAuth(secret, true, true)
+}
+
+func jwtAuth(secret string, allowCourier, allowDriver bool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -45,7 +64,38 @@ func JWTAuth(secret string) fiber.Handler {
 			})
 		}
 
+		driverID := uuid.Nil
+		if scope, _ := claims["scope"].(string); scope == models.DriverTokenScope {
+			driverIDStr, _ := claims["driver_id"].(string)
+			if driverID, err = uuid.Parse(driverIDStr); err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"success": false, "error": fiber.Map{"code": "UNAUTHORIZED", "message": "Invalid driver ID"},
+				})
+			}
+		}
+
+		if (driverID == uuid.Nil && !allowCourier) || (driverID != uuid.Nil && !allowDriver) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false, "error": fiber.Map{"code": "FORBIDDEN", "message": "This token cannot access this endpoint"},
+			})
+		}
+
 		c.Locals("courier_id", courierID)
+		c.Locals("driver_id", driverID)
+		return c.Next()
+	}
+}
+
+// AdminOnly restricts a route to the courier accounts configured in ADMIN_COURIER_IDS. It must run
+// after JWTAuth, which sets courier_id and rejects driver tokens.
+func AdminOnly(cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		courierID, ok := c.Locals("courier_id").(uuid.UUID)
+		if !ok || !cfg.IsAdminCourier(courierID.String()) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false, "error": fiber.Map{"code": "FORBIDDEN", "message": "Admin access required"},
+			})
+		}
 		return c.Next()
 	}
 }
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/services"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client's idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// Idempotency replays the stored response when a mutating request is retried with the same
+// Idempotency-Key header. Keys are scoped to the caller, so it must run after APIKeyAuth or JWTAuth.
+// Requests without the header are not affected.
+func Idempotency(svc *services.IdempotencyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutating(c.Method()) {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false, "error": fiber.Map{"code": "BAD_REQUEST", "message": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)},
+			})
+		}
+
+		scope := idempotencyScope(c)
+		if scope == "" {
+			return c.Next()
+		}
+
+		content, err := fingerprintBody(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false, "error": fiber.Map{"code": "BAD_REQUEST", "message": "Invalid multipart body"},
+			})
+		}
+
+		ctx := c.Context()
+		fingerprint := svc.Fingerprint(c.Method(), c.OriginalURL(), content)
+		record, err := svc.Begin(ctx, scope, key, fingerprint)
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"success": false, "error": fiber.Map{"code": "IDEMPOTENCY_KEY_REUSED", "message": err.Error()},
+			})
+		case errors.Is(err, services.ErrIdempotencyKeyInProgress):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"success": false, "error": fiber.Map{"code": "IDEMPOTENCY_KEY_IN_USE", "message": err.Error()},
+			})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false, "error": fiber.Map{"code": "SERVER_ERROR", "message": "Failed to check idempotency key"},
+			})
+		}
+
+		// Completed before: replay the original response
+		if record != nil {
+			c.Set("Idempotent-Replayed", "true")
+			if record.ContentType != "" {
+				c.Set(fiber.HeaderContentType, record.ContentType)
+			}
+			return c.Status(record.ResponseStatus).Send(record.ResponseBody)
+		}
+
+		if err := c.Next(); err != nil {
+			svc.Release(ctx, scope, key)
+			return err
+		}
+
+		// Server errors are not stored so the client can retry them
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			svc.Release(ctx, scope, key)
+			return nil
+		}
+
+		body := append([]byte(nil), c.Response().Body()...)
+		svc.Complete(ctx, scope, key, status, body, string(c.Response().Header.ContentType()))
+		return nil
+	}
+}
+
+// fingerprintBody returns the part of the body a request's fingerprint covers. Clients pick a new
+// random boundary for every multipart body, so form uploads are identified by their fields and the
+// SHA-256 of their files instead; other multipart bodies are left out.
+func fingerprintBody(c *fiber.Ctx) ([]byte, error) {
+	contentType := strings.ToLower(string(c.Request().Header.ContentType()))
+	if !strings.HasPrefix(contentType, "multipart/") {
+		return c.Body(), nil
+	}
+	if !strings.HasPrefix(contentType, fiber.MIMEMultipartForm) {
+		return nil, nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, name := range sortedKeys(form.Value) {
+		for _, value := range form.Value[name] {
+			fmt.Fprintf(&b, "field %q %q\n", name, value)
+		}
+	}
+	for _, name := range sortedKeys(form.File) {
+		for _, file := range form.File[name] {
+			digest, err := fileDigest(file)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, "file %q %q %s\n", name, file.Filename, digest)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func fileDigest(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idempotencyScope identifies the authenticated caller so keys from different stores or couriers never collide
+func idempotencyScope(c *fiber.Ctx) string {
+	if courierID, ok := c.Locals("courier_id").(uuid.UUID); ok {
+		return "courier:" + courierID.String()
+	}
+	if apiKey := c.Get("X-API-Key"); apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		return "store:" + hex.EncodeToString(sum[:])
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestFingerprintBody(t *testing.T) {
+	multipartBody := func(boundary, note string, photo []byte) (string, []byte) {
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		if err := w.SetBoundary(boundary); err != nil {
+			t.Fatalf("SetBoundary() error = %v", err)
+		}
+		_ = w.WriteField("note", note)
+		part, _ := w.CreateFormFile("photo", "door.jpg")
+		_, _ = part.Write(photo)
+		_ = w.Close()
+		return w.FormDataContentType(), b.Bytes()
+	}
+
+	fingerprint := func(contentType string, body []byte) string {
+		var got []byte
+		app := fiber.New()
+		app.Post("/", func(c *fiber.Ctx) error {
+			content, err := fingerprintBody(c)
+			if err != nil {
+				t.Errorf("fingerprintBody() error = %v", err)
+			}
+			got = content
+			return nil
+		})
+		req := httptest.NewRequest(fiber.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, contentType)
+		if _, err := app.Test(req); err != nil {
+			t.Fatalf("app.Test() error = %v", err)
+		}
+		sum := sha256.Sum256(got)
+		return hex.EncodeToString(sum[:])
+	}
+
+	photo := []byte("\xff\xd8\xff jpeg bytes")
+	first := fingerprint(multipartBody("boundary-first-attempt", "Left at the door", photo))
+	retry := fingerprint(multipartBody("boundary-retried-later", "Left at the door", photo))
+	if first != retry {
+		t.Errorf("retry with a new boundary fingerprints differently")
+	}
+	if other := fingerprint(multipartBody("boundary-retried-later", "Left with a neighbour", photo)); other == first {
+		t.Errorf("a different field fingerprints the same")
+	}
+	if other := fingerprint(multipartBody("boundary-retried-later", "Left at the door", []byte("another photo"))); other == first {
+		t.Errorf("a different file fingerprints the same")
+	}
+
+	body := fingerprint(fiber.MIMEApplicationJSON, []byte(`{"reason":"customer_absent"}`))
+	if other := fingerprint(fiber.MIMEApplicationJSON, []byte(`{"reason":"refused"}`)); other == body {
+		t.Errorf("different JSON bodies fingerprint the same")
+	}
+}
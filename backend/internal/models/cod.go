@@ -0,0 +1,131 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CODStatus tracks the goods payment a driver collects from the customer on delivery
+type CODStatus string
+
+const (
+	CODStatusPending   CODStatus = "pending"   // To be collected at handoff
+	CODStatusCollected CODStatus = "collected" // Cash is with the courier
+	CODStatusRemitting CODStatus = "remitting" // Included in a remittance the store has not confirmed yet
+	CODStatusRemitted  CODStatus = "remitted"  // The store confirmed receiving the cash
+	CODStatusVoid      CODStatus = "void"      // The order was not delivered, nothing is owed
+)
+
+// RemittanceStatus represents the state of a cash hand-over to a store
+type RemittanceStatus string
+
+const (
+	RemittanceStatusPending   RemittanceStatus = "pending"
+	RemittanceStatusConfirmed RemittanceStatus = "confirmed"
+	RemittanceStatusRejected  RemittanceStatus = "rejected"
+)
+
+// RemittanceMethod is how the courier hands the collected cash to the store
+type RemittanceMethod string
+
+const (
+	RemittanceMethodCash        RemittanceMethod = "cash"
+	RemittanceMethodMobileMoney RemittanceMethod = "mobile_money"
+	RemittanceMethodBankDeposit RemittanceMethod = "bank_deposit"
+)
+
+// CODRemittance is a courier handing the cash collected on a store's orders over to that store
+type CODRemittance struct {
+	ID           uuid.UUID        `json:"id" db:"id"`
+	CourierID    uuid.UUID        `json:"courierId" db:"courier_id"`
+	StoreID      uuid.UUID        `json:"storeId" db:"store_id"`
+	Amount       float64          `json:"amount" db:"amount"`
+	OrderCount   int              `json:"orderCount" db:"order_count"`
+	OrderIDs     []uuid.UUID      `json:"orderIds,omitempty"`
+	Method       RemittanceMethod `json:"method" db:"method"`
+	Reference    string           `json:"reference,omitempty" db:"reference"` // Mobile money or deposit reference
+	Note         string           `json:"note,omitempty" db:"note"`
+	Status       RemittanceStatus `json:"status" db:"status"`
+	DecisionNote string           `json:"decisionNote,omitempty" db:"decision_note"`
+	DecidedAt    *time.Time       `json:"decidedAt,omitempty" db:"decided_at"`
+	CreatedAt    time.Time        `json:"createdAt" db:"created_at"`
+}
+
+// CollectCODRequest is sent by the driver to confirm the cash collected at handoff
+type CollectCODRequest struct {
+	Amount float64 `json:"amount"`
+	Note   string  `json:"note,omitempty"` // Required when the amount differs from the order's codAmount
+}
+
+// CreateRemittanceRequest hands all collected cash for a store's delivered orders over to the store
+type CreateRemittanceRequest struct {
+	StoreID   string           `json:"storeId" validate:"required"`
+	Method    RemittanceMethod `json:"method" validate:"required"`
+	Reference string           `json:"reference,omitempty"`
+	Note      string           `json:"note,omitempty"`
+}
+
+// RemittanceDecisionRequest is the store's confirmation or rejection of a remittance
+type RemittanceDecisionRequest struct {
+	Note string `json:"note,omitempty"` // Required when rejecting
+}
+
+// CODStoreBalance is the cash a courier holds for one store
+type CODStoreBalance struct {
+	StoreID uuid.UUID `json:"storeId"`
+	Orders  int       `json:"orders"`
+	Amount  float64   `json:"amount"`
+}
+
+// CODSummary is a courier's cash liability: collected cash not yet confirmed by the stores
+type CODSummary struct {
+	CourierID          uuid.UUID         `json:"courierId"`
+	CashLiability      float64           `json:"cashLiability"`
+	Outstanding        []CODStoreBalance `json:"outstanding"` // Collected and not yet in a remittance
+	PendingRemittances []CODRemittance   `json:"pendingRemittances"`
+	Currency           string            `json:"currency"`
+	FormattedLiability string            `json:"formattedLiability"`
+}
+
+// CODSettlementLine is one cash-on-delivery order in a store settlement report
+type CODSettlementLine struct {
+	OrderID         uuid.UUID   `json:"orderId"`
+	OrderNumber     string      `json:"orderNumber"`
+	ExternalOrderID string      `json:"externalOrderId,omitempty"`
+	CourierID       uuid.UUID   `json:"courierId"`
+	OrderStatus     OrderStatus `json:"orderStatus"`
+	CODStatus       CODStatus   `json:"codStatus"`
+	Expected        float64     `json:"expected"`
+	Collected       float64     `json:"collected"`
+	CollectedAt     *time.Time  `json:"collectedAt,omitempty"`
+	RemittanceID    *uuid.UUID  `json:"remittanceId,omitempty"`
+	CreatedAt       time.Time   `json:"createdAt"`
+}
+
+// CODCourierSettlement totals one courier's cash for a store
+type CODCourierSettlement struct {
+	CourierID   uuid.UUID `json:"courierId"`
+	Orders      int       `json:"orders"`
+	Collected   float64   `json:"collected"`
+	Remitted    float64   `json:"remitted"`
+	Outstanding float64   `json:"outstanding"` // Collected, not yet confirmed by the store
+}
+
+// CODSettlementReport reconciles the cash collected on a store's orders with what has been remitted
+type CODSettlementReport struct {
+	StoreID     uuid.UUID              `json:"storeId"`
+	From        time.Time              `json:"from"`
+	To          time.Time              `json:"to"`
+	Orders      int                    `json:"orders"`
+	Expected    float64                `json:"expected"`    // COD amounts of delivered orders
+	Collected   float64                `json:"collected"`   // Cash the drivers confirmed collecting
+	Shortfall   float64                `json:"shortfall"`   // Expected minus collected
+	Remitting   float64                `json:"remitting"`   // In remittances awaiting confirmation
+	Remitted    float64                `json:"remitted"`    // Confirmed by the store
+	Outstanding float64                `json:"outstanding"` // Still held by couriers
+	Pending     float64                `json:"pending"`     // Not collected yet (orders still out for delivery)
+	Currency    string                 `json:"currency"`
+	Couriers    []CODCourierSettlement `json:"couriers"`
+	Lines       []CODSettlementLine    `json:"lines"`
+}
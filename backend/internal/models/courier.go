@@ -27,6 +27,7 @@ type Courier struct {
 	VehicleTypes   []string       `json:"vehicleTypes" db:"vehicle_types"`
 	MaxWeight      float64        `json:"maxWeight" db:"max_weight"` // in kg
 	OperatingHours OperatingHours `json:"operatingHours" db:"operating_hours"`
+	Timezone       string         `json:"timezone,omitempty" db:"timezone"` // IANA name, defaults to the platform timezone
 
 	// Pricing configuration (overrides system defaults)
 	BaseRatePerKm float64 `json:"baseRatePerKm" db:"base_rate_per_km"`
@@ -55,7 +56,9 @@ type Courier struct {
 	LastActiveAt *time.Time `json:"lastActiveAt,omitempty" db:"last_active_at"`
 }
 
-// OperatingHours defines business hours
+// OperatingHours defines business hours. Hours are read in the courier's timezone; a close time
+// before the open time runs past midnight, and equal times mean open all day. When no day is set
+// the courier is open around the clock; otherwise days left blank are closed.
 type OperatingHours struct {
 	Monday    DayHours `json:"monday"`
 	Tuesday   DayHours `json:"tuesday"`
@@ -82,6 +85,14 @@ type BankDetails struct {
 	SwiftCode     string `json:"swiftCode,omitempty"`
 }
 
+// CourierStats are the aggregates derived from a courier's orders and ratings
+type CourierStats struct {
+	TotalDeliveries int     `json:"totalDeliveries"`
+	TotalReviews    int     `json:"totalReviews"`
+	Rating          float64 `json:"rating"`
+	SuccessRate     float64 `json:"successRate"` // Percentage of finished orders that were delivered
+}
+
 // CourierListItem is a simplified courier for lists
 type CourierListItem struct {
 	ID              uuid.UUID `json:"id"`
@@ -94,6 +105,9 @@ type CourierListItem struct {
 	MinimumFare     float64   `json:"minimumFare"`
 	IsVerified      bool      `json:"isVerified"`
 	IsFeatured      bool      `json:"isFeatured"`
+
+	// Set on store courier lists
+	OpeningStatus *OpeningStatus `json:"openingStatus,omitempty"`
 }
 
 // CourierRegistrationRequest is the request body for registering a new courier
@@ -137,6 +151,7 @@ type CourierUpdateRequest struct {
 	VehicleTypes   []string        `json:"vehicleTypes,omitempty"`
 	MaxWeight      *float64        `json:"maxWeight,omitempty"`
 	OperatingHours *OperatingHours `json:"operatingHours,omitempty"`
+	Timezone       *string         `json:"timezone,omitempty"`
 	BaseRatePerKm  *float64        `json:"baseRatePerKm,omitempty"`
 	MinimumFare    *float64        `json:"minimumFare,omitempty"`
 	BankDetails    *BankDetails    `json:"bankDetails,omitempty"`
@@ -36,6 +36,16 @@ type DeliveryTracking struct {
 	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
 }
 
+// OrderTrackingSummary is the latest tracking state of an order, as returned by order queries
+type OrderTrackingSummary struct {
+	IsActive          bool       `json:"isActive"`
+	Latitude          *float64   `json:"latitude,omitempty"`
+	Longitude         *float64   `json:"longitude,omitempty"`
+	LastLocationAt    *time.Time `json:"lastLocationAt,omitempty"`
+	EstimatedArrival  *time.Time `json:"estimatedArrival,omitempty"`
+	DistanceRemaining *float64   `json:"distanceRemaining,omitempty"` // km
+}
+
 // LocationPoint represents a GPS coordinate with timestamp
 type LocationPoint struct {
 	Latitude  float64   `json:"latitude"`
@@ -75,5 +85,7 @@ type DriverAssignment struct {
 	VehiclePlate string     `json:"vehiclePlate,omitempty" db:"vehicle_plate"`
 	AssignedAt   time.Time  `json:"assignedAt" db:"assigned_at"`
 	AcceptedAt   *time.Time `json:"acceptedAt,omitempty" db:"accepted_at"`
-	Status       string     `json:"status" db:"status"` // pending, accepted, rejected
+	ReleasedAt   *time.Time `json:"releasedAt,omitempty" db:"released_at"`
+	Status       string     `json:"status" db:"status"`       // pending, accepted, rejected, released
+	Note         string     `json:"note,omitempty" db:"note"` // The driver's reason for rejecting
 }
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AttemptReason is the reason code a driver records for a failed delivery attempt
+type AttemptReason string
+
+const (
+	AttemptReasonCustomerAbsent      AttemptReason = "customer_absent"
+	AttemptReasonWrongAddress        AttemptReason = "wrong_address"
+	AttemptReasonRefused             AttemptReason = "refused"
+	AttemptReasonCustomerUnreachable AttemptReason = "customer_unreachable"
+	AttemptReasonOther               AttemptReason = "other"
+)
+
+// IsValid reports whether r is one of the known reason codes
+func (r AttemptReason) IsValid() bool {
+	switch r {
+	case AttemptReasonCustomerAbsent, AttemptReasonWrongAddress, AttemptReasonRefused,
+		AttemptReasonCustomerUnreachable, AttemptReasonOther:
+		return true
+	}
+	return false
+}
+
+// AllowsReattempt reports whether another attempt can succeed after a failure for this reason.
+// A refused parcel goes straight back to the sender.
+func (r AttemptReason) AllowsReattempt() bool {
+	return r != AttemptReasonRefused
+}
+
+// AttemptOutcome is what happened to the order after a failed attempt
+type AttemptOutcome string
+
+const (
+	AttemptOutcomeReattempt AttemptOutcome = "reattempt_scheduled"
+	AttemptOutcomeReturn    AttemptOutcome = "return_to_sender"
+	AttemptOutcomeFailed    AttemptOutcome = "failed" // A return leg that could not be delivered either
+)
+
+// DeliveryAttempt is one failed attempt to hand a parcel over
+type DeliveryAttempt struct {
+	ID            uuid.UUID      `json:"id" db:"id"`
+	OrderID       uuid.UUID      `json:"orderId" db:"order_id"`
+	CourierID     uuid.UUID      `json:"courierId" db:"courier_id"`
+	AttemptNumber int            `json:"attemptNumber" db:"attempt_number"` // 1-based
+	Reason        AttemptReason  `json:"reason" db:"reason"`
+	Note          string         `json:"note,omitempty" db:"note"`
+	Outcome       AttemptOutcome `json:"outcome" db:"outcome"`
+	NextAttemptAt *time.Time     `json:"nextAttemptAt,omitempty" db:"next_attempt_at"` // Set when a re-attempt was scheduled
+	ReturnOrderID *uuid.UUID     `json:"returnOrderId,omitempty" db:"return_order_id"` // Set when a return leg was created
+	CreatedAt     time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// RecordAttemptRequest is sent by the driver when a delivery attempt fails
+type RecordAttemptRequest struct {
+	Reason AttemptReason `json:"reason" validate:"required"`
+	Note   string        `json:"note,omitempty"` // Required for reason "other"
+}
+
+// FailedAttemptResult is the outcome of recording a failed attempt
+type FailedAttemptResult struct {
+	Attempt           DeliveryAttempt `json:"attempt"`
+	Order             *Order          `json:"order"`
+	AttemptsRemaining int             `json:"attemptsRemaining"`
+	ReturnOrder       *Order          `json:"returnOrder,omitempty"` // The return-to-sender leg, once attempts are exhausted
+}
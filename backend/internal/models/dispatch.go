@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DispatchMode is how an order found its courier
+type DispatchMode string
+
+const (
+	DispatchModeManual    DispatchMode = "manual"    // The store picked the courier
+	DispatchModeAuto      DispatchMode = "auto"      // Offered to the best-scoring courier, then the next, until one accepts
+	DispatchModeBroadcast DispatchMode = "broadcast" // Offered to every eligible courier at once; the first to accept wins
+)
+
+// DispatchStatus tracks an automatically dispatched order's search for a courier
+type DispatchStatus string
+
+const (
+	DispatchStatusOffered   DispatchStatus = "offered"   // Waiting for the current courier (or, for broadcasts, any courier) to accept
+	DispatchStatusAssigned  DispatchStatus = "assigned"  // A courier accepted
+	DispatchStatusExhausted DispatchStatus = "exhausted" // No courier left to offer the order to; broadcasts are handed to the store
+)
+
+// DispatchDecision is what the dispatcher did with a courier for an order
+type DispatchDecision string
+
+const (
+	DispatchDecisionOffered   DispatchDecision = "offered"   // Sent the offer: the best candidate, or every eligible courier of a broadcast
+	DispatchDecisionRanked    DispatchDecision = "ranked"    // Eligible, but scored below the courier that was offered the order
+	DispatchDecisionSkipped   DispatchDecision = "skipped"   // Not eligible; Reason says why
+	DispatchDecisionDeclined  DispatchDecision = "declined"  // Declined the offer
+	DispatchDecisionExpired   DispatchDecision = "expired"   // Did not answer before the offer timed out
+	DispatchDecisionAccepted  DispatchDecision = "accepted"  // Accepted the offer
+	DispatchDecisionNoMatch   DispatchDecision = "no_match"  // Nobody was left to offer the order to
+	DispatchDecisionWithdrawn DispatchDecision = "withdrawn" // A broadcast offer ended: someone else got the order, or the broadcast closed
+	DispatchDecisionEscalated DispatchDecision = "escalated" // Nobody accepted a broadcast in time; widened or handed to the store
+)
+
+// DispatchLogEntry is one line of an order's dispatch audit log
+type DispatchLogEntry struct {
+	ID         uuid.UUID          `json:"id" db:"id"`
+	OrderID    uuid.UUID          `json:"orderId" db:"order_id"`
+	Round      int                `json:"round" db:"round"` // 1 for the first offer, +1 for each re-offer
+	CourierID  *uuid.UUID         `json:"courierId,omitempty" db:"courier_id"`
+	Decision   DispatchDecision   `json:"decision" db:"decision"`
+	Score      *float64           `json:"score,omitempty" db:"score"`            // 0-100, for eligible couriers
+	DistanceKm *float64           `json:"distanceKm,omitempty" db:"distance_km"` // From the courier's live position to the pickup
+	Reason     string             `json:"reason,omitempty" db:"reason"`          // Why the courier was skipped, or what happened
+	Factors    map[string]float64 `json:"factors,omitempty" db:"factors"`        // Score components, each 0-1
+	CreatedAt  time.Time          `json:"createdAt" db:"created_at"`
+}
+
+// DispatchLog is an automatically dispatched order's current state and audit log
+type DispatchLog struct {
+	OrderID        uuid.UUID          `json:"orderId"`
+	OrderNumber    string             `json:"orderNumber"`
+	Status         OrderStatus        `json:"status"`
+	DispatchMode   DispatchMode       `json:"dispatchMode"`
+	DispatchStatus DispatchStatus     `json:"dispatchStatus,omitempty"`
+	CourierID      uuid.UUID          `json:"courierId"`
+	OfferExpiresAt *time.Time         `json:"offerExpiresAt,omitempty"`
+	Entries        []DispatchLogEntry `json:"entries"`
+}
+
+// AssignCourierRequest is the store's choice of courier for an order nobody accepted
+type AssignCourierRequest struct {
+	CourierID uuid.UUID `json:"courierId"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DriverTokenScope marks JWTs issued to drivers, so they cannot be used as the courier company's token
+const DriverTokenScope = "driver"
+
+// DriverStatus represents whether a driver can sign in and take orders
+type DriverStatus string
+
+const (
+	DriverStatusInvited   DriverStatus = "invited"   // Registered; waiting for the driver to set a password
+	DriverStatusActive    DriverStatus = "active"    // Can sign in and be assigned orders
+	DriverStatusSuspended DriverStatus = "suspended" // Blocked by the courier company
+)
+
+// Driver assignment statuses
+const (
+	DriverAssignmentPending  = "pending"
+	DriverAssignmentAccepted = "accepted"
+	DriverAssignmentRejected = "rejected"
+	DriverAssignmentReleased = "released" // The courier reassigned the order or it was transferred to another courier
+)
+
+// Driver is a person who drives for a courier company
+type Driver struct {
+	ID              uuid.UUID    `json:"id" db:"id"`
+	CourierID       uuid.UUID    `json:"courierId" db:"courier_id"`
+	Name            string       `json:"name" db:"name"`
+	Phone           string       `json:"phone" db:"phone"`
+	Email           string       `json:"email,omitempty" db:"email"`
+	Password        string       `json:"-" db:"password"`
+	LicenseNumber   string       `json:"licenseNumber,omitempty" db:"license_number"`
+	LicenseExpiry   *time.Time   `json:"licenseExpiry,omitempty" db:"license_expiry"`
+	PhotoURL        string       `json:"photoUrl,omitempty" db:"photo_url"`
+	VehicleType     string       `json:"vehicleType,omitempty" db:"vehicle_type"`
+	VehiclePlate    string       `json:"vehiclePlate,omitempty" db:"vehicle_plate"`
+	Status          DriverStatus `json:"status" db:"status"`
+	InviteCodeHash  string       `json:"-" db:"invite_code_hash"`
+	InviteExpiresAt *time.Time   `json:"inviteExpiresAt,omitempty" db:"invite_expires_at"`
+	LastLoginAt     *time.Time   `json:"lastLoginAt,omitempty" db:"last_login_at"`
+	CreatedAt       time.Time    `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time    `json:"updatedAt" db:"updated_at"`
+}
+
+// DriverRequest is the body for registering a driver
+type DriverRequest struct {
+	Name          string `json:"name"`
+	Phone         string `json:"phone"`
+	Email         string `json:"email,omitempty"`
+	LicenseNumber string `json:"licenseNumber,omitempty"`
+	LicenseExpiry string `json:"licenseExpiry,omitempty"` // YYYY-MM-DD
+	PhotoURL      string `json:"photoUrl,omitempty"`
+	VehicleType   string `json:"vehicleType,omitempty"`
+	VehiclePlate  string `json:"vehiclePlate,omitempty"`
+}
+
+// DriverUpdateRequest is the body for updating a driver; only the fields present are changed
+type DriverUpdateRequest struct {
+	Name          *string       `json:"name,omitempty"`
+	Phone         *string       `json:"phone,omitempty"`
+	Email         *string       `json:"email,omitempty"`
+	LicenseNumber *string       `json:"licenseNumber,omitempty"`
+	LicenseExpiry *string       `json:"licenseExpiry,omitempty"` // YYYY-MM-DD, or empty to clear
+	PhotoURL      *string       `json:"photoUrl,omitempty"`
+	VehicleType   *string       `json:"vehicleType,omitempty"`
+	VehiclePlate  *string       `json:"vehiclePlate,omitempty"`
+	Status        *DriverStatus `json:"status,omitempty"` // active or suspended
+}
+
+// DriverInvite is returned when a driver is registered or re-invited. The code is only shown once.
+type DriverInvite struct {
+	Driver     *Driver   `json:"driver"`
+	InviteCode string    `json:"inviteCode"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// DriverActivateRequest is the body for a driver accepting an invite and choosing a password
+type DriverActivateRequest struct {
+	Phone      string `json:"phone"`
+	InviteCode string `json:"inviteCode"`
+	Password   string `json:"password"`
+}
+
+// DriverLoginRequest is the body for a driver signing in
+type DriverLoginRequest struct {
+	Phone    string `json:"phone"`
+	Password string `json:"password"`
+}
+
+// DriverLoginResponse contains a driver's token
+type DriverLoginResponse struct {
+	Token  string  `json:"token"`
+	Driver *Driver `json:"driver"`
+}
+
+// AssignDriverRequest is the body for assigning an order to a driver
+type AssignDriverRequest struct {
+	DriverID uuid.UUID `json:"driverId"`
+}
+
+// DriverAssignmentResponseRequest is the optional body for a driver rejecting an assignment
+type DriverAssignmentResponseRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// DriverEarnings sums the courier earnings of the orders a driver delivered
+type DriverEarnings struct {
+	DriverID   uuid.UUID  `json:"driverId"`
+	From       *time.Time `json:"from,omitempty"`
+	To         *time.Time `json:"to,omitempty"`
+	Deliveries int        `json:"deliveries"`
+	Earnings   float64    `json:"earnings"`
+	Currency   string     `json:"currency"`
+}
+
+// DriverPerformance contains a driver's delivery metrics over the orders assigned to them
+type DriverPerformance struct {
+	DriverID uuid.UUID `json:"driverId"`
+	DeliveryMetrics
+	SuccessRate         float64 `json:"successRate"` // Percentage of finished orders that were delivered
+	RejectedAssignments int     `json:"rejectedAssignments"`
+	TotalReviews        int     `json:"totalReviews"`
+}
@@ -47,6 +47,9 @@ type CourierOption struct {
 	EstimatedTime         string `json:"estimatedTime,omitempty"`         // e.g., "30-45 mins" for local
 	EstimatedDeliveryDays string `json:"estimatedDeliveryDays,omitempty"` // e.g., "2-3 days" for external
 
+	// Operating hours at the pickup time (for local couriers)
+	OpeningStatus *OpeningStatus `json:"openingStatus,omitempty"`
+
 	// External courier specific
 	ServiceType string `json:"serviceType,omitempty"` // "express", "standard", "economy"
 	TrackingURL string `json:"trackingUrl,omitempty"`
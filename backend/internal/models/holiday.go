@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Holiday is a day a courier does not keep its weekly operating hours: platform holidays close every
+// courier (in one country, or everywhere), and a courier's own entries close it or set special hours.
+// A courier's entry overrides a platform holiday on the same day.
+type Holiday struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CourierID *uuid.UUID `json:"courierId,omitempty" db:"courier_id"` // nil for platform holidays
+	Country   string     `json:"country,omitempty" db:"country"`      // platform holidays only; empty for every country
+	Date      time.Time  `json:"date" db:"date"`
+	Name      string     `json:"name" db:"name"`
+	Open      string     `json:"open,omitempty" db:"open_time"`   // "10:00"; empty when closed all day
+	Close     string     `json:"close,omitempty" db:"close_time"` // "14:00"
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// HolidayRequest is the body for adding a holiday
+type HolidayRequest struct {
+	Date    string `json:"date"` // YYYY-MM-DD
+	Name    string `json:"name"`
+	Country string `json:"country,omitempty"` // platform holidays only
+	Open    string `json:"open,omitempty"`    // special hours for the day instead of closing it
+	Close   string `json:"close,omitempty"`
+}
+
+// OpeningStatus says whether a courier takes pickups at a time and, when closed, when it next does
+type OpeningStatus struct {
+	At                  time.Time  `json:"at"`
+	Timezone            string     `json:"timezone"`
+	IsOpen              bool       `json:"isOpen"`
+	ClosesAt            *time.Time `json:"closesAt,omitempty"`            // nil when open around the clock
+	Holiday             string     `json:"holiday,omitempty"`             // holiday the courier is closed or on special hours for
+	NextAvailablePickup *time.Time `json:"nextAvailablePickup,omitempty"` // At when open; nil when closed for the lookahead
+}
+
+// CourierHours is a courier's weekly operating hours with its upcoming holidays
+type CourierHours struct {
+	CourierID      uuid.UUID      `json:"courierId"`
+	Timezone       string         `json:"timezone"`
+	OperatingHours OperatingHours `json:"operatingHours"`
+	Status         *OpeningStatus `json:"status"`
+	Holidays       []Holiday      `json:"holidays"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// IdempotencyStatus represents the state of an idempotency key
+type IdempotencyStatus string
+
+const (
+	IdempotencyStatusProcessing IdempotencyStatus = "processing" // The first request is still running
+	IdempotencyStatusCompleted  IdempotencyStatus = "completed"  // The response is stored and replayed on retries
+)
+
+// IdempotencyRecord is the stored outcome of a request sent with an Idempotency-Key header
+type IdempotencyRecord struct {
+	Scope          string            `db:"scope"` // Caller the key belongs to (store API key hash or courier)
+	Key            string            `db:"idempotency_key"`
+	Fingerprint    string            `db:"fingerprint"` // SHA-256 of method, path and body
+	Status         IdempotencyStatus `db:"status"`
+	ResponseStatus int               `db:"response_status"`
+	ResponseBody   []byte            `db:"response_body"`
+	ContentType    string            `db:"content_type"`
+	LockedAt       time.Time         `db:"locked_at"`
+	CreatedAt      time.Time         `db:"created_at"`
+	ExpiresAt      time.Time         `db:"expires_at"`
+}
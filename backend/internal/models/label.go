@@ -0,0 +1,8 @@
+package models
+
+// LabelBatchRequest is the DTO for printing the labels of several orders at once
+type LabelBatchRequest struct {
+	OrderIDs []string `json:"orderIds"`
+	Format   string   `json:"format,omitempty"` // pdf (default) or zpl
+	Size     string   `json:"size,omitempty"`   // 4x6 (default) or a6
+}
@@ -10,6 +10,7 @@ import (
 type OrderStatus string
 
 const (
+	OrderStatusScheduled OrderStatus = "scheduled" // Dormant until shortly before the scheduled pickup
 	OrderStatusPending   OrderStatus = "pending"
 	OrderStatusAccepted  OrderStatus = "accepted"
 	OrderStatusDeclined  OrderStatus = "declined"
@@ -18,6 +19,11 @@ const (
 	OrderStatusDelivered OrderStatus = "delivered"
 	OrderStatusCancelled OrderStatus = "cancelled"
 	OrderStatusFailed    OrderStatus = "failed"
+
+	// Failed delivery attempts
+	OrderStatusReattemptScheduled OrderStatus = "reattempt_scheduled" // Attempt failed, the driver will try again
+	OrderStatusReturning          OrderStatus = "returning"           // Attempts exhausted, a return leg is taking the parcel back
+	OrderStatusReturned           OrderStatus = "returned"            // The return leg was delivered to the sender
 )
 
 // PaymentStatus represents the payment state
@@ -47,6 +53,7 @@ type Order struct {
 	CourierID       uuid.UUID  `json:"courierId" db:"courier_id"`
 	StoreID         *uuid.UUID `json:"storeId,omitempty" db:"store_id"`
 	ExternalOrderID string     `json:"externalOrderId,omitempty" db:"external_order_id"`
+	OrderType       OrderType  `json:"orderType" db:"order_type"`
 
 	// Customer information
 	CustomerName  string `json:"customerName" db:"customer_name"`
@@ -67,6 +74,9 @@ type Order struct {
 	DeliveryLongitude float64 `json:"deliveryLongitude" db:"delivery_longitude"`
 	DeliveryNotes     string  `json:"deliveryNotes,omitempty" db:"delivery_notes"`
 
+	// Drop-offs for multi-stop orders, in route order. The delivery fields above hold the final stop.
+	Stops []OrderStop `json:"stops,omitempty"`
+
 	// Package details
 	PackageDescription string  `json:"packageDescription" db:"package_description"`
 	PackageSize        string  `json:"packageSize" db:"package_size"`     // small, medium, large
@@ -88,28 +98,73 @@ type Order struct {
 	PaymentStatus    PaymentStatus `json:"paymentStatus" db:"payment_status"`
 	PaymentReference string        `json:"paymentReference,omitempty" db:"payment_reference"`
 
+	// Cash on delivery: the price of the goods, collected by the driver separately from the fare.
+	// For multi-stop orders CODAmount is the sum of the stops' amounts.
+	CODAmount       float64    `json:"codAmount" db:"cod_amount"`
+	CODCollected    float64    `json:"codCollected" db:"cod_collected"`
+	CODStatus       CODStatus  `json:"codStatus,omitempty" db:"cod_status"`
+	CODNote         string     `json:"codNote,omitempty" db:"cod_note"` // Driver's explanation of a collection shortfall
+	CODCollectedAt  *time.Time `json:"codCollectedAt,omitempty" db:"cod_collected_at"`
+	CODRemittanceID *uuid.UUID `json:"codRemittanceId,omitempty" db:"cod_remittance_id"`
+
 	// Status
 	Status        OrderStatus    `json:"status" db:"status"`
 	StatusHistory []StatusChange `json:"statusHistory,omitempty" db:"status_history"`
 
+	// Automatic dispatch: offered to one courier at a time until one accepts
+	DispatchMode   DispatchMode   `json:"dispatchMode,omitempty" db:"dispatch_mode"`
+	DispatchStatus DispatchStatus `json:"dispatchStatus,omitempty" db:"dispatch_status"`
+	DispatchArea   string         `json:"dispatchArea,omitempty" db:"dispatch_area"`      // Service area couriers must cover
+	OfferExpiresAt *time.Time     `json:"offerExpiresAt,omitempty" db:"offer_expires_at"` // When the current courier's offer times out
+
+	// Live tracking summary, only loaded by order queries that ask for it
+	Tracking *OrderTrackingSummary `json:"tracking,omitempty"`
+
 	// Scheduling
 	ScheduledPickup   *time.Time `json:"scheduledPickup,omitempty" db:"scheduled_pickup"`
 	ActualPickup      *time.Time `json:"actualPickup,omitempty" db:"actual_pickup"`
 	EstimatedDelivery *time.Time `json:"estimatedDelivery,omitempty" db:"estimated_delivery"`
 	ActualDelivery    *time.Time `json:"actualDelivery,omitempty" db:"actual_delivery"`
 
+	// Scheduled pickup follow-up
+	PickupReminderSentAt *time.Time `json:"pickupReminderSentAt,omitempty" db:"pickup_reminder_sent_at"`
+	PickupOverdueAt      *time.Time `json:"pickupOverdueAt,omitempty" db:"pickup_overdue_at"` // Set when tracking had not started by the scheduled pickup
+
+	// Failed delivery attempts and return to sender
+	DeliveryAttempts        int        `json:"deliveryAttempts" db:"delivery_attempts"` // Failed attempts so far
+	NextAttemptAt           *time.Time `json:"nextAttemptAt,omitempty" db:"next_attempt_at"`
+	ReattemptReminderSentAt *time.Time `json:"reattemptReminderSentAt,omitempty" db:"reattempt_reminder_sent_at"`
+	ReturnOrderID           *uuid.UUID `json:"returnOrderId,omitempty" db:"return_order_id"`      // The return leg created for this order
+	ReturnOfOrderID         *uuid.UUID `json:"returnOfOrderId,omitempty" db:"return_of_order_id"` // Set on a return leg: the order being returned
+
 	// Proof of delivery
 	DeliveryProofURL string `json:"deliveryProofUrl,omitempty" db:"delivery_proof_url"`
 	RecipientName    string `json:"recipientName,omitempty" db:"recipient_name"`
 	SignatureURL     string `json:"signatureUrl,omitempty" db:"signature_url"`
 
+	// Delivery PIN handoff confirmation (the PIN itself is only ever sent to the customer)
+	DeliveryPINRequired    bool       `json:"deliveryPinRequired" db:"delivery_pin_required"` // Set when the order went in transit
+	DeliveryPINHash        string     `json:"-" db:"delivery_pin_hash"`
+	DeliveryPINAttempts    int        `json:"-" db:"delivery_pin_attempts"`
+	DeliveryPINLockedUntil *time.Time `json:"-" db:"delivery_pin_locked_until"`
+	DeliveryPINVerifiedAt  *time.Time `json:"deliveryPinVerifiedAt,omitempty" db:"delivery_pin_verified_at"`
+
+	// Cancellation
+	CancelledBy        string     `json:"cancelledBy,omitempty" db:"cancelled_by"`
+	CancellationReason string     `json:"cancellationReason,omitempty" db:"cancellation_reason"`
+	CancellationFee    float64    `json:"cancellationFee,omitempty" db:"cancellation_fee"`
+	RefundAmount       float64    `json:"refundAmount,omitempty" db:"refund_amount"`
+	CancelledAt        *time.Time `json:"cancelledAt,omitempty" db:"cancelled_at"`
+
 	// Rating
-	CustomerRating   *int   `json:"customerRating,omitempty" db:"customer_rating"`
-	CustomerFeedback string `json:"customerFeedback,omitempty" db:"customer_feedback"`
+	CustomerRating   *int       `json:"customerRating,omitempty" db:"customer_rating"`
+	CustomerFeedback string     `json:"customerFeedback,omitempty" db:"customer_feedback"`
+	RatedBy          string     `json:"ratedBy,omitempty" db:"rated_by"`
+	RatedAt          *time.Time `json:"ratedAt,omitempty" db:"rated_at"`
 
 	// Metadata
 	Notes    string         `json:"notes,omitempty" db:"notes"`
-	Metadata map[string]any `json:"metadata,omitempty" db:"metadata"`
+	Metadata map[string]any `json:"metadata,omitempty" db:"metadata"` // Store-defined custom fields
 
 	// Timestamps
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
@@ -121,7 +176,67 @@ type StatusChange struct {
 	Status    OrderStatus `json:"status"`
 	Timestamp time.Time   `json:"timestamp"`
 	Note      string      `json:"note,omitempty"`
-	Actor     string      `json:"actor"` // courier, system, customer
+	Actor     string      `json:"actor"` // courier, store, customer, webhook, system
+}
+
+// MetadataReservedPrefix marks order metadata keys reserved for the platform; stores cannot set them
+const MetadataReservedPrefix = "nyengo_"
+
+// Actors recorded on status changes
+const (
+	StatusActorCourier  = "courier"
+	StatusActorStore    = "store"
+	StatusActorCustomer = "customer"
+	StatusActorWebhook  = "webhook"
+	StatusActorSystem   = "system"
+)
+
+// orderStatusTransitions lists the statuses each status may legally move to
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusScheduled: {OrderStatusPending, OrderStatusCancelled},
+	OrderStatusPending:   {OrderStatusAccepted, OrderStatusDeclined, OrderStatusCancelled},
+	OrderStatusAccepted:  {OrderStatusPickedUp, OrderStatusCancelled},
+	OrderStatusPickedUp:  {OrderStatusInTransit, OrderStatusReattemptScheduled, OrderStatusReturning, OrderStatusFailed, OrderStatusCancelled},
+	OrderStatusInTransit: {OrderStatusDelivered, OrderStatusReattemptScheduled, OrderStatusReturning, OrderStatusFailed},
+
+	OrderStatusReattemptScheduled: {OrderStatusInTransit, OrderStatusReturning},
+	OrderStatusReturning:          {OrderStatusReturned, OrderStatusFailed},
+}
+
+// NeedsDeliveryPIN reports whether the handoff of the order is confirmed with a PIN sent to the
+// customer. Multi-stop handoffs are confirmed per stop with photo proof instead, and a return leg to
+// a sender without a contact phone by the sender's signature alone.
+func (o *Order) NeedsDeliveryPIN() bool {
+	if o.OrderType == OrderTypeMultiStop {
+		return false
+	}
+	return o.OrderType != OrderTypeReturn || o.CustomerPhone != ""
+}
+
+// CanTransitionTo reports whether an order may move from s to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether no further transitions are possible from s
+func (s OrderStatus) IsTerminal() bool {
+	return len(orderStatusTransitions[s]) == 0
+}
+
+// IsValid reports whether s is one of the known order statuses
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusScheduled, OrderStatusPending, OrderStatusAccepted, OrderStatusDeclined, OrderStatusPickedUp,
+		OrderStatusInTransit, OrderStatusDelivered, OrderStatusCancelled, OrderStatusFailed,
+		OrderStatusReattemptScheduled, OrderStatusReturning, OrderStatusReturned:
+		return true
+	}
+	return false
 }
 
 // CreateOrderRequest is the request body for creating a new order
@@ -139,12 +254,15 @@ type CreateOrderRequest struct {
 	PickupContactName  string  `json:"pickupContactName,omitempty"`
 	PickupContactPhone string  `json:"pickupContactPhone,omitempty"`
 
-	// Delivery
-	DeliveryAddress   string  `json:"deliveryAddress" validate:"required"`
-	DeliveryLatitude  float64 `json:"deliveryLatitude" validate:"required"`
-	DeliveryLongitude float64 `json:"deliveryLongitude" validate:"required"`
+	// Delivery (single drop-off orders)
+	DeliveryAddress   string  `json:"deliveryAddress" validate:"required_without=Stops"`
+	DeliveryLatitude  float64 `json:"deliveryLatitude" validate:"required_without=Stops"`
+	DeliveryLongitude float64 `json:"deliveryLongitude" validate:"required_without=Stops"`
 	DeliveryNotes     string  `json:"deliveryNotes,omitempty"`
 
+	// Stops makes this a multi-stop order: one pickup followed by these drop-offs in order
+	Stops []CreateStopRequest `json:"stops,omitempty" validate:"omitempty,dive"`
+
 	// Package
 	PackageDescription string  `json:"packageDescription" validate:"required"`
 	PackageSize        string  `json:"packageSize" validate:"required,oneof=small medium large"`
@@ -154,6 +272,7 @@ type CreateOrderRequest struct {
 
 	// Payment
 	PaymentMethod PaymentMethod `json:"paymentMethod" validate:"required"`
+	CODAmount     float64       `json:"codAmount,omitempty"` // Goods price to collect from the customer; set per stop on multi-stop orders
 
 	// Scheduling
 	ScheduledPickup *time.Time `json:"scheduledPickup,omitempty"`
@@ -161,6 +280,9 @@ type CreateOrderRequest struct {
 	// External reference
 	ExternalOrderID string     `json:"externalOrderId,omitempty"`
 	StoreID         *uuid.UUID `json:"storeId,omitempty"`
+
+	// Custom fields kept with the order and returned with it
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
 // UpdateOrderStatusRequest is the request for updating order status
@@ -170,25 +292,98 @@ type UpdateOrderStatusRequest struct {
 	ProofURL      string      `json:"proofUrl,omitempty"`
 	Signature     string      `json:"signature,omitempty"`
 	RecipientName string      `json:"recipientName,omitempty"`
+	PIN           string      `json:"pin,omitempty"` // Required when marking an order delivered
+}
+
+// DeliveryProof is the proof-of-delivery for an order with time-limited download links
+type DeliveryProof struct {
+	OrderID       uuid.UUID  `json:"orderId"`
+	OrderNumber   string     `json:"orderNumber"`
+	StopID        *uuid.UUID `json:"stopId,omitempty"` // Set for a stop on a multi-stop order
+	RecipientName string     `json:"recipientName,omitempty"`
+	PhotoURL      string     `json:"photoUrl,omitempty"`
+	ThumbnailURL  string     `json:"thumbnailUrl,omitempty"`
+	SignatureURL  string     `json:"signatureUrl,omitempty"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+}
+
+// ConfirmDeliveryRequest is submitted by the driver at handoff to mark an order delivered
+type ConfirmDeliveryRequest struct {
+	PIN  string `json:"pin" validate:"required"`
+	Note string `json:"note,omitempty"`
+}
+
+// RateOrderRequest is the request for rating a delivered order
+type RateOrderRequest struct {
+	Rating   int    `json:"rating" validate:"required,min=1,max=5"`
+	Feedback string `json:"feedback,omitempty"`
+}
+
+// CancelOrderRequest is the request for cancelling an order
+type CancelOrderRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// CancellationResult summarises the financial outcome of a cancellation
+type CancellationResult struct {
+	OrderID         uuid.UUID   `json:"orderId"`
+	OrderNumber     string      `json:"orderNumber"`
+	Status          OrderStatus `json:"status"`
+	CancelledBy     string      `json:"cancelledBy"`
+	Reason          string      `json:"reason"`
+	PreviousStatus  OrderStatus `json:"previousStatus"`
+	CancellationFee float64     `json:"cancellationFee"`
+	RefundAmount    float64     `json:"refundAmount"`
+	FormattedFee    string      `json:"formattedFee"`
+	FormattedRefund string      `json:"formattedRefund"`
+}
+
+// OrderCancellation is what a cancellation records along with the status change
+type OrderCancellation struct {
+	CancelledBy     string
+	Reason          string
+	Fee             float64
+	Refund          float64
+	RefundPayment   bool    // The order was prepaid; its payment is marked refunded
+	CourierFeeShare float64 // The courier's share of the fee, credited to its wallet
 }
 
 // OrderListFilters contains filters for listing orders
 type OrderListFilters struct {
-	Status    []OrderStatus `json:"status,omitempty"`
-	DateFrom  *time.Time    `json:"dateFrom,omitempty"`
-	DateTo    *time.Time    `json:"dateTo,omitempty"`
-	Search    string        `json:"search,omitempty"`
-	SortBy    string        `json:"sortBy,omitempty"`
-	SortOrder string        `json:"sortOrder,omitempty"`
-	Page      int           `json:"page,omitempty"`
-	PageSize  int           `json:"pageSize,omitempty"`
+	CourierID     *uuid.UUID      `json:"courierId,omitempty"`
+	StoreID       *uuid.UUID      `json:"storeId,omitempty"`
+	DriverID      *uuid.UUID      `json:"driverId,omitempty"` // Orders assigned to the driver and not released
+	Status        []OrderStatus   `json:"status,omitempty"`
+	PaymentStatus []PaymentStatus `json:"paymentStatus,omitempty"`
+	DateFrom      *time.Time      `json:"dateFrom,omitempty"`
+	DateTo        *time.Time      `json:"dateTo,omitempty"`
+	MinFare       *float64        `json:"minFare,omitempty"`
+	MaxFare       *float64        `json:"maxFare,omitempty"`
+	Search        string          `json:"search,omitempty"`
+	SortBy        string          `json:"sortBy,omitempty"`    // One of OrderSortFields
+	SortOrder     string          `json:"sortOrder,omitempty"` // asc or desc (default)
+	Page          int             `json:"page,omitempty"`
+	PageSize      int             `json:"pageSize,omitempty"`
+	Cursor        string          `json:"cursor,omitempty"` // Keyset pagination: the nextCursor of the previous page
+
+	// Metadata matches orders whose top-level metadata values equal these, compared as text
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	IncludeStatusHistory bool `json:"includeStatusHistory,omitempty"`
+	IncludeTracking      bool `json:"includeTracking,omitempty"`
 }
 
-// OrderListResponse contains paginated order results
+// OrderSortFields are the fields orders can be sorted by
+var OrderSortFields = []string{"createdAt", "updatedAt", "totalFare", "distance", "orderNumber"}
+
+// OrderListResponse contains paginated order results. Page-numbered queries return the totals;
+// cursor queries skip counting and return nextCursor instead.
 type OrderListResponse struct {
 	Orders     []Order `json:"orders"`
-	TotalCount int     `json:"totalCount"`
-	Page       int     `json:"page"`
+	TotalCount *int    `json:"totalCount,omitempty"`
+	Page       int     `json:"page,omitempty"`
 	PageSize   int     `json:"pageSize"`
-	TotalPages int     `json:"totalPages"`
+	TotalPages *int    `json:"totalPages,omitempty"`
+	HasMore    bool    `json:"hasMore"`
+	NextCursor string  `json:"nextCursor,omitempty"`
 }
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AmendmentStatus represents the state of an order amendment
+type AmendmentStatus string
+
+const (
+	AmendmentStatusApplied         AmendmentStatus = "applied"
+	AmendmentStatusPendingApproval AmendmentStatus = "pending_approval" // Fare increase above the threshold, waiting for the store
+	AmendmentStatusRejected        AmendmentStatus = "rejected"
+)
+
+// AmendOrderRequest is the body of an order amendment. Only the fields that are set are changed.
+type AmendOrderRequest struct {
+	DeliveryAddress    *string  `json:"deliveryAddress,omitempty"`
+	DeliveryLatitude   *float64 `json:"deliveryLatitude,omitempty"`
+	DeliveryLongitude  *float64 `json:"deliveryLongitude,omitempty"`
+	DeliveryNotes      *string  `json:"deliveryNotes,omitempty"`
+	PackageDescription *string  `json:"packageDescription,omitempty"`
+	PackageSize        *string  `json:"packageSize,omitempty"` // small, medium, large
+	PackageWeight      *float64 `json:"packageWeight,omitempty"`
+	IsFragile          *bool    `json:"isFragile,omitempty"`
+	Reason             string   `json:"reason,omitempty"`
+}
+
+// FieldChange records one field's value before and after an amendment
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// OrderAmendment is an entry in an order's amendment log
+type OrderAmendment struct {
+	ID          uuid.UUID              `json:"id" db:"id"`
+	OrderID     uuid.UUID              `json:"orderId" db:"order_id"`
+	RequestedBy string                 `json:"requestedBy" db:"requested_by"` // courier or store
+	Reason      string                 `json:"reason,omitempty" db:"reason"`
+	Status      AmendmentStatus        `json:"status" db:"status"`
+	Changes     map[string]FieldChange `json:"changes" db:"changes"`
+	Request     AmendOrderRequest      `json:"-" db:"request"` // Kept to apply the amendment once approved
+
+	// Repricing
+	PreviousDistance float64 `json:"previousDistance" db:"previous_distance"`
+	NewDistance      float64 `json:"newDistance" db:"new_distance"`
+	PreviousFare     float64 `json:"previousFare" db:"previous_fare"`
+	NewFare          float64 `json:"newFare" db:"new_fare"`
+	FareDelta        float64 `json:"fareDelta" db:"fare_delta"`
+	PreviousEarnings float64 `json:"previousEarnings" db:"previous_earnings"`
+	NewEarnings      float64 `json:"newEarnings" db:"new_earnings"`
+	EarningsDelta    float64 `json:"earningsDelta" db:"earnings_delta"`
+
+	// Store decision on amendments that needed approval
+	DecidedBy    string     `json:"decidedBy,omitempty" db:"decided_by"`
+	DecisionNote string     `json:"decisionNote,omitempty" db:"decision_note"`
+	DecidedAt    *time.Time `json:"decidedAt,omitempty" db:"decided_at"`
+
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// AmendmentDecisionRequest is the body for approving or rejecting an amendment
+type AmendmentDecisionRequest struct {
+	Note string `json:"note,omitempty"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportStatus represents the outcome of a bulk order import
+type ImportStatus string
+
+const (
+	ImportStatusProcessing ImportStatus = "processing"
+	ImportStatusCompleted  ImportStatus = "completed" // Every row passed (dry run) or was created
+	ImportStatusPartial    ImportStatus = "partial"   // Some rows failed, the rest passed or were created
+	ImportStatusFailed     ImportStatus = "failed"    // No row passed or was created
+	ImportStatusRejected   ImportStatus = "rejected"  // onError=abort and a row was invalid, so nothing was created
+)
+
+// ImportRowStatus represents the outcome of one row of a bulk import
+type ImportRowStatus string
+
+const (
+	ImportRowValid   ImportRowStatus = "valid"   // Dry run: the row would be created
+	ImportRowCreated ImportRowStatus = "created" // The order was created
+	ImportRowInvalid ImportRowStatus = "invalid" // The row failed validation
+	ImportRowFailed  ImportRowStatus = "failed"  // The row was valid but the order could not be created
+	ImportRowSkipped ImportRowStatus = "skipped" // The row was valid but the import was rejected
+)
+
+// ImportErrorMode controls what happens to valid rows when other rows are invalid
+type ImportErrorMode string
+
+const (
+	ImportOnErrorContinue ImportErrorMode = "continue" // Create the valid rows and report the rest (default)
+	ImportOnErrorAbort    ImportErrorMode = "abort"    // Create nothing unless every row is valid
+)
+
+// OrderImport is a bulk order import job and its per-row report
+type OrderImport struct {
+	ID           uuid.UUID        `json:"id" db:"id"`
+	StoreID      *uuid.UUID       `json:"storeId,omitempty" db:"store_id"`
+	Format       string           `json:"format" db:"format"` // csv or json
+	DryRun       bool             `json:"dryRun" db:"dry_run"`
+	OnError      ImportErrorMode  `json:"onError" db:"on_error"`
+	Status       ImportStatus     `json:"status" db:"status"`
+	TotalRows    int              `json:"totalRows" db:"total_rows"`
+	ValidRows    int              `json:"validRows" db:"valid_rows"`
+	CreatedCount int              `json:"createdCount" db:"created_count"`
+	FailedCount  int              `json:"failedCount" db:"failed_count"` // Invalid plus failed rows
+	TotalFare    float64          `json:"totalFare" db:"total_fare"`     // Sum of the valid rows' fares
+	Rows         []OrderImportRow `json:"rows" db:"rows"`
+	CreatedAt    time.Time        `json:"createdAt" db:"created_at"`
+	CompletedAt  *time.Time       `json:"completedAt,omitempty" db:"completed_at"`
+}
+
+// OrderImportRow is the result of one imported row
+type OrderImportRow struct {
+	Row             int             `json:"row"` // 1-based data row (CSV header excluded) or array index + 1
+	Status          ImportRowStatus `json:"status"`
+	ExternalOrderID string          `json:"externalOrderId,omitempty"`
+	CourierID       string          `json:"courierId,omitempty"`
+	OrderID         *uuid.UUID      `json:"orderId,omitempty"`
+	OrderNumber     string          `json:"orderNumber,omitempty"`
+	Distance        float64         `json:"distance,omitempty"`
+	TotalFare       float64         `json:"totalFare,omitempty"`
+	FormattedFare   string          `json:"formattedFare,omitempty"`
+	Errors          []string        `json:"errors,omitempty"`
+}
+
+// ImportOrderRequest is one row of a JSON bulk import: a store order plus the courier to assign it to
+type ImportOrderRequest struct {
+	CourierID string `json:"courierId,omitempty"` // Defaults to the courierId query parameter
+	CreateOrderRequest
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderType distinguishes single drop-off orders from multi-stop routes and return legs
+type OrderType string
+
+const (
+	OrderTypeStandard  OrderType = "standard"
+	OrderTypeMultiStop OrderType = "multi_stop"
+	OrderTypeReturn    OrderType = "return" // Takes an undeliverable parcel back to the sender
+)
+
+// StopStatus represents the state of a single drop-off on a multi-stop order
+type StopStatus string
+
+const (
+	StopStatusPending   StopStatus = "pending"
+	StopStatusDelivered StopStatus = "delivered"
+	StopStatusFailed    StopStatus = "failed"
+)
+
+// OrderStop is one drop-off on a multi-stop order, visited in Sequence order
+type OrderStop struct {
+	ID       uuid.UUID  `json:"id" db:"id"`
+	OrderID  uuid.UUID  `json:"orderId" db:"order_id"`
+	Sequence int        `json:"sequence" db:"sequence"` // 1-based position on the route
+	Status   StopStatus `json:"status" db:"status"`
+
+	// Recipient
+	RecipientName  string `json:"recipientName" db:"recipient_name"`
+	RecipientPhone string `json:"recipientPhone" db:"recipient_phone"`
+
+	// Location
+	Address   string  `json:"address" db:"address"`
+	Latitude  float64 `json:"latitude" db:"latitude"`
+	Longitude float64 `json:"longitude" db:"longitude"`
+	Notes     string  `json:"notes,omitempty" db:"notes"`
+
+	// Parcel and cash on delivery
+	PackageDescription string  `json:"packageDescription,omitempty" db:"package_description"`
+	CODAmount          float64 `json:"codAmount" db:"cod_amount"`
+	CODCollected       float64 `json:"codCollected" db:"cod_collected"`
+
+	// Route
+	DistanceFromPrevious float64    `json:"distanceFromPrevious" db:"distance_from_previous"` // km from pickup or the previous stop
+	EstimatedArrival     *time.Time `json:"estimatedArrival,omitempty" db:"estimated_arrival"`
+
+	// Proof of delivery (storage keys, see DeliveryProof for download links)
+	DeliveryProofURL string `json:"deliveryProofUrl,omitempty" db:"delivery_proof_url"`
+	SignatureURL     string `json:"signatureUrl,omitempty" db:"signature_url"`
+	ReceivedBy       string `json:"receivedBy,omitempty" db:"received_by"`
+
+	// Outcome
+	FailureReason string     `json:"failureReason,omitempty" db:"failure_reason"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty" db:"completed_at"`
+
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// IsFinal reports whether the stop has been delivered or failed
+func (s *OrderStop) IsFinal() bool {
+	return s.Status == StopStatusDelivered || s.Status == StopStatusFailed
+}
+
+// CreateStopRequest describes one drop-off when creating a multi-stop order
+type CreateStopRequest struct {
+	RecipientName      string  `json:"recipientName" validate:"required"`
+	RecipientPhone     string  `json:"recipientPhone" validate:"required"`
+	Address            string  `json:"address" validate:"required"`
+	Latitude           float64 `json:"latitude" validate:"required"`
+	Longitude          float64 `json:"longitude" validate:"required"`
+	Notes              string  `json:"notes,omitempty"`
+	PackageDescription string  `json:"packageDescription,omitempty"`
+	CODAmount          float64 `json:"codAmount,omitempty"`
+}
+
+// UpdateStopStatusRequest is sent by the driver when a drop-off is completed or fails
+type UpdateStopStatusRequest struct {
+	Status        StopStatus `json:"status" validate:"required,oneof=delivered failed"`
+	FailureReason string     `json:"failureReason,omitempty"`
+	CODCollected  *float64   `json:"codCollected,omitempty"` // Cash collected; required when delivering a stop with a codAmount
+}
+
+// StopETA is the live arrival estimate for a stop that has not been completed yet
+type StopETA struct {
+	StopID            uuid.UUID  `json:"stopId"`
+	Sequence          int        `json:"sequence"`
+	Status            StopStatus `json:"status"`
+	Address           string     `json:"address"`
+	Latitude          float64    `json:"latitude"`
+	Longitude         float64    `json:"longitude"`
+	DistanceRemaining float64    `json:"distanceRemaining"` // km along the route from the driver
+	ETAMinutes        int        `json:"etaMinutes"`
+	EstimatedArrival  time.Time  `json:"estimatedArrival"`
+}
+
+// RoutePoint is a drop-off location used for route pricing
+type RoutePoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// RouteLeg is one leg of a priced route, ending at the stop with the same sequence
+type RouteLeg struct {
+	Sequence int     `json:"sequence"`
+	Distance float64 `json:"distance"` // km
+	Duration int     `json:"duration"` // minutes
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransferStatus represents the state of an order handover between couriers
+type TransferStatus string
+
+const (
+	TransferStatusPending   TransferStatus = "pending"   // Waiting for the receiving courier
+	TransferStatusAccepted  TransferStatus = "accepted"  // The order now belongs to the receiving courier
+	TransferStatusRejected  TransferStatus = "rejected"  // The receiving courier said no
+	TransferStatusCancelled TransferStatus = "cancelled" // Withdrawn by the proposer, or the order moved on before an answer
+	TransferStatusExpired   TransferStatus = "expired"   // Not answered within TRANSFER_REQUEST_TTL
+)
+
+// TransferEarningsRule decides how an order's courier earnings are split on a handover
+type TransferEarningsRule string
+
+const (
+	TransferEarningsProgress TransferEarningsRule = "progress" // By the share of the trip already driven; nothing before pickup
+	TransferEarningsFixed    TransferEarningsRule = "fixed"    // The handing-over courier keeps TRANSFER_ORIGINAL_SHARE
+	TransferEarningsReceiver TransferEarningsRule = "receiver" // Everything goes to the receiving courier
+)
+
+// OrderTransfer is an entry in an order's handover log
+type OrderTransfer struct {
+	ID            uuid.UUID      `json:"id" db:"id"`
+	OrderID       uuid.UUID      `json:"orderId" db:"order_id"`
+	OrderNumber   string         `json:"orderNumber,omitempty" db:"order_number"`
+	FromCourierID uuid.UUID      `json:"fromCourierId" db:"from_courier_id"`
+	ToCourierID   uuid.UUID      `json:"toCourierId" db:"to_courier_id"`
+	RequestedBy   string         `json:"requestedBy" db:"requested_by"` // courier or admin
+	Reason        string         `json:"reason,omitempty" db:"reason"`
+	Status        TransferStatus `json:"status" db:"status"`
+	OrderStatus   OrderStatus    `json:"orderStatus" db:"order_status"` // When the handover was proposed
+
+	// Earnings split, set on acceptance
+	EarningsRule     TransferEarningsRule `json:"earningsRule,omitempty" db:"earnings_rule"`
+	OriginalEarnings float64              `json:"originalEarnings" db:"original_earnings"`
+	FromEarnings     float64              `json:"fromEarnings" db:"from_earnings"` // Credited to the handing-over courier
+	ToEarnings       float64              `json:"toEarnings" db:"to_earnings"`     // The order's earnings from now on
+
+	ResponseNote string     `json:"responseNote,omitempty" db:"response_note"`
+	ExpiresAt    time.Time  `json:"expiresAt" db:"expires_at"`
+	RespondedAt  *time.Time `json:"respondedAt,omitempty" db:"responded_at"`
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// TransferOrderRequest is the body for proposing a handover
+type TransferOrderRequest struct {
+	ToCourierID uuid.UUID `json:"toCourierId"`
+	Reason      string    `json:"reason"`
+}
+
+// TransferResponseRequest is the optional body for rejecting or cancelling a handover
+type TransferResponseRequest struct {
+	Note string `json:"note,omitempty"`
+}
@@ -68,6 +68,7 @@ type CourierWallet struct {
 	AvailableBalance float64   `json:"availableBalance"` // Can be withdrawn
 	PendingBalance   float64   `json:"pendingBalance"`   // Pending verification
 	TotalEarnings    float64   `json:"totalEarnings"`    // All-time earnings
+	CashLiability    float64   `json:"cashLiability"`    // Cash-on-delivery cash held for stores
 	Currency         string    `json:"currency"`
 	UpdatedAt        time.Time `json:"updatedAt"`
 }
@@ -118,6 +119,7 @@ type StorePaymentConfig struct {
 	PaymentAPIURL string    `json:"paymentApiUrl"`
 	APIKey        string    `json:"apiKey"`
 	WebhookSecret string    `json:"webhookSecret"`
+	WebhookURL    string    `json:"webhookUrl,omitempty"` // Receives order event webhooks
 	IsActive      bool      `json:"isActive"`
 }
 
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // PriceEstimateRequest is the request for getting a delivery price estimate
 type PriceEstimateRequest struct {
 	// Pickup location
@@ -12,6 +14,9 @@ type PriceEstimateRequest struct {
 	DeliveryLongitude float64 `json:"deliveryLongitude" validate:"required"`
 	DeliveryAddress   string  `json:"deliveryAddress,omitempty"`
 
+	// Multi-stop route: drop-offs visited in order after pickup (replaces the delivery location)
+	Stops []RoutePoint `json:"stops,omitempty"`
+
 	// Package details (optional for more accurate pricing)
 	PackageSize   string  `json:"packageSize,omitempty"`   // small, medium, large
 	PackageWeight float64 `json:"packageWeight,omitempty"` // in kg
@@ -20,6 +25,8 @@ type PriceEstimateRequest struct {
 
 	// Optional: specific courier ID for custom pricing
 	CourierID string `json:"courierId,omitempty"`
+	// Optional: when the package will be collected, to check the courier's operating hours (defaults to now)
+	PickupAt *time.Time `json:"pickupAt,omitempty"`
 }
 
 // PriceEstimateResponse is the response containing price breakdown
@@ -39,6 +46,10 @@ type PriceEstimateResponse struct {
 	FragileFare  float64 `json:"fragileFare,omitempty"`
 	ExpressFare  float64 `json:"expressFare,omitempty"`
 	SurgeFare    float64 `json:"surgeFare,omitempty"`
+	StopFare     float64 `json:"stopFare,omitempty"` // Extra drop-offs on multi-stop routes
+
+	// Route legs for multi-stop estimates
+	Legs []RouteLeg `json:"legs,omitempty"`
 
 	// Totals
 	SubTotal    float64 `json:"subTotal"`
@@ -55,6 +66,9 @@ type PriceEstimateResponse struct {
 	SurgeMultiplier   float64 `json:"surgeMultiplier,omitempty"`
 	IsSurgeActive     bool    `json:"isSurgeActive"`
 
+	// Whether the requested courier is open at the pickup time, and when they next are if not
+	OpeningStatus *OpeningStatus `json:"openingStatus,omitempty"`
+
 	// Pricing tier applied
 	PricingTier string `json:"pricingTier"`
 
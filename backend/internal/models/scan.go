@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScanEvent is what happened to a parcel when its label was scanned
+type ScanEvent string
+
+const (
+	ScanEventPickedUp       ScanEvent = "picked_up"        // Collected from the sender
+	ScanEventHandedOver     ScanEvent = "handed_over"      // Received from another driver or hub; the scanner now holds it
+	ScanEventArrivedAtHub   ScanEvent = "arrived_at_hub"   // Checked in at a hub or depot
+	ScanEventLoaded         ScanEvent = "loaded"           // Loaded onto a vehicle
+	ScanEventOutForDelivery ScanEvent = "out_for_delivery" // Left for the recipient
+)
+
+// IsValid reports whether e is one of the known scan events
+func (e ScanEvent) IsValid() bool {
+	switch e {
+	case ScanEventPickedUp, ScanEventHandedOver, ScanEventArrivedAtHub, ScanEventLoaded, ScanEventOutForDelivery:
+		return true
+	}
+	return false
+}
+
+// ScanActorType is the role of the person who scanned a parcel
+type ScanActorType string
+
+const (
+	ScanActorDriver   ScanActorType = "driver"
+	ScanActorHubStaff ScanActorType = "hub_staff"
+)
+
+// IsValid reports whether t is one of the known actor types
+func (t ScanActorType) IsValid() bool {
+	return t == ScanActorDriver || t == ScanActorHubStaff
+}
+
+// ParcelScan is one scan of a parcel's label. Whoever scans a parcel holds it afterwards.
+type ParcelScan struct {
+	ID           uuid.UUID     `json:"id" db:"id"`
+	OrderID      uuid.UUID     `json:"orderId" db:"order_id"`
+	CourierID    uuid.UUID     `json:"courierId" db:"courier_id"`         // Courier account the scan was made under
+	DriverID     *uuid.UUID    `json:"driverId,omitempty" db:"driver_id"` // Driver who scanned, when made with a driver token
+	Event        ScanEvent     `json:"event" db:"event"`
+	ActorType    ScanActorType `json:"actorType" db:"actor_type"`
+	ActorName    string        `json:"actorName,omitempty" db:"actor_name"`
+	Location     string        `json:"location,omitempty" db:"location"` // Hub or place name
+	Latitude     float64       `json:"latitude" db:"latitude"`
+	Longitude    float64       `json:"longitude" db:"longitude"`
+	Note         string        `json:"note,omitempty" db:"note"`
+	StatusBefore OrderStatus   `json:"statusBefore" db:"status_before"`
+	StatusAfter  OrderStatus   `json:"statusAfter" db:"status_after"` // Differs from StatusBefore when the scan moved the order on
+	ScannedAt    time.Time     `json:"scannedAt" db:"scanned_at"`
+}
+
+// ScanRequest is sent by a driver or hub staff member scanning a parcel's label
+type ScanRequest struct {
+	Code      string        `json:"code" validate:"required"` // Barcode (order number) or QR code (tracking URL) contents
+	Event     ScanEvent     `json:"event" validate:"required"`
+	ActorType ScanActorType `json:"actorType,omitempty"` // Defaults to driver
+	ActorName string        `json:"actorName,omitempty"` // Required for hub staff
+	Location  string        `json:"location,omitempty"`  // Required for arrived_at_hub
+	Latitude  float64       `json:"latitude" validate:"required"`
+	Longitude float64       `json:"longitude" validate:"required"`
+	Note      string        `json:"note,omitempty"`
+}
+
+// ScanResult is the outcome of a scan
+type ScanResult struct {
+	Scan  ParcelScan `json:"scan"`
+	Order *Order     `json:"order"`
+}
+
+// CustodyPeriod is a span of time during which one person held a parcel
+type CustodyPeriod struct {
+	ActorType ScanActorType `json:"actorType"`
+	ActorName string        `json:"actorName,omitempty"`
+	CourierID uuid.UUID     `json:"courierId"`
+	DriverID  *uuid.UUID    `json:"driverId,omitempty"`
+	Location  string        `json:"location,omitempty"` // Where the period started
+	From      time.Time     `json:"from"`
+	Until     *time.Time    `json:"until,omitempty"` // Nil while the parcel is still held
+	Events    []ScanEvent   `json:"events"`          // Scans recorded during the period
+}
+
+// CustodyTimeline is an order's chain of custody: every scan and who held the parcel when
+type CustodyTimeline struct {
+	OrderID       uuid.UUID       `json:"orderId"`
+	OrderNumber   string          `json:"orderNumber"`
+	Status        OrderStatus     `json:"status"`
+	CurrentHolder *CustodyPeriod  `json:"currentHolder,omitempty"` // Nil before the first scan and once the parcel has left custody
+	Custody       []CustodyPeriod `json:"custody"`
+	Scans         []ParcelScan    `json:"scans"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Shift end reasons
+const (
+	ShiftEndOffline          = "offline"           // The courier or driver went offline
+	ShiftEndHeartbeatTimeout = "heartbeat_timeout" // No heartbeat within the timeout; the shift ends at the last one
+	ShiftEndDriverSuspended  = "driver_suspended"  // The courier company suspended the driver
+)
+
+// Shift actions sent over REST and the WebSocket
+const (
+	ShiftActionOnline    = "online"
+	ShiftActionOffline   = "offline"
+	ShiftActionHeartbeat = "heartbeat"
+)
+
+// Shift is a period the courier account or one of its drivers was online
+type Shift struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	CourierID       uuid.UUID  `json:"courierId" db:"courier_id"`
+	DriverID        *uuid.UUID `json:"driverId,omitempty" db:"driver_id"` // nil for the courier account
+	DriverName      string     `json:"driverName,omitempty" db:"-"`
+	StartedAt       time.Time  `json:"startedAt" db:"started_at"`
+	LastHeartbeatAt time.Time  `json:"lastHeartbeatAt" db:"last_heartbeat_at"`
+	EndedAt         *time.Time `json:"endedAt,omitempty" db:"ended_at"`
+	EndReason       string     `json:"endReason,omitempty" db:"end_reason"`
+	HoursWorked     float64    `json:"hoursWorked" db:"-"` // Up to now while the shift is open
+}
+
+// ShiftStatus is whether the courier account or a driver is online, and until when a heartbeat is due
+type ShiftStatus struct {
+	Online            bool       `json:"online"`
+	Shift             *Shift     `json:"shift,omitempty"`
+	HeartbeatDeadline *time.Time `json:"heartbeatDeadline,omitempty"`
+}
+
+// ShiftHistory lists the shifts overlapping a period and the hours worked within it
+type ShiftHistory struct {
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+	ShiftCount  int        `json:"shiftCount"`
+	HoursWorked float64    `json:"hoursWorked"`
+	Shifts      []Shift    `json:"shifts"`
+}
+
+// CourierAvailability is who is online for a courier right now. Shifts past the maximum shift length
+// are online but not available for new jobs.
+type CourierAvailability struct {
+	CourierID       uuid.UUID `json:"courierId"`
+	Online          bool      `json:"online"`
+	OnlineShifts    int       `json:"onlineShifts"`
+	AvailableShifts int       `json:"availableShifts"`
+	Shifts          []Shift   `json:"shifts,omitempty"`
+}
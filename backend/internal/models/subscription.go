@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecurrenceFrequency is how often a recurring delivery repeats
+type RecurrenceFrequency string
+
+const (
+	FrequencyDaily   RecurrenceFrequency = "daily"
+	FrequencyWeekly  RecurrenceFrequency = "weekly"
+	FrequencyMonthly RecurrenceFrequency = "monthly"
+)
+
+// SubscriptionStatus represents the state of a recurring delivery series
+type SubscriptionStatus string
+
+const (
+	SubscriptionStatusActive SubscriptionStatus = "active"
+	SubscriptionStatusPaused SubscriptionStatus = "paused"
+	SubscriptionStatusEnded  SubscriptionStatus = "ended"
+)
+
+// RecurrenceRule is a simplified RRULE describing when deliveries occur.
+// Times are wall-clock times in Timezone.
+type RecurrenceRule struct {
+	Frequency  RecurrenceFrequency `json:"frequency"`            // daily, weekly or monthly
+	Interval   int                 `json:"interval,omitempty"`   // Every N days/weeks/months (default 1)
+	ByWeekday  []string            `json:"byWeekday,omitempty"`  // Weekly: MO, TU, WE, TH, FR, SA, SU (default: the start date's weekday)
+	ByMonthDay []int               `json:"byMonthDay,omitempty"` // Monthly: 1-31, or -1 for the last day (default: the start date's day)
+	PickupTime string              `json:"pickupTime"`           // HH:MM
+	Timezone   string              `json:"timezone,omitempty"`   // IANA name, defaults to the platform timezone
+	StartDate  string              `json:"startDate"`            // YYYY-MM-DD, first possible occurrence
+	EndDate    string              `json:"endDate,omitempty"`    // YYYY-MM-DD, last possible occurrence
+	Count      int                 `json:"count,omitempty"`      // Stop after this many occurrences
+}
+
+// DeliverySubscription is a store's recurring delivery: an order template plus a schedule
+type DeliverySubscription struct {
+	ID        uuid.UUID          `json:"id" db:"id"`
+	StoreID   uuid.UUID          `json:"storeId" db:"store_id"`
+	CourierID uuid.UUID          `json:"courierId" db:"courier_id"`
+	Name      string             `json:"name" db:"name"`
+	Template  CreateOrderRequest `json:"template" db:"template"`
+	Rule      RecurrenceRule     `json:"rule" db:"rule"`
+	Status    SubscriptionStatus `json:"status" db:"status"`
+
+	// Occurrence dates (YYYY-MM-DD) that will not be generated
+	SkippedDates []string `json:"skippedDates,omitempty" db:"skipped_dates"`
+
+	// Generation progress
+	GeneratedCount int        `json:"generatedCount" db:"generated_count"`
+	LastOccurrence *time.Time `json:"lastOccurrence,omitempty" db:"last_occurrence"` // Latest occurrence an order was generated for
+	NextOccurrence *time.Time `json:"nextOccurrence,omitempty"`
+	EndedAt        *time.Time `json:"endedAt,omitempty" db:"ended_at"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// SubscriptionOccurrence is one scheduled delivery of a subscription
+type SubscriptionOccurrence struct {
+	Date            string     `json:"date"` // YYYY-MM-DD in the rule's timezone
+	ScheduledPickup time.Time  `json:"scheduledPickup"`
+	Skipped         bool       `json:"skipped"`
+	OrderID         *uuid.UUID `json:"orderId,omitempty"` // Set once the order has been generated
+}
+
+// CreateSubscriptionRequest is the request body for creating a recurring delivery
+type CreateSubscriptionRequest struct {
+	CourierID string             `json:"courierId" validate:"required"`
+	Name      string             `json:"name" validate:"required"`
+	Template  CreateOrderRequest `json:"template" validate:"required"`
+	Rule      RecurrenceRule     `json:"rule" validate:"required"`
+}
+
+// SkipOccurrenceRequest skips a single upcoming occurrence
+type SkipOccurrenceRequest struct {
+	Date string `json:"date" validate:"required"` // YYYY-MM-DD
+}
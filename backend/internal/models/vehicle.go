@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VehicleType is the kind of a registered vehicle
+type VehicleType string
+
+const (
+	VehicleTypeBicycle   VehicleType = "bicycle"
+	VehicleTypeMotorbike VehicleType = "motorbike"
+	VehicleTypeCar       VehicleType = "car"
+	VehicleTypeVan       VehicleType = "van"
+	VehicleTypeTruck     VehicleType = "truck"
+)
+
+// Vehicle documents with an expiry date
+const (
+	VehicleDocumentInsurance      = "insurance"
+	VehicleDocumentRoadworthiness = "roadworthiness"
+)
+
+// Vehicle is a vehicle registered by a courier company
+type Vehicle struct {
+	ID               uuid.UUID   `json:"id" db:"id"`
+	CourierID        uuid.UUID   `json:"courierId" db:"courier_id"`
+	Type             VehicleType `json:"type" db:"type"`
+	Plate            string      `json:"plate" db:"plate"`
+	Description      string      `json:"description,omitempty" db:"description"`
+	CapacityKg       float64     `json:"capacityKg" db:"capacity_kg"`
+	CapacityLitres   float64     `json:"capacityLitres" db:"capacity_litres"`
+	InsuranceExpiry  *time.Time  `json:"insuranceExpiry,omitempty" db:"insurance_expiry"`
+	RoadworthyExpiry *time.Time  `json:"roadworthyExpiry,omitempty" db:"roadworthy_expiry"`
+	IsActive         bool        `json:"isActive" db:"is_active"`
+	CreatedAt        time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt        time.Time   `json:"updatedAt" db:"updated_at"`
+
+	// Computed when the vehicle is returned; not stored
+	Warnings []string `json:"warnings,omitempty" db:"-"`
+}
+
+// VehicleRequest is the body for registering a vehicle
+type VehicleRequest struct {
+	Type             VehicleType `json:"type"`
+	Plate            string      `json:"plate"`
+	Description      string      `json:"description,omitempty"`
+	CapacityKg       float64     `json:"capacityKg"`
+	CapacityLitres   float64     `json:"capacityLitres"`
+	InsuranceExpiry  string      `json:"insuranceExpiry,omitempty"`  // YYYY-MM-DD
+	RoadworthyExpiry string      `json:"roadworthyExpiry,omitempty"` // YYYY-MM-DD
+}
+
+// VehicleUpdateRequest is the body for updating a vehicle; only the fields present are changed
+type VehicleUpdateRequest struct {
+	Type             *VehicleType `json:"type,omitempty"`
+	Plate            *string      `json:"plate,omitempty"`
+	Description      *string      `json:"description,omitempty"`
+	CapacityKg       *float64     `json:"capacityKg,omitempty"`
+	CapacityLitres   *float64     `json:"capacityLitres,omitempty"`
+	InsuranceExpiry  *string      `json:"insuranceExpiry,omitempty"`  // YYYY-MM-DD, or empty to clear
+	RoadworthyExpiry *string      `json:"roadworthyExpiry,omitempty"` // YYYY-MM-DD, or empty to clear
+	IsActive         *bool        `json:"isActive,omitempty"`
+}
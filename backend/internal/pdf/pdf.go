@@ -0,0 +1,175 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Font is one of the standard fonts every PDF reader provides, so nothing needs to be embedded
+type Font string
+
+const (
+	Helvetica     Font = "F1"
+	HelveticaBold Font = "F2"
+)
+
+// Fixed object numbers; pages and their contents are numbered from firstFreeObject
+const (
+	catalogObject = iota + 1
+	pagesObject
+	fontObject
+	boldFontObject
+	firstFreeObject
+)
+
+// Info is the document metadata written when the document is closed
+type Info struct {
+	Title    string
+	Producer string
+	Created  time.Time
+}
+
+// Writer writes a PDF document page by page. Each page is written out as soon as the next one is
+// started; only the page object numbers are kept until Close, so documents of any length stream.
+type Writer struct {
+	out     *countingWriter
+	offsets map[int]int64
+	nextID  int
+	pageIDs []int
+	page    *Page
+}
+
+// Page is a page being drawn. Coordinates are in points from the bottom left corner.
+type Page struct {
+	Width, Height float64
+	content       bytes.Buffer
+}
+
+// NewWriter starts a document on w
+func NewWriter(w io.Writer) *Writer {
+	pw := &Writer{
+		out:     &countingWriter{w: w},
+		offsets: map[int]int64{},
+		nextID:  firstFreeObject,
+	}
+	// The second line marks the file as binary for transfer tools
+	io.WriteString(pw.out, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	pw.writeObject(fontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	pw.writeObject(boldFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	return pw
+}
+
+// AddPage writes out the current page, if any, and starts a new one
+func (w *Writer) AddPage(width, height float64) *Page {
+	w.flushPage()
+	w.page = &Page{Width: width, Height: height}
+	return w.page
+}
+
+// Err returns the first error writing to the underlying writer
+func (w *Writer) Err() error {
+	return w.out.err
+}
+
+// Close writes out the last page, the page tree and the cross-reference table
+func (w *Writer) Close(info Info) error {
+	w.flushPage()
+
+	kids := make([]string, len(w.pageIDs))
+	for i, id := range w.pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	w.writeObject(pagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	w.writeObject(catalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject))
+	if info.Created.IsZero() {
+		info.Created = time.Now()
+	}
+	infoID := w.allocate()
+	w.writeObject(infoID, fmt.Sprintf("<< /Title %s /Producer %s /CreationDate (D:%s) >>",
+		literal(info.Title), literal(info.Producer), info.Created.UTC().Format("20060102150405Z")))
+
+	xref := w.out.n
+	fmt.Fprintf(w.out, "xref\n0 %d\n0000000000 65535 f \n", w.nextID)
+	for id := 1; id < w.nextID; id++ {
+		fmt.Fprintf(w.out, "%010d 00000 n \n", w.offsets[id])
+	}
+	fmt.Fprintf(w.out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		w.nextID, catalogObject, infoID, xref)
+	return w.out.err
+}
+
+// flushPage writes the current page's compressed content stream and page object
+func (w *Writer) flushPage() {
+	if w.page == nil {
+		return
+	}
+	var content bytes.Buffer
+	zw := zlib.NewWriter(&content)
+	zw.Write(w.page.content.Bytes())
+	zw.Close()
+
+	contentID := w.allocate()
+	w.offsets[contentID] = w.out.n
+	fmt.Fprintf(w.out, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", contentID, content.Len())
+	w.out.Write(content.Bytes())
+	io.WriteString(w.out, "\nendstream\nendobj\n")
+
+	pageID := w.allocate()
+	w.writeObject(pageID, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+		pagesObject, w.page.Width, w.page.Height, fontObject, boldFontObject, contentID))
+	w.pageIDs = append(w.pageIDs, pageID)
+	w.page = nil
+}
+
+func (w *Writer) allocate() int {
+	id := w.nextID
+	w.nextID++
+	return id
+}
+
+func (w *Writer) writeObject(id int, body string) {
+	w.offsets[id] = w.out.n
+	fmt.Fprintf(w.out, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// Text draws text with its baseline starting at (x, y)
+func (p *Page) Text(font Font, size, x, y float64, text string) {
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, y, literal(text))
+}
+
+// Line draws a straight line
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// FillRect draws a filled black rectangle with its bottom left corner at (x, y)
+func (p *Page) FillRect(x, y, width, height float64) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f %.3f re f\n", x, y, width, height)
+}
+
+// StrokeRect draws the outline of a rectangle with its bottom left corner at (x, y)
+func (p *Page) StrokeRect(x, y, width, height, lineWidth float64) {
+	fmt.Fprintf(&p.content, "%.2f w %.2f %.2f %.2f %.2f re S\n", lineWidth, x, y, width, height)
+}
+
+// countingWriter tracks the byte offset needed for the cross-reference table and keeps the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,121 @@
+package pdf
+
+import "strings"
+
+// TextWidth measures text in points using the standard Helvetica metrics
+func TextWidth(text string, font Font, size float64) float64 {
+	metrics := &helveticaWidths
+	if font == HelveticaBold {
+		metrics = &helveticaBoldWidths
+	}
+	var units int
+	for _, b := range winAnsi(text) {
+		if b >= 32 && b <= 126 {
+			units += metrics[b-32]
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// FitText shortens text with "..." until it fits the width
+func FitText(text string, font Font, size, width float64) string {
+	if TextWidth(text, font, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimRight(string(runes), " ") + "..."
+		if TextWidth(candidate, font, size) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// WrapText breaks text into lines that fit the width, returning at most maxLines lines.
+// When the text does not fit, the last line is shortened with "...".
+func WrapText(text string, font Font, size, width float64, maxLines int) []string {
+	var lines []string
+	line := ""
+	words := strings.Fields(text)
+	for i, word := range words {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if TextWidth(candidate, font, size) <= width || line == "" {
+			line = candidate
+			continue
+		}
+		if len(lines) == maxLines-1 {
+			return append(lines, FitText(line+" "+strings.Join(words[i:], " "), font, size, width))
+		}
+		lines = append(lines, line)
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, FitText(line, font, size, width))
+	}
+	return lines
+}
+
+// literal encodes text as a PDF literal string in WinAnsiEncoding
+func literal(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range winAnsi(text) {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// winAnsiExtras maps the characters of WinAnsiEncoding outside Latin-1 that commonly appear in names and prices
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+// winAnsi converts text to WinAnsiEncoding, replacing characters the standard fonts cannot show with "?"
+func winAnsi(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 32 || r == 127:
+			out = append(out, ' ')
+		case r < 127 || (r >= 160 && r <= 255):
+			out = append(out, byte(r))
+		default:
+			if c, ok := winAnsiExtras[r]; ok {
+				out = append(out, c)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// Advance widths of characters 32-126, in thousandths of the font size (Adobe AFM metrics)
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
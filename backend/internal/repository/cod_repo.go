@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+var (
+	// ErrCODStatusChanged is returned when an order's cash-on-delivery status no longer allows the update
+	ErrCODStatusChanged = errors.New("cash-on-delivery status changed concurrently")
+	// ErrRemittancePending is returned when the courier already has an unconfirmed remittance for the store
+	ErrRemittancePending = errors.New("a remittance for this store is already waiting for confirmation")
+	// ErrNothingToRemit is returned when the courier holds no collected cash for the store
+	ErrNothingToRemit = errors.New("no collected cash to remit for this store")
+	// ErrRemittanceNotFound is returned when a remittance does not exist or belongs to another store
+	ErrRemittanceNotFound = errors.New("remittance not found")
+	// ErrRemittanceDecided is returned when a remittance is no longer waiting for confirmation
+	ErrRemittanceDecided = errors.New("remittance has already been decided")
+)
+
+// CODRepository handles cash-on-delivery collections, courier cash liability and remittances
+type CODRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewCODRepository creates a new cash-on-delivery repository
+func NewCODRepository(db *pgxpool.Pool) *CODRepository {
+	return &CODRepository{db: db}
+}
+
+const remittanceColumns = `
+	id, courier_id, store_id, amount, order_count, method,
+	COALESCE(reference, '') as reference, COALESCE(note, '') as note, status,
+	COALESCE(decision_note, '') as decision_note, decided_at, created_at
+`
+
+// Collect records the cash a driver collected on an order and adds it to the courier's cash
+// liability in one transaction. The order's COD must still be pending, so cash is only counted once.
+func (r *CODRepository) Collect(ctx context.Context, orderID, courierID uuid.UUID, amount float64, note, currency string) (*time.Time, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	result, err := tx.Exec(ctx, `
+		UPDATE orders SET
+			cod_status = 'collected',
+			cod_collected = $2,
+			cod_note = NULLIF($3, ''),
+			cod_collected_at = $4,
+			updated_at = $4
+		WHERE id = $1 AND cod_status = 'pending'
+	`, orderID, amount, note, now)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrCODStatusChanged
+	}
+
+	if err := addCashLiability(ctx, tx, courierID, amount, currency); err != nil {
+		return nil, err
+	}
+
+	return &now, tx.Commit(ctx)
+}
+
+// Reverse undoes an order's collection when the parcel was not handed over: the COD goes back to
+// pending (re-attempt) or void (return, failure, cancellation) and any collected cash is taken off the
+// courier's liability. Orders already in a remittance are left unchanged and ErrCODStatusChanged is returned.
+func (r *CODRepository) Reverse(ctx context.Context, orderID uuid.UUID, to models.CODStatus) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var courierID uuid.UUID
+	var status models.CODStatus
+	var collected float64
+	err = tx.QueryRow(ctx, `
+		SELECT courier_id, COALESCE(cod_status, ''), cod_collected FROM orders WHERE id = $1 FOR UPDATE
+	`, orderID).Scan(&courierID, &status, &collected)
+	if err != nil {
+		return err
+	}
+	if status != models.CODStatusPending && status != models.CODStatusCollected {
+		return ErrCODStatusChanged
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE orders SET
+			cod_status = $2,
+			cod_collected = 0,
+			cod_note = NULL,
+			cod_collected_at = NULL,
+			updated_at = NOW()
+		WHERE id = $1
+	`, orderID, to)
+	if err != nil {
+		return err
+	}
+
+	if status == models.CODStatusCollected && collected != 0 {
+		if _, err := tx.Exec(ctx, `
+			UPDATE courier_wallets SET cash_liability = cash_liability - $2, updated_at = NOW()
+			WHERE courier_id = $1
+		`, courierID, collected); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CreateRemittance moves all cash the courier collected on the store's delivered orders into a new
+// pending remittance and fills in its amount and order count. A courier has at most one pending
+// remittance per store (ErrRemittancePending) and it must cover at least one order (ErrNothingToRemit).
+func (r *CODRepository) CreateRemittance(ctx context.Context, remittance *models.CODRemittance) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	remittance.ID = uuid.New()
+	remittance.Status = models.RemittanceStatusPending
+	remittance.CreatedAt = time.Now()
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO cod_remittances (id, courier_id, store_id, method, reference, note, status, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8)
+		ON CONFLICT (courier_id, store_id) WHERE status = 'pending' DO NOTHING
+	`,
+		remittance.ID, remittance.CourierID, remittance.StoreID, remittance.Method,
+		remittance.Reference, remittance.Note, remittance.Status, remittance.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRemittancePending
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE orders SET cod_status = 'remitting', cod_remittance_id = $1, updated_at = $4
+		WHERE courier_id = $2 AND store_id = $3 AND status = 'delivered' AND cod_status = 'collected'
+		RETURNING id, cod_collected
+	`, remittance.ID, remittance.CourierID, remittance.StoreID, remittance.CreatedAt)
+	if err != nil {
+		return err
+	}
+	remittance.Amount = 0
+	remittance.OrderIDs = []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		var collected float64
+		if err := rows.Scan(&id, &collected); err != nil {
+			rows.Close()
+			return err
+		}
+		remittance.OrderIDs = append(remittance.OrderIDs, id)
+		remittance.Amount += collected
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(remittance.OrderIDs) == 0 {
+		return ErrNothingToRemit
+	}
+	remittance.OrderCount = len(remittance.OrderIDs)
+
+	if _, err := tx.Exec(ctx, `UPDATE cod_remittances SET amount = $2, order_count = $3 WHERE id = $1`,
+		remittance.ID, remittance.Amount, remittance.OrderCount); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetRemittance retrieves a remittance with the orders it covers. Rejected remittances release their
+// orders, so they come back without any.
+func (r *CODRepository) GetRemittance(ctx context.Context, id uuid.UUID) (*models.CODRemittance, error) {
+	query := `SELECT ` + remittanceColumns + ` FROM cod_remittances WHERE id = $1`
+	remittance, err := scanRemittance(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRemittanceNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT id FROM orders WHERE cod_remittance_id = $1 ORDER BY created_at`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	remittance.OrderIDs = []uuid.UUID{}
+	for rows.Next() {
+		var orderID uuid.UUID
+		if err := rows.Scan(&orderID); err != nil {
+			return nil, err
+		}
+		remittance.OrderIDs = append(remittance.OrderIDs, orderID)
+	}
+	return remittance, rows.Err()
+}
+
+// ListRemittances returns a courier's or a store's remittances, newest first, without their order IDs
+func (r *CODRepository) ListRemittances(ctx context.Context, courierID, storeID *uuid.UUID, status string, limit, offset int) ([]models.CODRemittance, error) {
+	query := `
+		SELECT ` + remittanceColumns + ` FROM cod_remittances
+		WHERE ($1::uuid IS NULL OR courier_id = $1)
+			AND ($2::uuid IS NULL OR store_id = $2)
+			AND ($3 = '' OR status = $3)
+		ORDER BY created_at DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	rows, err := r.db.Query(ctx, query, courierID, storeID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	remittances := []models.CODRemittance{}
+	for rows.Next() {
+		remittance, err := scanRemittance(rows)
+		if err != nil {
+			return nil, err
+		}
+		remittances = append(remittances, *remittance)
+	}
+	return remittances, rows.Err()
+}
+
+// DecideRemittance confirms or rejects a pending remittance of the store. Confirming marks its orders
+// remitted and clears the cash from the courier's liability; rejecting puts the orders back with the
+// courier so they can be remitted again.
+func (r *CODRepository) DecideRemittance(ctx context.Context, id, storeID uuid.UUID, status models.RemittanceStatus, note string) (*models.CODRemittance, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT ` + remittanceColumns + ` FROM cod_remittances WHERE id = $1 AND store_id = $2 FOR UPDATE`
+	remittance, err := scanRemittance(tx.QueryRow(ctx, query, id, storeID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRemittanceNotFound
+		}
+		return nil, err
+	}
+	if remittance.Status != models.RemittanceStatusPending {
+		return nil, ErrRemittanceDecided
+	}
+
+	now := time.Now()
+	remittance.Status = status
+	remittance.DecisionNote = note
+	remittance.DecidedAt = &now
+	if _, err := tx.Exec(ctx, `
+		UPDATE cod_remittances SET status = $2, decision_note = NULLIF($3, ''), decided_at = $4 WHERE id = $1
+	`, id, status, note, now); err != nil {
+		return nil, err
+	}
+
+	if status == models.RemittanceStatusConfirmed {
+		if _, err := tx.Exec(ctx, `
+			UPDATE orders SET cod_status = 'remitted', updated_at = $2
+			WHERE cod_remittance_id = $1 AND cod_status = 'remitting'
+		`, id, now); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE courier_wallets SET cash_liability = cash_liability - $2, updated_at = $3
+			WHERE courier_id = $1
+		`, remittance.CourierID, remittance.Amount, now); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := tx.Exec(ctx, `
+			UPDATE orders SET cod_status = 'collected', cod_remittance_id = NULL, updated_at = $2
+			WHERE cod_remittance_id = $1 AND cod_status = 'remitting'
+		`, id, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return remittance, tx.Commit(ctx)
+}
+
+// OutstandingByStore totals the collected cash a courier has not put in a remittance yet, per store
+func (r *CODRepository) OutstandingByStore(ctx context.Context, courierID uuid.UUID) ([]models.CODStoreBalance, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT store_id, COUNT(*), COALESCE(SUM(cod_collected), 0)
+		FROM orders
+		WHERE courier_id = $1 AND cod_status = 'collected' AND store_id IS NOT NULL
+		GROUP BY store_id
+		ORDER BY store_id
+	`, courierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []models.CODStoreBalance{}
+	for rows.Next() {
+		var b models.CODStoreBalance
+		if err := rows.Scan(&b.StoreID, &b.Orders, &b.Amount); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// SettlementLines returns the store's cash-on-delivery orders created in [from, to), oldest first
+func (r *CODRepository) SettlementLines(ctx context.Context, storeID uuid.UUID, from, to time.Time) ([]models.CODSettlementLine, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, order_number, COALESCE(external_order_id, ''), courier_id, status,
+			COALESCE(cod_status, ''), cod_amount, cod_collected, cod_collected_at, cod_remittance_id, created_at
+		FROM orders
+		WHERE store_id = $1 AND cod_amount > 0 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at
+	`, storeID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.CODSettlementLine{}
+	for rows.Next() {
+		var l models.CODSettlementLine
+		if err := rows.Scan(
+			&l.OrderID, &l.OrderNumber, &l.ExternalOrderID, &l.CourierID, &l.OrderStatus,
+			&l.CODStatus, &l.Expected, &l.Collected, &l.CollectedAt, &l.RemittanceID, &l.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// addCashLiability adds collected cash to a courier's liability, creating the wallet if needed
+func addCashLiability(ctx context.Context, tx pgx.Tx, courierID uuid.UUID, amount float64, currency string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO courier_wallets (courier_id, available_balance, pending_balance, total_earnings, cash_liability, currency, updated_at)
+		VALUES ($1, 0, 0, 0, $2, $3, NOW())
+		ON CONFLICT (courier_id) DO UPDATE SET
+			cash_liability = courier_wallets.cash_liability + EXCLUDED.cash_liability,
+			updated_at = NOW()
+	`, courierID, amount, currency)
+	return err
+}
+
+func scanRemittance(row pgx.Row) (*models.CODRemittance, error) {
+	var remittance models.CODRemittance
+	err := row.Scan(
+		&remittance.ID,
+		&remittance.CourierID,
+		&remittance.StoreID,
+		&remittance.Amount,
+		&remittance.OrderCount,
+		&remittance.Method,
+		&remittance.Reference,
+		&remittance.Note,
+		&remittance.Status,
+		&remittance.DecisionNote,
+		&remittance.DecidedAt,
+		&remittance.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &remittance, nil
+}
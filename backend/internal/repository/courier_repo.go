@@ -51,6 +51,8 @@ func (r *CourierRepository) Create(ctx context.Context, courier *models.Courier)
 		courier.IsActive,
 		courier.CreatedAt,
 		courier.UpdatedAt,
+		courier.OperatingHours,
+		courier.Timezone,
 	)
 
 	return err
@@ -61,9 +63,9 @@ func (r *CourierRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.
 	query := `
 		SELECT id, email, company_name, owner_name, phone, alternate_phone, whatsapp,
 			address, city, country, logo_url, description, service_areas, vehicle_types,
-			max_weight, base_rate_per_km, minimum_fare, custom_pricing, rating, total_reviews,
-			total_deliveries, success_rate, is_verified, is_active, is_featured, wallet_balance,
-			created_at, updated_at, last_active_at
+			max_weight, COALESCE(operating_hours, '{}'::jsonb), COALESCE(timezone, ''), base_rate_per_km,
+			minimum_fare, custom_pricing, rating, total_reviews, total_deliveries, success_rate, is_verified,
+			is_active, is_featured, wallet_balance, created_at, updated_at, last_active_at
 		FROM couriers WHERE id = $1
 	`
 
@@ -84,6 +86,8 @@ func (r *CourierRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.
 		&courier.ServiceAreas,
 		&courier.VehicleTypes,
 		&courier.MaxWeight,
+		&courier.OperatingHours,
+		&courier.Timezone,
 		&courier.BaseRatePerKm,
 		&courier.MinimumFare,
 		&courier.CustomPricing,
@@ -140,7 +144,7 @@ func (r *CourierRepository) Update(ctx context.Context, courier *models.Courier)
 			company_name = $2, owner_name = $3, phone = $4, alternate_phone = $5,
 			whatsapp = $6, address = $7, city = $8, logo_url = $9, description = $10,
 			service_areas = $11, vehicle_types = $12, max_weight = $13, base_rate_per_km = $14,
-			minimum_fare = $15, updated_at = $16
+			minimum_fare = $15, updated_at = $16, operating_hours = $17, timezone = NULLIF($18, '')
 		WHERE id = $1
 	`
 
@@ -163,6 +167,8 @@ func (r *CourierRepository) Update(ctx context.Context, courier *models.Courier)
 		courier.BaseRatePerKm,
 		courier.MinimumFare,
 		courier.UpdatedAt,
+		courier.OperatingHours,
+		courier.Timezone,
 	)
 
 	return err
@@ -248,6 +254,70 @@ func (r *CourierRepository) ListByArea(ctx context.Context, area string) ([]mode
 	return couriers, nil
 }
 
+// ListDispatchCandidates returns the active couriers with the fields the dispatcher scores them on
+func (r *CourierRepository) ListDispatchCandidates(ctx context.Context) ([]models.Courier, error) {
+	query := `
+		SELECT id, company_name, COALESCE(service_areas, '{}'), COALESCE(vehicle_types, '{}'),
+			COALESCE(max_weight, 0), COALESCE(rating, 0), COALESCE(total_reviews, 0), is_verified, is_active
+		FROM couriers
+		WHERE is_active = true
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var couriers []models.Courier
+	for rows.Next() {
+		var c models.Courier
+		err := rows.Scan(
+			&c.ID,
+			&c.CompanyName,
+			&c.ServiceAreas,
+			&c.VehicleTypes,
+			&c.MaxWeight,
+			&c.Rating,
+			&c.TotalReviews,
+			&c.IsVerified,
+			&c.IsActive,
+		)
+		if err != nil {
+			return nil, err
+		}
+		couriers = append(couriers, c)
+	}
+	return couriers, rows.Err()
+}
+
+// ListSchedules returns the active couriers with the fields their operating hours are checked with
+func (r *CourierRepository) ListSchedules(ctx context.Context) ([]models.Courier, error) {
+	query := `
+		SELECT id, COALESCE(country, ''), COALESCE(operating_hours, '{}'::jsonb), COALESCE(timezone, '')
+		FROM couriers
+		WHERE is_active = true
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var couriers []models.Courier
+	for rows.Next() {
+		var c models.Courier
+		if err := rows.Scan(&c.ID, &c.Country, &c.OperatingHours, &c.Timezone); err != nil {
+			return nil, err
+		}
+		couriers = append(couriers, c)
+	}
+	return couriers, rows.Err()
+}
+
 // UpdateLastActive updates the courier's last active timestamp
 func (r *CourierRepository) UpdateLastActive(ctx context.Context, id uuid.UUID) error {
 	query := `UPDATE couriers SET last_active_at = $2 WHERE id = $1`
@@ -256,19 +326,39 @@ func (r *CourierRepository) UpdateLastActive(ctx context.Context, id uuid.UUID)
 }
 
 // UpdateStats updates courier statistics
-func (r *CourierRepository) UpdateStats(ctx context.Context, id uuid.UUID, totalDeliveries int, successRate, rating float64) error {
+func (r *CourierRepository) UpdateStats(ctx context.Context, id uuid.UUID, stats *models.CourierStats) error {
 	query := `
 		UPDATE couriers SET
 			total_deliveries = $2,
 			success_rate = $3,
 			rating = $4,
-			updated_at = $5
+			total_reviews = $5,
+			updated_at = $6
 		WHERE id = $1
 	`
-	_, err := r.db.Exec(ctx, query, id, totalDeliveries, successRate, rating, time.Now())
+	_, err := r.db.Exec(ctx, query, id, stats.TotalDeliveries, stats.SuccessRate, stats.Rating, stats.TotalReviews, time.Now())
 	return err
 }
 
+// ListIDs returns the IDs of all couriers
+func (r *CourierRepository) ListIDs(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `SELECT id FROM couriers`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // UpdateWalletBalance updates the courier's wallet balance
 func (r *CourierRepository) UpdateWalletBalance(ctx context.Context, id uuid.UUID, amount float64) error {
 	query := `UPDATE couriers SET wallet_balance = wallet_balance + $2, updated_at = $3 WHERE id = $1`
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+)
+
+var (
+	// ErrAttemptAlreadyRecorded is returned when another request recorded the same attempt first
+	ErrAttemptAlreadyRecorded = errors.New("delivery attempt was already recorded")
+	// ErrReturnLegExists is returned when an order already has a return-to-sender leg
+	ErrReturnLegExists = errors.New("order already has a return leg")
+)
+
+// RecordDeliveryAttempt logs a failed attempt and updates the order's attempt counter and
+// re-attempt time in one transaction. The counter must still be one below the attempt number,
+// so each attempt is only counted once.
+func (r *OrderRepository) RecordDeliveryAttempt(ctx context.Context, attempt *models.DeliveryAttempt) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	attempt.ID = uuid.New()
+	attempt.CreatedAt = time.Now()
+
+	result, err := tx.Exec(ctx, `
+		UPDATE orders SET
+			delivery_attempts = $2,
+			next_attempt_at = $3,
+			reattempt_reminder_sent_at = NULL,
+			updated_at = $4
+		WHERE id = $1 AND COALESCE(delivery_attempts, 0) = $2 - 1
+	`, attempt.OrderID, attempt.AttemptNumber, attempt.NextAttemptAt, attempt.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAttemptAlreadyRecorded
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO delivery_attempts (
+			id, order_id, courier_id, attempt_number, reason, note, outcome,
+			next_attempt_at, return_order_id, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`,
+		attempt.ID, attempt.OrderID, attempt.CourierID, attempt.AttemptNumber, attempt.Reason, attempt.Note,
+		attempt.Outcome, attempt.NextAttemptAt, attempt.ReturnOrderID, attempt.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ListDeliveryAttempts returns an order's failed attempts, oldest first
+func (r *OrderRepository) ListDeliveryAttempts(ctx context.Context, orderID uuid.UUID) ([]models.DeliveryAttempt, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, order_id, courier_id, attempt_number, reason, COALESCE(note, '') as note, outcome,
+			next_attempt_at, return_order_id, created_at
+		FROM delivery_attempts
+		WHERE order_id = $1
+		ORDER BY attempt_number
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.DeliveryAttempt{}
+	for rows.Next() {
+		var a models.DeliveryAttempt
+		if err := rows.Scan(
+			&a.ID, &a.OrderID, &a.CourierID, &a.AttemptNumber, &a.Reason, &a.Note, &a.Outcome,
+			&a.NextAttemptAt, &a.ReturnOrderID, &a.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// DeleteReturnLeg removes a return leg that was created for an order that could not be moved
+// to returning, and unlinks it from that order
+func (r *OrderRepository) DeleteReturnLeg(ctx context.Context, returnOrderID, originalOrderID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE orders SET return_order_id = NULL WHERE id = $1 AND return_order_id = $2`,
+		originalOrderID, returnOrderID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM orders WHERE id = $1 AND return_of_order_id = $2`, returnOrderID, originalOrderID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ClaimReattemptReminders marks and returns orders whose re-attempt is due before remindBefore
+// and whose courier has not been reminded yet (see ClaimPickupReminders)
+func (r *OrderRepository) ClaimReattemptReminders(ctx context.Context, remindBefore time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		UPDATE orders SET reattempt_reminder_sent_at = NOW()
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE status = 'reattempt_scheduled'
+				AND next_attempt_at <= $1
+				AND reattempt_reminder_sent_at IS NULL
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`
+	return r.claimIDs(ctx, query, remindBefore, limit)
+}
@@ -137,6 +137,24 @@ func (r *DeliveryRepository) Complete(ctx context.Context, orderID uuid.UUID) er
 	return err
 }
 
+// Reassign moves an order's active tracking record to another courier and driver
+func (r *DeliveryRepository) Reassign(ctx context.Context, orderID uuid.UUID, tracking *models.DeliveryTracking) error {
+	query := `
+		UPDATE delivery_tracking SET
+			courier_id = $2,
+			driver_name = $3,
+			driver_phone = $4,
+			vehicle_type = $5,
+			vehicle_plate = $6,
+			updated_at = $7
+		WHERE order_id = $1 AND is_active = true
+	`
+
+	_, err := r.db.Exec(ctx, query, orderID, tracking.CourierID, tracking.DriverName, tracking.DriverPhone,
+		tracking.VehicleType, tracking.VehiclePlate, time.Now())
+	return err
+}
+
 // GetActiveDeliveriesForCourier gets all active deliveries for a courier
 func (r *DeliveryRepository) GetActiveDeliveriesForCourier(ctx context.Context, courierID uuid.UUID) ([]models.DeliveryTracking, error) {
 	query := `
@@ -178,6 +196,34 @@ func (r *DeliveryRepository) GetActiveDeliveriesForCourier(ctx context.Context,
 	return deliveries, nil
 }
 
+// ListCourierPositions returns the latest position of each courier with an active delivery,
+// ignoring fixes older than since
+func (r *DeliveryRepository) ListCourierPositions(ctx context.Context, since time.Time) (map[uuid.UUID]models.LocationPoint, error) {
+	query := `
+		SELECT DISTINCT ON (courier_id) courier_id, current_latitude, current_longitude, last_location_at
+		FROM delivery_tracking
+		WHERE is_active = true AND last_location_at >= $1
+		ORDER BY courier_id, last_location_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := map[uuid.UUID]models.LocationPoint{}
+	for rows.Next() {
+		var courierID uuid.UUID
+		var p models.LocationPoint
+		if err := rows.Scan(&courierID, &p.Latitude, &p.Longitude, &p.Timestamp); err != nil {
+			return nil, err
+		}
+		positions[courierID] = p
+	}
+	return positions, rows.Err()
+}
+
 // SaveLocationHistory saves a location point to history
 func (r *DeliveryRepository) SaveLocationHistory(ctx context.Context, trackingID uuid.UUID, point models.LocationPoint) error {
 	query := `
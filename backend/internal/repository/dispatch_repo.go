@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// ErrOfferChanged is returned when an order's offer was accepted, declined or moved on by another request
+var ErrOfferChanged = errors.New("dispatch offer has changed")
+
+// DispatchRepository handles the automatic dispatch audit log
+type DispatchRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewDispatchRepository creates a new dispatch log repository
+func NewDispatchRepository(db *pgxpool.Pool) *DispatchRepository {
+	return &DispatchRepository{db: db}
+}
+
+// AddEntries appends entries to an order's dispatch log
+func (r *DispatchRepository) AddEntries(ctx context.Context, entries []models.DispatchLogEntry) error {
+	batch := &pgx.Batch{}
+	for i := range entries {
+		e := &entries[i]
+		e.ID = uuid.New()
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = time.Now()
+		}
+
+		var factorsJSON []byte
+		if len(e.Factors) > 0 {
+			var err error
+			if factorsJSON, err = json.Marshal(e.Factors); err != nil {
+				return err
+			}
+		}
+
+		batch.Queue(`
+			INSERT INTO dispatch_log (
+				id, order_id, round, courier_id, decision, score, distance_km, reason, factors, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, e.ID, e.OrderID, e.Round, e.CourierID, e.Decision, e.Score, e.DistanceKm, e.Reason, factorsJSON, e.CreatedAt)
+	}
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+// ListByOrder returns an order's dispatch log, oldest first
+func (r *DispatchRepository) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]models.DispatchLogEntry, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, order_id, round, courier_id, decision, score::float8, distance_km::float8,
+			COALESCE(reason, '') as reason, factors, created_at
+		FROM dispatch_log
+		WHERE order_id = $1
+		ORDER BY created_at, round, score DESC NULLS LAST
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.DispatchLogEntry{}
+	for rows.Next() {
+		var e models.DispatchLogEntry
+		var factorsJSON []byte
+		if err := rows.Scan(
+			&e.ID, &e.OrderID, &e.Round, &e.CourierID, &e.Decision, &e.Score, &e.DistanceKm,
+			&e.Reason, &factorsJSON, &e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(factorsJSON, &e.Factors)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// CountOpenOrdersByCourier returns how many orders each courier has accepted or been offered
+// and not yet finished
+func (r *OrderRepository) CountOpenOrdersByCourier(ctx context.Context) (map[uuid.UUID]int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT courier_id, COUNT(*)
+		FROM orders
+		WHERE courier_id IS NOT NULL
+			AND status IN ('pending', 'accepted', 'picked_up', 'in_transit', 'reattempt_scheduled')
+		GROUP BY courier_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[uuid.UUID]int{}
+	for rows.Next() {
+		var courierID uuid.UUID
+		var count int
+		if err := rows.Scan(&courierID, &count); err != nil {
+			return nil, err
+		}
+		counts[courierID] = count
+	}
+	return counts, rows.Err()
+}
+
+// StartDispatch marks a newly created order as automatically dispatched and offered to its courier
+func (r *OrderRepository) StartDispatch(ctx context.Context, id uuid.UUID, area string, expiresAt time.Time) error {
+	query := `
+		UPDATE orders SET
+			dispatch_mode = 'auto',
+			dispatch_status = 'offered',
+			dispatch_area = NULLIF($2, ''),
+			offer_expires_at = $3
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, area, expiresAt)
+	return err
+}
+
+// StartBroadcast marks a newly created, unassigned order as broadcast to every eligible courier
+func (r *OrderRepository) StartBroadcast(ctx context.Context, id uuid.UUID, area string, expiresAt time.Time) error {
+	query := `
+		UPDATE orders SET
+			dispatch_mode = 'broadcast',
+			dispatch_status = 'offered',
+			dispatch_area = NULLIF($2, ''),
+			offer_expires_at = $3
+		WHERE id = $1 AND courier_id IS NULL
+	`
+	_, err := r.db.Exec(ctx, query, id, area, expiresAt)
+	return err
+}
+
+// ClaimBroadcast gives a broadcast order to the first courier to accept it. Only one claim can
+// succeed: the update only applies while the order is still unassigned, pending and on offer;
+// otherwise ErrOfferChanged is returned.
+func (r *OrderRepository) ClaimBroadcast(ctx context.Context, id, courierID uuid.UUID) error {
+	return r.assignUnassigned(ctx, id, courierID, `AND dispatch_status = 'offered'`)
+}
+
+// AssignUnassigned gives a pending order without a courier to the given courier, whether or not its
+// broadcast is still running. ErrOfferChanged is returned if a courier already has it.
+func (r *OrderRepository) AssignUnassigned(ctx context.Context, id, courierID uuid.UUID) error {
+	return r.assignUnassigned(ctx, id, courierID, "")
+}
+
+func (r *OrderRepository) assignUnassigned(ctx context.Context, id, courierID uuid.UUID, condition string) error {
+	query := `
+		UPDATE orders SET
+			courier_id = $2,
+			dispatch_status = 'assigned',
+			offer_expires_at = NULL,
+			updated_at = NOW()
+		WHERE id = $1 AND courier_id IS NULL AND status = 'pending' ` + condition
+	result, err := r.db.Exec(ctx, query, id, courierID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOfferChanged
+	}
+	return nil
+}
+
+// ExtendBroadcast gives a broadcast that nobody has accepted yet a new deadline.
+// ErrOfferChanged is returned if the order was accepted or cancelled in the meantime.
+func (r *OrderRepository) ExtendBroadcast(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	return r.updateBroadcast(ctx, id, `offer_expires_at = $2`, expiresAt)
+}
+
+// EndBroadcast closes a broadcast that nobody accepted, leaving the order unassigned for the store.
+// ErrOfferChanged is returned if the order was accepted or cancelled in the meantime.
+func (r *OrderRepository) EndBroadcast(ctx context.Context, id uuid.UUID) error {
+	return r.updateBroadcast(ctx, id, `dispatch_status = 'exhausted', offer_expires_at = NULL`)
+}
+
+func (r *OrderRepository) updateBroadcast(ctx context.Context, id uuid.UUID, set string, args ...interface{}) error {
+	query := `UPDATE orders SET ` + set + `
+		WHERE id = $1 AND courier_id IS NULL AND status = 'pending'
+			AND dispatch_mode = 'broadcast' AND dispatch_status = 'offered'`
+	result, err := r.db.Exec(ctx, query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOfferChanged
+	}
+	return nil
+}
+
+// ListBroadcastOffers returns the broadcast orders still open to the courier: offered to them,
+// not declined, and not yet accepted by anyone
+func (r *OrderRepository) ListBroadcastOffers(ctx context.Context, courierID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT o.id FROM orders o
+		WHERE o.courier_id IS NULL AND o.status = 'pending'
+			AND o.dispatch_mode = 'broadcast' AND o.dispatch_status = 'offered'
+			AND EXISTS (
+				SELECT 1 FROM dispatch_log d
+				WHERE d.order_id = o.id AND d.courier_id = $1 AND d.decision = 'offered'
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM dispatch_log d
+				WHERE d.order_id = o.id AND d.courier_id = $1 AND d.decision = 'declined'
+			)
+		ORDER BY o.created_at
+	`, courierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ReassignOffer moves a pending order's offer from one courier to the next. The update only applies
+// while the order is still pending and offered to from; otherwise ErrOfferChanged is returned.
+func (r *OrderRepository) ReassignOffer(ctx context.Context, id, from, to uuid.UUID, expiresAt time.Time) error {
+	query := `
+		UPDATE orders SET
+			courier_id = $3,
+			offer_expires_at = $4,
+			updated_at = NOW()
+		WHERE id = $1 AND courier_id = $2 AND status = 'pending' AND dispatch_status = 'offered'
+	`
+	result, err := r.db.Exec(ctx, query, id, from, to, expiresAt)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOfferChanged
+	}
+	return nil
+}
+
+// SetDispatchStatus records the outcome of an order's dispatch and clears its offer timeout
+func (r *OrderRepository) SetDispatchStatus(ctx context.Context, id uuid.UUID, status models.DispatchStatus) error {
+	query := `UPDATE orders SET dispatch_status = $2, offer_expires_at = NULL WHERE id = $1 AND dispatch_mode = 'auto'`
+	_, err := r.db.Exec(ctx, query, id, status)
+	return err
+}
+
+// ClaimExpiredOffers clears and returns the offers that timed out before the courier answered.
+// The timeout is cleared on claim, so each expired offer is only handled once.
+func (r *OrderRepository) ClaimExpiredOffers(ctx context.Context, now time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		UPDATE orders SET offer_expires_at = NULL
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE dispatch_status = 'offered' AND status = 'pending' AND offer_expires_at <= $1
+			ORDER BY offer_expires_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`
+	return r.claimIDs(ctx, query, now, limit)
+}
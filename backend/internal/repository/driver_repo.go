@@ -0,0 +1,442 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+var (
+	// ErrDriverNotFound is returned when a driver does not exist
+	ErrDriverNotFound = errors.New("driver not found")
+	// ErrDriverPhoneTaken is returned when another driver already signs in with the phone number
+	ErrDriverPhoneTaken = errors.New("phone number is already registered to a driver")
+	// ErrInviteInvalid is returned when an invite code is wrong, expired or already used
+	ErrInviteInvalid = errors.New("invite code is invalid or has expired")
+	// ErrInviteLocked is returned when activation is refused after too many wrong invite codes
+	ErrInviteLocked = errors.New("invite code entry is locked")
+	// ErrNoDriverAssignment is returned when an order is not assigned to any driver
+	ErrNoDriverAssignment = errors.New("order is not assigned to a driver")
+	// ErrDriverAssignmentClosed is returned when an assignment is no longer waiting for the driver
+	ErrDriverAssignmentClosed = errors.New("assignment is no longer waiting for the driver")
+	// ErrDriverAssignmentChanged is returned when the order was assigned to another driver concurrently
+	ErrDriverAssignmentChanged = errors.New("order was assigned to another driver concurrently")
+)
+
+// DriverRepository handles courier drivers and their order assignments
+type DriverRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewDriverRepository creates a new driver repository
+func NewDriverRepository(db *pgxpool.Pool) *DriverRepository {
+	return &DriverRepository{db: db}
+}
+
+const driverColumns = `
+	id, courier_id, name, phone, COALESCE(email, '') as email, COALESCE(password, '') as password,
+	COALESCE(license_number, '') as license_number, license_expiry, COALESCE(photo_url, '') as photo_url,
+	COALESCE(vehicle_type, '') as vehicle_type, COALESCE(vehicle_plate, '') as vehicle_plate, status,
+	COALESCE(invite_code_hash, '') as invite_code_hash, invite_expires_at, last_login_at, created_at, updated_at
+`
+
+const driverAssignmentColumns = `
+	id, order_id, driver_id, driver_name, driver_phone, COALESCE(vehicle_type, '') as vehicle_type,
+	COALESCE(vehicle_plate, '') as vehicle_plate, assigned_at, accepted_at, released_at, status,
+	COALESCE(note, '') as note
+`
+
+// Create registers a driver. ErrDriverPhoneTaken is returned if the phone number is already in use.
+func (r *DriverRepository) Create(ctx context.Context, driver *models.Driver) error {
+	query := `
+		INSERT INTO drivers (
+			id, courier_id, name, phone, email, license_number, license_expiry, photo_url,
+			vehicle_type, vehicle_plate, status, invite_code_hash, invite_expires_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''),
+			$11, $12, $13, $14, $14)
+		ON CONFLICT (phone) DO NOTHING
+	`
+
+	driver.ID = uuid.New()
+	driver.CreatedAt = time.Now()
+	driver.UpdatedAt = driver.CreatedAt
+
+	result, err := r.db.Exec(ctx, query,
+		driver.ID, driver.CourierID, driver.Name, driver.Phone, driver.Email, driver.LicenseNumber,
+		driver.LicenseExpiry, driver.PhotoURL, driver.VehicleType, driver.VehiclePlate, driver.Status,
+		driver.InviteCodeHash, driver.InviteExpiresAt, driver.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrDriverPhoneTaken
+	}
+	return nil
+}
+
+// GetByID retrieves a driver by ID
+func (r *DriverRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Driver, error) {
+	query := `SELECT ` + driverColumns + ` FROM drivers WHERE id = $1`
+	return scanDriver(r.db.QueryRow(ctx, query, id))
+}
+
+// GetByPhone retrieves the driver who signs in with the phone number
+func (r *DriverRepository) GetByPhone(ctx context.Context, phone string) (*models.Driver, error) {
+	query := `SELECT ` + driverColumns + ` FROM drivers WHERE phone = $1`
+	return scanDriver(r.db.QueryRow(ctx, query, phone))
+}
+
+// ListByCourier retrieves a courier's drivers by name, optionally only those with the status
+func (r *DriverRepository) ListByCourier(ctx context.Context, courierID uuid.UUID, status string) ([]models.Driver, error) {
+	query := `SELECT ` + driverColumns + ` FROM drivers
+		WHERE courier_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY name`
+
+	rows, err := r.db.Query(ctx, query, courierID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drivers := []models.Driver{}
+	for rows.Next() {
+		driver, err := scanDriver(rows)
+		if err != nil {
+			return nil, err
+		}
+		drivers = append(drivers, *driver)
+	}
+	return drivers, rows.Err()
+}
+
+// Update saves a driver's profile and status. ErrDriverPhoneTaken is returned if the new phone
+// number belongs to another driver.
+func (r *DriverRepository) Update(ctx context.Context, driver *models.Driver) error {
+	query := `
+		UPDATE drivers SET
+			name = $2, phone = $3, email = NULLIF($4, ''), license_number = NULLIF($5, ''), license_expiry = $6,
+			photo_url = NULLIF($7, ''), vehicle_type = NULLIF($8, ''), vehicle_plate = NULLIF($9, ''),
+			status = $10, updated_at = $11
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM drivers other WHERE other.phone = $3 AND other.id <> $1)
+	`
+
+	driver.UpdatedAt = time.Now()
+	result, err := r.db.Exec(ctx, query,
+		driver.ID, driver.Name, driver.Phone, driver.Email, driver.LicenseNumber, driver.LicenseExpiry,
+		driver.PhotoURL, driver.VehicleType, driver.VehiclePlate, driver.Status, driver.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrDriverPhoneTaken
+	}
+	return nil
+}
+
+// SetInvite replaces a driver's invite code, so only the newest code can be used, and resets the
+// activation attempts
+func (r *DriverRepository) SetInvite(ctx context.Context, id uuid.UUID, codeHash string, expiresAt time.Time) error {
+	query := `
+		UPDATE drivers SET
+			invite_code_hash = $2, invite_expires_at = $3, invite_attempts = 0, invite_locked_until = NULL,
+			updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, codeHash, expiresAt)
+	return err
+}
+
+// ClaimInviteAttempt counts an activation attempt for the driver with the phone number before the
+// code is checked, so parallel attempts cannot get past the limit. The attempt that reaches
+// maxAttempts locks further ones until the lockout has passed, when the count starts over.
+// ErrInviteInvalid is returned when the phone number has no invite, and ErrInviteLocked with the
+// lock expiry while activation is locked.
+func (r *DriverRepository) ClaimInviteAttempt(ctx context.Context, phone string, maxAttempts int, lockout time.Duration) (*time.Time, error) {
+	query := `
+		UPDATE drivers SET
+			invite_attempts = CASE WHEN invite_locked_until IS NULL THEN invite_attempts + 1 ELSE 1 END,
+			invite_locked_until = CASE
+				WHEN (CASE WHEN invite_locked_until IS NULL THEN invite_attempts + 1 ELSE 1 END) >= $2
+					THEN $3::timestamptz
+				ELSE NULL
+			END,
+			updated_at = $4
+		WHERE phone = $1 AND invite_code_hash IS NOT NULL
+			AND (invite_locked_until IS NULL OR invite_locked_until <= $4)
+		RETURNING invite_locked_until
+	`
+
+	now := time.Now()
+	var lockedUntil *time.Time
+	err := r.db.QueryRow(ctx, query, phone, maxAttempts, now.Add(lockout), now).Scan(&lockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = r.db.QueryRow(ctx,
+			`SELECT invite_locked_until FROM drivers WHERE phone = $1 AND invite_code_hash IS NOT NULL`, phone,
+		).Scan(&lockedUntil)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInviteInvalid
+		}
+		if err != nil {
+			return nil, err
+		}
+		return lockedUntil, ErrInviteLocked
+	}
+	return lockedUntil, err
+}
+
+// Activate sets the password of the driver with the phone number and an unexpired invite with the
+// code, clears the invite and makes the driver active. ErrInviteInvalid is returned otherwise;
+// suspended drivers cannot activate.
+func (r *DriverRepository) Activate(ctx context.Context, phone, codeHash, passwordHash string) (*models.Driver, error) {
+	query := `
+		UPDATE drivers SET
+			password = $3, status = 'active', invite_code_hash = NULL, invite_expires_at = NULL,
+			invite_attempts = 0, invite_locked_until = NULL, last_login_at = NOW(), updated_at = NOW()
+		WHERE phone = $1 AND invite_code_hash = $2 AND invite_expires_at > NOW() AND status <> 'suspended'
+		RETURNING ` + driverColumns
+
+	driver, err := scanDriver(r.db.QueryRow(ctx, query, phone, codeHash, passwordHash))
+	if errors.Is(err, ErrDriverNotFound) {
+		return nil, ErrInviteInvalid
+	}
+	return driver, err
+}
+
+// UpdateLastLogin records that a driver signed in
+func (r *DriverRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE drivers SET last_login_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// Assign gives an order to a driver, releasing the order's current assignment in the same
+// transaction. The released assignment is returned, or nil if the order had none.
+func (r *DriverRepository) Assign(ctx context.Context, assignment *models.DriverAssignment) (*models.DriverAssignment, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	previous, err := scanDriverAssignment(tx.QueryRow(ctx, `
+		UPDATE driver_assignments SET status = 'released', released_at = $2
+		WHERE order_id = $1 AND status IN ('pending', 'accepted')
+		RETURNING `+driverAssignmentColumns, assignment.OrderID, now))
+	if errors.Is(err, ErrNoDriverAssignment) {
+		previous = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	assignment.ID = uuid.New()
+	assignment.Status = models.DriverAssignmentPending
+	assignment.AssignedAt = now
+	result, err := tx.Exec(ctx, `
+		INSERT INTO driver_assignments (
+			id, order_id, driver_id, driver_name, driver_phone, vehicle_type, vehicle_plate, status, assigned_at
+		) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9)
+		ON CONFLICT (order_id) WHERE status IN ('pending', 'accepted') DO NOTHING
+	`,
+		assignment.ID, assignment.OrderID, assignment.DriverID, assignment.DriverName, assignment.DriverPhone,
+		assignment.VehicleType, assignment.VehiclePlate, assignment.Status, assignment.AssignedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrDriverAssignmentChanged
+	}
+
+	return previous, tx.Commit(ctx)
+}
+
+// Release ends an order's current assignment. ErrNoDriverAssignment is returned if it has none.
+func (r *DriverRepository) Release(ctx context.Context, orderID uuid.UUID) (*models.DriverAssignment, error) {
+	query := `
+		UPDATE driver_assignments SET status = 'released', released_at = NOW()
+		WHERE order_id = $1 AND status IN ('pending', 'accepted')
+		RETURNING ` + driverAssignmentColumns
+	return scanDriverAssignment(r.db.QueryRow(ctx, query, orderID))
+}
+
+// GetActiveAssignment retrieves the assignment of the driver currently working the order.
+// ErrNoDriverAssignment is returned if no driver is.
+func (r *DriverRepository) GetActiveAssignment(ctx context.Context, orderID uuid.UUID) (*models.DriverAssignment, error) {
+	query := `SELECT ` + driverAssignmentColumns + ` FROM driver_assignments
+		WHERE order_id = $1 AND status IN ('pending', 'accepted')`
+	return scanDriverAssignment(r.db.QueryRow(ctx, query, orderID))
+}
+
+// RespondAssignment records the driver's answer to a pending assignment of the order.
+// ErrDriverAssignmentClosed is returned if the driver has no pending assignment for it.
+func (r *DriverRepository) RespondAssignment(ctx context.Context, orderID, driverID uuid.UUID, status, note string) (*models.DriverAssignment, error) {
+	query := `
+		UPDATE driver_assignments SET
+			status = $3, note = NULLIF($4, ''),
+			accepted_at = CASE WHEN $3 = 'accepted' THEN NOW() ELSE accepted_at END
+		WHERE order_id = $1 AND driver_id = $2 AND status = 'pending'
+		RETURNING ` + driverAssignmentColumns
+
+	assignment, err := scanDriverAssignment(r.db.QueryRow(ctx, query, orderID, driverID, status, note))
+	if errors.Is(err, ErrNoDriverAssignment) {
+		return nil, ErrDriverAssignmentClosed
+	}
+	return assignment, err
+}
+
+// ListAssignments retrieves a driver's assignments, newest first, optionally only those with the status
+func (r *DriverRepository) ListAssignments(ctx context.Context, driverID uuid.UUID, status string) ([]models.DriverAssignment, error) {
+	query := `SELECT ` + driverAssignmentColumns + ` FROM driver_assignments
+		WHERE driver_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY assigned_at DESC
+		LIMIT 100`
+
+	rows, err := r.db.Query(ctx, query, driverID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []models.DriverAssignment{}
+	for rows.Next() {
+		assignment, err := scanDriverAssignment(rows)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, *assignment)
+	}
+	return assignments, rows.Err()
+}
+
+// GetEarnings sums the courier earnings of the orders the driver delivered, by delivery time
+func (r *DriverRepository) GetEarnings(ctx context.Context, driverID uuid.UUID, from, to *time.Time) (*models.DriverEarnings, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(o.courier_earnings), 0)
+		FROM driver_assignments da
+		JOIN orders o ON o.id = da.order_id
+		WHERE da.driver_id = $1 AND da.status IN ('pending', 'accepted') AND o.status = 'delivered'
+			AND ($2::timestamptz IS NULL OR o.actual_delivery >= $2)
+			AND ($3::timestamptz IS NULL OR o.actual_delivery <= $3)
+	`
+
+	earnings := &models.DriverEarnings{DriverID: driverID, From: from, To: to}
+	if err := r.db.QueryRow(ctx, query, driverID, from, to).Scan(&earnings.Deliveries, &earnings.Earnings); err != nil {
+		return nil, err
+	}
+	return earnings, nil
+}
+
+// GetPerformance aggregates delivery outcomes, timing and ratings across the orders assigned to the driver
+func (r *DriverRepository) GetPerformance(ctx context.Context, driverID uuid.UUID) (*models.DriverPerformance, error) {
+	query := `
+		SELECT
+			COUNT(o.id),
+			COUNT(o.id) FILTER (WHERE o.status = 'delivered'),
+			COUNT(o.id) FILTER (WHERE o.status = 'failed'),
+			COUNT(o.id) FILTER (WHERE o.status = 'cancelled'),
+			COUNT(o.id) FILTER (WHERE o.status = 'delivered' AND o.estimated_delivery IS NOT NULL),
+			COUNT(o.id) FILTER (WHERE o.status = 'delivered' AND o.actual_delivery <= o.estimated_delivery),
+			COALESCE(AVG(EXTRACT(EPOCH FROM o.actual_delivery - o.actual_pickup) / 60)
+				FILTER (WHERE o.status = 'delivered' AND o.actual_pickup IS NOT NULL), 0),
+			COUNT(o.customer_rating),
+			COALESCE(AVG(o.customer_rating), 0),
+			(SELECT COUNT(*) FROM driver_assignments WHERE driver_id = $1 AND status = 'rejected')
+		FROM driver_assignments da
+		JOIN orders o ON o.id = da.order_id
+		WHERE da.driver_id = $1 AND da.status IN ('pending', 'accepted')
+	`
+
+	var scheduled, onTime int
+	performance := &models.DriverPerformance{DriverID: driverID}
+	err := r.db.QueryRow(ctx, query, driverID).Scan(
+		&performance.TotalDeliveries,
+		&performance.CompletedDeliveries,
+		&performance.FailedDeliveries,
+		&performance.CancelledDeliveries,
+		&scheduled,
+		&onTime,
+		&performance.AverageDeliveryTime,
+		&performance.TotalReviews,
+		&performance.AverageRating,
+		&performance.RejectedAssignments,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivered, failed := performance.CompletedDeliveries, performance.FailedDeliveries
+	if delivered+failed > 0 {
+		performance.SuccessRate = math.Round(float64(delivered)/float64(delivered+failed)*10000) / 100
+	}
+	if scheduled > 0 {
+		performance.OnTimeRate = math.Round(float64(onTime)/float64(scheduled)*10000) / 100
+	}
+	performance.AverageDeliveryTime = math.Round(performance.AverageDeliveryTime*10) / 10
+	performance.AverageRating = math.Round(performance.AverageRating*100) / 100
+	return performance, nil
+}
+
+func scanDriver(row pgx.Row) (*models.Driver, error) {
+	var driver models.Driver
+	err := row.Scan(
+		&driver.ID,
+		&driver.CourierID,
+		&driver.Name,
+		&driver.Phone,
+		&driver.Email,
+		&driver.Password,
+		&driver.LicenseNumber,
+		&driver.LicenseExpiry,
+		&driver.PhotoURL,
+		&driver.VehicleType,
+		&driver.VehiclePlate,
+		&driver.Status,
+		&driver.InviteCodeHash,
+		&driver.InviteExpiresAt,
+		&driver.LastLoginAt,
+		&driver.CreatedAt,
+		&driver.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDriverNotFound
+		}
+		return nil, err
+	}
+	return &driver, nil
+}
+
+func scanDriverAssignment(row pgx.Row) (*models.DriverAssignment, error) {
+	var assignment models.DriverAssignment
+	err := row.Scan(
+		&assignment.ID,
+		&assignment.OrderID,
+		&assignment.DriverID,
+		&assignment.DriverName,
+		&assignment.DriverPhone,
+		&assignment.VehicleType,
+		&assignment.VehiclePlate,
+		&assignment.AssignedAt,
+		&assignment.AcceptedAt,
+		&assignment.ReleasedAt,
+		&assignment.Status,
+		&assignment.Note,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoDriverAssignment
+		}
+		return nil, err
+	}
+	return &assignment, nil
+}
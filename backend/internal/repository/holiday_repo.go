@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+var (
+	// ErrHolidayNotFound is returned when a holiday does not exist
+	ErrHolidayNotFound = errors.New("holiday not found")
+	// ErrHolidayExists is returned when the calendar already has an entry for the day
+	ErrHolidayExists = errors.New("a holiday is already set for that day")
+)
+
+// HolidayRepository handles the platform and courier holiday calendars
+type HolidayRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewHolidayRepository creates a new holiday repository
+func NewHolidayRepository(db *pgxpool.Pool) *HolidayRepository {
+	return &HolidayRepository{db: db}
+}
+
+const holidayColumns = `
+	id, courier_id, COALESCE(country, '') as country, date, name, COALESCE(open_time, '') as open_time,
+	COALESCE(close_time, '') as close_time, created_at
+`
+
+// Create adds a holiday. ErrHolidayExists is returned if the calendar already has the day.
+func (r *HolidayRepository) Create(ctx context.Context, holiday *models.Holiday) error {
+	query := `
+		INSERT INTO holidays (id, courier_id, country, date, name, open_time, close_time, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8)
+		ON CONFLICT (COALESCE(courier_id, '00000000-0000-0000-0000-000000000000'::uuid), COALESCE(LOWER(country), ''), date)
+			DO NOTHING
+	`
+
+	holiday.ID = uuid.New()
+	holiday.CreatedAt = time.Now()
+
+	result, err := r.db.Exec(ctx, query,
+		holiday.ID, holiday.CourierID, holiday.Country, holiday.Date, holiday.Name, holiday.Open, holiday.Close,
+		holiday.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrHolidayExists
+	}
+	return nil
+}
+
+// Delete removes a holiday from a courier's calendar, or from the platform calendar when courierID
+// is nil
+func (r *HolidayRepository) Delete(ctx context.Context, id uuid.UUID, courierID *uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM holidays WHERE id = $1 AND courier_id IS NOT DISTINCT FROM $2::uuid`, id, courierID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrHolidayNotFound
+	}
+	return nil
+}
+
+// ListForCourier retrieves the holidays that apply to a courier between two dates (inclusive): its own
+// and the platform's for its country or every country
+func (r *HolidayRepository) ListForCourier(ctx context.Context, courierID uuid.UUID, country string, from, to time.Time) ([]models.Holiday, error) {
+	query := `SELECT ` + holidayColumns + ` FROM holidays
+		WHERE date BETWEEN $3::date AND $4::date
+			AND (courier_id = $1 OR (courier_id IS NULL AND (country IS NULL OR LOWER(country) = LOWER($2))))
+		ORDER BY date, courier_id NULLS FIRST`
+	return r.list(ctx, query, courierID, country, from, to)
+}
+
+// ListPlatform retrieves the platform holidays between two dates (inclusive), optionally of one
+// country and those for every country
+func (r *HolidayRepository) ListPlatform(ctx context.Context, country string, from, to time.Time) ([]models.Holiday, error) {
+	query := `SELECT ` + holidayColumns + ` FROM holidays
+		WHERE courier_id IS NULL AND date BETWEEN $2::date AND $3::date
+			AND ($1 = '' OR country IS NULL OR LOWER(country) = LOWER($1))
+		ORDER BY date, country NULLS FIRST`
+	return r.list(ctx, query, country, from, to)
+}
+
+// ListBetween retrieves every platform and courier holiday between two dates (inclusive)
+func (r *HolidayRepository) ListBetween(ctx context.Context, from, to time.Time) ([]models.Holiday, error) {
+	query := `SELECT ` + holidayColumns + ` FROM holidays
+		WHERE date BETWEEN $1::date AND $2::date
+		ORDER BY date, courier_id NULLS FIRST`
+	return r.list(ctx, query, from, to)
+}
+
+func (r *HolidayRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.Holiday, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []models.Holiday{}
+	for rows.Next() {
+		holiday, err := scanHoliday(rows)
+		if err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, *holiday)
+	}
+	return holidays, rows.Err()
+}
+
+func scanHoliday(row pgx.Row) (*models.Holiday, error) {
+	var holiday models.Holiday
+	err := row.Scan(
+		&holiday.ID,
+		&holiday.CourierID,
+		&holiday.Country,
+		&holiday.Date,
+		&holiday.Name,
+		&holiday.Open,
+		&holiday.Close,
+		&holiday.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrHolidayNotFound
+		}
+		return nil, err
+	}
+	return &holiday, nil
+}
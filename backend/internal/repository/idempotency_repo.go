@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// IdempotencyRepository handles idempotency key data access
+type IdempotencyRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewIdempotencyRepository creates a new idempotency repository
+func NewIdempotencyRepository(db *pgxpool.Pool) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve claims a key for a new request. It returns true if the caller now owns the key;
+// otherwise it returns the record already stored for it. Expired keys are taken over, and so
+// are keys left processing since staleBefore by an identical request (e.g. after a crash).
+func (r *IdempotencyRepository) Reserve(ctx context.Context, scope, key, fingerprint string, expiresAt, staleBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (scope, idempotency_key, fingerprint, status, locked_at, created_at, expires_at)
+		VALUES ($1, $2, $3, 'processing', NOW(), NOW(), $4)
+		ON CONFLICT (scope, idempotency_key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status = 'processing',
+			response_status = NULL,
+			response_body = NULL,
+			content_type = NULL,
+			locked_at = NOW(),
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		   OR (idempotency_keys.status = 'processing'
+		       AND idempotency_keys.locked_at < $5
+		       AND idempotency_keys.fingerprint = EXCLUDED.fingerprint)
+		RETURNING scope
+	`
+
+	var claimed string
+	err := r.db.QueryRow(ctx, query, scope, key, fingerprint, expiresAt, staleBefore).Scan(&claimed)
+	if err == nil {
+		return nil, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	record, err := r.Get(ctx, scope, key)
+	if err != nil {
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+// Get retrieves the record stored for a key
+func (r *IdempotencyRepository) Get(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error) {
+	query := `
+		SELECT scope, idempotency_key, fingerprint, status,
+			COALESCE(response_status, 0), response_body, COALESCE(content_type, ''),
+			locked_at, created_at, expires_at
+		FROM idempotency_keys
+		WHERE scope = $1 AND idempotency_key = $2
+	`
+
+	var record models.IdempotencyRecord
+	err := r.db.QueryRow(ctx, query, scope, key).Scan(
+		&record.Scope,
+		&record.Key,
+		&record.Fingerprint,
+		&record.Status,
+		&record.ResponseStatus,
+		&record.ResponseBody,
+		&record.ContentType,
+		&record.LockedAt,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete stores the response of a reserved key so retries replay it
+func (r *IdempotencyRepository) Complete(ctx context.Context, scope, key string, status int, body []byte, contentType string) error {
+	query := `
+		UPDATE idempotency_keys SET
+			status = 'completed',
+			response_status = $3,
+			response_body = $4,
+			content_type = $5
+		WHERE scope = $1 AND idempotency_key = $2 AND status = 'processing'
+	`
+	_, err := r.db.Exec(ctx, query, scope, key, status, body, contentType)
+	return err
+}
+
+// Release removes a reserved key whose request failed so it can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2 AND status = 'processing'`
+	_, err := r.db.Exec(ctx, query, scope, key)
+	return err
+}
+
+// DeleteExpired removes keys past their expiry
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
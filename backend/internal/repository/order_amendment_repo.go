@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"nyengo-deliveries/internal/models"
+)
+
+var (
+	// ErrOrderModified is returned when an order changed between being read and being amended
+	ErrOrderModified = errors.New("order was modified by another request")
+	// ErrAmendmentPending is returned when an order already has an amendment waiting for approval
+	ErrAmendmentPending = errors.New("order already has an amendment waiting for store approval")
+	// ErrAmendmentDecided is returned when an amendment is no longer waiting for approval
+	ErrAmendmentDecided = errors.New("amendment has already been decided")
+)
+
+const amendmentColumns = `
+	id, order_id, requested_by, COALESCE(reason, '') as reason, status, changes, request,
+	previous_distance, new_distance, previous_fare, new_fare, fare_delta,
+	previous_earnings, new_earnings, earnings_delta,
+	COALESCE(decided_by, '') as decided_by, COALESCE(decision_note, '') as decision_note,
+	decided_at, created_at
+`
+
+// ApplyAmendment writes an amended order's delivery, package and pricing fields and records the
+// amendment in one transaction. The order must still be in one of the amendable statuses and
+// unchanged since it was read (same updated_at); otherwise ErrOrderModified is returned.
+// A previously pending amendment is updated in place, a new one is inserted.
+func (r *OrderRepository) ApplyAmendment(ctx context.Context, order *models.Order, readAt time.Time, amendable []models.OrderStatus, amendment *models.OrderAmendment) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	statuses := make([]string, len(amendable))
+	for i, status := range amendable {
+		statuses[i] = string(status)
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(ctx, `
+		UPDATE orders SET
+			delivery_address = $2, delivery_latitude = $3, delivery_longitude = $4, delivery_notes = $5,
+			package_description = $6, package_size = $7, package_weight = $8, is_fragile = $9,
+			distance = $10, base_fare = $11, distance_fare = $12, surge_fare = $13,
+			total_fare = $14, platform_fee = $15, courier_earnings = $16,
+			updated_at = $17
+		WHERE id = $1 AND status = ANY($18) AND updated_at = $19
+	`,
+		order.ID,
+		order.DeliveryAddress, order.DeliveryLatitude, order.DeliveryLongitude, order.DeliveryNotes,
+		order.PackageDescription, order.PackageSize, order.PackageWeight, order.IsFragile,
+		order.Distance, order.BaseFare, order.DistanceFare, order.SurgeFare,
+		order.TotalFare, order.PlatformFee, order.CourierEarnings,
+		now, statuses, readAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOrderModified
+	}
+
+	if amendment.ID == uuid.Nil {
+		amendment.Status = models.AmendmentStatusApplied
+		if err := insertAmendment(ctx, tx, amendment); err != nil {
+			return err
+		}
+	} else {
+		changesJSON, err := json.Marshal(amendment.Changes)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(ctx, `
+			UPDATE order_amendments SET
+				status = 'applied', changes = $2,
+				previous_distance = $3, new_distance = $4,
+				previous_fare = $5, new_fare = $6, fare_delta = $7,
+				previous_earnings = $8, new_earnings = $9, earnings_delta = $10,
+				decided_by = $11, decision_note = $12, decided_at = $13
+			WHERE id = $1 AND status = 'pending_approval'
+		`,
+			amendment.ID, changesJSON,
+			amendment.PreviousDistance, amendment.NewDistance,
+			amendment.PreviousFare, amendment.NewFare, amendment.FareDelta,
+			amendment.PreviousEarnings, amendment.NewEarnings, amendment.EarningsDelta,
+			amendment.DecidedBy, amendment.DecisionNote, now,
+		)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrAmendmentDecided
+		}
+		amendment.Status = models.AmendmentStatusApplied
+		amendment.DecidedAt = &now
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	order.UpdatedAt = now
+	return nil
+}
+
+// CreatePendingAmendment records an amendment that waits for store approval.
+// Only one amendment per order can be pending; otherwise ErrAmendmentPending is returned.
+func (r *OrderRepository) CreatePendingAmendment(ctx context.Context, amendment *models.OrderAmendment) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	amendment.Status = models.AmendmentStatusPendingApproval
+	if err := insertAmendment(ctx, tx, amendment); err != nil {
+		if errors.Is(err, errAmendmentConflict) {
+			return ErrAmendmentPending
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RejectAmendment marks a pending amendment rejected
+func (r *OrderRepository) RejectAmendment(ctx context.Context, amendment *models.OrderAmendment) error {
+	query := `
+		UPDATE order_amendments SET status = 'rejected', decided_by = $2, decision_note = $3, decided_at = $4
+		WHERE id = $1 AND status = 'pending_approval'
+	`
+
+	now := time.Now()
+	result, err := r.db.Exec(ctx, query, amendment.ID, amendment.DecidedBy, amendment.DecisionNote, now)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAmendmentDecided
+	}
+	amendment.Status = models.AmendmentStatusRejected
+	amendment.DecidedAt = &now
+	return nil
+}
+
+// GetAmendment retrieves one amendment of an order
+func (r *OrderRepository) GetAmendment(ctx context.Context, orderID, amendmentID uuid.UUID) (*models.OrderAmendment, error) {
+	query := `SELECT ` + amendmentColumns + ` FROM order_amendments WHERE id = $1 AND order_id = $2`
+	return scanAmendment(r.db.QueryRow(ctx, query, amendmentID, orderID))
+}
+
+// ListAmendments retrieves an order's amendment log, oldest first
+func (r *OrderRepository) ListAmendments(ctx context.Context, orderID uuid.UUID) ([]models.OrderAmendment, error) {
+	query := `SELECT ` + amendmentColumns + ` FROM order_amendments WHERE order_id = $1 ORDER BY created_at`
+	rows, err := r.db.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	amendments := []models.OrderAmendment{}
+	for rows.Next() {
+		amendment, err := scanAmendment(rows)
+		if err != nil {
+			return nil, err
+		}
+		amendments = append(amendments, *amendment)
+	}
+	return amendments, rows.Err()
+}
+
+// errAmendmentConflict signals that the one-pending-per-order index rejected an insert
+var errAmendmentConflict = errors.New("amendment conflict")
+
+func insertAmendment(ctx context.Context, tx pgx.Tx, amendment *models.OrderAmendment) error {
+	query := `
+		INSERT INTO order_amendments (
+			id, order_id, requested_by, reason, status, changes, request,
+			previous_distance, new_distance, previous_fare, new_fare, fare_delta,
+			previous_earnings, new_earnings, earnings_delta, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (order_id) WHERE status = 'pending_approval' DO NOTHING
+	`
+
+	amendment.ID = uuid.New()
+	amendment.CreatedAt = time.Now()
+
+	changesJSON, err := json.Marshal(amendment.Changes)
+	if err != nil {
+		return err
+	}
+	requestJSON, err := json.Marshal(amendment.Request)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, query,
+		amendment.ID, amendment.OrderID, amendment.RequestedBy, amendment.Reason, amendment.Status,
+		changesJSON, requestJSON,
+		amendment.PreviousDistance, amendment.NewDistance,
+		amendment.PreviousFare, amendment.NewFare, amendment.FareDelta,
+		amendment.PreviousEarnings, amendment.NewEarnings, amendment.EarningsDelta,
+		amendment.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errAmendmentConflict
+	}
+	return nil
+}
+
+func scanAmendment(row pgx.Row) (*models.OrderAmendment, error) {
+	var amendment models.OrderAmendment
+	var changesJSON, requestJSON []byte
+	err := row.Scan(
+		&amendment.ID,
+		&amendment.OrderID,
+		&amendment.RequestedBy,
+		&amendment.Reason,
+		&amendment.Status,
+		&changesJSON,
+		&requestJSON,
+		&amendment.PreviousDistance,
+		&amendment.NewDistance,
+		&amendment.PreviousFare,
+		&amendment.NewFare,
+		&amendment.FareDelta,
+		&amendment.PreviousEarnings,
+		&amendment.NewEarnings,
+		&amendment.EarningsDelta,
+		&amendment.DecidedBy,
+		&amendment.DecisionNote,
+		&amendment.DecidedAt,
+		&amendment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(changesJSON, &amendment.Changes)
+	json.Unmarshal(requestJSON, &amendment.Request)
+	return &amendment, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// OrderImportRepository handles bulk order import data access
+type OrderImportRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewOrderImportRepository creates a new order import repository
+func NewOrderImportRepository(db *pgxpool.Pool) *OrderImportRepository {
+	return &OrderImportRepository{db: db}
+}
+
+// Create records a new import job
+func (r *OrderImportRepository) Create(ctx context.Context, imp *models.OrderImport) error {
+	query := `
+		INSERT INTO order_imports (id, store_id, format, dry_run, on_error, status, total_rows, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	imp.ID = uuid.New()
+	imp.Status = models.ImportStatusProcessing
+	imp.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(ctx, query,
+		imp.ID, imp.StoreID, imp.Format, imp.DryRun, imp.OnError, imp.Status, imp.TotalRows, imp.CreatedAt,
+	)
+	return err
+}
+
+// Complete stores the outcome and per-row report of an import job
+func (r *OrderImportRepository) Complete(ctx context.Context, imp *models.OrderImport) error {
+	query := `
+		UPDATE order_imports SET
+			status = $2, valid_rows = $3, created_count = $4, failed_count = $5,
+			total_fare = $6, rows = $7, completed_at = $8
+		WHERE id = $1
+	`
+
+	rowsJSON, err := json.Marshal(imp.Rows)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	imp.CompletedAt = &now
+	_, err = r.db.Exec(ctx, query,
+		imp.ID, imp.Status, imp.ValidRows, imp.CreatedCount, imp.FailedCount, imp.TotalFare, rowsJSON, imp.CompletedAt,
+	)
+	return err
+}
+
+// GetByID retrieves an import job with its report
+func (r *OrderImportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.OrderImport, error) {
+	query := `
+		SELECT id, store_id, format, dry_run, on_error, status, total_rows, valid_rows,
+			created_count, failed_count, total_fare, rows, created_at, completed_at
+		FROM order_imports WHERE id = $1
+	`
+
+	var imp models.OrderImport
+	var rowsJSON []byte
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&imp.ID, &imp.StoreID, &imp.Format, &imp.DryRun, &imp.OnError, &imp.Status,
+		&imp.TotalRows, &imp.ValidRows, &imp.CreatedCount, &imp.FailedCount, &imp.TotalFare,
+		&rowsJSON, &imp.CreatedAt, &imp.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(rowsJSON, &imp.Rows)
+	return &imp, nil
+}
@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+)
+
+var (
+	// ErrInvalidSort is returned when an order list is sorted by a field that is not whitelisted
+	ErrInvalidSort = errors.New("unsupported sort field")
+	// ErrInvalidCursor is returned when a cursor is malformed or was issued for a different sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// orderSortColumn is a whitelisted sort field. Only these column names are ever written into SQL.
+type orderSortColumn struct {
+	column string
+	kind   string // "time", "number" or "text", used to decode cursor values
+}
+
+var orderSortColumns = map[string]orderSortColumn{
+	"createdAt":   {"o.created_at", "time"},
+	"updatedAt":   {"o.updated_at", "time"},
+	"totalFare":   {"o.total_fare", "number"},
+	"distance":    {"o.distance", "number"},
+	"orderNumber": {"o.order_number", "text"},
+}
+
+// orderCursor is the position after the last row of a page, for keyset pagination
+type orderCursor struct {
+	Sort  string          `json:"s"`
+	Order string          `json:"o"`
+	Value json.RawMessage `json:"v"`
+	ID    uuid.UUID       `json:"id"`
+}
+
+// List retrieves orders matching the filters. With a cursor the page continues after the cursor's
+// row (keyset pagination, no total count); otherwise page numbers are used and totals are counted.
+// Rows are always ordered by the sort column and then by ID, so pages never overlap or skip rows.
+func (r *OrderRepository) List(ctx context.Context, filters *models.OrderListFilters) (*models.OrderListResponse, error) {
+	sortKey, sort, sortOrder, comparison, err := resolveOrderSort(filters)
+	if err != nil {
+		return nil, err
+	}
+	if filters.PageSize <= 0 {
+		filters.PageSize = 20
+	}
+
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conditions := orderFilterConditions(filters, arg)
+
+	response := &models.OrderListResponse{PageSize: filters.PageSize}
+	var offset int
+	if filters.Cursor != "" {
+		cursor, value, err := decodeOrderCursor(filters.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != sortKey || cursor.Order != sortOrder {
+			return nil, fmt.Errorf("%w: the cursor was issued for a different sort", ErrInvalidCursor)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, o.id) %s (%s, %s)", sort.column, comparison, arg(value), arg(cursor.ID)))
+	} else {
+		if filters.Page <= 0 {
+			filters.Page = 1
+		}
+		offset = (filters.Page - 1) * filters.PageSize
+		response.Page = filters.Page
+	}
+
+	whereClause := "TRUE"
+	if len(conditions) > 0 {
+		whereClause = strings.Join(conditions, " AND ")
+	}
+
+	if filters.Cursor == "" {
+		var totalCount int
+		if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM orders o WHERE "+whereClause, args...).Scan(&totalCount); err != nil {
+			return nil, err
+		}
+		totalPages := (totalCount + filters.PageSize - 1) / filters.PageSize
+		response.TotalCount = &totalCount
+		response.TotalPages = &totalPages
+	}
+
+	columns := `o.id, o.order_number, o.courier_id, o.store_id, COALESCE(o.external_order_id, ''),
+		COALESCE(o.order_type, 'standard'), o.customer_name, o.customer_phone,
+		o.pickup_address, o.delivery_address, o.package_size,
+		o.distance, o.total_fare, o.payment_method, o.status, o.payment_status,
+		o.scheduled_pickup, o.actual_delivery, o.metadata, o.created_at, o.updated_at`
+	joins := ""
+	if filters.IncludeStatusHistory {
+		columns += `, COALESCE(o.status_history, '[]'::jsonb)`
+	}
+	if filters.IncludeTracking {
+		columns += `, t.id IS NOT NULL, COALESCE(t.is_active, false), t.current_latitude, t.current_longitude,
+			t.last_location_at, t.estimated_arrival, t.distance_remaining`
+		joins = `
+		LEFT JOIN LATERAL (
+			SELECT id, is_active, current_latitude, current_longitude, last_location_at,
+				estimated_arrival, distance_remaining
+			FROM delivery_tracking
+			WHERE order_id = o.id
+			ORDER BY created_at DESC
+			LIMIT 1
+		) t ON TRUE`
+	}
+
+	// One extra row tells whether there is a next page
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM orders o%s
+		WHERE %s
+		ORDER BY %s %s, o.id %s
+		LIMIT %s OFFSET %s
+	`, columns, joins, whereClause, sort.column, sortOrder, sortOrder, arg(filters.PageSize+1), arg(offset))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var o models.Order
+		var historyJSON, metadataJSON []byte
+		var hasTracking bool
+		var tracking models.OrderTrackingSummary
+		dest := []interface{}{
+			&o.ID, &o.OrderNumber, &o.CourierID, &o.StoreID, &o.ExternalOrderID,
+			&o.OrderType, &o.CustomerName, &o.CustomerPhone,
+			&o.PickupAddress, &o.DeliveryAddress, &o.PackageSize,
+			&o.Distance, &o.TotalFare, &o.PaymentMethod, &o.Status, &o.PaymentStatus,
+			&o.ScheduledPickup, &o.ActualDelivery, &metadataJSON, &o.CreatedAt, &o.UpdatedAt,
+		}
+		if filters.IncludeStatusHistory {
+			dest = append(dest, &historyJSON)
+		}
+		if filters.IncludeTracking {
+			dest = append(dest, &hasTracking, &tracking.IsActive, &tracking.Latitude, &tracking.Longitude,
+				&tracking.LastLocationAt, &tracking.EstimatedArrival, &tracking.DistanceRemaining)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(metadataJSON, &o.Metadata)
+		if filters.IncludeStatusHistory {
+			json.Unmarshal(historyJSON, &o.StatusHistory)
+		}
+		if hasTracking {
+			o.Tracking = &tracking
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(orders) > filters.PageSize {
+		orders = orders[:filters.PageSize]
+		response.HasMore = true
+		last := &orders[len(orders)-1]
+		response.NextCursor = encodeOrderCursor(sortKey, sortOrder, last)
+	}
+	response.Orders = orders
+	return response, nil
+}
+
+// resolveOrderSort returns the whitelisted sort for the filters, defaulting to newest first
+func resolveOrderSort(filters *models.OrderListFilters) (string, orderSortColumn, string, string, error) {
+	sortKey := filters.SortBy
+	if sortKey == "" {
+		sortKey = "createdAt"
+	}
+	sort, ok := orderSortColumns[sortKey]
+	if !ok {
+		return "", orderSortColumn{}, "", "", fmt.Errorf("%w %q", ErrInvalidSort, filters.SortBy)
+	}
+	if strings.EqualFold(filters.SortOrder, "asc") {
+		return sortKey, sort, "ASC", ">", nil
+	}
+	return sortKey, sort, "DESC", "<", nil
+}
+
+// orderFilterConditions builds the WHERE conditions shared by order lists and exports.
+// arg binds a value and returns its placeholder.
+func orderFilterConditions(filters *models.OrderListFilters, arg func(interface{}) string) []string {
+	var conditions []string
+	if filters.CourierID != nil {
+		conditions = append(conditions, "o.courier_id = "+arg(*filters.CourierID))
+	}
+	if filters.StoreID != nil {
+		conditions = append(conditions, "o.store_id = "+arg(*filters.StoreID))
+	}
+	if filters.DriverID != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM driver_assignments da WHERE da.order_id = o.id AND da.driver_id = "+
+			arg(*filters.DriverID)+" AND da.status IN ('pending', 'accepted'))")
+	}
+	if len(filters.Status) > 0 {
+		statuses := make([]string, len(filters.Status))
+		for i, status := range filters.Status {
+			statuses[i] = string(status)
+		}
+		conditions = append(conditions, "o.status = ANY("+arg(statuses)+")")
+	}
+	if len(filters.PaymentStatus) > 0 {
+		statuses := make([]string, len(filters.PaymentStatus))
+		for i, status := range filters.PaymentStatus {
+			statuses[i] = string(status)
+		}
+		conditions = append(conditions, "o.payment_status = ANY("+arg(statuses)+")")
+	}
+	if filters.DateFrom != nil {
+		conditions = append(conditions, "o.created_at >= "+arg(*filters.DateFrom))
+	}
+	if filters.DateTo != nil {
+		conditions = append(conditions, "o.created_at <= "+arg(*filters.DateTo))
+	}
+	if filters.MinFare != nil {
+		conditions = append(conditions, "o.total_fare >= "+arg(*filters.MinFare))
+	}
+	if filters.MaxFare != nil {
+		conditions = append(conditions, "o.total_fare <= "+arg(*filters.MaxFare))
+	}
+	if filters.Search != "" {
+		p := arg("%" + filters.Search + "%")
+		conditions = append(conditions, fmt.Sprintf(
+			"(o.customer_name ILIKE %s OR o.order_number ILIKE %s OR o.external_order_id ILIKE %s)", p, p, p))
+	}
+	// Keys are bound as parameters like values, never written into the SQL
+	keys := make([]string, 0, len(filters.Metadata))
+	for key := range filters.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		conditions = append(conditions, fmt.Sprintf("o.metadata ->> %s = %s", arg(key), arg(filters.Metadata[key])))
+	}
+	return conditions
+}
+
+// StreamForExport calls fn for every order matching the filters, in sort order, without paging.
+// Rows are read from the open cursor one at a time so exports of any size use constant memory.
+func (r *OrderRepository) StreamForExport(ctx context.Context, filters *models.OrderListFilters, fn func(*models.Order) error) error {
+	_, sort, sortOrder, _, err := resolveOrderSort(filters)
+	if err != nil {
+		return err
+	}
+
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	whereClause := "TRUE"
+	if conditions := orderFilterConditions(filters, arg); len(conditions) > 0 {
+		whereClause = strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT o.id, o.order_number, COALESCE(o.external_order_id, ''), COALESCE(o.order_type, 'standard'),
+			o.customer_name, o.pickup_address, o.delivery_address, o.package_size, o.distance,
+			o.total_fare, o.platform_fee, o.courier_earnings, COALESCE(o.cod_amount, 0), COALESCE(o.cod_collected, 0),
+			o.payment_method, o.status, o.payment_status, o.actual_delivery, o.created_at
+		FROM orders o
+		WHERE %s
+		ORDER BY %s %s, o.id %s
+	`, whereClause, sort.column, sortOrder, sortOrder)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o models.Order
+		if err := rows.Scan(
+			&o.ID, &o.OrderNumber, &o.ExternalOrderID, &o.OrderType,
+			&o.CustomerName, &o.PickupAddress, &o.DeliveryAddress, &o.PackageSize, &o.Distance,
+			&o.TotalFare, &o.PlatformFee, &o.CourierEarnings, &o.CODAmount, &o.CODCollected,
+			&o.PaymentMethod, &o.Status, &o.PaymentStatus, &o.ActualDelivery, &o.CreatedAt,
+		); err != nil {
+			return err
+		}
+		if err := fn(&o); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// encodeOrderCursor builds the cursor that continues after the given order
+func encodeOrderCursor(sortKey, sortOrder string, order *models.Order) string {
+	var value interface{}
+	switch sortKey {
+	case "createdAt":
+		value = order.CreatedAt.Format(time.RFC3339Nano)
+	case "updatedAt":
+		value = order.UpdatedAt.Format(time.RFC3339Nano)
+	case "totalFare":
+		value = order.TotalFare
+	case "distance":
+		value = order.Distance
+	case "orderNumber":
+		value = order.OrderNumber
+	}
+
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(orderCursor{Sort: sortKey, Order: sortOrder, Value: raw, ID: order.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeOrderCursor parses a cursor and its sort value into the type of the sort column
+func decodeOrderCursor(encoded string, sort orderSortColumn) (*orderCursor, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	var cursor orderCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	switch sort.kind {
+	case "time":
+		var s string
+		if json.Unmarshal(cursor.Value, &s) != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		return &cursor, t, nil
+	case "number":
+		var f float64
+		if json.Unmarshal(cursor.Value, &f) != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		return &cursor, f, nil
+	default:
+		var s string
+		if json.Unmarshal(cursor.Value, &s) != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		return &cursor, s, nil
+	}
+}
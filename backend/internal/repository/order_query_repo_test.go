@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+)
+
+func TestOrderCursorRoundTrip(t *testing.T) {
+	order := &models.Order{
+		ID:          uuid.New(),
+		OrderNumber: "NYG-20260601-0042",
+		TotalFare:   125.75,
+		Distance:    12.3456789,
+		CreatedAt:   time.Date(2026, time.June, 1, 10, 15, 30, 123456789, time.FixedZone("CAT", 2*60*60)),
+		UpdatedAt:   time.Date(2026, time.June, 2, 8, 0, 0, 987654000, time.UTC),
+	}
+
+	tests := []struct {
+		sortKey string
+		want    interface{}
+	}{
+		{"createdAt", order.CreatedAt},
+		{"updatedAt", order.UpdatedAt},
+		{"totalFare", order.TotalFare},
+		{"distance", order.Distance},
+		{"orderNumber", order.OrderNumber},
+	}
+
+	for _, tt := range tests {
+		for _, sortOrder := range []string{"ASC", "DESC"} {
+			t.Run(tt.sortKey+" "+sortOrder, func(t *testing.T) {
+				encoded := encodeOrderCursor(tt.sortKey, sortOrder, order)
+				cursor, value, err := decodeOrderCursor(encoded, orderSortColumns[tt.sortKey])
+				if err != nil {
+					t.Fatalf("decodeOrderCursor() error = %v", err)
+				}
+				if cursor.Sort != tt.sortKey || cursor.Order != sortOrder || cursor.ID != order.ID {
+					t.Errorf("cursor = %s %s %s, want %s %s %s",
+						cursor.Sort, cursor.Order, cursor.ID, tt.sortKey, sortOrder, order.ID)
+				}
+
+				if want, ok := tt.want.(time.Time); ok {
+					got, ok := value.(time.Time)
+					if !ok || !got.Equal(want) {
+						t.Errorf("value = %v, want %v", value, want)
+					}
+				} else if value != tt.want {
+					t.Errorf("value = %v (%T), want %v (%T)", value, value, tt.want, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeOrderCursorInvalid(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+	id := uuid.New().String()
+
+	tests := []struct {
+		name    string
+		cursor  string
+		sortKey string
+	}{
+		{"not base64", "not a cursor!", "createdAt"},
+		{"not json", encode("createdAt"), "createdAt"},
+		{"missing id", encode(`{"s":"totalFare","o":"DESC","v":12.5}`), "totalFare"},
+		{"time sort with a number", encode(`{"s":"createdAt","o":"DESC","v":12.5,"id":"` + id + `"}`), "createdAt"},
+		{"malformed time", encode(`{"s":"createdAt","o":"DESC","v":"yesterday","id":"` + id + `"}`), "createdAt"},
+		{"number sort with text", encode(`{"s":"totalFare","o":"ASC","v":"12.5","id":"` + id + `"}`), "totalFare"},
+		{"text sort with a number", encode(`{"s":"orderNumber","o":"ASC","v":42,"id":"` + id + `"}`), "orderNumber"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeOrderCursor(tt.cursor, orderSortColumns[tt.sortKey]); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeOrderCursor() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
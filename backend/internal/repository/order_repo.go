@@ -3,17 +3,29 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"nyengo-deliveries/internal/models"
 )
 
+// ErrOrderStatusChanged is returned when an order's status no longer matches the expected value
+var ErrOrderStatusChanged = errors.New("order status changed concurrently")
+
+// ErrOrderNotRateable is returned when an order is not delivered or has already been rated
+var ErrOrderNotRateable = errors.New("order cannot be rated")
+
+// ErrDeliveryPINLocked is returned when a delivery PIN attempt is refused because PIN entry is locked
+var ErrDeliveryPINLocked = errors.New("delivery PIN entry is locked")
+
 // OrderRepository handles order data access
 type OrderRepository struct {
 	db *pgxpool.Pool
@@ -28,18 +40,20 @@ func NewOrderRepository(db *pgxpool.Pool) *OrderRepository {
 func (r *OrderRepository) Create(ctx context.Context, order *models.Order) error {
 	query := `
 		INSERT INTO orders (
-			id, order_number, courier_id, store_id, external_order_id,
+			id, order_number, courier_id, store_id, external_order_id, order_type,
 			customer_name, customer_phone, customer_email,
 			pickup_address, pickup_latitude, pickup_longitude, pickup_notes,
 			pickup_contact_name, pickup_contact_phone,
 			delivery_address, delivery_latitude, delivery_longitude, delivery_notes,
 			package_description, package_size, package_weight, is_fragile, requires_signature,
 			distance, base_fare, distance_fare, surge_fare, total_fare, platform_fee, courier_earnings,
-			payment_method, payment_status, status, scheduled_pickup,
-			created_at, updated_at
+			payment_method, payment_status, status, status_history, scheduled_pickup,
+			actual_pickup, return_of_order_id, cod_amount, cod_status, metadata, created_at, updated_at
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18,
-			$19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36
+			$1, $2, NULLIF($3::uuid, '00000000-0000-0000-0000-000000000000'), -- broadcast orders start unassigned
+			$4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18,
+			$19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38,
+			$39, NULLIF($40, ''), $41, $42, $43
 		)
 	`
 
@@ -47,19 +61,58 @@ func (r *OrderRepository) Create(ctx context.Context, order *models.Order) error
 	order.OrderNumber = generateOrderNumber()
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
-	order.Status = models.OrderStatusPending
 	order.PaymentStatus = models.PaymentStatusPending
+	if order.OrderType == "" {
+		order.OrderType = models.OrderTypeStandard
+	}
+	order.CODStatus = ""
+	if order.CODAmount > 0 {
+		order.CODStatus = models.CODStatusPending
+	}
+
+	// Orders scheduled far enough ahead start dormant and return legs start with the parcel
+	// already on board; everything else is immediately pending
+	note := "Order created"
+	switch {
+	case order.Status == models.OrderStatusScheduled && order.ScheduledPickup != nil:
+		note = "Order scheduled for pickup at " + order.ScheduledPickup.Format(time.RFC3339)
+	case order.ReturnOfOrderID != nil:
+		order.Status = models.OrderStatusPickedUp
+		order.ActualPickup = &order.CreatedAt
+		note = "Return to sender created"
+	default:
+		order.Status = models.OrderStatusPending
+	}
+	order.StatusHistory = []models.StatusChange{{
+		Status:    order.Status,
+		Timestamp: order.CreatedAt,
+		Note:      note,
+		Actor:     models.StatusActorSystem,
+	}}
+	historyJSON, _ := json.Marshal(order.StatusHistory)
+	metadataJSON, err := marshalMetadata(order.Metadata)
+	if err != nil {
+		return err
+	}
 
 	// Log the order object being created
 	orderJSON, _ := json.MarshalIndent(order, "", "  ")
 	log.Printf("📦 Creating new order in database:\n%s", string(orderJSON))
 
-	_, err := r.db.Exec(ctx, query,
+	// The order and its stops are written together so a multi-stop order is never left without drop-offs
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, query,
 		order.ID,
 		order.OrderNumber,
 		order.CourierID,
 		order.StoreID,
 		order.ExternalOrderID,
+		order.OrderType,
 		order.CustomerName,
 		order.CustomerPhone,
 		order.CustomerEmail,
@@ -88,12 +141,40 @@ func (r *OrderRepository) Create(ctx context.Context, order *models.Order) error
 		order.PaymentMethod,
 		order.PaymentStatus,
 		order.Status,
+		historyJSON,
 		order.ScheduledPickup,
+		order.ActualPickup,
+		order.ReturnOfOrderID,
+		order.CODAmount,
+		order.CODStatus,
+		metadataJSON,
 		order.CreatedAt,
 		order.UpdatedAt,
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// Linking the original order in the same transaction means it can only ever get one return leg
+	if order.ReturnOfOrderID != nil {
+		result, err := tx.Exec(ctx,
+			`UPDATE orders SET return_order_id = $2, updated_at = $3 WHERE id = $1 AND return_order_id IS NULL`,
+			*order.ReturnOfOrderID, order.ID, order.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrReturnLegExists
+		}
+	}
+
+	for i := range order.Stops {
+		if err := insertStop(ctx, tx, order.ID, &order.Stops[i], order.CreatedAt); err != nil {
+			return fmt.Errorf("failed to create stop %d: %w", order.Stops[i].Sequence, err)
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
 // GetByID retrieves an order by ID
@@ -102,6 +183,7 @@ func (r *OrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Or
 		SELECT id, order_number, courier_id, 
 			COALESCE(store_id, '00000000-0000-0000-0000-000000000000') as store_id, 
 			COALESCE(external_order_id, '') as external_order_id,
+			COALESCE(order_type, 'standard') as order_type,
 			customer_name, customer_phone, COALESCE(customer_email, '') as customer_email,
 			pickup_address, pickup_latitude, pickup_longitude, COALESCE(pickup_notes, '') as pickup_notes,
 			COALESCE(pickup_contact_name, '') as pickup_contact_name, COALESCE(pickup_contact_phone, '') as pickup_contact_phone,
@@ -109,23 +191,42 @@ func (r *OrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Or
 			package_description, package_size, package_weight, is_fragile, requires_signature,
 			distance, base_fare, distance_fare, surge_fare, total_fare, platform_fee, courier_earnings,
 			payment_method, payment_status, COALESCE(payment_reference, '') as payment_reference, status,
+			COALESCE(status_history, '[]'::jsonb) as status_history,
 			scheduled_pickup, actual_pickup, estimated_delivery, actual_delivery,
+			pickup_reminder_sent_at, pickup_overdue_at,
+			COALESCE(delivery_attempts, 0) as delivery_attempts, next_attempt_at, reattempt_reminder_sent_at,
+			return_order_id, return_of_order_id,
+			cod_amount, cod_collected, COALESCE(cod_status, '') as cod_status, COALESCE(cod_note, '') as cod_note,
+			cod_collected_at, cod_remittance_id,
+			COALESCE(dispatch_mode, 'manual') as dispatch_mode, COALESCE(dispatch_status, '') as dispatch_status,
+			COALESCE(dispatch_area, '') as dispatch_area, offer_expires_at,
 			COALESCE(delivery_proof_url, '') as delivery_proof_url, 
 			COALESCE(recipient_name, '') as recipient_name, 
 			COALESCE(signature_url, '') as signature_url,
-			customer_rating, COALESCE(customer_feedback, '') as customer_feedback, 
-			COALESCE(notes, '') as notes,
+			delivery_pin_required, COALESCE(delivery_pin_hash, '') as delivery_pin_hash,
+			COALESCE(delivery_pin_attempts, 0) as delivery_pin_attempts,
+			delivery_pin_locked_until, delivery_pin_verified_at,
+			COALESCE(cancelled_by, '') as cancelled_by,
+			COALESCE(cancellation_reason, '') as cancellation_reason,
+			COALESCE(cancellation_fee, 0) as cancellation_fee,
+			COALESCE(refund_amount, 0) as refund_amount,
+			cancelled_at,
+			customer_rating, COALESCE(customer_feedback, '') as customer_feedback,
+			COALESCE(rated_by, '') as rated_by, rated_at,
+			COALESCE(notes, '') as notes, metadata,
 			created_at, updated_at
 		FROM orders WHERE id = $1
 	`
 
 	var order models.Order
+	var historyJSON, metadataJSON []byte
 	err := r.db.QueryRow(ctx, query, id).Scan(
 		&order.ID,
 		&order.OrderNumber,
 		&order.CourierID,
 		&order.StoreID,
 		&order.ExternalOrderID,
+		&order.OrderType,
 		&order.CustomerName,
 		&order.CustomerPhone,
 		&order.CustomerEmail,
@@ -155,16 +256,47 @@ func (r *OrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Or
 		&order.PaymentStatus,
 		&order.PaymentReference,
 		&order.Status,
+		&historyJSON,
 		&order.ScheduledPickup,
 		&order.ActualPickup,
 		&order.EstimatedDelivery,
 		&order.ActualDelivery,
+		&order.PickupReminderSentAt,
+		&order.PickupOverdueAt,
+		&order.DeliveryAttempts,
+		&order.NextAttemptAt,
+		&order.ReattemptReminderSentAt,
+		&order.ReturnOrderID,
+		&order.ReturnOfOrderID,
+		&order.CODAmount,
+		&order.CODCollected,
+		&order.CODStatus,
+		&order.CODNote,
+		&order.CODCollectedAt,
+		&order.CODRemittanceID,
+		&order.DispatchMode,
+		&order.DispatchStatus,
+		&order.DispatchArea,
+		&order.OfferExpiresAt,
 		&order.DeliveryProofURL,
 		&order.RecipientName,
 		&order.SignatureURL,
+		&order.DeliveryPINRequired,
+		&order.DeliveryPINHash,
+		&order.DeliveryPINAttempts,
+		&order.DeliveryPINLockedUntil,
+		&order.DeliveryPINVerifiedAt,
+		&order.CancelledBy,
+		&order.CancellationReason,
+		&order.CancellationFee,
+		&order.RefundAmount,
+		&order.CancelledAt,
 		&order.CustomerRating,
 		&order.CustomerFeedback,
+		&order.RatedBy,
+		&order.RatedAt,
 		&order.Notes,
+		&metadataJSON,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 	)
@@ -173,6 +305,14 @@ func (r *OrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Or
 		return nil, err
 	}
 
+	json.Unmarshal(historyJSON, &order.StatusHistory)
+	json.Unmarshal(metadataJSON, &order.Metadata)
+
+	if order.OrderType == models.OrderTypeMultiStop {
+		if order.Stops, err = r.GetStops(ctx, order.ID); err != nil {
+			return nil, fmt.Errorf("failed to load stops: %w", err)
+		}
+	}
 	return &order, nil
 }
 
@@ -187,155 +327,306 @@ func (r *OrderRepository) GetByOrderNumber(ctx context.Context, orderNumber stri
 	return r.GetByID(ctx, id)
 }
 
-// List retrieves orders for a courier with filters
-func (r *OrderRepository) List(ctx context.Context, courierID uuid.UUID, filters *models.OrderListFilters) (*models.OrderListResponse, error) {
-	// Build query dynamically
-	var conditions []string
-	var args []interface{}
-	argIndex := 1
-
-	conditions = append(conditions, fmt.Sprintf("courier_id = $%d", argIndex))
-	args = append(args, courierID)
-	argIndex++
-
-	if len(filters.Status) > 0 {
-		placeholders := make([]string, len(filters.Status))
-		for i, s := range filters.Status {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, s)
-			argIndex++
-		}
-		conditions = append(conditions, fmt.Sprintf("status IN (%s)", strings.Join(placeholders, ",")))
-	}
+// TransitionStatus moves an order from one status to another and appends the change to its history.
+// The update only applies while the order is still in the expected status and assigned to the
+// expected courier (none, for uuid.Nil); otherwise ErrOrderStatusChanged is returned. Pickup and delivery timestamps are set on the matching transitions.
+// Going in transit also records whether delivery needs the customer's PIN, so a PIN that fails to be
+// issued blocks delivery rather than skipping the check.
+func (r *OrderRepository) TransitionStatus(ctx context.Context, id, courierID uuid.UUID, from models.OrderStatus, change models.StatusChange, requirePIN bool) error {
+	query := `
+		UPDATE orders SET
+			status = $3,
+			status_history = COALESCE(status_history, '[]'::jsonb) || $4::jsonb,
+			actual_pickup = CASE WHEN $3 = 'picked_up' THEN $5 ELSE actual_pickup END,
+			actual_delivery = CASE WHEN $3 = 'delivered' THEN $5 ELSE actual_delivery END,
+			delivery_pin_required = CASE WHEN $3 = 'in_transit' THEN $7 ELSE delivery_pin_required END,
+			updated_at = $5
+		WHERE id = $1 AND status = $2
+			AND courier_id IS NOT DISTINCT FROM NULLIF($6::uuid, '00000000-0000-0000-0000-000000000000')
+	`
 
-	if filters.DateFrom != nil {
-		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argIndex))
-		args = append(args, *filters.DateFrom)
-		argIndex++
+	changeJSON, err := json.Marshal([]models.StatusChange{change})
+	if err != nil {
+		return err
 	}
 
-	if filters.DateTo != nil {
-		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", argIndex))
-		args = append(args, *filters.DateTo)
-		argIndex++
+	result, err := r.db.Exec(ctx, query, id, from, change.Status, changeJSON, change.Timestamp, courierID, requirePIN)
+	if err != nil {
+		return err
 	}
-
-	if filters.Search != "" {
-		conditions = append(conditions, fmt.Sprintf("(customer_name ILIKE $%d OR order_number ILIKE $%d)", argIndex, argIndex))
-		args = append(args, "%"+filters.Search+"%")
-		argIndex++
+	if result.RowsAffected() == 0 {
+		return ErrOrderStatusChanged
 	}
+	return nil
+}
+
+// UpdatePaymentStatus updates the payment status and reference
+func (r *OrderRepository) UpdatePaymentStatus(ctx context.Context, id uuid.UUID, status string, reference string) error {
+	query := `UPDATE orders SET payment_status = $2, payment_reference = $3, updated_at = $4 WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id, status, reference, time.Now())
+	return err
+}
+
+// UpdateDeliveryProof updates delivery proof information.
+// The delivery time itself is recorded by the status transition to delivered.
+func (r *OrderRepository) UpdateDeliveryProof(ctx context.Context, id uuid.UUID, proofURL, recipientName, signatureURL string) error {
+	query := `
+		UPDATE orders SET
+			delivery_proof_url = $2,
+			recipient_name = $3,
+			signature_url = $4,
+			updated_at = $5
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, proofURL, recipientName, signatureURL, time.Now())
+	return err
+}
+
+// SetDeliveryPIN stores a newly issued delivery PIN hash and resets any previous attempts
+func (r *OrderRepository) SetDeliveryPIN(ctx context.Context, id uuid.UUID, pinHash string) error {
+	query := `
+		UPDATE orders SET
+			delivery_pin_hash = $2,
+			delivery_pin_attempts = 0,
+			delivery_pin_locked_until = NULL,
+			delivery_pin_verified_at = NULL,
+			delivery_pin_sent_at = $3,
+			updated_at = $3
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, pinHash, time.Now())
+	return err
+}
 
-	whereClause := strings.Join(conditions, " AND ")
+// ClaimDeliveryPINAttempt counts a PIN submission before it is checked, so parallel submissions cannot
+// get past the limit. The attempt that reaches maxAttempts locks further ones; verifying the PIN
+// clears the lock again. Once a lock has expired the count starts over.
+// It returns the attempt count and the lock expiry if this attempt set it. While PIN entry is locked
+// the attempt is refused with ErrDeliveryPINLocked and the lock expiry.
+func (r *OrderRepository) ClaimDeliveryPINAttempt(ctx context.Context, id uuid.UUID, maxAttempts int, lockout time.Duration) (int, *time.Time, error) {
+	query := `
+		UPDATE orders SET
+			delivery_pin_attempts = CASE
+				WHEN delivery_pin_locked_until IS NULL THEN delivery_pin_attempts + 1
+				ELSE 1
+			END,
+			delivery_pin_locked_until = CASE
+				WHEN (CASE WHEN delivery_pin_locked_until IS NULL THEN delivery_pin_attempts + 1 ELSE 1 END) >= $2
+					THEN $3::timestamptz
+				ELSE NULL
+			END,
+			updated_at = $4
+		WHERE id = $1 AND (delivery_pin_locked_until IS NULL OR delivery_pin_locked_until <= $4)
+		RETURNING delivery_pin_attempts, delivery_pin_locked_until
+	`
 
-	// Count total
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM orders WHERE %s", whereClause)
-	var totalCount int
-	err := r.db.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
+	now := time.Now()
+	var attempts int
+	var lockedUntil *time.Time
+	err := r.db.QueryRow(ctx, query, id, maxAttempts, now.Add(lockout), now).Scan(&attempts, &lockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := r.db.QueryRow(ctx, `SELECT delivery_pin_locked_until FROM orders WHERE id = $1`, id).Scan(&lockedUntil); err != nil {
+			return 0, nil, err
+		}
+		return maxAttempts, lockedUntil, ErrDeliveryPINLocked
+	}
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
+	return attempts, lockedUntil, nil
+}
 
-	// Set defaults for pagination
-	if filters.Page <= 0 {
-		filters.Page = 1
+// MarkDeliveryPINVerified records that the driver submitted the correct delivery PIN
+func (r *OrderRepository) MarkDeliveryPINVerified(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE orders SET
+			delivery_pin_verified_at = $2,
+			delivery_pin_attempts = 0,
+			delivery_pin_locked_until = NULL,
+			updated_at = $2
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, time.Now())
+	return err
+}
+
+// LogDeliveryPINAttempt keeps an audit trail of every PIN submission
+func (r *OrderRepository) LogDeliveryPINAttempt(ctx context.Context, orderID, courierID uuid.UUID, success bool, ipAddress string) error {
+	query := `
+		INSERT INTO delivery_pin_attempts (id, order_id, courier_id, success, ip_address, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.Exec(ctx, query, uuid.New(), orderID, courierID, success, ipAddress, time.Now())
+	return err
+}
+
+// CancelOrder applies a cancellation status change together with who cancelled the order, why, the
+// fee and refund, and the couriers' wallet settlement, in one transaction. Earnings credited for the
+// order are reversed and the courier's share of the fee is credited. Like TransitionStatus it only
+// applies while the order is still in its loaded status and assigned to the same courier; otherwise
+// ErrOrderStatusChanged is returned.
+func (r *OrderRepository) CancelOrder(ctx context.Context, order *models.Order, change models.StatusChange, cancellation *models.OrderCancellation) error {
+	changeJSON, err := json.Marshal([]models.StatusChange{change})
+	if err != nil {
+		return err
 	}
-	if filters.PageSize <= 0 {
-		filters.PageSize = 20
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	// Sort
-	sortBy := "created_at"
-	if filters.SortBy != "" {
-		sortBy = filters.SortBy
+	result, err := tx.Exec(ctx, `
+		UPDATE orders SET
+			status = $3,
+			status_history = COALESCE(status_history, '[]'::jsonb) || $4::jsonb,
+			payment_status = CASE WHEN $7 THEN 'refunded' ELSE payment_status END,
+			cancelled_by = $8,
+			cancellation_reason = $9,
+			cancellation_fee = $10,
+			refund_amount = $11,
+			cancelled_at = $5,
+			updated_at = $5
+		WHERE id = $1 AND status = $2
+			AND courier_id IS NOT DISTINCT FROM NULLIF($6::uuid, '00000000-0000-0000-0000-000000000000')
+	`, order.ID, order.Status, change.Status, changeJSON, change.Timestamp, order.CourierID,
+		cancellation.RefundPayment, cancellation.CancelledBy, cancellation.Reason, cancellation.Fee, cancellation.Refund)
+	if err != nil {
+		return err
 	}
-	sortOrder := "DESC"
-	if filters.SortOrder == "asc" {
-		sortOrder = "ASC"
+	if result.RowsAffected() == 0 {
+		return ErrOrderStatusChanged
 	}
 
-	offset := (filters.Page - 1) * filters.PageSize
-
-	// Main query
-	query := fmt.Sprintf(`
-		SELECT id, order_number, customer_name, customer_phone,
-			pickup_address, delivery_address, package_size,
-			distance, total_fare, status, payment_status, created_at
-		FROM orders
-		WHERE %s
-		ORDER BY %s %s
-		LIMIT $%d OFFSET $%d
-	`, whereClause, sortBy, sortOrder, argIndex, argIndex+1)
+	if err := settleCancelledOrder(ctx, tx, order, cancellation.CourierFeeShare); err != nil {
+		return fmt.Errorf("failed to settle courier wallets: %w", err)
+	}
 
-	args = append(args, filters.PageSize, offset)
+	return tx.Commit(ctx)
+}
 
-	rows, err := r.db.Query(ctx, query, args...)
+// settleCancelledOrder reverses the earnings credited for the order, including the shares of couriers
+// who handed it over, and credits the current courier's share of the cancellation fee
+func settleCancelledOrder(ctx context.Context, tx pgx.Tx, order *models.Order, feeShare float64) error {
+	rows, err := tx.Query(ctx, `
+		SELECT courier_id, SUM(amount) FROM wallet_transactions
+		WHERE order_id = $1 AND type = 'earning'
+		GROUP BY courier_id
+		HAVING SUM(amount) <> 0
+		ORDER BY courier_id
+	`, order.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-
-	var orders []models.Order
+	credited := make(map[uuid.UUID]float64)
+	var couriers []uuid.UUID
 	for rows.Next() {
-		var o models.Order
-		err := rows.Scan(
-			&o.ID,
-			&o.OrderNumber,
-			&o.CustomerName,
-			&o.CustomerPhone,
-			&o.PickupAddress,
-			&o.DeliveryAddress,
-			&o.PackageSize,
-			&o.Distance,
-			&o.TotalFare,
-			&o.Status,
-			&o.PaymentStatus,
-			&o.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
+		var courierID uuid.UUID
+		var amount float64
+		if err := rows.Scan(&courierID, &amount); err != nil {
+			rows.Close()
+			return err
 		}
-		orders = append(orders, o)
+		credited[courierID] = amount
+		couriers = append(couriers, courierID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
-	totalPages := (totalCount + filters.PageSize - 1) / filters.PageSize
-
-	return &models.OrderListResponse{
-		Orders:     orders,
-		TotalCount: totalCount,
-		Page:       filters.Page,
-		PageSize:   filters.PageSize,
-		TotalPages: totalPages,
-	}, nil
-}
+	for _, courierID := range couriers {
+		balance, err := lockWalletPendingBalance(ctx, tx, courierID)
+		if err != nil {
+			return err
+		}
+		reversal := &models.WalletTransaction{
+			ID:            uuid.New(),
+			CourierID:     courierID,
+			OrderID:       &order.ID,
+			Type:          "refund",
+			Amount:        -credited[courierID],
+			BalanceBefore: balance,
+			BalanceAfter:  balance - credited[courierID],
+			Description:   fmt.Sprintf("Earnings reversed for cancelled order %s", order.OrderNumber),
+			Reference:     order.OrderNumber,
+		}
+		if err := addPendingWalletTransaction(ctx, tx, reversal, 0); err != nil {
+			return err
+		}
+	}
 
-// UpdateStatus updates the order status
-func (r *OrderRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.OrderStatus) error {
-	query := `UPDATE orders SET status = $2, updated_at = $3 WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, id, status, time.Now())
-	return err
-}
+	if feeShare > 0 && order.CourierID != uuid.Nil {
+		balance, err := lockWalletPendingBalance(ctx, tx, order.CourierID)
+		if err != nil {
+			return err
+		}
+		credit := &models.WalletTransaction{
+			ID:            uuid.New(),
+			CourierID:     order.CourierID,
+			OrderID:       &order.ID,
+			Type:          "adjustment",
+			Amount:        feeShare,
+			BalanceBefore: balance,
+			BalanceAfter:  balance + feeShare,
+			Description:   fmt.Sprintf("Cancellation fee for order %s", order.OrderNumber),
+			Reference:     order.OrderNumber,
+		}
+		if err := addPendingWalletTransaction(ctx, tx, credit, feeShare); err != nil {
+			return err
+		}
+	}
 
-// UpdatePaymentStatus updates the payment status and reference
-func (r *OrderRepository) UpdatePaymentStatus(ctx context.Context, id uuid.UUID, status string, reference string) error {
-	query := `UPDATE orders SET payment_status = $2, payment_reference = $3, updated_at = $4 WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, id, status, reference, time.Now())
-	return err
+	return nil
 }
 
-// UpdateDeliveryProof updates delivery proof information
-func (r *OrderRepository) UpdateDeliveryProof(ctx context.Context, id uuid.UUID, proofURL, recipientName, signatureURL string) error {
+// SaveRating records a customer rating on a delivered order that has not been rated yet
+func (r *OrderRepository) SaveRating(ctx context.Context, id uuid.UUID, rating int, feedback, ratedBy string) error {
 	query := `
 		UPDATE orders SET
-			delivery_proof_url = $2,
-			recipient_name = $3,
-			signature_url = $4,
-			actual_delivery = $5,
+			customer_rating = $2,
+			customer_feedback = $3,
+			rated_by = $4,
+			rated_at = $5,
 			updated_at = $5
-		WHERE id = $1
+		WHERE id = $1 AND status = 'delivered' AND customer_rating IS NULL
 	`
-	_, err := r.db.Exec(ctx, query, id, proofURL, recipientName, signatureURL, time.Now())
-	return err
+	result, err := r.db.Exec(ctx, query, id, rating, feedback, ratedBy, time.Now())
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOrderNotRateable
+	}
+	return nil
+}
+
+// GetCourierStats aggregates delivery outcomes and ratings across all of a courier's orders
+func (r *OrderRepository) GetCourierStats(ctx context.Context, courierID uuid.UUID) (*models.CourierStats, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'delivered') as delivered,
+			COUNT(*) FILTER (WHERE status = 'failed') as failed,
+			COUNT(customer_rating) as reviews,
+			COALESCE(AVG(customer_rating), 0) as rating
+		FROM orders
+		WHERE courier_id = $1
+	`
+
+	var delivered, failed int
+	var stats models.CourierStats
+	err := r.db.QueryRow(ctx, query, courierID).Scan(&delivered, &failed, &stats.TotalReviews, &stats.Rating)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.TotalDeliveries = delivered
+	stats.Rating = math.Round(stats.Rating*100) / 100
+	if delivered+failed > 0 {
+		stats.SuccessRate = math.Round(float64(delivered)/float64(delivered+failed)*10000) / 100
+	}
+	return &stats, nil
 }
 
 // GetDailyStats retrieves daily statistics for a courier
@@ -438,3 +729,11 @@ func generateOrderNumber() string {
 	unique := uuid.New().String()[:8]
 	return fmt.Sprintf("NYG-%s-%s", timestamp, strings.ToUpper(unique))
 }
+
+// marshalMetadata encodes order metadata for the JSONB column; orders without metadata store NULL
+func marshalMetadata(metadata map[string]any) ([]byte, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(metadata)
+}
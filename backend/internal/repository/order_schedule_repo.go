@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scheduled pickup claims mark each order in the same statement that selects it, and
+// FOR UPDATE SKIP LOCKED keeps concurrent schedulers on other instances from picking the
+// same rows, so every reminder and overdue flag is handled by exactly one instance.
+
+// ClaimPickupReminders marks and returns scheduled orders whose pickup is before remindBefore
+// and whose courier has not been reminded yet
+func (r *OrderRepository) ClaimPickupReminders(ctx context.Context, remindBefore time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		UPDATE orders SET pickup_reminder_sent_at = NOW()
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE scheduled_pickup IS NOT NULL
+				AND scheduled_pickup <= $1
+				AND pickup_reminder_sent_at IS NULL
+				AND status IN ('scheduled', 'pending', 'accepted')
+			ORDER BY scheduled_pickup
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`
+	return r.claimIDs(ctx, query, remindBefore, limit)
+}
+
+// ListDueScheduled returns dormant orders whose pickup is before activateBefore.
+// Activation itself goes through the conditional status transition, so it also happens only once.
+func (r *OrderRepository) ListDueScheduled(ctx context.Context, activateBefore time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM orders
+		WHERE status = 'scheduled' AND scheduled_pickup <= $1
+		ORDER BY scheduled_pickup
+		LIMIT $2
+	`
+	return r.claimIDs(ctx, query, activateBefore, limit)
+}
+
+// ClaimOverduePickups marks and returns scheduled orders whose pickup time has passed
+// without the driver starting live tracking
+func (r *OrderRepository) ClaimOverduePickups(ctx context.Context, now time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		UPDATE orders SET pickup_overdue_at = NOW()
+		WHERE id IN (
+			SELECT o.id FROM orders o
+			WHERE o.scheduled_pickup IS NOT NULL
+				AND o.scheduled_pickup <= $1
+				AND o.pickup_overdue_at IS NULL
+				AND o.status IN ('scheduled', 'pending', 'accepted', 'picked_up')
+				AND NOT EXISTS (SELECT 1 FROM delivery_tracking t WHERE t.order_id = o.id)
+			ORDER BY o.scheduled_pickup
+			LIMIT $2
+			FOR UPDATE OF o SKIP LOCKED
+		)
+		RETURNING id
+	`
+	return r.claimIDs(ctx, query, now, limit)
+}
+
+func (r *OrderRepository) claimIDs(ctx context.Context, query string, before time.Time, limit int) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
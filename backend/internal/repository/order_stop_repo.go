@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// ErrStopStatusChanged is returned when a stop has already been completed
+var ErrStopStatusChanged = errors.New("stop has already been completed")
+
+const stopColumns = `
+	id, order_id, sequence, status, recipient_name, recipient_phone,
+	address, latitude, longitude, COALESCE(notes, '') as notes,
+	COALESCE(package_description, '') as package_description, cod_amount, cod_collected,
+	distance_from_previous, estimated_arrival,
+	COALESCE(delivery_proof_url, '') as delivery_proof_url,
+	COALESCE(signature_url, '') as signature_url,
+	COALESCE(received_by, '') as received_by,
+	COALESCE(failure_reason, '') as failure_reason,
+	completed_at, created_at, updated_at
+`
+
+// insertStop writes a new pending stop as part of an order's creation
+func insertStop(ctx context.Context, tx pgx.Tx, orderID uuid.UUID, stop *models.OrderStop, now time.Time) error {
+	query := `
+		INSERT INTO order_stops (
+			id, order_id, sequence, status, recipient_name, recipient_phone,
+			address, latitude, longitude, notes, package_description, cod_amount,
+			distance_from_previous, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`
+
+	stop.ID = uuid.New()
+	stop.OrderID = orderID
+	stop.Status = models.StopStatusPending
+	stop.CreatedAt = now
+	stop.UpdatedAt = now
+
+	_, err := tx.Exec(ctx, query,
+		stop.ID,
+		stop.OrderID,
+		stop.Sequence,
+		stop.Status,
+		stop.RecipientName,
+		stop.RecipientPhone,
+		stop.Address,
+		stop.Latitude,
+		stop.Longitude,
+		stop.Notes,
+		stop.PackageDescription,
+		stop.CODAmount,
+		stop.DistanceFromPrevious,
+		stop.CreatedAt,
+		stop.UpdatedAt,
+	)
+	return err
+}
+
+// GetStops retrieves the stops of a multi-stop order in route order
+func (r *OrderRepository) GetStops(ctx context.Context, orderID uuid.UUID) ([]models.OrderStop, error) {
+	query := `SELECT ` + stopColumns + ` FROM order_stops WHERE order_id = $1 ORDER BY sequence`
+
+	rows, err := r.db.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stops []models.OrderStop
+	for rows.Next() {
+		stop, err := scanStop(rows)
+		if err != nil {
+			return nil, err
+		}
+		stops = append(stops, *stop)
+	}
+	return stops, rows.Err()
+}
+
+// GetStop retrieves a single stop belonging to an order
+func (r *OrderRepository) GetStop(ctx context.Context, orderID, stopID uuid.UUID) (*models.OrderStop, error) {
+	query := `SELECT ` + stopColumns + ` FROM order_stops WHERE id = $1 AND order_id = $2`
+	return scanStop(r.db.QueryRow(ctx, query, stopID, orderID))
+}
+
+// CompleteStop marks a pending stop delivered or failed, recording the cash collected at the stop.
+// ErrStopStatusChanged is returned if the stop was completed in the meantime.
+func (r *OrderRepository) CompleteStop(ctx context.Context, stopID uuid.UUID, status models.StopStatus, failureReason string, codCollected float64) (*time.Time, error) {
+	query := `
+		UPDATE order_stops SET
+			status = $2,
+			failure_reason = NULLIF($3, ''),
+			cod_collected = $5,
+			completed_at = $4,
+			updated_at = $4
+		WHERE id = $1 AND status = 'pending'
+	`
+
+	now := time.Now()
+	result, err := r.db.Exec(ctx, query, stopID, status, failureReason, now, codCollected)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrStopStatusChanged
+	}
+	return &now, nil
+}
+
+// UpdateStopProof records the proof-of-delivery keys for a stop
+func (r *OrderRepository) UpdateStopProof(ctx context.Context, stopID uuid.UUID, proofURL, receivedBy, signatureURL string) error {
+	query := `
+		UPDATE order_stops SET
+			delivery_proof_url = $2,
+			received_by = $3,
+			signature_url = $4,
+			updated_at = $5
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, stopID, proofURL, receivedBy, signatureURL, time.Now())
+	return err
+}
+
+// UpdateStopETAs stores the latest arrival estimates for an order's remaining stops
+func (r *OrderRepository) UpdateStopETAs(ctx context.Context, etas []models.StopETA) error {
+	batch := &pgx.Batch{}
+	for _, eta := range etas {
+		batch.Queue(`UPDATE order_stops SET estimated_arrival = $2, updated_at = NOW() WHERE id = $1 AND status = 'pending'`,
+			eta.StopID, eta.EstimatedArrival)
+	}
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+func scanStop(row pgx.Row) (*models.OrderStop, error) {
+	var stop models.OrderStop
+	err := row.Scan(
+		&stop.ID,
+		&stop.OrderID,
+		&stop.Sequence,
+		&stop.Status,
+		&stop.RecipientName,
+		&stop.RecipientPhone,
+		&stop.Address,
+		&stop.Latitude,
+		&stop.Longitude,
+		&stop.Notes,
+		&stop.PackageDescription,
+		&stop.CODAmount,
+		&stop.CODCollected,
+		&stop.DistanceFromPrevious,
+		&stop.EstimatedArrival,
+		&stop.DeliveryProofURL,
+		&stop.SignatureURL,
+		&stop.ReceivedBy,
+		&stop.FailureReason,
+		&stop.CompletedAt,
+		&stop.CreatedAt,
+		&stop.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &stop, nil
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"nyengo-deliveries/internal/models"
+)
+
+var (
+	// ErrTransferPending is returned when an order already has a handover waiting for an answer
+	ErrTransferPending = errors.New("order already has a transfer waiting for an answer")
+	// ErrTransferClosed is returned when a transfer is no longer waiting for an answer
+	ErrTransferClosed = errors.New("transfer has already been answered")
+)
+
+const transferColumns = `
+	t.id, t.order_id, o.order_number, t.from_courier_id, t.to_courier_id, t.requested_by,
+	COALESCE(t.reason, '') as reason, t.status, t.order_status, COALESCE(t.earnings_rule, '') as earnings_rule,
+	t.original_earnings, t.from_earnings, t.to_earnings,
+	COALESCE(t.response_note, '') as response_note, t.expires_at, t.responded_at, t.created_at
+`
+
+// CreateTransfer records a proposed handover. Handovers of the order that were never answered and
+// have expired are closed first; if another is still waiting, ErrTransferPending is returned.
+func (r *OrderRepository) CreateTransfer(ctx context.Context, transfer *models.OrderTransfer) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	if _, err := tx.Exec(ctx, `
+		UPDATE order_transfers SET status = 'expired', responded_at = $2
+		WHERE order_id = $1 AND status = 'pending' AND expires_at <= $2
+	`, transfer.OrderID, now); err != nil {
+		return err
+	}
+
+	transfer.ID = uuid.New()
+	transfer.Status = models.TransferStatusPending
+	transfer.CreatedAt = now
+	result, err := tx.Exec(ctx, `
+		INSERT INTO order_transfers (
+			id, order_id, from_courier_id, to_courier_id, requested_by, reason, status,
+			order_status, original_earnings, expires_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11)
+		ON CONFLICT (order_id) WHERE status = 'pending' DO NOTHING
+	`,
+		transfer.ID, transfer.OrderID, transfer.FromCourierID, transfer.ToCourierID, transfer.RequestedBy,
+		transfer.Reason, transfer.Status, transfer.OrderStatus, transfer.OriginalEarnings,
+		transfer.ExpiresAt, transfer.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTransferPending
+	}
+	return tx.Commit(ctx)
+}
+
+// CompleteTransfer hands the order to the receiving courier with its new earnings, marks the
+// transfer accepted, credits the handing-over courier's share to their wallet and releases the
+// order's driver assignment, in one transaction. The order must
+// still belong to the handing-over courier, be in one of the transferable statuses and not have
+// cash on delivery collected (otherwise ErrOrderModified), and the transfer must still be pending
+// (otherwise ErrTransferClosed).
+func (r *OrderRepository) CompleteTransfer(ctx context.Context, transfer *models.OrderTransfer, transferable []models.OrderStatus) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	statuses := make([]string, len(transferable))
+	for i, status := range transferable {
+		statuses[i] = string(status)
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(ctx, `
+		UPDATE orders SET courier_id = $3, courier_earnings = $4, updated_at = $5
+		WHERE id = $1 AND courier_id = $2 AND status = ANY($6)
+			AND COALESCE(cod_status, '') NOT IN ('collected', 'remitting')
+	`, transfer.OrderID, transfer.FromCourierID, transfer.ToCourierID, transfer.ToEarnings, now, statuses)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOrderModified
+	}
+
+	result, err = tx.Exec(ctx, `
+		UPDATE order_transfers SET
+			status = 'accepted', earnings_rule = $2, original_earnings = $3,
+			from_earnings = $4, to_earnings = $5, responded_at = $6
+		WHERE id = $1 AND status = 'pending'
+	`, transfer.ID, transfer.EarningsRule, transfer.OriginalEarnings, transfer.FromEarnings, transfer.ToEarnings, now)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTransferClosed
+	}
+
+	if transfer.FromEarnings > 0 {
+		balance, err := lockWalletPendingBalance(ctx, tx, transfer.FromCourierID)
+		if err != nil {
+			return err
+		}
+		share := &models.WalletTransaction{
+			ID:            uuid.New(),
+			CourierID:     transfer.FromCourierID,
+			OrderID:       &transfer.OrderID,
+			Type:          "earning",
+			Amount:        transfer.FromEarnings,
+			BalanceBefore: balance,
+			BalanceAfter:  balance + transfer.FromEarnings,
+			Description:   fmt.Sprintf("Share of order %s handed over to another courier", transfer.OrderNumber),
+			Reference:     transfer.OrderNumber,
+		}
+		if err := addPendingWalletTransaction(ctx, tx, share, 0); err != nil {
+			return err
+		}
+	}
+
+	// The handing-over courier's driver no longer works the order
+	if _, err := tx.Exec(ctx, `
+		UPDATE driver_assignments SET status = 'released', released_at = $2
+		WHERE order_id = $1 AND status IN ('pending', 'accepted')
+	`, transfer.OrderID, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	transfer.Status = models.TransferStatusAccepted
+	transfer.RespondedAt = &now
+	return nil
+}
+
+// CloseTransfer ends a pending transfer as rejected, cancelled or expired.
+// ErrTransferClosed is returned if it was already answered.
+func (r *OrderRepository) CloseTransfer(ctx context.Context, transfer *models.OrderTransfer, status models.TransferStatus, note string) error {
+	query := `
+		UPDATE order_transfers SET status = $2, response_note = NULLIF($3, ''), responded_at = $4
+		WHERE id = $1 AND status = 'pending'
+	`
+
+	now := time.Now()
+	result, err := r.db.Exec(ctx, query, transfer.ID, status, note, now)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTransferClosed
+	}
+	transfer.Status = status
+	transfer.ResponseNote = note
+	transfer.RespondedAt = &now
+	return nil
+}
+
+// GetTransfer retrieves a transfer by ID
+func (r *OrderRepository) GetTransfer(ctx context.Context, id uuid.UUID) (*models.OrderTransfer, error) {
+	query := `SELECT ` + transferColumns + ` FROM order_transfers t JOIN orders o ON o.id = t.order_id WHERE t.id = $1`
+	return scanTransfer(r.db.QueryRow(ctx, query, id))
+}
+
+// ListTransfers retrieves an order's handover log, oldest first
+func (r *OrderRepository) ListTransfers(ctx context.Context, orderID uuid.UUID) ([]models.OrderTransfer, error) {
+	query := `SELECT ` + transferColumns + ` FROM order_transfers t JOIN orders o ON o.id = t.order_id
+		WHERE t.order_id = $1 ORDER BY t.created_at`
+	return r.queryTransfers(ctx, query, orderID)
+}
+
+// ListIncomingTransfers retrieves the unexpired handovers waiting for the courier's answer, oldest first
+func (r *OrderRepository) ListIncomingTransfers(ctx context.Context, courierID uuid.UUID, now time.Time) ([]models.OrderTransfer, error) {
+	query := `SELECT ` + transferColumns + ` FROM order_transfers t JOIN orders o ON o.id = t.order_id
+		WHERE t.to_courier_id = $1 AND t.status = 'pending' AND t.expires_at > $2 ORDER BY t.created_at`
+	return r.queryTransfers(ctx, query, courierID, now)
+}
+
+func (r *OrderRepository) queryTransfers(ctx context.Context, query string, args ...interface{}) ([]models.OrderTransfer, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []models.OrderTransfer{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *transfer)
+	}
+	return transfers, rows.Err()
+}
+
+func scanTransfer(row pgx.Row) (*models.OrderTransfer, error) {
+	var transfer models.OrderTransfer
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.OrderID,
+		&transfer.OrderNumber,
+		&transfer.FromCourierID,
+		&transfer.ToCourierID,
+		&transfer.RequestedBy,
+		&transfer.Reason,
+		&transfer.Status,
+		&transfer.OrderStatus,
+		&transfer.EarningsRule,
+		&transfer.OriginalEarnings,
+		&transfer.FromEarnings,
+		&transfer.ToEarnings,
+		&transfer.ResponseNote,
+		&transfer.ExpiresAt,
+		&transfer.RespondedAt,
+		&transfer.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
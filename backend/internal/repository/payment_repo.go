@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"nyengo-deliveries/internal/models"
@@ -227,7 +228,7 @@ func (r *PaymentRepository) LinkOrdersToPayout(ctx context.Context, payoutID uui
 // GetCourierWallet retrieves or creates a courier's wallet
 func (r *PaymentRepository) GetCourierWallet(ctx context.Context, courierID uuid.UUID) (*models.CourierWallet, error) {
 	query := `
-		SELECT courier_id, available_balance, pending_balance, total_earnings, currency, updated_at
+		SELECT courier_id, available_balance, pending_balance, total_earnings, cash_liability, currency, updated_at
 		FROM courier_wallets WHERE courier_id = $1
 	`
 
@@ -237,6 +238,7 @@ func (r *PaymentRepository) GetCourierWallet(ctx context.Context, courierID uuid
 		&wallet.AvailableBalance,
 		&wallet.PendingBalance,
 		&wallet.TotalEarnings,
+		&wallet.CashLiability,
 		&wallet.Currency,
 		&wallet.UpdatedAt,
 	)
@@ -247,13 +249,14 @@ func (r *PaymentRepository) GetCourierWallet(ctx context.Context, courierID uuid
 			INSERT INTO courier_wallets (courier_id, available_balance, pending_balance, total_earnings, currency, updated_at)
 			VALUES ($1, 0, 0, 0, 'ZMW', NOW())
 			ON CONFLICT (courier_id) DO NOTHING
-			RETURNING courier_id, available_balance, pending_balance, total_earnings, currency, updated_at
+			RETURNING courier_id, available_balance, pending_balance, total_earnings, cash_liability, currency, updated_at
 		`
 		err = r.db.QueryRow(ctx, createQuery, courierID).Scan(
 			&wallet.CourierID,
 			&wallet.AvailableBalance,
 			&wallet.PendingBalance,
 			&wallet.TotalEarnings,
+			&wallet.CashLiability,
 			&wallet.Currency,
 			&wallet.UpdatedAt,
 		)
@@ -347,10 +350,105 @@ func (r *PaymentRepository) GetWalletTransactions(ctx context.Context, courierID
 	return transactions, nil
 }
 
+// StreamWalletTransactions calls fn for each of the courier's wallet transactions in the period, oldest first.
+// Rows are read from the open cursor one at a time so exports of any size use constant memory.
+func (r *PaymentRepository) StreamWalletTransactions(ctx context.Context, courierID uuid.UUID, from, to *time.Time, fn func(*models.WalletTransaction) error) error {
+	query := `
+		SELECT id, courier_id, order_id, payout_id, type, amount,
+			   balance_before, balance_after, description, reference, created_at
+		FROM wallet_transactions
+		WHERE courier_id = $1
+		AND ($2::timestamptz IS NULL OR created_at >= $2)
+		AND ($3::timestamptz IS NULL OR created_at <= $3)
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, courierID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tx models.WalletTransaction
+		if err := rows.Scan(
+			&tx.ID,
+			&tx.CourierID,
+			&tx.OrderID,
+			&tx.PayoutID,
+			&tx.Type,
+			&tx.Amount,
+			&tx.BalanceBefore,
+			&tx.BalanceAfter,
+			&tx.Description,
+			&tx.Reference,
+			&tx.CreatedAt,
+		); err != nil {
+			return err
+		}
+		if err := fn(&tx); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamPayouts calls fn for each of the courier's payouts in the period, oldest first
+func (r *PaymentRepository) StreamPayouts(ctx context.Context, courierID uuid.UUID, status string, from, to *time.Time, fn func(*models.Payout) error) error {
+	query := `
+		SELECT id, courier_id, order_ids, total_amount, platform_fee, net_amount,
+			   currency, status, payout_method, payout_details, transaction_ref,
+			   failure_reason, processed_at, completed_at, created_at, updated_at
+		FROM payouts
+		WHERE courier_id = $1
+		AND ($2 = '' OR status = $2)
+		AND ($3::timestamptz IS NULL OR created_at >= $3)
+		AND ($4::timestamptz IS NULL OR created_at <= $4)
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, courierID, status, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payout models.Payout
+		var orderIDsJSON []byte
+		if err := rows.Scan(
+			&payout.ID,
+			&payout.CourierID,
+			&orderIDsJSON,
+			&payout.TotalAmount,
+			&payout.PlatformFee,
+			&payout.NetAmount,
+			&payout.Currency,
+			&payout.Status,
+			&payout.PayoutMethod,
+			&payout.PayoutDetails,
+			&payout.TransactionRef,
+			&payout.FailureReason,
+			&payout.ProcessedAt,
+			&payout.CompletedAt,
+			&payout.CreatedAt,
+			&payout.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		json.Unmarshal(orderIDsJSON, &payout.OrderIDs)
+		if err := fn(&payout); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // GetStorePaymentConfig retrieves payment config for a store
 func (r *PaymentRepository) GetStorePaymentConfig(ctx context.Context, storeID uuid.UUID) (*models.StorePaymentConfig, error) {
 	query := `
-		SELECT store_id, store_name, payment_api_url, api_key, webhook_secret, is_active
+		SELECT store_id, store_name, payment_api_url, api_key, webhook_secret,
+			COALESCE(webhook_url, '') as webhook_url, is_active
 		FROM store_payment_configs WHERE store_id = $1 AND is_active = true
 	`
 
@@ -361,6 +459,7 @@ func (r *PaymentRepository) GetStorePaymentConfig(ctx context.Context, storeID u
 		&config.PaymentAPIURL,
 		&config.APIKey,
 		&config.WebhookSecret,
+		&config.WebhookURL,
 		&config.IsActive,
 	)
 
@@ -410,3 +509,44 @@ func (r *PaymentRepository) GetEarningsSummary(ctx context.Context, courierID uu
 		Currency:         wallet.Currency,
 	}, nil
 }
+
+// lockWalletPendingBalance locks a courier's wallet for the rest of the transaction, creating it if
+// needed, and returns its pending balance
+func lockWalletPendingBalance(ctx context.Context, tx pgx.Tx, courierID uuid.UUID) (float64, error) {
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO courier_wallets (courier_id, available_balance, pending_balance, total_earnings, currency, updated_at)
+		VALUES ($1, 0, 0, 0, 'ZMW', NOW())
+		ON CONFLICT (courier_id) DO NOTHING
+	`, courierID); err != nil {
+		return 0, err
+	}
+
+	var pending float64
+	err := tx.QueryRow(ctx, `SELECT pending_balance FROM courier_wallets WHERE courier_id = $1 FOR UPDATE`, courierID).Scan(&pending)
+	return pending, err
+}
+
+// addPendingWalletTransaction records a wallet transaction against the pending balance and applies
+// its amount to it, adding totalDelta to the courier's total earnings
+func addPendingWalletTransaction(ctx context.Context, tx pgx.Tx, wt *models.WalletTransaction, totalDelta float64) error {
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO wallet_transactions (
+			id, courier_id, order_id, payout_id, type, amount,
+			balance_before, balance_after, description, reference, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`,
+		wt.ID, wt.CourierID, wt.OrderID, wt.PayoutID, wt.Type, wt.Amount,
+		wt.BalanceBefore, wt.BalanceAfter, wt.Description, wt.Reference, time.Now(),
+	); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `
+		UPDATE courier_wallets SET
+			pending_balance = pending_balance + $2,
+			total_earnings = total_earnings + $3,
+			updated_at = NOW()
+		WHERE courier_id = $1
+	`, wt.CourierID, wt.Amount, totalDelta)
+	return err
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// ScanRepository handles parcel label scans
+type ScanRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewScanRepository creates a new parcel scan repository
+func NewScanRepository(db *pgxpool.Pool) *ScanRepository {
+	return &ScanRepository{db: db}
+}
+
+// Create records a scan
+func (r *ScanRepository) Create(ctx context.Context, scan *models.ParcelScan) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO parcel_scans (
+			id, order_id, courier_id, driver_id, event, actor_type, actor_name, location,
+			latitude, longitude, note, status_before, status_after, scanned_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`,
+		scan.ID, scan.OrderID, scan.CourierID, scan.DriverID, scan.Event, scan.ActorType, scan.ActorName, scan.Location,
+		scan.Latitude, scan.Longitude, scan.Note, scan.StatusBefore, scan.StatusAfter, scan.ScannedAt,
+	)
+	return err
+}
+
+// ListByOrder returns an order's scans, oldest first
+func (r *ScanRepository) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]models.ParcelScan, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, order_id, courier_id, driver_id, event, actor_type, COALESCE(actor_name, '') as actor_name,
+			COALESCE(location, '') as location, latitude, longitude, COALESCE(note, '') as note,
+			status_before, status_after, scanned_at
+		FROM parcel_scans
+		WHERE order_id = $1
+		ORDER BY scanned_at, id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scans := []models.ParcelScan{}
+	for rows.Next() {
+		var s models.ParcelScan
+		if err := rows.Scan(
+			&s.ID, &s.OrderID, &s.CourierID, &s.DriverID, &s.Event, &s.ActorType, &s.ActorName,
+			&s.Location, &s.Latitude, &s.Longitude, &s.Note,
+			&s.StatusBefore, &s.StatusAfter, &s.ScannedAt,
+		); err != nil {
+			return nil, err
+		}
+		scans = append(scans, s)
+	}
+	return scans, rows.Err()
+}
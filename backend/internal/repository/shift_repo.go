@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// ErrShiftNotOpen is returned when the courier account or driver is not online
+var ErrShiftNotOpen = errors.New("not online")
+
+// ShiftRepository handles the online shifts of courier accounts and their drivers
+type ShiftRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewShiftRepository creates a new shift repository
+func NewShiftRepository(db *pgxpool.Pool) *ShiftRepository {
+	return &ShiftRepository{db: db}
+}
+
+// shiftColumns selects a shift aliased s, joined to its driver aliased d
+const shiftColumns = `
+	s.id, s.courier_id, s.driver_id, COALESCE(d.name, '') as driver_name, s.started_at, s.last_heartbeat_at,
+	s.ended_at, COALESCE(s.end_reason, '') as end_reason
+`
+
+// openShift matches the open shift of the courier account ($2 NULL) or of a driver
+const openShift = `courier_id = $1 AND driver_id IS NOT DISTINCT FROM $2::uuid AND ended_at IS NULL`
+
+// Start opens a shift for the courier account (driverID nil) or a driver. If one is already open it
+// is returned instead, with started false.
+func (r *ShiftRepository) Start(ctx context.Context, courierID uuid.UUID, driverID *uuid.UUID, now time.Time) (*models.Shift, bool, error) {
+	query := `
+		INSERT INTO shifts (id, courier_id, driver_id, started_at, last_heartbeat_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (courier_id, COALESCE(driver_id, '00000000-0000-0000-0000-000000000000'::uuid))
+			WHERE ended_at IS NULL DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query, uuid.New(), courierID, driverID, now)
+	if err != nil {
+		return nil, false, err
+	}
+
+	shift, err := r.GetOpen(ctx, courierID, driverID)
+	if err != nil {
+		return nil, false, err
+	}
+	return shift, result.RowsAffected() > 0, nil
+}
+
+// GetOpen retrieves the open shift of the courier account (driverID nil) or a driver
+func (r *ShiftRepository) GetOpen(ctx context.Context, courierID uuid.UUID, driverID *uuid.UUID) (*models.Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts s LEFT JOIN drivers d ON d.id = s.driver_id
+		WHERE s.` + openShift
+	return scanShift(r.db.QueryRow(ctx, query, courierID, driverID))
+}
+
+// Heartbeat records that the courier account (driverID nil) or driver is still online.
+// ErrShiftNotOpen is returned if they have no open shift.
+func (r *ShiftRepository) Heartbeat(ctx context.Context, courierID uuid.UUID, driverID *uuid.UUID, now time.Time) (*models.Shift, error) {
+	query := `
+		WITH updated AS (
+			UPDATE shifts SET last_heartbeat_at = $3 WHERE ` + openShift + `
+			RETURNING *
+		)
+		SELECT ` + shiftColumns + ` FROM updated s LEFT JOIN drivers d ON d.id = s.driver_id`
+	return scanShift(r.db.QueryRow(ctx, query, courierID, driverID, now))
+}
+
+// End closes the open shift of the courier account (driverID nil) or a driver.
+// ErrShiftNotOpen is returned if they have none.
+func (r *ShiftRepository) End(ctx context.Context, courierID uuid.UUID, driverID *uuid.UUID, endedAt time.Time, reason string) (*models.Shift, error) {
+	query := `
+		WITH updated AS (
+			UPDATE shifts SET ended_at = GREATEST($3, started_at), end_reason = $4 WHERE ` + openShift + `
+			RETURNING *
+		)
+		SELECT ` + shiftColumns + ` FROM updated s LEFT JOIN drivers d ON d.id = s.driver_id`
+	return scanShift(r.db.QueryRow(ctx, query, courierID, driverID, endedAt, reason))
+}
+
+// CloseStale closes open shifts whose last heartbeat is before heartbeatBefore, ending them at that
+// heartbeat, and returns them
+func (r *ShiftRepository) CloseStale(ctx context.Context, heartbeatBefore time.Time, limit int) ([]models.Shift, error) {
+	query := `
+		WITH updated AS (
+			UPDATE shifts SET ended_at = last_heartbeat_at, end_reason = 'heartbeat_timeout'
+			WHERE id IN (
+				SELECT id FROM shifts
+				WHERE ended_at IS NULL AND last_heartbeat_at < $1
+				ORDER BY last_heartbeat_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT ` + shiftColumns + ` FROM updated s LEFT JOIN drivers d ON d.id = s.driver_id`
+	return r.list(ctx, query, heartbeatBefore, limit)
+}
+
+// ListOpen retrieves the open shifts with a heartbeat after heartbeatAfter, of active couriers and
+// of drivers who are still active, optionally of one courier only
+func (r *ShiftRepository) ListOpen(ctx context.Context, courierID *uuid.UUID, heartbeatAfter time.Time) ([]models.Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts s LEFT JOIN drivers d ON d.id = s.driver_id
+		JOIN couriers c ON c.id = s.courier_id
+		WHERE s.ended_at IS NULL AND s.last_heartbeat_at > $2 AND c.is_active = true
+			AND ($1::uuid IS NULL OR s.courier_id = $1)
+			AND (s.driver_id IS NULL OR d.status = 'active')
+		ORDER BY s.courier_id, s.started_at`
+	return r.list(ctx, query, courierID, heartbeatAfter)
+}
+
+// List retrieves a courier's shifts overlapping the period, newest first, optionally of one driver only
+func (r *ShiftRepository) List(ctx context.Context, courierID uuid.UUID, driverID *uuid.UUID, from, to *time.Time, limit int) ([]models.Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts s LEFT JOIN drivers d ON d.id = s.driver_id
+		WHERE s.courier_id = $1 AND ($2::uuid IS NULL OR s.driver_id = $2)
+			AND ($3::timestamptz IS NULL OR COALESCE(s.ended_at, NOW()) > $3)
+			AND ($4::timestamptz IS NULL OR s.started_at < $4)
+		ORDER BY s.started_at DESC
+		LIMIT $5`
+	return r.list(ctx, query, courierID, driverID, from, to, limit)
+}
+
+// HoursWorked counts a courier's shifts overlapping the period, optionally of one driver only, and
+// sums the hours worked within it. Open shifts count up to now.
+func (r *ShiftRepository) HoursWorked(ctx context.Context, courierID uuid.UUID, driverID *uuid.UUID, from, to *time.Time) (int, float64, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(GREATEST(0, EXTRACT(EPOCH FROM
+			LEAST(COALESCE(ended_at, NOW()), COALESCE($4::timestamptz, NOW())) - GREATEST(started_at, COALESCE($3::timestamptz, started_at))
+		))), 0) / 3600
+		FROM shifts
+		WHERE courier_id = $1 AND ($2::uuid IS NULL OR driver_id = $2)
+			AND ($3::timestamptz IS NULL OR COALESCE(ended_at, NOW()) > $3)
+			AND ($4::timestamptz IS NULL OR started_at < $4)
+	`
+
+	var count int
+	var hours float64
+	if err := r.db.QueryRow(ctx, query, courierID, driverID, from, to).Scan(&count, &hours); err != nil {
+		return 0, 0, err
+	}
+	return count, math.Round(hours*100) / 100, nil
+}
+
+func (r *ShiftRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.Shift, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := []models.Shift{}
+	for rows.Next() {
+		shift, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, *shift)
+	}
+	return shifts, rows.Err()
+}
+
+func scanShift(row pgx.Row) (*models.Shift, error) {
+	var shift models.Shift
+	err := row.Scan(
+		&shift.ID,
+		&shift.CourierID,
+		&shift.DriverID,
+		&shift.DriverName,
+		&shift.StartedAt,
+		&shift.LastHeartbeatAt,
+		&shift.EndedAt,
+		&shift.EndReason,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrShiftNotOpen
+		}
+		return nil, err
+	}
+
+	end := time.Now()
+	if shift.EndedAt != nil {
+		end = *shift.EndedAt
+	}
+	shift.HoursWorked = math.Round(end.Sub(shift.StartedAt).Hours()*100) / 100
+	return &shift, nil
+}
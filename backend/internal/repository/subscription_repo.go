@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// SubscriptionRepository handles recurring delivery data access
+type SubscriptionRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewSubscriptionRepository creates a new subscription repository
+func NewSubscriptionRepository(db *pgxpool.Pool) *SubscriptionRepository {
+	return &SubscriptionRepository{db: db}
+}
+
+const subscriptionColumns = `
+	id, store_id, courier_id, name, template, rule, status,
+	COALESCE(skipped_dates, '[]'::jsonb) as skipped_dates,
+	generated_count, last_occurrence, ended_at, created_at, updated_at
+`
+
+// Create inserts a new subscription
+func (r *SubscriptionRepository) Create(ctx context.Context, sub *models.DeliverySubscription) error {
+	query := `
+		INSERT INTO delivery_subscriptions (
+			id, store_id, courier_id, name, template, rule, status, skipped_dates,
+			generated_count, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, '[]'::jsonb, 0, $8, $8)
+	`
+
+	sub.ID = uuid.New()
+	sub.Status = models.SubscriptionStatusActive
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = sub.CreatedAt
+
+	templateJSON, err := json.Marshal(sub.Template)
+	if err != nil {
+		return err
+	}
+	ruleJSON, err := json.Marshal(sub.Rule)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, query,
+		sub.ID,
+		sub.StoreID,
+		sub.CourierID,
+		sub.Name,
+		templateJSON,
+		ruleJSON,
+		sub.Status,
+		sub.CreatedAt,
+	)
+	return err
+}
+
+// GetByID retrieves a subscription by ID
+func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.DeliverySubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM delivery_subscriptions WHERE id = $1`
+	return scanSubscription(r.db.QueryRow(ctx, query, id))
+}
+
+// ListByStore retrieves a store's subscriptions, newest first
+func (r *SubscriptionRepository) ListByStore(ctx context.Context, storeID uuid.UUID) ([]models.DeliverySubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM delivery_subscriptions WHERE store_id = $1 ORDER BY created_at DESC`
+	return r.list(ctx, query, storeID)
+}
+
+// ListActive retrieves every subscription that is still generating orders
+func (r *SubscriptionRepository) ListActive(ctx context.Context) ([]models.DeliverySubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM delivery_subscriptions WHERE status = 'active' ORDER BY created_at`
+	return r.list(ctx, query)
+}
+
+// UpdateStatus changes a subscription's status if it is currently in one of the given statuses
+func (r *SubscriptionRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.SubscriptionStatus, from ...models.SubscriptionStatus) (bool, error) {
+	query := `
+		UPDATE delivery_subscriptions SET
+			status = $2,
+			ended_at = CASE WHEN $2 = 'ended' THEN NOW() ELSE ended_at END,
+			updated_at = NOW()
+		WHERE id = $1 AND status = ANY($3)
+	`
+
+	fromStatuses := make([]string, len(from))
+	for i, s := range from {
+		fromStatuses[i] = string(s)
+	}
+
+	result, err := r.db.Exec(ctx, query, id, status, fromStatuses)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// AddSkippedDate records an occurrence date that must not be generated
+func (r *SubscriptionRepository) AddSkippedDate(ctx context.Context, id uuid.UUID, date string) error {
+	query := `
+		UPDATE delivery_subscriptions SET
+			skipped_dates = CASE
+				WHEN COALESCE(skipped_dates, '[]'::jsonb) ? $2 THEN skipped_dates
+				ELSE COALESCE(skipped_dates, '[]'::jsonb) || to_jsonb($2::text)
+			END,
+			updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, date)
+	return err
+}
+
+// ClaimOccurrence reserves an occurrence for generation. It returns false if another
+// instance already claimed it, so each occurrence produces at most one order.
+func (r *SubscriptionRepository) ClaimOccurrence(ctx context.Context, subscriptionID uuid.UUID, occurrence time.Time) (bool, error) {
+	query := `
+		INSERT INTO subscription_occurrences (subscription_id, occurrence_at, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (subscription_id, occurrence_at) DO NOTHING
+	`
+	result, err := r.db.Exec(ctx, query, subscriptionID, occurrence)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ReleaseOccurrence removes a claim whose order could not be created so it is retried
+func (r *SubscriptionRepository) ReleaseOccurrence(ctx context.Context, subscriptionID uuid.UUID, occurrence time.Time) error {
+	query := `DELETE FROM subscription_occurrences WHERE subscription_id = $1 AND occurrence_at = $2 AND order_id IS NULL`
+	_, err := r.db.Exec(ctx, query, subscriptionID, occurrence)
+	return err
+}
+
+// RecordOccurrenceOrder links a claimed occurrence to its generated order and advances the subscription
+func (r *SubscriptionRepository) RecordOccurrenceOrder(ctx context.Context, subscriptionID uuid.UUID, occurrence time.Time, orderID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE subscription_occurrences SET order_id = $3 WHERE subscription_id = $1 AND occurrence_at = $2`,
+		subscriptionID, occurrence, orderID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE delivery_subscriptions SET
+			generated_count = generated_count + 1,
+			last_occurrence = GREATEST(COALESCE(last_occurrence, $2), $2),
+			updated_at = NOW()
+		WHERE id = $1
+	`, subscriptionID, occurrence); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetOccurrenceOrders returns the generated orders of a subscription from the given time on, keyed by occurrence
+func (r *SubscriptionRepository) GetOccurrenceOrders(ctx context.Context, subscriptionID uuid.UUID, from time.Time) (map[time.Time]uuid.UUID, error) {
+	query := `
+		SELECT occurrence_at, order_id FROM subscription_occurrences
+		WHERE subscription_id = $1 AND occurrence_at > $2 AND order_id IS NOT NULL
+	`
+	rows, err := r.db.Query(ctx, query, subscriptionID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make(map[time.Time]uuid.UUID)
+	for rows.Next() {
+		var at time.Time
+		var orderID uuid.UUID
+		if err := rows.Scan(&at, &orderID); err != nil {
+			return nil, err
+		}
+		orders[at.UTC()] = orderID
+	}
+	return orders, rows.Err()
+}
+
+func (r *SubscriptionRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.DeliverySubscription, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []models.DeliverySubscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+func scanSubscription(row pgx.Row) (*models.DeliverySubscription, error) {
+	var sub models.DeliverySubscription
+	var templateJSON, ruleJSON, skippedJSON []byte
+	err := row.Scan(
+		&sub.ID,
+		&sub.StoreID,
+		&sub.CourierID,
+		&sub.Name,
+		&templateJSON,
+		&ruleJSON,
+		&sub.Status,
+		&skippedJSON,
+		&sub.GeneratedCount,
+		&sub.LastOccurrence,
+		&sub.EndedAt,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(templateJSON, &sub.Template)
+	json.Unmarshal(ruleJSON, &sub.Rule)
+	json.Unmarshal(skippedJSON, &sub.SkippedDates)
+	return &sub, nil
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"nyengo-deliveries/internal/models"
+)
+
+var (
+	// ErrVehicleNotFound is returned when a vehicle does not exist
+	ErrVehicleNotFound = errors.New("vehicle not found")
+	// ErrVehiclePlateTaken is returned when another vehicle is already registered with the plate
+	ErrVehiclePlateTaken = errors.New("plate is already registered to a vehicle")
+)
+
+// VehicleRepository handles the vehicles registered by courier companies
+type VehicleRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewVehicleRepository creates a new vehicle repository
+func NewVehicleRepository(db *pgxpool.Pool) *VehicleRepository {
+	return &VehicleRepository{db: db}
+}
+
+const vehicleColumns = `
+	id, courier_id, type, COALESCE(plate, '') as plate, COALESCE(description, '') as description, capacity_kg, capacity_litres,
+	insurance_expiry, roadworthy_expiry, is_active, created_at, updated_at
+`
+
+// vehicleExpiryColumns maps a vehicle document to its expiry and reminder columns
+var vehicleExpiryColumns = map[string][2]string{
+	models.VehicleDocumentInsurance:      {"insurance_expiry", "insurance_reminded_for"},
+	models.VehicleDocumentRoadworthiness: {"roadworthy_expiry", "roadworthy_reminded_for"},
+}
+
+// Create registers a vehicle. ErrVehiclePlateTaken is returned if the plate is already in use.
+func (r *VehicleRepository) Create(ctx context.Context, vehicle *models.Vehicle) error {
+	query := `
+		INSERT INTO vehicles (
+			id, courier_id, type, plate, description, capacity_kg, capacity_litres,
+			insurance_expiry, roadworthy_expiry, is_active, created_at, updated_at
+		) VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $11)
+		ON CONFLICT (plate) DO NOTHING
+	`
+
+	vehicle.ID = uuid.New()
+	vehicle.CreatedAt = time.Now()
+	vehicle.UpdatedAt = vehicle.CreatedAt
+
+	result, err := r.db.Exec(ctx, query,
+		vehicle.ID, vehicle.CourierID, vehicle.Type, vehicle.Plate, vehicle.Description, vehicle.CapacityKg,
+		vehicle.CapacityLitres, vehicle.InsuranceExpiry, vehicle.RoadworthyExpiry, vehicle.IsActive, vehicle.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrVehiclePlateTaken
+	}
+	return nil
+}
+
+// GetByID retrieves a vehicle by ID
+func (r *VehicleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Vehicle, error) {
+	query := `SELECT ` + vehicleColumns + ` FROM vehicles WHERE id = $1`
+	return scanVehicle(r.db.QueryRow(ctx, query, id))
+}
+
+// ListByCourier retrieves a courier's vehicles by type and plate, optionally only those of the
+// type or only the active ones
+func (r *VehicleRepository) ListByCourier(ctx context.Context, courierID uuid.UUID, vehicleType string, activeOnly bool) ([]models.Vehicle, error) {
+	query := `SELECT ` + vehicleColumns + ` FROM vehicles
+		WHERE courier_id = $1 AND ($2 = '' OR type = $2) AND (NOT $3 OR is_active)
+		ORDER BY type, plate`
+
+	return r.list(ctx, query, courierID, vehicleType, activeOnly)
+}
+
+// ListByActiveCouriers retrieves every vehicle, active or not, of the active couriers
+func (r *VehicleRepository) ListByActiveCouriers(ctx context.Context) ([]models.Vehicle, error) {
+	query := `SELECT ` + vehicleColumns + ` FROM vehicles
+		WHERE courier_id IN (SELECT id FROM couriers WHERE is_active = true)
+		ORDER BY courier_id`
+
+	return r.list(ctx, query)
+}
+
+// Update saves a vehicle. ErrVehiclePlateTaken is returned if the new plate belongs to another vehicle.
+func (r *VehicleRepository) Update(ctx context.Context, vehicle *models.Vehicle) error {
+	query := `
+		UPDATE vehicles SET
+			type = $2, plate = NULLIF($3, ''), description = NULLIF($4, ''), capacity_kg = $5, capacity_litres = $6,
+			insurance_expiry = $7, roadworthy_expiry = $8, is_active = $9, updated_at = $10
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM vehicles other WHERE other.plate = NULLIF($3, '') AND other.id <> $1)
+	`
+
+	vehicle.UpdatedAt = time.Now()
+	result, err := r.db.Exec(ctx, query,
+		vehicle.ID, vehicle.Type, vehicle.Plate, vehicle.Description, vehicle.CapacityKg, vehicle.CapacityLitres,
+		vehicle.InsuranceExpiry, vehicle.RoadworthyExpiry, vehicle.IsActive, vehicle.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrVehiclePlateTaken
+	}
+	return nil
+}
+
+// Delete removes a vehicle from the registry
+func (r *VehicleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM vehicles WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrVehicleNotFound
+	}
+	return nil
+}
+
+// ClaimExpiryReminders marks and returns active vehicles whose document expires before remindBefore
+// and whose courier has not been reminded of that expiry date yet. Renewing the document re-arms it.
+func (r *VehicleRepository) ClaimExpiryReminders(ctx context.Context, document string, remindBefore time.Time, limit int) ([]models.Vehicle, error) {
+	columns, ok := vehicleExpiryColumns[document]
+	if !ok {
+		return nil, fmt.Errorf("unknown vehicle document %q", document)
+	}
+	expiry, reminded := columns[0], columns[1]
+
+	query := fmt.Sprintf(`
+		UPDATE vehicles SET %[2]s = %[1]s
+		WHERE id IN (
+			SELECT id FROM vehicles
+			WHERE is_active = true AND %[1]s IS NOT NULL AND %[1]s <= $1::date
+				AND %[2]s IS DISTINCT FROM %[1]s
+			ORDER BY %[1]s
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+vehicleColumns, expiry, reminded)
+
+	return r.list(ctx, query, remindBefore, limit)
+}
+
+func (r *VehicleRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.Vehicle, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vehicles := []models.Vehicle{}
+	for rows.Next() {
+		vehicle, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, *vehicle)
+	}
+	return vehicles, rows.Err()
+}
+
+func scanVehicle(row pgx.Row) (*models.Vehicle, error) {
+	var vehicle models.Vehicle
+	err := row.Scan(
+		&vehicle.ID,
+		&vehicle.CourierID,
+		&vehicle.Type,
+		&vehicle.Plate,
+		&vehicle.Description,
+		&vehicle.CapacityKg,
+		&vehicle.CapacityLitres,
+		&vehicle.InsuranceExpiry,
+		&vehicle.RoadworthyExpiry,
+		&vehicle.IsActive,
+		&vehicle.CreatedAt,
+		&vehicle.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrVehicleNotFound
+		}
+		return nil, err
+	}
+	return &vehicle, nil
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+	"nyengo-deliveries/pkg/validator"
+)
+
+// Store webhook events for order amendments
+const (
+	WebhookOrderAmended            = "order.amended"
+	WebhookOrderAmendmentRequested = "order.amendment_requested"
+	WebhookOrderAmendmentRejected  = "order.amendment_rejected"
+)
+
+var (
+	ErrInvalidAmendment  = errors.New("invalid amendment")
+	ErrOrderNotAmendable = errors.New("order can only be amended before pickup or while waiting for a re-attempt")
+	ErrAmendmentNotFound = errors.New("amendment not found")
+	ErrAmendmentPending  = errors.New("order already has an amendment waiting for store approval")
+	ErrAmendmentDecided  = errors.New("amendment has already been approved or rejected")
+	ErrAmendmentConflict = errors.New("order was changed by another request, please retry")
+)
+
+// amendableStatuses are the statuses in which an order's drop-off and package can still change.
+// Orders waiting for a re-attempt can be corrected too, e.g. after a wrong_address failure.
+var amendableStatuses = []models.OrderStatus{
+	models.OrderStatusScheduled, models.OrderStatusPending, models.OrderStatusAccepted,
+	models.OrderStatusReattemptScheduled,
+}
+
+// AmendmentService changes the drop-off and package details of orders that have not been picked up
+// or are waiting for a re-attempt, repricing them and keeping an amendment log
+type AmendmentService struct {
+	orderRepo    *repository.OrderRepository
+	pricing      *PricingService
+	tracking     *TrackingService
+	notification *NotificationService
+	webhooks     *StoreWebhookService
+	cfg          *config.Config
+}
+
+// NewAmendmentService creates a new order amendment service
+func NewAmendmentService(
+	orderRepo *repository.OrderRepository,
+	pricing *PricingService,
+	tracking *TrackingService,
+	notification *NotificationService,
+	webhooks *StoreWebhookService,
+	cfg *config.Config,
+) *AmendmentService {
+	return &AmendmentService{
+		orderRepo:    orderRepo,
+		pricing:      pricing,
+		tracking:     tracking,
+		notification: notification,
+		webhooks:     webhooks,
+		cfg:          cfg,
+	}
+}
+
+// AmendByCourier amends an order on behalf of its customer. If the fare rises by more than
+// AMENDMENT_APPROVAL_THRESHOLD the amendment waits for store approval instead of being applied.
+func (s *AmendmentService) AmendByCourier(ctx context.Context, courierID, orderID uuid.UUID, req *models.AmendOrderRequest) (*models.OrderAmendment, *models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, ErrOrderNotFound
+	}
+	if order.CourierID != courierID {
+		return nil, nil, ErrNotOrderCourier
+	}
+	return s.amend(ctx, order, models.StatusActorCourier, req)
+}
+
+// AmendByStore amends an order for the store. The store is the approver, so it is applied directly.
+func (s *AmendmentService) AmendByStore(ctx context.Context, orderID uuid.UUID, req *models.AmendOrderRequest) (*models.OrderAmendment, *models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, ErrOrderNotFound
+	}
+	return s.amend(ctx, order, models.StatusActorStore, req)
+}
+
+// Approve applies a pending amendment. It is repriced against the order as it is now.
+func (s *AmendmentService) Approve(ctx context.Context, orderID, amendmentID uuid.UUID, note string) (*models.OrderAmendment, *models.Order, error) {
+	amendment, order, err := s.loadPending(ctx, orderID, amendmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	amended, err := s.prepare(order, &amendment.Request, amendment)
+	if err != nil {
+		return nil, nil, err
+	}
+	amendment.DecidedBy = models.StatusActorStore
+	amendment.DecisionNote = note
+
+	if err := s.apply(ctx, order, amended, amendment); err != nil {
+		return nil, nil, err
+	}
+	return amendment, amended, nil
+}
+
+// Reject discards a pending amendment, leaving the order unchanged
+func (s *AmendmentService) Reject(ctx context.Context, orderID, amendmentID uuid.UUID, note string) (*models.OrderAmendment, error) {
+	amendment, order, err := s.loadPending(ctx, orderID, amendmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	amendment.DecidedBy = models.StatusActorStore
+	amendment.DecisionNote = note
+	if err := s.orderRepo.RejectAmendment(ctx, amendment); err != nil {
+		if errors.Is(err, repository.ErrAmendmentDecided) {
+			return nil, ErrAmendmentDecided
+		}
+		return nil, err
+	}
+
+	s.notifyCourier(ctx, order, "Amendment Rejected",
+		fmt.Sprintf("The store rejected the requested change to order %s", order.OrderNumber), amendment)
+	s.webhooks.Send(order, WebhookOrderAmendmentRejected, amendment)
+	return amendment, nil
+}
+
+// List returns an order's amendment log, oldest first
+func (s *AmendmentService) List(ctx context.Context, orderID uuid.UUID) ([]models.OrderAmendment, error) {
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, ErrOrderNotFound
+	}
+	return s.orderRepo.ListAmendments(ctx, orderID)
+}
+
+// ListForCourier returns the amendment log of one of the courier's orders
+func (s *AmendmentService) ListForCourier(ctx context.Context, courierID, orderID uuid.UUID) ([]models.OrderAmendment, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return s.orderRepo.ListAmendments(ctx, orderID)
+}
+
+func (s *AmendmentService) amend(ctx context.Context, order *models.Order, actor string, req *models.AmendOrderRequest) (*models.OrderAmendment, *models.Order, error) {
+	amendment := &models.OrderAmendment{OrderID: order.ID, RequestedBy: actor, Reason: req.Reason, Request: *req}
+	amended, err := s.prepare(order, req, amendment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Large fare increases requested on the customer's behalf need the store to agree first
+	if actor != models.StatusActorStore && amendment.FareDelta > order.TotalFare*s.cfg.AmendmentApprovalThreshold {
+		if err := s.orderRepo.CreatePendingAmendment(ctx, amendment); err != nil {
+			if errors.Is(err, repository.ErrAmendmentPending) {
+				return nil, nil, ErrAmendmentPending
+			}
+			return nil, nil, err
+		}
+		log.Printf("📝 Amendment %s on order %s needs store approval (fare %s → %s)",
+			amendment.ID, order.OrderNumber, s.cfg.FormatCurrency(amendment.PreviousFare), s.cfg.FormatCurrency(amendment.NewFare))
+		s.webhooks.Send(order, WebhookOrderAmendmentRequested, amendment)
+		return amendment, order, nil
+	}
+
+	if err := s.apply(ctx, order, amended, amendment); err != nil {
+		return nil, nil, err
+	}
+	return amendment, amended, nil
+}
+
+// prepare validates a request against the order and returns the amended copy, filling the
+// amendment's changes and repricing
+func (s *AmendmentService) prepare(order *models.Order, req *models.AmendOrderRequest, amendment *models.OrderAmendment) (*models.Order, error) {
+	if !isAmendable(order.Status) {
+		return nil, ErrOrderNotAmendable
+	}
+
+	locationChange := req.DeliveryAddress != nil || req.DeliveryLatitude != nil || req.DeliveryLongitude != nil
+	if locationChange && order.OrderType == models.OrderTypeMultiStop {
+		return nil, fmt.Errorf("%w: the drop-offs of a multi-stop order cannot be moved", ErrInvalidAmendment)
+	}
+	if (req.DeliveryLatitude == nil) != (req.DeliveryLongitude == nil) {
+		return nil, fmt.Errorf("%w: deliveryLatitude and deliveryLongitude must be changed together", ErrInvalidAmendment)
+	}
+
+	amended := *order
+	changes := make(map[string]models.FieldChange)
+	setString := func(field string, target *string, value *string) {
+		if value != nil && strings.TrimSpace(*value) != *target {
+			changes[field] = models.FieldChange{From: *target, To: strings.TrimSpace(*value)}
+			*target = strings.TrimSpace(*value)
+		}
+	}
+
+	setString("deliveryAddress", &amended.DeliveryAddress, req.DeliveryAddress)
+	setString("deliveryNotes", &amended.DeliveryNotes, req.DeliveryNotes)
+	setString("packageDescription", &amended.PackageDescription, req.PackageDescription)
+	setString("packageSize", &amended.PackageSize, req.PackageSize)
+	if req.DeliveryLatitude != nil && (*req.DeliveryLatitude != order.DeliveryLatitude || *req.DeliveryLongitude != order.DeliveryLongitude) {
+		if !validator.ValidateCoordinates(*req.DeliveryLatitude, *req.DeliveryLongitude) || (*req.DeliveryLatitude == 0 && *req.DeliveryLongitude == 0) {
+			return nil, fmt.Errorf("%w: delivery coordinates are out of range", ErrInvalidAmendment)
+		}
+		changes["deliveryLatitude"] = models.FieldChange{From: order.DeliveryLatitude, To: *req.DeliveryLatitude}
+		changes["deliveryLongitude"] = models.FieldChange{From: order.DeliveryLongitude, To: *req.DeliveryLongitude}
+		amended.DeliveryLatitude, amended.DeliveryLongitude = *req.DeliveryLatitude, *req.DeliveryLongitude
+	}
+	if req.PackageWeight != nil && *req.PackageWeight != order.PackageWeight {
+		if *req.PackageWeight < 0 {
+			return nil, fmt.Errorf("%w: packageWeight cannot be negative", ErrInvalidAmendment)
+		}
+		changes["packageWeight"] = models.FieldChange{From: order.PackageWeight, To: *req.PackageWeight}
+		amended.PackageWeight = *req.PackageWeight
+	}
+	if req.IsFragile != nil && *req.IsFragile != order.IsFragile {
+		changes["isFragile"] = models.FieldChange{From: order.IsFragile, To: *req.IsFragile}
+		amended.IsFragile = *req.IsFragile
+	}
+
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("%w: nothing to change", ErrInvalidAmendment)
+	}
+	if _, ok := changes["packageSize"]; ok && !validPackageSizes[amended.PackageSize] {
+		return nil, fmt.Errorf("%w: packageSize must be small, medium or large", ErrInvalidAmendment)
+	}
+	if amended.DeliveryAddress == "" || amended.PackageDescription == "" {
+		return nil, fmt.Errorf("%w: deliveryAddress and packageDescription cannot be empty", ErrInvalidAmendment)
+	}
+
+	// Reprice over the amended route and package
+	estimateReq := &models.PriceEstimateRequest{
+		PickupLatitude: amended.PickupLatitude, PickupLongitude: amended.PickupLongitude,
+		DeliveryLatitude: amended.DeliveryLatitude, DeliveryLongitude: amended.DeliveryLongitude,
+		PackageWeight: amended.PackageWeight, IsFragile: amended.IsFragile,
+	}
+	for _, stop := range amended.Stops {
+		estimateReq.Stops = append(estimateReq.Stops, models.RoutePoint{Latitude: stop.Latitude, Longitude: stop.Longitude})
+	}
+	estimate, err := s.pricing.CalculateEstimate(estimateReq)
+	if err != nil {
+		return nil, err
+	}
+	amended.Distance = estimate.Distance
+	amended.BaseFare, amended.DistanceFare, amended.SurgeFare = estimate.BaseFare, estimate.DistanceFare, estimate.SurgeFare
+	amended.TotalFare = estimate.TotalFare
+	amended.PlatformFee, amended.CourierEarnings = s.pricing.CalculateCourierEarnings(estimate.TotalFare)
+
+	amendment.Changes = changes
+	amendment.PreviousDistance, amendment.NewDistance = order.Distance, amended.Distance
+	amendment.PreviousFare, amendment.NewFare = order.TotalFare, amended.TotalFare
+	amendment.FareDelta = roundMoney(amended.TotalFare - order.TotalFare)
+	amendment.PreviousEarnings, amendment.NewEarnings = order.CourierEarnings, amended.CourierEarnings
+	amendment.EarningsDelta = roundMoney(amended.CourierEarnings - order.CourierEarnings)
+	return &amended, nil
+}
+
+// apply saves the amended order and amendment, then tells the courier, the live tracking view and the store
+func (s *AmendmentService) apply(ctx context.Context, order, amended *models.Order, amendment *models.OrderAmendment) error {
+	if err := s.orderRepo.ApplyAmendment(ctx, amended, order.UpdatedAt, amendableStatuses, amendment); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrOrderModified):
+			return ErrAmendmentConflict
+		case errors.Is(err, repository.ErrAmendmentDecided):
+			return ErrAmendmentDecided
+		}
+		return err
+	}
+
+	log.Printf("📝 Order %s amended by %s (fare delta %s)", order.OrderNumber, amendment.RequestedBy, s.cfg.FormatCurrency(amendment.FareDelta))
+
+	if _, moved := amendment.Changes["deliveryLatitude"]; moved {
+		s.tracking.UpdateDestination(ctx, amended)
+	}
+	if amendment.RequestedBy != models.StatusActorCourier || amendment.DecidedBy != "" {
+		s.notifyCourier(ctx, amended, "Order Amended",
+			fmt.Sprintf("Order %s was changed. New fare: %s", amended.OrderNumber, s.cfg.FormatCurrency(amended.TotalFare)), amendment)
+	}
+	s.webhooks.Send(amended, WebhookOrderAmended, amendment)
+	return nil
+}
+
+func (s *AmendmentService) loadPending(ctx context.Context, orderID, amendmentID uuid.UUID) (*models.OrderAmendment, *models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, ErrOrderNotFound
+	}
+	amendment, err := s.orderRepo.GetAmendment(ctx, orderID, amendmentID)
+	if err != nil {
+		return nil, nil, ErrAmendmentNotFound
+	}
+	if amendment.Status != models.AmendmentStatusPendingApproval {
+		return nil, nil, ErrAmendmentDecided
+	}
+	return amendment, order, nil
+}
+
+func (s *AmendmentService) notifyCourier(ctx context.Context, order *models.Order, title, message string, amendment *models.OrderAmendment) {
+	err := s.notification.Send(ctx, "courier:"+order.CourierID.String(), &Notification{
+		Type: "order_amended", Title: title, Message: message,
+		Data: map[string]interface{}{
+			"orderId":     order.ID.String(),
+			"amendmentId": amendment.ID.String(),
+			"status":      amendment.Status,
+			"fareDelta":   amendment.FareDelta,
+		},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to notify courier of amendment to order %s: %v", order.OrderNumber, err)
+	}
+}
+
+func isAmendable(status models.OrderStatus) bool {
+	for _, s := range amendableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// ErrNotOrderCourier is returned when a courier acts on an order assigned to someone else
+var ErrNotOrderCourier = errors.New("order is not assigned to this courier")
+
+// CancellationService handles order cancellations, fees and refunds
+type CancellationService struct {
+	orderRepo    *repository.OrderRepository
+	stateMachine *OrderStateMachine
+	pricing      *PricingService
+	tracking     *TrackingService
+	notification *NotificationService
+	cfg          *config.Config
+}
+
+// NewCancellationService creates a new cancellation service
+func NewCancellationService(
+	orderRepo *repository.OrderRepository,
+	stateMachine *OrderStateMachine,
+	pricing *PricingService,
+	tracking *TrackingService,
+	notification *NotificationService,
+	cfg *config.Config,
+) *CancellationService {
+	return &CancellationService{
+		orderRepo:    orderRepo,
+		stateMachine: stateMachine,
+		pricing:      pricing,
+		tracking:     tracking,
+		notification: notification,
+		cfg:          cfg,
+	}
+}
+
+// CancelByCourier cancels an order on behalf of its assigned courier.
+// Couriers are never charged a cancellation fee; any earnings already credited are reversed.
+func (s *CancellationService) CancelByCourier(ctx context.Context, courierID, orderID uuid.UUID, reason string) (*models.CancellationResult, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return s.cancel(ctx, order, models.StatusActorCourier, models.StatusActorCourier, reason)
+}
+
+// CancelFromWebhook cancels an order reported cancelled by the courier platform's delivery webhook.
+// It is settled like a cancellation by the courier.
+func (s *CancellationService) CancelFromWebhook(ctx context.Context, order *models.Order, reason string) (*models.CancellationResult, error) {
+	if strings.TrimSpace(reason) == "" {
+		reason = "Cancelled by the courier platform"
+	}
+	return s.cancel(ctx, order, models.StatusActorCourier, models.StatusActorWebhook, reason)
+}
+
+// CancelByStore cancels an order on behalf of the store that placed it.
+// A fee is charged according to how far the order has progressed.
+func (s *CancellationService) CancelByStore(ctx context.Context, orderID uuid.UUID, reason string) (*models.CancellationResult, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	return s.cancel(ctx, order, models.StatusActorStore, models.StatusActorStore, reason)
+}
+
+// CalculateCancellationFee returns the fee owed when the given party cancels the order now
+func (s *CancellationService) CalculateCancellationFee(order *models.Order, cancelledBy string) float64 {
+	if cancelledBy != models.StatusActorStore {
+		return 0
+	}
+
+	var rate float64
+	switch order.Status {
+	case models.OrderStatusPending:
+		rate = s.cfg.CancellationFeePending
+	case models.OrderStatusAccepted:
+		rate = s.cfg.CancellationFeeAccepted
+	case models.OrderStatusPickedUp:
+		rate = s.cfg.CancellationFeePickedUp
+	}
+
+	return math.Round(order.TotalFare*rate*100) / 100
+}
+
+// settlement works out the fee, refund and courier's share of the fee when the party cancels the
+// order now
+func (s *CancellationService) settlement(order *models.Order, cancelledBy, reason string) *models.OrderCancellation {
+	cancellation := &models.OrderCancellation{
+		CancelledBy: cancelledBy,
+		Reason:      reason,
+		Fee:         s.CalculateCancellationFee(order, cancelledBy),
+	}
+	// Refund whatever was prepaid beyond the cancellation fee
+	if order.PaymentStatus == models.PaymentStatusPaid {
+		cancellation.RefundPayment = true
+		cancellation.Refund = math.Round((order.TotalFare-cancellation.Fee)*100) / 100
+	}
+	if cancellation.Fee > 0 && order.CourierID != uuid.Nil {
+		_, cancellation.CourierFeeShare = s.pricing.CalculateCourierEarnings(cancellation.Fee)
+	}
+	return cancellation
+}
+
+// cancel moves the order to cancelled and settles fees, refunds and tracking. The status change, the
+// refund and the courier's wallet settlement are written in one transaction. actor is who the status
+// history records the change by.
+func (s *CancellationService) cancel(ctx context.Context, order *models.Order, cancelledBy, actor, reason string) (*models.CancellationResult, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("a cancellation reason is required")
+	}
+
+	previousStatus := order.Status
+	cancellation := s.settlement(order, cancelledBy, reason)
+
+	persist := func(ctx context.Context, change models.StatusChange) error {
+		if err := s.orderRepo.CancelOrder(ctx, order, change, cancellation); err != nil {
+			return err
+		}
+		if cancellation.RefundPayment {
+			order.PaymentStatus = models.PaymentStatusRefunded
+		}
+		order.CancelledBy = cancelledBy
+		order.CancellationReason = reason
+		order.CancellationFee = cancellation.Fee
+		order.RefundAmount = cancellation.Refund
+		order.CancelledAt = &change.Timestamp
+		return nil
+	}
+	if _, err := s.stateMachine.TransitionOrderWith(ctx, order, models.OrderStatusCancelled, actor, reason, persist); err != nil {
+		return nil, err
+	}
+
+	// Stop any live tracking; the order may never have been tracked
+	if previousStatus == models.OrderStatusAccepted || previousStatus == models.OrderStatusPickedUp {
+		if err := s.tracking.StopTracking(ctx, order.ID, "cancelled"); err != nil {
+			log.Printf("⚠️ Failed to stop tracking for cancelled order %s: %v", order.OrderNumber, err)
+		}
+	}
+
+	// Broadcast orders nobody accepted have no courier to tell
+	if s.notification != nil && order.CourierID != uuid.Nil {
+		_ = s.notification.SendOrderUpdate(ctx, order.CourierID.String(), order.ID.String(), string(models.OrderStatusCancelled))
+	}
+
+	return &models.CancellationResult{
+		OrderID:         order.ID,
+		OrderNumber:     order.OrderNumber,
+		Status:          order.Status,
+		CancelledBy:     cancelledBy,
+		Reason:          reason,
+		PreviousStatus:  previousStatus,
+		CancellationFee: cancellation.Fee,
+		RefundAmount:    cancellation.Refund,
+		FormattedFee:    s.cfg.FormatCurrency(cancellation.Fee),
+		FormattedRefund: s.cfg.FormatCurrency(cancellation.Refund),
+	}, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+)
+
+func TestCancellationSettlement(t *testing.T) {
+	cfg := &config.Config{
+		PlatformFeePerc:         0.10,
+		CancellationFeePending:  0,
+		CancellationFeeAccepted: 0.25,
+		CancellationFeePickedUp: 0.50,
+	}
+	s := NewCancellationService(nil, nil, NewPricingService(cfg), nil, nil, cfg)
+	courierID := uuid.New()
+
+	tests := []struct {
+		name          string
+		status        models.OrderStatus
+		payment       models.PaymentStatus
+		fare          float64
+		courierID     uuid.UUID
+		cancelledBy   string
+		fee           float64
+		refund        float64
+		refundPayment bool
+		courierShare  float64
+	}{
+		{"store, scheduled", models.OrderStatusScheduled, models.PaymentStatusPending, 100, uuid.Nil, models.StatusActorStore, 0, 0, false, 0},
+		{"store, pending", models.OrderStatusPending, models.PaymentStatusPending, 100, courierID, models.StatusActorStore, 0, 0, false, 0},
+		{"store, accepted", models.OrderStatusAccepted, models.PaymentStatusPending, 100, courierID, models.StatusActorStore, 25, 0, false, 22.5},
+		{"store, picked up", models.OrderStatusPickedUp, models.PaymentStatusPending, 100, courierID, models.StatusActorStore, 50, 0, false, 45},
+		{"store, accepted and prepaid", models.OrderStatusAccepted, models.PaymentStatusPaid, 100, courierID, models.StatusActorStore, 25, 75, true, 22.5},
+		{"store, pending and prepaid", models.OrderStatusPending, models.PaymentStatusPaid, 100, courierID, models.StatusActorStore, 0, 100, true, 0},
+		{"store, fee rounded to the cent", models.OrderStatusAccepted, models.PaymentStatusPaid, 33.33, courierID, models.StatusActorStore, 8.33, 25, true, 7.5},
+		{"courier, accepted", models.OrderStatusAccepted, models.PaymentStatusPending, 100, courierID, models.StatusActorCourier, 0, 0, false, 0},
+		{"courier, picked up and prepaid", models.OrderStatusPickedUp, models.PaymentStatusPaid, 100, courierID, models.StatusActorCourier, 0, 100, true, 0},
+		{"customer, picked up", models.OrderStatusPickedUp, models.PaymentStatusPending, 100, courierID, models.StatusActorCustomer, 0, 0, false, 0},
+		{"system, accepted", models.OrderStatusAccepted, models.PaymentStatusPending, 100, courierID, models.StatusActorSystem, 0, 0, false, 0},
+		{"store, in transit is not charged", models.OrderStatusInTransit, models.PaymentStatusPending, 100, courierID, models.StatusActorStore, 0, 0, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &models.Order{Status: tt.status, PaymentStatus: tt.payment, TotalFare: tt.fare, CourierID: tt.courierID}
+
+			if fee := s.CalculateCancellationFee(order, tt.cancelledBy); fee != tt.fee {
+				t.Errorf("CalculateCancellationFee() = %v, want %v", fee, tt.fee)
+			}
+			got := s.settlement(order, tt.cancelledBy, "Out of stock")
+			if got.Fee != tt.fee || got.Refund != tt.refund || got.RefundPayment != tt.refundPayment || got.CourierFeeShare != tt.courierShare {
+				t.Errorf("settlement() = fee %v, refund %v (%v), courier share %v; want fee %v, refund %v (%v), courier share %v",
+					got.Fee, got.Refund, got.RefundPayment, got.CourierFeeShare, tt.fee, tt.refund, tt.refundPayment, tt.courierShare)
+			}
+			if got.CancelledBy != tt.cancelledBy || got.Reason != "Out of stock" {
+				t.Errorf("settlement() by %q for %q, want %q for %q", got.CancelledBy, got.Reason, tt.cancelledBy, "Out of stock")
+			}
+		})
+	}
+}
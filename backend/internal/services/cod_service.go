@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// Store webhook event for cash-on-delivery collections
+const WebhookCODCollected = "order.cod_collected"
+
+// codSettlementDefaultDays is the period a settlement report covers when no dates are given
+const codSettlementDefaultDays = 30
+
+var (
+	// ErrInvalidCOD is returned when a collection or remittance fails validation
+	ErrInvalidCOD = errors.New("invalid cash-on-delivery request")
+	// ErrNoCODOnOrder is returned for collections on orders without a COD amount
+	ErrNoCODOnOrder = errors.New("order has no cash-on-delivery amount")
+	// ErrCODNotCollectable is returned when the COD is collected before the handoff or more than once
+	ErrCODNotCollectable = errors.New("cash can only be collected once, while the order is in transit")
+	// ErrCODOnMultiStop is returned for order-level collections on multi-stop orders
+	ErrCODOnMultiStop = errors.New("cash on multi-stop orders is collected per stop")
+	// ErrCODCollectionRequired is returned when a COD order is delivered before the cash was collected
+	ErrCODCollectionRequired = errors.New("confirm the cash collected with POST /api/v1/orders/:id/cod before delivering")
+	// ErrRemittanceNotFound is returned when a remittance does not exist or belongs to another store
+	ErrRemittanceNotFound = errors.New("remittance not found")
+	// ErrRemittancePending is returned when the courier already has an unconfirmed remittance for the store
+	ErrRemittancePending = errors.New("a remittance for this store is already waiting for confirmation")
+	// ErrNothingToRemit is returned when the courier holds no collected cash for the store
+	ErrNothingToRemit = errors.New("no collected cash to remit for this store")
+	// ErrRemittanceDecided is returned when a remittance has already been confirmed or rejected
+	ErrRemittanceDecided = errors.New("remittance has already been decided")
+)
+
+// CODService handles cash on delivery: the goods payment the driver collects from the customer on top
+// of the delivery fare. Collected cash is the courier's liability until the store confirms a remittance.
+type CODService struct {
+	codRepo      *repository.CODRepository
+	orderRepo    *repository.OrderRepository
+	paymentRepo  *repository.PaymentRepository
+	notification *NotificationService
+	webhooks     *StoreWebhookService
+	cfg          *config.Config
+}
+
+// NewCODService creates a new cash-on-delivery service
+func NewCODService(
+	codRepo *repository.CODRepository,
+	orderRepo *repository.OrderRepository,
+	paymentRepo *repository.PaymentRepository,
+	notification *NotificationService,
+	webhooks *StoreWebhookService,
+	cfg *config.Config,
+) *CODService {
+	return &CODService{
+		codRepo:      codRepo,
+		orderRepo:    orderRepo,
+		paymentRepo:  paymentRepo,
+		notification: notification,
+		webhooks:     webhooks,
+		cfg:          cfg,
+	}
+}
+
+// Collect records the cash the driver collected at handoff. A note is required when the amount
+// differs from the order's codAmount, so the store can follow up on the difference.
+func (s *CODService) Collect(ctx context.Context, courierID, orderID uuid.UUID, req *models.CollectCODRequest) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	if order.CODAmount <= 0 {
+		return nil, ErrNoCODOnOrder
+	}
+	if order.OrderType == models.OrderTypeMultiStop {
+		return nil, ErrCODOnMultiStop
+	}
+	if order.Status != models.OrderStatusInTransit || order.CODStatus != models.CODStatusPending {
+		return nil, ErrCODNotCollectable
+	}
+
+	amount := roundMoney(req.Amount)
+	note := strings.TrimSpace(req.Note)
+	if amount < 0 {
+		return nil, fmt.Errorf("%w: amount cannot be negative", ErrInvalidCOD)
+	}
+	if amount != order.CODAmount && note == "" {
+		return nil, fmt.Errorf("%w: a note is required when the amount differs from %s",
+			ErrInvalidCOD, s.cfg.FormatCurrency(order.CODAmount))
+	}
+
+	collectedAt, err := s.codRepo.Collect(ctx, order.ID, courierID, amount, note, s.cfg.Currency)
+	if err != nil {
+		if errors.Is(err, repository.ErrCODStatusChanged) {
+			return nil, ErrCODNotCollectable
+		}
+		return nil, fmt.Errorf("failed to record collection: %w", err)
+	}
+	order.CODStatus = models.CODStatusCollected
+	order.CODCollected = amount
+	order.CODNote = note
+	order.CODCollectedAt = collectedAt
+
+	log.Printf("💵 Collected %s of %s cash on delivery for order %s",
+		s.cfg.FormatCurrency(amount), s.cfg.FormatCurrency(order.CODAmount), order.OrderNumber)
+
+	s.webhooks.Send(order, WebhookCODCollected, codSummary(order))
+	return order, nil
+}
+
+// GuardDelivery is a transition guard that blocks delivering a single drop-off COD order until the
+// driver has confirmed the cash collected
+func (s *CODService) GuardDelivery(ctx context.Context, order *models.Order, to models.OrderStatus, actor string) error {
+	if to != models.OrderStatusDelivered || order.OrderType == models.OrderTypeMultiStop {
+		return nil
+	}
+	if order.CODAmount > 0 && order.CODStatus == models.CODStatusPending {
+		return ErrCODCollectionRequired
+	}
+	return nil
+}
+
+// SettleOnTransition is a transition hook that keeps an order's COD in step with its status: the stop
+// collections of a delivered multi-stop order are rolled up, and cash is handed back to the customer
+// when a parcel is not delivered after all
+func (s *CODService) SettleOnTransition(ctx context.Context, order *models.Order, change models.StatusChange) {
+	if order.CODAmount <= 0 {
+		return
+	}
+
+	var err error
+	switch change.Status {
+	case models.OrderStatusDelivered:
+		if order.OrderType != models.OrderTypeMultiStop || order.CODStatus != models.CODStatusPending {
+			return
+		}
+		collected := 0.0
+		for _, stop := range order.Stops {
+			collected += stop.CODCollected
+		}
+		collected = roundMoney(collected)
+		if _, err = s.codRepo.Collect(ctx, order.ID, order.CourierID, collected, "", s.cfg.Currency); err == nil {
+			order.CODStatus = models.CODStatusCollected
+			order.CODCollected = collected
+			s.webhooks.Send(order, WebhookCODCollected, codSummary(order))
+		}
+	case models.OrderStatusReattemptScheduled:
+		if order.CODStatus != models.CODStatusCollected {
+			return
+		}
+		err = s.codRepo.Reverse(ctx, order.ID, models.CODStatusPending)
+	case models.OrderStatusReturning, models.OrderStatusFailed, models.OrderStatusCancelled:
+		if order.CODStatus != models.CODStatusPending && order.CODStatus != models.CODStatusCollected {
+			return
+		}
+		err = s.codRepo.Reverse(ctx, order.ID, models.CODStatusVoid)
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to update cash on delivery for order %s after %s: %v", order.OrderNumber, change.Status, err)
+	}
+}
+
+// Summary returns a courier's cash liability with the cash still to be remitted per store
+func (s *CODService) Summary(ctx context.Context, courierID uuid.UUID) (*models.CODSummary, error) {
+	wallet, err := s.paymentRepo.GetCourierWallet(ctx, courierID)
+	if err != nil {
+		return nil, err
+	}
+	outstanding, err := s.codRepo.OutstandingByStore(ctx, courierID)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.codRepo.ListRemittances(ctx, &courierID, nil, string(models.RemittanceStatusPending), 100, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CODSummary{
+		CourierID:          courierID,
+		CashLiability:      wallet.CashLiability,
+		Outstanding:        outstanding,
+		PendingRemittances: pending,
+		Currency:           s.cfg.Currency,
+		FormattedLiability: s.cfg.FormatCurrency(wallet.CashLiability),
+	}, nil
+}
+
+// CreateRemittance hands all cash the courier collected on a store's delivered orders over to the store.
+// The cash stays on the courier's liability until the store confirms receiving it.
+func (s *CODService) CreateRemittance(ctx context.Context, courierID uuid.UUID, req *models.CreateRemittanceRequest) (*models.CODRemittance, error) {
+	storeID, err := uuid.Parse(req.StoreID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: a valid storeId is required", ErrInvalidCOD)
+	}
+	switch req.Method {
+	case models.RemittanceMethodCash:
+	case models.RemittanceMethodMobileMoney, models.RemittanceMethodBankDeposit:
+		if strings.TrimSpace(req.Reference) == "" {
+			return nil, fmt.Errorf("%w: a reference is required for %s remittances", ErrInvalidCOD, req.Method)
+		}
+	default:
+		return nil, fmt.Errorf("%w: method must be cash, mobile_money or bank_deposit", ErrInvalidCOD)
+	}
+
+	remittance := &models.CODRemittance{
+		CourierID: courierID,
+		StoreID:   storeID,
+		Method:    req.Method,
+		Reference: strings.TrimSpace(req.Reference),
+		Note:      strings.TrimSpace(req.Note),
+	}
+	if err := s.codRepo.CreateRemittance(ctx, remittance); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRemittancePending):
+			return nil, ErrRemittancePending
+		case errors.Is(err, repository.ErrNothingToRemit):
+			return nil, ErrNothingToRemit
+		}
+		return nil, fmt.Errorf("failed to create remittance: %w", err)
+	}
+	remittance.Amount = roundMoney(remittance.Amount)
+
+	log.Printf("🏦 Courier %s remitting %s for %d orders to store %s",
+		courierID, s.cfg.FormatCurrency(remittance.Amount), remittance.OrderCount, storeID)
+	return remittance, nil
+}
+
+// ListForCourier returns the courier's remittances, newest first
+func (s *CODService) ListForCourier(ctx context.Context, courierID uuid.UUID, status string, page, pageSize int) ([]models.CODRemittance, error) {
+	offset := (page - 1) * pageSize
+	return s.codRepo.ListRemittances(ctx, &courierID, nil, status, pageSize, offset)
+}
+
+// ListForStore returns the remittances made to a store, newest first
+func (s *CODService) ListForStore(ctx context.Context, storeID uuid.UUID, status string, page, pageSize int) ([]models.CODRemittance, error) {
+	offset := (page - 1) * pageSize
+	return s.codRepo.ListRemittances(ctx, nil, &storeID, status, pageSize, offset)
+}
+
+// GetForCourier returns one of the courier's remittances with the orders it covers
+func (s *CODService) GetForCourier(ctx context.Context, courierID, id uuid.UUID) (*models.CODRemittance, error) {
+	remittance, err := s.codRepo.GetRemittance(ctx, id)
+	if err != nil || remittance.CourierID != courierID {
+		return nil, ErrRemittanceNotFound
+	}
+	return remittance, nil
+}
+
+// Confirm records that the store received a remittance, clearing the cash from the courier's liability
+func (s *CODService) Confirm(ctx context.Context, storeID, id uuid.UUID, note string) (*models.CODRemittance, error) {
+	return s.decide(ctx, storeID, id, models.RemittanceStatusConfirmed, strings.TrimSpace(note))
+}
+
+// Reject records that the store did not receive a remittance; its orders go back to the courier to remit again
+func (s *CODService) Reject(ctx context.Context, storeID, id uuid.UUID, note string) (*models.CODRemittance, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, fmt.Errorf("%w: a note is required when rejecting a remittance", ErrInvalidCOD)
+	}
+	return s.decide(ctx, storeID, id, models.RemittanceStatusRejected, note)
+}
+
+func (s *CODService) decide(ctx context.Context, storeID, id uuid.UUID, status models.RemittanceStatus, note string) (*models.CODRemittance, error) {
+	remittance, err := s.codRepo.DecideRemittance(ctx, id, storeID, status, note)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRemittanceNotFound):
+			return nil, ErrRemittanceNotFound
+		case errors.Is(err, repository.ErrRemittanceDecided):
+			return nil, ErrRemittanceDecided
+		}
+		return nil, fmt.Errorf("failed to update remittance: %w", err)
+	}
+
+	log.Printf("🏦 Store %s %s remittance %s of %s", storeID, status, remittance.ID, s.cfg.FormatCurrency(remittance.Amount))
+
+	title, message := "Remittance Confirmed", fmt.Sprintf("The store confirmed receiving %s", s.cfg.FormatCurrency(remittance.Amount))
+	if status == models.RemittanceStatusRejected {
+		title, message = "Remittance Rejected", fmt.Sprintf("The store did not confirm receiving %s: %s", s.cfg.FormatCurrency(remittance.Amount), note)
+	}
+	if err := s.notification.Send(ctx, "courier:"+remittance.CourierID.String(), &Notification{
+		Type:    "cod_remittance",
+		Title:   title,
+		Message: message,
+		Data:    map[string]interface{}{"remittanceId": remittance.ID, "status": remittance.Status},
+	}); err != nil {
+		log.Printf("⚠️ Failed to notify courier of remittance %s: %v", remittance.ID, err)
+	}
+	return remittance, nil
+}
+
+// Settlement reconciles the cash on a store's COD orders created between from and to (inclusive
+// dates, YYYY-MM-DD in the default timezone). Without dates it covers the last 30 days.
+func (s *CODService) Settlement(ctx context.Context, storeID uuid.UUID, from, to string) (*models.CODSettlementReport, error) {
+	start, end, err := s.settlementPeriod(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := s.codRepo.SettlementLines(ctx, storeID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.CODSettlementReport{
+		StoreID:  storeID,
+		From:     start,
+		To:       end,
+		Orders:   len(lines),
+		Currency: s.cfg.Currency,
+		Lines:    lines,
+	}
+	couriers := map[uuid.UUID]*models.CODCourierSettlement{}
+	var courierIDs []uuid.UUID
+	for _, line := range lines {
+		c, ok := couriers[line.CourierID]
+		if !ok {
+			c = &models.CODCourierSettlement{CourierID: line.CourierID}
+			couriers[line.CourierID] = c
+			courierIDs = append(courierIDs, line.CourierID)
+		}
+		c.Orders++
+
+		switch line.CODStatus {
+		case models.CODStatusPending:
+			report.Pending += line.Expected
+		case models.CODStatusCollected:
+			report.Outstanding += line.Collected
+			c.Outstanding += line.Collected
+		case models.CODStatusRemitting:
+			report.Remitting += line.Collected
+			c.Outstanding += line.Collected
+		case models.CODStatusRemitted:
+			report.Remitted += line.Collected
+			c.Remitted += line.Collected
+		}
+		if line.OrderStatus == models.OrderStatusDelivered {
+			report.Expected += line.Expected
+			report.Collected += line.Collected
+			c.Collected += line.Collected
+		}
+	}
+	report.Shortfall = report.Expected - report.Collected
+
+	report.Couriers = make([]models.CODCourierSettlement, 0, len(courierIDs))
+	for _, id := range courierIDs {
+		c := couriers[id]
+		c.Collected, c.Remitted, c.Outstanding = roundMoney(c.Collected), roundMoney(c.Remitted), roundMoney(c.Outstanding)
+		report.Couriers = append(report.Couriers, *c)
+	}
+	for _, total := range []*float64{
+		&report.Expected, &report.Collected, &report.Shortfall, &report.Remitting,
+		&report.Remitted, &report.Outstanding, &report.Pending,
+	} {
+		*total = roundMoney(*total)
+	}
+	return report, nil
+}
+
+// WriteSettlement writes a settlement report's order lines as CSV
+func (s *CODService) WriteSettlement(w io.Writer, report *models.CODSettlementReport) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"orderNumber", "externalOrderId", "orderId", "courierId", "orderStatus", "codStatus",
+		"expected", "collected", "collectedAt", "remittanceId", "createdAt",
+	})
+
+	money := func(amount float64) string { return strconv.FormatFloat(amount, 'f', 2, 64) }
+	for _, line := range report.Lines {
+		collectedAt, remittanceID := "", ""
+		if line.CollectedAt != nil {
+			collectedAt = line.CollectedAt.Format(time.RFC3339)
+		}
+		if line.RemittanceID != nil {
+			remittanceID = line.RemittanceID.String()
+		}
+		writer.Write([]string{
+			line.OrderNumber, line.ExternalOrderID, line.OrderID.String(), line.CourierID.String(),
+			string(line.OrderStatus), string(line.CODStatus), money(line.Expected), money(line.Collected),
+			collectedAt, remittanceID, line.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// settlementPeriod resolves a report's dates to a [start, end) range in the default timezone
+func (s *CODService) settlementPeriod(from, to string) (time.Time, time.Time, error) {
+	loc, err := time.LoadLocation(s.cfg.DefaultTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	now := time.Now().In(loc)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	if to != "" {
+		if end, err = time.ParseInLocation(dateLayout, to, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be a date (YYYY-MM-DD)", ErrInvalidCOD)
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	start := end.AddDate(0, 0, -codSettlementDefaultDays)
+	if from != "" {
+		if start, err = time.ParseInLocation(dateLayout, from, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be a date (YYYY-MM-DD)", ErrInvalidCOD)
+		}
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must not be after to", ErrInvalidCOD)
+	}
+	return start, end, nil
+}
+
+// codSummary is the webhook payload for a collection
+func codSummary(order *models.Order) map[string]interface{} {
+	return map[string]interface{}{
+		"codAmount":      order.CODAmount,
+		"codCollected":   order.CODCollected,
+		"shortfall":      math.Max(0, roundMoney(order.CODAmount-order.CODCollected)),
+		"codNote":        order.CODNote,
+		"codCollectedAt": order.CODCollectedAt,
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -172,8 +173,20 @@ func (s *CourierService) UpdateProfile(ctx context.Context, courierID uuid.UUID,
 		courier.MaxWeight = *req.MaxWeight
 	}
 	if req.OperatingHours != nil {
+		if _, err := parseOperatingHours(*req.OperatingHours); err != nil {
+			return nil, err
+		}
 		courier.OperatingHours = *req.OperatingHours
 	}
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if timezone != "" {
+			if _, err := courierLocation(timezone, ""); err != nil {
+				return nil, err
+			}
+		}
+		courier.Timezone = timezone
+	}
 	if req.BaseRatePerKm != nil {
 		courier.BaseRatePerKm = *req.BaseRatePerKm
 		courier.CustomPricing = true
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// CourierStatsService keeps courier rating and delivery aggregates in sync with their orders
+type CourierStatsService struct {
+	orderRepo   *repository.OrderRepository
+	courierRepo *repository.CourierRepository
+}
+
+// NewCourierStatsService creates a new courier statistics service
+func NewCourierStatsService(orderRepo *repository.OrderRepository, courierRepo *repository.CourierRepository) *CourierStatsService {
+	return &CourierStatsService{orderRepo: orderRepo, courierRepo: courierRepo}
+}
+
+// Recompute recalculates and stores the aggregates for one courier
+func (s *CourierStatsService) Recompute(ctx context.Context, courierID uuid.UUID) (*models.CourierStats, error) {
+	stats, err := s.orderRepo.GetCourierStats(ctx, courierID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.courierRepo.UpdateStats(ctx, courierID, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// RecomputeAll recalculates the aggregates for every courier and returns how many were updated
+func (s *CourierStatsService) RecomputeAll(ctx context.Context) (int, error) {
+	ids, err := s.courierRepo.ListIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, id := range ids {
+		if _, err := s.Recompute(ctx, id); err != nil {
+			log.Printf("⚠️ Failed to recompute stats for courier %s: %v", id, err)
+			continue
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// RecomputeOnTransition is a transition hook that refreshes a courier's aggregates
+// whenever one of their orders reaches a final delivery outcome
+func (s *CourierStatsService) RecomputeOnTransition(ctx context.Context, order *models.Order, change models.StatusChange) {
+	if change.Status != models.OrderStatusDelivered && change.Status != models.OrderStatusFailed {
+		return
+	}
+	if _, err := s.Recompute(ctx, order.CourierID); err != nil {
+		log.Printf("⚠️ Failed to recompute stats for courier %s: %v", order.CourierID, err)
+	}
+}
+
+// Run periodically recomputes all courier aggregates to correct any drift until ctx is cancelled
+func (s *CourierStatsService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			updated, err := s.RecomputeAll(ctx)
+			if err != nil {
+				log.Printf("⚠️ Courier stats recomputation failed: %v", err)
+				continue
+			}
+			log.Printf("📊 Recomputed stats for %d couriers", updated)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// Store webhook events for failed delivery attempts and returns
+const (
+	WebhookDeliveryAttemptFailed = "order.delivery_attempt_failed"
+	WebhookReattemptScheduled    = "order.reattempt_scheduled"
+	WebhookReattemptStarted      = "order.reattempt_started"
+	WebhookReturnStarted         = "order.return_started"
+	WebhookOrderReturned         = "order.returned"
+	WebhookReturnFailed          = "order.return_failed"
+)
+
+var (
+	// ErrInvalidAttempt is returned when a failed attempt fails validation
+	ErrInvalidAttempt = errors.New("invalid delivery attempt")
+	// ErrAttemptNotAllowed is returned when the parcel is not out for delivery
+	ErrAttemptNotAllowed = errors.New("a failed attempt can only be recorded while the parcel is out for delivery")
+	// ErrAttemptOnMultiStop is returned for multi-stop orders, whose drop-offs fail individually
+	ErrAttemptOnMultiStop = errors.New("failed drop-offs on multi-stop orders are recorded per stop")
+)
+
+// DeliveryAttemptService records failed delivery attempts. Each failure schedules a re-attempt until
+// MAX_DELIVERY_ATTEMPTS is reached (or the customer refused the parcel); the order is then sent back
+// to its pickup address on a return leg, a new order that is priced and tracked like any other.
+type DeliveryAttemptService struct {
+	orderRepo    *repository.OrderRepository
+	stateMachine *OrderStateMachine
+	pricing      *PricingService
+	tracking     *TrackingService
+	notification *NotificationService
+	webhooks     *StoreWebhookService
+	cfg          *config.Config
+}
+
+// NewDeliveryAttemptService creates a new delivery attempt service
+func NewDeliveryAttemptService(
+	orderRepo *repository.OrderRepository,
+	stateMachine *OrderStateMachine,
+	pricing *PricingService,
+	tracking *TrackingService,
+	notification *NotificationService,
+	webhooks *StoreWebhookService,
+	cfg *config.Config,
+) *DeliveryAttemptService {
+	return &DeliveryAttemptService{
+		orderRepo:    orderRepo,
+		stateMachine: stateMachine,
+		pricing:      pricing,
+		tracking:     tracking,
+		notification: notification,
+		webhooks:     webhooks,
+		cfg:          cfg,
+	}
+}
+
+// RecordFailure records a failed attempt on one of the courier's orders and moves the order on:
+// to reattempt_scheduled, to returning with a new return leg, or, for a return leg, to failed
+func (s *DeliveryAttemptService) RecordFailure(ctx context.Context, courierID, orderID uuid.UUID, req *models.RecordAttemptRequest) (*models.FailedAttemptResult, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	if order.OrderType == models.OrderTypeMultiStop {
+		return nil, ErrAttemptOnMultiStop
+	}
+	if order.Status != models.OrderStatusInTransit && order.Status != models.OrderStatusPickedUp {
+		return nil, ErrAttemptNotAllowed
+	}
+
+	note := strings.TrimSpace(req.Note)
+	if !req.Reason.IsValid() {
+		return nil, fmt.Errorf("%w: reason must be customer_absent, wrong_address, refused, customer_unreachable or other", ErrInvalidAttempt)
+	}
+	if req.Reason == models.AttemptReasonOther && note == "" {
+		return nil, fmt.Errorf("%w: a note is required when the reason is other", ErrInvalidAttempt)
+	}
+
+	attempt := &models.DeliveryAttempt{
+		OrderID:       order.ID,
+		CourierID:     courierID,
+		AttemptNumber: order.DeliveryAttempts + 1,
+		Reason:        req.Reason,
+		Note:          note,
+	}
+	result := &models.FailedAttemptResult{Order: order}
+	previousStatus := order.Status
+
+	switch {
+	case attempt.AttemptNumber < s.cfg.MaxDeliveryAttempts && req.Reason.AllowsReattempt():
+		if err := s.scheduleReattempt(ctx, order, attempt); err != nil {
+			return nil, err
+		}
+		result.AttemptsRemaining = s.cfg.MaxDeliveryAttempts - attempt.AttemptNumber
+	case order.OrderType == models.OrderTypeReturn:
+		// A return leg that cannot be handed back ends here; the parcel stays with the courier for manual resolution
+		attempt.Outcome = models.AttemptOutcomeFailed
+		if _, err := s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusFailed, models.StatusActorCourier, attemptNote(attempt)); err != nil {
+			return nil, err
+		}
+	default:
+		returnOrder, err := s.returnToSender(ctx, order, attempt)
+		if err != nil {
+			return nil, err
+		}
+		result.ReturnOrder = returnOrder
+	}
+
+	if err := s.orderRepo.RecordDeliveryAttempt(ctx, attempt); err != nil {
+		if errors.Is(err, repository.ErrAttemptAlreadyRecorded) {
+			return nil, ErrConcurrentStatusChange
+		}
+		return nil, fmt.Errorf("failed to record delivery attempt: %w", err)
+	}
+	order.DeliveryAttempts = attempt.AttemptNumber
+	order.NextAttemptAt = attempt.NextAttemptAt
+	order.ReattemptReminderSentAt = nil
+	result.Attempt = *attempt
+
+	log.Printf("🚪 Delivery attempt %d failed for order %s (%s): %s", attempt.AttemptNumber, order.OrderNumber, attempt.Reason, attempt.Outcome)
+
+	// The driver is no longer heading to the customer
+	if previousStatus == models.OrderStatusInTransit {
+		if err := s.tracking.StopTracking(ctx, order.ID, "delivery_attempt_failed"); err != nil {
+			log.Printf("⚠️ Failed to stop tracking for order %s: %v", order.OrderNumber, err)
+		}
+	}
+
+	s.webhooks.Send(order, WebhookDeliveryAttemptFailed, map[string]interface{}{
+		"attempt":           attempt,
+		"attemptsRemaining": result.AttemptsRemaining,
+	})
+	switch attempt.Outcome {
+	case models.AttemptOutcomeReattempt:
+		s.webhooks.Send(order, WebhookReattemptScheduled, map[string]interface{}{
+			"attemptNumber": attempt.AttemptNumber + 1,
+			"nextAttemptAt": attempt.NextAttemptAt,
+		})
+		if err := s.notification.SendReattemptScheduled(ctx, order); err != nil {
+			log.Printf("⚠️ Failed to notify customer of re-attempt for order %s: %v", order.OrderNumber, err)
+		}
+	case models.AttemptOutcomeReturn:
+		s.webhooks.Send(order, WebhookReturnStarted, returnSummary(result.ReturnOrder))
+	}
+
+	return result, nil
+}
+
+// List returns an order's failed attempts
+func (s *DeliveryAttemptService) List(ctx context.Context, orderID uuid.UUID) ([]models.DeliveryAttempt, error) {
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, ErrOrderNotFound
+	}
+	return s.orderRepo.ListDeliveryAttempts(ctx, orderID)
+}
+
+// ListForCourier returns the failed attempts of one of the courier's orders
+func (s *DeliveryAttemptService) ListForCourier(ctx context.Context, courierID, orderID uuid.UUID) ([]models.DeliveryAttempt, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return s.orderRepo.ListDeliveryAttempts(ctx, orderID)
+}
+
+// AnnounceReattempt is a transition hook that tells the store when the driver sets out on a re-attempt
+func (s *DeliveryAttemptService) AnnounceReattempt(ctx context.Context, order *models.Order, change models.StatusChange) {
+	if change.Status != models.OrderStatusInTransit || len(order.StatusHistory) < 2 {
+		return
+	}
+	if order.StatusHistory[len(order.StatusHistory)-2].Status != models.OrderStatusReattemptScheduled {
+		return
+	}
+	s.webhooks.Send(order, WebhookReattemptStarted, map[string]interface{}{
+		"attemptNumber": order.DeliveryAttempts + 1,
+	})
+}
+
+// CompleteReturn is a transition hook that closes the original order once its return leg
+// has been handed back to the sender, or could not be
+func (s *DeliveryAttemptService) CompleteReturn(ctx context.Context, order *models.Order, change models.StatusChange) {
+	if order.ReturnOfOrderID == nil {
+		return
+	}
+
+	var status models.OrderStatus
+	var note string
+	switch change.Status {
+	case models.OrderStatusDelivered:
+		status, note = models.OrderStatusReturned, "Returned to sender by order "+order.OrderNumber
+	case models.OrderStatusFailed, models.OrderStatusCancelled:
+		status, note = models.OrderStatusFailed, "Return leg "+order.OrderNumber+" was "+string(change.Status)
+	default:
+		return
+	}
+
+	original, err := s.stateMachine.Transition(ctx, *order.ReturnOfOrderID, status, models.StatusActorSystem, note)
+	if err != nil {
+		log.Printf("⚠️ Failed to close order %s after its return leg %s: %v", order.ReturnOfOrderID, order.OrderNumber, err)
+		return
+	}
+	s.webhooks.Send(original, returnEvent(status), returnSummary(order))
+}
+
+// Run reminds couriers of due re-attempts every interval until ctx is cancelled
+func (s *DeliveryAttemptService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sendReattemptReminders(ctx, time.Now())
+		}
+	}
+}
+
+// sendReattemptReminders notifies couriers whose re-attempts are due
+func (s *DeliveryAttemptService) sendReattemptReminders(ctx context.Context, now time.Time) {
+	ids, err := s.orderRepo.ClaimReattemptReminders(ctx, now, pickupSchedulerBatch)
+	if err != nil {
+		log.Printf("⚠️ Failed to claim re-attempt reminders: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		order, err := s.orderRepo.GetByID(ctx, id)
+		if err != nil {
+			log.Printf("⚠️ Failed to load order %s for re-attempt reminder: %v", id, err)
+			continue
+		}
+		if err := s.notification.SendReattemptDue(ctx, order); err != nil {
+			log.Printf("⚠️ Failed to send re-attempt reminder for order %s: %v", order.OrderNumber, err)
+			continue
+		}
+		log.Printf("⏰ Re-attempt reminder sent for order %s", order.OrderNumber)
+	}
+}
+
+// scheduleReattempt moves the order to reattempt_scheduled, due REATTEMPT_DELAY from now
+func (s *DeliveryAttemptService) scheduleReattempt(ctx context.Context, order *models.Order, attempt *models.DeliveryAttempt) error {
+	next := time.Now().Add(s.cfg.ReattemptDelay)
+	attempt.Outcome = models.AttemptOutcomeReattempt
+	attempt.NextAttemptAt = &next
+
+	note := fmt.Sprintf("%s; re-attempt scheduled for %s", attemptNote(attempt), next.Format(time.RFC3339))
+	_, err := s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusReattemptScheduled, models.StatusActorCourier, note)
+	return err
+}
+
+// returnToSender creates the return leg back to the pickup address and moves the order to returning.
+// The leg is created first so that two concurrent requests cannot both return the parcel.
+func (s *DeliveryAttemptService) returnToSender(ctx context.Context, order *models.Order, attempt *models.DeliveryAttempt) (*models.Order, error) {
+	returnOrder, err := s.buildReturnLeg(order)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.orderRepo.Create(ctx, returnOrder); err != nil {
+		if errors.Is(err, repository.ErrReturnLegExists) {
+			return nil, ErrConcurrentStatusChange
+		}
+		return nil, fmt.Errorf("failed to create return leg: %w", err)
+	}
+
+	attempt.Outcome = models.AttemptOutcomeReturn
+	attempt.ReturnOrderID = &returnOrder.ID
+
+	note := fmt.Sprintf("%s; returning to sender as order %s", attemptNote(attempt), returnOrder.OrderNumber)
+	if _, err := s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusReturning, models.StatusActorCourier, note); err != nil {
+		if delErr := s.orderRepo.DeleteReturnLeg(ctx, returnOrder.ID, order.ID); delErr != nil {
+			log.Printf("⚠️ Failed to remove return leg %s of order %s: %v", returnOrder.OrderNumber, order.OrderNumber, delErr)
+		}
+		return nil, err
+	}
+	order.ReturnOrderID = &returnOrder.ID
+
+	log.Printf("↩️ Order %s returning to sender as order %s", order.OrderNumber, returnOrder.OrderNumber)
+	return returnOrder, nil
+}
+
+// buildReturnLeg prices a new order from the drop-off back to the pickup address. The sender is
+// the recipient and signs for the parcel; it is already on board, so the leg starts picked up.
+func (s *DeliveryAttemptService) buildReturnLeg(order *models.Order) (*models.Order, error) {
+	estimate, err := s.pricing.CalculateEstimate(&models.PriceEstimateRequest{
+		PickupLatitude: order.DeliveryLatitude, PickupLongitude: order.DeliveryLongitude,
+		DeliveryLatitude: order.PickupLatitude, DeliveryLongitude: order.PickupLongitude,
+		PackageWeight: order.PackageWeight, IsFragile: order.IsFragile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to price return leg: %w", err)
+	}
+	platformFee, earnings := s.pricing.CalculateCourierEarnings(estimate.TotalFare)
+
+	recipient := order.PickupContactName
+	if recipient == "" {
+		recipient = "Sender"
+	}
+
+	return &models.Order{
+		CourierID: order.CourierID, StoreID: order.StoreID, ExternalOrderID: order.ExternalOrderID,
+		OrderType: models.OrderTypeReturn, ReturnOfOrderID: &order.ID,
+		CustomerName: recipient, CustomerPhone: order.PickupContactPhone,
+		PickupAddress: order.DeliveryAddress, PickupLatitude: order.DeliveryLatitude, PickupLongitude: order.DeliveryLongitude,
+		PickupContactName: order.CustomerName, PickupContactPhone: order.CustomerPhone,
+		DeliveryAddress: order.PickupAddress, DeliveryLatitude: order.PickupLatitude, DeliveryLongitude: order.PickupLongitude,
+		DeliveryNotes:      order.PickupNotes,
+		PackageDescription: order.PackageDescription, PackageSize: order.PackageSize, PackageWeight: order.PackageWeight,
+		IsFragile: order.IsFragile, RequiresSignature: true,
+		Distance: estimate.Distance, BaseFare: estimate.BaseFare, DistanceFare: estimate.DistanceFare,
+		SurgeFare: estimate.SurgeFare, TotalFare: estimate.TotalFare,
+		PlatformFee: platformFee, CourierEarnings: earnings,
+		PaymentMethod: order.PaymentMethod,
+		Metadata:      order.Metadata,
+	}, nil
+}
+
+func attemptNote(attempt *models.DeliveryAttempt) string {
+	note := fmt.Sprintf("Delivery attempt %d failed: %s", attempt.AttemptNumber, attempt.Reason)
+	if attempt.Note != "" {
+		note += " (" + attempt.Note + ")"
+	}
+	return note
+}
+
+func returnEvent(status models.OrderStatus) string {
+	if status == models.OrderStatusReturned {
+		return WebhookOrderReturned
+	}
+	return WebhookReturnFailed
+}
+
+func returnSummary(returnOrder *models.Order) map[string]interface{} {
+	return map[string]interface{}{
+		"returnOrderId":     returnOrder.ID,
+		"returnOrderNumber": returnOrder.OrderNumber,
+		"status":            returnOrder.Status,
+		"distance":          returnOrder.Distance,
+		"totalFare":         returnOrder.TotalFare,
+	}
+}
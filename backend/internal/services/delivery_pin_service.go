@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// ErrDeliveryPINRequired is returned when an order is marked delivered without the customer's PIN
+var ErrDeliveryPINRequired = errors.New("the customer's delivery PIN must be confirmed first")
+
+// ErrDeliveryPINNotIssued is returned when delivery needs a PIN but none reached the customer
+var ErrDeliveryPINNotIssued = errors.New("the customer was not sent a delivery PIN, resend it first")
+
+// ErrSignatureRequired is returned when a signature-required order has no uploaded signature
+var ErrSignatureRequired = errors.New("a recipient signature must be uploaded first")
+
+// InvalidPINError is returned when the submitted PIN does not match
+type InvalidPINError struct {
+	RemainingAttempts int
+}
+
+func (e *InvalidPINError) Error() string {
+	return fmt.Sprintf("incorrect delivery PIN, %d attempts remaining", e.RemainingAttempts)
+}
+
+// PINLockedError is returned while PIN submissions are locked after too many failures
+type PINLockedError struct {
+	Until time.Time
+}
+
+func (e *PINLockedError) Error() string {
+	return fmt.Sprintf("too many incorrect PINs, try again after %s", e.Until.Format(time.Kitchen))
+}
+
+// DeliveryPINService issues one-time delivery PINs and confirms handoffs with them
+type DeliveryPINService struct {
+	orderRepo    *repository.OrderRepository
+	stateMachine *OrderStateMachine
+	notification *NotificationService
+	cfg          *config.Config
+}
+
+// NewDeliveryPINService creates a new delivery PIN service
+func NewDeliveryPINService(
+	orderRepo *repository.OrderRepository,
+	stateMachine *OrderStateMachine,
+	notification *NotificationService,
+	cfg *config.Config,
+) *DeliveryPINService {
+	return &DeliveryPINService{
+		orderRepo:    orderRepo,
+		stateMachine: stateMachine,
+		notification: notification,
+		cfg:          cfg,
+	}
+}
+
+// IssueOnTransit is a transition hook that sends a PIN to the customer when an order goes in transit.
+// The transition has already marked the PIN as required, so if issuing fails delivery stays blocked
+// until the driver resends it.
+func (s *DeliveryPINService) IssueOnTransit(ctx context.Context, order *models.Order, change models.StatusChange) {
+	if change.Status != models.OrderStatusInTransit || !order.DeliveryPINRequired {
+		return
+	}
+	if err := s.issuePIN(ctx, order); err != nil {
+		log.Printf("⚠️ Failed to issue delivery PIN for order %s, delivery is blocked until it is resent: %v", order.OrderNumber, err)
+	}
+}
+
+// GuardDelivery is a transition guard that blocks delivery until the handoff has been confirmed.
+// Orders that went in transit before PINs were required have no PIN and only need a signature if required.
+// Multi-stop orders are delivered once every stop is completed and at least one was delivered.
+func (s *DeliveryPINService) GuardDelivery(ctx context.Context, order *models.Order, to models.OrderStatus, actor string) error {
+	if to != models.OrderStatusDelivered {
+		return nil
+	}
+	if order.OrderType == models.OrderTypeMultiStop {
+		delivered := 0
+		for _, stop := range order.Stops {
+			if !stop.IsFinal() {
+				return ErrCompleteStopsFirst
+			}
+			if stop.Status == models.StopStatusDelivered {
+				delivered++
+			}
+		}
+		if delivered == 0 {
+			return ErrCompleteStopsFirst
+		}
+		return nil
+	}
+	if order.RequiresSignature && order.SignatureURL == "" {
+		return ErrSignatureRequired
+	}
+	if (order.DeliveryPINRequired || order.DeliveryPINHash != "") && order.DeliveryPINVerifiedAt == nil {
+		return ErrDeliveryPINRequired
+	}
+	return nil
+}
+
+// ConfirmDelivery checks the PIN submitted by the driver and marks the order delivered
+func (s *DeliveryPINService) ConfirmDelivery(ctx context.Context, courierID, orderID uuid.UUID, pin, note, ipAddress string) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	if order.OrderType == models.OrderTypeMultiStop {
+		return nil, ErrCompleteStopsFirst
+	}
+	if order.Status != models.OrderStatusInTransit {
+		return nil, &InvalidTransitionError{From: order.Status, To: models.OrderStatusDelivered}
+	}
+	if order.RequiresSignature && order.SignatureURL == "" {
+		return nil, ErrSignatureRequired
+	}
+	// Checked before the PIN so a correct PIN is not spent on a handoff that cannot complete yet
+	if order.CODAmount > 0 && order.CODStatus == models.CODStatusPending {
+		return nil, ErrCODCollectionRequired
+	}
+
+	if order.DeliveryPINRequired && order.DeliveryPINHash == "" {
+		return nil, ErrDeliveryPINNotIssued
+	}
+	if order.DeliveryPINHash != "" {
+		if err := s.verifyPIN(ctx, order, courierID, strings.TrimSpace(pin), ipAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	if note == "" {
+		note = "Delivery confirmed with customer PIN"
+	}
+	return s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusDelivered, models.StatusActorCourier, note)
+}
+
+// ResendPIN issues a fresh PIN for an order in transit, e.g. when the customer lost the original
+func (s *DeliveryPINService) ResendPIN(ctx context.Context, courierID, orderID uuid.UUID) error {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if order.CourierID != courierID {
+		return ErrNotOrderCourier
+	}
+	if order.Status != models.OrderStatusInTransit {
+		return errors.New("a delivery PIN can only be resent while the order is in transit")
+	}
+	if order.OrderType == models.OrderTypeMultiStop {
+		return ErrCompleteStopsFirst
+	}
+	// Reissuing clears the attempt counter, so it must not be usable to skip a lockout
+	if order.DeliveryPINLockedUntil != nil && order.DeliveryPINLockedUntil.After(time.Now()) {
+		return &PINLockedError{Until: *order.DeliveryPINLockedUntil}
+	}
+	return s.issuePIN(ctx, order)
+}
+
+// verifyPIN compares the submitted PIN, applying the lockout and recording every attempt.
+// The attempt is counted before the comparison so the lockout holds against parallel submissions.
+func (s *DeliveryPINService) verifyPIN(ctx context.Context, order *models.Order, courierID uuid.UUID, pin, ipAddress string) error {
+	attempts, lockedUntil, err := s.orderRepo.ClaimDeliveryPINAttempt(ctx, order.ID, s.cfg.DeliveryPINMaxAttempts, s.cfg.DeliveryPINLockout)
+	if errors.Is(err, repository.ErrDeliveryPINLocked) {
+		log.Printf("🔒 Delivery PIN attempt for locked order %s by courier %s from %s", order.OrderNumber, courierID, ipAddress)
+		if lockedUntil == nil {
+			// The lock expired between the attempt and reading it back
+			now := time.Now()
+			lockedUntil = &now
+		}
+		return &PINLockedError{Until: *lockedUntil}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record PIN attempt: %w", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(order.DeliveryPINHash), []byte(pin)) != nil {
+		if err := s.orderRepo.LogDeliveryPINAttempt(ctx, order.ID, courierID, false, ipAddress); err != nil {
+			log.Printf("⚠️ Failed to log delivery PIN attempt for order %s: %v", order.OrderNumber, err)
+		}
+		log.Printf("⚠️ Incorrect delivery PIN for order %s by courier %s from %s (%d/%d)",
+			order.OrderNumber, courierID, ipAddress, attempts, s.cfg.DeliveryPINMaxAttempts)
+
+		if lockedUntil != nil {
+			return &PINLockedError{Until: *lockedUntil}
+		}
+		return &InvalidPINError{RemainingAttempts: s.cfg.DeliveryPINMaxAttempts - attempts}
+	}
+
+	if err := s.orderRepo.LogDeliveryPINAttempt(ctx, order.ID, courierID, true, ipAddress); err != nil {
+		log.Printf("⚠️ Failed to log delivery PIN attempt for order %s: %v", order.OrderNumber, err)
+	}
+	if err := s.orderRepo.MarkDeliveryPINVerified(ctx, order.ID); err != nil {
+		return fmt.Errorf("failed to record PIN verification: %w", err)
+	}
+
+	now := time.Now()
+	order.DeliveryPINVerifiedAt = &now
+	order.DeliveryPINAttempts = 0
+	order.DeliveryPINLockedUntil = nil
+	return nil
+}
+
+// issuePIN generates a new PIN, stores its hash and sends it to the customer
+func (s *DeliveryPINService) issuePIN(ctx context.Context, order *models.Order) error {
+	pin, err := generatePIN(s.cfg.DeliveryPINLength)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.orderRepo.SetDeliveryPIN(ctx, order.ID, string(hash)); err != nil {
+		return err
+	}
+	order.DeliveryPINHash = string(hash)
+	order.DeliveryPINAttempts = 0
+	order.DeliveryPINLockedUntil = nil
+	order.DeliveryPINVerifiedAt = nil
+
+	if s.notification == nil {
+		return errors.New("notifications are unavailable, the customer was not sent their PIN")
+	}
+	if err := s.notification.SendDeliveryPIN(ctx, order, pin); err != nil {
+		return fmt.Errorf("failed to send PIN to customer: %w", err)
+	}
+
+	log.Printf("🔑 Delivery PIN sent for order %s", order.OrderNumber)
+	return nil
+}
+
+// generatePIN returns a random numeric PIN of the given length
+func generatePIN(length int) (string, error) {
+	if length < 4 {
+		length = 4
+	}
+	digits := make([]byte, length)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
+}
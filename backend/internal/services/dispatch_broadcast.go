@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// BroadcastOrder creates an order without a courier and offers it to every eligible courier at once.
+// The first to accept gets it. No order is created when no courier is eligible.
+func (s *DispatchService) BroadcastOrder(ctx context.Context, req *models.CreateOrderRequest, area string) (*models.Order, error) {
+	area = strings.TrimSpace(area)
+	entries, err := s.rankRequest(ctx, req, area, s.cfg.DispatchMaxRadiusKm)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.orders.Create(ctx, uuid.Nil, req)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.cfg.BroadcastWindow)
+	if err := s.orderRepo.StartBroadcast(ctx, order.ID, area, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to start broadcast: %w", err)
+	}
+	order.DispatchMode = models.DispatchModeBroadcast
+	order.DispatchStatus = models.DispatchStatusOffered
+	order.DispatchArea = area
+	order.OfferExpiresAt = &expiresAt
+
+	s.broadcast(ctx, order, 1, entries)
+	return order, nil
+}
+
+// Offers returns the broadcast orders the courier can still accept
+func (s *DispatchService) Offers(ctx context.Context, courierID uuid.UUID) ([]*models.Order, error) {
+	ids, err := s.orderRepo.ListBroadcastOffers(ctx, courierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offers: %w", err)
+	}
+
+	orders := make([]*models.Order, 0, len(ids))
+	for _, id := range ids {
+		order, err := s.orderRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load offered order %s: %w", id, err)
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// Assign gives an order without a courier to a courier the store picked, typically after its
+// broadcast was handed back to the store. The courier still has to accept it.
+func (s *DispatchService) Assign(ctx context.Context, orderID, courierID uuid.UUID) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.CourierID != uuid.Nil || order.Status != models.OrderStatusPending {
+		return nil, fmt.Errorf("%w: order %s already has a courier or is no longer pending", ErrOfferUnavailable, order.OrderNumber)
+	}
+	courier, err := s.courierRepo.GetByID(ctx, courierID)
+	if err != nil || !courier.IsActive {
+		return nil, fmt.Errorf("%w: courier not found or inactive", ErrInvalidOrder)
+	}
+
+	history, err := s.dispatchRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dispatch log: %w", err)
+	}
+	state := dispatchHistory(history)
+
+	if err := s.orderRepo.AssignUnassigned(ctx, order.ID, courierID); err != nil {
+		if errors.Is(err, repository.ErrOfferChanged) {
+			return nil, ErrOfferUnavailable
+		}
+		return nil, err
+	}
+	open := state.open(order)
+	order.CourierID = courierID
+	order.DispatchStatus = models.DispatchStatusAssigned
+	order.OfferExpiresAt = nil
+
+	entries := []models.DispatchLogEntry{{CourierID: &courierID, Decision: models.DispatchDecisionOffered, Reason: "Assigned by the store"}}
+	s.record(ctx, order, state.round, append(entries, s.withdrawAll(ctx, order, open, "the store assigned the order")...))
+
+	log.Printf("🧭 Order %s assigned to courier %s by the store", order.OrderNumber, courierID)
+	if err := s.notification.SendNewOrder(ctx, courierID.String(), order.ID.String(), order.CustomerName); err != nil {
+		log.Printf("⚠️ Failed to notify courier %s of order %s: %v", courierID, order.OrderNumber, err)
+	}
+	return order, nil
+}
+
+// claimBroadcast accepts a broadcast order for the first courier to ask. The claim is a conditional
+// update on the unassigned row, so of several couriers accepting at once exactly one succeeds; the
+// others get ErrOfferUnavailable and every other courier the order was offered to is withdrawn.
+func (s *DispatchService) claimBroadcast(ctx context.Context, courierID uuid.UUID, order *models.Order) (*models.Order, error) {
+	history, err := s.dispatchRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dispatch log: %w", err)
+	}
+	state := dispatchHistory(history)
+	if !state.offered[courierID] {
+		return nil, ErrNotOrderCourier
+	}
+	if state.declined[courierID] || order.DispatchStatus != models.DispatchStatusOffered || order.Status != models.OrderStatusPending {
+		return nil, ErrOfferUnavailable
+	}
+
+	if err := s.orderRepo.ClaimBroadcast(ctx, order.ID, courierID); err != nil {
+		if errors.Is(err, repository.ErrOfferChanged) {
+			return nil, ErrOfferUnavailable
+		}
+		return nil, err
+	}
+	open := state.open(order)
+	order.CourierID = courierID
+	order.DispatchStatus = models.DispatchStatusAssigned
+	order.OfferExpiresAt = nil
+
+	if _, err := s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusAccepted, models.StatusActorCourier, "Order accepted (first to accept a broadcast)"); err != nil {
+		return nil, err
+	}
+
+	delete(open, courierID)
+	entries := []models.DispatchLogEntry{{CourierID: &courierID, Decision: models.DispatchDecisionAccepted}}
+	s.record(ctx, order, state.round, append(entries, s.withdrawAll(ctx, order, open, "another courier accepted it")...))
+
+	log.Printf("🧭 Broadcast order %s claimed by courier %s", order.OrderNumber, courierID)
+	s.webhooks.Send(order, WebhookCourierAssigned, map[string]interface{}{
+		"courierId": courierID,
+		"round":     state.round,
+	})
+	return order, nil
+}
+
+// declineBroadcast takes the courier out of a broadcast. Once everyone it was offered to has
+// declined, the broadcast escalates straight away.
+func (s *DispatchService) declineBroadcast(ctx context.Context, courierID uuid.UUID, order *models.Order) (*models.Order, error) {
+	history, err := s.dispatchRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dispatch log: %w", err)
+	}
+	state := dispatchHistory(history)
+	if !state.offered[courierID] {
+		return nil, ErrNotOrderCourier
+	}
+	if state.declined[courierID] {
+		return order, nil
+	}
+	if order.DispatchStatus != models.DispatchStatusOffered || order.Status != models.OrderStatusPending {
+		return nil, ErrOfferUnavailable
+	}
+
+	s.record(ctx, order, state.round, []models.DispatchLogEntry{{CourierID: &courierID, Decision: models.DispatchDecisionDeclined, Reason: "Courier declined the offer"}})
+	state.declined[courierID] = true
+
+	if len(state.open(order)) == 0 {
+		if err := s.escalate(ctx, order, "Every courier declined"); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// escalate handles a broadcast nobody accepted: the first time it is widened to
+// BROADCAST_ESCALATION_RADIUS_KM for couriers not yet offered the order, after that (or when nobody
+// new is in range) it is handed back to the store, which can assign a courier or cancel.
+func (s *DispatchService) escalate(ctx context.Context, order *models.Order, reason string) error {
+	history, err := s.dispatchRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load dispatch log: %w", err)
+	}
+	state := dispatchHistory(history)
+	radius := s.cfg.BroadcastEscalationRadiusKm
+
+	if !state.escalated && radius > s.cfg.DispatchMaxRadiusKm {
+		entries, _, err := s.rank(ctx, order, state.offered, radius)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 && entries[0].Decision == models.DispatchDecisionRanked {
+			expiresAt := time.Now().Add(s.cfg.BroadcastWindow)
+			if err := s.orderRepo.ExtendBroadcast(ctx, order.ID, expiresAt); err != nil {
+				if errors.Is(err, repository.ErrOfferChanged) {
+					return ErrConcurrentStatusChange
+				}
+				return err
+			}
+			order.OfferExpiresAt = &expiresAt
+
+			s.record(ctx, order, state.round, []models.DispatchLogEntry{{
+				Decision: models.DispatchDecisionEscalated,
+				Reason:   fmt.Sprintf("%s; widened to %.0f km", reason, radius),
+			}})
+			s.broadcast(ctx, order, state.round+1, entries)
+			s.webhooks.Send(order, WebhookDispatchEscalated, map[string]interface{}{
+				"escalatedTo": "radius",
+				"radiusKm":    radius,
+				"reason":      reason,
+			})
+			return nil
+		}
+	}
+
+	if err := s.orderRepo.EndBroadcast(ctx, order.ID); err != nil {
+		if errors.Is(err, repository.ErrOfferChanged) {
+			return ErrConcurrentStatusChange
+		}
+		return err
+	}
+	open := state.open(order)
+	order.DispatchStatus = models.DispatchStatusExhausted
+	order.OfferExpiresAt = nil
+
+	entries := []models.DispatchLogEntry{{Decision: models.DispatchDecisionEscalated, Reason: reason + "; handed to the store"}}
+	s.record(ctx, order, state.round, append(entries, s.withdrawAll(ctx, order, open, "nobody accepted it in time")...))
+
+	log.Printf("🧭 Broadcast of order %s handed to the store: %s", order.OrderNumber, reason)
+	s.webhooks.Send(order, WebhookDispatchEscalated, map[string]interface{}{
+		"escalatedTo": "store",
+		"reason":      reason,
+		"offers":      len(state.offered),
+	})
+	return nil
+}
+
+// withdrawCancelled tells the couriers still considering a broadcast that the order was cancelled
+func (s *DispatchService) withdrawCancelled(ctx context.Context, order *models.Order) {
+	history, err := s.dispatchRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		log.Printf("⚠️ Failed to load dispatch log of cancelled order %s: %v", order.OrderNumber, err)
+		return
+	}
+	state := dispatchHistory(history)
+	if entries := s.withdrawAll(ctx, order, state.open(order), "the order was cancelled"); len(entries) > 0 {
+		s.record(ctx, order, state.round, entries)
+	}
+}
+
+// broadcast offers the order to every eligible courier in entries and logs the round
+func (s *DispatchService) broadcast(ctx context.Context, order *models.Order, round int, entries []models.DispatchLogEntry) {
+	var offered []uuid.UUID
+	for i := range entries {
+		if entries[i].Decision == models.DispatchDecisionRanked {
+			entries[i].Decision = models.DispatchDecisionOffered
+			offered = append(offered, *entries[i].CourierID)
+		}
+	}
+	s.record(ctx, order, round, entries)
+
+	log.Printf("🧭 Order %s broadcast to %d couriers", order.OrderNumber, len(offered))
+	for _, courierID := range offered {
+		s.sendOffer(ctx, courierID, order)
+	}
+}
+
+// withdrawAll tells the given couriers their offer has ended and returns the matching log entries
+func (s *DispatchService) withdrawAll(ctx context.Context, order *models.Order, couriers map[uuid.UUID]bool, reason string) []models.DispatchLogEntry {
+	var entries []models.DispatchLogEntry
+	for courierID := range couriers {
+		id := courierID
+		s.withdraw(ctx, order, id, reason)
+		entries = append(entries, models.DispatchLogEntry{CourierID: &id, Decision: models.DispatchDecisionWithdrawn, Reason: reason})
+	}
+	return entries
+}
+
+// open returns the couriers a broadcast is still open to: offered it and not declined. Nobody is
+// left once the order has a courier or the broadcast has ended.
+func (state dispatchState) open(order *models.Order) map[uuid.UUID]bool {
+	open := map[uuid.UUID]bool{}
+	if order.DispatchMode != models.DispatchModeBroadcast || order.DispatchStatus != models.DispatchStatusOffered {
+		return open
+	}
+	for courierID := range state.offered {
+		if !state.declined[courierID] {
+			open[courierID] = true
+		}
+	}
+	return open
+}
@@ -0,0 +1,682 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+	"nyengo-deliveries/internal/utils"
+	"nyengo-deliveries/internal/websocket"
+	"nyengo-deliveries/pkg/validator"
+)
+
+// Store webhook events for automatically dispatched and broadcast orders
+const (
+	WebhookCourierAssigned   = "order.courier_assigned"
+	WebhookDispatchFailed    = "order.dispatch_failed"
+	WebhookDispatchEscalated = "order.dispatch_escalated"
+)
+
+var (
+	// ErrNoCourierAvailable is returned when no courier is eligible for an automatically dispatched order
+	ErrNoCourierAvailable = errors.New("no courier is available for this order")
+	// ErrOfferUnavailable is returned when a courier answers an offer that was taken by someone else or has closed
+	ErrOfferUnavailable = errors.New("this offer is no longer available")
+)
+
+// Score weights; each factor is between 0 and 1 and the score is scaled to 0-100
+const (
+	dispatchWeightDistance = 0.4
+	dispatchWeightRating   = 0.3
+	dispatchWeightLoad     = 0.3
+)
+
+const (
+	// dispatchPositionMaxAge is how old a courier's last location fix may be before it is ignored
+	dispatchPositionMaxAge = 30 * time.Minute
+	// dispatchUnknownFactor is used for distance and rating when the courier has no position or no reviews
+	dispatchUnknownFactor = 0.5
+)
+
+// vehiclePackageSizes lists the package sizes each known vehicle type can carry, for couriers
+// who have not registered their vehicles. Vehicle types not listed here are assumed to carry anything.
+var vehiclePackageSizes = map[string][]string{
+	"bicycle":    {"small"},
+	"motorcycle": {"small", "medium"},
+	"motorbike":  {"small", "medium"},
+	"car":        {"small", "medium", "large"},
+	"van":        {"small", "medium", "large"},
+	"truck":      {"small", "medium", "large"},
+}
+
+// DispatchService assigns orders to couriers automatically. Eligible couriers (serving the area,
+// with a vehicle able to carry the package, open at the pickup time, on shift, not overloaded and
+// close enough) are scored on distance from the pickup,
+// rating and current load; the order is offered to the best one, then to the next whenever an offer
+// is declined or times out. Broadcast orders are instead offered to every eligible courier at once
+// (see dispatch_broadcast.go). Every decision is written to the order's dispatch log.
+type DispatchService struct {
+	orders       *OrderService
+	orderRepo    *repository.OrderRepository
+	courierRepo  *repository.CourierRepository
+	dispatchRepo *repository.DispatchRepository
+	stateMachine *OrderStateMachine
+	tracking     *TrackingService
+	vehicles     *VehicleService
+	shifts       *ShiftService
+	hours        *OperatingHoursService
+	notification *NotificationService
+	webhooks     *StoreWebhookService
+	hub          *websocket.Hub
+	cfg          *config.Config
+}
+
+// NewDispatchService creates a new dispatch service and registers its transition hook
+func NewDispatchService(
+	orders *OrderService,
+	orderRepo *repository.OrderRepository,
+	courierRepo *repository.CourierRepository,
+	dispatchRepo *repository.DispatchRepository,
+	stateMachine *OrderStateMachine,
+	tracking *TrackingService,
+	vehicles *VehicleService,
+	shifts *ShiftService,
+	hours *OperatingHoursService,
+	notification *NotificationService,
+	webhooks *StoreWebhookService,
+	hub *websocket.Hub,
+	cfg *config.Config,
+) *DispatchService {
+	s := &DispatchService{
+		orders:       orders,
+		orderRepo:    orderRepo,
+		courierRepo:  courierRepo,
+		dispatchRepo: dispatchRepo,
+		stateMachine: stateMachine,
+		tracking:     tracking,
+		vehicles:     vehicles,
+		shifts:       shifts,
+		hours:        hours,
+		notification: notification,
+		webhooks:     webhooks,
+		hub:          hub,
+		cfg:          cfg,
+	}
+	stateMachine.OnTransition(s.onTransition)
+	return s
+}
+
+// CreateOrder creates an order for the best-scoring courier and offers it to them.
+// No order is created when no courier is eligible.
+func (s *DispatchService) CreateOrder(ctx context.Context, req *models.CreateOrderRequest, area string) (*models.Order, error) {
+	area = strings.TrimSpace(area)
+	entries, err := s.rankRequest(ctx, req, area, s.cfg.DispatchMaxRadiusKm)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.orders.Create(ctx, *entries[0].CourierID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.cfg.DispatchOfferTimeout)
+	if err := s.orderRepo.StartDispatch(ctx, order.ID, area, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to start dispatch: %w", err)
+	}
+	order.DispatchMode = models.DispatchModeAuto
+	order.DispatchStatus = models.DispatchStatusOffered
+	order.DispatchArea = area
+	order.OfferExpiresAt = &expiresAt
+
+	entries[0].Decision = models.DispatchDecisionOffered
+	s.record(ctx, order, 1, entries)
+	s.offer(ctx, order, entries[0])
+	return order, nil
+}
+
+// Accept handles a courier accepting an order. Broadcast orders go to the first courier to accept.
+func (s *DispatchService) Accept(ctx context.Context, courierID, orderID uuid.UUID) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.DispatchMode == models.DispatchModeBroadcast && order.CourierID == uuid.Nil {
+		return s.claimBroadcast(ctx, courierID, order)
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusAccepted, models.StatusActorCourier, "Order accepted")
+}
+
+// Decline handles a courier declining an order. Automatically dispatched orders move on to the next
+// candidate and only become declined once nobody is left; declining a broadcast only withdraws the
+// courier from it. Other orders are declined straight away.
+func (s *DispatchService) Decline(ctx context.Context, courierID, orderID uuid.UUID) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.DispatchMode == models.DispatchModeBroadcast && order.CourierID == uuid.Nil {
+		return s.declineBroadcast(ctx, courierID, order)
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	if order.DispatchMode != models.DispatchModeAuto || order.DispatchStatus != models.DispatchStatusOffered ||
+		order.Status != models.OrderStatusPending {
+		return s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusDeclined, models.StatusActorCourier, "Order declined")
+	}
+
+	if err := s.moveOn(ctx, order, models.DispatchDecisionDeclined, "Courier declined the offer"); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Log returns an order's dispatch state and audit log
+func (s *DispatchService) Log(ctx context.Context, orderID uuid.UUID) (*models.DispatchLog, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	entries, err := s.dispatchRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dispatch log: %w", err)
+	}
+
+	return &models.DispatchLog{
+		OrderID:        order.ID,
+		OrderNumber:    order.OrderNumber,
+		Status:         order.Status,
+		DispatchMode:   order.DispatchMode,
+		DispatchStatus: order.DispatchStatus,
+		CourierID:      order.CourierID,
+		OfferExpiresAt: order.OfferExpiresAt,
+		Entries:        entries,
+	}, nil
+}
+
+// Run moves expired offers on to the next candidate every interval until ctx is cancelled
+func (s *DispatchService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expireOffers(ctx, time.Now())
+		}
+	}
+}
+
+// expireOffers re-offers orders whose courier did not answer in time
+func (s *DispatchService) expireOffers(ctx context.Context, now time.Time) {
+	ids, err := s.orderRepo.ClaimExpiredOffers(ctx, now, pickupSchedulerBatch)
+	if err != nil {
+		log.Printf("⚠️ Failed to claim expired dispatch offers: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		order, err := s.orderRepo.GetByID(ctx, id)
+		if err != nil {
+			log.Printf("⚠️ Failed to load order %s for an expired offer: %v", id, err)
+			continue
+		}
+		if order.DispatchMode == models.DispatchModeBroadcast {
+			reason := fmt.Sprintf("Nobody accepted within %s", s.cfg.BroadcastWindow)
+			if err := s.escalate(ctx, order, reason); err != nil {
+				log.Printf("⚠️ Failed to escalate broadcast of order %s: %v", order.OrderNumber, err)
+			}
+			continue
+		}
+
+		previous := order.CourierID
+		reason := fmt.Sprintf("No answer within %s", s.cfg.DispatchOfferTimeout)
+		if err := s.moveOn(ctx, order, models.DispatchDecisionExpired, reason); err != nil {
+			log.Printf("⚠️ Failed to re-offer order %s: %v", order.OrderNumber, err)
+			continue
+		}
+		s.withdraw(ctx, order, previous, "the offer expired")
+	}
+}
+
+// moveOn ends the current courier's offer and offers the order to the best remaining candidate.
+// When nobody is left (or DISPATCH_MAX_OFFERS is reached) the order is declined and the store told.
+func (s *DispatchService) moveOn(ctx context.Context, order *models.Order, decision models.DispatchDecision, reason string) error {
+	history, err := s.dispatchRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load dispatch log: %w", err)
+	}
+	state := dispatchHistory(history)
+	round := state.round
+	previous := order.CourierID
+	ended := models.DispatchLogEntry{Round: round, CourierID: &previous, Decision: decision, Reason: reason}
+
+	var entries []models.DispatchLogEntry
+	noMatch := fmt.Sprintf("Gave up after %d offers", len(state.offered))
+	if len(state.offered) < s.cfg.DispatchMaxOffers {
+		if entries, _, err = s.rank(ctx, order, state.offered, s.cfg.DispatchMaxRadiusKm); err != nil {
+			return err
+		}
+		noMatch = "No eligible courier left to offer the order to"
+	}
+
+	if len(entries) > 0 && entries[0].Decision == models.DispatchDecisionRanked {
+		next := *entries[0].CourierID
+		expiresAt := time.Now().Add(s.cfg.DispatchOfferTimeout)
+		if err := s.orderRepo.ReassignOffer(ctx, order.ID, previous, next, expiresAt); err != nil {
+			if errors.Is(err, repository.ErrOfferChanged) {
+				return ErrConcurrentStatusChange
+			}
+			return err
+		}
+		order.CourierID = next
+		order.OfferExpiresAt = &expiresAt
+
+		entries[0].Decision = models.DispatchDecisionOffered
+		s.record(ctx, order, round, []models.DispatchLogEntry{ended})
+		s.record(ctx, order, round+1, entries)
+		s.offer(ctx, order, entries[0])
+		return nil
+	}
+
+	actor := models.StatusActorSystem
+	if decision == models.DispatchDecisionDeclined {
+		actor = models.StatusActorCourier
+	}
+	if _, err := s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusDeclined, actor, "No courier accepted the order"); err != nil {
+		return err
+	}
+	if err := s.orderRepo.SetDispatchStatus(ctx, order.ID, models.DispatchStatusExhausted); err != nil {
+		log.Printf("⚠️ Failed to mark dispatch of order %s exhausted: %v", order.OrderNumber, err)
+	}
+	order.DispatchStatus = models.DispatchStatusExhausted
+	order.OfferExpiresAt = nil
+
+	s.record(ctx, order, round, []models.DispatchLogEntry{ended})
+	s.record(ctx, order, round+1, append(entries, models.DispatchLogEntry{Decision: models.DispatchDecisionNoMatch, Reason: noMatch}))
+
+	log.Printf("🧭 Order %s could not be dispatched: %s", order.OrderNumber, noMatch)
+	s.webhooks.Send(order, WebhookDispatchFailed, map[string]interface{}{
+		"reason": noMatch,
+		"offers": len(state.offered),
+	})
+	return nil
+}
+
+// onTransition closes the dispatch of an order once its courier accepts, and withdraws a broadcast
+// that is cancelled before anyone accepts it
+func (s *DispatchService) onTransition(ctx context.Context, order *models.Order, change models.StatusChange) {
+	if change.Status == models.OrderStatusCancelled && order.CourierID == uuid.Nil {
+		s.withdrawCancelled(ctx, order)
+		return
+	}
+	if change.Status != models.OrderStatusAccepted || order.DispatchMode != models.DispatchModeAuto ||
+		order.DispatchStatus != models.DispatchStatusOffered {
+		return
+	}
+
+	if err := s.orderRepo.SetDispatchStatus(ctx, order.ID, models.DispatchStatusAssigned); err != nil {
+		log.Printf("⚠️ Failed to mark dispatch of order %s assigned: %v", order.OrderNumber, err)
+	}
+	order.DispatchStatus = models.DispatchStatusAssigned
+	order.OfferExpiresAt = nil
+
+	round := 1
+	if history, err := s.dispatchRepo.ListByOrder(ctx, order.ID); err == nil {
+		round = dispatchHistory(history).round
+	}
+	courierID := order.CourierID
+	s.record(ctx, order, round, []models.DispatchLogEntry{{CourierID: &courierID, Decision: models.DispatchDecisionAccepted}})
+
+	s.webhooks.Send(order, WebhookCourierAssigned, map[string]interface{}{
+		"courierId": courierID,
+		"round":     round,
+	})
+}
+
+// rankRequest ranks the couriers for an order that is about to be created. It fails with
+// ErrNoCourierAvailable unless at least one courier is eligible.
+func (s *DispatchService) rankRequest(ctx context.Context, req *models.CreateOrderRequest, area string, radiusKm float64) ([]models.DispatchLogEntry, error) {
+	if req.ScheduledPickup != nil && time.Until(*req.ScheduledPickup) > s.cfg.ScheduleActivationLead {
+		return nil, fmt.Errorf("%w: automatic dispatch is only available for pickups within %s", ErrInvalidOrder, s.cfg.ScheduleActivationLead)
+	}
+	if (req.PickupLatitude == 0 && req.PickupLongitude == 0) || !validator.ValidateCoordinates(req.PickupLatitude, req.PickupLongitude) {
+		return nil, fmt.Errorf("%w: pickup coordinates are required for automatic dispatch", ErrInvalidOrder)
+	}
+
+	probe := &models.Order{
+		PickupAddress: req.PickupAddress, PickupLatitude: req.PickupLatitude, PickupLongitude: req.PickupLongitude,
+		PackageSize: req.PackageSize, PackageWeight: req.PackageWeight, DispatchArea: area,
+		ScheduledPickup: req.ScheduledPickup,
+	}
+	entries, considered, err := s.rank(ctx, probe, nil, radiusKm)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 || entries[0].Decision != models.DispatchDecisionRanked {
+		for _, entry := range entries {
+			log.Printf("🧭 Courier %s skipped: %s", entry.CourierID, entry.Reason)
+		}
+		return nil, fmt.Errorf("%w: none of the %d active couriers can take it", ErrNoCourierAvailable, considered)
+	}
+	return entries, nil
+}
+
+// rank checks every active courier against the order, skipping those in exclude. Eligible couriers
+// come first as ranked entries, best score first, followed by the skipped ones. It also returns how
+// many couriers were considered.
+func (s *DispatchService) rank(ctx context.Context, order *models.Order, exclude map[uuid.UUID]bool, radiusKm float64) ([]models.DispatchLogEntry, int, error) {
+	couriers, err := s.courierRepo.ListDispatchCandidates(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load couriers: %w", err)
+	}
+	loads, err := s.orderRepo.CountOpenOrdersByCourier(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count open orders: %w", err)
+	}
+	positions, err := s.tracking.CourierPositions(ctx, dispatchPositionMaxAge)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load courier positions: %w", err)
+	}
+	fleets, err := s.vehicles.Fleets(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load vehicles: %w", err)
+	}
+	availabilities, err := s.shifts.Availabilities(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load shifts: %w", err)
+	}
+	openings, err := s.hours.Statuses(ctx, pickupTime(order))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var ranked, skipped []models.DispatchLogEntry
+	considered := 0
+	for i := range couriers {
+		courier := &couriers[i]
+		if exclude[courier.ID] {
+			continue
+		}
+		considered++
+
+		var distance *float64
+		if position, ok := positions[courier.ID]; ok {
+			km := math.Round(utils.Haversine(position.Latitude, position.Longitude, order.PickupLatitude, order.PickupLongitude)*100) / 100
+			distance = &km
+		}
+
+		entry := s.evaluate(courier, fleets[courier.ID], openings[courier.ID], availabilities[courier.ID], order, loads[courier.ID], distance, radiusKm)
+		if entry.Decision == models.DispatchDecisionRanked {
+			ranked = append(ranked, entry)
+		} else {
+			skipped = append(skipped, entry)
+		}
+	}
+
+	sortRanked(ranked)
+	return append(ranked, skipped...), considered, nil
+}
+
+// evaluate checks one courier against the order and scores them if they can take it, or records why
+// they were skipped
+func (s *DispatchService) evaluate(courier *models.Courier, fleet []models.Vehicle, opening *models.OpeningStatus, availability models.CourierAvailability, order *models.Order, load int, distance *float64, radiusKm float64) models.DispatchLogEntry {
+	courierID := courier.ID
+	entry := models.DispatchLogEntry{CourierID: &courierID, Decision: models.DispatchDecisionSkipped, DistanceKm: distance}
+	if reason := s.ineligible(courier, fleet, opening, availability, order, load, distance, radiusKm); reason != "" {
+		entry.Reason = reason
+		return entry
+	}
+
+	entry.Decision = models.DispatchDecisionRanked
+	entry.Factors = s.factors(courier, load, s.loadLimit(availability), distance, radiusKm)
+	score := math.Round(100*(dispatchWeightDistance*entry.Factors["distance"]+
+		dispatchWeightRating*entry.Factors["rating"]+
+		dispatchWeightLoad*entry.Factors["load"])*100) / 100
+	entry.Score = &score
+	return entry
+}
+
+// sortRanked orders ranked entries best score first
+func sortRanked(ranked []models.DispatchLogEntry) {
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if *ranked[i].Score != *ranked[j].Score {
+			return *ranked[i].Score > *ranked[j].Score
+		}
+		// Prefer a courier whose position is known, then the closer one
+		di, dj := ranked[i].DistanceKm, ranked[j].DistanceKm
+		if di == nil || dj == nil {
+			return di != nil
+		}
+		return *di < *dj
+	})
+}
+
+// ineligible returns why a courier cannot take the order, or "" if they can
+func (s *DispatchService) ineligible(courier *models.Courier, fleet []models.Vehicle, opening *models.OpeningStatus, availability models.CourierAvailability, order *models.Order, load int, distance *float64, radiusKm float64) string {
+	if !servesArea(courier.ServiceAreas, order) {
+		if order.DispatchArea != "" {
+			return fmt.Sprintf("Does not serve %s (serves %s)", order.DispatchArea, strings.Join(courier.ServiceAreas, ", "))
+		}
+		return fmt.Sprintf("Pickup address is outside its service areas (%s)", strings.Join(courier.ServiceAreas, ", "))
+	}
+	if reason := cargoMismatch(courier, fleet, order.PackageWeight, order.PackageSize, time.Now()); reason != "" {
+		return reason
+	}
+	if reason := closedReason(opening); reason != "" {
+		return reason
+	}
+
+	switch {
+	case !availability.Online:
+		return "Offline: nobody is on shift"
+	case availability.AvailableShifts == 0:
+		return fmt.Sprintf("Everyone on shift has been online for over %s", s.cfg.ShiftMaxDuration)
+	case load >= s.loadLimit(availability):
+		return fmt.Sprintf("Already has %d open orders (limit %d)", load, s.loadLimit(availability))
+	case distance != nil && *distance > radiusKm:
+		return fmt.Sprintf("%.1f km from the pickup, beyond the %.0f km dispatch radius", *distance, radiusKm)
+	}
+	return ""
+}
+
+// closedReason describes why a courier is closed at the pickup time, or "" if it is open (or its hours
+// are unknown)
+func closedReason(opening *models.OpeningStatus) string {
+	if opening == nil || opening.IsOpen {
+		return ""
+	}
+	reason := "Closed at the pickup time"
+	if opening.Holiday != "" {
+		reason = fmt.Sprintf("Closed for %s", opening.Holiday)
+	}
+	if opening.NextAvailablePickup != nil {
+		return fmt.Sprintf("%s; opens %s", reason, opening.NextAvailablePickup.Format("Mon 2 Jan 15:04 MST"))
+	}
+	return fmt.Sprintf("%s; not open in the next %d days", reason, openingLookaheadDays)
+}
+
+// pickupTime is when the order will be collected: its scheduled pickup, or now
+func pickupTime(order *models.Order) time.Time {
+	if order.ScheduledPickup != nil && order.ScheduledPickup.After(time.Now()) {
+		return *order.ScheduledPickup
+	}
+	return time.Now()
+}
+
+// loadLimit is how many open orders a courier may hold: the per-shift limit for each shift available
+// for new jobs
+func (s *DispatchService) loadLimit(availability models.CourierAvailability) int {
+	if availability.AvailableShifts < 1 {
+		return s.cfg.DispatchMaxActiveOrders
+	}
+	return s.cfg.DispatchMaxActiveOrders * availability.AvailableShifts
+}
+
+// factors scores an eligible courier on distance, rating and load, each between 0 and 1
+func (s *DispatchService) factors(courier *models.Courier, load, limit int, distance *float64, radiusKm float64) map[string]float64 {
+	factors := map[string]float64{
+		"distance": dispatchUnknownFactor,
+		"rating":   dispatchUnknownFactor,
+		"load":     1 - float64(load)/float64(limit),
+	}
+	if distance != nil && radiusKm > 0 {
+		factors["distance"] = 1 - *distance/radiusKm
+	}
+	if courier.TotalReviews > 0 {
+		factors["rating"] = courier.Rating / 5
+	}
+	for name, value := range factors {
+		factors[name] = math.Round(math.Max(0, math.Min(1, value))*1000) / 1000
+	}
+	return factors
+}
+
+// offer notifies the courier the order is now offered to
+func (s *DispatchService) offer(ctx context.Context, order *models.Order, entry models.DispatchLogEntry) {
+	log.Printf("🧭 Order %s offered to courier %s (score %.2f)", order.OrderNumber, order.CourierID, *entry.Score)
+	s.sendOffer(ctx, order.CourierID, order)
+}
+
+// sendOffer pushes an offer to the courier's open WebSocket connections and their notification channel
+func (s *DispatchService) sendOffer(ctx context.Context, courierID uuid.UUID, order *models.Order) {
+	s.push(courierID, "dispatch_offer", map[string]interface{}{
+		"orderId":         order.ID,
+		"orderNumber":     order.OrderNumber,
+		"dispatchMode":    order.DispatchMode,
+		"pickupAddress":   order.PickupAddress,
+		"deliveryAddress": order.DeliveryAddress,
+		"packageSize":     order.PackageSize,
+		"courierEarnings": order.CourierEarnings,
+		"offerExpiresAt":  order.OfferExpiresAt,
+	})
+	if err := s.notification.SendDispatchOffer(ctx, courierID.String(), order); err != nil {
+		log.Printf("⚠️ Failed to send dispatch offer for order %s: %v", order.OrderNumber, err)
+	}
+}
+
+// withdraw tells a courier that an order they were offered is no longer available
+func (s *DispatchService) withdraw(ctx context.Context, order *models.Order, courierID uuid.UUID, reason string) {
+	s.push(courierID, "offer_withdrawn", map[string]interface{}{
+		"orderId":     order.ID,
+		"orderNumber": order.OrderNumber,
+		"reason":      reason,
+	})
+	if err := s.notification.SendOfferWithdrawn(ctx, courierID.String(), order, reason); err != nil {
+		log.Printf("⚠️ Failed to withdraw offer of order %s from courier %s: %v", order.OrderNumber, courierID, err)
+	}
+}
+
+// push sends a message to the courier's WebSocket connections on this instance
+func (s *DispatchService) push(courierID uuid.UUID, messageType string, payload interface{}) {
+	if s.hub == nil {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	message, err := json.Marshal(websocket.WSMessage{Type: messageType, Payload: data})
+	if err != nil {
+		return
+	}
+	s.hub.SendToCourier(courierID, message)
+}
+
+// record appends entries to the order's dispatch log. A failure is logged rather than returned so
+// that the offer itself is not undone.
+func (s *DispatchService) record(ctx context.Context, order *models.Order, round int, entries []models.DispatchLogEntry) {
+	for i := range entries {
+		entries[i].OrderID = order.ID
+		entries[i].Round = round
+	}
+	if err := s.dispatchRepo.AddEntries(ctx, entries); err != nil {
+		log.Printf("⚠️ Failed to write dispatch log for order %s: %v", order.OrderNumber, err)
+	}
+}
+
+// dispatchState summarises an order's dispatch log
+type dispatchState struct {
+	round     int                // Latest round
+	offered   map[uuid.UUID]bool // Couriers the order was offered to
+	declined  map[uuid.UUID]bool // Couriers who declined it
+	escalated bool               // A broadcast has already been widened
+}
+
+func dispatchHistory(entries []models.DispatchLogEntry) dispatchState {
+	state := dispatchState{round: 1, offered: map[uuid.UUID]bool{}, declined: map[uuid.UUID]bool{}}
+	for _, entry := range entries {
+		if entry.Round > state.round {
+			state.round = entry.Round
+		}
+		switch {
+		case entry.Decision == models.DispatchDecisionEscalated:
+			state.escalated = true
+		case entry.CourierID == nil:
+		case entry.Decision == models.DispatchDecisionOffered:
+			state.offered[*entry.CourierID] = true
+		case entry.Decision == models.DispatchDecisionDeclined:
+			state.declined[*entry.CourierID] = true
+		}
+	}
+	return state
+}
+
+// servesArea reports whether a courier covers the order's dispatch area or, without one, whether the
+// pickup address names one of the courier's areas. Couriers without service areas serve everywhere.
+func servesArea(areas []string, order *models.Order) bool {
+	if len(areas) == 0 {
+		return true
+	}
+	address := strings.ToLower(order.PickupAddress)
+	for _, area := range areas {
+		area = strings.TrimSpace(area)
+		if area == "" {
+			continue
+		}
+		if order.DispatchArea != "" {
+			if strings.EqualFold(area, order.DispatchArea) {
+				return true
+			}
+		} else if strings.Contains(address, strings.ToLower(area)) {
+			return true
+		}
+	}
+	return false
+}
+
+// canCarry reports whether any of a courier's profile vehicle types suits the package size
+func canCarry(vehicleTypes []string, size string) bool {
+	if len(vehicleTypes) == 0 || size == "" {
+		return true
+	}
+	for _, vehicle := range vehicleTypes {
+		sizes, known := vehiclePackageSizes[strings.ToLower(strings.TrimSpace(vehicle))]
+		if !known {
+			return true
+		}
+		for _, s := range sizes {
+			if strings.EqualFold(s, size) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+)
+
+func newTestDispatchService() *DispatchService {
+	cfg := config.LoadConfig()
+	cfg.DispatchMaxActiveOrders = 5
+	return &DispatchService{cfg: cfg}
+}
+
+func km(distance float64) *float64 {
+	return &distance
+}
+
+func TestDispatchScoring(t *testing.T) {
+	onShift := models.CourierAvailability{Online: true, OnlineShifts: 1, AvailableShifts: 1}
+
+	tests := []struct {
+		name         string
+		courier      models.Courier
+		availability models.CourierAvailability
+		load         int
+		distance     *float64
+		factors      map[string]float64
+		score        float64
+	}{
+		{
+			name:         "close, well rated and lightly loaded",
+			courier:      models.Courier{Rating: 4.5, TotalReviews: 20},
+			availability: onShift,
+			load:         1,
+			distance:     km(2),
+			factors:      map[string]float64{"distance": 0.8, "rating": 0.9, "load": 0.8},
+			score:        83,
+		},
+		{
+			name:         "at the edge of the radius",
+			courier:      models.Courier{Rating: 4.5, TotalReviews: 20},
+			availability: onShift,
+			load:         1,
+			distance:     km(10),
+			factors:      map[string]float64{"distance": 0, "rating": 0.9, "load": 0.8},
+			score:        51,
+		},
+		{
+			name:         "unknown position and no reviews",
+			courier:      models.Courier{Rating: 5},
+			availability: onShift,
+			factors:      map[string]float64{"distance": 0.5, "rating": 0.5, "load": 1},
+			score:        65,
+		},
+		{
+			name:         "one order short of the limit",
+			courier:      models.Courier{Rating: 4.5, TotalReviews: 20},
+			availability: onShift,
+			load:         4,
+			distance:     km(2),
+			factors:      map[string]float64{"distance": 0.8, "rating": 0.9, "load": 0.2},
+			score:        65,
+		},
+		{
+			name:         "two available shifts double the load limit",
+			courier:      models.Courier{Rating: 4.5, TotalReviews: 20},
+			availability: models.CourierAvailability{Online: true, OnlineShifts: 2, AvailableShifts: 2},
+			load:         4,
+			distance:     km(2),
+			factors:      map[string]float64{"distance": 0.8, "rating": 0.9, "load": 0.6},
+			score:        77,
+		},
+		{
+			name:         "factors are rounded",
+			courier:      models.Courier{Rating: 4.1, TotalReviews: 3},
+			availability: onShift,
+			load:         1,
+			distance:     km(3.33),
+			factors:      map[string]float64{"distance": 0.667, "rating": 0.82, "load": 0.8},
+			score:        75.28,
+		},
+	}
+
+	s := newTestDispatchService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.courier.ID = uuid.New()
+			entry := s.evaluate(&tt.courier, nil, nil, tt.availability, &models.Order{}, tt.load, tt.distance, 10)
+			if entry.Decision != models.DispatchDecisionRanked {
+				t.Fatalf("evaluate() = %s (%s), want ranked", entry.Decision, entry.Reason)
+			}
+			for name, want := range tt.factors {
+				if got := entry.Factors[name]; got != want {
+					t.Errorf("factor %s = %v, want %v", name, got, want)
+				}
+			}
+			if *entry.Score != tt.score {
+				t.Errorf("score = %v, want %v", *entry.Score, tt.score)
+			}
+			if *entry.CourierID != tt.courier.ID {
+				t.Errorf("courier = %s, want %s", *entry.CourierID, tt.courier.ID)
+			}
+		})
+	}
+}
+
+func TestDispatchIneligible(t *testing.T) {
+	onShift := models.CourierAvailability{Online: true, OnlineShifts: 1, AvailableShifts: 1}
+
+	tests := []struct {
+		name         string
+		courier      models.Courier
+		opening      *models.OpeningStatus
+		availability models.CourierAvailability
+		load         int
+		distance     *float64
+		want         string
+	}{
+		{name: "eligible", availability: onShift, load: 4, distance: km(9.9)},
+		{name: "eligible with unknown hours and position", availability: onShift},
+		{name: "eligible on two shifts above a single shift's limit", availability: models.CourierAvailability{Online: true, OnlineShifts: 2, AvailableShifts: 2}, load: 9},
+		{name: "offline", availability: models.CourierAvailability{}, want: "Offline: nobody is on shift"},
+		{name: "on shift for too long", availability: models.CourierAvailability{Online: true, OnlineShifts: 1}, want: "Everyone on shift has been online for over"},
+		{name: "at capacity", availability: onShift, load: 5, want: "Already has 5 open orders (limit 5)"},
+		{name: "over capacity", availability: onShift, load: 7, want: "Already has 7 open orders (limit 5)"},
+		{name: "at capacity on two shifts", availability: models.CourierAvailability{Online: true, OnlineShifts: 2, AvailableShifts: 2}, load: 10, want: "(limit 10)"},
+		{name: "beyond the radius", availability: onShift, distance: km(10.5), want: "beyond the 10 km dispatch radius"},
+		{name: "closed", availability: onShift, opening: &models.OpeningStatus{}, want: "Closed at the pickup time"},
+		{name: "outside the service area", courier: models.Courier{ServiceAreas: []string{"Ndola"}}, availability: onShift, want: "outside its service areas (Ndola)"},
+		{name: "cannot carry the package", courier: models.Courier{VehicleTypes: []string{"bicycle"}}, availability: onShift, want: "No vehicle suited to a large package"},
+		{name: "offline and at capacity reports offline", availability: models.CourierAvailability{}, load: 5, want: "Offline"},
+	}
+
+	s := newTestDispatchService()
+	order := &models.Order{PickupAddress: "Cairo Road, Lusaka", PackageSize: "large"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.courier.ID = uuid.New()
+			entry := s.evaluate(&tt.courier, nil, tt.opening, tt.availability, order, tt.load, tt.distance, 10)
+			if tt.want == "" {
+				if entry.Decision != models.DispatchDecisionRanked {
+					t.Errorf("evaluate() = %s (%s), want ranked", entry.Decision, entry.Reason)
+				}
+				return
+			}
+			if entry.Decision != models.DispatchDecisionSkipped || entry.Score != nil || !strings.Contains(entry.Reason, tt.want) {
+				t.Errorf("evaluate() = %s (%s), want skipped for %q", entry.Decision, entry.Reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortRanked(t *testing.T) {
+	entry := func(name string, score float64, distance *float64) models.DispatchLogEntry {
+		return models.DispatchLogEntry{Reason: name, Score: &score, DistanceKm: distance}
+	}
+	ranked := []models.DispatchLogEntry{
+		entry("tied, position unknown", 70, nil),
+		entry("tied, far", 70, km(6)),
+		entry("best", 80, km(9)),
+		entry("tied, near", 70, km(2)),
+		entry("worst", 40, km(1)),
+		entry("tied, position also unknown", 70, nil),
+	}
+
+	sortRanked(ranked)
+	want := []string{"best", "tied, near", "tied, far", "tied, position unknown", "tied, position also unknown", "worst"}
+	for i, name := range want {
+		if ranked[i].Reason != name {
+			t.Errorf("ranked[%d] = %q, want %q", i, ranked[i].Reason, name)
+		}
+	}
+}
+
+func TestDispatchRanking(t *testing.T) {
+	s := newTestDispatchService()
+	onShift := models.CourierAvailability{Online: true, OnlineShifts: 1, AvailableShifts: 1}
+	candidates := []struct {
+		name     string
+		load     int
+		distance *float64
+	}{
+		{"far and idle", 0, km(6)},
+		{"near and busy", 4, km(1)},
+		{"near and idle", 0, km(1)},
+		{"full", 5, km(0.5)},
+	}
+
+	var ranked, skipped []models.DispatchLogEntry
+	names := map[uuid.UUID]string{}
+	for _, c := range candidates {
+		courier := models.Courier{ID: uuid.New(), Rating: 4, TotalReviews: 10}
+		names[courier.ID] = c.name
+		entry := s.evaluate(&courier, nil, nil, onShift, &models.Order{}, c.load, c.distance, 10)
+		if entry.Decision == models.DispatchDecisionRanked {
+			ranked = append(ranked, entry)
+		} else {
+			skipped = append(skipped, entry)
+		}
+	}
+	sortRanked(ranked)
+
+	want := []string{"near and idle", "far and idle", "near and busy"}
+	if len(ranked) != len(want) || len(skipped) != 1 || names[*skipped[0].CourierID] != "full" {
+		t.Fatalf("ranked %d and skipped %d couriers, want %d ranked and the full one skipped", len(ranked), len(skipped), len(want))
+	}
+	for i, name := range want {
+		if got := names[*ranked[i].CourierID]; got != name {
+			t.Errorf("ranked[%d] = %q (score %v), want %q", i, got, *ranked[i].Score, name)
+		}
+	}
+}
@@ -0,0 +1,553 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+var (
+	ErrDriverNotFound           = errors.New("driver not found")
+	ErrInvalidDriver            = errors.New("invalid driver")
+	ErrDriverPhoneTaken         = errors.New("phone number is already registered to a driver")
+	ErrDriverNotActive          = errors.New("driver is not active")
+	ErrInviteInvalid            = errors.New("invite code is invalid or has expired")
+	ErrInviteLocked             = errors.New("too many incorrect invite codes")
+	ErrInvalidDriverCredentials = errors.New("invalid credentials")
+	ErrOrderNotAssignable       = errors.New("orders can only be assigned to a driver after acceptance and before delivery")
+	ErrNotAssignedDriver        = errors.New("order is assigned to another driver")
+	ErrNoDriverAssignment       = errors.New("order is not assigned to a driver")
+	ErrDriverAssignmentClosed   = errors.New("assignment is no longer waiting for the driver")
+)
+
+// driverInviteCodeLength is the number of digits in a driver's invite code
+const driverInviteCodeLength = 8
+
+// assignableStatuses are the statuses in which a courier holds an order and can give it to a driver
+var assignableStatuses = []models.OrderStatus{
+	models.OrderStatusAccepted, models.OrderStatusPickedUp, models.OrderStatusInTransit,
+	models.OrderStatusReattemptScheduled,
+}
+
+// DriverService manages a courier company's drivers: registration and invites, driver sign-in
+// with tokens scoped to the driver, order assignment, per-driver reporting, and which account
+// may drive an order's live tracking
+type DriverService struct {
+	repo         *repository.DriverRepository
+	orderRepo    *repository.OrderRepository
+	orders       *OrderService
+	tracking     *TrackingService
+	notification *NotificationService
+	cfg          *config.Config
+}
+
+// NewDriverService creates a new driver service
+func NewDriverService(
+	repo *repository.DriverRepository,
+	orderRepo *repository.OrderRepository,
+	orders *OrderService,
+	tracking *TrackingService,
+	notification *NotificationService,
+	cfg *config.Config,
+) *DriverService {
+	return &DriverService{
+		repo:         repo,
+		orderRepo:    orderRepo,
+		orders:       orders,
+		tracking:     tracking,
+		notification: notification,
+		cfg:          cfg,
+	}
+}
+
+// Register adds a driver to the courier company and returns their first invite code
+func (s *DriverService) Register(ctx context.Context, courierID uuid.UUID, req *models.DriverRequest) (*models.DriverInvite, error) {
+	driver := &models.Driver{
+		CourierID:     courierID,
+		Name:          strings.TrimSpace(req.Name),
+		Phone:         strings.TrimSpace(req.Phone),
+		Email:         strings.TrimSpace(req.Email),
+		LicenseNumber: strings.TrimSpace(req.LicenseNumber),
+		PhotoURL:      req.PhotoURL,
+		VehicleType:   req.VehicleType,
+		VehiclePlate:  strings.ToUpper(strings.TrimSpace(req.VehiclePlate)),
+		Status:        models.DriverStatusInvited,
+	}
+	if driver.Name == "" || driver.Phone == "" {
+		return nil, fmt.Errorf("%w: name and phone are required", ErrInvalidDriver)
+	}
+	expiry, err := parseLicenseExpiry(req.LicenseExpiry)
+	if err != nil {
+		return nil, err
+	}
+	driver.LicenseExpiry = expiry
+
+	code, hash, expiresAt, err := s.newInvite()
+	if err != nil {
+		return nil, err
+	}
+	driver.InviteCodeHash = hash
+	driver.InviteExpiresAt = &expiresAt
+
+	if err := s.repo.Create(ctx, driver); err != nil {
+		if errors.Is(err, repository.ErrDriverPhoneTaken) {
+			return nil, ErrDriverPhoneTaken
+		}
+		return nil, fmt.Errorf("failed to register driver: %w", err)
+	}
+
+	log.Printf("🪪 Driver %s registered for courier %s", driver.ID, courierID)
+	return &models.DriverInvite{Driver: driver, InviteCode: code, ExpiresAt: expiresAt}, nil
+}
+
+// Invite issues a new invite code for the driver, replacing any earlier one. Active drivers can
+// use it to choose a new password.
+func (s *DriverService) Invite(ctx context.Context, courierID, driverID uuid.UUID) (*models.DriverInvite, error) {
+	driver, err := s.Get(ctx, courierID, driverID)
+	if err != nil {
+		return nil, err
+	}
+	if driver.Status == models.DriverStatusSuspended {
+		return nil, ErrDriverNotActive
+	}
+
+	code, hash, expiresAt, err := s.newInvite()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetInvite(ctx, driver.ID, hash, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to save invite: %w", err)
+	}
+	driver.InviteExpiresAt = &expiresAt
+	return &models.DriverInvite{Driver: driver, InviteCode: code, ExpiresAt: expiresAt}, nil
+}
+
+// List returns the courier's drivers, optionally only those with the status
+func (s *DriverService) List(ctx context.Context, courierID uuid.UUID, status string) ([]models.Driver, error) {
+	return s.repo.ListByCourier(ctx, courierID, status)
+}
+
+// Get returns one of the courier's drivers
+func (s *DriverService) Get(ctx context.Context, courierID, driverID uuid.UUID) (*models.Driver, error) {
+	driver, err := s.repo.GetByID(ctx, driverID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDriverNotFound) {
+			return nil, ErrDriverNotFound
+		}
+		return nil, err
+	}
+	if driver.CourierID != courierID {
+		return nil, ErrDriverNotFound
+	}
+	return driver, nil
+}
+
+// Update changes a driver's details, or suspends and reinstates them
+func (s *DriverService) Update(ctx context.Context, courierID, driverID uuid.UUID, req *models.DriverUpdateRequest) (*models.Driver, error) {
+	driver, err := s.Get(ctx, courierID, driverID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		driver.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Phone != nil {
+		driver.Phone = strings.TrimSpace(*req.Phone)
+	}
+	if driver.Name == "" || driver.Phone == "" {
+		return nil, fmt.Errorf("%w: name and phone cannot be empty", ErrInvalidDriver)
+	}
+	if req.Email != nil {
+		driver.Email = strings.TrimSpace(*req.Email)
+	}
+	if req.LicenseNumber != nil {
+		driver.LicenseNumber = strings.TrimSpace(*req.LicenseNumber)
+	}
+	if req.LicenseExpiry != nil {
+		if driver.LicenseExpiry, err = parseLicenseExpiry(*req.LicenseExpiry); err != nil {
+			return nil, err
+		}
+	}
+	if req.PhotoURL != nil {
+		driver.PhotoURL = *req.PhotoURL
+	}
+	if req.VehicleType != nil {
+		driver.VehicleType = *req.VehicleType
+	}
+	if req.VehiclePlate != nil {
+		driver.VehiclePlate = strings.ToUpper(strings.TrimSpace(*req.VehiclePlate))
+	}
+	if req.Status != nil {
+		switch *req.Status {
+		case models.DriverStatusSuspended:
+			driver.Status = models.DriverStatusSuspended
+		case models.DriverStatusActive:
+			// Reinstated drivers who never accepted their invite go back to waiting for it
+			driver.Status = models.DriverStatusActive
+			if driver.Password == "" {
+				driver.Status = models.DriverStatusInvited
+			}
+		default:
+			return nil, fmt.Errorf("%w: status must be active or suspended", ErrInvalidDriver)
+		}
+	}
+
+	if err := s.repo.Update(ctx, driver); err != nil {
+		if errors.Is(err, repository.ErrDriverPhoneTaken) {
+			return nil, ErrDriverPhoneTaken
+		}
+		return nil, fmt.Errorf("failed to update driver: %w", err)
+	}
+	return driver, nil
+}
+
+// Activate accepts a driver's invite, sets their password and signs them in. Wrong codes are
+// counted per phone number, and activation is locked for a while after too many.
+func (s *DriverService) Activate(ctx context.Context, req *models.DriverActivateRequest) (*models.DriverLoginResponse, error) {
+	if len(req.Password) < 8 {
+		return nil, fmt.Errorf("%w: password must be at least 8 characters", ErrInvalidDriver)
+	}
+	phone := strings.TrimSpace(req.Phone)
+
+	lockedUntil, err := s.repo.ClaimInviteAttempt(ctx, phone, s.cfg.DriverInviteMaxAttempts, s.cfg.DriverInviteLockout)
+	switch {
+	case errors.Is(err, repository.ErrInviteInvalid):
+		return nil, ErrInviteInvalid
+	case errors.Is(err, repository.ErrInviteLocked):
+		log.Printf("🔒 Driver activation attempt for locked phone %s", phone)
+		return nil, inviteLockedError(lockedUntil)
+	case err != nil:
+		return nil, fmt.Errorf("failed to record activation attempt: %w", err)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := s.repo.Activate(ctx, phone, hashInviteCode(strings.TrimSpace(req.InviteCode)), string(hashedPassword))
+	if err != nil {
+		if errors.Is(err, repository.ErrInviteInvalid) {
+			log.Printf("⚠️ Wrong or expired invite code for driver phone %s", phone)
+			if lockedUntil != nil {
+				return nil, inviteLockedError(lockedUntil)
+			}
+			return nil, ErrInviteInvalid
+		}
+		return nil, err
+	}
+
+	log.Printf("🪪 Driver %s activated", driver.ID)
+	return s.signIn(driver)
+}
+
+// Login authenticates a driver and returns a token scoped to them
+func (s *DriverService) Login(ctx context.Context, req *models.DriverLoginRequest) (*models.DriverLoginResponse, error) {
+	driver, err := s.repo.GetByPhone(ctx, strings.TrimSpace(req.Phone))
+	if err != nil || driver.Password == "" {
+		return nil, ErrInvalidDriverCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(driver.Password), []byte(req.Password)); err != nil {
+		return nil, ErrInvalidDriverCredentials
+	}
+	if driver.Status != models.DriverStatusActive {
+		return nil, ErrDriverNotActive
+	}
+
+	_ = s.repo.UpdateLastLogin(ctx, driver.ID)
+	return s.signIn(driver)
+}
+
+// AssignOrder gives one of the courier's orders to one of their active drivers, taking it off the
+// driver who had it. A trip being tracked carries on under the new driver.
+func (s *DriverService) AssignOrder(ctx context.Context, courierID, orderID uuid.UUID, req *models.AssignDriverRequest) (*models.DriverAssignment, error) {
+	order, err := s.courierOrder(ctx, courierID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAssignable(order.Status) {
+		return nil, ErrOrderNotAssignable
+	}
+	if req.DriverID == uuid.Nil {
+		return nil, fmt.Errorf("%w: driverId is required", ErrInvalidDriver)
+	}
+	driver, err := s.Get(ctx, courierID, req.DriverID)
+	if err != nil {
+		return nil, err
+	}
+	if driver.Status != models.DriverStatusActive {
+		return nil, ErrDriverNotActive
+	}
+
+	assignment := &models.DriverAssignment{
+		OrderID:      order.ID,
+		DriverID:     driver.ID,
+		DriverName:   driver.Name,
+		DriverPhone:  driver.Phone,
+		VehicleType:  driver.VehicleType,
+		VehiclePlate: driver.VehiclePlate,
+	}
+	previous, err := s.repo.Assign(ctx, assignment)
+	if err != nil {
+		if errors.Is(err, repository.ErrDriverAssignmentChanged) {
+			return nil, ErrConcurrentStatusChange
+		}
+		return nil, fmt.Errorf("failed to assign driver: %w", err)
+	}
+
+	log.Printf("🪪 Order %s assigned to driver %s", order.OrderNumber, driver.ID)
+	if previous != nil && previous.DriverID != driver.ID {
+		if err := s.notification.SendDriverUnassigned(ctx, previous.DriverID.String(), order); err != nil {
+			log.Printf("⚠️ Failed to notify driver %s of unassignment: %v", previous.DriverID, err)
+		}
+	}
+	if err := s.notification.SendDriverAssignment(ctx, driver.ID.String(), order); err != nil {
+		log.Printf("⚠️ Failed to notify driver %s of order %s: %v", driver.ID, order.OrderNumber, err)
+	}
+	if err := s.tracking.TransferDelivery(ctx, order, assignmentDriverInfo(assignment)); err != nil {
+		log.Printf("⚠️ Failed to move tracking of order %s to driver %s: %v", order.OrderNumber, driver.ID, err)
+	}
+	return assignment, nil
+}
+
+// UnassignOrder takes an order off its driver, so the courier account works it again
+func (s *DriverService) UnassignOrder(ctx context.Context, courierID, orderID uuid.UUID) (*models.DriverAssignment, error) {
+	order, err := s.courierOrder(ctx, courierID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment, err := s.repo.Release(ctx, order.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoDriverAssignment) {
+			return nil, ErrNoDriverAssignment
+		}
+		return nil, fmt.Errorf("failed to unassign driver: %w", err)
+	}
+
+	log.Printf("🪪 Order %s unassigned from driver %s", order.OrderNumber, assignment.DriverID)
+	if err := s.notification.SendDriverUnassigned(ctx, assignment.DriverID.String(), order); err != nil {
+		log.Printf("⚠️ Failed to notify driver %s of unassignment: %v", assignment.DriverID, err)
+	}
+	return assignment, nil
+}
+
+// RespondAssignment records a driver accepting or rejecting an order assigned to them. A rejected
+// order goes back to the courier company to assign again.
+func (s *DriverService) RespondAssignment(ctx context.Context, courierID, driverID, orderID uuid.UUID, accept bool, note string) (*models.DriverAssignment, error) {
+	if _, err := s.activeDriver(ctx, courierID, driverID); err != nil {
+		return nil, err
+	}
+	order, err := s.courierOrder(ctx, courierID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := models.DriverAssignmentRejected
+	if accept {
+		status = models.DriverAssignmentAccepted
+	}
+	assignment, err := s.repo.RespondAssignment(ctx, order.ID, driverID, status, strings.TrimSpace(note))
+	if err != nil {
+		if errors.Is(err, repository.ErrDriverAssignmentClosed) {
+			return nil, ErrDriverAssignmentClosed
+		}
+		return nil, err
+	}
+
+	log.Printf("🪪 Driver %s %s order %s", driverID, status, order.OrderNumber)
+	if err := s.notification.SendDriverAssignmentUpdate(ctx, courierID.String(), order, assignment); err != nil {
+		log.Printf("⚠️ Failed to notify courier %s of driver answer: %v", courierID, err)
+	}
+	return assignment, nil
+}
+
+// Assignments returns a driver's assignments, newest first, optionally only those with the status
+func (s *DriverService) Assignments(ctx context.Context, courierID, driverID uuid.UUID, status string) ([]models.DriverAssignment, error) {
+	if _, err := s.Get(ctx, courierID, driverID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListAssignments(ctx, driverID, status)
+}
+
+// Orders returns a page of the orders assigned to one of the courier's drivers
+func (s *DriverService) Orders(ctx context.Context, courierID, driverID uuid.UUID, filters *models.OrderListFilters) (*models.OrderListResponse, error) {
+	if _, err := s.Get(ctx, courierID, driverID); err != nil {
+		return nil, err
+	}
+	filters.CourierID = &courierID
+	filters.DriverID = &driverID
+	return s.orders.Query(ctx, filters)
+}
+
+// Earnings sums the courier earnings of the orders a driver delivered between from and to
+func (s *DriverService) Earnings(ctx context.Context, courierID, driverID uuid.UUID, from, to *time.Time) (*models.DriverEarnings, error) {
+	if _, err := s.Get(ctx, courierID, driverID); err != nil {
+		return nil, err
+	}
+	earnings, err := s.repo.GetEarnings(ctx, driverID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	earnings.Earnings = roundMoney(earnings.Earnings)
+	earnings.Currency = s.cfg.Currency
+	return earnings, nil
+}
+
+// Performance returns a driver's delivery metrics over the orders assigned to them
+func (s *DriverService) Performance(ctx context.Context, courierID, driverID uuid.UUID) (*models.DriverPerformance, error) {
+	if _, err := s.Get(ctx, courierID, driverID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetPerformance(ctx, driverID)
+}
+
+// AuthorizeLocation checks that the account may send live locations for an order over the WebSocket,
+// under the same rules as the tracking endpoints
+func (s *DriverService) AuthorizeLocation(ctx context.Context, courierID, driverID, orderID uuid.UUID) error {
+	_, err := s.AuthorizeTracking(ctx, courierID, driverID, orderID)
+	return err
+}
+
+// AuthorizeTracking checks that the account may start, update or stop an order's live tracking.
+// The order must belong to the courier. Once it is assigned to a driver, only that driver may,
+// and their first tracking call accepts a pending assignment; orders without a driver are tracked
+// by the courier account as before. The assigned driver's details are returned, or nil for the
+// courier account.
+func (s *DriverService) AuthorizeTracking(ctx context.Context, courierID, driverID, orderID uuid.UUID) (*DriverInfo, error) {
+	order, err := s.courierOrder(ctx, courierID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment, err := s.repo.GetActiveAssignment(ctx, order.ID)
+	if err != nil && !errors.Is(err, repository.ErrNoDriverAssignment) {
+		return nil, err
+	}
+	if driverID == uuid.Nil {
+		if assignment != nil {
+			return nil, ErrNotAssignedDriver
+		}
+		return nil, nil
+	}
+
+	if _, err := s.activeDriver(ctx, courierID, driverID); err != nil {
+		return nil, err
+	}
+	if assignment == nil || assignment.DriverID != driverID {
+		return nil, ErrNotAssignedDriver
+	}
+	if assignment.Status == models.DriverAssignmentPending {
+		if _, err := s.repo.RespondAssignment(ctx, order.ID, driverID, models.DriverAssignmentAccepted, ""); err != nil {
+			log.Printf("⚠️ Failed to accept assignment of order %s for driver %s: %v", order.OrderNumber, driverID, err)
+		}
+	}
+	return assignmentDriverInfo(assignment), nil
+}
+
+func (s *DriverService) courierOrder(ctx context.Context, courierID, orderID uuid.UUID) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return order, nil
+}
+
+// activeDriver loads the signed-in driver, so suspensions apply before their token expires
+func (s *DriverService) activeDriver(ctx context.Context, courierID, driverID uuid.UUID) (*models.Driver, error) {
+	driver, err := s.Get(ctx, courierID, driverID)
+	if err != nil {
+		return nil, err
+	}
+	if driver.Status != models.DriverStatusActive {
+		return nil, ErrDriverNotActive
+	}
+	return driver, nil
+}
+
+// inviteLockedError says until when activation is locked
+func inviteLockedError(until *time.Time) error {
+	if until == nil {
+		return ErrInviteLocked
+	}
+	return fmt.Errorf("%w, try again after %s", ErrInviteLocked, until.Format(time.Kitchen))
+}
+
+// newInvite returns a random invite code, the hash that is stored and when it expires
+func (s *DriverService) newInvite() (string, string, time.Time, error) {
+	code, err := generatePIN(driverInviteCodeLength)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return code, hashInviteCode(code), time.Now().Add(s.cfg.DriverInviteTTL), nil
+}
+
+// signIn issues a driver's token. It carries the company's courier_id for ownership checks and
+// the driver scope, which courier-only routes reject.
+func (s *DriverService) signIn(driver *models.Driver) (*models.DriverLoginResponse, error) {
+	claims := jwt.MapClaims{
+		"courier_id": driver.CourierID.String(),
+		"driver_id":  driver.ID.String(),
+		"scope":      models.DriverTokenScope,
+		"exp":        time.Now().Add(s.cfg.JWTExpiration).Unix(),
+		"iat":        time.Now().Unix(),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+	return &models.DriverLoginResponse{Token: token, Driver: driver}, nil
+}
+
+func hashInviteCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseLicenseExpiry(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	expiry, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: licenseExpiry must be a YYYY-MM-DD date", ErrInvalidDriver)
+	}
+	return &expiry, nil
+}
+
+func assignmentDriverInfo(assignment *models.DriverAssignment) *DriverInfo {
+	return &DriverInfo{
+		Name:         assignment.DriverName,
+		Phone:        assignment.DriverPhone,
+		VehicleType:  assignment.VehicleType,
+		VehiclePlate: assignment.VehiclePlate,
+	}
+}
+
+func isAssignable(status models.OrderStatus) bool {
+	for _, s := range assignableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
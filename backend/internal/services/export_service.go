@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/export"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// ErrInvalidExport is returned when an export request fails validation
+var ErrInvalidExport = errors.New("invalid export")
+
+// Export is a prepared export. Its rows are only read from the database while it is written,
+// so it can be streamed straight into the response.
+type Export struct {
+	Filename string
+	Format   export.Format
+	write    func(ctx context.Context, w io.Writer) error
+}
+
+// Write streams the export to w
+func (e *Export) Write(ctx context.Context, w io.Writer) error {
+	return e.write(ctx, w)
+}
+
+// ExportService exports order history, wallet transactions and payouts for bookkeeping
+type ExportService struct {
+	orderRepo   *repository.OrderRepository
+	paymentRepo *repository.PaymentRepository
+	courierRepo *repository.CourierRepository
+	cfg         *config.Config
+}
+
+// NewExportService creates a new export service
+func NewExportService(
+	orderRepo *repository.OrderRepository,
+	paymentRepo *repository.PaymentRepository,
+	courierRepo *repository.CourierRepository,
+	cfg *config.Config,
+) *ExportService {
+	return &ExportService{
+		orderRepo:   orderRepo,
+		paymentRepo: paymentRepo,
+		courierRepo: courierRepo,
+		cfg:         cfg,
+	}
+}
+
+var orderExportColumns = []export.Column{
+	{Title: "Order Number", Kind: export.KindText, Width: 10},
+	{Title: "Store Reference", Kind: export.KindText, Width: 9},
+	{Title: "Created", Kind: export.KindTime, Width: 10},
+	{Title: "Delivered", Kind: export.KindTime, Width: 10},
+	{Title: "Status", Kind: export.KindText, Width: 8},
+	{Title: "Customer", Kind: export.KindText, Width: 11},
+	{Title: "Pickup Address", Kind: export.KindText, Width: 16},
+	{Title: "Delivery Address", Kind: export.KindText, Width: 16},
+	{Title: "Distance (km)", Kind: export.KindNumber, Width: 7, Total: true},
+	{Title: "Payment", Kind: export.KindText, Width: 9},
+	{Title: "Total Fare", Kind: export.KindMoney, Width: 9, Total: true},
+	{Title: "Platform Fee", Kind: export.KindMoney, Width: 9, Total: true},
+	{Title: "Earnings", Kind: export.KindMoney, Width: 9, Total: true},
+	{Title: "Cash Collected", Kind: export.KindMoney, Width: 9, Total: true},
+}
+
+var walletExportColumns = []export.Column{
+	{Title: "Date", Kind: export.KindTime, Width: 10},
+	{Title: "Type", Kind: export.KindText, Width: 7},
+	{Title: "Description", Kind: export.KindText, Width: 28},
+	{Title: "Reference", Kind: export.KindText, Width: 12},
+	{Title: "Order ID", Kind: export.KindText, Width: 12},
+	{Title: "Amount", Kind: export.KindMoney, Width: 9, Total: true},
+	{Title: "Balance", Kind: export.KindMoney, Width: 9},
+}
+
+var payoutExportColumns = []export.Column{
+	{Title: "Requested", Kind: export.KindTime, Width: 10},
+	{Title: "Payout ID", Kind: export.KindText, Width: 12},
+	{Title: "Method", Kind: export.KindText, Width: 9},
+	{Title: "Status", Kind: export.KindText, Width: 8},
+	{Title: "Transaction Ref", Kind: export.KindText, Width: 11},
+	{Title: "Orders", Kind: export.KindNumber, Width: 5, Total: true},
+	{Title: "Gross", Kind: export.KindMoney, Width: 9, Total: true},
+	{Title: "Platform Fee", Kind: export.KindMoney, Width: 9, Total: true},
+	{Title: "Net", Kind: export.KindMoney, Width: 9, Total: true},
+	{Title: "Completed", Kind: export.KindTime, Width: 10},
+	{Title: "Failure Reason", Kind: export.KindText, Width: 14},
+}
+
+// Orders prepares an export of the courier's orders matching the filters, in the filters' sort order.
+// Paging fields are ignored: every matching order is exported.
+func (s *ExportService) Orders(ctx context.Context, courierID uuid.UUID, filters *models.OrderListFilters, format export.Format) (*Export, error) {
+	filters.CourierID = &courierID
+	if err := validateOrderFilters(filters); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(ctx, courierID, "Order History", filters.DateFrom, filters.DateTo)
+	if err != nil {
+		return nil, err
+	}
+	doc.Columns = orderExportColumns
+	if len(filters.Status) > 0 {
+		statuses := make([]string, len(filters.Status))
+		for i, status := range filters.Status {
+			statuses[i] = string(status)
+		}
+		doc.Details = append(doc.Details, "Status: "+strings.Join(statuses, ", "))
+	}
+
+	return &Export{
+		Filename: exportFilename("orders", format),
+		Format:   format,
+		write: func(ctx context.Context, w io.Writer) error {
+			return s.stream(w, format, doc, func(out export.Writer) error {
+				return s.orderRepo.StreamForExport(ctx, filters, func(o *models.Order) error {
+					return out.WriteRow(
+						o.OrderNumber, o.ExternalOrderID, o.CreatedAt, o.ActualDelivery, string(o.Status),
+						o.CustomerName, o.PickupAddress, o.DeliveryAddress, o.Distance,
+						fmt.Sprintf("%s / %s", o.PaymentMethod, o.PaymentStatus),
+						o.TotalFare, o.PlatformFee, o.CourierEarnings, o.CODCollected,
+					)
+				})
+			})
+		},
+	}, nil
+}
+
+// WalletTransactions prepares an export of the courier's wallet transactions, oldest first
+func (s *ExportService) WalletTransactions(ctx context.Context, courierID uuid.UUID, from, to *time.Time, format export.Format) (*Export, error) {
+	if err := validateExportPeriod(from, to); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(ctx, courierID, "Wallet Statement", from, to)
+	if err != nil {
+		return nil, err
+	}
+	doc.Columns = walletExportColumns
+
+	return &Export{
+		Filename: exportFilename("wallet-transactions", format),
+		Format:   format,
+		write: func(ctx context.Context, w io.Writer) error {
+			return s.stream(w, format, doc, func(out export.Writer) error {
+				return s.paymentRepo.StreamWalletTransactions(ctx, courierID, from, to, func(tx *models.WalletTransaction) error {
+					orderID := ""
+					if tx.OrderID != nil {
+						orderID = tx.OrderID.String()
+					}
+					return out.WriteRow(tx.CreatedAt, tx.Type, tx.Description, tx.Reference, orderID, tx.Amount, tx.BalanceAfter)
+				})
+			})
+		},
+	}, nil
+}
+
+// Payouts prepares an export of the courier's payouts, oldest first, optionally filtered by status
+func (s *ExportService) Payouts(ctx context.Context, courierID uuid.UUID, status string, from, to *time.Time, format export.Format) (*Export, error) {
+	if err := validateExportPeriod(from, to); err != nil {
+		return nil, err
+	}
+	switch models.PayoutStatus(status) {
+	case "", models.PayoutStatusPending, models.PayoutStatusProcessing, models.PayoutStatusCompleted, models.PayoutStatusFailed, models.PayoutStatusCancelled:
+	default:
+		return nil, fmt.Errorf("%w: unknown payout status %q", ErrInvalidExport, status)
+	}
+	doc, err := s.document(ctx, courierID, "Payout Statement", from, to)
+	if err != nil {
+		return nil, err
+	}
+	doc.Columns = payoutExportColumns
+	if status != "" {
+		doc.Details = append(doc.Details, "Status: "+status)
+	}
+
+	return &Export{
+		Filename: exportFilename("payouts", format),
+		Format:   format,
+		write: func(ctx context.Context, w io.Writer) error {
+			return s.stream(w, format, doc, func(out export.Writer) error {
+				return s.paymentRepo.StreamPayouts(ctx, courierID, status, from, to, func(p *models.Payout) error {
+					return out.WriteRow(
+						p.CreatedAt, p.ID.String(), string(p.PayoutMethod), string(p.Status), p.TransactionRef,
+						len(p.OrderIDs), p.TotalAmount, p.PlatformFee, p.NetAmount, p.CompletedAt, p.FailureReason,
+					)
+				})
+			})
+		},
+	}, nil
+}
+
+// stream writes the document heading, the rows produced by rows and the closing totals
+func (s *ExportService) stream(w io.Writer, format export.Format, doc export.Document, rows func(export.Writer) error) error {
+	out, err := export.NewWriter(format, w, doc)
+	if err != nil {
+		return err
+	}
+	if err := rows(out); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// document builds the statement heading: the business issuing it, the courier it is for and the period
+func (s *ExportService) document(ctx context.Context, courierID uuid.UUID, title string, from, to *time.Time) (export.Document, error) {
+	courier, err := s.courierRepo.GetByID(ctx, courierID)
+	if err != nil {
+		return export.Document{}, fmt.Errorf("failed to load courier: %w", err)
+	}
+	loc, err := time.LoadLocation(s.cfg.DefaultTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	preparedFor := courier.CompanyName
+	if courier.OwnerName != "" && courier.OwnerName != courier.CompanyName {
+		preparedFor += " (" + courier.OwnerName + ")"
+	}
+	return export.Document{
+		Title:   title,
+		Details: []string{"Prepared for: " + preparedFor, "Period: " + describePeriod(from, to, loc), "Currency: " + s.cfg.Currency},
+		Sheet:   title,
+		Business: export.Business{
+			Name:    s.cfg.BusinessName,
+			Country: s.cfg.BusinessCountry,
+			Email:   s.cfg.SupportEmail,
+			Phone:   s.cfg.SupportPhone,
+		},
+		Location:    loc,
+		FormatMoney: s.cfg.FormatCurrency,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+func validateExportPeriod(from, to *time.Time) error {
+	if from != nil && to != nil && from.After(*to) {
+		return fmt.Errorf("%w: from must not be after to", ErrInvalidExport)
+	}
+	return nil
+}
+
+// describePeriod renders an optional date range for a statement heading
+func describePeriod(from, to *time.Time, loc *time.Location) string {
+	const layout = "02 Jan 2006"
+	switch {
+	case from != nil && to != nil:
+		return from.In(loc).Format(layout) + " to " + to.In(loc).Format(layout)
+	case from != nil:
+		return "From " + from.In(loc).Format(layout)
+	case to != nil:
+		return "Up to " + to.In(loc).Format(layout)
+	}
+	return "All dates"
+}
+
+func exportFilename(name string, format export.Format) string {
+	return fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// idempotencyLockTimeout is how long a key can stay processing before an identical retry may take it over
+const idempotencyLockTimeout = time.Minute
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotencyService makes retried store and payment requests safe by replaying the stored response
+type IdempotencyService struct {
+	repo *repository.IdempotencyRepository
+	cfg  *config.Config
+}
+
+// NewIdempotencyService creates a new idempotency service
+func NewIdempotencyService(repo *repository.IdempotencyRepository, cfg *config.Config) *IdempotencyService {
+	return &IdempotencyService{repo: repo, cfg: cfg}
+}
+
+// Fingerprint identifies a request by its method, path and body (for multipart uploads, its fields
+// and file digests)
+func (s *IdempotencyService) Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin reserves a key for a request. It returns nil if the request should run, the stored
+// record if it already completed and must be replayed, or an error if the key is in use by
+// a running request or was used for a different request.
+func (s *IdempotencyService) Begin(ctx context.Context, scope, key, fingerprint string) (*models.IdempotencyRecord, error) {
+	now := time.Now()
+	record, reserved, err := s.repo.Reserve(ctx, scope, key, fingerprint, now.Add(s.cfg.IdempotencyKeyTTL), now.Add(-idempotencyLockTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	if record.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if record.Status != models.IdempotencyStatusCompleted {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return record, nil
+}
+
+// Complete stores the response of a request so retries with the same key replay it
+func (s *IdempotencyService) Complete(ctx context.Context, scope, key string, status int, body []byte, contentType string) {
+	if err := s.repo.Complete(ctx, scope, key, status, body, contentType); err != nil {
+		log.Printf("⚠️ Failed to store idempotent response for key %s: %v", key, err)
+	}
+}
+
+// Release frees a key whose request failed so a retry runs it again
+func (s *IdempotencyService) Release(ctx context.Context, scope, key string) {
+	if err := s.repo.Release(ctx, scope, key); err != nil {
+		log.Printf("⚠️ Failed to release idempotency key %s: %v", key, err)
+	}
+}
+
+// Run periodically deletes expired keys until ctx is cancelled
+func (s *IdempotencyService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteExpired(ctx)
+			if err != nil {
+				log.Printf("⚠️ Idempotency key cleanup failed: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("🧹 Deleted %d expired idempotency keys", deleted)
+			}
+		}
+	}
+}
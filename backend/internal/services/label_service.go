@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/label"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+var (
+	// ErrInvalidLabelRequest is returned when a label batch request fails validation
+	ErrInvalidLabelRequest = errors.New("invalid label request")
+	// ErrLabelUnavailable is returned for orders that are no longer going to be shipped
+	ErrLabelUnavailable = errors.New("labels cannot be printed for cancelled or declined orders")
+)
+
+// LabelService builds printable shipping labels for orders
+type LabelService struct {
+	orderRepo   *repository.OrderRepository
+	courierRepo *repository.CourierRepository
+	importRepo  *repository.OrderImportRepository
+	cfg         *config.Config
+}
+
+// NewLabelService creates a new label service
+func NewLabelService(
+	orderRepo *repository.OrderRepository,
+	courierRepo *repository.CourierRepository,
+	importRepo *repository.OrderImportRepository,
+	cfg *config.Config,
+) *LabelService {
+	return &LabelService{
+		orderRepo:   orderRepo,
+		courierRepo: courierRepo,
+		importRepo:  importRepo,
+		cfg:         cfg,
+	}
+}
+
+// ForOrder returns the labels of one order: one label, or one per drop-off on a multi-stop order
+func (s *LabelService) ForOrder(ctx context.Context, orderID uuid.UUID) ([]label.Label, *models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if !labelPrintable(order) {
+		return nil, nil, ErrLabelUnavailable
+	}
+
+	carriers := map[uuid.UUID]string{}
+	return s.orderLabels(ctx, order, carriers), order, nil
+}
+
+// ForCourierOrder returns the labels of one of the courier's orders
+func (s *LabelService) ForCourierOrder(ctx context.Context, courierID, orderID uuid.UUID) ([]label.Label, *models.Order, error) {
+	labels, order, err := s.ForOrder(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order.CourierID != courierID {
+		return nil, nil, ErrNotOrderCourier
+	}
+	return labels, order, nil
+}
+
+// ForOrders returns the labels of several orders, in the order given, for batch printing.
+// Cancelled and declined orders are left out.
+func (s *LabelService) ForOrders(ctx context.Context, orderIDs []uuid.UUID) ([]label.Label, error) {
+	if len(orderIDs) == 0 {
+		return nil, fmt.Errorf("%w: orderIds is required", ErrInvalidLabelRequest)
+	}
+	if len(orderIDs) > s.cfg.MaxImportRows {
+		return nil, fmt.Errorf("%w: at most %d orders can be printed at once", ErrInvalidLabelRequest, s.cfg.MaxImportRows)
+	}
+
+	var labels []label.Label
+	carriers := map[uuid.UUID]string{}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range orderIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		order, err := s.orderRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, id)
+		}
+		if labelPrintable(order) {
+			labels = append(labels, s.orderLabels(ctx, order, carriers)...)
+		}
+	}
+	if len(labels) == 0 {
+		return nil, ErrLabelUnavailable
+	}
+	return labels, nil
+}
+
+// ForImport returns the labels of every order created by a bulk import, in row order
+func (s *LabelService) ForImport(ctx context.Context, importID uuid.UUID) ([]label.Label, *models.OrderImport, error) {
+	imp, err := s.importRepo.GetByID(ctx, importID)
+	if err != nil {
+		return nil, nil, ErrImportNotFound
+	}
+
+	var orderIDs []uuid.UUID
+	for _, row := range imp.Rows {
+		if row.Status == models.ImportRowCreated && row.OrderID != nil {
+			orderIDs = append(orderIDs, *row.OrderID)
+		}
+	}
+	if len(orderIDs) == 0 {
+		return nil, nil, fmt.Errorf("%w: the import did not create any orders", ErrInvalidLabelRequest)
+	}
+
+	labels, err := s.ForOrders(ctx, orderIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return labels, imp, nil
+}
+
+// Write renders labels in the given format and size
+func (s *LabelService) Write(w io.Writer, format label.Format, size label.Size, labels []label.Label) error {
+	if format == label.FormatZPL {
+		return label.WriteZPL(w, size, labels)
+	}
+	return label.WritePDF(w, size, labels)
+}
+
+// TrackingURL returns the customer tracking page of an order
+func (s *LabelService) TrackingURL(orderID uuid.UUID) string {
+	return strings.TrimRight(s.cfg.TrackingPageURL, "/") + "/" + orderID.String()
+}
+
+// orderLabels builds an order's labels. carriers caches courier company names across a batch.
+func (s *LabelService) orderLabels(ctx context.Context, order *models.Order, carriers map[uuid.UUID]string) []label.Label {
+	carrier, ok := carriers[order.CourierID]
+	if !ok {
+		carrier = s.cfg.BusinessName
+		if courier, err := s.courierRepo.GetByID(ctx, order.CourierID); err == nil && courier.CompanyName != "" {
+			carrier = courier.CompanyName
+		}
+		carriers[order.CourierID] = carrier
+	}
+
+	base := label.Label{
+		Carrier:     carrier,
+		OrderNumber: order.OrderNumber,
+		TrackingURL: s.TrackingURL(order.ID),
+		From: label.Party{
+			Name:    order.PickupContactName,
+			Phone:   order.PickupContactPhone,
+			Address: order.PickupAddress,
+		},
+		PackageSize:        order.PackageSize,
+		PackageWeight:      order.PackageWeight,
+		PackageDescription: order.PackageDescription,
+		Fragile:            order.IsFragile,
+		SignatureRequired:  order.RequiresSignature,
+	}
+
+	if order.OrderType != models.OrderTypeMultiStop || len(order.Stops) == 0 {
+		l := base
+		l.To = label.Party{
+			Name:    order.CustomerName,
+			Phone:   order.CustomerPhone,
+			Address: order.DeliveryAddress,
+			Notes:   order.DeliveryNotes,
+		}
+		if order.CODAmount > 0 {
+			l.CashToCollect = s.cfg.FormatCurrency(order.CODAmount)
+		}
+		return []label.Label{l}
+	}
+
+	// One parcel per drop-off, each labelled with its own recipient
+	labels := make([]label.Label, 0, len(order.Stops))
+	for _, stop := range order.Stops {
+		l := base
+		l.Stop = fmt.Sprintf("Stop %d of %d", stop.Sequence, len(order.Stops))
+		l.To = label.Party{
+			Name:    stop.RecipientName,
+			Phone:   stop.RecipientPhone,
+			Address: stop.Address,
+			Notes:   stop.Notes,
+		}
+		if stop.PackageDescription != "" {
+			l.PackageDescription = stop.PackageDescription
+		}
+		if stop.CODAmount > 0 {
+			l.CashToCollect = s.cfg.FormatCurrency(stop.CODAmount)
+		}
+		labels = append(labels, l)
+	}
+	return labels
+}
+
+func labelPrintable(order *models.Order) bool {
+	return order.Status != models.OrderStatusCancelled && order.Status != models.OrderStatusDeclined
+}
@@ -3,10 +3,17 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"nyengo-deliveries/internal/models"
 )
 
+// CustomerNotificationChannel is consumed by the SMS/email gateway that reaches customers
+const CustomerNotificationChannel = "customer_notifications"
+
 type NotificationService struct {
 	redis *redis.Client
 }
@@ -48,3 +55,217 @@ func (s *NotificationService) SendNewOrder(ctx context.Context, courierID, order
 		Data:    map[string]string{"orderId": orderID},
 	})
 }
+
+// SendDeliveryPIN sends the handoff PIN to the order's customer
+func (s *NotificationService) SendDeliveryPIN(ctx context.Context, order *models.Order, pin string) error {
+	return s.Send(ctx, CustomerNotificationChannel, &Notification{
+		Type: "delivery_pin", Title: "Your Delivery PIN",
+		Message: fmt.Sprintf("Your delivery PIN for order %s is %s. Only share it with the driver once you have received your parcel.", order.OrderNumber, pin),
+		Data: map[string]string{
+			"orderId":       order.ID.String(),
+			"orderNumber":   order.OrderNumber,
+			"customerName":  order.CustomerName,
+			"customerPhone": order.CustomerPhone,
+			"customerEmail": order.CustomerEmail,
+			"pin":           pin,
+		},
+	})
+}
+
+// SendPickupReminder reminds the assigned courier of an upcoming scheduled pickup
+func (s *NotificationService) SendPickupReminder(ctx context.Context, order *models.Order) error {
+	return s.Send(ctx, "courier:"+order.CourierID.String(), &Notification{
+		Type: "pickup_reminder", Title: "Upcoming Pickup",
+		Message: fmt.Sprintf("Order %s is scheduled for pickup at %s from %s", order.OrderNumber, order.ScheduledPickup.Format(time.Kitchen), order.PickupAddress),
+		Data: map[string]string{
+			"orderId":         order.ID.String(),
+			"orderNumber":     order.OrderNumber,
+			"scheduledPickup": order.ScheduledPickup.Format(time.RFC3339),
+		},
+	})
+}
+
+// SendPickupOverdue warns the assigned courier that a scheduled pickup time has passed without tracking
+func (s *NotificationService) SendPickupOverdue(ctx context.Context, order *models.Order) error {
+	return s.Send(ctx, "courier:"+order.CourierID.String(), &Notification{
+		Type: "pickup_overdue", Title: "Pickup Overdue",
+		Message: fmt.Sprintf("Order %s was due for pickup at %s. Start tracking once you are on your way.", order.OrderNumber, order.ScheduledPickup.Format(time.Kitchen)),
+		Data: map[string]string{
+			"orderId":         order.ID.String(),
+			"orderNumber":     order.OrderNumber,
+			"scheduledPickup": order.ScheduledPickup.Format(time.RFC3339),
+		},
+	})
+}
+
+// SendReattemptScheduled tells the customer that delivery failed and when the driver will try again
+func (s *NotificationService) SendReattemptScheduled(ctx context.Context, order *models.Order) error {
+	return s.Send(ctx, CustomerNotificationChannel, &Notification{
+		Type: "reattempt_scheduled", Title: "We Missed You",
+		Message: fmt.Sprintf("We could not deliver order %s. We will try again around %s.", order.OrderNumber, order.NextAttemptAt.Format("Mon 2 Jan 15:04")),
+		Data: map[string]string{
+			"orderId":       order.ID.String(),
+			"orderNumber":   order.OrderNumber,
+			"customerName":  order.CustomerName,
+			"customerPhone": order.CustomerPhone,
+			"customerEmail": order.CustomerEmail,
+			"nextAttemptAt": order.NextAttemptAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// SendReattemptDue reminds the assigned courier that a scheduled re-attempt is due
+func (s *NotificationService) SendReattemptDue(ctx context.Context, order *models.Order) error {
+	return s.Send(ctx, "courier:"+order.CourierID.String(), &Notification{
+		Type: "reattempt_due", Title: "Re-attempt Due",
+		Message: fmt.Sprintf("Order %s is due for another delivery attempt at %s", order.OrderNumber, order.DeliveryAddress),
+		Data: map[string]string{
+			"orderId":       order.ID.String(),
+			"orderNumber":   order.OrderNumber,
+			"nextAttemptAt": order.NextAttemptAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// SendDispatchOffer offers an automatically dispatched or broadcast order to a courier
+func (s *NotificationService) SendDispatchOffer(ctx context.Context, courierID string, order *models.Order) error {
+	data := map[string]string{
+		"orderId":       order.ID.String(),
+		"orderNumber":   order.OrderNumber,
+		"pickupAddress": order.PickupAddress,
+		"dispatchMode":  string(order.DispatchMode),
+	}
+	if order.OfferExpiresAt != nil {
+		data["offerExpiresAt"] = order.OfferExpiresAt.Format(time.RFC3339)
+	}
+	return s.Send(ctx, "courier:"+courierID, &Notification{
+		Type: "dispatch_offer", Title: "New Delivery Offer",
+		Message: fmt.Sprintf("Order %s from %s is available. Accept it before the offer expires.", order.OrderNumber, order.PickupAddress),
+		Data:    data,
+	})
+}
+
+// SendOfferWithdrawn tells a courier that an order they were offered is no longer available
+func (s *NotificationService) SendOfferWithdrawn(ctx context.Context, courierID string, order *models.Order, reason string) error {
+	return s.Send(ctx, "courier:"+courierID, &Notification{
+		Type: "offer_withdrawn", Title: "Offer Withdrawn",
+		Message: fmt.Sprintf("Order %s is no longer available: %s", order.OrderNumber, reason),
+		Data: map[string]string{
+			"orderId":     order.ID.String(),
+			"orderNumber": order.OrderNumber,
+			"reason":      reason,
+		},
+	})
+}
+
+// SendTransferRequest asks a courier to take over another courier's order
+func (s *NotificationService) SendTransferRequest(ctx context.Context, transfer *models.OrderTransfer) error {
+	return s.Send(ctx, "courier:"+transfer.ToCourierID.String(), &Notification{
+		Type: "transfer_request", Title: "Order Handover Request",
+		Message: fmt.Sprintf("You have been asked to take over order %s. Accept it before %s.", transfer.OrderNumber, transfer.ExpiresAt.Format("15:04")),
+		Data: map[string]string{
+			"transferId":  transfer.ID.String(),
+			"orderId":     transfer.OrderID.String(),
+			"orderNumber": transfer.OrderNumber,
+			"reason":      transfer.Reason,
+			"expiresAt":   transfer.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// SendTransferUpdate tells a courier that a handover they are part of was accepted, rejected, cancelled or expired
+func (s *NotificationService) SendTransferUpdate(ctx context.Context, courierID string, transfer *models.OrderTransfer) error {
+	return s.Send(ctx, "courier:"+courierID, &Notification{
+		Type: "transfer_" + string(transfer.Status), Title: "Order Handover Update",
+		Message: fmt.Sprintf("The handover of order %s was %s", transfer.OrderNumber, transfer.Status),
+		Data: map[string]string{
+			"transferId":   transfer.ID.String(),
+			"orderId":      transfer.OrderID.String(),
+			"orderNumber":  transfer.OrderNumber,
+			"status":       string(transfer.Status),
+			"fromEarnings": fmt.Sprintf("%.2f", transfer.FromEarnings),
+			"toEarnings":   fmt.Sprintf("%.2f", transfer.ToEarnings),
+		},
+	})
+}
+
+// SendDriverAssignment tells a driver they were assigned an order
+func (s *NotificationService) SendDriverAssignment(ctx context.Context, driverID string, order *models.Order) error {
+	return s.Send(ctx, "driver:"+driverID, &Notification{
+		Type: "driver_assignment", Title: "New Delivery Assigned",
+		Message: fmt.Sprintf("Order %s to %s has been assigned to you", order.OrderNumber, order.DeliveryAddress),
+		Data: map[string]string{
+			"orderId":     order.ID.String(),
+			"orderNumber": order.OrderNumber,
+			"status":      string(order.Status),
+		},
+	})
+}
+
+// SendDriverUnassigned tells a driver an order was taken off them
+func (s *NotificationService) SendDriverUnassigned(ctx context.Context, driverID string, order *models.Order) error {
+	return s.Send(ctx, "driver:"+driverID, &Notification{
+		Type: "driver_unassigned", Title: "Delivery Unassigned",
+		Message: fmt.Sprintf("Order %s is no longer assigned to you", order.OrderNumber),
+		Data: map[string]string{
+			"orderId":     order.ID.String(),
+			"orderNumber": order.OrderNumber,
+		},
+	})
+}
+
+// SendDriverAssignmentUpdate tells the courier company that a driver accepted or rejected an order
+func (s *NotificationService) SendDriverAssignmentUpdate(ctx context.Context, courierID string, order *models.Order, assignment *models.DriverAssignment) error {
+	return s.Send(ctx, "courier:"+courierID, &Notification{
+		Type: "driver_assignment_" + assignment.Status, Title: "Driver Assignment Update",
+		Message: fmt.Sprintf("%s %s order %s", assignment.DriverName, assignment.Status, order.OrderNumber),
+		Data: map[string]string{
+			"orderId":     order.ID.String(),
+			"orderNumber": order.OrderNumber,
+			"driverId":    assignment.DriverID.String(),
+			"status":      assignment.Status,
+			"note":        assignment.Note,
+		},
+	})
+}
+
+// SendShiftTimedOut tells a courier or driver they were set offline after missing heartbeats
+func (s *NotificationService) SendShiftTimedOut(ctx context.Context, shift *models.Shift) error {
+	recipient := "courier:" + shift.CourierID.String()
+	if shift.DriverID != nil {
+		recipient = "driver:" + shift.DriverID.String()
+	}
+
+	return s.Send(ctx, recipient, &Notification{
+		Type: "shift_timed_out", Title: "You Are Offline",
+		Message: "No heartbeat was received from your app, so you were set offline. Go online again to receive jobs.",
+		Data: map[string]string{
+			"shiftId":     shift.ID.String(),
+			"lastSeenAt":  shift.LastHeartbeatAt.Format(time.RFC3339),
+			"hoursWorked": fmt.Sprintf("%.2f", shift.HoursWorked),
+		},
+	})
+}
+
+// SendVehicleDocumentReminder tells a courier that a vehicle's insurance or roadworthiness is about
+// to expire, or already has
+func (s *NotificationService) SendVehicleDocumentReminder(ctx context.Context, vehicle *models.Vehicle, document string, expiry time.Time, expired bool) error {
+	name := string(vehicle.Type)
+	if vehicle.Plate != "" {
+		name += " " + vehicle.Plate
+	}
+	notificationType, message := "vehicle_document_expiring", fmt.Sprintf("The %s of your %s expires on %s", document, name, expiry.Format("2 Jan 2006"))
+	if expired {
+		notificationType, message = "vehicle_document_expired", fmt.Sprintf("The %s of your %s expired on %s; it will not be offered orders until renewed", document, name, expiry.Format("2 Jan 2006"))
+	}
+
+	return s.Send(ctx, "courier:"+vehicle.CourierID.String(), &Notification{
+		Type: notificationType, Title: "Vehicle Document Reminder", Message: message,
+		Data: map[string]string{
+			"vehicleId": vehicle.ID.String(),
+			"plate":     vehicle.Plate,
+			"document":  document,
+			"expiry":    expiry.Format("2006-01-02"),
+		},
+	})
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// ErrInvalidOperatingHours is returned when operating hours or a timezone cannot be parsed
+var ErrInvalidOperatingHours = errors.New("invalid operating hours")
+
+// openingLookaheadDays bounds how far ahead the next opening of a closed courier is searched for
+const openingLookaheadDays = 14
+
+// openingWindow is a period a courier is open, as offsets from local midnight of the day it starts.
+// close passes 24h for hours that run past midnight.
+type openingWindow struct {
+	open, close time.Duration
+}
+
+// allDay is the window of a courier open around the clock
+var allDay = &openingWindow{close: 24 * time.Hour}
+
+// calendarDay is a holiday on a courier's calendar: closed all day (window nil) or on special hours
+type calendarDay struct {
+	name   string
+	window *openingWindow
+	own    bool // the courier's own entry, which wins over a platform holiday
+}
+
+// courierCalendar is a courier's weekly hours and holidays in its timezone, ready to be checked
+type courierCalendar struct {
+	loc      *time.Location
+	week     [7]*openingWindow // by time.Weekday; nil when closed
+	holidays map[string]calendarDay
+}
+
+// newCourierCalendar builds the calendar of a courier from its hours and the holidays that apply to it
+func newCourierCalendar(courier *models.Courier, holidays []models.Holiday, defaultTimezone string) (*courierCalendar, error) {
+	loc, err := courierLocation(courier.Timezone, defaultTimezone)
+	if err != nil {
+		return nil, err
+	}
+	week, err := parseOperatingHours(courier.OperatingHours)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &courierCalendar{loc: loc, week: week, holidays: make(map[string]calendarDay, len(holidays))}
+	for _, holiday := range holidays {
+		day := calendarDay{name: holiday.Name, own: holiday.CourierID != nil}
+		if existing, ok := c.holidays[holiday.Date.Format(dateLayout)]; ok && existing.own && !day.own {
+			continue
+		}
+		if holiday.Open != "" {
+			// Hours were validated when the holiday was added; bad ones close the day
+			day.window, _ = parseWindow(holiday.Open, holiday.Close)
+		}
+		c.holidays[holiday.Date.Format(dateLayout)] = day
+	}
+	return c, nil
+}
+
+// courierLocation loads a courier's timezone, the platform one when it has none
+func courierLocation(timezone, defaultTimezone string) (*time.Location, error) {
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidOperatingHours, timezone)
+	}
+	return loc, nil
+}
+
+// parseOperatingHours validates weekly hours. With no day set the courier is open around the clock;
+// otherwise days that are closed or left blank have no window.
+func parseOperatingHours(hours models.OperatingHours) ([7]*openingWindow, error) {
+	var week [7]*openingWindow
+	if hours == (models.OperatingHours{}) {
+		for i := range week {
+			week[i] = allDay
+		}
+		return week, nil
+	}
+
+	days := [7]struct {
+		name  string
+		hours models.DayHours
+	}{
+		time.Sunday:    {"sunday", hours.Sunday},
+		time.Monday:    {"monday", hours.Monday},
+		time.Tuesday:   {"tuesday", hours.Tuesday},
+		time.Wednesday: {"wednesday", hours.Wednesday},
+		time.Thursday:  {"thursday", hours.Thursday},
+		time.Friday:    {"friday", hours.Friday},
+		time.Saturday:  {"saturday", hours.Saturday},
+	}
+	for i, day := range days {
+		if day.hours.Closed || (day.hours.Open == "" && day.hours.Close == "") {
+			continue
+		}
+		window, err := parseWindow(day.hours.Open, day.hours.Close)
+		if err != nil {
+			return week, fmt.Errorf("%w: %s %v", ErrInvalidOperatingHours, day.name, err)
+		}
+		week[i] = window
+	}
+	return week, nil
+}
+
+// parseWindow parses open and close times (HH:MM, close may be 24:00). A close at or before the open
+// time runs into the next day, so equal times are open all day.
+func parseWindow(open, close string) (*openingWindow, error) {
+	opens, ok := parseClock(open)
+	if !ok || opens == 24*time.Hour {
+		return nil, errors.New("open must be HH:MM")
+	}
+	closes, ok := parseClock(close)
+	if !ok {
+		return nil, errors.New("close must be HH:MM")
+	}
+	if closes <= opens {
+		closes += 24 * time.Hour
+	}
+	return &openingWindow{open: opens, close: closes}, nil
+}
+
+// parseClock parses a wall-clock time as an offset from midnight
+func parseClock(value string) (time.Duration, bool) {
+	if value == "24:00" {
+		return 24 * time.Hour, true
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, true
+}
+
+// status reports whether the courier is open at t and, if not, when it next opens
+func (c *courierCalendar) status(t time.Time) *models.OpeningStatus {
+	t = t.In(c.loc)
+	status := &models.OpeningStatus{At: t, Timezone: c.loc.String()}
+	if _, holiday := c.windowOn(c.midnight(t)); holiday != "" {
+		status.Holiday = holiday
+	}
+
+	if closes, open := c.openAt(t); open {
+		status.IsOpen = true
+		status.NextAvailablePickup = &t
+		if !closes.IsZero() {
+			status.ClosesAt = &closes
+		}
+		return status
+	}
+	if opens, ok := c.nextOpening(t); ok {
+		status.NextAvailablePickup = &opens
+	}
+	return status
+}
+
+// openAt reports whether the courier is open at t and when that opening ends, following on into the
+// next day's hours when they start right as these close. The end is zero when the courier stays open
+// for the whole lookahead.
+func (c *courierCalendar) openAt(t time.Time) (time.Time, bool) {
+	today := c.midnight(t)
+	var closes time.Time
+	// Yesterday's hours may run past midnight
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		window, _ := c.windowOn(day)
+		if window == nil {
+			continue
+		}
+		if opens, end := c.clock(day, window.open), c.clock(day, window.close); !t.Before(opens) && t.Before(end) {
+			closes = end
+		}
+	}
+	if closes.IsZero() {
+		return time.Time{}, false
+	}
+
+	for i := 0; i < openingLookaheadDays; i++ {
+		day := c.midnight(closes)
+		window, _ := c.windowOn(day)
+		if window == nil || !c.clock(day, window.open).Equal(closes) {
+			return closes, true
+		}
+		closes = c.clock(day, window.close)
+	}
+	return time.Time{}, true
+}
+
+// nextOpening returns when the courier next opens after t, within the lookahead
+func (c *courierCalendar) nextOpening(t time.Time) (time.Time, bool) {
+	today := c.midnight(t)
+	for i := 0; i <= openingLookaheadDays; i++ {
+		day := today.AddDate(0, 0, i)
+		if window, _ := c.windowOn(day); window != nil {
+			if opens := c.clock(day, window.open); opens.After(t) {
+				return opens, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// windowOn returns the hours of the day starting at local midnight day, and the holiday they come
+// from if any
+func (c *courierCalendar) windowOn(day time.Time) (*openingWindow, string) {
+	if holiday, ok := c.holidays[day.Format(dateLayout)]; ok {
+		return holiday.window, holiday.name
+	}
+	return c.week[day.Weekday()], ""
+}
+
+// midnight returns local midnight of the day t falls on
+func (c *courierCalendar) midnight(t time.Time) time.Time {
+	local := t.In(c.loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.loc)
+}
+
+// clock returns the wall-clock time offset from local midnight of day, so daylight saving changes
+// keep hours on the clock
+func (c *courierCalendar) clock(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(offset/time.Minute), 0, 0, c.loc)
+}
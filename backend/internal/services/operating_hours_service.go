@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+var (
+	ErrCourierNotFound = errors.New("courier not found")
+	ErrInvalidHoliday  = errors.New("invalid holiday")
+	ErrHolidayNotFound = errors.New("holiday not found")
+	ErrHolidayExists   = errors.New("a holiday is already set for that day")
+)
+
+// holidayListDefaultPeriod is the period a holiday list covers when no range is given
+const holidayListDefaultPeriod = 90 * 24 * time.Hour
+
+// OperatingHoursService checks couriers' operating hours in their own timezone, against the platform
+// holiday calendar (per country) and each courier's own. Couriers closed at the pickup time are not
+// dispatched and are left out of store courier lists, which can instead say when they next open.
+type OperatingHoursService struct {
+	holidayRepo *repository.HolidayRepository
+	courierRepo *repository.CourierRepository
+	cfg         *config.Config
+}
+
+// NewOperatingHoursService creates a new operating hours service
+func NewOperatingHoursService(
+	holidayRepo *repository.HolidayRepository,
+	courierRepo *repository.CourierRepository,
+	cfg *config.Config,
+) *OperatingHoursService {
+	return &OperatingHoursService{
+		holidayRepo: holidayRepo,
+		courierRepo: courierRepo,
+		cfg:         cfg,
+	}
+}
+
+// Status returns whether a courier takes pickups at a time, and when it next does if not
+func (s *OperatingHoursService) Status(ctx context.Context, courierID uuid.UUID, at time.Time) (*models.OpeningStatus, error) {
+	courier, calendar, err := s.calendar(ctx, courierID, at)
+	if err != nil {
+		return nil, err
+	}
+	if calendar == nil {
+		log.Printf("⚠️ Courier %s has unreadable operating hours; treating it as open", courier.ID)
+		return &models.OpeningStatus{At: at, Timezone: courier.Timezone, IsOpen: true, NextAvailablePickup: &at}, nil
+	}
+	return calendar.status(at), nil
+}
+
+// Statuses returns the opening status at a time of every active courier. Couriers whose stored hours
+// cannot be read are left out, and are treated as open by callers.
+func (s *OperatingHoursService) Statuses(ctx context.Context, at time.Time) (map[uuid.UUID]*models.OpeningStatus, error) {
+	couriers, err := s.courierRepo.ListSchedules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load operating hours: %w", err)
+	}
+	from, to := holidayWindow(at)
+	holidays, err := s.holidayRepo.ListBetween(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load holidays: %w", err)
+	}
+
+	var platform []models.Holiday
+	own := make(map[uuid.UUID][]models.Holiday)
+	for _, holiday := range holidays {
+		if holiday.CourierID == nil {
+			platform = append(platform, holiday)
+		} else {
+			own[*holiday.CourierID] = append(own[*holiday.CourierID], holiday)
+		}
+	}
+
+	statuses := make(map[uuid.UUID]*models.OpeningStatus, len(couriers))
+	for i := range couriers {
+		courier := &couriers[i]
+		applying := own[courier.ID]
+		for _, holiday := range platform {
+			if holiday.Country == "" || strings.EqualFold(holiday.Country, courier.Country) {
+				applying = append(applying, holiday)
+			}
+		}
+		calendar, err := newCourierCalendar(courier, applying, s.cfg.DefaultTimezone)
+		if err != nil {
+			log.Printf("⚠️ Courier %s has unreadable operating hours: %v", courier.ID, err)
+			continue
+		}
+		statuses[courier.ID] = calendar.status(at)
+	}
+	return statuses, nil
+}
+
+// Hours returns a courier's weekly hours, whether it is open at a time and its holidays over the
+// coming period
+func (s *OperatingHoursService) Hours(ctx context.Context, courierID uuid.UUID, at time.Time) (*models.CourierHours, error) {
+	courier, err := s.courier(ctx, courierID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := s.Status(ctx, courierID, at)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	holidays, err := s.holidayRepo.ListForCourier(ctx, courierID, courier.Country, now, now.Add(holidayListDefaultPeriod))
+	if err != nil {
+		return nil, err
+	}
+	return &models.CourierHours{
+		CourierID:      courierID,
+		Timezone:       status.Timezone,
+		OperatingHours: courier.OperatingHours,
+		Status:         status,
+		Holidays:       holidays,
+	}, nil
+}
+
+// ListHolidays lists the holidays on a courier's calendar, its own and the platform's for its
+// country, between two dates. Without a range it covers the coming 90 days.
+func (s *OperatingHoursService) ListHolidays(ctx context.Context, courierID uuid.UUID, from, to *time.Time) ([]models.Holiday, error) {
+	courier, err := s.courier(ctx, courierID)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := holidayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.holidayRepo.ListForCourier(ctx, courierID, courier.Country, start, end)
+}
+
+// AddHoliday closes a courier for a day, or sets special hours for it. It overrides a platform
+// holiday on the same day, so a courier can open on a public holiday.
+func (s *OperatingHoursService) AddHoliday(ctx context.Context, courierID uuid.UUID, req *models.HolidayRequest) (*models.Holiday, error) {
+	if req.Country != "" {
+		return nil, fmt.Errorf("%w: country only applies to platform holidays", ErrInvalidHoliday)
+	}
+	holiday, err := parseHoliday(req)
+	if err != nil {
+		return nil, err
+	}
+	holiday.CourierID = &courierID
+	if err := s.create(ctx, holiday); err != nil {
+		return nil, err
+	}
+
+	log.Printf("📅 Courier %s added holiday %s on %s", courierID, holiday.Name, holiday.Date.Format(dateLayout))
+	return holiday, nil
+}
+
+// DeleteHoliday removes a holiday from a courier's calendar
+func (s *OperatingHoursService) DeleteHoliday(ctx context.Context, courierID, holidayID uuid.UUID) error {
+	return s.delete(ctx, holidayID, &courierID)
+}
+
+// ListPlatformHolidays lists the platform holidays between two dates, optionally for one country.
+// Without a range it covers the coming 90 days.
+func (s *OperatingHoursService) ListPlatformHolidays(ctx context.Context, country string, from, to *time.Time) ([]models.Holiday, error) {
+	start, end, err := holidayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.holidayRepo.ListPlatform(ctx, strings.TrimSpace(country), start, end)
+}
+
+// AddPlatformHoliday adds a public holiday that closes every courier in its country, or everywhere
+// when no country is given
+func (s *OperatingHoursService) AddPlatformHoliday(ctx context.Context, req *models.HolidayRequest) (*models.Holiday, error) {
+	holiday, err := parseHoliday(req)
+	if err != nil {
+		return nil, err
+	}
+	holiday.Country = strings.TrimSpace(req.Country)
+	if err := s.create(ctx, holiday); err != nil {
+		return nil, err
+	}
+
+	log.Printf("📅 Platform holiday %s added on %s", holiday.Name, holiday.Date.Format(dateLayout))
+	return holiday, nil
+}
+
+// DeletePlatformHoliday removes a platform holiday
+func (s *OperatingHoursService) DeletePlatformHoliday(ctx context.Context, holidayID uuid.UUID) error {
+	return s.delete(ctx, holidayID, nil)
+}
+
+// calendar loads a courier and its calendar for checks around a time. The calendar is nil when the
+// stored hours cannot be read.
+func (s *OperatingHoursService) calendar(ctx context.Context, courierID uuid.UUID, at time.Time) (*models.Courier, *courierCalendar, error) {
+	courier, err := s.courier(ctx, courierID)
+	if err != nil {
+		return nil, nil, err
+	}
+	from, to := holidayWindow(at)
+	holidays, err := s.holidayRepo.ListForCourier(ctx, courierID, courier.Country, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load holidays: %w", err)
+	}
+	calendar, err := newCourierCalendar(courier, holidays, s.cfg.DefaultTimezone)
+	if err != nil {
+		return courier, nil, nil
+	}
+	return courier, calendar, nil
+}
+
+func (s *OperatingHoursService) courier(ctx context.Context, courierID uuid.UUID) (*models.Courier, error) {
+	courier, err := s.courierRepo.GetByID(ctx, courierID)
+	if err != nil || !courier.IsActive {
+		return nil, ErrCourierNotFound
+	}
+	return courier, nil
+}
+
+func (s *OperatingHoursService) create(ctx context.Context, holiday *models.Holiday) error {
+	if err := s.holidayRepo.Create(ctx, holiday); err != nil {
+		if errors.Is(err, repository.ErrHolidayExists) {
+			return ErrHolidayExists
+		}
+		return fmt.Errorf("failed to add holiday: %w", err)
+	}
+	return nil
+}
+
+func (s *OperatingHoursService) delete(ctx context.Context, holidayID uuid.UUID, courierID *uuid.UUID) error {
+	if err := s.holidayRepo.Delete(ctx, holidayID, courierID); err != nil {
+		if errors.Is(err, repository.ErrHolidayNotFound) {
+			return ErrHolidayNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// parseHoliday validates a holiday request
+func parseHoliday(req *models.HolidayRequest) (*models.Holiday, error) {
+	date, err := time.Parse(dateLayout, strings.TrimSpace(req.Date))
+	if err != nil {
+		return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidHoliday)
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 255 {
+		return nil, fmt.Errorf("%w: name is required (at most 255 characters)", ErrInvalidHoliday)
+	}
+
+	holiday := &models.Holiday{Date: date, Name: name}
+	if req.Open != "" || req.Close != "" {
+		if _, err := parseWindow(req.Open, req.Close); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidHoliday, err)
+		}
+		holiday.Open, holiday.Close = req.Open, req.Close
+	}
+	return holiday, nil
+}
+
+// holidayWindow covers the dates a status check at a time can touch: the day before, for hours that
+// run past midnight, up to the end of the lookahead for the next opening
+func holidayWindow(at time.Time) (time.Time, time.Time) {
+	return at.AddDate(0, 0, -2), at.AddDate(0, 0, openingLookaheadDays+2)
+}
+
+// holidayRange applies the default period to a holiday list range
+func holidayRange(from, to *time.Time) (time.Time, time.Time, error) {
+	start := time.Now()
+	if from != nil {
+		start = *from
+	}
+	end := start.Add(holidayListDefaultPeriod)
+	if to != nil {
+		end = *to
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("%w: from must be before to", ErrInvalidHoliday)
+	}
+	return start, end, nil
+}
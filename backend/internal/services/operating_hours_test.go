@@ -0,0 +1,202 @@
+package services
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+)
+
+func TestCourierCalendarStatus(t *testing.T) {
+	lusaka, err := time.LoadLocation("Africa/Lusaka")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.June, day, hour, minute, 0, 0, lusaka)
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	// 2026-06-01 is a Monday. Saturday hours run past midnight into Sunday, which is closed.
+	weekdays := models.DayHours{Open: "08:00", Close: "18:00"}
+	hours := models.OperatingHours{
+		Monday: weekdays, Tuesday: weekdays, Wednesday: weekdays, Thursday: weekdays, Friday: weekdays,
+		Saturday: models.DayHours{Open: "18:00", Close: "02:00"},
+		Sunday:   models.DayHours{Closed: true},
+	}
+	courierID := uuid.New()
+	platformHoliday := func(date, name string) models.Holiday {
+		day, _ := time.Parse(dateLayout, date)
+		return models.Holiday{Date: day, Name: name}
+	}
+	ownHoliday := func(date, name, open, close string) models.Holiday {
+		day, _ := time.Parse(dateLayout, date)
+		return models.Holiday{CourierID: &courierID, Date: day, Name: name, Open: open, Close: close}
+	}
+
+	tests := []struct {
+		name     string
+		hours    models.OperatingHours
+		holidays []models.Holiday
+		at       time.Time
+		want     models.OpeningStatus
+	}{
+		{
+			name:  "open on a weekday",
+			hours: hours,
+			at:    at(1, 10, 0),
+			want:  models.OpeningStatus{IsOpen: true, ClosesAt: ptr(at(1, 18, 0)), NextAvailablePickup: ptr(at(1, 10, 0))},
+		},
+		{
+			name:  "before opening",
+			hours: hours,
+			at:    at(1, 7, 30),
+			want:  models.OpeningStatus{NextAvailablePickup: ptr(at(1, 8, 0))},
+		},
+		{
+			name:  "at closing time",
+			hours: hours,
+			at:    at(1, 18, 0),
+			want:  models.OpeningStatus{NextAvailablePickup: ptr(at(2, 8, 0))},
+		},
+		{
+			name:  "read in the courier's timezone",
+			hours: hours,
+			at:    time.Date(2026, time.June, 1, 6, 30, 0, 0, time.UTC),
+			want:  models.OpeningStatus{IsOpen: true, ClosesAt: ptr(at(1, 18, 0)), NextAvailablePickup: ptr(at(1, 8, 30))},
+		},
+		{
+			name:  "past midnight on the previous day's hours",
+			hours: hours,
+			at:    at(7, 1, 0),
+			want:  models.OpeningStatus{IsOpen: true, ClosesAt: ptr(at(7, 2, 0)), NextAvailablePickup: ptr(at(7, 1, 0))},
+		},
+		{
+			name:  "after the past-midnight hours end",
+			hours: hours,
+			at:    at(7, 3, 0),
+			want:  models.OpeningStatus{NextAvailablePickup: ptr(at(8, 8, 0))},
+		},
+		{
+			name:     "closed on a platform holiday",
+			hours:    hours,
+			holidays: []models.Holiday{platformHoliday("2026-06-02", "Public Holiday")},
+			at:       at(2, 10, 0),
+			want:     models.OpeningStatus{Holiday: "Public Holiday", NextAvailablePickup: ptr(at(3, 8, 0))},
+		},
+		{
+			name:     "holiday the day before closes its past-midnight hours",
+			hours:    hours,
+			holidays: []models.Holiday{platformHoliday("2026-06-06", "Public Holiday")},
+			at:       at(7, 1, 0),
+			want:     models.OpeningStatus{NextAvailablePickup: ptr(at(8, 8, 0))},
+		},
+		{
+			name:     "special hours on a holiday",
+			hours:    hours,
+			holidays: []models.Holiday{ownHoliday("2026-06-03", "Half day", "10:00", "14:00")},
+			at:       at(3, 9, 0),
+			want:     models.OpeningStatus{Holiday: "Half day", NextAvailablePickup: ptr(at(3, 10, 0))},
+		},
+		{
+			name:  "courier's own entry overrides a platform holiday",
+			hours: hours,
+			holidays: []models.Holiday{
+				platformHoliday("2026-06-02", "Public Holiday"),
+				ownHoliday("2026-06-02", "Open anyway", "10:00", "14:00"),
+			},
+			at:   at(2, 11, 0),
+			want: models.OpeningStatus{IsOpen: true, Holiday: "Open anyway", ClosesAt: ptr(at(2, 14, 0)), NextAvailablePickup: ptr(at(2, 11, 0))},
+		},
+		{
+			name: "hours that continue into the next day",
+			hours: models.OperatingHours{
+				Monday:  models.DayHours{Open: "08:00", Close: "24:00"},
+				Tuesday: models.DayHours{Open: "00:00", Close: "12:00"},
+			},
+			at:   at(1, 22, 0),
+			want: models.OpeningStatus{IsOpen: true, ClosesAt: ptr(at(2, 12, 0)), NextAvailablePickup: ptr(at(1, 22, 0))},
+		},
+		{
+			name:  "open around the clock without hours",
+			hours: models.OperatingHours{},
+			at:    at(7, 3, 0),
+			want:  models.OpeningStatus{IsOpen: true, NextAvailablePickup: ptr(at(7, 3, 0))},
+		},
+		{
+			name: "closed for the whole lookahead",
+			hours: models.OperatingHours{
+				Monday: models.DayHours{Closed: true}, Tuesday: models.DayHours{Closed: true},
+				Wednesday: models.DayHours{Closed: true}, Thursday: models.DayHours{Closed: true},
+				Friday: models.DayHours{Closed: true}, Saturday: models.DayHours{Closed: true},
+				Sunday: models.DayHours{Closed: true},
+			},
+			at:   at(1, 10, 0),
+			want: models.OpeningStatus{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			courier := &models.Courier{ID: courierID, Timezone: "Africa/Lusaka", OperatingHours: tt.hours}
+			calendar, err := newCourierCalendar(courier, tt.holidays, "UTC")
+			if err != nil {
+				t.Fatalf("newCourierCalendar() error = %v", err)
+			}
+
+			got := calendar.status(tt.at)
+			if got.IsOpen != tt.want.IsOpen {
+				t.Errorf("IsOpen = %v, want %v", got.IsOpen, tt.want.IsOpen)
+			}
+			if got.Holiday != tt.want.Holiday {
+				t.Errorf("Holiday = %q, want %q", got.Holiday, tt.want.Holiday)
+			}
+			if got.Timezone != "Africa/Lusaka" {
+				t.Errorf("Timezone = %q, want Africa/Lusaka", got.Timezone)
+			}
+			if !sameTime(got.ClosesAt, tt.want.ClosesAt) {
+				t.Errorf("ClosesAt = %v, want %v", got.ClosesAt, tt.want.ClosesAt)
+			}
+			if !sameTime(got.NextAvailablePickup, tt.want.NextAvailablePickup) {
+				t.Errorf("NextAvailablePickup = %v, want %v", got.NextAvailablePickup, tt.want.NextAvailablePickup)
+			}
+		})
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		open, close string
+		want        *openingWindow
+		wantErr     bool
+	}{
+		{open: "08:00", close: "18:00", want: &openingWindow{open: 8 * time.Hour, close: 18 * time.Hour}},
+		{open: "18:00", close: "02:00", want: &openingWindow{open: 18 * time.Hour, close: 26 * time.Hour}},
+		{open: "08:00", close: "24:00", want: &openingWindow{open: 8 * time.Hour, close: 24 * time.Hour}},
+		{open: "09:00", close: "09:00", want: &openingWindow{open: 9 * time.Hour, close: 33 * time.Hour}},
+		{open: "24:00", close: "06:00", wantErr: true},
+		{open: "8am", close: "18:00", wantErr: true},
+		{open: "08:00", close: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWindow(tt.open, tt.close)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseWindow(%q, %q) error = %v, wantErr %v", tt.open, tt.close, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && *got != *tt.want {
+			t.Errorf("parseWindow(%q, %q) = %+v, want %+v", tt.open, tt.close, *got, *tt.want)
+		}
+	}
+}
+
+func sameTime(got, want *time.Time) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	return got.Equal(*want)
+}
@@ -0,0 +1,543 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+	"nyengo-deliveries/pkg/validator"
+)
+
+var (
+	ErrImportNotFound = errors.New("import not found")
+	ErrInvalidImport  = errors.New("invalid import")
+)
+
+// importColumns are the CSV columns a bulk import understands, matching the JSON field names
+var importColumns = []string{
+	"courierId", "storeId", "externalOrderId",
+	"customerName", "customerPhone", "customerEmail",
+	"pickupAddress", "pickupLatitude", "pickupLongitude", "pickupNotes", "pickupContactName", "pickupContactPhone",
+	"deliveryAddress", "deliveryLatitude", "deliveryLongitude", "deliveryNotes",
+	"packageDescription", "packageSize", "packageWeight", "isFragile", "requiresSignature",
+	"paymentMethod", "codAmount", "scheduledPickup",
+}
+
+// metadataColumnPrefix marks CSV columns that set an order metadata field
+const metadataColumnPrefix = "metadata."
+
+var validPackageSizes = map[string]bool{"small": true, "medium": true, "large": true}
+
+var validPaymentMethods = map[models.PaymentMethod]bool{
+	models.PaymentMethodCash: true, models.PaymentMethodMobileMoney: true,
+	models.PaymentMethodCard: true, models.PaymentMethodWallet: true,
+}
+
+// ImportRow is one parsed row of a bulk import, with any errors found while parsing it
+type ImportRow struct {
+	Row         int
+	Request     models.ImportOrderRequest
+	ParseErrors []string
+}
+
+// ImportOptions controls how a bulk import is processed
+type ImportOptions struct {
+	Format    string
+	CourierID *uuid.UUID // Used for rows without a courierId
+	StoreID   *uuid.UUID // Used for rows without a storeId
+	DryRun    bool       // Validate and price only
+	OnError   models.ImportErrorMode
+}
+
+// OrderImportService validates, prices and creates store orders in bulk
+type OrderImportService struct {
+	repo         *repository.OrderImportRepository
+	courierRepo  *repository.CourierRepository
+	orderService *OrderService
+	pricing      *PricingService
+	cfg          *config.Config
+}
+
+// NewOrderImportService creates a new order import service
+func NewOrderImportService(
+	repo *repository.OrderImportRepository,
+	courierRepo *repository.CourierRepository,
+	orderService *OrderService,
+	pricing *PricingService,
+	cfg *config.Config,
+) *OrderImportService {
+	return &OrderImportService{repo: repo, courierRepo: courierRepo, orderService: orderService, pricing: pricing, cfg: cfg}
+}
+
+// ParseCSV reads a CSV file with a header row naming the columns in importColumns.
+// metadata.<key> columns set order metadata fields as text.
+func (s *OrderImportService) ParseCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read the CSV header row", ErrInvalidImport)
+	}
+
+	known := make(map[string]string, len(importColumns))
+	for _, col := range importColumns {
+		known[strings.ToLower(col)] = col
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "\ufeff") // Excel adds a byte order mark
+		col, ok := known[strings.ToLower(name)]
+		if len(name) > len(metadataColumnPrefix) && strings.EqualFold(name[:len(metadataColumnPrefix)], metadataColumnPrefix) {
+			col, ok = metadataColumnPrefix+name[len(metadataColumnPrefix):], true
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, name)
+		}
+		columns[i] = col
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) >= s.cfg.MaxImportRows {
+			return nil, fmt.Errorf("%w: an import can have at most %d rows", ErrInvalidImport, s.cfg.MaxImportRows)
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("%w: could not read the file", ErrInvalidImport)
+			}
+			rows = append(rows, ImportRow{Row: parseErr.StartLine - 1, ParseErrors: []string{"malformed CSV line: " + parseErr.Err.Error()}})
+			continue
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+
+		// Rows are numbered by their line in the file, not counting the header
+		line, _ := reader.FieldPos(0)
+		row := ImportRow{Row: line - 1}
+		for i, value := range record {
+			if i >= len(columns) {
+				row.ParseErrors = append(row.ParseErrors, "row has more fields than the header")
+				break
+			}
+			if err := setImportField(&row.Request, columns[i], strings.TrimSpace(value)); err != nil {
+				row.ParseErrors = append(row.ParseErrors, err.Error())
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the file has no rows", ErrInvalidImport)
+	}
+	return rows, nil
+}
+
+// ParseJSON reads a JSON array of ImportOrderRequest rows. Rows that do not decode are
+// reported individually rather than failing the whole import.
+func (s *OrderImportService) ParseJSON(data []byte) ([]ImportRow, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: body must be a JSON array of orders", ErrInvalidImport)
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: the array has no orders", ErrInvalidImport)
+	}
+	if len(raw) > s.cfg.MaxImportRows {
+		return nil, fmt.Errorf("%w: an import can have at most %d rows", ErrInvalidImport, s.cfg.MaxImportRows)
+	}
+
+	rows := make([]ImportRow, len(raw))
+	for i, item := range raw {
+		rows[i].Row = i + 1
+		if err := json.Unmarshal(item, &rows[i].Request); err != nil {
+			rows[i].ParseErrors = []string{"invalid order: " + err.Error()}
+		}
+	}
+	return rows, nil
+}
+
+// Import validates and prices every row, then creates the valid ones unless this is a dry run.
+// With onError=continue valid rows are created even if others fail; with onError=abort nothing
+// is created unless every row is valid. The job and its per-row report are stored.
+func (s *OrderImportService) Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (*models.OrderImport, error) {
+	if opts.OnError == "" {
+		opts.OnError = models.ImportOnErrorContinue
+	}
+	if opts.OnError != models.ImportOnErrorContinue && opts.OnError != models.ImportOnErrorAbort {
+		return nil, fmt.Errorf("%w: onError must be continue or abort", ErrInvalidImport)
+	}
+
+	imp := &models.OrderImport{
+		StoreID: opts.StoreID, Format: opts.Format, DryRun: opts.DryRun,
+		OnError: opts.OnError, TotalRows: len(rows),
+	}
+	if err := s.repo.Create(ctx, imp); err != nil {
+		return nil, err
+	}
+
+	// Validate and price every row before creating anything
+	couriers := make(map[uuid.UUID]error)
+	courierIDs := make([]uuid.UUID, len(rows))
+	imp.Rows = make([]models.OrderImportRow, len(rows))
+	for i := range rows {
+		courierIDs[i] = s.validateRow(ctx, &rows[i], opts, couriers, &imp.Rows[i])
+		if imp.Rows[i].Status == models.ImportRowValid {
+			imp.ValidRows++
+			imp.TotalFare += imp.Rows[i].TotalFare
+		}
+	}
+
+	rejected := opts.OnError == models.ImportOnErrorAbort && imp.ValidRows < imp.TotalRows
+	for i := range rows {
+		result := &imp.Rows[i]
+		if result.Status != models.ImportRowValid {
+			imp.FailedCount++
+			continue
+		}
+		if opts.DryRun {
+			continue
+		}
+		if rejected {
+			result.Status = models.ImportRowSkipped
+			continue
+		}
+
+		order, err := s.orderService.Create(ctx, courierIDs[i], &rows[i].Request.CreateOrderRequest)
+		if err != nil {
+			result.Status = models.ImportRowFailed
+			result.Errors = append(result.Errors, err.Error())
+			imp.FailedCount++
+			continue
+		}
+		result.Status = models.ImportRowCreated
+		result.OrderID = &order.ID
+		result.OrderNumber = order.OrderNumber
+		imp.CreatedCount++
+	}
+
+	succeeded := imp.CreatedCount
+	if opts.DryRun {
+		succeeded = imp.ValidRows
+	}
+	switch {
+	case rejected && !opts.DryRun:
+		imp.Status = models.ImportStatusRejected
+	case succeeded == imp.TotalRows:
+		imp.Status = models.ImportStatusCompleted
+	case succeeded == 0:
+		imp.Status = models.ImportStatusFailed
+	default:
+		imp.Status = models.ImportStatusPartial
+	}
+
+	if err := s.repo.Complete(ctx, imp); err != nil {
+		return nil, err
+	}
+	return imp, nil
+}
+
+// Get returns an import job with its per-row report
+func (s *OrderImportService) Get(ctx context.Context, id uuid.UUID) (*models.OrderImport, error) {
+	imp, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrImportNotFound
+	}
+	return imp, nil
+}
+
+// WriteReport writes an import's per-row report as CSV
+func (s *OrderImportService) WriteReport(w io.Writer, imp *models.OrderImport) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"row", "status", "externalOrderId", "courierId", "orderId", "orderNumber", "distanceKm", "totalFare", "errors"})
+
+	for _, row := range imp.Rows {
+		orderID, fare, distance := "", "", ""
+		if row.OrderID != nil {
+			orderID = row.OrderID.String()
+		}
+		if row.TotalFare > 0 {
+			fare = strconv.FormatFloat(row.TotalFare, 'f', 2, 64)
+			distance = strconv.FormatFloat(row.Distance, 'f', 2, 64)
+		}
+		writer.Write([]string{
+			strconv.Itoa(row.Row), string(row.Status), row.ExternalOrderID, row.CourierID,
+			orderID, row.OrderNumber, distance, fare, strings.Join(row.Errors, "; "),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// validateRow checks one row and prices it, filling in result. It returns the courier the order goes to.
+func (s *OrderImportService) validateRow(ctx context.Context, row *ImportRow, opts ImportOptions, couriers map[uuid.UUID]error, result *models.OrderImportRow) uuid.UUID {
+	req := &row.Request
+	if req.StoreID == nil {
+		req.StoreID = opts.StoreID
+	}
+
+	result.Row = row.Row
+	result.ExternalOrderID = req.ExternalOrderID
+	errs := append([]string(nil), row.ParseErrors...)
+
+	var courierID uuid.UUID
+	switch {
+	case req.CourierID != "":
+		id, err := uuid.Parse(req.CourierID)
+		if err != nil {
+			errs = append(errs, "courierId is not a valid ID")
+		}
+		courierID = id
+	case opts.CourierID != nil:
+		courierID = *opts.CourierID
+	default:
+		errs = append(errs, "courierId is required")
+	}
+	if courierID != uuid.Nil {
+		result.CourierID = courierID.String()
+		if err := s.checkCourier(ctx, courierID, couriers); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if !validator.ValidateRequired(req.CustomerName) {
+		errs = append(errs, "customerName is required")
+	}
+	if !validator.ValidatePhone(req.CustomerPhone) {
+		errs = append(errs, "customerPhone must be a phone number with 10-15 digits")
+	}
+	if req.CustomerEmail != "" && !validator.ValidateEmail(req.CustomerEmail) {
+		errs = append(errs, "customerEmail is not a valid email address")
+	}
+	if req.PickupContactPhone != "" && !validator.ValidatePhone(req.PickupContactPhone) {
+		errs = append(errs, "pickupContactPhone must be a phone number with 10-15 digits")
+	}
+	if !validator.ValidateRequired(req.PickupAddress) {
+		errs = append(errs, "pickupAddress is required")
+	}
+	if !validImportCoordinates(req.PickupLatitude, req.PickupLongitude) {
+		errs = append(errs, "pickup coordinates are missing or out of range")
+	}
+	if len(req.Stops) == 0 {
+		if !validator.ValidateRequired(req.DeliveryAddress) {
+			errs = append(errs, "deliveryAddress is required")
+		}
+		if !validImportCoordinates(req.DeliveryLatitude, req.DeliveryLongitude) {
+			errs = append(errs, "delivery coordinates are missing or out of range")
+		}
+	}
+	if !validator.ValidateRequired(req.PackageDescription) {
+		errs = append(errs, "packageDescription is required")
+	}
+	if !validPackageSizes[req.PackageSize] {
+		errs = append(errs, "packageSize must be small, medium or large")
+	}
+	if req.PackageWeight < 0 {
+		errs = append(errs, "packageWeight cannot be negative")
+	}
+	if !validPaymentMethods[req.PaymentMethod] {
+		errs = append(errs, "paymentMethod must be cash, mobile_money, card or wallet")
+	}
+	if req.ScheduledPickup != nil && req.ScheduledPickup.Before(time.Now()) {
+		errs = append(errs, "scheduledPickup must be in the future")
+	}
+	if len(req.Stops) > 0 {
+		// Validate on a copy so the request is only rewritten when the order is created
+		stopsReq := req.CreateOrderRequest
+		if err := s.orderService.prepareStops(&stopsReq); err != nil {
+			errs = append(errs, strings.TrimPrefix(err.Error(), ErrInvalidOrder.Error()+": "))
+		}
+	}
+
+	if err := validateCOD(&req.CreateOrderRequest); err != nil {
+		errs = append(errs, strings.TrimPrefix(err.Error(), ErrInvalidOrder.Error()+": "))
+	}
+	if err := validateMetadata(req.Metadata, s.cfg); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) == 0 {
+		estimate, err := s.pricing.CalculateEstimate(importEstimateRequest(req))
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			result.Distance = estimate.Distance
+			result.TotalFare = estimate.TotalFare
+			result.FormattedFare = estimate.FormattedTotal
+		}
+	}
+
+	if len(errs) > 0 {
+		result.Status = models.ImportRowInvalid
+		result.Errors = errs
+		return courierID
+	}
+	result.Status = models.ImportRowValid
+	return courierID
+}
+
+// checkCourier verifies that a courier exists and is active, caching the result per import
+func (s *OrderImportService) checkCourier(ctx context.Context, courierID uuid.UUID, couriers map[uuid.UUID]error) error {
+	if err, checked := couriers[courierID]; checked {
+		return err
+	}
+
+	var err error
+	courier, lookupErr := s.courierRepo.GetByID(ctx, courierID)
+	switch {
+	case lookupErr != nil:
+		err = errors.New("courier not found")
+	case !courier.IsActive:
+		err = errors.New("courier is not active")
+	}
+	couriers[courierID] = err
+	return err
+}
+
+// importEstimateRequest builds the pricing request for a row, routing through its stops if any
+func importEstimateRequest(req *models.ImportOrderRequest) *models.PriceEstimateRequest {
+	estimateReq := &models.PriceEstimateRequest{
+		PickupLatitude: req.PickupLatitude, PickupLongitude: req.PickupLongitude,
+		DeliveryLatitude: req.DeliveryLatitude, DeliveryLongitude: req.DeliveryLongitude,
+		PackageWeight: req.PackageWeight, IsFragile: req.IsFragile,
+	}
+	for _, stop := range req.Stops {
+		estimateReq.Stops = append(estimateReq.Stops, models.RoutePoint{Latitude: stop.Latitude, Longitude: stop.Longitude})
+	}
+	if n := len(req.Stops); n > 0 {
+		estimateReq.DeliveryLatitude, estimateReq.DeliveryLongitude = req.Stops[n-1].Latitude, req.Stops[n-1].Longitude
+	}
+	return estimateReq
+}
+
+func validImportCoordinates(lat, lon float64) bool {
+	return !(lat == 0 && lon == 0) && validator.ValidateCoordinates(lat, lon)
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// setImportField assigns one CSV cell to the matching request field
+func setImportField(req *models.ImportOrderRequest, column, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	parseFloat := func(target *float64) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", column)
+		}
+		*target = f
+		return nil
+	}
+	parseBool := func(target *bool) error {
+		switch strings.ToLower(value) {
+		case "yes", "y":
+			*target = true
+			return nil
+		case "no", "n":
+			*target = false
+			return nil
+		}
+		b, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", column)
+		}
+		*target = b
+		return nil
+	}
+
+	if key, ok := strings.CutPrefix(column, metadataColumnPrefix); ok {
+		if req.Metadata == nil {
+			req.Metadata = map[string]any{}
+		}
+		req.Metadata[key] = value
+		return nil
+	}
+
+	switch column {
+	case "courierId":
+		req.CourierID = value
+	case "storeId":
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return errors.New("storeId is not a valid ID")
+		}
+		req.StoreID = &id
+	case "externalOrderId":
+		req.ExternalOrderID = value
+	case "customerName":
+		req.CustomerName = value
+	case "customerPhone":
+		req.CustomerPhone = value
+	case "customerEmail":
+		req.CustomerEmail = value
+	case "pickupAddress":
+		req.PickupAddress = value
+	case "pickupLatitude":
+		return parseFloat(&req.PickupLatitude)
+	case "pickupLongitude":
+		return parseFloat(&req.PickupLongitude)
+	case "pickupNotes":
+		req.PickupNotes = value
+	case "pickupContactName":
+		req.PickupContactName = value
+	case "pickupContactPhone":
+		req.PickupContactPhone = value
+	case "deliveryAddress":
+		req.DeliveryAddress = value
+	case "deliveryLatitude":
+		return parseFloat(&req.DeliveryLatitude)
+	case "deliveryLongitude":
+		return parseFloat(&req.DeliveryLongitude)
+	case "deliveryNotes":
+		req.DeliveryNotes = value
+	case "packageDescription":
+		req.PackageDescription = value
+	case "packageSize":
+		req.PackageSize = strings.ToLower(value)
+	case "packageWeight":
+		return parseFloat(&req.PackageWeight)
+	case "isFragile":
+		return parseBool(&req.IsFragile)
+	case "requiresSignature":
+		return parseBool(&req.RequiresSignature)
+	case "paymentMethod":
+		req.PaymentMethod = models.PaymentMethod(strings.ToLower(value))
+	case "codAmount":
+		return parseFloat(&req.CODAmount)
+	case "scheduledPickup":
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("scheduledPickup must be an RFC 3339 time, e.g. 2026-01-05T08:00:00+02:00")
+		}
+		req.ScheduledPickup = &t
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+)
+
+func newTestImportService(maxRows int) *OrderImportService {
+	cfg := config.LoadConfig()
+	cfg.MaxImportRows = maxRows
+	return NewOrderImportService(nil, nil, nil, NewPricingService(cfg), cfg)
+}
+
+func TestParseCSVRejectsFile(t *testing.T) {
+	header := "customerName,customerPhone,pickupAddress\n"
+	tests := []struct {
+		name string
+		csv  string
+		want string
+	}{
+		{"empty file", "", "could not read the CSV header row"},
+		{"unknown column", "customerName,customerFax\nJane,123\n", `unknown column "customerFax"`},
+		{"metadata column without a key", "customerName,metadata.\nJane,x\n", `unknown column "metadata."`},
+		{"header only", header, "the file has no rows"},
+		{"only blank rows", header + ",,\n , ,\n", "the file has no rows"},
+		{"too many rows", header + strings.Repeat("Jane,0971234567,Cairo Rd\n", 4), "at most 3 rows"},
+	}
+
+	s := newTestImportService(3)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ParseCSV(strings.NewReader(tt.csv))
+			if !errors.Is(err, ErrInvalidImport) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseCSV() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	rows, err := s.ParseCSV(strings.NewReader(header + strings.Repeat("Jane,0971234567,Cairo Rd\n", 3)))
+	if err != nil || len(rows) != 3 {
+		t.Errorf("ParseCSV(3 rows) = %d rows, error %v; want 3 rows at the limit", len(rows), err)
+	}
+}
+
+func TestParseCSVRows(t *testing.T) {
+	csv := "\ufeffCustomerName, customerphone,pickupLatitude,isFragile,packageSize,scheduledPickup,metadata.Branch\n" +
+		"Jane Banda,0971234567,-15.4167,yes,MEDIUM,,Lusaka\n" +
+		"Joe Phiri,0977654321,south,maybe,small,tomorrow,\n" +
+		",,,,,,\n" +
+		"Ann Zulu,0961111111,-15.4,no,large,,North,extra\n" +
+		"Bad \"quote,0961111111,-15.4,no,large,,\n" +
+		"Mary Tembo,0962222222,-15.5,false,small,2030-01-05T08:00:00+02:00,\n"
+
+	rows, err := newTestImportService(10).ParseCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+
+	want := []struct {
+		row    int
+		errors []string
+	}{
+		{1, nil},
+		{2, []string{"pickupLatitude must be a number", "isFragile must be true or false", "scheduledPickup must be an RFC 3339 time"}},
+		{4, []string{"row has more fields than the header"}},
+		{5, []string{"malformed CSV line"}},
+		{6, nil},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("ParseCSV() returned %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, w := range want {
+		if rows[i].Row != w.row {
+			t.Errorf("rows[%d].Row = %d, want %d", i, rows[i].Row, w.row)
+		}
+		if len(rows[i].ParseErrors) != len(w.errors) {
+			t.Errorf("row %d errors = %q, want %q", w.row, rows[i].ParseErrors, w.errors)
+			continue
+		}
+		for j, e := range w.errors {
+			if !strings.Contains(rows[i].ParseErrors[j], e) {
+				t.Errorf("row %d error %d = %q, want %q", w.row, j, rows[i].ParseErrors[j], e)
+			}
+		}
+	}
+
+	first := rows[0].Request
+	if first.CustomerName != "Jane Banda" || first.CustomerPhone != "0971234567" || first.PickupLatitude != -15.4167 ||
+		!first.IsFragile || first.PackageSize != "medium" || first.Metadata["Branch"] != "Lusaka" {
+		t.Errorf("rows[0].Request = %+v", first)
+	}
+	if last := rows[4].Request; last.ScheduledPickup == nil || last.IsFragile {
+		t.Errorf("rows[4].Request = %+v", last)
+	}
+}
+
+func TestParseJSONRows(t *testing.T) {
+	s := newTestImportService(2)
+
+	rows, err := s.ParseJSON([]byte(`[{"customerName":"Jane"},{"customerName":42}]`))
+	if err != nil {
+		t.Fatalf("ParseJSON() error = %v", err)
+	}
+	if len(rows) != 2 || rows[0].Row != 1 || len(rows[0].ParseErrors) != 0 || rows[1].Row != 2 || len(rows[1].ParseErrors) != 1 {
+		t.Errorf("ParseJSON() = %+v", rows)
+	}
+
+	for _, body := range []string{`{"customerName":"Jane"}`, `[]`, `[{},{},{}]`} {
+		if _, err := s.ParseJSON([]byte(body)); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("ParseJSON(%s) error = %v, want ErrInvalidImport", body, err)
+		}
+	}
+}
+
+func TestValidateImportRow(t *testing.T) {
+	courierID := uuid.New()
+	inactiveID := uuid.New()
+	valid := func() models.ImportOrderRequest {
+		var req models.ImportOrderRequest
+		req.CourierID = courierID.String()
+		req.CustomerName = "Jane Banda"
+		req.CustomerPhone = "0971234567"
+		req.PickupAddress = "Cairo Road, Lusaka"
+		req.PickupLatitude, req.PickupLongitude = -15.4167, 28.2833
+		req.DeliveryAddress = "Kabulonga, Lusaka"
+		req.DeliveryLatitude, req.DeliveryLongitude = -15.4300, 28.3300
+		req.PackageDescription = "Shoes"
+		req.PackageSize = "small"
+		req.PaymentMethod = models.PaymentMethodCash
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		edit       func(req *models.ImportOrderRequest)
+		parseError string
+		opts       ImportOptions
+		want       []string
+	}{
+		{name: "valid row"},
+		{name: "courier from the import options", edit: func(req *models.ImportOrderRequest) { req.CourierID = "" }, opts: ImportOptions{CourierID: &courierID}},
+		{name: "no courier", edit: func(req *models.ImportOrderRequest) { req.CourierID = "" }, want: []string{"courierId is required"}},
+		{name: "malformed courier", edit: func(req *models.ImportOrderRequest) { req.CourierID = "courier-1" }, want: []string{"courierId is not a valid ID"}},
+		{name: "inactive courier", edit: func(req *models.ImportOrderRequest) { req.CourierID = inactiveID.String() }, want: []string{"courier is not active"}},
+		{name: "parse errors are kept", parseError: "pickupLatitude must be a number", want: []string{"pickupLatitude must be a number"}},
+		{
+			name: "every missing field is reported",
+			edit: func(req *models.ImportOrderRequest) {
+				req.CustomerName, req.PickupAddress, req.DeliveryAddress, req.PackageDescription = "", "", "", ""
+			},
+			want: []string{"customerName is required", "pickupAddress is required", "deliveryAddress is required", "packageDescription is required"},
+		},
+		{
+			name: "invalid values",
+			edit: func(req *models.ImportOrderRequest) {
+				req.CustomerPhone = "12"
+				req.CustomerEmail = "jane@"
+				req.PackageSize = "huge"
+				req.PackageWeight = -1
+				req.PaymentMethod = "cheque"
+			},
+			want: []string{"customerPhone must be", "customerEmail is not a valid email", "packageSize must be", "packageWeight cannot be negative", "paymentMethod must be"},
+		},
+		{
+			name: "coordinates out of range or missing",
+			edit: func(req *models.ImportOrderRequest) {
+				req.PickupLatitude = 95
+				req.DeliveryLatitude, req.DeliveryLongitude = 0, 0
+			},
+			want: []string{"pickup coordinates are missing or out of range", "delivery coordinates are missing or out of range"},
+		},
+		{
+			name: "reserved metadata key",
+			edit: func(req *models.ImportOrderRequest) { req.Metadata = map[string]any{"nyengo_route": "A"} },
+			want: []string{"reserved"},
+		},
+	}
+
+	s := newTestImportService(10)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := ImportRow{Row: 7, Request: valid()}
+			if tt.edit != nil {
+				tt.edit(&row.Request)
+			}
+			if tt.parseError != "" {
+				row.ParseErrors = []string{tt.parseError}
+			}
+			couriers := map[uuid.UUID]error{courierID: nil, inactiveID: errors.New("courier is not active")}
+
+			var result models.OrderImportRow
+			s.validateRow(context.Background(), &row, tt.opts, couriers, &result)
+			if result.Row != 7 {
+				t.Errorf("Row = %d, want 7", result.Row)
+			}
+			if len(tt.want) == 0 {
+				if result.Status != models.ImportRowValid || result.TotalFare <= 0 || len(result.Errors) != 0 {
+					t.Errorf("validateRow() = %s with fare %v, errors %q; want a priced valid row", result.Status, result.TotalFare, result.Errors)
+				}
+				return
+			}
+
+			if result.Status != models.ImportRowInvalid || result.TotalFare != 0 {
+				t.Errorf("validateRow() = %s with fare %v, want an unpriced invalid row", result.Status, result.TotalFare)
+			}
+			if len(result.Errors) != len(tt.want) {
+				t.Fatalf("errors = %q, want %q", result.Errors, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(result.Errors[i], want) {
+					t.Errorf("error %d = %q, want %q", i, result.Errors[i], want)
+				}
+			}
+		})
+	}
+}
@@ -2,30 +2,76 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"nyengo-deliveries/internal/config"
 	"nyengo-deliveries/internal/models"
 	"nyengo-deliveries/internal/repository"
 )
 
+// ErrOrderNotFound is returned when an order does not exist
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrInvalidOrder is returned when an order request fails validation
+var ErrInvalidOrder = errors.New("invalid order")
+
+// ErrInvalidOrderQuery is returned when order list filters, sorting or the cursor are invalid
+var ErrInvalidOrderQuery = errors.New("invalid order query")
+
+// maxOrderPageSize caps the number of orders returned per page
+const maxOrderPageSize = 100
+
+// maxMetadataFilters caps the metadata.<key>=<value> filters in one order query
+const maxMetadataFilters = 5
+
+// metadataKeyPattern is what order metadata keys may look like, so they can be used in query filters
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,40}$`)
+
 type OrderService struct {
-	repo        *repository.OrderRepository
-	courierRepo *repository.CourierRepository
-	pricing     *PricingService
+	repo         *repository.OrderRepository
+	courierRepo  *repository.CourierRepository
+	pricing      *PricingService
+	stateMachine *OrderStateMachine
 }
 
-func NewOrderService(repo *repository.OrderRepository, courierRepo *repository.CourierRepository, pricing *PricingService) *OrderService {
-	return &OrderService{repo: repo, courierRepo: courierRepo, pricing: pricing}
+func NewOrderService(repo *repository.OrderRepository, courierRepo *repository.CourierRepository, pricing *PricingService, stateMachine *OrderStateMachine) *OrderService {
+	return &OrderService{repo: repo, courierRepo: courierRepo, pricing: pricing, stateMachine: stateMachine}
 }
 
 func (s *OrderService) Create(ctx context.Context, courierID uuid.UUID, req *models.CreateOrderRequest) (*models.Order, error) {
-	estimate, err := s.pricing.CalculateEstimate(&models.PriceEstimateRequest{
+	if req.ScheduledPickup != nil && req.ScheduledPickup.Before(time.Now().Add(-time.Minute)) {
+		return nil, fmt.Errorf("%w: scheduled pickup must be in the future", ErrInvalidOrder)
+	}
+	if len(req.Stops) > 0 {
+		if err := s.prepareStops(req); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateCOD(req); err != nil {
+		return nil, err
+	}
+	if err := validateMetadata(req.Metadata, s.pricing.cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+
+	estimateReq := &models.PriceEstimateRequest{
 		PickupLatitude: req.PickupLatitude, PickupLongitude: req.PickupLongitude,
 		DeliveryLatitude: req.DeliveryLatitude, DeliveryLongitude: req.DeliveryLongitude,
 		PackageWeight: req.PackageWeight, IsFragile: req.IsFragile,
-	})
+	}
+	for _, stop := range req.Stops {
+		estimateReq.Stops = append(estimateReq.Stops, models.RoutePoint{Latitude: stop.Latitude, Longitude: stop.Longitude})
+	}
+
+	estimate, err := s.pricing.CalculateEstimate(estimateReq)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +91,29 @@ func (s *OrderService) Create(ctx context.Context, courierID uuid.UUID, req *mod
 		SurgeFare: estimate.SurgeFare, TotalFare: estimate.TotalFare,
 		PlatformFee: platformFee, CourierEarnings: earnings,
 		PaymentMethod: req.PaymentMethod, ScheduledPickup: req.ScheduledPickup,
+		OrderType: models.OrderTypeStandard, CODAmount: roundMoney(req.CODAmount),
+		Metadata: req.Metadata,
+	}
+
+	// Pickups scheduled beyond the activation window stay dormant until the pickup scheduler activates them
+	if req.ScheduledPickup != nil && time.Until(*req.ScheduledPickup) > s.pricing.cfg.ScheduleActivationLead {
+		order.Status = models.OrderStatusScheduled
+	}
+
+	if len(req.Stops) > 0 {
+		order.OrderType = models.OrderTypeMultiStop
+		order.Stops = make([]models.OrderStop, len(req.Stops))
+		for i, stop := range req.Stops {
+			order.Stops[i] = models.OrderStop{
+				Sequence:      i + 1,
+				RecipientName: stop.RecipientName, RecipientPhone: stop.RecipientPhone,
+				Address: stop.Address, Latitude: stop.Latitude, Longitude: stop.Longitude, Notes: stop.Notes,
+				PackageDescription: stop.PackageDescription, CODAmount: stop.CODAmount,
+				DistanceFromPrevious: estimate.Legs[i].Distance,
+			}
+			order.CODAmount += stop.CODAmount
+		}
+		order.CODAmount = roundMoney(order.CODAmount)
 	}
 
 	if err := s.repo.Create(ctx, order); err != nil {
@@ -53,24 +122,170 @@ func (s *OrderService) Create(ctx context.Context, courierID uuid.UUID, req *mod
 	return order, nil
 }
 
+// prepareStops validates the drop-offs of a multi-stop request and points the
+// order's delivery fields at the final stop so single-stop consumers keep working
+func (s *OrderService) prepareStops(req *models.CreateOrderRequest) error {
+	if len(req.Stops) > s.pricing.cfg.MaxStopsPerOrder {
+		return fmt.Errorf("%w: an order can have at most %d stops", ErrInvalidOrder, s.pricing.cfg.MaxStopsPerOrder)
+	}
+
+	for i, stop := range req.Stops {
+		switch {
+		case strings.TrimSpace(stop.RecipientName) == "" || strings.TrimSpace(stop.RecipientPhone) == "":
+			return fmt.Errorf("%w: stop %d needs a recipient name and phone", ErrInvalidOrder, i+1)
+		case strings.TrimSpace(stop.Address) == "":
+			return fmt.Errorf("%w: stop %d needs an address", ErrInvalidOrder, i+1)
+		case stop.Latitude == 0 && stop.Longitude == 0,
+			stop.Latitude < -90 || stop.Latitude > 90 || stop.Longitude < -180 || stop.Longitude > 180:
+			return fmt.Errorf("%w: stop %d has invalid coordinates", ErrInvalidOrder, i+1)
+		case stop.CODAmount < 0:
+			return fmt.Errorf("%w: stop %d has a negative COD amount", ErrInvalidOrder, i+1)
+		}
+	}
+
+	last := req.Stops[len(req.Stops)-1]
+	req.DeliveryAddress = last.Address
+	req.DeliveryLatitude = last.Latitude
+	req.DeliveryLongitude = last.Longitude
+	req.DeliveryNotes = last.Notes
+	if req.CustomerName == "" {
+		req.CustomerName = last.RecipientName
+	}
+	if req.CustomerPhone == "" {
+		req.CustomerPhone = last.RecipientPhone
+	}
+	return nil
+}
+
+// validateCOD checks the cash-on-delivery amounts of an order. Multi-stop orders collect per stop,
+// and the cash is remitted to the store, so COD orders must name one.
+func validateCOD(req *models.CreateOrderRequest) error {
+	total := req.CODAmount
+	for _, stop := range req.Stops {
+		total += stop.CODAmount
+	}
+	switch {
+	case req.CODAmount < 0:
+		return fmt.Errorf("%w: codAmount cannot be negative", ErrInvalidOrder)
+	case len(req.Stops) > 0 && req.CODAmount > 0:
+		return fmt.Errorf("%w: set codAmount on each stop of a multi-stop order", ErrInvalidOrder)
+	case total > 0 && req.StoreID == nil:
+		return fmt.Errorf("%w: cash-on-delivery orders need a storeId to remit the cash to", ErrInvalidOrder)
+	}
+	return nil
+}
+
+// validateMetadata checks the custom fields a store attaches to an order against the key format,
+// the reserved namespace and the configured key count and size limits
+func validateMetadata(metadata map[string]any, cfg *config.Config) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	if len(metadata) > cfg.MaxMetadataKeys {
+		return fmt.Errorf("metadata can have at most %d keys", cfg.MaxMetadataKeys)
+	}
+	for key := range metadata {
+		switch {
+		case !metadataKeyPattern.MatchString(key):
+			return fmt.Errorf("metadata key %q must be 1-40 letters, digits, underscores or dashes", key)
+		case strings.HasPrefix(strings.ToLower(key), models.MetadataReservedPrefix):
+			return fmt.Errorf("metadata keys starting with %s are reserved", models.MetadataReservedPrefix)
+		}
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("metadata is not valid JSON: %v", err)
+	}
+	if len(encoded) > cfg.MaxMetadataBytes {
+		return fmt.Errorf("metadata is %d bytes, the limit is %d", len(encoded), cfg.MaxMetadataBytes)
+	}
+	return nil
+}
+
 func (s *OrderService) GetByID(ctx context.Context, id uuid.UUID) (*models.Order, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
+// List returns a page of the courier's orders
 func (s *OrderService) List(ctx context.Context, courierID uuid.UUID, filters *models.OrderListFilters) (*models.OrderListResponse, error) {
-	return s.repo.List(ctx, courierID, filters)
+	filters.CourierID = &courierID
+	return s.Query(ctx, filters)
+}
+
+// Query returns a page of orders matching the filters, by page number or by cursor
+func (s *OrderService) Query(ctx context.Context, filters *models.OrderListFilters) (*models.OrderListResponse, error) {
+	if err := validateOrderFilters(filters); err != nil {
+		return nil, err
+	}
+	if filters.Cursor != "" && filters.Page > 1 {
+		return nil, fmt.Errorf("%w: use either page or cursor", ErrInvalidOrderQuery)
+	}
+	if filters.PageSize > maxOrderPageSize {
+		filters.PageSize = maxOrderPageSize
+	}
+
+	result, err := s.repo.List(ctx, filters)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidSort) {
+			return nil, fmt.Errorf("%w: sortBy must be one of %s", ErrInvalidOrderQuery, strings.Join(models.OrderSortFields, ", "))
+		}
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOrderQuery, err)
+		}
+		return nil, err
+	}
+	return result, nil
 }
 
-func (s *OrderService) UpdateStatus(ctx context.Context, orderID uuid.UUID, status models.OrderStatus) error {
-	return s.repo.UpdateStatus(ctx, orderID, status)
+// validateOrderFilters checks the filters shared by order lists and exports
+func validateOrderFilters(filters *models.OrderListFilters) error {
+	for _, status := range filters.Status {
+		if !status.IsValid() {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidOrderQuery, status)
+		}
+	}
+	for _, status := range filters.PaymentStatus {
+		switch status {
+		case models.PaymentStatusPending, models.PaymentStatusPaid, models.PaymentStatusFailed, models.PaymentStatusRefunded:
+		default:
+			return fmt.Errorf("%w: unknown payment status %q", ErrInvalidOrderQuery, status)
+		}
+	}
+	switch {
+	case filters.DateFrom != nil && filters.DateTo != nil && filters.DateFrom.After(*filters.DateTo):
+		return fmt.Errorf("%w: dateFrom must not be after dateTo", ErrInvalidOrderQuery)
+	case filters.MinFare != nil && filters.MaxFare != nil && *filters.MinFare > *filters.MaxFare:
+		return fmt.Errorf("%w: minFare must not be above maxFare", ErrInvalidOrderQuery)
+	case filters.SortOrder != "" && filters.SortOrder != "asc" && filters.SortOrder != "desc":
+		return fmt.Errorf("%w: sortOrder must be asc or desc", ErrInvalidOrderQuery)
+	case filters.SortBy != "" && !slices.Contains(models.OrderSortFields, filters.SortBy):
+		return fmt.Errorf("%w: sortBy must be one of %s", ErrInvalidOrderQuery, strings.Join(models.OrderSortFields, ", "))
+	case len(filters.Metadata) > maxMetadataFilters:
+		return fmt.Errorf("%w: at most %d metadata filters can be combined", ErrInvalidOrderQuery, maxMetadataFilters)
+	}
+	for key := range filters.Metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("%w: metadata.%s is not a valid metadata key", ErrInvalidOrderQuery, key)
+		}
+	}
+	return nil
 }
 
-func (s *OrderService) Accept(ctx context.Context, orderID uuid.UUID) error {
-	return s.repo.UpdateStatus(ctx, orderID, models.OrderStatusAccepted)
+// UpdateStatus moves one of a courier's orders to a new status
+func (s *OrderService) UpdateStatus(ctx context.Context, courierID, orderID uuid.UUID, status models.OrderStatus, note string) (*models.Order, error) {
+	order, err := s.repo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return s.stateMachine.TransitionOrder(ctx, order, status, models.StatusActorCourier, note)
 }
 
-func (s *OrderService) Decline(ctx context.Context, orderID uuid.UUID) error {
-	return s.repo.UpdateStatus(ctx, orderID, models.OrderStatusDeclined)
+// UpdateStatusFromWebhook applies a status reported by the courier platform's delivery webhook
+func (s *OrderService) UpdateStatusFromWebhook(ctx context.Context, order *models.Order, status models.OrderStatus, event string) (*models.Order, error) {
+	return s.stateMachine.TransitionOrder(ctx, order, status, models.StatusActorWebhook, event)
 }
 
 func (s *OrderService) GetDailyStats(ctx context.Context, courierID uuid.UUID) (map[string]interface{}, error) {
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// InvalidTransitionError is returned when an order cannot move to the requested status.
+// Reason is set when the transition is legal in general but was rejected by a guard.
+type InvalidTransitionError struct {
+	From   models.OrderStatus
+	To     models.OrderStatus
+	Reason error
+}
+
+func (e *InvalidTransitionError) Error() string {
+	if e.Reason != nil {
+		return fmt.Sprintf("cannot change order status from %s to %s: %v", e.From, e.To, e.Reason)
+	}
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
+func (e *InvalidTransitionError) Unwrap() error {
+	return e.Reason
+}
+
+// ErrConcurrentStatusChange is returned when the order changed status while a transition was in progress
+var ErrConcurrentStatusChange = errors.New("order status was changed by another request, please retry")
+
+// ErrCancellationRequired is returned when an order is moved to cancelled without the cancellation
+// workflow, which records who cancelled and settles fees, refunds and the courier's wallet
+var ErrCancellationRequired = errors.New("orders can only be cancelled through the cancellation endpoints")
+
+// TransitionHook is called after an order has successfully changed status.
+// The order reflects the state after the transition.
+type TransitionHook func(ctx context.Context, order *models.Order, change models.StatusChange)
+
+// TransitionGuard is called before an order changes status and can veto the change by returning an error
+type TransitionGuard func(ctx context.Context, order *models.Order, to models.OrderStatus, actor string) error
+
+// OrderStateMachine enforces legal order status transitions and records them in the status history
+type OrderStateMachine struct {
+	repo   *repository.OrderRepository
+	guards []TransitionGuard
+	hooks  []TransitionHook
+}
+
+// NewOrderStateMachine creates a new order state machine
+func NewOrderStateMachine(repo *repository.OrderRepository) *OrderStateMachine {
+	return &OrderStateMachine{repo: repo}
+}
+
+// BeforeTransition registers a guard that runs before every transition
+func (m *OrderStateMachine) BeforeTransition(guard TransitionGuard) {
+	m.guards = append(m.guards, guard)
+}
+
+// OnTransition registers a hook that runs after every successful transition
+func (m *OrderStateMachine) OnTransition(hook TransitionHook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// Transition moves an order to a new status if the transition is legal
+func (m *OrderStateMachine) Transition(ctx context.Context, orderID uuid.UUID, to models.OrderStatus, actor, note string) (*models.Order, error) {
+	order, err := m.repo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	return m.TransitionOrder(ctx, order, to, actor, note)
+}
+
+// TransitionOrder moves an already loaded order to a new status if the transition is legal.
+// The order is updated in place.
+func (m *OrderStateMachine) TransitionOrder(ctx context.Context, order *models.Order, to models.OrderStatus, actor, note string) (*models.Order, error) {
+	return m.TransitionOrderWith(ctx, order, to, actor, note, nil)
+}
+
+// TransitionOrderWith is TransitionOrder with the status change written by persist, so other writes
+// can share its database transaction. persist must only apply the change while the order is still in
+// its loaded status, returning repository.ErrOrderStatusChanged otherwise. Guards and hooks run as
+// for any transition. Cancellations are only accepted this way, from CancellationService.
+func (m *OrderStateMachine) TransitionOrderWith(
+	ctx context.Context,
+	order *models.Order,
+	to models.OrderStatus,
+	actor, note string,
+	persist func(ctx context.Context, change models.StatusChange) error,
+) (*models.Order, error) {
+	if !to.IsValid() || !order.Status.CanTransitionTo(to) {
+		return nil, &InvalidTransitionError{From: order.Status, To: to}
+	}
+	if to == models.OrderStatusCancelled && persist == nil {
+		return nil, &InvalidTransitionError{From: order.Status, To: to, Reason: ErrCancellationRequired}
+	}
+	for _, guard := range m.guards {
+		if err := guard(ctx, order, to, actor); err != nil {
+			return nil, &InvalidTransitionError{From: order.Status, To: to, Reason: err}
+		}
+	}
+
+	change := models.StatusChange{
+		Status:    to,
+		Timestamp: time.Now(),
+		Note:      note,
+		Actor:     actor,
+	}
+
+	requirePIN := order.DeliveryPINRequired
+	if to == models.OrderStatusInTransit {
+		requirePIN = order.NeedsDeliveryPIN()
+	}
+	if persist == nil {
+		persist = func(ctx context.Context, change models.StatusChange) error {
+			return m.repo.TransitionStatus(ctx, order.ID, order.CourierID, order.Status, change, requirePIN)
+		}
+	}
+	if err := persist(ctx, change); err != nil {
+		if errors.Is(err, repository.ErrOrderStatusChanged) {
+			return nil, ErrConcurrentStatusChange
+		}
+		return nil, err
+	}
+
+	order.Status = to
+	order.DeliveryPINRequired = requirePIN
+	order.StatusHistory = append(order.StatusHistory, change)
+	order.UpdatedAt = change.Timestamp
+	switch to {
+	case models.OrderStatusPickedUp:
+		order.ActualPickup = &change.Timestamp
+	case models.OrderStatusDelivered:
+		order.ActualDelivery = &change.Timestamp
+	}
+
+	log.Printf("🔄 Order %s: %s (by %s)", order.OrderNumber, to, actor)
+
+	for _, hook := range m.hooks {
+		hook(ctx, order, change)
+	}
+
+	return order, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+func TestOrderStateMachineTransitions(t *testing.T) {
+	statuses := []models.OrderStatus{
+		models.OrderStatusScheduled, models.OrderStatusPending, models.OrderStatusAccepted, models.OrderStatusDeclined,
+		models.OrderStatusPickedUp, models.OrderStatusInTransit, models.OrderStatusDelivered, models.OrderStatusCancelled,
+		models.OrderStatusFailed, models.OrderStatusReattemptScheduled, models.OrderStatusReturning, models.OrderStatusReturned,
+		"lost",
+	}
+	allowed := map[[2]models.OrderStatus]bool{
+		{models.OrderStatusScheduled, models.OrderStatusPending}:            true,
+		{models.OrderStatusScheduled, models.OrderStatusCancelled}:          true,
+		{models.OrderStatusPending, models.OrderStatusAccepted}:             true,
+		{models.OrderStatusPending, models.OrderStatusDeclined}:             true,
+		{models.OrderStatusPending, models.OrderStatusCancelled}:            true,
+		{models.OrderStatusAccepted, models.OrderStatusPickedUp}:            true,
+		{models.OrderStatusAccepted, models.OrderStatusCancelled}:           true,
+		{models.OrderStatusPickedUp, models.OrderStatusInTransit}:           true,
+		{models.OrderStatusPickedUp, models.OrderStatusReattemptScheduled}:  true,
+		{models.OrderStatusPickedUp, models.OrderStatusReturning}:           true,
+		{models.OrderStatusPickedUp, models.OrderStatusFailed}:              true,
+		{models.OrderStatusPickedUp, models.OrderStatusCancelled}:           true,
+		{models.OrderStatusInTransit, models.OrderStatusDelivered}:          true,
+		{models.OrderStatusInTransit, models.OrderStatusReattemptScheduled}: true,
+		{models.OrderStatusInTransit, models.OrderStatusReturning}:          true,
+		{models.OrderStatusInTransit, models.OrderStatusFailed}:             true,
+		{models.OrderStatusReattemptScheduled, models.OrderStatusInTransit}: true,
+		{models.OrderStatusReattemptScheduled, models.OrderStatusReturning}: true,
+		{models.OrderStatusReturning, models.OrderStatusReturned}:           true,
+		{models.OrderStatusReturning, models.OrderStatusFailed}:             true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]models.OrderStatus{from, to}]
+			t.Run(string(from)+" to "+string(to), func(t *testing.T) {
+				m := NewOrderStateMachine(nil)
+				order := &models.Order{Status: from, OrderNumber: "NYG-TEST"}
+				persisted := false
+				persist := func(ctx context.Context, change models.StatusChange) error {
+					persisted = true
+					return nil
+				}
+
+				_, err := m.TransitionOrderWith(context.Background(), order, to, models.StatusActorSystem, "", persist)
+				if !want {
+					var transitionErr *InvalidTransitionError
+					if !errors.As(err, &transitionErr) || transitionErr.From != from || transitionErr.To != to {
+						t.Fatalf("TransitionOrderWith() error = %v, want an invalid transition from %s to %s", err, from, to)
+					}
+					if persisted || order.Status != from {
+						t.Errorf("rejected transition was applied (persisted %v, status %s)", persisted, order.Status)
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("TransitionOrderWith() error = %v", err)
+				}
+				if !persisted || order.Status != to {
+					t.Errorf("transition not applied (persisted %v, status %s)", persisted, order.Status)
+				}
+				if len(order.StatusHistory) != 1 || order.StatusHistory[0].Status != to || order.StatusHistory[0].Actor != models.StatusActorSystem {
+					t.Errorf("status history = %+v, want one %s change by %s", order.StatusHistory, to, models.StatusActorSystem)
+				}
+			})
+		}
+	}
+}
+
+func TestOrderStateMachineGuards(t *testing.T) {
+	errBlocked := errors.New("blocked")
+	m := NewOrderStateMachine(nil)
+
+	var guarded []string
+	m.BeforeTransition(func(ctx context.Context, order *models.Order, to models.OrderStatus, actor string) error {
+		guarded = append(guarded, actor)
+		if to == models.OrderStatusInTransit {
+			return errBlocked
+		}
+		return nil
+	})
+	var hooked []models.StatusChange
+	m.OnTransition(func(ctx context.Context, order *models.Order, change models.StatusChange) {
+		hooked = append(hooked, change)
+	})
+
+	persisted := 0
+	persist := func(ctx context.Context, change models.StatusChange) error {
+		persisted++
+		return nil
+	}
+
+	order := &models.Order{Status: models.OrderStatusPickedUp}
+	_, err := m.TransitionOrderWith(context.Background(), order, models.OrderStatusInTransit, models.StatusActorCourier, "", persist)
+	var transitionErr *InvalidTransitionError
+	if !errors.As(err, &transitionErr) || !errors.Is(err, errBlocked) {
+		t.Fatalf("TransitionOrderWith() error = %v, want the guard's error", err)
+	}
+	if persisted != 0 || len(hooked) != 0 || order.Status != models.OrderStatusPickedUp {
+		t.Errorf("vetoed transition ran (persisted %d, hooks %d, status %s)", persisted, len(hooked), order.Status)
+	}
+
+	if _, err := m.TransitionOrderWith(context.Background(), order, models.OrderStatusFailed, models.StatusActorCourier, "Parcel damaged", persist); err != nil {
+		t.Fatalf("TransitionOrderWith() error = %v", err)
+	}
+	if persisted != 1 || len(hooked) != 1 || hooked[0].Status != models.OrderStatusFailed || hooked[0].Note != "Parcel damaged" {
+		t.Errorf("allowed transition: persisted %d, hooks %+v", persisted, hooked)
+	}
+	if len(guarded) != 2 || guarded[0] != models.StatusActorCourier {
+		t.Errorf("guard calls = %v, want two by %s", guarded, models.StatusActorCourier)
+	}
+}
+
+func TestOrderStateMachineCancellationRequiresPersist(t *testing.T) {
+	m := NewOrderStateMachine(nil)
+	order := &models.Order{Status: models.OrderStatusPending}
+
+	_, err := m.TransitionOrder(context.Background(), order, models.OrderStatusCancelled, models.StatusActorWebhook, "")
+	if !errors.Is(err, ErrCancellationRequired) {
+		t.Fatalf("TransitionOrder() error = %v, want ErrCancellationRequired", err)
+	}
+	if order.Status != models.OrderStatusPending {
+		t.Errorf("status = %s, want pending", order.Status)
+	}
+}
+
+func TestOrderStateMachineConcurrentChange(t *testing.T) {
+	m := NewOrderStateMachine(nil)
+	order := &models.Order{Status: models.OrderStatusAccepted}
+	persist := func(ctx context.Context, change models.StatusChange) error {
+		return repository.ErrOrderStatusChanged
+	}
+
+	_, err := m.TransitionOrderWith(context.Background(), order, models.OrderStatusPickedUp, models.StatusActorCourier, "", persist)
+	if !errors.Is(err, ErrConcurrentStatusChange) {
+		t.Fatalf("TransitionOrderWith() error = %v, want ErrConcurrentStatusChange", err)
+	}
+	if order.Status != models.OrderStatusAccepted || order.ActualPickup != nil {
+		t.Errorf("order changed after a failed persist: status %s", order.Status)
+	}
+}
+
+func TestOrderStateMachineDeliveryPINRequirement(t *testing.T) {
+	tests := []struct {
+		name  string
+		order models.Order
+		want  bool
+	}{
+		{"standard order", models.Order{OrderType: models.OrderTypeStandard, CustomerPhone: "+260971234567"}, true},
+		{"multi-stop order", models.Order{OrderType: models.OrderTypeMultiStop, CustomerPhone: "+260971234567"}, false},
+		{"return leg with a phone", models.Order{OrderType: models.OrderTypeReturn, CustomerPhone: "+260971234567"}, true},
+		{"return leg without a phone", models.Order{OrderType: models.OrderTypeReturn}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewOrderStateMachine(nil)
+			order := tt.order
+			order.Status = models.OrderStatusPickedUp
+			persist := func(ctx context.Context, change models.StatusChange) error { return nil }
+
+			if _, err := m.TransitionOrderWith(context.Background(), &order, models.OrderStatusInTransit, models.StatusActorCourier, "", persist); err != nil {
+				t.Fatalf("TransitionOrderWith() error = %v", err)
+			}
+			if order.DeliveryPINRequired != tt.want {
+				t.Errorf("DeliveryPINRequired = %v, want %v", order.DeliveryPINRequired, tt.want)
+			}
+		})
+	}
+}
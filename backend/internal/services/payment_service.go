@@ -114,8 +114,18 @@ func (s *PaymentService) verifyCashPayment(ctx context.Context, order *models.Or
 		PaymentMethod: "cash",
 	}
 
-	// Cash is considered paid if delivery is confirmed with proof
-	if order.DeliveryProofURL != "" || order.RecipientName != "" || order.SignatureURL != "" {
+	// Cash is considered paid once the customer's delivery PIN was confirmed at handoff.
+	// Orders that were never issued a PIN fall back to uploaded photo or signature proof.
+	// Multi-stop orders are only delivered once every delivered stop has photo proof.
+	confirmed := order.DeliveryPINVerifiedAt != nil
+	switch {
+	case order.OrderType == models.OrderTypeMultiStop:
+		confirmed = order.Status == models.OrderStatusDelivered
+	case order.DeliveryPINHash == "":
+		confirmed = order.DeliveryProofURL != "" || order.SignatureURL != ""
+	}
+
+	if confirmed {
 		verification.IsPaid = true
 		verification.AmountPaid = order.TotalFare
 		now := time.Now()
@@ -127,7 +137,7 @@ func (s *PaymentService) verifyCashPayment(ctx context.Context, order *models.Or
 		}
 	} else {
 		verification.IsPaid = false
-		verification.Error = "cash payment not confirmed - missing delivery proof"
+		verification.Error = "cash payment not confirmed - delivery handoff not verified"
 	}
 
 	return verification, nil
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// pickupSchedulerBatch caps how many orders are handled per step on each run
+const pickupSchedulerBatch = 100
+
+// PickupScheduler drives orders with a scheduled pickup: it reminds the courier ahead of time,
+// moves dormant orders into the active queue when due, and flags pickups that have not started.
+// Every step claims its orders in Postgres, so several instances can run it side by side.
+type PickupScheduler struct {
+	orderRepo    *repository.OrderRepository
+	stateMachine *OrderStateMachine
+	notification *NotificationService
+	webhooks     *StoreWebhookService
+	cfg          *config.Config
+}
+
+// NewPickupScheduler creates a new scheduled pickup scheduler
+func NewPickupScheduler(
+	orderRepo *repository.OrderRepository,
+	stateMachine *OrderStateMachine,
+	notification *NotificationService,
+	webhooks *StoreWebhookService,
+	cfg *config.Config,
+) *PickupScheduler {
+	return &PickupScheduler{
+		orderRepo:    orderRepo,
+		stateMachine: stateMachine,
+		notification: notification,
+		webhooks:     webhooks,
+		cfg:          cfg,
+	}
+}
+
+// Run checks scheduled pickups every interval until ctx is cancelled
+func (s *PickupScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunOnce(ctx, time.Now())
+		}
+	}
+}
+
+// RunOnce performs a single pass of reminders, activations and overdue checks
+func (s *PickupScheduler) RunOnce(ctx context.Context, now time.Time) {
+	s.sendReminders(ctx, now)
+	s.activateDue(ctx, now)
+	s.flagOverdue(ctx, now)
+}
+
+// sendReminders notifies couriers of pickups starting within the reminder lead time
+func (s *PickupScheduler) sendReminders(ctx context.Context, now time.Time) {
+	ids, err := s.orderRepo.ClaimPickupReminders(ctx, now.Add(s.cfg.ScheduleReminderLead), pickupSchedulerBatch)
+	if err != nil {
+		log.Printf("⚠️ Failed to claim pickup reminders: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		order, err := s.orderRepo.GetByID(ctx, id)
+		if err != nil {
+			log.Printf("⚠️ Failed to load order %s for pickup reminder: %v", id, err)
+			continue
+		}
+		if err := s.notification.SendPickupReminder(ctx, order); err != nil {
+			log.Printf("⚠️ Failed to send pickup reminder for order %s: %v", order.OrderNumber, err)
+			continue
+		}
+		log.Printf("⏰ Pickup reminder sent for order %s (pickup at %s)", order.OrderNumber, order.ScheduledPickup.Format(time.RFC3339))
+	}
+}
+
+// activateDue moves dormant orders into the active queue once their pickup is within the activation lead
+func (s *PickupScheduler) activateDue(ctx context.Context, now time.Time) {
+	ids, err := s.orderRepo.ListDueScheduled(ctx, now.Add(s.cfg.ScheduleActivationLead), pickupSchedulerBatch)
+	if err != nil {
+		log.Printf("⚠️ Failed to list due scheduled orders: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		s.activate(ctx, id)
+	}
+}
+
+func (s *PickupScheduler) activate(ctx context.Context, id uuid.UUID) {
+	order, err := s.stateMachine.Transition(ctx, id, models.OrderStatusPending, models.StatusActorSystem, "Scheduled pickup is due")
+	if err != nil {
+		// Another instance activated or cancelled it first
+		var transitionErr *InvalidTransitionError
+		if errors.Is(err, ErrConcurrentStatusChange) || errors.As(err, &transitionErr) {
+			return
+		}
+		log.Printf("⚠️ Failed to activate scheduled order %s: %v", id, err)
+		return
+	}
+
+	if err := s.notification.SendNewOrder(ctx, order.CourierID.String(), order.ID.String(), order.CustomerName); err != nil {
+		log.Printf("⚠️ Failed to notify courier of activated order %s: %v", order.OrderNumber, err)
+	}
+	log.Printf("🗓️ Scheduled order %s activated", order.OrderNumber)
+}
+
+// flagOverdue flags orders whose pickup time has passed without the driver starting tracking
+func (s *PickupScheduler) flagOverdue(ctx context.Context, now time.Time) {
+	ids, err := s.orderRepo.ClaimOverduePickups(ctx, now, pickupSchedulerBatch)
+	if err != nil {
+		log.Printf("⚠️ Failed to claim overdue pickups: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		order, err := s.orderRepo.GetByID(ctx, id)
+		if err != nil {
+			log.Printf("⚠️ Failed to load overdue order %s: %v", id, err)
+			continue
+		}
+
+		log.Printf("🚩 Scheduled pickup overdue for order %s (due %s, status %s)",
+			order.OrderNumber, order.ScheduledPickup.Format(time.RFC3339), order.Status)
+
+		if err := s.notification.SendPickupOverdue(ctx, order); err != nil {
+			log.Printf("⚠️ Failed to notify courier of overdue order %s: %v", order.OrderNumber, err)
+		}
+		s.webhooks.Send(order, WebhookPickupOverdue, map[string]interface{}{
+			"status":          order.Status,
+			"scheduledPickup": order.ScheduledPickup,
+			"flaggedAt":       order.PickupOverdueAt,
+		})
+	}
+}
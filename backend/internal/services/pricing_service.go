@@ -1,6 +1,7 @@
 package services
 
 import (
+	"fmt"
 	"math"
 
 	"nyengo-deliveries/internal/config"
@@ -15,9 +16,24 @@ func NewPricingService(cfg *config.Config) *PricingService {
 	return &PricingService{cfg: cfg}
 }
 
+// stopDwellMinutes is the time allowed at each drop-off on a multi-stop route
+const stopDwellMinutes = 5
+
 func (s *PricingService) CalculateEstimate(req *models.PriceEstimateRequest) (*models.PriceEstimateResponse, error) {
 	distance := s.CalculateDistance(req.PickupLatitude, req.PickupLongitude, req.DeliveryLatitude, req.DeliveryLongitude)
 	duration := int(distance / 30 * 60)
+
+	// Multi-stop routes are priced over every leg, plus a flat fee per extra drop-off
+	var legs []models.RouteLeg
+	stopFare := 0.0
+	if len(req.Stops) > 0 {
+		if len(req.Stops) > s.cfg.MaxStopsPerOrder {
+			return nil, fmt.Errorf("%w: a route can have at most %d stops", ErrInvalidOrder, s.cfg.MaxStopsPerOrder)
+		}
+		legs, distance = s.CalculateRoute(req.PickupLatitude, req.PickupLongitude, req.Stops)
+		duration = int(distance/30*60) + (len(req.Stops)-1)*stopDwellMinutes
+		stopFare = float64(len(req.Stops)-1) * s.cfg.MultiStopFeePerStop
+	}
 	if duration < 10 {
 		duration = 10
 	}
@@ -41,7 +57,7 @@ func (s *PricingService) CalculateEstimate(req *models.PriceEstimateRequest) (*m
 		surgeFare = (baseFare + distanceFare) * (s.cfg.SurgePricingMult - 1)
 	}
 
-	subTotal := baseFare + distanceFare + weightFare + fragileFare + expressFare + surgeFare
+	subTotal := baseFare + distanceFare + weightFare + fragileFare + expressFare + surgeFare + stopFare
 	if subTotal < s.cfg.MinimumFare {
 		subTotal = s.cfg.MinimumFare
 	}
@@ -58,6 +74,7 @@ func (s *PricingService) CalculateEstimate(req *models.PriceEstimateRequest) (*m
 		Distance: math.Round(distance*100) / 100, Duration: duration,
 		BaseFare: baseFare, DistanceFare: distanceFare, WeightFare: weightFare,
 		FragileFare: fragileFare, ExpressFare: expressFare, SurgeFare: surgeFare,
+		StopFare: stopFare, Legs: legs,
 		SubTotal: subTotal, PlatformFee: platformFee, TotalFare: totalFare,
 		FormattedTotal: s.cfg.FormatCurrency(totalFare), PricingTier: tier,
 		IsSurgeActive: s.cfg.SurgePricingMult > 1.0, SurgeMultiplier: s.cfg.SurgePricingMult,
@@ -82,6 +99,26 @@ func (s *PricingService) CalculateDistance(lat1, lon1, lat2, lon2 float64) float
 	return R * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a)) * 1.3
 }
 
+// CalculateRoute returns the legs from the pickup through each stop in order and the total distance
+func (s *PricingService) CalculateRoute(pickupLat, pickupLon float64, stops []models.RoutePoint) ([]models.RouteLeg, float64) {
+	legs := make([]models.RouteLeg, len(stops))
+	total := 0.0
+	fromLat, fromLon := pickupLat, pickupLon
+
+	for i, stop := range stops {
+		distance := math.Round(s.CalculateDistance(fromLat, fromLon, stop.Latitude, stop.Longitude)*100) / 100
+		duration := int(distance / 30 * 60)
+		if duration < 1 {
+			duration = 1
+		}
+		legs[i] = models.RouteLeg{Sequence: i + 1, Distance: distance, Duration: duration}
+		total += distance
+		fromLat, fromLon = stop.Latitude, stop.Longitude
+	}
+
+	return legs, total
+}
+
 // IsLocalDelivery determines if a delivery is local based on distance threshold
 func (s *PricingService) IsLocalDelivery(distance float64) bool {
 	return distance < s.cfg.LocalDistanceThreshold
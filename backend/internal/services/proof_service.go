@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+	"nyengo-deliveries/internal/storage"
+	"nyengo-deliveries/internal/utils"
+)
+
+// ErrProofNotAllowed is returned when proof is uploaded for an order that is not out for delivery
+var ErrProofNotAllowed = errors.New("proof of delivery can only be uploaded for orders in transit")
+
+// ErrInvalidProof is returned when uploaded proof fails validation
+var ErrInvalidProof = errors.New("invalid proof of delivery")
+
+// ErrNoDeliveryProof is returned when an order has no proof of delivery yet
+var ErrNoDeliveryProof = errors.New("no proof of delivery has been uploaded for this order")
+
+// ProofUpload contains the files submitted by a driver at handoff
+type ProofUpload struct {
+	Photo         []byte
+	Signature     []byte
+	RecipientName string
+}
+
+// ProofService stores proof-of-delivery images and issues download links
+type ProofService struct {
+	orderRepo *repository.OrderRepository
+	store     storage.BlobStore
+	cfg       *config.Config
+}
+
+// NewProofService creates a new proof-of-delivery service
+func NewProofService(orderRepo *repository.OrderRepository, store storage.BlobStore, cfg *config.Config) *ProofService {
+	return &ProofService{orderRepo: orderRepo, store: store, cfg: cfg}
+}
+
+// UploadProof validates and stores the delivery photo and signature for an order
+// assigned to the courier, then records the stored keys on the order
+func (s *ProofService) UploadProof(ctx context.Context, courierID, orderID uuid.UUID, upload *ProofUpload) (*models.DeliveryProof, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	if order.Status != models.OrderStatusInTransit {
+		return nil, ErrProofNotAllowed
+	}
+
+	photoKey, signatureKey, err := s.storeUpload(ctx, order, fmt.Sprintf("proofs/%s", order.ID), upload)
+	if err != nil {
+		return nil, err
+	}
+
+	recipientName := strings.TrimSpace(upload.RecipientName)
+	if err := s.orderRepo.UpdateDeliveryProof(ctx, order.ID, photoKey, recipientName, signatureKey); err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	order.DeliveryProofURL = photoKey
+	order.RecipientName = recipientName
+	order.SignatureURL = signatureKey
+
+	return s.buildProof(ctx, order)
+}
+
+// UploadStopProof validates and stores the photo and signature for one stop of a multi-stop order
+func (s *ProofService) UploadStopProof(ctx context.Context, courierID, orderID, stopID uuid.UUID, upload *ProofUpload) (*models.DeliveryProof, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	stop, err := findStop(order, stopID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusInTransit {
+		return nil, ErrProofNotAllowed
+	}
+	if stop.IsFinal() {
+		return nil, ErrStopAlreadyCompleted
+	}
+
+	photoKey, signatureKey, err := s.storeUpload(ctx, order, fmt.Sprintf("proofs/%s/stops/%s", order.ID, stop.ID), upload)
+	if err != nil {
+		return nil, err
+	}
+
+	receivedBy := strings.TrimSpace(upload.RecipientName)
+	if err := s.orderRepo.UpdateStopProof(ctx, stop.ID, photoKey, receivedBy, signatureKey); err != nil {
+		return nil, fmt.Errorf("failed to update stop: %w", err)
+	}
+
+	stop.DeliveryProofURL = photoKey
+	stop.ReceivedBy = receivedBy
+	stop.SignatureURL = signatureKey
+
+	return s.buildStopProof(ctx, order, stop)
+}
+
+// storeUpload validates the proof images and stores them under the given key prefix,
+// returning the photo and signature keys
+func (s *ProofService) storeUpload(ctx context.Context, order *models.Order, keyPrefix string, upload *ProofUpload) (string, string, error) {
+	if len(upload.Photo) == 0 {
+		return "", "", fmt.Errorf("%w: a delivery photo is required", ErrInvalidProof)
+	}
+	if order.RequiresSignature && len(upload.Signature) == 0 {
+		return "", "", fmt.Errorf("%w: this order requires a recipient signature", ErrInvalidProof)
+	}
+
+	photoType, err := utils.ValidateImage(upload.Photo, s.cfg.MaxUploadSize, s.cfg.MaxImagePixels)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: photo: %v", ErrInvalidProof, err)
+	}
+	var signatureType string
+	if len(upload.Signature) > 0 {
+		if signatureType, err = utils.ValidateImage(upload.Signature, s.cfg.MaxUploadSize, s.cfg.MaxImagePixels); err != nil {
+			return "", "", fmt.Errorf("%w: signature: %v", ErrInvalidProof, err)
+		}
+	}
+
+	thumbnail, err := utils.GenerateThumbnail(upload.Photo, utils.ThumbnailMaxSize, s.cfg.MaxImagePixels)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: photo: %v", ErrInvalidProof, err)
+	}
+
+	// Keys are versioned by upload time so a re-upload never overwrites a link already handed out
+	prefix := fmt.Sprintf("%s/%d", keyPrefix, time.Now().Unix())
+	photoKey := prefix + "-photo" + utils.ImageExtension(photoType)
+
+	if err := s.store.Put(ctx, photoKey, upload.Photo, photoType); err != nil {
+		return "", "", fmt.Errorf("failed to store photo: %w", err)
+	}
+	if err := s.store.Put(ctx, thumbnailKey(photoKey), thumbnail, utils.ContentTypeJPEG); err != nil {
+		return "", "", fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+
+	var signatureKey string
+	if len(upload.Signature) > 0 {
+		signatureKey = prefix + "-signature" + utils.ImageExtension(signatureType)
+		if err := s.store.Put(ctx, signatureKey, upload.Signature, signatureType); err != nil {
+			return "", "", fmt.Errorf("failed to store signature: %w", err)
+		}
+	}
+
+	return photoKey, signatureKey, nil
+}
+
+// GetStopProof returns fresh download links for the proof of delivery of one stop
+func (s *ProofService) GetStopProof(ctx context.Context, orderID, stopID uuid.UUID) (*models.DeliveryProof, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	stop, err := findStop(order, stopID)
+	if err != nil {
+		return nil, err
+	}
+	if stop.DeliveryProofURL == "" && stop.SignatureURL == "" {
+		return nil, ErrNoDeliveryProof
+	}
+	return s.buildStopProof(ctx, order, stop)
+}
+
+// GetProof returns fresh download links for an order's proof of delivery
+func (s *ProofService) GetProof(ctx context.Context, orderID uuid.UUID) (*models.DeliveryProof, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	return s.proofForOrder(ctx, order)
+}
+
+// GetCourierProof returns the proof of delivery for an order assigned to the courier
+func (s *ProofService) GetCourierProof(ctx context.Context, courierID, orderID uuid.UUID) (*models.DeliveryProof, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return s.proofForOrder(ctx, order)
+}
+
+func (s *ProofService) proofForOrder(ctx context.Context, order *models.Order) (*models.DeliveryProof, error) {
+	if order.DeliveryProofURL == "" && order.SignatureURL == "" {
+		return nil, ErrNoDeliveryProof
+	}
+	return s.buildProof(ctx, order)
+}
+
+// buildProof signs download links for the stored proof objects
+func (s *ProofService) buildProof(ctx context.Context, order *models.Order) (*models.DeliveryProof, error) {
+	proof := &models.DeliveryProof{
+		OrderID:       order.ID,
+		OrderNumber:   order.OrderNumber,
+		RecipientName: order.RecipientName,
+		ExpiresAt:     time.Now().Add(s.cfg.SignedURLTTL),
+	}
+	return s.signProof(ctx, proof, order.DeliveryProofURL, order.SignatureURL)
+}
+
+// buildStopProof signs download links for the proof objects of a single stop
+func (s *ProofService) buildStopProof(ctx context.Context, order *models.Order, stop *models.OrderStop) (*models.DeliveryProof, error) {
+	proof := &models.DeliveryProof{
+		OrderID:       order.ID,
+		OrderNumber:   order.OrderNumber,
+		StopID:        &stop.ID,
+		RecipientName: stop.ReceivedBy,
+		ExpiresAt:     time.Now().Add(s.cfg.SignedURLTTL),
+	}
+	return s.signProof(ctx, proof, stop.DeliveryProofURL, stop.SignatureURL)
+}
+
+func (s *ProofService) signProof(ctx context.Context, proof *models.DeliveryProof, photoKey, signatureKey string) (*models.DeliveryProof, error) {
+	var err error
+	if photoKey != "" {
+		if proof.PhotoURL, err = s.signedURL(ctx, photoKey); err != nil {
+			return nil, err
+		}
+		if !isExternalURL(photoKey) {
+			if proof.ThumbnailURL, err = s.signedURL(ctx, thumbnailKey(photoKey)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if signatureKey != "" {
+		if proof.SignatureURL, err = s.signedURL(ctx, signatureKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return proof, nil
+}
+
+// signedURL signs a stored key; values recorded before blob storage existed are returned as-is
+func (s *ProofService) signedURL(ctx context.Context, key string) (string, error) {
+	if isExternalURL(key) {
+		return key, nil
+	}
+	return s.store.SignedURL(ctx, key, s.cfg.SignedURLTTL)
+}
+
+// thumbnailKey derives the thumbnail key stored alongside a photo
+func thumbnailKey(photoKey string) string {
+	return strings.TrimSuffix(photoKey, path.Ext(photoKey)) + "-thumb.jpg"
+}
+
+func isExternalURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// ErrInvalidRating is returned when a rating is outside 1-5 stars
+var ErrInvalidRating = errors.New("rating must be between 1 and 5 stars")
+
+// ErrInvalidFeedback is returned when feedback text is too long
+var ErrInvalidFeedback = fmt.Errorf("feedback must be at most %d characters", maxFeedbackLength)
+
+// ErrOrderNotDelivered is returned when rating an order that has not been delivered
+var ErrOrderNotDelivered = errors.New("only delivered orders can be rated")
+
+// ErrOrderAlreadyRated is returned when an order already has a rating
+var ErrOrderAlreadyRated = errors.New("this order has already been rated")
+
+// maxFeedbackLength caps free-text feedback stored with a rating
+const maxFeedbackLength = 1000
+
+// RatingService records customer ratings for delivered orders
+type RatingService struct {
+	orderRepo    *repository.OrderRepository
+	stats        *CourierStatsService
+	notification *NotificationService
+}
+
+// NewRatingService creates a new rating service
+func NewRatingService(orderRepo *repository.OrderRepository, stats *CourierStatsService, notification *NotificationService) *RatingService {
+	return &RatingService{orderRepo: orderRepo, stats: stats, notification: notification}
+}
+
+// RateOrder stores a 1-5 star rating for a delivered order and refreshes the courier's aggregates.
+// ratedBy is the party submitting the rating (store or customer); each order can only be rated once.
+func (s *RatingService) RateOrder(ctx context.Context, orderID uuid.UUID, req *models.RateOrderRequest, ratedBy string) (*models.Order, error) {
+	if req.Rating < 1 || req.Rating > 5 {
+		return nil, ErrInvalidRating
+	}
+	feedback := strings.TrimSpace(req.Feedback)
+	if len(feedback) > maxFeedbackLength {
+		return nil, ErrInvalidFeedback
+	}
+
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if order.CustomerRating != nil {
+		return nil, ErrOrderAlreadyRated
+	}
+	if order.Status != models.OrderStatusDelivered {
+		return nil, ErrOrderNotDelivered
+	}
+
+	if err := s.orderRepo.SaveRating(ctx, order.ID, req.Rating, feedback, ratedBy); err != nil {
+		// Lost a race with another rating submission
+		if errors.Is(err, repository.ErrOrderNotRateable) {
+			return nil, ErrOrderAlreadyRated
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	order.CustomerRating = &req.Rating
+	order.CustomerFeedback = feedback
+	order.RatedBy = ratedBy
+	order.RatedAt = &now
+
+	if _, err := s.stats.Recompute(ctx, order.CourierID); err != nil {
+		log.Printf("⚠️ Failed to recompute stats for courier %s: %v", order.CourierID, err)
+	}
+
+	if s.notification != nil {
+		_ = s.notification.Send(ctx, "courier:"+order.CourierID.String(), &Notification{
+			Type: "order_rated", Title: "New Rating",
+			Message: fmt.Sprintf("Order %s was rated %d/5", order.OrderNumber, req.Rating),
+			Data:    map[string]string{"orderId": order.ID.String(), "rating": fmt.Sprint(req.Rating)},
+		})
+	}
+
+	log.Printf("⭐ Order %s rated %d/5 by %s", order.OrderNumber, req.Rating, ratedBy)
+	return order, nil
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"nyengo-deliveries/internal/models"
+)
+
+// ErrInvalidRecurrence is returned when a recurrence rule cannot be parsed
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+// recurrenceHorizonDays bounds how far past the start date, or the time asked about, a rule is expanded
+const recurrenceHorizonDays = 366 * 5
+
+const dateLayout = "2006-01-02"
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// recurrence is a parsed RecurrenceRule ready for expansion
+type recurrence struct {
+	rule      models.RecurrenceRule
+	loc       *time.Location
+	start     time.Time // midnight of the start date in loc
+	end       time.Time // midnight of the end date in loc, zero if open-ended
+	hour      int
+	minute    int
+	weekdays  map[time.Weekday]bool
+	monthDays []int
+}
+
+// parseRecurrence validates a rule and applies its defaults
+func parseRecurrence(rule models.RecurrenceRule, defaultTimezone string) (*recurrence, error) {
+	r := &recurrence{rule: rule}
+
+	timezone := rule.Timezone
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidRecurrence, timezone)
+	}
+	r.loc = loc
+
+	if r.start, err = time.ParseInLocation(dateLayout, rule.StartDate, loc); err != nil {
+		return nil, fmt.Errorf("%w: startDate must be YYYY-MM-DD", ErrInvalidRecurrence)
+	}
+	if rule.EndDate != "" {
+		if r.end, err = time.ParseInLocation(dateLayout, rule.EndDate, loc); err != nil {
+			return nil, fmt.Errorf("%w: endDate must be YYYY-MM-DD", ErrInvalidRecurrence)
+		}
+		if r.end.Before(r.start) {
+			return nil, fmt.Errorf("%w: endDate is before startDate", ErrInvalidRecurrence)
+		}
+	}
+
+	pickup, err := time.Parse("15:04", rule.PickupTime)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pickupTime must be HH:MM", ErrInvalidRecurrence)
+	}
+	r.hour, r.minute = pickup.Hour(), pickup.Minute()
+
+	if r.rule.Interval == 0 {
+		r.rule.Interval = 1
+	}
+	if r.rule.Interval < 0 || r.rule.Count < 0 {
+		return nil, fmt.Errorf("%w: interval and count cannot be negative", ErrInvalidRecurrence)
+	}
+
+	switch rule.Frequency {
+	case models.FrequencyDaily:
+	case models.FrequencyWeekly:
+		r.weekdays = make(map[time.Weekday]bool)
+		for _, code := range rule.ByWeekday {
+			day, ok := weekdayCodes[strings.ToUpper(code)]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown weekday %q", ErrInvalidRecurrence, code)
+			}
+			r.weekdays[day] = true
+		}
+		if len(r.weekdays) == 0 {
+			r.weekdays[r.start.Weekday()] = true
+		}
+	case models.FrequencyMonthly:
+		for _, day := range rule.ByMonthDay {
+			if day == 0 || day < -1 || day > 31 {
+				return nil, fmt.Errorf("%w: byMonthDay must be 1-31 or -1", ErrInvalidRecurrence)
+			}
+		}
+		r.monthDays = rule.ByMonthDay
+		if len(r.monthDays) == 0 {
+			r.monthDays = []int{r.start.Day()}
+		}
+	default:
+		return nil, fmt.Errorf("%w: frequency must be daily, weekly or monthly", ErrInvalidRecurrence)
+	}
+
+	return r, nil
+}
+
+// occurrencesAfter returns up to limit occurrence times strictly after the given time, in order.
+// Open-ended series are scanned from the later of the start date and after's date; Count limits the
+// series as a whole, so occurrences before after still use it up and counted series are scanned from
+// their start date.
+func (r *recurrence) occurrencesAfter(after time.Time, limit int) []time.Time {
+	from := r.start
+	if local := after.In(r.loc); local.After(from) {
+		from = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, r.loc)
+	}
+	horizon := from.AddDate(0, 0, recurrenceHorizonDays)
+
+	day := from
+	if r.rule.Count > 0 {
+		day = r.start
+	}
+
+	var times []time.Time
+	n := 0
+	for ; len(times) < limit; day = day.AddDate(0, 0, 1) {
+		if !r.end.IsZero() && day.After(r.end) {
+			break
+		}
+		if day.After(horizon) {
+			break
+		}
+		if !r.matches(day) {
+			continue
+		}
+
+		n++
+		if r.rule.Count > 0 && n > r.rule.Count {
+			break
+		}
+
+		at := time.Date(day.Year(), day.Month(), day.Day(), r.hour, r.minute, 0, 0, r.loc)
+		if at.After(after) {
+			times = append(times, at)
+		}
+	}
+
+	return times
+}
+
+// matches reports whether a calendar day (midnight in loc) is part of the series
+func (r *recurrence) matches(day time.Time) bool {
+	switch r.rule.Frequency {
+	case models.FrequencyDaily:
+		return daysBetween(r.start, day)%r.rule.Interval == 0
+
+	case models.FrequencyWeekly:
+		if !r.weekdays[day.Weekday()] {
+			return false
+		}
+		// Weeks are counted from the Monday of the start week
+		weekStart := r.start.AddDate(0, 0, -((int(r.start.Weekday()) + 6) % 7))
+		return (daysBetween(weekStart, day)/7)%r.rule.Interval == 0
+
+	case models.FrequencyMonthly:
+		months := (day.Year()-r.start.Year())*12 + int(day.Month()) - int(r.start.Month())
+		if months%r.rule.Interval != 0 {
+			return false
+		}
+		lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, r.loc).Day()
+		for _, monthDay := range r.monthDays {
+			if monthDay == day.Day() || (monthDay == -1 && day.Day() == lastDay) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// localDate formats an occurrence as its calendar date in the rule's timezone
+func (r *recurrence) localDate(t time.Time) string {
+	return t.In(r.loc).Format(dateLayout)
+}
+
+// daysBetween counts calendar days between two local midnights, ignoring DST shifts
+func daysBetween(from, to time.Time) int {
+	fromUTC := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toUTC := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toUTC.Sub(fromUTC).Hours() / 24)
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"nyengo-deliveries/internal/models"
+)
+
+func TestRecurrenceMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.RecurrenceRule
+		days map[string]bool
+	}{
+		{
+			name: "every other day",
+			rule: models.RecurrenceRule{Frequency: models.FrequencyDaily, Interval: 2, StartDate: "2026-06-01"},
+			days: map[string]bool{"2026-06-01": true, "2026-06-02": false, "2026-06-03": true, "2026-07-01": true},
+		},
+		{
+			name: "weekly on Monday and Wednesday",
+			rule: models.RecurrenceRule{Frequency: models.FrequencyWeekly, ByWeekday: []string{"MO", "we"}, StartDate: "2026-06-01"},
+			days: map[string]bool{"2026-06-01": true, "2026-06-03": true, "2026-06-04": false, "2026-06-08": true},
+		},
+		{
+			name: "weekly defaults to the start date's weekday",
+			rule: models.RecurrenceRule{Frequency: models.FrequencyWeekly, StartDate: "2026-06-04"},
+			days: map[string]bool{"2026-06-04": true, "2026-06-05": false, "2026-06-11": true},
+		},
+		{
+			name: "every other week counted from the Monday of the start week",
+			rule: models.RecurrenceRule{Frequency: models.FrequencyWeekly, Interval: 2, ByWeekday: []string{"MO"}, StartDate: "2026-06-03"},
+			days: map[string]bool{"2026-06-01": true, "2026-06-08": false, "2026-06-15": true, "2026-06-22": false},
+		},
+		{
+			name: "monthly on the 31st skips shorter months",
+			rule: models.RecurrenceRule{Frequency: models.FrequencyMonthly, ByMonthDay: []int{31}, StartDate: "2026-01-31"},
+			days: map[string]bool{"2026-01-31": true, "2026-02-28": false, "2026-03-31": true, "2026-04-30": false},
+		},
+		{
+			name: "monthly on the last day",
+			rule: models.RecurrenceRule{Frequency: models.FrequencyMonthly, ByMonthDay: []int{-1}, StartDate: "2026-01-01"},
+			days: map[string]bool{"2026-01-31": true, "2026-02-27": false, "2026-02-28": true, "2026-04-30": true, "2028-02-28": false, "2028-02-29": true},
+		},
+		{
+			name: "quarterly on the 15th",
+			rule: models.RecurrenceRule{Frequency: models.FrequencyMonthly, Interval: 3, StartDate: "2026-01-15"},
+			days: map[string]bool{"2026-01-15": true, "2026-02-15": false, "2026-04-15": true, "2026-04-16": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.PickupTime = "09:00"
+			r, err := parseRecurrence(tt.rule, "UTC")
+			if err != nil {
+				t.Fatalf("parseRecurrence() error = %v", err)
+			}
+			for date, want := range tt.days {
+				day, _ := time.ParseInLocation(dateLayout, date, r.loc)
+				if got := r.matches(day); got != want {
+					t.Errorf("matches(%s) = %v, want %v", date, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecurrenceOccurrencesAfter(t *testing.T) {
+	lusaka, err := time.LoadLocation("Africa/Lusaka")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	at := func(date string, hour, minute int) time.Time {
+		day, _ := time.ParseInLocation(dateLayout, date, lusaka)
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	tests := []struct {
+		name  string
+		rule  models.RecurrenceRule
+		after time.Time
+		limit int
+		want  []time.Time
+	}{
+		{
+			name:  "weekly in the rule's timezone",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyWeekly, ByWeekday: []string{"MO", "FR"}, StartDate: "2026-06-01"},
+			after: at("2026-06-03", 12, 0),
+			limit: 3,
+			want:  []time.Time{at("2026-06-05", 9, 30), at("2026-06-08", 9, 30), at("2026-06-12", 9, 30)},
+		},
+		{
+			name:  "later the same day as an occurrence",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyWeekly, ByWeekday: []string{"MO", "FR"}, StartDate: "2026-06-01"},
+			after: at("2026-06-05", 9, 30),
+			limit: 1,
+			want:  []time.Time{at("2026-06-08", 9, 30)},
+		},
+		{
+			name:  "earlier the same day as an occurrence",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyWeekly, ByWeekday: []string{"MO", "FR"}, StartDate: "2026-06-01"},
+			after: at("2026-06-05", 9, 29),
+			limit: 1,
+			want:  []time.Time{at("2026-06-05", 9, 30)},
+		},
+		{
+			name:  "before the start date",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyDaily, StartDate: "2026-06-01"},
+			after: at("2026-01-01", 0, 0),
+			limit: 2,
+			want:  []time.Time{at("2026-06-01", 9, 30), at("2026-06-02", 9, 30)},
+		},
+		{
+			name:  "stops at the end date",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyDaily, StartDate: "2026-06-01", EndDate: "2026-06-03"},
+			after: at("2026-06-01", 12, 0),
+			limit: 10,
+			want:  []time.Time{at("2026-06-02", 9, 30), at("2026-06-03", 9, 30)},
+		},
+		{
+			name:  "occurrences before after use up the count",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyDaily, StartDate: "2026-06-01", Count: 3},
+			after: at("2026-06-02", 12, 0),
+			limit: 10,
+			want:  []time.Time{at("2026-06-03", 9, 30)},
+		},
+		{
+			name:  "count used up",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyDaily, StartDate: "2026-06-01", Count: 3},
+			after: at("2026-06-03", 12, 0),
+			limit: 10,
+		},
+		{
+			name:  "month ends",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyMonthly, ByMonthDay: []int{-1}, StartDate: "2026-01-20"},
+			after: at("2026-01-01", 0, 0),
+			limit: 3,
+			want:  []time.Time{at("2026-01-31", 9, 30), at("2026-02-28", 9, 30), at("2026-03-31", 9, 30)},
+		},
+		{
+			name:  "open-ended series keeps going years after its start",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyDaily, StartDate: "2020-01-01"},
+			after: at("2031-06-01", 12, 0),
+			limit: 2,
+			want:  []time.Time{at("2031-06-02", 9, 30), at("2031-06-03", 9, 30)},
+		},
+		{
+			name:  "a rule that never matches stops at the horizon",
+			rule:  models.RecurrenceRule{Frequency: models.FrequencyMonthly, Interval: 12, ByMonthDay: []int{31}, StartDate: "2026-04-01"},
+			after: at("2026-04-01", 0, 0),
+			limit: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.PickupTime = "09:30"
+			tt.rule.Timezone = "Africa/Lusaka"
+			r, err := parseRecurrence(tt.rule, "UTC")
+			if err != nil {
+				t.Fatalf("parseRecurrence() error = %v", err)
+			}
+
+			got := r.occurrencesAfter(tt.after, tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("occurrencesAfter() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrencesAfter()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
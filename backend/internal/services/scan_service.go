@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+	"nyengo-deliveries/pkg/validator"
+)
+
+// WebhookParcelScanned is sent to the store whenever one of its parcels is scanned
+const WebhookParcelScanned = "order.parcel_scanned"
+
+var (
+	// ErrInvalidScan is returned when a scan fails validation
+	ErrInvalidScan = errors.New("invalid scan")
+	// ErrUnknownParcel is returned when a scanned code does not match any order
+	ErrUnknownParcel = errors.New("no parcel matches the scanned code")
+	// ErrScanNotAllowed is returned when the order's status does not allow the scanned event
+	ErrScanNotAllowed = errors.New("scan not allowed")
+	// ErrDuplicateScan is returned when the same person scans the same event twice in a row
+	ErrDuplicateScan = errors.New("parcel was already scanned for this event")
+)
+
+// scanRule lists the order statuses a scan event is accepted in and the status it moves the order to, if any
+type scanRule struct {
+	from []models.OrderStatus
+	to   models.OrderStatus
+}
+
+var scanRules = map[models.ScanEvent]scanRule{
+	models.ScanEventPickedUp: {
+		from: []models.OrderStatus{models.OrderStatusAccepted},
+		to:   models.OrderStatusPickedUp,
+	},
+	models.ScanEventHandedOver: {
+		from: []models.OrderStatus{models.OrderStatusPickedUp, models.OrderStatusInTransit, models.OrderStatusReattemptScheduled, models.OrderStatusReturning},
+	},
+	models.ScanEventArrivedAtHub: {
+		from: []models.OrderStatus{models.OrderStatusPickedUp, models.OrderStatusReattemptScheduled, models.OrderStatusReturning},
+	},
+	models.ScanEventLoaded: {
+		from: []models.OrderStatus{models.OrderStatusPickedUp, models.OrderStatusReattemptScheduled, models.OrderStatusReturning},
+	},
+	models.ScanEventOutForDelivery: {
+		from: []models.OrderStatus{models.OrderStatusPickedUp, models.OrderStatusReattemptScheduled},
+		to:   models.OrderStatusInTransit,
+	},
+}
+
+// ScanService records label scans by drivers and hub staff. Scans build each parcel's chain of
+// custody and move the order on where the event implies it (picked_up, out_for_delivery).
+type ScanService struct {
+	scanRepo     *repository.ScanRepository
+	orderRepo    *repository.OrderRepository
+	stateMachine *OrderStateMachine
+	drivers      *DriverService
+	webhooks     *StoreWebhookService
+}
+
+// NewScanService creates a new scan service
+func NewScanService(
+	scanRepo *repository.ScanRepository,
+	orderRepo *repository.OrderRepository,
+	stateMachine *OrderStateMachine,
+	drivers *DriverService,
+	webhooks *StoreWebhookService,
+) *ScanService {
+	return &ScanService{
+		scanRepo:     scanRepo,
+		orderRepo:    orderRepo,
+		stateMachine: stateMachine,
+		drivers:      drivers,
+		webhooks:     webhooks,
+	}
+}
+
+// Scan records a scan of one of the courier's parcels. driverID is the driver whose token made the
+// scan, or uuid.Nil for the courier account. Driver scans follow the order's driver assignment as
+// tracking does: only the assigned driver may scan an order assigned to a driver, and the courier
+// account only on behalf of hub staff.
+func (s *ScanService) Scan(ctx context.Context, courierID, driverID uuid.UUID, req *models.ScanRequest) (*models.ScanResult, error) {
+	if err := validateScan(req); err != nil {
+		return nil, err
+	}
+	if driverID != uuid.Nil && req.ActorType != models.ScanActorDriver {
+		return nil, fmt.Errorf("%w: drivers can only scan as driver", ErrInvalidScan)
+	}
+
+	order, err := s.findParcel(ctx, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	if req.ActorType == models.ScanActorDriver {
+		driver, err := s.drivers.AuthorizeTracking(ctx, courierID, driverID, order.ID)
+		if err != nil {
+			return nil, err
+		}
+		if driver != nil && req.ActorName == "" {
+			req.ActorName = driver.Name
+		}
+	}
+
+	rule := scanRules[req.Event]
+	if !slices.Contains(rule.from, order.Status) {
+		return nil, fmt.Errorf("%w: %s is %s, %s scans are only accepted while it is %s",
+			ErrScanNotAllowed, order.OrderNumber, order.Status, req.Event, joinStatuses(rule.from))
+	}
+
+	scans, err := s.scanRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scans: %w", err)
+	}
+	if n := len(scans); n > 0 {
+		last := scans[n-1]
+		if last.Event == req.Event && sameHolder(&last, courierID, driverID, req.ActorType, req.ActorName) {
+			return nil, fmt.Errorf("%w: %s was scanned %s at %s", ErrDuplicateScan, order.OrderNumber, last.Event, last.ScannedAt.Format("15:04"))
+		}
+	}
+
+	scan := &models.ParcelScan{
+		ID:           uuid.New(),
+		OrderID:      order.ID,
+		CourierID:    courierID,
+		DriverID:     scanDriver(driverID),
+		Event:        req.Event,
+		ActorType:    req.ActorType,
+		ActorName:    req.ActorName,
+		Location:     req.Location,
+		Latitude:     req.Latitude,
+		Longitude:    req.Longitude,
+		Note:         req.Note,
+		StatusBefore: order.Status,
+		StatusAfter:  order.Status,
+	}
+
+	if rule.to != "" {
+		if _, err := s.stateMachine.TransitionOrder(ctx, order, rule.to, models.StatusActorCourier, scanNote(scan)); err != nil {
+			return nil, err
+		}
+		scan.StatusAfter = order.Status
+		scan.ScannedAt = order.UpdatedAt
+	} else {
+		scan.ScannedAt = time.Now()
+	}
+
+	if err := s.scanRepo.Create(ctx, scan); err != nil {
+		return nil, fmt.Errorf("failed to record scan: %w", err)
+	}
+
+	log.Printf("📦 Order %s scanned %s by %s", order.OrderNumber, scan.Event, scanActor(scan))
+
+	s.webhooks.Send(order, WebhookParcelScanned, scan)
+	return &models.ScanResult{Scan: *scan, Order: order}, nil
+}
+
+// Custody returns an order's chain of custody
+func (s *ScanService) Custody(ctx context.Context, orderID uuid.UUID) (*models.CustodyTimeline, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	return s.custody(ctx, order)
+}
+
+// CustodyForCourier returns the chain of custody of one of the courier's orders
+func (s *ScanService) CustodyForCourier(ctx context.Context, courierID, orderID uuid.UUID) (*models.CustodyTimeline, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return s.custody(ctx, order)
+}
+
+// custody groups an order's scans into custody periods. A new period starts whenever the parcel is
+// scanned by someone other than its current holder; the last period ends when the order reaches a
+// final status.
+func (s *ScanService) custody(ctx context.Context, order *models.Order) (*models.CustodyTimeline, error) {
+	scans, err := s.scanRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scans: %w", err)
+	}
+
+	timeline := &models.CustodyTimeline{
+		OrderID:     order.ID,
+		OrderNumber: order.OrderNumber,
+		Status:      order.Status,
+		Custody:     []models.CustodyPeriod{},
+		Scans:       scans,
+	}
+
+	for i := range scans {
+		scan := &scans[i]
+		if n := len(timeline.Custody); n > 0 {
+			current := &timeline.Custody[n-1]
+			if sameHolder(scan, current.CourierID, scanDriverID(current.DriverID), current.ActorType, current.ActorName) {
+				current.Events = append(current.Events, scan.Event)
+				continue
+			}
+			until := scan.ScannedAt
+			current.Until = &until
+		}
+		timeline.Custody = append(timeline.Custody, models.CustodyPeriod{
+			ActorType: scan.ActorType,
+			ActorName: scan.ActorName,
+			CourierID: scan.CourierID,
+			DriverID:  scan.DriverID,
+			Location:  scan.Location,
+			From:      scan.ScannedAt,
+			Events:    []models.ScanEvent{scan.Event},
+		})
+	}
+
+	if n := len(timeline.Custody); n > 0 {
+		last := &timeline.Custody[n-1]
+		if order.Status.IsTerminal() {
+			until := order.UpdatedAt
+			if order.ActualDelivery != nil {
+				until = *order.ActualDelivery
+			}
+			last.Until = &until
+		} else {
+			timeline.CurrentHolder = last
+		}
+	}
+	return timeline, nil
+}
+
+// findParcel resolves a scanned code: an order number from the barcode, or the tracking URL or
+// order ID from the QR code
+func (s *ScanService) findParcel(ctx context.Context, code string) (*models.Order, error) {
+	code = strings.TrimSpace(code)
+	if i := strings.LastIndex(strings.TrimRight(code, "/"), "/"); i >= 0 {
+		code = strings.TrimRight(code, "/")[i+1:]
+	}
+
+	var order *models.Order
+	var err error
+	if id, parseErr := uuid.Parse(code); parseErr == nil {
+		order, err = s.orderRepo.GetByID(ctx, id)
+	} else {
+		order, err = s.orderRepo.GetByOrderNumber(ctx, strings.ToUpper(code))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownParcel, code)
+	}
+	return order, nil
+}
+
+func validateScan(req *models.ScanRequest) error {
+	req.Code = strings.TrimSpace(req.Code)
+	req.ActorName = strings.TrimSpace(req.ActorName)
+	req.Location = strings.TrimSpace(req.Location)
+	req.Note = strings.TrimSpace(req.Note)
+	if req.ActorType == "" {
+		req.ActorType = models.ScanActorDriver
+	}
+
+	switch {
+	case req.Code == "":
+		return fmt.Errorf("%w: code is required", ErrInvalidScan)
+	case !req.Event.IsValid():
+		return fmt.Errorf("%w: event must be picked_up, handed_over, arrived_at_hub, loaded or out_for_delivery", ErrInvalidScan)
+	case !req.ActorType.IsValid():
+		return fmt.Errorf("%w: actorType must be driver or hub_staff", ErrInvalidScan)
+	case req.ActorType == models.ScanActorHubStaff && req.ActorName == "":
+		return fmt.Errorf("%w: actorName is required for hub staff", ErrInvalidScan)
+	case req.Event == models.ScanEventArrivedAtHub && req.Location == "":
+		return fmt.Errorf("%w: location is required when a parcel arrives at a hub", ErrInvalidScan)
+	case (req.Latitude == 0 && req.Longitude == 0) || !validator.ValidateCoordinates(req.Latitude, req.Longitude):
+		return fmt.Errorf("%w: latitude and longitude are required", ErrInvalidScan)
+	}
+	return nil
+}
+
+// sameHolder reports whether a scan was made by the given person
+func sameHolder(scan *models.ParcelScan, courierID, driverID uuid.UUID, actorType models.ScanActorType, actorName string) bool {
+	return scan.CourierID == courierID && scanDriverID(scan.DriverID) == driverID &&
+		scan.ActorType == actorType && strings.EqualFold(scan.ActorName, actorName)
+}
+
+// scanDriver returns the driver a scan is recorded for, nil for the courier account
+func scanDriver(driverID uuid.UUID) *uuid.UUID {
+	if driverID == uuid.Nil {
+		return nil
+	}
+	return &driverID
+}
+
+// scanDriverID returns the driver a scan was made by, uuid.Nil for the courier account
+func scanDriverID(driverID *uuid.UUID) uuid.UUID {
+	if driverID == nil {
+		return uuid.Nil
+	}
+	return *driverID
+}
+
+func scanActor(scan *models.ParcelScan) string {
+	if scan.ActorName == "" {
+		return string(scan.ActorType)
+	}
+	return fmt.Sprintf("%s %s", scan.ActorType, scan.ActorName)
+}
+
+func scanNote(scan *models.ParcelScan) string {
+	note := fmt.Sprintf("Scanned %s by %s", scan.Event, scanActor(scan))
+	if scan.Location != "" {
+		note += " at " + scan.Location
+	}
+	return note
+}
+
+func joinStatuses(statuses []models.OrderStatus) string {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	return strings.Join(names, " or ")
+}
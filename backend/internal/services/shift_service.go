@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+	"nyengo-deliveries/internal/websocket"
+)
+
+var (
+	ErrNotOnline     = errors.New("not online")
+	ErrInvalidShift  = errors.New("invalid shift request")
+	ErrUnknownAction = errors.New("shift action must be online, offline or heartbeat")
+)
+
+const (
+	// shiftSweepBatch caps how many timed-out shifts are closed on each run
+	shiftSweepBatch = 200
+	// shiftHistoryLimit caps how many shifts a history lists
+	shiftHistoryLimit = 500
+	// shiftHistoryDefaultPeriod is the period a history covers when no range is given
+	shiftHistoryDefaultPeriod = 7 * 24 * time.Hour
+)
+
+// ShiftService tracks when courier accounts and their drivers are online. They go online and offline
+// over REST or the WebSocket and keep their shift open with heartbeats; shifts without a heartbeat
+// within the timeout are closed at the last one. Dispatch and the store courier list only consider
+// couriers with an open shift under the maximum shift length.
+type ShiftService struct {
+	repo         *repository.ShiftRepository
+	driverRepo   *repository.DriverRepository
+	notification *NotificationService
+	hub          *websocket.Hub
+	cfg          *config.Config
+}
+
+// NewShiftService creates a new shift service
+func NewShiftService(
+	repo *repository.ShiftRepository,
+	driverRepo *repository.DriverRepository,
+	notification *NotificationService,
+	hub *websocket.Hub,
+	cfg *config.Config,
+) *ShiftService {
+	return &ShiftService{
+		repo:         repo,
+		driverRepo:   driverRepo,
+		notification: notification,
+		hub:          hub,
+		cfg:          cfg,
+	}
+}
+
+// Online opens a shift for the courier account (driverID uuid.Nil) or one of its drivers. Going online
+// while already online keeps the open shift and counts as a heartbeat.
+func (s *ShiftService) Online(ctx context.Context, courierID, driverID uuid.UUID) (*models.ShiftStatus, error) {
+	if err := s.checkDriver(ctx, courierID, driverID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	shift, started, err := s.repo.Start(ctx, courierID, shiftDriver(driverID), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start shift: %w", err)
+	}
+	if !started {
+		return s.Heartbeat(ctx, courierID, driverID)
+	}
+
+	log.Printf("🟢 %s went online", shiftOwner(shift))
+	s.push(shift)
+	return s.status(shift), nil
+}
+
+// Offline closes the open shift of the courier account (driverID uuid.Nil) or one of its drivers
+func (s *ShiftService) Offline(ctx context.Context, courierID, driverID uuid.UUID) (*models.ShiftStatus, error) {
+	shift, err := s.repo.End(ctx, courierID, shiftDriver(driverID), time.Now(), models.ShiftEndOffline)
+	if err != nil {
+		if errors.Is(err, repository.ErrShiftNotOpen) {
+			return nil, ErrNotOnline
+		}
+		return nil, fmt.Errorf("failed to end shift: %w", err)
+	}
+
+	log.Printf("⚪ %s went offline after %.2f h", shiftOwner(shift), shift.HoursWorked)
+	s.push(shift)
+	return s.status(shift), nil
+}
+
+// Heartbeat keeps the open shift of the courier account (driverID uuid.Nil) or one of its drivers
+// alive. A driver suspended during their shift is set offline.
+func (s *ShiftService) Heartbeat(ctx context.Context, courierID, driverID uuid.UUID) (*models.ShiftStatus, error) {
+	if err := s.checkDriver(ctx, courierID, driverID); err != nil {
+		if errors.Is(err, ErrDriverNotActive) {
+			if shift, endErr := s.repo.End(ctx, courierID, shiftDriver(driverID), time.Now(), models.ShiftEndDriverSuspended); endErr == nil {
+				s.push(shift)
+			}
+		}
+		return nil, err
+	}
+
+	shift, err := s.repo.Heartbeat(ctx, courierID, shiftDriver(driverID), time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrShiftNotOpen) {
+			return nil, ErrNotOnline
+		}
+		return nil, fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+	return s.status(shift), nil
+}
+
+// Status returns whether the courier account (driverID uuid.Nil) or one of its drivers is online
+func (s *ShiftService) Status(ctx context.Context, courierID, driverID uuid.UUID) (*models.ShiftStatus, error) {
+	shift, err := s.repo.GetOpen(ctx, courierID, shiftDriver(driverID))
+	if err != nil {
+		if errors.Is(err, repository.ErrShiftNotOpen) {
+			return &models.ShiftStatus{}, nil
+		}
+		return nil, err
+	}
+	// A shift past its heartbeat deadline is offline even before the sweep closes it
+	if time.Since(shift.LastHeartbeatAt) > s.cfg.ShiftHeartbeatTimeout {
+		return &models.ShiftStatus{}, nil
+	}
+	return s.status(shift), nil
+}
+
+// History lists a courier's shifts overlapping the period, optionally of one driver only, with the
+// hours worked within it. Without a period it covers the last seven days.
+func (s *ShiftService) History(ctx context.Context, courierID uuid.UUID, driverID *uuid.UUID, from, to *time.Time) (*models.ShiftHistory, error) {
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidShift)
+	}
+	if from == nil && to == nil {
+		since := time.Now().Add(-shiftHistoryDefaultPeriod)
+		from = &since
+	}
+
+	shifts, err := s.repo.List(ctx, courierID, driverID, from, to, shiftHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	count, hours, err := s.repo.HoursWorked(ctx, courierID, driverID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ShiftHistory{From: from, To: to, ShiftCount: count, HoursWorked: hours, Shifts: shifts}, nil
+}
+
+// Availability returns who is online for the courier right now
+func (s *ShiftService) Availability(ctx context.Context, courierID uuid.UUID) (*models.CourierAvailability, error) {
+	shifts, err := s.repo.ListOpen(ctx, &courierID, time.Now().Add(-s.cfg.ShiftHeartbeatTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	availability := s.summarize(courierID, shifts, time.Now())
+	availability.Shifts = shifts
+	return &availability, nil
+}
+
+// Availabilities returns the availability of every courier with someone online right now
+func (s *ShiftService) Availabilities(ctx context.Context) (map[uuid.UUID]models.CourierAvailability, error) {
+	now := time.Now()
+	shifts, err := s.repo.ListOpen(ctx, nil, now.Add(-s.cfg.ShiftHeartbeatTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	byCourier := make(map[uuid.UUID][]models.Shift)
+	for _, shift := range shifts {
+		byCourier[shift.CourierID] = append(byCourier[shift.CourierID], shift)
+	}
+	availabilities := make(map[uuid.UUID]models.CourierAvailability, len(byCourier))
+	for courierID, open := range byCourier {
+		availabilities[courierID] = s.summarize(courierID, open, now)
+	}
+	return availabilities, nil
+}
+
+// HandleSocket performs a shift action sent over the WebSocket and returns the reply payload
+func (s *ShiftService) HandleSocket(ctx context.Context, courierID, driverID uuid.UUID, action string) (interface{}, error) {
+	switch action {
+	case models.ShiftActionOnline:
+		return s.Online(ctx, courierID, driverID)
+	case models.ShiftActionOffline:
+		return s.Offline(ctx, courierID, driverID)
+	case models.ShiftActionHeartbeat:
+		return s.Heartbeat(ctx, courierID, driverID)
+	}
+	return nil, ErrUnknownAction
+}
+
+// Run closes shifts that missed their heartbeat every interval until ctx is cancelled
+func (s *ShiftService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunOnce(ctx, time.Now())
+		}
+	}
+}
+
+// RunOnce closes the shifts whose last heartbeat is older than the heartbeat timeout and tells their
+// courier or driver they are offline
+func (s *ShiftService) RunOnce(ctx context.Context, now time.Time) {
+	shifts, err := s.repo.CloseStale(ctx, now.Add(-s.cfg.ShiftHeartbeatTimeout), shiftSweepBatch)
+	if err != nil {
+		log.Printf("⚠️ Failed to close timed-out shifts: %v", err)
+		return
+	}
+
+	for i := range shifts {
+		shift := &shifts[i]
+		log.Printf("⏱️ %s set offline after missing heartbeats", shiftOwner(shift))
+		s.push(shift)
+		if err := s.notification.SendShiftTimedOut(ctx, shift); err != nil {
+			log.Printf("⚠️ Failed to notify shift %s timeout: %v", shift.ID, err)
+		}
+	}
+}
+
+// summarize counts the open shifts of a courier and those still under the maximum shift length
+func (s *ShiftService) summarize(courierID uuid.UUID, shifts []models.Shift, now time.Time) models.CourierAvailability {
+	availability := models.CourierAvailability{CourierID: courierID, OnlineShifts: len(shifts), Online: len(shifts) > 0}
+	for _, shift := range shifts {
+		if s.cfg.ShiftMaxDuration <= 0 || now.Sub(shift.StartedAt) < s.cfg.ShiftMaxDuration {
+			availability.AvailableShifts++
+		}
+	}
+	return availability
+}
+
+// checkDriver makes sure a driver belongs to the courier and is active; the courier account always may
+func (s *ShiftService) checkDriver(ctx context.Context, courierID, driverID uuid.UUID) error {
+	if driverID == uuid.Nil {
+		return nil
+	}
+	driver, err := s.driverRepo.GetByID(ctx, driverID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDriverNotFound) {
+			return ErrDriverNotFound
+		}
+		return err
+	}
+	if driver.CourierID != courierID {
+		return ErrDriverNotFound
+	}
+	if driver.Status != models.DriverStatusActive {
+		return ErrDriverNotActive
+	}
+	return nil
+}
+
+func (s *ShiftService) status(shift *models.Shift) *models.ShiftStatus {
+	if shift.EndedAt != nil {
+		return &models.ShiftStatus{Shift: shift}
+	}
+	deadline := shift.LastHeartbeatAt.Add(s.cfg.ShiftHeartbeatTimeout)
+	return &models.ShiftStatus{Online: true, Shift: shift, HeartbeatDeadline: &deadline}
+}
+
+// push sends the shift to the courier's open sockets, so dispatch screens see who is online
+func (s *ShiftService) push(shift *models.Shift) {
+	if s.hub == nil {
+		return
+	}
+	data, err := json.Marshal(shift)
+	if err != nil {
+		return
+	}
+	message, err := json.Marshal(websocket.WSMessage{Type: "shift_update", Payload: data})
+	if err != nil {
+		return
+	}
+	s.hub.SendToCourier(shift.CourierID, message)
+}
+
+// shiftDriver returns the driver a shift belongs to, nil for the courier account
+func shiftDriver(driverID uuid.UUID) *uuid.UUID {
+	if driverID == uuid.Nil {
+		return nil
+	}
+	return &driverID
+}
+
+// shiftOwner describes who a shift belongs to for logging
+func shiftOwner(shift *models.Shift) string {
+	if shift.DriverID != nil {
+		return fmt.Sprintf("Driver %s of courier %s", shift.DriverID, shift.CourierID)
+	}
+	return fmt.Sprintf("Courier %s", shift.CourierID)
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// ErrNotMultiStop is returned for stop operations on a single drop-off order
+var ErrNotMultiStop = errors.New("order does not have multiple stops")
+
+// ErrStopNotFound is returned when a stop does not belong to the order
+var ErrStopNotFound = errors.New("stop not found")
+
+// ErrStopAlreadyCompleted is returned when a stop has already been delivered or failed
+var ErrStopAlreadyCompleted = errors.New("stop has already been completed")
+
+// ErrStopNotAllowed is returned when a stop is updated before the order is out for delivery
+var ErrStopNotAllowed = errors.New("stops can only be completed while the order is in transit")
+
+// ErrStopProofRequired is returned when a stop is marked delivered without a photo
+var ErrStopProofRequired = errors.New("a delivery photo must be uploaded for this stop first")
+
+// ErrInvalidStopUpdate is returned when a stop update fails validation
+var ErrInvalidStopUpdate = errors.New("invalid stop update")
+
+// ErrStopCODRequired is returned when a stop with a cash-on-delivery amount is delivered without the amount collected
+var ErrStopCODRequired = errors.New("codCollected is required for stops with a cash-on-delivery amount")
+
+// ErrCompleteStopsFirst is returned when a multi-stop order is delivered directly instead of stop by stop
+var ErrCompleteStopsFirst = errors.New("multi-stop orders are delivered by completing each stop")
+
+// StopService completes the drop-offs of multi-stop orders and closes the order after the last one
+type StopService struct {
+	orderRepo    *repository.OrderRepository
+	stateMachine *OrderStateMachine
+	tracking     *TrackingService
+	webhooks     *StoreWebhookService
+}
+
+// NewStopService creates a new stop service
+func NewStopService(
+	orderRepo *repository.OrderRepository,
+	stateMachine *OrderStateMachine,
+	tracking *TrackingService,
+	webhooks *StoreWebhookService,
+) *StopService {
+	return &StopService{
+		orderRepo:    orderRepo,
+		stateMachine: stateMachine,
+		tracking:     tracking,
+		webhooks:     webhooks,
+	}
+}
+
+// CompleteStop records the outcome of a drop-off. Once no stops are pending the order is
+// delivered if at least one stop was delivered, and failed otherwise.
+func (s *StopService) CompleteStop(ctx context.Context, courierID, orderID, stopID uuid.UUID, req *models.UpdateStopStatusRequest) (*models.Order, error) {
+	order, stop, err := s.loadStop(ctx, courierID, orderID, stopID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusInTransit {
+		return nil, ErrStopNotAllowed
+	}
+	if stop.IsFinal() {
+		return nil, ErrStopAlreadyCompleted
+	}
+
+	reason := strings.TrimSpace(req.FailureReason)
+	collected := 0.0
+	switch req.Status {
+	case models.StopStatusDelivered:
+		if stop.DeliveryProofURL == "" {
+			return nil, ErrStopProofRequired
+		}
+		if order.RequiresSignature && stop.SignatureURL == "" {
+			return nil, ErrSignatureRequired
+		}
+		if stop.CODAmount > 0 {
+			if req.CODCollected == nil {
+				return nil, ErrStopCODRequired
+			}
+			if *req.CODCollected < 0 {
+				return nil, fmt.Errorf("%w: codCollected cannot be negative", ErrInvalidStopUpdate)
+			}
+			collected = roundMoney(*req.CODCollected)
+		}
+		reason = ""
+	case models.StopStatusFailed:
+		if reason == "" {
+			return nil, fmt.Errorf("%w: a failure reason is required", ErrInvalidStopUpdate)
+		}
+	default:
+		return nil, fmt.Errorf("%w: status must be delivered or failed", ErrInvalidStopUpdate)
+	}
+
+	completedAt, err := s.orderRepo.CompleteStop(ctx, stop.ID, req.Status, reason, collected)
+	if err != nil {
+		if errors.Is(err, repository.ErrStopStatusChanged) {
+			return nil, ErrStopAlreadyCompleted
+		}
+		return nil, fmt.Errorf("failed to update stop: %w", err)
+	}
+	stop.Status = req.Status
+	stop.FailureReason = reason
+	stop.CODCollected = collected
+	stop.CompletedAt = completedAt
+
+	log.Printf("📍 Stop %d of order %s %s", stop.Sequence, order.OrderNumber, stop.Status)
+
+	event := WebhookStopDelivered
+	if stop.Status == models.StopStatusFailed {
+		event = WebhookStopFailed
+	}
+	s.webhooks.Send(order, event, stop)
+	if s.tracking != nil {
+		s.tracking.CompleteStop(ctx, order.OrderNumber, stop)
+	}
+
+	if next := nextPendingStop(order); next != nil {
+		s.webhooks.Send(order, WebhookStopEnRoute, next)
+		return order, nil
+	}
+	return s.closeOrder(ctx, order)
+}
+
+// AnnounceFirstStop is a transition hook that tells the store which stop the driver is heading to
+// once a multi-stop order goes in transit
+func (s *StopService) AnnounceFirstStop(ctx context.Context, order *models.Order, change models.StatusChange) {
+	if change.Status != models.OrderStatusInTransit || order.OrderType != models.OrderTypeMultiStop {
+		return
+	}
+	if next := nextPendingStop(order); next != nil {
+		s.webhooks.Send(order, WebhookStopEnRoute, next)
+	}
+}
+
+// closeOrder moves an order whose stops are all complete to its final status
+func (s *StopService) closeOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
+	delivered := 0
+	for _, stop := range order.Stops {
+		if stop.Status == models.StopStatusDelivered {
+			delivered++
+		}
+	}
+
+	status := models.OrderStatusDelivered
+	if delivered == 0 {
+		status = models.OrderStatusFailed
+	}
+	note := fmt.Sprintf("All stops completed: %d of %d delivered", delivered, len(order.Stops))
+
+	return s.stateMachine.TransitionOrder(ctx, order, status, models.StatusActorSystem, note)
+}
+
+// loadStop loads an order assigned to the courier together with one of its stops
+func (s *StopService) loadStop(ctx context.Context, courierID, orderID, stopID uuid.UUID) (*models.Order, *models.OrderStop, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrOrderNotFound, err)
+	}
+	if order.CourierID != courierID {
+		return nil, nil, ErrNotOrderCourier
+	}
+	stop, err := findStop(order, stopID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, stop, nil
+}
+
+// findStop returns a pointer into the order's stops so updates are reflected on the order
+func findStop(order *models.Order, stopID uuid.UUID) (*models.OrderStop, error) {
+	if order.OrderType != models.OrderTypeMultiStop {
+		return nil, ErrNotMultiStop
+	}
+	for i := range order.Stops {
+		if order.Stops[i].ID == stopID {
+			return &order.Stops[i], nil
+		}
+	}
+	return nil, ErrStopNotFound
+}
+
+// nextPendingStop returns the first stop on the route that has not been completed
+func nextPendingStop(order *models.Order) *models.OrderStop {
+	for i := range order.Stops {
+		if !order.Stops[i].IsFinal() {
+			return &order.Stops[i]
+		}
+	}
+	return nil
+}
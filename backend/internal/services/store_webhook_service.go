@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// Store webhook event types
+const (
+	WebhookStopEnRoute   = "stop.en_route"
+	WebhookStopDelivered = "stop.delivered"
+	WebhookStopFailed    = "stop.failed"
+
+	WebhookPickupOverdue = "order.pickup_overdue"
+)
+
+// storeWebhookAttempts is how many times a webhook is tried before it is dropped
+const storeWebhookAttempts = 3
+
+// StoreWebhookEvent is the body POSTed to a store's webhook URL
+type StoreWebhookEvent struct {
+	Event       string      `json:"event"`
+	OrderID     string      `json:"orderId"`
+	OrderNumber string      `json:"orderNumber"`
+	ExternalID  string      `json:"externalOrderId,omitempty"`
+	Timestamp   time.Time   `json:"timestamp"`
+	Data        interface{} `json:"data"`
+}
+
+// StoreWebhookService delivers signed order events to the webhook URL configured for a store
+type StoreWebhookService struct {
+	paymentRepo *repository.PaymentRepository
+	httpClient  *http.Client
+}
+
+// NewStoreWebhookService creates a new store webhook service
+func NewStoreWebhookService(paymentRepo *repository.PaymentRepository) *StoreWebhookService {
+	return &StoreWebhookService{
+		paymentRepo: paymentRepo,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send delivers an event for a store order in the background.
+// Orders without a store, or stores without a webhook URL, are skipped.
+func (s *StoreWebhookService) Send(order *models.Order, event string, data interface{}) {
+	if s == nil || order.StoreID == nil {
+		return
+	}
+
+	payload := &StoreWebhookEvent{
+		Event:       event,
+		OrderID:     order.ID.String(),
+		OrderNumber: order.OrderNumber,
+		ExternalID:  order.ExternalOrderID,
+		Timestamp:   time.Now(),
+		Data:        data,
+	}
+	storeID := *order.StoreID
+
+	go func() {
+		ctx := context.Background()
+		storeConfig, err := s.paymentRepo.GetStorePaymentConfig(ctx, storeID)
+		if err != nil || storeConfig.WebhookURL == "" {
+			return
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("⚠️ Failed to encode %s webhook for order %s: %v", event, payload.OrderNumber, err)
+			return
+		}
+
+		for attempt := 1; attempt <= storeWebhookAttempts; attempt++ {
+			if err = s.post(ctx, storeConfig, event, body); err == nil {
+				log.Printf("📤 Sent %s webhook for order %s to store %s", event, payload.OrderNumber, storeConfig.StoreName)
+				return
+			}
+			time.Sleep(time.Duration(attempt*attempt) * time.Second)
+		}
+		log.Printf("⚠️ Giving up on %s webhook for order %s after %d attempts: %v", event, payload.OrderNumber, storeWebhookAttempts, err)
+	}()
+}
+
+// post sends one webhook request signed with the store's webhook secret
+func (s *StoreWebhookService) post(ctx context.Context, storeConfig *models.StorePaymentConfig, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, storeConfig.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(storeConfig.WebhookSecret))
+	mac.Write(body)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Nyengo-Event", event)
+	req.Header.Set("X-Timestamp", time.Now().UTC().Format(time.RFC3339))
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("store responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// ErrSubscriptionNotFound is returned when a subscription does not exist
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// ErrInvalidSubscription is returned when a subscription request fails validation
+var ErrInvalidSubscription = errors.New("invalid subscription")
+
+// ErrSubscriptionState is returned when a subscription cannot be paused, resumed or ended from its current status
+var ErrSubscriptionState = errors.New("subscription cannot be changed from its current status")
+
+// ErrOccurrenceNotFound is returned when a skipped date is not an upcoming occurrence
+var ErrOccurrenceNotFound = errors.New("date is not an upcoming occurrence of this subscription")
+
+// ErrOccurrenceGenerated is returned when skipping an occurrence whose order already exists
+var ErrOccurrenceGenerated = errors.New("the order for this occurrence has already been created, cancel it instead")
+
+// maxListedOccurrences caps the number of upcoming occurrences returned at once
+const maxListedOccurrences = 50
+
+// skipSearchLimit is how many upcoming occurrences are searched when skipping a date
+const skipSearchLimit = 400
+
+// SubscriptionService manages recurring deliveries and generates their orders ahead of time
+type SubscriptionService struct {
+	repo         *repository.SubscriptionRepository
+	orderService *OrderService
+	cfg          *config.Config
+}
+
+// NewSubscriptionService creates a new subscription service
+func NewSubscriptionService(repo *repository.SubscriptionRepository, orderService *OrderService, cfg *config.Config) *SubscriptionService {
+	return &SubscriptionService{repo: repo, orderService: orderService, cfg: cfg}
+}
+
+// Create validates and stores a recurring delivery, then generates any occurrences already within the lead time
+func (s *SubscriptionService) Create(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.DeliverySubscription, error) {
+	courierID, err := uuid.Parse(req.CourierID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid courier ID", ErrInvalidSubscription)
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("%w: a name is required", ErrInvalidSubscription)
+	}
+	if req.Template.StoreID == nil {
+		return nil, fmt.Errorf("%w: template.storeId is required", ErrInvalidSubscription)
+	}
+	if req.Template.ScheduledPickup != nil {
+		return nil, fmt.Errorf("%w: template.scheduledPickup is set from the schedule", ErrInvalidSubscription)
+	}
+	if req.Template.PickupAddress == "" || req.Template.PackageDescription == "" {
+		return nil, fmt.Errorf("%w: template needs a pickup address and package description", ErrInvalidSubscription)
+	}
+	if len(req.Template.Stops) == 0 && req.Template.DeliveryAddress == "" {
+		return nil, fmt.Errorf("%w: template needs a delivery address or stops", ErrInvalidSubscription)
+	}
+	if err := validateMetadata(req.Template.Metadata, s.cfg); err != nil {
+		return nil, fmt.Errorf("%w: template %v", ErrInvalidSubscription, err)
+	}
+
+	rec, err := parseRecurrence(req.Rule, s.cfg.DefaultTimezone)
+	if err != nil {
+		return nil, err
+	}
+	if len(rec.occurrencesAfter(time.Now(), 1)) == 0 {
+		return nil, fmt.Errorf("%w: the schedule has no future occurrences", ErrInvalidRecurrence)
+	}
+
+	sub := &models.DeliverySubscription{
+		StoreID:   *req.Template.StoreID,
+		CourierID: courierID,
+		Name:      strings.TrimSpace(req.Name),
+		Template:  req.Template,
+		Rule:      req.Rule,
+	}
+	if err := s.repo.Create(ctx, sub); err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+	log.Printf("🔁 Subscription %q created for store %s", sub.Name, sub.StoreID)
+
+	s.generate(ctx, sub, time.Now())
+	return s.Get(ctx, sub.ID)
+}
+
+// Get returns a subscription with its next occurrence
+func (s *SubscriptionService) Get(ctx context.Context, id uuid.UUID) (*models.DeliverySubscription, error) {
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSubscriptionNotFound, err)
+	}
+	s.setNextOccurrence(sub)
+	return sub, nil
+}
+
+// ListByStore returns a store's subscriptions
+func (s *SubscriptionService) ListByStore(ctx context.Context, storeID uuid.UUID) ([]models.DeliverySubscription, error) {
+	subs, err := s.repo.ListByStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		s.setNextOccurrence(&subs[i])
+	}
+	return subs, nil
+}
+
+// Pause stops generating orders until the subscription is resumed. Orders already generated are kept.
+func (s *SubscriptionService) Pause(ctx context.Context, id uuid.UUID) (*models.DeliverySubscription, error) {
+	return s.changeStatus(ctx, id, models.SubscriptionStatusPaused, models.SubscriptionStatusActive)
+}
+
+// Resume restarts a paused subscription. Occurrences missed while paused are not generated.
+func (s *SubscriptionService) Resume(ctx context.Context, id uuid.UUID) (*models.DeliverySubscription, error) {
+	sub, err := s.changeStatus(ctx, id, models.SubscriptionStatusActive, models.SubscriptionStatusPaused)
+	if err != nil {
+		return nil, err
+	}
+	s.generate(ctx, sub, time.Now())
+	return sub, nil
+}
+
+// End permanently stops a subscription
+func (s *SubscriptionService) End(ctx context.Context, id uuid.UUID) (*models.DeliverySubscription, error) {
+	return s.changeStatus(ctx, id, models.SubscriptionStatusEnded, models.SubscriptionStatusActive, models.SubscriptionStatusPaused)
+}
+
+// Skip excludes a single upcoming occurrence, identified by its date
+func (s *SubscriptionService) Skip(ctx context.Context, id uuid.UUID, date string) (*models.DeliverySubscription, error) {
+	sub, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status == models.SubscriptionStatusEnded {
+		return nil, ErrSubscriptionState
+	}
+	rec, err := parseRecurrence(sub.Rule, s.cfg.DefaultTimezone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var occurrence *time.Time
+	for _, at := range rec.occurrencesAfter(now, skipSearchLimit) {
+		if rec.localDate(at) == date {
+			occurrence = &at
+			break
+		}
+	}
+	if occurrence == nil {
+		return nil, ErrOccurrenceNotFound
+	}
+
+	generated, err := s.repo.GetOccurrenceOrders(ctx, sub.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := generated[occurrence.UTC()]; ok {
+		return nil, ErrOccurrenceGenerated
+	}
+
+	if err := s.repo.AddSkippedDate(ctx, sub.ID, date); err != nil {
+		return nil, fmt.Errorf("failed to skip occurrence: %w", err)
+	}
+	return s.Get(ctx, sub.ID)
+}
+
+// Upcoming lists the next occurrences of a subscription, including skipped ones and any generated orders
+func (s *SubscriptionService) Upcoming(ctx context.Context, id uuid.UUID, limit int) ([]models.SubscriptionOccurrence, error) {
+	sub, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxListedOccurrences {
+		limit = maxListedOccurrences
+	}
+	if sub.Status == models.SubscriptionStatusEnded {
+		return []models.SubscriptionOccurrence{}, nil
+	}
+
+	rec, err := parseRecurrence(sub.Rule, s.cfg.DefaultTimezone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	generated, err := s.repo.GetOccurrenceOrders(ctx, sub.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []models.SubscriptionOccurrence{}
+	for _, at := range rec.occurrencesAfter(now, limit) {
+		occurrence := models.SubscriptionOccurrence{
+			Date:            rec.localDate(at),
+			ScheduledPickup: at,
+			Skipped:         containsString(sub.SkippedDates, rec.localDate(at)),
+		}
+		if orderID, ok := generated[at.UTC()]; ok {
+			occurrence.OrderID = &orderID
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, nil
+}
+
+// Run generates due orders for all active subscriptions every interval until ctx is cancelled
+func (s *SubscriptionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.GenerateDue(ctx, time.Now())
+		}
+	}
+}
+
+// GenerateDue creates orders for every active subscription occurrence within the lead time
+func (s *SubscriptionService) GenerateDue(ctx context.Context, now time.Time) {
+	subs, err := s.repo.ListActive(ctx)
+	if err != nil {
+		log.Printf("⚠️ Failed to list active subscriptions: %v", err)
+		return
+	}
+	for i := range subs {
+		s.generate(ctx, &subs[i], now)
+	}
+}
+
+// generate creates the orders for one subscription's occurrences within the lead time.
+// Each occurrence is claimed first, so concurrent instances never create the same order twice.
+func (s *SubscriptionService) generate(ctx context.Context, sub *models.DeliverySubscription, now time.Time) {
+	if sub.Status != models.SubscriptionStatusActive {
+		return
+	}
+	rec, err := parseRecurrence(sub.Rule, s.cfg.DefaultTimezone)
+	if err != nil {
+		log.Printf("⚠️ Subscription %s has an invalid schedule: %v", sub.ID, err)
+		return
+	}
+
+	after := now
+	if sub.LastOccurrence != nil && sub.LastOccurrence.After(after) {
+		after = *sub.LastOccurrence
+	}
+
+	upcoming := rec.occurrencesAfter(after, maxListedOccurrences)
+	if len(upcoming) == 0 && (sub.LastOccurrence == nil || !sub.LastOccurrence.After(now)) {
+		// The series is exhausted (end date or count reached)
+		if _, err := s.repo.UpdateStatus(ctx, sub.ID, models.SubscriptionStatusEnded, models.SubscriptionStatusActive); err == nil {
+			log.Printf("🔁 Subscription %q completed its schedule", sub.Name)
+		}
+		return
+	}
+
+	horizon := now.Add(s.cfg.SubscriptionLeadTime)
+	for _, at := range upcoming {
+		if at.After(horizon) {
+			break
+		}
+		if containsString(sub.SkippedDates, rec.localDate(at)) {
+			continue
+		}
+		s.generateOccurrence(ctx, sub, at)
+	}
+}
+
+func (s *SubscriptionService) generateOccurrence(ctx context.Context, sub *models.DeliverySubscription, at time.Time) {
+	claimed, err := s.repo.ClaimOccurrence(ctx, sub.ID, at)
+	if err != nil {
+		log.Printf("⚠️ Failed to claim occurrence %s of subscription %s: %v", at.Format(time.RFC3339), sub.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	req := sub.Template
+	req.ScheduledPickup = &at
+	order, err := s.orderService.Create(ctx, sub.CourierID, &req)
+	if err != nil {
+		log.Printf("⚠️ Failed to create order for subscription %q at %s: %v", sub.Name, at.Format(time.RFC3339), err)
+		if err := s.repo.ReleaseOccurrence(ctx, sub.ID, at); err != nil {
+			log.Printf("⚠️ Failed to release occurrence of subscription %s: %v", sub.ID, err)
+		}
+		return
+	}
+
+	if err := s.repo.RecordOccurrenceOrder(ctx, sub.ID, at, order.ID); err != nil {
+		log.Printf("⚠️ Failed to record order %s for subscription %s: %v", order.OrderNumber, sub.ID, err)
+		return
+	}
+	log.Printf("🔁 Generated order %s for subscription %q (pickup %s)", order.OrderNumber, sub.Name, at.Format(time.RFC3339))
+}
+
+// changeStatus moves a subscription to a new status if it is currently in one of the allowed statuses
+func (s *SubscriptionService) changeStatus(ctx context.Context, id uuid.UUID, to models.SubscriptionStatus, from ...models.SubscriptionStatus) (*models.DeliverySubscription, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	changed, err := s.repo.UpdateStatus(ctx, id, to, from...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update subscription: %w", err)
+	}
+	if !changed {
+		return nil, ErrSubscriptionState
+	}
+	return s.Get(ctx, id)
+}
+
+// setNextOccurrence fills in the next occurrence that will produce an order
+func (s *SubscriptionService) setNextOccurrence(sub *models.DeliverySubscription) {
+	if sub.Status == models.SubscriptionStatusEnded {
+		return
+	}
+	rec, err := parseRecurrence(sub.Rule, s.cfg.DefaultTimezone)
+	if err != nil {
+		return
+	}
+	for _, at := range rec.occurrencesAfter(time.Now(), maxListedOccurrences) {
+		if !containsString(sub.SkippedDates, rec.localDate(at)) {
+			sub.NextOccurrence = &at
+			return
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
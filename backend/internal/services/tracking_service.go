@@ -20,6 +20,7 @@ type TrackingService struct {
 	redis        *redis.Client
 	deliveryRepo *repository.DeliveryRepository
 	orderRepo    *repository.OrderRepository
+	stateMachine *OrderStateMachine
 
 	// In-memory cache for active deliveries (for fast lookups)
 	activeDeliveries sync.Map // map[orderID]*LiveDelivery
@@ -43,11 +44,14 @@ type LiveDelivery struct {
 	DestinationLat float64 `json:"destinationLat"`
 	DestinationLng float64 `json:"destinationLng"`
 
-	// ETA calculations
+	// ETA calculations (to the next stop on multi-stop orders)
 	DistanceRemaining float64   `json:"distanceRemaining"` // km
 	ETAMinutes        int       `json:"etaMinutes"`
 	EstimatedArrival  time.Time `json:"estimatedArrival"`
 
+	// Remaining drop-offs on multi-stop orders, in route order
+	Stops []models.StopETA `json:"stops,omitempty"`
+
 	// Status
 	Status   string `json:"status"`
 	IsActive bool   `json:"isActive"`
@@ -84,11 +88,12 @@ type TrackingEvent struct {
 }
 
 // NewTrackingService creates a new tracking service
-func NewTrackingService(redis *redis.Client, deliveryRepo *repository.DeliveryRepository, orderRepo *repository.OrderRepository) *TrackingService {
+func NewTrackingService(redis *redis.Client, deliveryRepo *repository.DeliveryRepository, orderRepo *repository.OrderRepository, stateMachine *OrderStateMachine) *TrackingService {
 	service := &TrackingService{
 		redis:        redis,
 		deliveryRepo: deliveryRepo,
 		orderRepo:    orderRepo,
+		stateMachine: stateMachine,
 	}
 
 	// Start background cleanup for stale deliveries
@@ -105,6 +110,22 @@ func (s *TrackingService) StartTracking(ctx context.Context, orderID uuid.UUID,
 		return fmt.Errorf("order not found: %w", err)
 	}
 
+	// Tracking is only possible once the courier has accepted and before the order is closed
+	switch order.Status {
+	case models.OrderStatusAccepted, models.OrderStatusInTransit:
+	case models.OrderStatusPickedUp, models.OrderStatusReattemptScheduled:
+		// The parcel is on board, so starting the trip puts it in transit
+		note := "Live tracking started"
+		if order.Status == models.OrderStatusReattemptScheduled {
+			note = fmt.Sprintf("Delivery re-attempt %d started", order.DeliveryAttempts+1)
+		}
+		if _, err := s.stateMachine.TransitionOrder(ctx, order, models.OrderStatusInTransit, models.StatusActorCourier, note); err != nil {
+			return err
+		}
+	default:
+		return &InvalidTransitionError{From: order.Status, To: models.OrderStatusInTransit}
+	}
+
 	delivery := &LiveDelivery{
 		OrderID:        orderID,
 		OrderNumber:    order.OrderNumber, // Use orderNumber for tracking
@@ -119,6 +140,7 @@ func (s *TrackingService) StartTracking(ctx context.Context, orderID uuid.UUID,
 		IsActive:       true,
 		LastUpdatedAt:  time.Now(),
 	}
+	delivery.setStops(order)
 
 	// Store in memory using orderNumber as key
 	s.activeDeliveries.Store(order.OrderNumber, delivery)
@@ -213,6 +235,11 @@ func (s *TrackingService) UpdateLocation(ctx context.Context, update *LocationUp
 	}
 	delivery.EstimatedArrival = now.Add(time.Duration(delivery.ETAMinutes) * time.Minute)
 
+	// Multi-stop: chain the remaining stops after the next one
+	if len(delivery.Stops) > 0 {
+		s.estimateStops(delivery, avgSpeed, now)
+	}
+
 	// Update in memory using orderNumber
 	s.activeDeliveries.Store(orderNumber, delivery)
 
@@ -253,17 +280,184 @@ func (s *TrackingService) UpdateLocation(ctx context.Context, update *LocationUp
 		}
 	}()
 
+	if len(delivery.Stops) > 0 {
+		stops := append([]models.StopETA(nil), delivery.Stops...)
+		go func() {
+			_ = s.orderRepo.UpdateStopETAs(context.Background(), stops)
+		}()
+	}
+
 	// Broadcast location update to subscribers
 	s.broadcastEvent(ctx, orderNumber, "location_update", map[string]interface{}{
 		"location":          delivery.CurrentLocation,
 		"distanceRemaining": delivery.DistanceRemaining,
 		"etaMinutes":        delivery.ETAMinutes,
 		"estimatedArrival":  delivery.EstimatedArrival,
+		"stops":             delivery.Stops,
 	})
 
 	return delivery, nil
 }
 
+// CompleteStop removes a completed stop from live tracking, retargets the next pending stop
+// and broadcasts the stop's outcome
+func (s *TrackingService) CompleteStop(ctx context.Context, orderNumber string, stop *models.OrderStop) {
+	s.broadcastEvent(ctx, orderNumber, "stop_update", stop)
+
+	val, exists := s.activeDeliveries.Load(orderNumber)
+	if !exists && s.redis != nil {
+		// Another instance may be tracking this order
+		if data, err := s.redis.Get(ctx, s.getTrackingKey(orderNumber)).Bytes(); err == nil {
+			var cached LiveDelivery
+			if json.Unmarshal(data, &cached) == nil {
+				val, exists = &cached, true
+				s.activeDeliveries.Store(orderNumber, &cached)
+			}
+		}
+	}
+	if !exists {
+		return
+	}
+	delivery := val.(*LiveDelivery)
+
+	remaining := delivery.Stops[:0]
+	for _, eta := range delivery.Stops {
+		if eta.StopID != stop.ID {
+			remaining = append(remaining, eta)
+		}
+	}
+	delivery.Stops = remaining
+	if len(remaining) > 0 {
+		delivery.DestinationLat = remaining[0].Latitude
+		delivery.DestinationLng = remaining[0].Longitude
+	}
+
+	if s.redis != nil {
+		data, _ := json.Marshal(delivery)
+		s.redis.Set(ctx, s.getTrackingKey(orderNumber), data, 24*time.Hour)
+	}
+}
+
+// UpdateDestination retargets live tracking after an order's drop-off was amended and broadcasts the new destination
+func (s *TrackingService) UpdateDestination(ctx context.Context, order *models.Order) {
+	s.broadcastEvent(ctx, order.OrderNumber, "destination_update", map[string]interface{}{
+		"deliveryAddress":   order.DeliveryAddress,
+		"deliveryLatitude":  order.DeliveryLatitude,
+		"deliveryLongitude": order.DeliveryLongitude,
+	})
+
+	val, exists := s.activeDeliveries.Load(order.OrderNumber)
+	if !exists && s.redis != nil {
+		// Another instance may be tracking this order
+		if data, err := s.redis.Get(ctx, s.getTrackingKey(order.OrderNumber)).Bytes(); err == nil {
+			var cached LiveDelivery
+			if json.Unmarshal(data, &cached) == nil {
+				val, exists = &cached, true
+				s.activeDeliveries.Store(order.OrderNumber, &cached)
+			}
+		}
+	}
+	if !exists {
+		return
+	}
+	delivery := val.(*LiveDelivery)
+	delivery.DestinationLat = order.DeliveryLatitude
+	delivery.DestinationLng = order.DeliveryLongitude
+
+	if s.redis != nil {
+		data, _ := json.Marshal(delivery)
+		s.redis.Set(ctx, s.getTrackingKey(order.OrderNumber), data, 24*time.Hour)
+	}
+}
+
+// TransferDelivery hands an order's live tracking to the courier it was transferred to, keeping the
+// last known position, and broadcasts the new driver. Orders that are not being tracked are left alone.
+func (s *TrackingService) TransferDelivery(ctx context.Context, order *models.Order, driverInfo *DriverInfo) error {
+	val, exists := s.activeDeliveries.Load(order.OrderNumber)
+	if !exists && s.redis != nil {
+		// Another instance may be tracking this order
+		if data, err := s.redis.Get(ctx, s.getTrackingKey(order.OrderNumber)).Bytes(); err == nil {
+			var cached LiveDelivery
+			if json.Unmarshal(data, &cached) == nil {
+				val, exists = &cached, true
+				s.activeDeliveries.Store(order.OrderNumber, &cached)
+			}
+		}
+	}
+	if !exists {
+		return nil
+	}
+	delivery := val.(*LiveDelivery)
+	delivery.CourierID = order.CourierID
+	delivery.DriverName = driverInfo.Name
+	delivery.DriverPhone = driverInfo.Phone
+	delivery.VehicleType = driverInfo.VehicleType
+	delivery.VehiclePlate = driverInfo.VehiclePlate
+
+	if s.redis != nil {
+		data, _ := json.Marshal(delivery)
+		s.redis.Set(ctx, s.getTrackingKey(order.OrderNumber), data, 24*time.Hour)
+	}
+
+	if err := s.deliveryRepo.Reassign(ctx, order.ID, &models.DeliveryTracking{
+		CourierID:    order.CourierID,
+		DriverName:   driverInfo.Name,
+		DriverPhone:  driverInfo.Phone,
+		VehicleType:  driverInfo.VehicleType,
+		VehiclePlate: driverInfo.VehiclePlate,
+	}); err != nil {
+		return fmt.Errorf("failed to reassign tracking record: %w", err)
+	}
+
+	s.broadcastEvent(ctx, order.OrderNumber, "driver_changed", delivery)
+	return nil
+}
+
+// setStops loads the pending stops of a multi-stop order and heads for the first one
+func (d *LiveDelivery) setStops(order *models.Order) {
+	d.Stops = nil
+	for _, stop := range order.Stops {
+		if stop.IsFinal() {
+			continue
+		}
+		eta := models.StopETA{
+			StopID:    stop.ID,
+			Sequence:  stop.Sequence,
+			Status:    stop.Status,
+			Address:   stop.Address,
+			Latitude:  stop.Latitude,
+			Longitude: stop.Longitude,
+		}
+		if stop.EstimatedArrival != nil {
+			eta.EstimatedArrival = *stop.EstimatedArrival
+		}
+		d.Stops = append(d.Stops, eta)
+	}
+	if len(d.Stops) > 0 {
+		d.DestinationLat = d.Stops[0].Latitude
+		d.DestinationLng = d.Stops[0].Longitude
+	}
+}
+
+// estimateStops computes cumulative distances and arrival times for every remaining stop,
+// allowing dwell time at each drop-off before the next leg
+func (s *TrackingService) estimateStops(delivery *LiveDelivery, avgSpeed float64, now time.Time) {
+	distance := delivery.DistanceRemaining
+	minutes := float64(delivery.ETAMinutes)
+
+	for i := range delivery.Stops {
+		if i > 0 {
+			prev := delivery.Stops[i-1]
+			leg := s.calculateDistance(prev.Latitude, prev.Longitude, delivery.Stops[i].Latitude, delivery.Stops[i].Longitude)
+			distance += leg
+			minutes += stopDwellMinutes + leg/avgSpeed*60
+		}
+		delivery.Stops[i].DistanceRemaining = math.Round(distance*100) / 100
+		delivery.Stops[i].ETAMinutes = int(minutes)
+		delivery.Stops[i].EstimatedArrival = now.Add(time.Duration(minutes) * time.Minute)
+	}
+}
+
 // GetLiveTracking retrieves current tracking data for an order
 // If no active tracking, returns order details with status "pending_pickup"
 func (s *TrackingService) GetLiveTracking(ctx context.Context, orderID uuid.UUID) (*LiveDelivery, error) {
@@ -293,7 +487,7 @@ func (s *TrackingService) GetLiveTracking(ctx context.Context, orderID uuid.UUID
 
 	// No active tracking - return order details with pending status
 	// This allows store apps to get pickup/delivery info before courier starts
-	return &LiveDelivery{
+	pending := &LiveDelivery{
 		OrderID:        order.ID,
 		OrderNumber:    order.OrderNumber,
 		CourierID:      order.CourierID,
@@ -308,7 +502,38 @@ func (s *TrackingService) GetLiveTracking(ctx context.Context, orderID uuid.UUID
 			Latitude:  order.PickupLatitude,
 			Longitude: order.PickupLongitude,
 		},
-	}, nil
+	}
+	pending.setStops(order)
+	return pending, nil
+}
+
+// CourierPositions returns the latest known position of every courier with an active delivery.
+// Fixes older than maxAge are ignored.
+func (s *TrackingService) CourierPositions(ctx context.Context, maxAge time.Duration) (map[uuid.UUID]Location, error) {
+	since := time.Now().Add(-maxAge)
+
+	points, err := s.deliveryRepo.ListCourierPositions(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[uuid.UUID]Location, len(points))
+	for courierID, p := range points {
+		positions[courierID] = Location{Latitude: p.Latitude, Longitude: p.Longitude, Timestamp: p.Timestamp}
+	}
+
+	// Live updates in memory are newer than the last database write
+	s.activeDeliveries.Range(func(_, value interface{}) bool {
+		delivery := value.(*LiveDelivery)
+		current := delivery.CurrentLocation
+		if !delivery.IsActive || current.Timestamp.Before(since) {
+			return true
+		}
+		if known, ok := positions[delivery.CourierID]; !ok || current.Timestamp.After(known.Timestamp) {
+			positions[delivery.CourierID] = current
+		}
+		return true
+	})
+	return positions, nil
 }
 
 // GetLocationHistory retrieves location history for an order
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+// WebhookCourierTransferred is sent to the store when another courier takes over its order
+const WebhookCourierTransferred = "order.courier_transferred"
+
+var (
+	ErrTransferNotFound     = errors.New("transfer not found")
+	ErrInvalidTransfer      = errors.New("invalid transfer")
+	ErrNotTransferRecipient = errors.New("only the receiving courier can answer this transfer")
+	ErrNotTransferProposer  = errors.New("only the courier handing the order over can cancel this transfer")
+	ErrOrderNotTransferable = errors.New("order can only be transferred after acceptance and before delivery")
+	ErrTransferPending      = errors.New("order already has a transfer waiting for an answer")
+	ErrTransferClosed       = errors.New("transfer has already been answered")
+	ErrTransferExpired      = errors.New("transfer request has expired")
+	ErrTransferCODHeld      = errors.New("the courier holds this order's cash on delivery; it cannot be handed over until remitted")
+)
+
+// transferableStatuses are the statuses in which a courier holds an order and can hand it over.
+// Pending orders are declined instead.
+var transferableStatuses = []models.OrderStatus{
+	models.OrderStatusAccepted, models.OrderStatusPickedUp, models.OrderStatusInTransit,
+	models.OrderStatusReattemptScheduled,
+}
+
+// TransferService hands orders from one courier to another: the current courier (or an admin)
+// proposes the handover, the receiving courier accepts, and the earnings, live tracking and
+// notifications follow the order
+type TransferService struct {
+	orderRepo    *repository.OrderRepository
+	courierRepo  *repository.CourierRepository
+	tracking     *TrackingService
+	notification *NotificationService
+	webhooks     *StoreWebhookService
+	cfg          *config.Config
+}
+
+// NewTransferService creates a new order transfer service
+func NewTransferService(
+	orderRepo *repository.OrderRepository,
+	courierRepo *repository.CourierRepository,
+	tracking *TrackingService,
+	notification *NotificationService,
+	webhooks *StoreWebhookService,
+	cfg *config.Config,
+) *TransferService {
+	return &TransferService{
+		orderRepo:    orderRepo,
+		courierRepo:  courierRepo,
+		tracking:     tracking,
+		notification: notification,
+		webhooks:     webhooks,
+		cfg:          cfg,
+	}
+}
+
+// Propose asks another courier to take over one of the courier's orders
+func (s *TransferService) Propose(ctx context.Context, courierID, orderID uuid.UUID, req *models.TransferOrderRequest) (*models.OrderTransfer, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.CourierID != courierID {
+		return nil, ErrNotOrderCourier
+	}
+	return s.propose(ctx, order, "courier", req)
+}
+
+// ProposeByAdmin asks a courier to take over an order on behalf of its current courier
+func (s *TransferService) ProposeByAdmin(ctx context.Context, orderID uuid.UUID, req *models.TransferOrderRequest) (*models.OrderTransfer, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	return s.propose(ctx, order, "admin", req)
+}
+
+func (s *TransferService) propose(ctx context.Context, order *models.Order, requestedBy string, req *models.TransferOrderRequest) (*models.OrderTransfer, error) {
+	if !isTransferable(order.Status) || order.CourierID == uuid.Nil {
+		return nil, ErrOrderNotTransferable
+	}
+	if codHeld(order) {
+		return nil, ErrTransferCODHeld
+	}
+	if req.ToCourierID == uuid.Nil {
+		return nil, fmt.Errorf("%w: toCourierId is required", ErrInvalidTransfer)
+	}
+	if req.ToCourierID == order.CourierID {
+		return nil, fmt.Errorf("%w: the order already belongs to this courier", ErrInvalidTransfer)
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidTransfer)
+	}
+	if _, err := s.receiver(ctx, req.ToCourierID); err != nil {
+		return nil, err
+	}
+
+	transfer := &models.OrderTransfer{
+		OrderID:          order.ID,
+		OrderNumber:      order.OrderNumber,
+		FromCourierID:    order.CourierID,
+		ToCourierID:      req.ToCourierID,
+		RequestedBy:      requestedBy,
+		Reason:           reason,
+		OrderStatus:      order.Status,
+		OriginalEarnings: order.CourierEarnings,
+		ExpiresAt:        time.Now().Add(s.cfg.TransferRequestTTL),
+	}
+	if err := s.orderRepo.CreateTransfer(ctx, transfer); err != nil {
+		if errors.Is(err, repository.ErrTransferPending) {
+			return nil, ErrTransferPending
+		}
+		return nil, fmt.Errorf("failed to create transfer: %w", err)
+	}
+
+	log.Printf("🔁 Transfer of order %s from courier %s to %s proposed by %s", order.OrderNumber, transfer.FromCourierID, transfer.ToCourierID, requestedBy)
+	if err := s.notification.SendTransferRequest(ctx, transfer); err != nil {
+		log.Printf("⚠️ Failed to send transfer request for order %s: %v", order.OrderNumber, err)
+	}
+	return transfer, nil
+}
+
+// Accept hands the order to the receiving courier. The courier earnings are re-split by
+// TRANSFER_EARNINGS_RULE, with the handing-over courier's share credited to their wallet straight
+// away, and live tracking continues under the new courier.
+func (s *TransferService) Accept(ctx context.Context, courierID, transferID uuid.UUID) (*models.OrderTransfer, *models.Order, error) {
+	transfer, err := s.pending(ctx, transferID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if transfer.ToCourierID != courierID {
+		return nil, nil, ErrNotTransferRecipient
+	}
+	courier, err := s.receiver(ctx, courierID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order, err := s.orderRepo.GetByID(ctx, transfer.OrderID)
+	if err != nil {
+		return nil, nil, ErrOrderNotFound
+	}
+	if order.CourierID != transfer.FromCourierID || !isTransferable(order.Status) {
+		s.close(ctx, transfer, models.TransferStatusCancelled, "The order moved on before the handover was accepted")
+		return nil, nil, ErrOrderNotTransferable
+	}
+	if codHeld(order) {
+		s.close(ctx, transfer, models.TransferStatusCancelled, "Cash on delivery was collected before the handover was accepted")
+		return nil, nil, ErrTransferCODHeld
+	}
+
+	transfer.EarningsRule, transfer.FromEarnings, transfer.ToEarnings = s.split(ctx, order)
+	transfer.OriginalEarnings = order.CourierEarnings
+	if err := s.orderRepo.CompleteTransfer(ctx, transfer, transferableStatuses); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrOrderModified):
+			return nil, nil, ErrOrderNotTransferable
+		case errors.Is(err, repository.ErrTransferClosed):
+			return nil, nil, ErrTransferClosed
+		}
+		return nil, nil, fmt.Errorf("failed to complete transfer: %w", err)
+	}
+	order.CourierID = transfer.ToCourierID
+	order.CourierEarnings = transfer.ToEarnings
+
+	if err := s.tracking.TransferDelivery(ctx, order, driverInfo(courier)); err != nil {
+		log.Printf("⚠️ Failed to move live tracking of order %s: %v", order.OrderNumber, err)
+	}
+
+	log.Printf("🔁 Order %s transferred from courier %s to %s (%s split: %.2f / %.2f)", order.OrderNumber,
+		transfer.FromCourierID, transfer.ToCourierID, transfer.EarningsRule, transfer.FromEarnings, transfer.ToEarnings)
+	s.notifyParties(ctx, transfer)
+	s.webhooks.Send(order, WebhookCourierTransferred, map[string]interface{}{
+		"transferId":    transfer.ID,
+		"fromCourierId": transfer.FromCourierID,
+		"toCourierId":   transfer.ToCourierID,
+		"requestedBy":   transfer.RequestedBy,
+		"reason":        transfer.Reason,
+	})
+	return transfer, order, nil
+}
+
+// Reject turns down a handover; the order stays with its current courier
+func (s *TransferService) Reject(ctx context.Context, courierID, transferID uuid.UUID, note string) (*models.OrderTransfer, error) {
+	transfer, err := s.pending(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.ToCourierID != courierID {
+		return nil, ErrNotTransferRecipient
+	}
+	if err := s.close(ctx, transfer, models.TransferStatusRejected, strings.TrimSpace(note)); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// Cancel withdraws a handover before the receiving courier answers
+func (s *TransferService) Cancel(ctx context.Context, courierID, transferID uuid.UUID, note string) (*models.OrderTransfer, error) {
+	transfer, err := s.pending(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.FromCourierID != courierID {
+		return nil, ErrNotTransferProposer
+	}
+	if err := s.close(ctx, transfer, models.TransferStatusCancelled, strings.TrimSpace(note)); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// Incoming returns the handovers waiting for the courier's answer
+func (s *TransferService) Incoming(ctx context.Context, courierID uuid.UUID) ([]models.OrderTransfer, error) {
+	return s.orderRepo.ListIncomingTransfers(ctx, courierID, time.Now())
+}
+
+// ListForCourier returns an order's handover log to its current courier or a courier it was handed between
+func (s *TransferService) ListForCourier(ctx context.Context, courierID, orderID uuid.UUID) ([]models.OrderTransfer, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	transfers, err := s.orderRepo.ListTransfers(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.CourierID == courierID {
+		return transfers, nil
+	}
+	for _, transfer := range transfers {
+		if transfer.FromCourierID == courierID || transfer.ToCourierID == courierID {
+			return transfers, nil
+		}
+	}
+	return nil, ErrNotOrderCourier
+}
+
+// List returns an order's handover log
+func (s *TransferService) List(ctx context.Context, orderID uuid.UUID) ([]models.OrderTransfer, error) {
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, ErrOrderNotFound
+	}
+	return s.orderRepo.ListTransfers(ctx, orderID)
+}
+
+// pending loads a transfer that is still waiting for an answer, expiring it if its time ran out
+func (s *TransferService) pending(ctx context.Context, transferID uuid.UUID) (*models.OrderTransfer, error) {
+	transfer, err := s.orderRepo.GetTransfer(ctx, transferID)
+	if err != nil {
+		return nil, ErrTransferNotFound
+	}
+	if transfer.Status != models.TransferStatusPending {
+		return nil, ErrTransferClosed
+	}
+	if !time.Now().Before(transfer.ExpiresAt) {
+		s.close(ctx, transfer, models.TransferStatusExpired, "")
+		return nil, ErrTransferExpired
+	}
+	return transfer, nil
+}
+
+// close ends a pending transfer and tells both couriers
+func (s *TransferService) close(ctx context.Context, transfer *models.OrderTransfer, status models.TransferStatus, note string) error {
+	if err := s.orderRepo.CloseTransfer(ctx, transfer, status, note); err != nil {
+		if errors.Is(err, repository.ErrTransferClosed) {
+			return ErrTransferClosed
+		}
+		return err
+	}
+	log.Printf("🔁 Transfer of order %s %s", transfer.OrderNumber, status)
+	s.notifyParties(ctx, transfer)
+	return nil
+}
+
+// receiver loads the courier an order is being handed to, who must be active
+func (s *TransferService) receiver(ctx context.Context, courierID uuid.UUID) (*models.Courier, error) {
+	courier, err := s.courierRepo.GetByID(ctx, courierID)
+	if err != nil || !courier.IsActive {
+		return nil, fmt.Errorf("%w: receiving courier not found or inactive", ErrInvalidTransfer)
+	}
+	return courier, nil
+}
+
+// split divides the order's courier earnings between the handing-over and the receiving courier
+func (s *TransferService) split(ctx context.Context, order *models.Order) (models.TransferEarningsRule, float64, float64) {
+	rule := models.TransferEarningsRule(s.cfg.TransferEarningsRule)
+	var share float64
+	switch rule {
+	case models.TransferEarningsReceiver:
+	case models.TransferEarningsFixed:
+		share = s.cfg.TransferOriginalShare
+	default:
+		rule = models.TransferEarningsProgress
+		share = s.progress(ctx, order)
+	}
+	share = math.Min(math.Max(share, 0), 1)
+
+	from := roundMoney(order.CourierEarnings * share)
+	return rule, from, roundMoney(order.CourierEarnings - from)
+}
+
+// progress is the share of the trip the current courier has driven: nothing before pickup, otherwise
+// the distance covered going by the live position. Without a live position it falls back to
+// TRANSFER_ORIGINAL_SHARE.
+func (s *TransferService) progress(ctx context.Context, order *models.Order) float64 {
+	if order.Status == models.OrderStatusAccepted {
+		return 0
+	}
+	delivery, err := s.tracking.GetLiveTracking(ctx, order.ID)
+	if err != nil || !delivery.IsActive || delivery.CurrentLocation.Timestamp.IsZero() || order.Distance <= 0 {
+		return s.cfg.TransferOriginalShare
+	}
+	remaining := delivery.DistanceRemaining
+	if n := len(delivery.Stops); n > 0 {
+		remaining = delivery.Stops[n-1].DistanceRemaining
+	}
+	return 1 - remaining/order.Distance
+}
+
+func (s *TransferService) notifyParties(ctx context.Context, transfer *models.OrderTransfer) {
+	for _, courierID := range []uuid.UUID{transfer.FromCourierID, transfer.ToCourierID} {
+		if err := s.notification.SendTransferUpdate(ctx, courierID.String(), transfer); err != nil {
+			log.Printf("⚠️ Failed to notify courier %s of transfer of order %s: %v", courierID, transfer.OrderNumber, err)
+		}
+	}
+}
+
+// driverInfo describes a courier as the driver shown on live tracking
+func driverInfo(courier *models.Courier) *DriverInfo {
+	info := &DriverInfo{Name: courier.OwnerName, Phone: courier.Phone}
+	if len(courier.VehicleTypes) > 0 {
+		info.VehicleType = courier.VehicleTypes[0]
+	}
+	return info
+}
+
+// codHeld reports whether the order's cash on delivery is with its courier. That cash stays on the
+// courier's liability until remitted, so the order cannot move to another courier.
+func codHeld(order *models.Order) bool {
+	return order.CODStatus == models.CODStatusCollected || order.CODStatus == models.CODStatusRemitting
+}
+
+func isTransferable(status models.OrderStatus) bool {
+	for _, s := range transferableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"nyengo-deliveries/internal/config"
+	"nyengo-deliveries/internal/models"
+	"nyengo-deliveries/internal/repository"
+)
+
+var (
+	ErrVehicleNotFound   = errors.New("vehicle not found")
+	ErrInvalidVehicle    = errors.New("invalid vehicle")
+	ErrVehiclePlateTaken = errors.New("plate is already registered to a vehicle")
+)
+
+// vehicleReminderBatch caps how many vehicles are reminded about per document on each run
+const vehicleReminderBatch = 100
+
+var validVehicleTypes = map[models.VehicleType]bool{
+	models.VehicleTypeBicycle: true, models.VehicleTypeMotorbike: true, models.VehicleTypeCar: true,
+	models.VehicleTypeVan: true, models.VehicleTypeTruck: true,
+}
+
+// packageSizeLitres is the nominal volume of each package size, matched against a registered
+// vehicle's cargo volume. Orders without a size are matched on weight only.
+var packageSizeLitres = map[string]float64{
+	"small":  20,
+	"medium": 60,
+	"large":  200,
+}
+
+// VehicleService manages the vehicles registered by courier companies, decides which couriers
+// have a vehicle able to carry a package, and reminds couriers of expiring vehicle documents
+type VehicleService struct {
+	repo         *repository.VehicleRepository
+	courierRepo  *repository.CourierRepository
+	notification *NotificationService
+	cfg          *config.Config
+}
+
+// NewVehicleService creates a new vehicle service
+func NewVehicleService(
+	repo *repository.VehicleRepository,
+	courierRepo *repository.CourierRepository,
+	notification *NotificationService,
+	cfg *config.Config,
+) *VehicleService {
+	return &VehicleService{
+		repo:         repo,
+		courierRepo:  courierRepo,
+		notification: notification,
+		cfg:          cfg,
+	}
+}
+
+// Register adds a vehicle to the courier company
+func (s *VehicleService) Register(ctx context.Context, courierID uuid.UUID, req *models.VehicleRequest) (*models.Vehicle, error) {
+	vehicle := &models.Vehicle{
+		CourierID:      courierID,
+		Type:           models.VehicleType(strings.ToLower(strings.TrimSpace(string(req.Type)))),
+		Plate:          normalizePlate(req.Plate),
+		Description:    strings.TrimSpace(req.Description),
+		CapacityKg:     req.CapacityKg,
+		CapacityLitres: req.CapacityLitres,
+		IsActive:       true,
+	}
+
+	var err error
+	if vehicle.InsuranceExpiry, err = parseVehicleDate("insuranceExpiry", req.InsuranceExpiry); err != nil {
+		return nil, err
+	}
+	if vehicle.RoadworthyExpiry, err = parseVehicleDate("roadworthyExpiry", req.RoadworthyExpiry); err != nil {
+		return nil, err
+	}
+	if err := validateVehicle(vehicle); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, vehicle); err != nil {
+		if errors.Is(err, repository.ErrVehiclePlateTaken) {
+			return nil, ErrVehiclePlateTaken
+		}
+		return nil, fmt.Errorf("failed to register vehicle: %w", err)
+	}
+
+	log.Printf("🚐 Vehicle %s (%s) registered for courier %s", vehicle.ID, vehicle.Type, courierID)
+	return s.annotate(vehicle, time.Now()), nil
+}
+
+// List returns the courier's vehicles, optionally only those of the type or only the active ones
+func (s *VehicleService) List(ctx context.Context, courierID uuid.UUID, vehicleType string, activeOnly bool) ([]models.Vehicle, error) {
+	vehicles, err := s.repo.ListByCourier(ctx, courierID, strings.ToLower(vehicleType), activeOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range vehicles {
+		s.annotate(&vehicles[i], now)
+	}
+	return vehicles, nil
+}
+
+// Get returns one of the courier's vehicles
+func (s *VehicleService) Get(ctx context.Context, courierID, vehicleID uuid.UUID) (*models.Vehicle, error) {
+	vehicle, err := s.get(ctx, courierID, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.annotate(vehicle, time.Now()), nil
+}
+
+// Update changes a vehicle's details or documents, or takes it out of and back into service
+func (s *VehicleService) Update(ctx context.Context, courierID, vehicleID uuid.UUID, req *models.VehicleUpdateRequest) (*models.Vehicle, error) {
+	vehicle, err := s.get(ctx, courierID, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Type != nil {
+		vehicle.Type = models.VehicleType(strings.ToLower(strings.TrimSpace(string(*req.Type))))
+	}
+	if req.Plate != nil {
+		vehicle.Plate = normalizePlate(*req.Plate)
+	}
+	if req.Description != nil {
+		vehicle.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.CapacityKg != nil {
+		vehicle.CapacityKg = *req.CapacityKg
+	}
+	if req.CapacityLitres != nil {
+		vehicle.CapacityLitres = *req.CapacityLitres
+	}
+	if req.InsuranceExpiry != nil {
+		if vehicle.InsuranceExpiry, err = parseVehicleDate("insuranceExpiry", *req.InsuranceExpiry); err != nil {
+			return nil, err
+		}
+	}
+	if req.RoadworthyExpiry != nil {
+		if vehicle.RoadworthyExpiry, err = parseVehicleDate("roadworthyExpiry", *req.RoadworthyExpiry); err != nil {
+			return nil, err
+		}
+	}
+	if req.IsActive != nil {
+		vehicle.IsActive = *req.IsActive
+	}
+	if err := validateVehicle(vehicle); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, vehicle); err != nil {
+		if errors.Is(err, repository.ErrVehiclePlateTaken) {
+			return nil, ErrVehiclePlateTaken
+		}
+		return nil, fmt.Errorf("failed to update vehicle: %w", err)
+	}
+	return s.annotate(vehicle, time.Now()), nil
+}
+
+// Delete removes one of the courier's vehicles from the registry
+func (s *VehicleService) Delete(ctx context.Context, courierID, vehicleID uuid.UUID) error {
+	if _, err := s.get(ctx, courierID, vehicleID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, vehicleID); err != nil {
+		if errors.Is(err, repository.ErrVehicleNotFound) {
+			return ErrVehicleNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Fleets returns the registered vehicles of every active courier, by courier
+func (s *VehicleService) Fleets(ctx context.Context) (map[uuid.UUID][]models.Vehicle, error) {
+	vehicles, err := s.repo.ListByActiveCouriers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fleets := make(map[uuid.UUID][]models.Vehicle)
+	for _, vehicle := range vehicles {
+		fleets[vehicle.CourierID] = append(fleets[vehicle.CourierID], vehicle)
+	}
+	return fleets, nil
+}
+
+// CapableCouriers returns the active couriers with a vehicle able to carry a package of the weight
+// (in kg) and size
+func (s *VehicleService) CapableCouriers(ctx context.Context, weight float64, size string) (map[uuid.UUID]bool, error) {
+	couriers, err := s.courierRepo.ListDispatchCandidates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load couriers: %w", err)
+	}
+	fleets, err := s.Fleets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vehicles: %w", err)
+	}
+
+	now := time.Now()
+	capable := make(map[uuid.UUID]bool)
+	for i := range couriers {
+		if cargoMismatch(&couriers[i], fleets[couriers[i].ID], weight, size, now) == "" {
+			capable[couriers[i].ID] = true
+		}
+	}
+	return capable, nil
+}
+
+// Run sends expiry reminders every interval until ctx is cancelled
+func (s *VehicleService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunOnce(ctx, time.Now())
+		}
+	}
+}
+
+// RunOnce reminds couriers once about every vehicle document expiring within the reminder lead time.
+// Documents that have already expired without a reminder are reported as expired.
+func (s *VehicleService) RunOnce(ctx context.Context, now time.Time) {
+	for _, document := range []string{models.VehicleDocumentInsurance, models.VehicleDocumentRoadworthiness} {
+		vehicles, err := s.repo.ClaimExpiryReminders(ctx, document, now.Add(s.cfg.VehicleExpiryReminderLead), vehicleReminderBatch)
+		if err != nil {
+			log.Printf("⚠️ Failed to claim vehicle %s reminders: %v", document, err)
+			continue
+		}
+
+		for i := range vehicles {
+			vehicle := &vehicles[i]
+			expiry := vehicle.InsuranceExpiry
+			if document == models.VehicleDocumentRoadworthiness {
+				expiry = vehicle.RoadworthyExpiry
+			}
+			if expiry == nil {
+				continue
+			}
+			if err := s.notification.SendVehicleDocumentReminder(ctx, vehicle, document, *expiry, documentExpired(expiry, now)); err != nil {
+				log.Printf("⚠️ Failed to send %s reminder for vehicle %s: %v", document, vehicle.ID, err)
+			}
+		}
+	}
+}
+
+func (s *VehicleService) get(ctx context.Context, courierID, vehicleID uuid.UUID) (*models.Vehicle, error) {
+	vehicle, err := s.repo.GetByID(ctx, vehicleID)
+	if err != nil {
+		if errors.Is(err, repository.ErrVehicleNotFound) {
+			return nil, ErrVehicleNotFound
+		}
+		return nil, err
+	}
+	if vehicle.CourierID != courierID {
+		return nil, ErrVehicleNotFound
+	}
+	return vehicle, nil
+}
+
+// annotate sets the warnings about the vehicle's expired and soon expiring documents
+func (s *VehicleService) annotate(vehicle *models.Vehicle, now time.Time) *models.Vehicle {
+	vehicle.Warnings = nil
+	documents := []struct {
+		name   string
+		expiry *time.Time
+	}{
+		{models.VehicleDocumentInsurance, vehicle.InsuranceExpiry},
+		{models.VehicleDocumentRoadworthiness, vehicle.RoadworthyExpiry},
+	}
+	for _, document := range documents {
+		switch {
+		case document.expiry == nil:
+		case documentExpired(document.expiry, now):
+			vehicle.Warnings = append(vehicle.Warnings, fmt.Sprintf("%s expired on %s", document.name, document.expiry.Format("2006-01-02")))
+		case document.expiry.Before(now.Add(s.cfg.VehicleExpiryReminderLead)):
+			vehicle.Warnings = append(vehicle.Warnings, fmt.Sprintf("%s expires on %s", document.name, document.expiry.Format("2006-01-02")))
+		}
+	}
+	return vehicle
+}
+
+// cargoMismatch returns why none of a courier's vehicles can carry a package of the weight (in kg)
+// and size, or "" if one can. Couriers who registered vehicles are matched on those that are in
+// service with unexpired documents; the others on the vehicle types and weight limit of their profile.
+func cargoMismatch(courier *models.Courier, fleet []models.Vehicle, weight float64, size string, now time.Time) string {
+	if len(fleet) == 0 {
+		switch {
+		case courier.MaxWeight > 0 && weight > courier.MaxWeight:
+			return fmt.Sprintf("Package weighs %.1f kg, over its %.1f kg limit", weight, courier.MaxWeight)
+		case !canCarry(courier.VehicleTypes, size):
+			return fmt.Sprintf("No vehicle suited to a %s package (has %s)", size, strings.Join(courier.VehicleTypes, ", "))
+		}
+		return ""
+	}
+
+	litres := packageSizeLitres[strings.ToLower(size)]
+	usable := 0
+	var maxKg, maxLitres float64
+	for i := range fleet {
+		vehicle := &fleet[i]
+		if !vehicleInService(vehicle, now) {
+			continue
+		}
+		if vehicle.CapacityKg >= weight && vehicle.CapacityLitres >= litres {
+			return ""
+		}
+		usable++
+		maxKg = math.Max(maxKg, vehicle.CapacityKg)
+		maxLitres = math.Max(maxLitres, vehicle.CapacityLitres)
+	}
+
+	if usable == 0 {
+		return fmt.Sprintf("None of its %d registered vehicles is in service with valid insurance and roadworthiness", len(fleet))
+	}
+	pkg := fmt.Sprintf("%.1f kg", weight)
+	if size != "" {
+		pkg += " " + size
+	}
+	return fmt.Sprintf("No vehicle can carry a %s package (largest take %.1f kg, %.0f L)", pkg, maxKg, maxLitres)
+}
+
+// vehicleInService reports whether a vehicle is active and neither its insurance nor its
+// roadworthiness has expired
+func vehicleInService(vehicle *models.Vehicle, now time.Time) bool {
+	return vehicle.IsActive && !documentExpired(vehicle.InsuranceExpiry, now) && !documentExpired(vehicle.RoadworthyExpiry, now)
+}
+
+// documentExpired reports whether an expiry date has passed; a document is valid through its expiry day
+func documentExpired(expiry *time.Time, now time.Time) bool {
+	return expiry != nil && !now.Before(expiry.AddDate(0, 0, 1))
+}
+
+func validateVehicle(vehicle *models.Vehicle) error {
+	switch {
+	case !validVehicleTypes[vehicle.Type]:
+		return fmt.Errorf("%w: type must be bicycle, motorbike, car, van or truck", ErrInvalidVehicle)
+	case vehicle.Plate == "" && vehicle.Type != models.VehicleTypeBicycle:
+		return fmt.Errorf("%w: plate is required for a %s", ErrInvalidVehicle, vehicle.Type)
+	case vehicle.CapacityKg <= 0 || vehicle.CapacityLitres <= 0:
+		return fmt.Errorf("%w: capacityKg and capacityLitres must be greater than zero", ErrInvalidVehicle)
+	}
+	return nil
+}
+
+// normalizePlate upper-cases a plate and drops its spaces, so the same plate is always stored alike
+func normalizePlate(plate string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(plate)), "")
+}
+
+func parseVehicleDate(field, value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be a YYYY-MM-DD date", ErrInvalidVehicle, field)
+	}
+	return &date, nil
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore keeps blobs on the local filesystem and serves them through signed API URLs
+type LocalStore struct {
+	root          string
+	publicURL     string
+	signingSecret []byte
+}
+
+// NewLocalStore creates a filesystem blob store rooted at root.
+// publicURL is the externally reachable base URL of the API, used to build download links.
+func NewLocalStore(root, publicURL, signingSecret string) (*LocalStore, error) {
+	if signingSecret == "" {
+		return nil, errors.New("local storage requires a signing secret")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStore{
+		root:          root,
+		publicURL:     strings.TrimRight(publicURL, "/"),
+		signingSecret: []byte(signingSecret),
+	}, nil
+}
+
+// Put implements BlobStore
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp := filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filePath)
+}
+
+// Delete implements BlobStore
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL implements BlobStore
+func (s *LocalStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.sign(key, expires))
+
+	return fmt.Sprintf("%s/api/v1/files/%s?%s", s.publicURL, key, query.Encode()), nil
+}
+
+// Open verifies a signed download request and returns the path of the file to serve
+func (s *LocalStore) Open(key, expiresParam, signature string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", errors.New("download link has expired")
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, expires))) {
+		return "", errors.New("invalid download signature")
+	}
+
+	filePath, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		return "", ErrNotFound
+	}
+	return filePath, nil
+}
+
+// path maps a key to a location inside the store root
+func (s *LocalStore) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// sign creates the HMAC signature for a key and expiry
+func (s *LocalStore) sign(key string, expires int64) string {
+	h := hmac.New(sha256.New, s.signingSecret)
+	h.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
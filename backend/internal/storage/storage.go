@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"nyengo-deliveries/internal/config"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are empty or try to escape the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore stores binary objects such as delivery photos and signatures
+type BlobStore interface {
+	// Put stores data under key, replacing any existing object
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+
+	// Delete removes the object stored under key
+	Delete(ctx context.Context, key string) error
+
+	// SignedURL returns a time-limited URL that can be used to download the object
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// NewBlobStore creates the blob store selected by configuration
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	switch cfg.StorageDriver {
+	case "local", "":
+		return NewLocalStore(cfg.StorageLocalPath, cfg.StoragePublicURL, cfg.StorageSigningSecret)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.StorageDriver)
+	}
+}
+
+// cleanKey normalises a key and rejects anything that could escape the store root
+func cleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	if key == "" || key == "." || strings.Contains(key, "..") {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // register PNG decoder
+	"net/http"
+)
+
+// Allowed image content types for uploads
+const (
+	ContentTypeJPEG = "image/jpeg"
+	ContentTypePNG  = "image/png"
+)
+
+// ThumbnailMaxSize is the longest edge of generated thumbnails in pixels
+const ThumbnailMaxSize = 320
+
+// ErrUnsupportedImage is returned when an upload is not a JPEG or PNG image
+var ErrUnsupportedImage = errors.New("only JPEG and PNG images are supported")
+
+// ValidateImage checks the size, pixel count and content type of an uploaded image.
+// The content type is sniffed from the data rather than trusted from the client.
+func ValidateImage(data []byte, maxSize, maxPixels int) (string, error) {
+	if len(data) == 0 {
+		return "", errors.New("image is empty")
+	}
+	if maxSize > 0 && len(data) > maxSize {
+		return "", fmt.Errorf("image exceeds maximum size of %d KB", maxSize/1024)
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != ContentTypeJPEG && contentType != ContentTypePNG {
+		return "", ErrUnsupportedImage
+	}
+
+	// Make sure the image actually decodes, without decoding more pixels than allowed
+	if _, err := decodeConfig(data, maxPixels); err != nil {
+		return "", err
+	}
+
+	return contentType, nil
+}
+
+// decodeConfig reads the dimensions from an image header and rejects images with more than
+// maxPixels pixels, so a small compressed upload cannot expand into a huge bitmap when decoded
+func decodeConfig(data []byte, maxPixels int) (image.Config, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return cfg, fmt.Errorf("invalid image: %w", err)
+	}
+	if maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return cfg, fmt.Errorf("image exceeds maximum of %d pixels", maxPixels)
+	}
+	return cfg, nil
+}
+
+// ImageExtension returns the file extension for an allowed content type
+func ImageExtension(contentType string) string {
+	if contentType == ContentTypePNG {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// GenerateThumbnail scales an image down so its longest edge is at most maxSize
+// pixels and encodes the result as JPEG. Images with more than maxPixels pixels are
+// rejected before decoding.
+func GenerateThumbnail(data []byte, maxSize, maxPixels int) ([]byte, error) {
+	if _, err := decodeConfig(data, maxPixels); err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, errors.New("image has no pixels")
+	}
+
+	// Never upscale
+	scale := 1.0
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			scale = float64(maxSize) / float64(width)
+		} else {
+			scale = float64(maxSize) / float64(height)
+		}
+	}
+	dstWidth := max(1, int(float64(width)*scale))
+	dstHeight := max(1, int(float64(height)*scale))
+
+	// Box-filter downscale: average every source pixel that falls into each destination pixel.
+	// Transparent areas are composited onto white since JPEG has no alpha channel.
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/dstHeight)
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/dstWidth)
+
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					white := 0xffff - uint64(pa)
+					r += uint64(pr) + white
+					g += uint64(pg) + white
+					b += uint64(pb) + white
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: 0xffff,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -3,6 +3,7 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"sync"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 type Client struct {
 	ID            string
 	CourierID     uuid.UUID
+	DriverID      uuid.UUID // uuid.Nil when the courier account is connected
 	Conn          *websocket.Conn
 	Send          chan []byte
 	Subscriptions map[string]bool // order IDs subscribed to
@@ -38,9 +40,23 @@ type Hub struct {
 	// Redis for cross-instance pub/sub
 	redis *redis.Client
 
+	// Handles shift messages, when set
+	shiftHandler ShiftHandler
+
+	// Checks location updates before they are broadcast; without it they are dropped
+	locationAuthorizer LocationAuthorizer
+
 	mu sync.RWMutex
 }
 
+// ShiftHandler performs a shift action (online, offline or heartbeat) for the courier account
+// (driverID uuid.Nil) or a driver and returns the payload of the reply
+type ShiftHandler func(ctx context.Context, courierID, driverID uuid.UUID, action string) (interface{}, error)
+
+// LocationAuthorizer checks that the courier account (driverID uuid.Nil) or a driver may send live
+// locations for an order
+type LocationAuthorizer func(ctx context.Context, courierID, driverID, orderID uuid.UUID) error
+
 // Message types
 type WSMessage struct {
 	Type    string          `json:"type"`
@@ -56,6 +72,10 @@ type LocationPayload struct {
 	Timestamp int64   `json:"timestamp"`
 }
 
+type ShiftPayload struct {
+	Action string `json:"action"` // "online", "offline" or "heartbeat"
+}
+
 type SubscribePayload struct {
 	OrderID string `json:"orderId"`
 	Action  string `json:"action"` // "subscribe" or "unsubscribe"
@@ -93,6 +113,16 @@ func (h *Hub) SetRedis(redis *redis.Client) {
 	}
 }
 
+// SetShiftHandler sets the handler for shift messages sent by couriers and drivers
+func (h *Hub) SetShiftHandler(handler ShiftHandler) {
+	h.shiftHandler = handler
+}
+
+// SetLocationAuthorizer sets the check location updates sent by couriers and drivers must pass
+func (h *Hub) SetLocationAuthorizer(authorizer LocationAuthorizer) {
+	h.locationAuthorizer = authorizer
+}
+
 // Run starts the hub event loop
 func (h *Hub) Run() {
 	for {
@@ -265,10 +295,12 @@ func (h *Hub) subscribeToRedis() {
 func HandleWebSocket(hub *Hub) fiber.Handler {
 	return websocket.New(func(c *websocket.Conn) {
 		courierID := c.Locals("courier_id").(uuid.UUID)
+		driverID, _ := c.Locals("driver_id").(uuid.UUID)
 
 		client := &Client{
 			ID:            uuid.New().String(),
 			CourierID:     courierID,
+			DriverID:      driverID,
 			Conn:          c,
 			Send:          make(chan []byte, 256),
 			Subscriptions: make(map[string]bool),
@@ -305,9 +337,9 @@ func HandleWebSocket(hub *Hub) fiber.Handler {
 			// Handle different message types
 			switch msg.Type {
 			case "location_update":
-				// Driver sending location - broadcast to subscribers
+				// Driver sending location - broadcast to subscribers if they work the order
 				var loc LocationPayload
-				if json.Unmarshal(msg.Payload, &loc) == nil {
+				if json.Unmarshal(msg.Payload, &loc) == nil && hub.authorizeLocation(client, loc.OrderID) {
 					update := &TrackingUpdate{
 						OrderID:   loc.OrderID,
 						Latitude:  loc.Latitude,
@@ -330,8 +362,18 @@ func HandleWebSocket(hub *Hub) fiber.Handler {
 					}
 				}
 
+			case "shift":
+				// Courier or driver going online or offline, or keeping their shift alive
+				var shift ShiftPayload
+				if json.Unmarshal(msg.Payload, &shift) == nil && hub.shiftHandler != nil {
+					client.Send <- shiftReply(hub.shiftHandler, client, shift.Action)
+				}
+
 			case "ping":
-				// Respond with pong
+				// Respond with pong; a ping also keeps an open shift alive
+				if hub.shiftHandler != nil {
+					hub.shiftHandler(context.Background(), client.CourierID, client.DriverID, "heartbeat")
+				}
 				pong, _ := json.Marshal(WSMessage{Type: "pong"})
 				client.Send <- pong
 			}
@@ -339,6 +381,34 @@ func HandleWebSocket(hub *Hub) fiber.Handler {
 	})
 }
 
+// authorizeLocation reports whether the client may send live locations for an order
+func (h *Hub) authorizeLocation(client *Client, orderID string) bool {
+	id, err := uuid.Parse(orderID)
+	if err != nil || h.locationAuthorizer == nil {
+		return false
+	}
+	if err := h.locationAuthorizer(context.Background(), client.CourierID, client.DriverID, id); err != nil {
+		log.Printf("⚠️ Dropped location update for order %s from courier %s (driver %s): %v", orderID, client.CourierID, client.DriverID, err)
+		return false
+	}
+	return true
+}
+
+// shiftReply performs a shift action for the client and returns a shift_update message, or a
+// shift_error message with the reason it failed
+func shiftReply(handler ShiftHandler, client *Client, action string) []byte {
+	result, err := handler(context.Background(), client.CourierID, client.DriverID, action)
+	if err != nil {
+		payload, _ := json.Marshal(map[string]string{"action": action, "message": err.Error()})
+		reply, _ := json.Marshal(WSMessage{Type: "shift_error", Payload: payload})
+		return reply
+	}
+
+	payload, _ := json.Marshal(result)
+	reply, _ := json.Marshal(WSMessage{Type: "shift_update", Payload: payload})
+	return reply
+}
+
 // GetActiveSubscribers returns count of clients watching an order
 func (h *Hub) GetActiveSubscribers(orderID string) int {
 	h.mu.RLock()
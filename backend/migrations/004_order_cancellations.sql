@@ -0,0 +1,17 @@
+-- Nyengo Deliveries - Order Cancellation Migration
+-- Adds cancellation details and refund tracking to orders
+
+-- ============================================================
+-- ADD CANCELLATION COLUMNS TO ORDERS TABLE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(50);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_reason TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_fee DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refund_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_cancelled_at ON orders(cancelled_at) WHERE cancelled_at IS NOT NULL;
+
+COMMENT ON COLUMN orders.cancelled_by IS 'Party that cancelled the order: courier or store';
+COMMENT ON COLUMN orders.cancellation_fee IS 'Fee charged to the store for a late cancellation';
+COMMENT ON COLUMN orders.refund_amount IS 'Amount refunded to the payer after cancellation';
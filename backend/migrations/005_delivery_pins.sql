@@ -0,0 +1,30 @@
+-- Nyengo Deliveries - Delivery PIN Migration
+-- Adds one-time PIN handoff confirmation for deliveries
+
+-- ============================================================
+-- ADD DELIVERY PIN COLUMNS TO ORDERS TABLE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_pin_hash VARCHAR(255);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_pin_sent_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_pin_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_pin_locked_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_pin_verified_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN orders.delivery_pin_hash IS 'bcrypt hash of the PIN sent to the customer when the order went in transit';
+COMMENT ON COLUMN orders.delivery_pin_attempts IS 'Consecutive wrong PIN submissions since the last lockout';
+COMMENT ON COLUMN orders.delivery_pin_verified_at IS 'When the driver submitted the correct PIN at handoff';
+
+-- ============================================================
+-- DELIVERY PIN ATTEMPTS TABLE (audit log)
+-- ============================================================
+CREATE TABLE IF NOT EXISTS delivery_pin_attempts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    courier_id UUID NOT NULL REFERENCES couriers(id),
+    success BOOLEAN NOT NULL,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_pin_attempts_order ON delivery_pin_attempts(order_id);
+CREATE INDEX IF NOT EXISTS idx_delivery_pin_attempts_failed ON delivery_pin_attempts(created_at) WHERE success = FALSE;
@@ -0,0 +1,32 @@
+-- Nyengo Deliveries - Order Ratings Migration
+-- Records who rated a delivery and when, and backfills courier review counts
+
+-- ============================================================
+-- ADD RATING COLUMNS TO ORDERS TABLE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS rated_by VARCHAR(50);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS rated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_courier_rating ON orders(courier_id) WHERE customer_rating IS NOT NULL;
+
+COMMENT ON COLUMN orders.rated_by IS 'Who submitted the rating: store or customer';
+
+-- ============================================================
+-- BACKFILL COURIER AGGREGATES
+-- ============================================================
+UPDATE couriers c SET
+    total_deliveries = s.delivered,
+    success_rate = CASE WHEN s.delivered + s.failed > 0
+        THEN ROUND(s.delivered * 100.0 / (s.delivered + s.failed), 2) ELSE 0 END,
+    total_reviews = s.reviews,
+    rating = ROUND(COALESCE(s.rating, 0), 2)
+FROM (
+    SELECT courier_id,
+        COUNT(*) FILTER (WHERE status = 'delivered') AS delivered,
+        COUNT(*) FILTER (WHERE status = 'failed') AS failed,
+        COUNT(customer_rating) AS reviews,
+        AVG(customer_rating) AS rating
+    FROM orders
+    GROUP BY courier_id
+) s
+WHERE c.id = s.courier_id;
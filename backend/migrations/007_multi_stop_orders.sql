@@ -0,0 +1,49 @@
+-- Nyengo Deliveries - Multi-stop Orders Migration
+-- Adds ordered drop-offs for one pickup, and store webhook URLs for per-stop events
+
+-- ============================================================
+-- ORDER TYPE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS order_type VARCHAR(20) NOT NULL DEFAULT 'standard';
+
+COMMENT ON COLUMN orders.order_type IS 'standard (one drop-off) or multi_stop (see order_stops)';
+
+-- ============================================================
+-- ORDER_STOPS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS order_stops (
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    sequence INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    recipient_name VARCHAR(200) NOT NULL,
+    recipient_phone VARCHAR(30) NOT NULL,
+    address TEXT NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    notes TEXT,
+    package_description TEXT,
+    cod_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    distance_from_previous DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    estimated_arrival TIMESTAMP WITH TIME ZONE,
+    delivery_proof_url TEXT,
+    signature_url TEXT,
+    received_by VARCHAR(200),
+    failure_reason TEXT,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (order_id, sequence),
+    CONSTRAINT valid_stop_status CHECK (status IN ('pending', 'delivered', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_stops_order ON order_stops(order_id, sequence);
+
+COMMENT ON TABLE order_stops IS 'Drop-offs on multi-stop orders, visited in sequence order';
+
+-- ============================================================
+-- STORE WEBHOOKS
+-- ============================================================
+ALTER TABLE store_payment_configs ADD COLUMN IF NOT EXISTS webhook_url TEXT;
+
+COMMENT ON COLUMN store_payment_configs.webhook_url IS 'Receives signed order event webhooks (e.g. stop.delivered)';
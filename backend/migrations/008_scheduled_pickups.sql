@@ -0,0 +1,14 @@
+-- Nyengo Deliveries - Scheduled Pickups Migration
+-- Tracks pickup reminders and overdue flags for orders with a scheduled pickup
+
+-- ============================================================
+-- ADD SCHEDULING COLUMNS TO ORDERS TABLE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pickup_reminder_sent_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pickup_overdue_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_scheduled_pickup ON orders(scheduled_pickup)
+    WHERE scheduled_pickup IS NOT NULL AND status IN ('scheduled', 'pending', 'accepted', 'picked_up');
+
+COMMENT ON COLUMN orders.pickup_reminder_sent_at IS 'When the courier was reminded of the scheduled pickup';
+COMMENT ON COLUMN orders.pickup_overdue_at IS 'Set when live tracking had not started by the scheduled pickup';
@@ -0,0 +1,39 @@
+-- Nyengo Deliveries - Recurring Delivery Subscriptions Migration
+-- Stores recurring order templates and the orders generated for each occurrence
+
+-- ============================================================
+-- DELIVERY_SUBSCRIPTIONS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS delivery_subscriptions (
+    id UUID PRIMARY KEY,
+    store_id UUID NOT NULL,
+    courier_id UUID NOT NULL REFERENCES couriers(id),
+    name VARCHAR(200) NOT NULL,
+    template JSONB NOT NULL,
+    rule JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    skipped_dates JSONB NOT NULL DEFAULT '[]',
+    generated_count INTEGER NOT NULL DEFAULT 0,
+    last_occurrence TIMESTAMP WITH TIME ZONE,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_subscription_status CHECK (status IN ('active', 'paused', 'ended'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_subscriptions_store ON delivery_subscriptions(store_id);
+CREATE INDEX IF NOT EXISTS idx_delivery_subscriptions_active ON delivery_subscriptions(status) WHERE status = 'active';
+
+COMMENT ON TABLE delivery_subscriptions IS 'Recurring deliveries: a CreateOrderRequest template plus a recurrence rule';
+
+-- ============================================================
+-- SUBSCRIPTION_OCCURRENCES TABLE
+-- ============================================================
+-- One row per generated occurrence; the primary key stops two instances generating the same order
+CREATE TABLE IF NOT EXISTS subscription_occurrences (
+    subscription_id UUID NOT NULL REFERENCES delivery_subscriptions(id) ON DELETE CASCADE,
+    occurrence_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    order_id UUID REFERENCES orders(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subscription_id, occurrence_at)
+);
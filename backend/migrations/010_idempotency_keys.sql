@@ -0,0 +1,25 @@
+-- Nyengo Deliveries - Idempotency Keys Migration
+-- Stores the response of mutating store and payment requests so retries are replayed, not repeated
+
+-- ============================================================
+-- IDEMPOTENCY_KEYS TABLE
+-- ============================================================
+-- scope is the caller (hashed store API key or courier ID), so two callers can reuse the same key
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(100) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'processing',
+    response_status INTEGER,
+    response_body BYTEA,
+    content_type VARCHAR(100),
+    locked_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (scope, idempotency_key),
+    CONSTRAINT valid_idempotency_status CHECK (status IN ('processing', 'completed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+COMMENT ON TABLE idempotency_keys IS 'Idempotency-Key header records: request fingerprint and stored response, kept until expires_at';
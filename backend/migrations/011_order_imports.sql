@@ -0,0 +1,28 @@
+-- Nyengo Deliveries - Bulk Order Imports Migration
+-- Records each CSV/JSON import job with its per-row report so it can be downloaded later
+
+-- ============================================================
+-- ORDER_IMPORTS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS order_imports (
+    id UUID PRIMARY KEY,
+    store_id UUID,
+    format VARCHAR(10) NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    on_error VARCHAR(20) NOT NULL DEFAULT 'continue',
+    status VARCHAR(20) NOT NULL DEFAULT 'processing',
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    valid_rows INTEGER NOT NULL DEFAULT 0,
+    created_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    total_fare DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    rows JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT valid_import_status CHECK (status IN ('processing', 'completed', 'partial', 'failed', 'rejected')),
+    CONSTRAINT valid_import_on_error CHECK (on_error IN ('continue', 'abort'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_imports_store ON order_imports(store_id, created_at DESC);
+
+COMMENT ON TABLE order_imports IS 'Bulk order import jobs; rows holds the per-row result report';
@@ -0,0 +1,36 @@
+-- Nyengo Deliveries - Order Amendments Migration
+-- Log of changes to an order's drop-off and package details, with the resulting fare delta
+
+-- ============================================================
+-- ORDER_AMENDMENTS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS order_amendments (
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    requested_by VARCHAR(20) NOT NULL,
+    reason TEXT,
+    status VARCHAR(20) NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    request JSONB NOT NULL DEFAULT '{}',
+    previous_distance DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    new_distance DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    previous_fare DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    new_fare DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    fare_delta DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    previous_earnings DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    new_earnings DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    earnings_delta DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    decided_by VARCHAR(20),
+    decision_note TEXT,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_amendment_status CHECK (status IN ('applied', 'pending_approval', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_amendments_order ON order_amendments(order_id, created_at);
+
+-- At most one amendment per order can be waiting for store approval
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_amendments_one_pending
+    ON order_amendments(order_id) WHERE status = 'pending_approval';
+
+COMMENT ON TABLE order_amendments IS 'Amendment log: every change to an order''s delivery or package details and its repricing';
@@ -0,0 +1,48 @@
+-- Nyengo Deliveries - Failed Delivery Attempts Migration
+-- Log of failed delivery attempts, re-attempt scheduling and return-to-sender legs
+
+-- ============================================================
+-- ADD ATTEMPT AND RETURN COLUMNS TO ORDERS TABLE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS reattempt_reminder_sent_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS return_order_id UUID REFERENCES orders(id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS return_of_order_id UUID REFERENCES orders(id);
+
+CREATE INDEX IF NOT EXISTS idx_orders_next_attempt ON orders(next_attempt_at)
+    WHERE status = 'reattempt_scheduled';
+
+-- An order is returned by at most one return leg
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_return_of ON orders(return_of_order_id)
+    WHERE return_of_order_id IS NOT NULL;
+
+COMMENT ON COLUMN orders.order_type IS 'standard (one drop-off), multi_stop (see order_stops) or return (return-to-sender leg)';
+COMMENT ON COLUMN orders.delivery_attempts IS 'Number of failed delivery attempts so far';
+COMMENT ON COLUMN orders.next_attempt_at IS 'When the next delivery attempt is planned';
+COMMENT ON COLUMN orders.reattempt_reminder_sent_at IS 'When the courier was reminded that the re-attempt is due';
+COMMENT ON COLUMN orders.return_order_id IS 'Return-to-sender leg created once delivery attempts were exhausted';
+COMMENT ON COLUMN orders.return_of_order_id IS 'On a return leg: the order whose parcel is being returned';
+
+-- ============================================================
+-- DELIVERY_ATTEMPTS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS delivery_attempts (
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    courier_id UUID NOT NULL REFERENCES couriers(id),
+    attempt_number INTEGER NOT NULL,
+    reason VARCHAR(30) NOT NULL,
+    note TEXT,
+    outcome VARCHAR(30) NOT NULL,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    return_order_id UUID REFERENCES orders(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_attempt_number UNIQUE (order_id, attempt_number),
+    CONSTRAINT valid_attempt_reason CHECK (reason IN ('customer_absent', 'wrong_address', 'refused', 'customer_unreachable', 'other')),
+    CONSTRAINT valid_attempt_outcome CHECK (outcome IN ('reattempt_scheduled', 'return_to_sender', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_attempts_order ON delivery_attempts(order_id, attempt_number);
+
+COMMENT ON TABLE delivery_attempts IS 'Failed delivery attempts with the driver''s reason code and what happened next';
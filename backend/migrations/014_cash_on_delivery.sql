@@ -0,0 +1,58 @@
+-- Nyengo Deliveries - Cash on Delivery Migration
+-- Goods payments collected by drivers, courier cash liability and remittances to stores
+
+-- ============================================================
+-- COD_REMITTANCES TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS cod_remittances (
+    id UUID PRIMARY KEY,
+    courier_id UUID NOT NULL REFERENCES couriers(id),
+    store_id UUID NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    order_count INTEGER NOT NULL DEFAULT 0,
+    method VARCHAR(20) NOT NULL,
+    reference VARCHAR(100),
+    note TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    decision_note TEXT,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_remittance_status CHECK (status IN ('pending', 'confirmed', 'rejected')),
+    CONSTRAINT valid_remittance_method CHECK (method IN ('cash', 'mobile_money', 'bank_deposit'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_cod_remittances_store ON cod_remittances(store_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_cod_remittances_courier ON cod_remittances(courier_id, created_at DESC);
+
+-- A courier has at most one unconfirmed remittance per store
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cod_remittances_one_pending
+    ON cod_remittances(courier_id, store_id) WHERE status = 'pending';
+
+COMMENT ON TABLE cod_remittances IS 'Cash-on-delivery cash handed over by couriers to stores';
+
+-- ============================================================
+-- ADD COD COLUMNS TO ORDERS AND ORDER_STOPS
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cod_amount DECIMAL(12, 2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cod_collected DECIMAL(12, 2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cod_status VARCHAR(20);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cod_note TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cod_collected_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cod_remittance_id UUID REFERENCES cod_remittances(id);
+
+CREATE INDEX IF NOT EXISTS idx_orders_cod_outstanding ON orders(courier_id, store_id)
+    WHERE cod_status = 'collected';
+CREATE INDEX IF NOT EXISTS idx_orders_cod_remittance ON orders(cod_remittance_id)
+    WHERE cod_remittance_id IS NOT NULL;
+
+COMMENT ON COLUMN orders.cod_amount IS 'Goods price the driver collects from the customer, separate from the delivery fare';
+COMMENT ON COLUMN orders.cod_status IS 'pending, collected, remitting, remitted or void; NULL when the order has no COD';
+
+ALTER TABLE order_stops ADD COLUMN IF NOT EXISTS cod_collected DECIMAL(12, 2) NOT NULL DEFAULT 0;
+
+-- ============================================================
+-- COURIER CASH LIABILITY
+-- ============================================================
+ALTER TABLE courier_wallets ADD COLUMN IF NOT EXISTS cash_liability DECIMAL(12, 2) NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN courier_wallets.cash_liability IS 'COD cash collected by the courier and not yet confirmed as remitted';
@@ -0,0 +1,10 @@
+-- Nyengo Deliveries - Order Query Indexes Migration
+-- Composite indexes for keyset (cursor) pagination of order lists: the sort column and id, per courier and per store
+
+CREATE INDEX IF NOT EXISTS idx_orders_courier_created ON orders(courier_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_store_created ON orders(store_id, created_at DESC, id DESC)
+    WHERE store_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_orders_courier_updated ON orders(courier_id, updated_at DESC, id DESC);
+
+-- Latest tracking row per order, for tracking summaries in order lists
+CREATE INDEX IF NOT EXISTS idx_delivery_tracking_order_created ON delivery_tracking(order_id, created_at DESC);
@@ -0,0 +1,30 @@
+-- Nyengo Deliveries - Parcel Scans Migration
+-- Label scans by drivers and hub staff, forming each parcel's chain of custody
+
+-- ============================================================
+-- PARCEL_SCANS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS parcel_scans (
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    courier_id UUID NOT NULL REFERENCES couriers(id),
+    event VARCHAR(30) NOT NULL,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_name VARCHAR(255),
+    location VARCHAR(255),
+    latitude DECIMAL(10, 8) NOT NULL,
+    longitude DECIMAL(11, 8) NOT NULL,
+    note TEXT,
+    status_before VARCHAR(30) NOT NULL,
+    status_after VARCHAR(30) NOT NULL,
+    scanned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_scan_event CHECK (event IN ('picked_up', 'handed_over', 'arrived_at_hub', 'loaded', 'out_for_delivery')),
+    CONSTRAINT valid_scan_actor CHECK (actor_type IN ('driver', 'hub_staff'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_parcel_scans_order ON parcel_scans(order_id, scanned_at);
+CREATE INDEX IF NOT EXISTS idx_parcel_scans_courier ON parcel_scans(courier_id, scanned_at DESC);
+
+COMMENT ON TABLE parcel_scans IS 'Label scans of parcels; whoever scans a parcel holds it until the next scan by someone else';
+COMMENT ON COLUMN parcel_scans.location IS 'Hub or place name given by the scanner';
+COMMENT ON COLUMN parcel_scans.status_after IS 'Order status after the scan; differs from status_before when the scan moved the order on';
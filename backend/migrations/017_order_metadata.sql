@@ -0,0 +1,14 @@
+-- Nyengo Deliveries - Order Metadata Migration
+-- Store-defined custom fields kept with each order
+
+-- ============================================================
+-- METADATA COLUMN ON ORDERS TABLE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+-- Metadata is always a JSON object of custom fields
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS valid_order_metadata;
+ALTER TABLE orders ADD CONSTRAINT valid_order_metadata
+    CHECK (metadata IS NULL OR jsonb_typeof(metadata) = 'object');
+
+COMMENT ON COLUMN orders.metadata IS 'Store-defined custom fields; keys starting with nyengo_ are reserved for the platform';
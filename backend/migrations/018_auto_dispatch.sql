@@ -0,0 +1,39 @@
+-- Nyengo Deliveries - Automatic Dispatch Migration
+-- Orders offered to the best-scoring courier in turn, with an audit log of every decision
+
+-- ============================================================
+-- ADD DISPATCH COLUMNS TO ORDERS TABLE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispatch_mode VARCHAR(20) NOT NULL DEFAULT 'manual';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispatch_status VARCHAR(20);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispatch_area VARCHAR(100);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS offer_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_offer_expires ON orders(offer_expires_at)
+    WHERE dispatch_status = 'offered';
+
+COMMENT ON COLUMN orders.dispatch_mode IS 'manual (the store chose the courier) or auto (dispatch engine)';
+COMMENT ON COLUMN orders.dispatch_status IS 'Auto dispatch only: offered, assigned or exhausted';
+COMMENT ON COLUMN orders.dispatch_area IS 'Service area the dispatched courier must cover';
+COMMENT ON COLUMN orders.offer_expires_at IS 'When the current courier''s offer times out and the next candidate is tried';
+
+-- ============================================================
+-- DISPATCH_LOG TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS dispatch_log (
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    round INTEGER NOT NULL,
+    courier_id UUID REFERENCES couriers(id),
+    decision VARCHAR(20) NOT NULL,
+    score DECIMAL(6, 2),
+    distance_km DECIMAL(10, 2),
+    reason TEXT,
+    factors JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_dispatch_decision CHECK (decision IN ('offered', 'ranked', 'skipped', 'declined', 'expired', 'accepted', 'no_match'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispatch_log_order ON dispatch_log(order_id, created_at);
+
+COMMENT ON TABLE dispatch_log IS 'Why each courier was offered, ranked or skipped for an automatically dispatched order, and how offers ended';
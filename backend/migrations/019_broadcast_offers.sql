@@ -0,0 +1,23 @@
+-- Nyengo Deliveries - Broadcast Offers Migration
+-- Orders offered to every eligible courier at once; the first to accept gets the order
+
+-- ============================================================
+-- UNASSIGNED ORDERS
+-- ============================================================
+-- Broadcast orders have no courier until one accepts
+ALTER TABLE orders ALTER COLUMN courier_id DROP NOT NULL;
+
+COMMENT ON COLUMN orders.dispatch_mode IS 'manual (the store chose the courier), auto (offered to one courier at a time) or broadcast (offered to all eligible couriers, first to accept wins)';
+
+CREATE INDEX IF NOT EXISTS idx_orders_unassigned ON orders(created_at)
+    WHERE courier_id IS NULL AND status = 'pending';
+
+-- ============================================================
+-- DISPATCH LOG DECISIONS
+-- ============================================================
+ALTER TABLE dispatch_log DROP CONSTRAINT IF EXISTS valid_dispatch_decision;
+ALTER TABLE dispatch_log ADD CONSTRAINT valid_dispatch_decision CHECK (decision IN (
+    'offered', 'ranked', 'skipped', 'declined', 'expired', 'accepted', 'no_match', 'withdrawn', 'escalated'
+));
+
+CREATE INDEX IF NOT EXISTS idx_dispatch_log_courier ON dispatch_log(courier_id, decision);
@@ -0,0 +1,40 @@
+-- Nyengo Deliveries - Order Transfers Migration
+-- Courier-to-courier handovers of accepted orders, with the earnings split and an audit trail
+
+-- ============================================================
+-- ORDER_TRANSFERS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS order_transfers (
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_courier_id UUID NOT NULL REFERENCES couriers(id),
+    to_courier_id UUID NOT NULL REFERENCES couriers(id),
+    requested_by VARCHAR(20) NOT NULL,
+    reason TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    order_status VARCHAR(30) NOT NULL,
+    earnings_rule VARCHAR(20),
+    original_earnings DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    from_earnings DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    to_earnings DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    response_note TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    responded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_transfer_status CHECK (status IN ('pending', 'accepted', 'rejected', 'cancelled', 'expired')),
+    CONSTRAINT valid_transfer_requester CHECK (requested_by IN ('courier', 'admin')),
+    CONSTRAINT transfer_to_another_courier CHECK (from_courier_id <> to_courier_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_transfers_order ON order_transfers(order_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_order_transfers_incoming ON order_transfers(to_courier_id, created_at)
+    WHERE status = 'pending';
+
+-- At most one handover per order can be waiting for the receiving courier
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_transfers_one_pending
+    ON order_transfers(order_id) WHERE status = 'pending';
+
+COMMENT ON TABLE order_transfers IS 'Handovers of an order from one courier to another: who proposed it, the answer and how the earnings were split';
+COMMENT ON COLUMN order_transfers.order_status IS 'Order status when the handover was proposed';
+COMMENT ON COLUMN order_transfers.from_earnings IS 'Share of the courier earnings credited to the handing-over courier on acceptance';
+COMMENT ON COLUMN order_transfers.to_earnings IS 'The order''s courier earnings after the handover, paid to the receiving courier on delivery';
@@ -0,0 +1,62 @@
+-- Nyengo Deliveries - Drivers Migration
+-- Drivers registered under a courier company, with their own logins, and the orders assigned to them
+
+-- ============================================================
+-- DRIVERS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS drivers (
+    id UUID PRIMARY KEY,
+    courier_id UUID NOT NULL REFERENCES couriers(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    phone VARCHAR(50) NOT NULL,
+    email VARCHAR(255),
+    password VARCHAR(255),
+    license_number VARCHAR(100),
+    license_expiry DATE,
+    photo_url TEXT,
+    vehicle_type VARCHAR(50),
+    vehicle_plate VARCHAR(50),
+    status VARCHAR(20) NOT NULL DEFAULT 'invited',
+    invite_code_hash VARCHAR(64),
+    invite_expires_at TIMESTAMP WITH TIME ZONE,
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_driver_status CHECK (status IN ('invited', 'active', 'suspended'))
+);
+
+-- Drivers sign in with their phone number, so it identifies one driver across all companies
+CREATE UNIQUE INDEX IF NOT EXISTS idx_drivers_phone ON drivers(phone);
+CREATE INDEX IF NOT EXISTS idx_drivers_courier ON drivers(courier_id, name);
+
+COMMENT ON TABLE drivers IS 'Drivers of a courier company; they sign in with their own credentials to work the orders assigned to them';
+COMMENT ON COLUMN drivers.password IS 'bcrypt hash, set when the driver accepts the invite';
+COMMENT ON COLUMN drivers.invite_code_hash IS 'SHA-256 of the one-time invite code; cleared once the driver has set a password';
+
+-- ============================================================
+-- DRIVER_ASSIGNMENTS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS driver_assignments (
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    driver_id UUID NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    driver_name VARCHAR(255) NOT NULL,
+    driver_phone VARCHAR(50) NOT NULL,
+    vehicle_type VARCHAR(50),
+    vehicle_plate VARCHAR(50),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    note TEXT,
+    assigned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    released_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT valid_driver_assignment_status CHECK (status IN ('pending', 'accepted', 'rejected', 'released'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_driver_assignments_driver ON driver_assignments(driver_id, assigned_at DESC);
+
+-- An order is worked by at most one driver at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_driver_assignments_one_active
+    ON driver_assignments(order_id) WHERE status IN ('pending', 'accepted');
+
+COMMENT ON TABLE driver_assignments IS 'Orders assigned to a courier''s drivers, with a snapshot of the driver and vehicle at assignment';
+COMMENT ON COLUMN driver_assignments.status IS 'pending until the driver accepts; released when the courier reassigns or the order is transferred';
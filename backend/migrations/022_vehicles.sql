@@ -0,0 +1,38 @@
+-- Nyengo Deliveries - Vehicles Migration
+-- A courier company's registered vehicles, with their capacity and document expiry dates
+
+-- ============================================================
+-- VEHICLES TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS vehicles (
+    id UUID PRIMARY KEY,
+    courier_id UUID NOT NULL REFERENCES couriers(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    plate VARCHAR(50),
+    description VARCHAR(255),
+    capacity_kg DECIMAL(10, 2) NOT NULL,
+    capacity_litres DECIMAL(10, 2) NOT NULL,
+    insurance_expiry DATE,
+    roadworthy_expiry DATE,
+    insurance_reminded_for DATE,
+    roadworthy_reminded_for DATE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_vehicle_type CHECK (type IN ('bicycle', 'motorbike', 'car', 'van', 'truck')),
+    CONSTRAINT valid_vehicle_capacity CHECK (capacity_kg > 0 AND capacity_litres > 0)
+);
+
+-- A plate identifies one vehicle across all companies
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicles_plate ON vehicles(plate);
+CREATE INDEX IF NOT EXISTS idx_vehicles_courier ON vehicles(courier_id, type);
+
+-- Expiry reminders only look at active vehicles
+CREATE INDEX IF NOT EXISTS idx_vehicles_insurance_expiry ON vehicles(insurance_expiry) WHERE is_active = TRUE;
+CREATE INDEX IF NOT EXISTS idx_vehicles_roadworthy_expiry ON vehicles(roadworthy_expiry) WHERE is_active = TRUE;
+
+COMMENT ON TABLE vehicles IS 'Vehicles of a courier company; once a courier registers any, dispatch matches packages against them instead of the courier profile';
+COMMENT ON COLUMN vehicles.plate IS 'Registration plate, stored upper case without spaces; optional for bicycles';
+COMMENT ON COLUMN vehicles.capacity_litres IS 'Cargo volume; packages are matched on the nominal volume of their size class';
+COMMENT ON COLUMN vehicles.insurance_reminded_for IS 'Insurance expiry date the courier was last reminded of; a renewed date re-arms the reminder';
+COMMENT ON COLUMN vehicles.roadworthy_reminded_for IS 'Roadworthiness expiry date the courier was last reminded of; a renewed date re-arms the reminder';
@@ -0,0 +1,32 @@
+-- Nyengo Deliveries - Shifts Migration
+-- Couriers and their drivers go online and offline; open shifts decide who can take a job now
+
+-- ============================================================
+-- SHIFTS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS shifts (
+    id UUID PRIMARY KEY,
+    courier_id UUID NOT NULL REFERENCES couriers(id) ON DELETE CASCADE,
+    driver_id UUID REFERENCES drivers(id) ON DELETE CASCADE,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_heartbeat_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMP WITH TIME ZONE,
+    end_reason VARCHAR(30),
+    CONSTRAINT valid_shift_end_reason CHECK (end_reason IN ('offline', 'heartbeat_timeout', 'driver_suspended')),
+    CONSTRAINT valid_shift_period CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shifts_courier ON shifts(courier_id, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_shifts_driver ON shifts(driver_id, started_at DESC) WHERE driver_id IS NOT NULL;
+
+-- The courier account and each driver have at most one open shift
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_one_open
+    ON shifts(courier_id, COALESCE(driver_id, '00000000-0000-0000-0000-000000000000'::uuid))
+    WHERE ended_at IS NULL;
+
+-- Open shifts are swept for missed heartbeats
+CREATE INDEX IF NOT EXISTS idx_shifts_open_heartbeat ON shifts(last_heartbeat_at) WHERE ended_at IS NULL;
+
+COMMENT ON TABLE shifts IS 'Periods a courier account or one of its drivers was online and able to take orders';
+COMMENT ON COLUMN shifts.driver_id IS 'NULL when the courier account itself went online';
+COMMENT ON COLUMN shifts.ended_at IS 'Set to the last heartbeat when the shift is closed for missed heartbeats';
@@ -0,0 +1,55 @@
+-- Nyengo Deliveries - Operating Hours Migration
+-- Couriers' operating hours are checked in their own timezone, with platform and courier holidays
+
+-- ============================================================
+-- COURIER TIMEZONE
+-- ============================================================
+ALTER TABLE couriers ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
+
+COMMENT ON COLUMN couriers.timezone IS 'IANA timezone operating hours are read in; NULL uses the platform timezone';
+
+-- ============================================================
+-- HOLIDAYS TABLE
+-- ============================================================
+CREATE TABLE IF NOT EXISTS holidays (
+    id UUID PRIMARY KEY,
+    courier_id UUID REFERENCES couriers(id) ON DELETE CASCADE,
+    country VARCHAR(100),
+    date DATE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    open_time VARCHAR(5),
+    close_time VARCHAR(5),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT holiday_country_platform_only CHECK (courier_id IS NULL OR country IS NULL),
+    CONSTRAINT holiday_hours_pair CHECK ((open_time IS NULL) = (close_time IS NULL))
+);
+
+-- One entry per day for the platform in each country, and for each courier
+CREATE UNIQUE INDEX IF NOT EXISTS idx_holidays_unique_day
+    ON holidays(COALESCE(courier_id, '00000000-0000-0000-0000-000000000000'::uuid), COALESCE(LOWER(country), ''), date);
+
+CREATE INDEX IF NOT EXISTS idx_holidays_date ON holidays(date);
+
+COMMENT ON TABLE holidays IS 'Days couriers are closed, or keep special hours, instead of their weekly operating hours';
+COMMENT ON COLUMN holidays.courier_id IS 'NULL for platform-wide holidays';
+COMMENT ON COLUMN holidays.country IS 'Country a platform holiday applies to; NULL for every country';
+COMMENT ON COLUMN holidays.open_time IS 'Special opening time (HH:MM) for the day; NULL when closed all day';
+
+-- ============================================================
+-- MALAWI PUBLIC HOLIDAYS 2026
+-- Eid al-Fitr and later years are added through the admin API once announced
+-- ============================================================
+INSERT INTO holidays (id, country, date, name) VALUES
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a1', 'Malawi', '2026-01-01', 'New Year''s Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a2', 'Malawi', '2026-01-15', 'John Chilembwe Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a3', 'Malawi', '2026-03-03', 'Martyrs'' Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a4', 'Malawi', '2026-04-03', 'Good Friday'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a5', 'Malawi', '2026-04-06', 'Easter Monday'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a6', 'Malawi', '2026-05-01', 'Labour Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a7', 'Malawi', '2026-05-14', 'Kamuzu Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a8', 'Malawi', '2026-07-06', 'Independence Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000a9', 'Malawi', '2026-10-15', 'Mother''s Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000aa', 'Malawi', '2026-12-25', 'Christmas Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000ab', 'Malawi', '2026-12-26', 'Boxing Day'),
+    ('b7a1e0c2-0001-4c6e-9a51-2026000000ac', 'Malawi', '2026-12-28', 'Boxing Day (observed)')
+ON CONFLICT DO NOTHING;
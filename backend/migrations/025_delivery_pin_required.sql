@@ -0,0 +1,14 @@
+-- Nyengo Deliveries - Delivery PIN Required Migration
+-- Records whether delivery needs the customer's PIN when the order goes in transit, so a PIN that
+-- fails to be issued blocks delivery instead of skipping the check
+
+-- ============================================================
+-- ADD DELIVERY PIN REQUIRED FLAG TO ORDERS TABLE
+-- ============================================================
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_pin_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN orders.delivery_pin_required IS 'Set by the transition to in_transit; delivery is blocked until the PIN is verified';
+
+-- Orders already in transit with an issued PIN keep needing it
+UPDATE orders SET delivery_pin_required = TRUE
+WHERE delivery_pin_hash IS NOT NULL AND status IN ('in_transit', 'reattempt_scheduled');
@@ -0,0 +1,9 @@
+-- Nyengo Deliveries - Parcel Scan Drivers Migration
+-- Drivers scan parcels with their own tokens; scans record which driver made them
+
+-- ============================================================
+-- ADD DRIVER TO PARCEL SCANS
+-- ============================================================
+ALTER TABLE parcel_scans ADD COLUMN IF NOT EXISTS driver_id UUID REFERENCES drivers(id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN parcel_scans.driver_id IS 'Driver whose token made the scan; NULL for the courier account';
@@ -0,0 +1,11 @@
+-- Nyengo Deliveries - Driver Invite Attempts Migration
+-- Wrong invite codes are counted per driver, and activation is locked after too many
+
+-- ============================================================
+-- ADD INVITE ATTEMPT COLUMNS TO DRIVERS TABLE
+-- ============================================================
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS invite_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE drivers ADD COLUMN IF NOT EXISTS invite_locked_until TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN drivers.invite_attempts IS 'Activation attempts since the invite was sent or the last lockout ended';
+COMMENT ON COLUMN drivers.invite_locked_until IS 'Activation is refused until then after too many wrong invite codes';